@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// Engine types of the behaviors.
+const (
+	KustomizeEngineType = "kustomize"
+)
+
+// Behavior specifies how the parent resources of a StackDefinition are
+// rendered into their child resources.
+type Behavior struct {
+	// CRD is the kind of the parent resources.
+	CRD BehaviorCRD `json:"crd"`
+
+	// Engine is the configuration of the engine that renders the parent
+	// resources.
+	Engine EngineConfiguration `json:"engine"`
+}
+
+// BehaviorCRD represents the kind of the parent resources.
+type BehaviorCRD struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// EngineConfiguration represents the configuration of an engine, such as
+// helm3 or kustomize.
+type EngineConfiguration struct {
+	// ControllerImage is the image of the controller that reconciles the
+	// parent resources.
+	// +optional
+	ControllerImage string `json:"controllerImage,omitempty"`
+
+	// Type is the type of the engine, such as helm3 or kustomize.
+	Type string `json:"type"`
+
+	// Kustomize is the configuration of the kustomize engine.
+	// +optional
+	Kustomize *KustomizeEngineConfiguration `json:"kustomize,omitempty"`
+}
+
+// KustomizeEngineConfiguration provides the configuration of the kustomize
+// engine.
+type KustomizeEngineConfiguration struct {
+	// Overlays are the objects that are generated from the fields of the
+	// parent resources and patched over the resources of kustomization.
+	// +optional
+	Overlays []KustomizeEngineOverlay `json:"overlays,omitempty"`
+
+	// Kustomization is the kustomization that the overlays are added to.
+	// +optional
+	Kustomization *kustomizetypes.Kustomization `json:"kustomization,omitempty"`
+
+	// Components are the directories of the kustomize components that are
+	// listed in the components field of the kustomization. They're kept
+	// apart since the kustomization type doesn't have that field.
+	// +optional
+	Components []string `json:"components,omitempty"`
+}
+
+// KustomizeEngineOverlay is an object whose fields are bound to the fields of
+// the parent resources, and which is patched over the object of the same
+// kind, name and namespace in the resources of kustomization.
+type KustomizeEngineOverlay struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+
+	// Namespace of the patched object. It's given in the name of the
+	// overlay of the StackDefinition in namespace/name format, since the
+	// overlays of the StackDefinition don't have that field. Only the
+	// objects without a namespace are patched if it's empty.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	Bindings []FieldBinding `json:"bindings"`
+}
+
+// FieldBinding binds a field of the parent resource to a field of the
+// overlay. The fields are dot separated paths, e.g. spec.engineVersion.
+type FieldBinding struct {
+	From string `json:"from"`
+
+	// To is the field of the overlay. The items of lists can be addressed
+	// by their index, e.g. spec.template.spec.containers[0].image, and a
+	// trailing [-] appends to a list, e.g. spec.args[-]. It defaults to From.
+	// +optional
+	To string `json:"to,omitempty"`
+
+	// Required makes the rendering fail if the From field of the parent
+	// resource is not set. Otherwise, the binding is skipped.
+	// +optional
+	Required bool `json:"required,omitempty"`
+
+	// Default is the value of the To field if the From field of the parent
+	// resource is not set.
+	// +optional
+	Default interface{} `json:"default,omitempty"`
+
+	// Transform is a Go template that is executed with the value of the From
+	// field as data, which is nil if it's not set, and whose output is parsed
+	// as YAML and used as the value of the To field instead, e.g.
+	// "{{ . }}-replica". The binding is skipped if the output is empty.
+	// +optional
+	Transform string `json:"transform,omitempty"`
+}
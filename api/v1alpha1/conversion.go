@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	packagesv1alpha1 "github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+const (
+	// RequiredBindingsAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of a list of the from fields of
+	// the overlay bindings that are required. The bindings of StackDefinition
+	// don't have a field for that.
+	RequiredBindingsAnnotationKey = "templatestacks.crossplane.io/kustomize-required-bindings"
+
+	// BindingDefaultsAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of a map of the from fields of
+	// the overlay bindings to their default values. The bindings of
+	// StackDefinition don't have a field for that.
+	BindingDefaultsAnnotationKey = "templatestacks.crossplane.io/kustomize-binding-defaults"
+
+	// BindingTransformsAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of a map of the from fields of
+	// the overlay bindings to their transforms. The bindings of
+	// StackDefinition don't have a field for that.
+	BindingTransformsAnnotationKey = "templatestacks.crossplane.io/kustomize-binding-transforms"
+
+	componentsField = "components"
+
+	errConvertKustomization = "cannot convert the kustomization of the behavior"
+	errInvalidBehavior      = "invalid behavior of the StackDefinition"
+	errFmtParseAnnotation   = "cannot parse the value of %s annotation"
+	errFmtUnknownRequired   = "required field %s is not read by any binding of the overlays"
+	errFmtUnknownDefault    = "defaulted field %s is not read by any binding of the overlays"
+	errFmtUnknownTransform  = "transformed field %s is not read by any binding of the overlays"
+)
+
+// BehaviorOf returns the defaulted and validated behavior of the given
+// StackDefinition.
+func BehaviorOf(sd *packagesv1alpha1.StackDefinition) (*Behavior, error) {
+	b, err := ConvertBehavior(&sd.Spec.Behavior)
+	if err != nil {
+		return nil, err
+	}
+	if val, ok := sd.GetAnnotations()[RequiredBindingsAnnotationKey]; ok {
+		if err := b.requireBindings(val); err != nil {
+			return nil, err
+		}
+	}
+	if val, ok := sd.GetAnnotations()[BindingDefaultsAnnotationKey]; ok {
+		if err := b.defaultBindings(val); err != nil {
+			return nil, err
+		}
+	}
+	if val, ok := sd.GetAnnotations()[BindingTransformsAnnotationKey]; ok {
+		if err := b.transformBindings(val); err != nil {
+			return nil, err
+		}
+	}
+	b.Default()
+	if errs := b.Validate(); len(errs) != 0 {
+		return nil, errors.Wrap(errs.ToAggregate(), errInvalidBehavior)
+	}
+	return b, nil
+}
+
+// requireBindings marks the overlay bindings that read from the fields in the
+// given YAML list as required.
+func (b *Behavior) requireBindings(data string) error {
+	var fields []string
+	if err := yaml.Unmarshal([]byte(data), &fields); err != nil {
+		return errors.Wrapf(err, errFmtParseAnnotation, RequiredBindingsAnnotationKey)
+	}
+	for _, f := range fields {
+		if !b.eachBinding(f, func(bd *FieldBinding) { bd.Required = true }) {
+			return errors.Errorf(errFmtUnknownRequired, f)
+		}
+	}
+	return nil
+}
+
+// defaultBindings sets the default values of the overlay bindings that read
+// from the fields in the given YAML map to their values in the map.
+func (b *Behavior) defaultBindings(data string) error {
+	defaults := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(data), &defaults); err != nil {
+		return errors.Wrapf(err, errFmtParseAnnotation, BindingDefaultsAnnotationKey)
+	}
+	for f, val := range defaults {
+		if !b.eachBinding(f, func(bd *FieldBinding) { bd.Default = runtime.DeepCopyJSONValue(val) }) {
+			return errors.Errorf(errFmtUnknownDefault, f)
+		}
+	}
+	return nil
+}
+
+// transformBindings sets the transforms of the overlay bindings that read from
+// the fields in the given YAML map to their values in the map.
+func (b *Behavior) transformBindings(data string) error {
+	transforms := map[string]string{}
+	if err := yaml.Unmarshal([]byte(data), &transforms); err != nil {
+		return errors.Wrapf(err, errFmtParseAnnotation, BindingTransformsAnnotationKey)
+	}
+	for f, t := range transforms {
+		if !b.eachBinding(f, func(bd *FieldBinding) { bd.Transform = t }) {
+			return errors.Errorf(errFmtUnknownTransform, f)
+		}
+	}
+	return nil
+}
+
+// eachBinding calls the given function with every overlay binding that reads
+// from the given field, and returns false if there is none.
+func (b *Behavior) eachBinding(from string, fn func(*FieldBinding)) bool {
+	if b.Engine.Kustomize == nil {
+		return false
+	}
+	found := false
+	for i := range b.Engine.Kustomize.Overlays {
+		for j := range b.Engine.Kustomize.Overlays[i].Bindings {
+			if bd := &b.Engine.Kustomize.Overlays[i].Bindings[j]; bd.From == from {
+				fn(bd)
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// ConvertBehavior converts the given behavior of a StackDefinition into a
+// Behavior. The kustomization is decoded into its typed form, and its
+// unknown fields are rejected.
+func ConvertBehavior(in *packagesv1alpha1.Behavior) (*Behavior, error) {
+	out := &Behavior{
+		CRD: BehaviorCRD{
+			APIVersion: in.CRD.APIVersion,
+			Kind:       in.CRD.Kind,
+		},
+		Engine: EngineConfiguration{
+			ControllerImage: in.Engine.ControllerImage,
+			Type:            in.Engine.Type,
+		},
+	}
+	k := in.Engine.Kustomize
+	if k == nil {
+		return out, nil
+	}
+	out.Engine.Kustomize = &KustomizeEngineConfiguration{}
+	for _, o := range k.Overlays {
+		overlay := KustomizeEngineOverlay{
+			APIVersion: o.APIVersion,
+			Kind:       o.Kind,
+			Name:       o.Name,
+		}
+		if i := strings.Index(o.Name, "/"); i != -1 {
+			overlay.Namespace, overlay.Name = o.Name[:i], o.Name[i+1:]
+		}
+		for _, b := range o.Bindings {
+			overlay.Bindings = append(overlay.Bindings, FieldBinding{From: b.From, To: b.To})
+		}
+		out.Engine.Kustomize.Overlays = append(out.Engine.Kustomize.Overlays, overlay)
+	}
+	if k.Kustomization == nil {
+		return out, nil
+	}
+	content := k.Kustomization.DeepCopy().UnstructuredContent()
+	components, _, err := unstructured.NestedStringSlice(content, componentsField)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertKustomization)
+	}
+	out.Engine.Kustomize.Components = components
+	delete(content, componentsField)
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertKustomization)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	out.Engine.Kustomize.Kustomization = &kustomizetypes.Kustomization{}
+	if err := d.Decode(out.Engine.Kustomize.Kustomization); err != nil {
+		return nil, errors.Wrap(err, errConvertKustomization)
+	}
+	return out, nil
+}
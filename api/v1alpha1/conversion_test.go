@@ -0,0 +1,266 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	packagesv1alpha1 "github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+func TestBehaviorOf(t *testing.T) {
+	crd := packagesv1alpha1.BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"}
+	overlays := []packagesv1alpha1.KustomizeEngineOverlay{{
+		APIVersion: "database.crossplane.io/v1alpha1",
+		Kind:       "MySQLInstance",
+		Name:       "sql",
+		Bindings:   []packagesv1alpha1.FieldBinding{{From: "spec.engineVersion"}},
+	}, {
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       "monitoring/config",
+		Bindings:   []packagesv1alpha1.FieldBinding{{From: "spec.scrapeInterval", To: "data.interval"}},
+	}}
+	sd := func(engine packagesv1alpha1.StackResourceEngineConfiguration) *packagesv1alpha1.StackDefinition {
+		return &packagesv1alpha1.StackDefinition{
+			Spec: packagesv1alpha1.StackDefinitionSpec{
+				Behavior: packagesv1alpha1.Behavior{CRD: crd, Engine: engine},
+			},
+		}
+	}
+	withAnnotations := func(sd *packagesv1alpha1.StackDefinition, annotations map[string]string) *packagesv1alpha1.StackDefinition {
+		sd.SetAnnotations(annotations)
+		return sd
+	}
+	type want struct {
+		b   *Behavior
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		sd     *packagesv1alpha1.StackDefinition
+		want   want
+	}{
+		"Kustomize": {
+			reason: "The kustomization should be typed with its components kept apart, the namespaces of the overlays should be split from their names and the bindings should be defaulted.",
+			sd: sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type: KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{
+					Overlays: overlays,
+					Kustomization: &unstructured.Unstructured{Object: map[string]interface{}{
+						"namespace":    "apps",
+						"commonLabels": map[string]interface{}{"team": "data"},
+						"components":   []interface{}{"components/monitoring"},
+					}},
+				},
+			}),
+			want: want{b: &Behavior{
+				CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"},
+				Engine: EngineConfiguration{
+					Type: KustomizeEngineType,
+					Kustomize: &KustomizeEngineConfiguration{
+						Overlays: []KustomizeEngineOverlay{{
+							APIVersion: "database.crossplane.io/v1alpha1",
+							Kind:       "MySQLInstance",
+							Name:       "sql",
+							Bindings:   []FieldBinding{{From: "spec.engineVersion", To: "spec.engineVersion"}},
+						}, {
+							APIVersion: "v1",
+							Kind:       "ConfigMap",
+							Name:       "config",
+							Namespace:  "monitoring",
+							Bindings:   []FieldBinding{{From: "spec.scrapeInterval", To: "data.interval"}},
+						}},
+						Kustomization: &kustomizetypes.Kustomization{
+							Namespace:    "apps",
+							CommonLabels: map[string]string{"team": "data"},
+						},
+						Components: []string{"components/monitoring"},
+					},
+				},
+			}},
+		},
+		"RequiredBindings": {
+			reason: "The bindings that read from the fields in the annotation should be required.",
+			sd: withAnnotations(sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type:      KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{Overlays: overlays[:1]},
+			}), map[string]string{RequiredBindingsAnnotationKey: "[spec.engineVersion]"}),
+			want: want{b: &Behavior{
+				CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"},
+				Engine: EngineConfiguration{
+					Type: KustomizeEngineType,
+					Kustomize: &KustomizeEngineConfiguration{
+						Overlays: []KustomizeEngineOverlay{{
+							APIVersion: "database.crossplane.io/v1alpha1",
+							Kind:       "MySQLInstance",
+							Name:       "sql",
+							Bindings:   []FieldBinding{{From: "spec.engineVersion", To: "spec.engineVersion", Required: true}},
+						}},
+						Kustomization: &kustomizetypes.Kustomization{},
+					},
+				},
+			}},
+		},
+		"BindingDefaults": {
+			reason: "The bindings that read from the fields in the annotation should have their default values.",
+			sd: withAnnotations(sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type:      KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{Overlays: overlays[:1]},
+			}), map[string]string{BindingDefaultsAnnotationKey: `{spec.engineVersion: "5.7"}`}),
+			want: want{b: &Behavior{
+				CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"},
+				Engine: EngineConfiguration{
+					Type: KustomizeEngineType,
+					Kustomize: &KustomizeEngineConfiguration{
+						Overlays: []KustomizeEngineOverlay{{
+							APIVersion: "database.crossplane.io/v1alpha1",
+							Kind:       "MySQLInstance",
+							Name:       "sql",
+							Bindings:   []FieldBinding{{From: "spec.engineVersion", To: "spec.engineVersion", Default: "5.7"}},
+						}},
+						Kustomization: &kustomizetypes.Kustomization{},
+					},
+				},
+			}},
+		},
+		"BindingTransforms": {
+			reason: "The bindings that read from the fields in the annotation should have their transforms.",
+			sd: withAnnotations(sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type:      KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{Overlays: overlays[:1]},
+			}), map[string]string{BindingTransformsAnnotationKey: `{spec.engineVersion: "{{ . }}.0"}`}),
+			want: want{b: &Behavior{
+				CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"},
+				Engine: EngineConfiguration{
+					Type: KustomizeEngineType,
+					Kustomize: &KustomizeEngineConfiguration{
+						Overlays: []KustomizeEngineOverlay{{
+							APIVersion: "database.crossplane.io/v1alpha1",
+							Kind:       "MySQLInstance",
+							Name:       "sql",
+							Bindings:   []FieldBinding{{From: "spec.engineVersion", To: "spec.engineVersion", Transform: "{{ . }}.0"}},
+						}},
+						Kustomization: &kustomizetypes.Kustomization{},
+					},
+				},
+			}},
+		},
+		"RequiredBindingWithDefault": {
+			reason: "A required binding should not have a default value.",
+			sd: withAnnotations(sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type:      KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{Overlays: overlays[:1]},
+			}), map[string]string{
+				RequiredBindingsAnnotationKey: "[spec.engineVersion]",
+				BindingDefaultsAnnotationKey:  `{spec.engineVersion: "5.7"}`,
+			}),
+			want: want{err: errors.Wrap(field.ErrorList{
+				field.Invalid(field.NewPath("engine", "kustomize", "overlays").Index(0).Child("bindings").Index(0).Child("default"), "5.7", errRequiredDefault),
+			}.ToAggregate(), errInvalidBehavior)},
+		},
+		"UnknownRequiredBinding": {
+			reason: "A required field that no binding reads from should be rejected.",
+			sd: withAnnotations(sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type:      KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{Overlays: overlays[:1]},
+			}), map[string]string{RequiredBindingsAnnotationKey: "[spec.region]"}),
+			want: want{err: errors.Errorf(errFmtUnknownRequired, "spec.region")},
+		},
+		"KustomizeWithoutConfiguration": {
+			reason: "An empty configuration should be defaulted for the kustomize engine.",
+			sd:     sd(packagesv1alpha1.StackResourceEngineConfiguration{Type: KustomizeEngineType}),
+			want: want{b: &Behavior{
+				CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1", Kind: "App"},
+				Engine: EngineConfiguration{
+					Type:      KustomizeEngineType,
+					Kustomize: &KustomizeEngineConfiguration{Kustomization: &kustomizetypes.Kustomization{}},
+				},
+			}},
+		},
+		"UnknownKustomizationField": {
+			reason: "The unknown fields of the kustomization should be rejected.",
+			sd: sd(packagesv1alpha1.StackResourceEngineConfiguration{
+				Type: KustomizeEngineType,
+				Kustomize: &packagesv1alpha1.KustomizeEngineConfiguration{
+					Kustomization: &unstructured.Unstructured{Object: map[string]interface{}{"namePrefx": "app-"}},
+				},
+			}),
+			want: want{err: errors.Wrap(errors.New(`json: unknown field "namePrefx"`), errConvertKustomization)},
+		},
+		"Invalid": {
+			reason: "The invalid fields of the behavior should be reported.",
+			sd:     &packagesv1alpha1.StackDefinition{},
+			want: want{err: errors.Wrap(field.ErrorList{
+				field.Required(field.NewPath("crd", "apiVersion"), ""),
+				field.Required(field.NewPath("crd", "kind"), ""),
+				field.Required(field.NewPath("engine", "type"), ""),
+			}.ToAggregate(), errInvalidBehavior)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := BehaviorOf(tc.sd)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nBehaviorOf(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.b, got); diff != "" {
+				t.Errorf("\n%s\nBehaviorOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	b := &Behavior{
+		CRD: BehaviorCRD{APIVersion: "example.org/v1alpha1/extra", Kind: "App"},
+		Engine: EngineConfiguration{
+			Type: KustomizeEngineType,
+			Kustomize: &KustomizeEngineConfiguration{
+				Overlays: []KustomizeEngineOverlay{{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Namespace:  "Apps",
+					Bindings: []FieldBinding{
+						{From: "spec..name", To: "data.name"},
+						{From: "spec.image", To: "spec.containers[0].image"},
+						{From: "spec.arg", To: "spec.containers[-].args"},
+					},
+				}},
+			},
+		},
+	}
+	overlay := field.NewPath("engine", "kustomize", "overlays").Index(0)
+	want := field.ErrorList{
+		field.Invalid(field.NewPath("crd", "apiVersion"), "example.org/v1alpha1/extra", "unexpected GroupVersion string: example.org/v1alpha1/extra"),
+		field.Required(overlay.Child("name"), ""),
+		field.Invalid(overlay.Child("namespace"), "Apps", validation.IsDNS1123Label("Apps")[0]),
+		field.Invalid(overlay.Child("bindings").Index(0).Child("from"), "spec..name", errInvalidPath),
+		field.Invalid(overlay.Child("bindings").Index(2).Child("to"), "spec.containers[-].args", errInvalidToPath),
+	}
+	if diff := cmp.Diff(want, b.Validate()); diff != "" {
+		t.Errorf("Validate(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// DeepCopyInto copies the receiver into out. The receiver must be non-nil.
+func (in *Behavior) DeepCopyInto(out *Behavior) {
+	*out = *in
+	in.Engine.DeepCopyInto(&out.Engine)
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *Behavior) DeepCopy() *Behavior {
+	if in == nil {
+		return nil
+	}
+	out := new(Behavior)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out. The receiver must be non-nil.
+func (in *EngineConfiguration) DeepCopyInto(out *EngineConfiguration) {
+	*out = *in
+	if in.Kustomize != nil {
+		out.Kustomize = in.Kustomize.DeepCopy()
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *EngineConfiguration) DeepCopy() *EngineConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out. The receiver must be non-nil.
+func (in *KustomizeEngineConfiguration) DeepCopyInto(out *KustomizeEngineConfiguration) {
+	*out = *in
+	if in.Overlays != nil {
+		out.Overlays = make([]KustomizeEngineOverlay, len(in.Overlays))
+		for i := range in.Overlays {
+			in.Overlays[i].DeepCopyInto(&out.Overlays[i])
+		}
+	}
+	if in.Kustomization != nil {
+		out.Kustomization = copyKustomization(in.Kustomization)
+	}
+	if in.Components != nil {
+		out.Components = make([]string, len(in.Components))
+		copy(out.Components, in.Components)
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *KustomizeEngineConfiguration) DeepCopy() *KustomizeEngineConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizeEngineConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out. The receiver must be non-nil.
+func (in *KustomizeEngineOverlay) DeepCopyInto(out *KustomizeEngineOverlay) {
+	*out = *in
+	if in.Bindings != nil {
+		out.Bindings = make([]FieldBinding, len(in.Bindings))
+		for i := range in.Bindings {
+			in.Bindings[i].DeepCopyInto(&out.Bindings[i])
+		}
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *KustomizeEngineOverlay) DeepCopy() *KustomizeEngineOverlay {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizeEngineOverlay)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out. The receiver must be non-nil.
+func (in *FieldBinding) DeepCopyInto(out *FieldBinding) {
+	*out = *in
+	if in.Default != nil {
+		out.Default = runtime.DeepCopyJSONValue(in.Default)
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *FieldBinding) DeepCopy() *FieldBinding {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldBinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// copyKustomization returns a deep copy of the given kustomization. The
+// kustomize types have no deep copy functions, so it's copied through its
+// JSON form that consists of plain data only.
+func copyKustomization(in *kustomizetypes.Kustomization) *kustomizetypes.Kustomization {
+	data, err := json.Marshal(in)
+	if err != nil {
+		panic(err)
+	}
+	out := &kustomizetypes.Kustomization{}
+	if err := json.Unmarshal(data, out); err != nil {
+		panic(err)
+	}
+	return out
+}
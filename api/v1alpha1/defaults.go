@@ -0,0 +1,47 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	kustomizetypes "sigs.k8s.io/kustomize/api/types"
+)
+
+// Default sets the default values of the unset fields of the Behavior.
+func (b *Behavior) Default() {
+	if b.Engine.Type != KustomizeEngineType {
+		return
+	}
+	if b.Engine.Kustomize == nil {
+		b.Engine.Kustomize = &KustomizeEngineConfiguration{}
+	}
+	b.Engine.Kustomize.Default()
+}
+
+// Default sets the default values of the unset fields of the
+// KustomizeEngineConfiguration.
+func (c *KustomizeEngineConfiguration) Default() {
+	if c.Kustomization == nil {
+		c.Kustomization = &kustomizetypes.Kustomization{}
+	}
+	for i := range c.Overlays {
+		for j := range c.Overlays[i].Bindings {
+			if b := &c.Overlays[i].Bindings[j]; b.To == "" {
+				b.To = b.From
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AppendIndex is the index of a path that appends to a list, e.g.
+// spec.args[-].
+const AppendIndex = "-"
+
+var (
+	segmentRegex = regexp.MustCompile(`^([^.\[\]]+)((?:\[(?:\d+|-)\])*)$`)
+	indexRegex   = regexp.MustCompile(`\[(\d+|-)\]`)
+)
+
+// IndexesLists returns true if the To field of the binding addresses the
+// items of lists, e.g. spec.template.spec.containers[0].image or
+// spec.args[-].
+func (b FieldBinding) IndexesLists() bool {
+	return strings.Contains(b.To, "[")
+}
+
+// ToPointer returns the JSON pointer of the To field of the binding, e.g.
+// /spec/template/spec/containers/0/image, and false if it's not a valid path.
+func (b FieldBinding) ToPointer() (string, bool) {
+	var sb strings.Builder
+	segments := strings.Split(b.To, ".")
+	for i, s := range segments {
+		m := segmentRegex.FindStringSubmatch(s)
+		if m == nil {
+			return "", false
+		}
+		sb.WriteString("/" + escapePointer(m[1]))
+		indexes := indexRegex.FindAllStringSubmatch(m[2], -1)
+		for j, idx := range indexes {
+			// NOTE: Only the last item can be appended since there is
+			// nothing to address in an item that doesn't exist yet.
+			if idx[1] == AppendIndex && (i != len(segments)-1 || j != len(indexes)-1) {
+				return "", false
+			}
+			sb.WriteString("/" + idx[1])
+		}
+	}
+	return sb.String(), true
+}
+
+// escapePointer escapes the given field name to be used as a token of a JSON
+// pointer.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestToPointer(t *testing.T) {
+	type want struct {
+		pointer string
+		ok      bool
+	}
+	cases := map[string]struct {
+		reason string
+		to     string
+		want   want
+	}{
+		"Fields": {
+			reason: "A path of fields should be converted to a pointer.",
+			to:     "spec.engineVersion",
+			want:   want{pointer: "/spec/engineVersion", ok: true},
+		},
+		"Index": {
+			reason: "The items of lists should be addressed by their index.",
+			to:     "spec.template.spec.containers[0].image",
+			want:   want{pointer: "/spec/template/spec/containers/0/image", ok: true},
+		},
+		"NestedLists": {
+			reason: "The items of nested lists should be addressed by their indexes.",
+			to:     "spec.matrix[1][2]",
+			want:   want{pointer: "/spec/matrix/1/2", ok: true},
+		},
+		"Append": {
+			reason: "A trailing [-] should append to the list.",
+			to:     "spec.args[-]",
+			want:   want{pointer: "/spec/args/-", ok: true},
+		},
+		"Escaped": {
+			reason: "The special characters of JSON pointers should be escaped.",
+			to:     "metadata.annotations.example.org/owner",
+			want:   want{pointer: "/metadata/annotations/example/org~1owner", ok: true},
+		},
+		"AppendInTheMiddle": {
+			reason: "An item that doesn't exist yet cannot be addressed.",
+			to:     "spec.containers[-].image",
+		},
+		"InvalidIndex": {
+			reason: "An index should be a non-negative integer.",
+			to:     "spec.containers[x].image",
+		},
+		"EmptyField": {
+			reason: "A path should not have empty fields.",
+			to:     "spec..image",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			pointer, ok := FieldBinding{To: tc.to}.ToPointer()
+			if diff := cmp.Diff(tc.want, want{pointer: pointer, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nToPointer(): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	errInvalidPath     = "must be a dot separated path of fields"
+	errInvalidToPath   = "must be a dot separated path of fields whose items of lists are given as [index], or [-] at the end to append"
+	errRequiredDefault = "cannot be given for a required binding"
+)
+
+// Validate returns the errors of the fields of the Behavior. The engine type
+// is not checked against the supported types, which is up to the
+// controller.
+func (b *Behavior) Validate() field.ErrorList {
+	var errs field.ErrorList
+	crd := field.NewPath("crd")
+	if b.CRD.APIVersion == "" {
+		errs = append(errs, field.Required(crd.Child("apiVersion"), ""))
+	} else if _, err := schema.ParseGroupVersion(b.CRD.APIVersion); err != nil {
+		errs = append(errs, field.Invalid(crd.Child("apiVersion"), b.CRD.APIVersion, err.Error()))
+	}
+	if b.CRD.Kind == "" {
+		errs = append(errs, field.Required(crd.Child("kind"), ""))
+	}
+	engine := field.NewPath("engine")
+	if b.Engine.Type == "" {
+		errs = append(errs, field.Required(engine.Child("type"), ""))
+	}
+	if b.Engine.Kustomize != nil {
+		errs = append(errs, b.Engine.Kustomize.validate(engine.Child("kustomize"))...)
+	}
+	return errs
+}
+
+func (c *KustomizeEngineConfiguration) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, o := range c.Overlays {
+		p := path.Child("overlays").Index(i)
+		if o.APIVersion == "" {
+			errs = append(errs, field.Required(p.Child("apiVersion"), ""))
+		}
+		if o.Kind == "" {
+			errs = append(errs, field.Required(p.Child("kind"), ""))
+		}
+		if o.Name == "" {
+			errs = append(errs, field.Required(p.Child("name"), ""))
+		}
+		if o.Namespace != "" {
+			for _, msg := range validation.IsDNS1123Label(o.Namespace) {
+				errs = append(errs, field.Invalid(p.Child("namespace"), o.Namespace, msg))
+			}
+		}
+		for j, b := range o.Bindings {
+			bp := p.Child("bindings").Index(j)
+			if !validPath(b.From) {
+				errs = append(errs, field.Invalid(bp.Child("from"), b.From, errInvalidPath))
+			}
+			if _, ok := b.ToPointer(); !ok {
+				errs = append(errs, field.Invalid(bp.Child("to"), b.To, errInvalidToPath))
+			}
+			if b.Required && b.Default != nil {
+				errs = append(errs, field.Invalid(bp.Child("default"), b.Default, errRequiredDefault))
+			}
+		}
+	}
+	for i, comp := range c.Components {
+		if comp == "" {
+			errs = append(errs, field.Required(path.Child("kustomization", "components").Index(i), ""))
+		}
+	}
+	return errs
+}
+
+// validPath returns true if the given dot separated path has no empty
+// fields.
+func validPath(p string) bool {
+	for _, f := range strings.Split(p, ".") {
+		if f == "" {
+			return false
+		}
+	}
+	return true
+}
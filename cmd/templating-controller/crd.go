@@ -0,0 +1,113 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/install"
+	"github.com/crossplane/templating-controller/pkg/openapi"
+	"github.com/crossplane/templating-controller/pkg/operations/kustomize"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// runCRD writes the CustomResourceDefinition of the parent resource of the
+// given StackDefinition with an OpenAPI v3 schema derived from the chart or
+// the overlay bindings.
+func runCRD(w io.Writer, sdFile, resourceDir, scope string) error {
+	sd, err := readStackDefinition(sdFile)
+	if err != nil {
+		return err
+	}
+	crd, err := newCRD(sd, resourceDir, scope)
+	if err != nil {
+		return err
+	}
+	return writeManifests(w, crd)
+}
+
+// newCRD returns the CustomResourceDefinition of the parent resource of the
+// given StackDefinition with structural validation.
+func newCRD(sd *v1alpha1.StackDefinition, resourceDir, scope string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	s, scale, err := parentSchema(sd, resourceDir)
+	if err != nil {
+		return nil, err
+	}
+	gvk := schema.FromAPIVersionAndKind(sd.Spec.Behavior.CRD.APIVersion, sd.Spec.Behavior.CRD.Kind)
+	crd := install.NewCustomResourceDefinition(gvk, apiextensionsv1.ResourceScope(scope), s)
+	crd.Spec.Versions[0].Subresources.Scale = scale
+	return crd, nil
+}
+
+// parentSchema returns the OpenAPI v3 schema of the parent resource of the
+// given StackDefinition, along with its scale subresource if the
+// StackDefinition declares one. The fields of the scale subresource and the
+// fields that the JSON 6902 patches and the common metadata read from are
+// added to the schema, along with the field that selects the variant.
+func parentSchema(sd *v1alpha1.StackDefinition, resourceDir string) (*apiextensionsv1.JSONSchemaProps, *apiextensionsv1.CustomResourceSubresourceScale, error) {
+	s, err := openapi.ForStackDefinition(sd, resourceDir)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot generate OpenAPI v3 schema")
+	}
+	patches, err := json6902Patches(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := commonMetadata(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	images, err := imageBindings(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	replicas, err := replicaBindings(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := dataGenerators(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := append(kustomize.Json6902ValueFields(patches), m.Fields()...)
+	fields = append(fields, kustomize.ImageFields(images)...)
+	fields = append(fields, kustomize.ReplicaFields(replicas)...)
+	s = openapi.WithFields(s, append(fields, data.Fields()...)...)
+	v, err := variants(sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v.Field != "" {
+		s = openapi.WithEnum(s, v.Field, v.Names()...)
+	}
+	scaleData, ok := sd.GetAnnotations()[templating.ScaleAnnotationKey]
+	if !ok {
+		return s, nil, nil
+	}
+	sc, err := templating.ParseScale(scaleData)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot parse the value of %s annotation", templating.ScaleAnnotationKey)
+	}
+	scale := sc.Subresource()
+	return openapi.WithScale(s, scale), scale, nil
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// The annotations and the label that Helm marks the objects of a release
+// with.
+const (
+	helmReleaseNameAnnotationKey      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotationKey = "meta.helm.sh/release-namespace"
+	helmManagedByLabelKey             = "app.kubernetes.io/managed-by"
+	helmManagedByLabelValue           = "Helm"
+)
+
+// importConfig is the input of the import subcommand.
+type importConfig struct {
+	StackDefinitionFile string
+	Release             string
+	ReleaseNamespace    string
+	Name                string
+	Namespace           string
+	ForgetRelease       bool
+}
+
+// runImport adopts the live objects of the deployed revision of a Helm release
+// under a new parent resource whose spec is the values of the release, so that
+// they are managed by the templating controller from then on without being
+// recreated. The objects get the parent resource as their controller and the
+// stack label, lose the ownership marks of Helm, and are listed in the
+// status.resourceRefs field of the parent resource.
+func runImport(w io.Writer, cfg importConfig) error { // nolint:gocyclo
+	sd, err := readStackDefinition(cfg.StackDefinitionFile)
+	if err != nil {
+		return err
+	}
+	rc, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "cannot get the kubeconfig")
+	}
+	cs, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return errors.Wrap(err, "cannot create the Kubernetes clientset")
+	}
+	mapper, err := apiutil.NewDynamicRESTMapper(rc)
+	if err != nil {
+		return errors.Wrap(err, "cannot create the REST mapper")
+	}
+	kube, err := client.New(rc, client.Options{Mapper: mapper})
+	if err != nil {
+		return errors.Wrap(err, "cannot create the Kubernetes client")
+	}
+	ctx := context.Background()
+
+	rel, err := deployedRelease(driver.NewSecrets(cs.CoreV1().Secrets(cfg.ReleaseNamespace)), cfg.Release)
+	if err != nil {
+		return err
+	}
+	objs, err := resource.ParseUnstructured([]byte(rel.Manifest))
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse the manifest of release %s", cfg.Release)
+	}
+
+	// NOTE: All objects are read and checked before anything is changed so
+	// that a release that cannot be imported is left as it is.
+	live := make([]resource.ChildResource, 0, len(objs))
+	for _, o := range objs {
+		namespaced, err := isNamespaced(mapper, o.GroupVersionKind())
+		if err != nil {
+			return err
+		}
+		if namespaced && o.GetNamespace() == "" {
+			o.SetNamespace(cfg.ReleaseNamespace)
+		}
+		l := &unstructured.Unstructured{}
+		l.SetGroupVersionKind(o.GroupVersionKind())
+		err = kube.Get(ctx, types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}, l)
+		if kerrors.IsNotFound(err) {
+			fmt.Fprintf(w, "skipped %s %s %s: not found\n", o.GetAPIVersion(), o.GetKind(), describe(o))
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot get %s %s", o.GetKind(), describe(o))
+		}
+		if ref := metav1.GetControllerOf(l); ref != nil {
+			return errors.Errorf("%s %s is already controlled by %s %s", o.GetKind(), describe(o), ref.Kind, ref.Name)
+		}
+		live = append(live, l)
+	}
+
+	gvk := schema.FromAPIVersionAndKind(sd.Spec.Behavior.CRD.APIVersion, sd.Spec.Behavior.CRD.Kind)
+	cr := &unstructured.Unstructured{}
+	cr.SetGroupVersionKind(gvk)
+	cr.SetName(cfg.Name)
+	if cr.GetName() == "" {
+		cr.SetName(rel.Name)
+	}
+	namespaced, err := isNamespaced(mapper, gvk)
+	if err != nil {
+		return err
+	}
+	if namespaced {
+		cr.SetNamespace(cfg.Namespace)
+		if cr.GetNamespace() == "" {
+			cr.SetNamespace(cfg.ReleaseNamespace)
+		}
+	}
+	spec := map[string]interface{}{}
+	if rel.Config != nil {
+		spec = runtime.DeepCopyJSON(rel.Config)
+	}
+	if err := unstructured.SetNestedMap(cr.Object, spec, "spec"); err != nil {
+		return errors.Wrap(err, "cannot set the values of the release as the spec of the parent resource")
+	}
+	if err := kube.Create(ctx, cr); err != nil {
+		return errors.Wrapf(err, "cannot create %s %s", gvk.Kind, describe(cr))
+	}
+	fmt.Fprintf(w, "created %s %s %s\n", cr.GetAPIVersion(), cr.GetKind(), describe(cr))
+
+	ref := meta.AsController(meta.ReferenceTo(cr, gvk))
+	trueVal := true
+	ref.BlockOwnerDeletion = &trueVal
+	for _, o := range live {
+		meta.AddOwnerReference(o, ref)
+		meta.AddLabels(o, map[string]string{templating.StackNameLabelKey: sd.GetName()})
+		meta.RemoveAnnotations(o, helmReleaseNameAnnotationKey, helmReleaseNamespaceAnnotationKey)
+		if o.GetLabels()[helmManagedByLabelKey] == helmManagedByLabelValue {
+			meta.RemoveLabels(o, helmManagedByLabelKey)
+		}
+		if err := kube.Update(ctx, o); err != nil {
+			return errors.Wrapf(err, "cannot adopt %s %s", o.GetObjectKind().GroupVersionKind().Kind, describe(o))
+		}
+		fmt.Fprintf(w, "adopted %s %s %s\n", o.GetObjectKind().GroupVersionKind().GroupVersion(), o.GetObjectKind().GroupVersionKind().Kind, describe(o))
+	}
+
+	// NOTE: The controller may have reconciled the parent resource in the
+	// meantime, so the status is written on its latest version. The
+	// observedGeneration is left at zero since the parent resource has not
+	// been rendered yet.
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := kube.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}, cr); err != nil {
+			return err
+		}
+		if err := templating.SetObservation(cr, templating.Observation{ResourceRefs: templating.NewInventory(live)}); err != nil {
+			return err
+		}
+		return kube.Status().Update(ctx, cr)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "cannot write the inventory of %s %s", gvk.Kind, describe(cr))
+	}
+
+	if !cfg.ForgetRelease {
+		return nil
+	}
+	sel := labels.Set{"owner": "helm", "name": rel.Name}.AsSelector().String()
+	if err := cs.CoreV1().Secrets(cfg.ReleaseNamespace).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: sel}); err != nil {
+		return errors.Wrapf(err, "cannot delete the history of release %s", rel.Name)
+	}
+	fmt.Fprintf(w, "forgot release %s\n", rel.Name)
+	return nil
+}
+
+// deployedRelease returns the deployed revision of the Helm release with the
+// given name.
+func deployedRelease(d driver.Driver, name string) (*release.Release, error) {
+	list, err := d.Query(map[string]string{"name": name, "owner": "helm", "status": release.StatusDeployed.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find the deployed revision of release %s", name)
+	}
+	var latest *release.Release
+	for _, rel := range list {
+		if latest == nil || rel.Version > latest.Version {
+			latest = rel
+		}
+	}
+	if latest == nil {
+		return nil, errors.Errorf("release %s has no deployed revision", name)
+	}
+	return latest, nil
+}
+
+// isNamespaced returns true if the objects of the given kind are namespaced.
+func isNamespaced(mapper kmeta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	m, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot get the REST mapping of %s", gvk)
+	}
+	return m.Scope.Name() == kmeta.RESTScopeNameNamespace, nil
+}
+
+// describe returns the namespace/name of the given object, or only its name
+// if it's cluster-scoped.
+func describe(o metav1.Object) string {
+	if o.GetNamespace() == "" {
+		return o.GetName()
+	}
+	return o.GetNamespace() + "/" + o.GetName()
+}
@@ -18,57 +18,259 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	kustomizeapi "sigs.k8s.io/kustomize/api/types"
 
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane/apis/packages"
 	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
 
+	templatingv1alpha1 "github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/lookup"
+	"github.com/crossplane/templating-controller/pkg/openapi"
+	"github.com/crossplane/templating-controller/pkg/operations/cue"
+	"github.com/crossplane/templating-controller/pkg/operations/external"
+	"github.com/crossplane/templating-controller/pkg/operations/gotemplate"
 	"github.com/crossplane/templating-controller/pkg/operations/helm3"
 	"github.com/crossplane/templating-controller/pkg/operations/kustomize"
+	"github.com/crossplane/templating-controller/pkg/operations/sandbox"
+	"github.com/crossplane/templating-controller/pkg/operations/wasm"
+	"github.com/crossplane/templating-controller/pkg/rbac"
+	"github.com/crossplane/templating-controller/pkg/resource"
 	"github.com/crossplane/templating-controller/pkg/templating"
 )
 
 // Engine name constants.
 const (
-	KustomizeEngine = "kustomize"
-	Helm3Engine     = "helm3"
+	KustomizeEngine  = templatingv1alpha1.KustomizeEngineType
+	Helm3Engine      = "helm3"
+	GoTemplateEngine = "gotemplate"
+	CUEEngine        = "cue"
+	ExternalEngine   = "external"
+	WASMEngine       = "wasm"
 )
 
-var (
-	scheme = runtime.NewScheme()
-)
+// chartFetchTimeout is the timeout of fetching the charts of the
+// StackDefinition from their repositories.
+const chartFetchTimeout = 5 * time.Minute
+
+// healthSummaryInterval is how often the health summary of the custom
+// resources is written to the health ConfigMap.
+const healthSummaryInterval = 30 * time.Second
+
+// engineRecoveryInterval is how often the StackDefinition is read again to
+// build its engine while its engine type is not supported.
+const engineRecoveryInterval = 30 * time.Second
+
+var scheme = runtime.NewScheme()
 
 func main() {
 	var (
 		// top level app definition
 		app = kingpin.New(filepath.Base(os.Args[0]), "Templating controller for Crossplane Template Stacks.").DefaultEnvars()
 
-		stackDefinitionNameInput      = app.Flag("stack-definition-name", "Name of the StackDefinition custom resource.").Required().String()
+		stackDefinitionNameInput      = app.Flag("stack-definition-name", "Name of the StackDefinition custom resource.").String()
 		stackDefinitionNamespaceInput = app.Flag("stack-definition-namespace", "Namespace of the StackDefinition custom resource").String()
 		resourceDirInput              = app.Flag("resources-dir", "Directory of the resources to be fetched as input to the templating engine").Required().ExistingDir()
 		debugInput                    = app.Flag("debug", "Enable debug logging").Bool()
+		resyncIntervalInput           = app.Flag("resync-interval", "Interval of the periodic re-render and apply of every custom resource that corrects the drift of child resources. Changes of custom resources are reconciled immediately.").Default("1m").Duration()
+		jitterInput                   = app.Flag("jitter", "Fraction of the resync interval and the wait after errors by which the requeue of every custom resource is randomly spread.").Default("0.1").Float64()
+		valuesConfigMapInput          = app.Flag("values-configmap", "Namespace and name of the ConfigMap, in namespace/name format, whose values.yaml key is merged beneath the spec of every custom resource before rendering.").String()
+		valuesSecretInput             = app.Flag("values-secret", "Namespace and name of the Secret, in namespace/name format, whose values.yaml key is merged beneath the spec of every custom resource before rendering.").String()
+		parentBackoffInput            = app.Flag("parent-backoff", "Wait before the next reconciliation of a custom resource whose reconciliation failed. It doubles with every consecutive failure of the same custom resource, and the reconciliations of that custom resource that are triggered in the meantime are skipped so that it cannot starve the others. Zero disables the backoff.").Default("30s").Duration()
+		maxParentBackoffInput         = app.Flag("max-parent-backoff", "Maximum wait before the next reconciliation of a custom resource whose reconciliation failed.").Default("10m").Duration()
+		healthProbeAddressInput       = app.Flag("health-probe-bind-address", "Address that the readiness probe endpoint, /readyz, binds to. The controller is not ready while the engine type of the StackDefinition is not supported.").Default(":8081").String()
+		healthConfigMapInput          = app.Flag("health-configmap", "Namespace and name of the ConfigMap, in namespace/name format, whose summary.yaml key the number of custom resources and the ones that are failing are periodically written to.").String()
+		failingThresholdInput         = app.Flag("failing-threshold", "Number of consecutive failed reconciliations after which a custom resource is reported as failing in the templating_controller_failing_instances metric and the health ConfigMap.").Default("3").Int()
+		dryRunInput                   = app.Flag("dry-run", "Render and diff the child resources of every custom resource and report the changes in its status and events without creating, patching or deleting anything.").Bool()
+		chartCacheDirInput            = app.Flag("chart-cache-dir", "Directory that the Helm charts fetched from repositories are cached in. Mount a volume to keep them across restarts.").Default(filepath.Join(os.TempDir(), "templating-controller", "charts")).String()
+		environmentInput              = app.Flag("environment", "Name of the environment that the controller runs in, e.g. prod. The values of the environment in the helm3 environment values annotation of the StackDefinition are merged over the values of every custom resource.").String()
+		allowRemoteBasesInput         = app.Flag("allow-remote-bases", "Allow the resources of the kustomization of the StackDefinition to refer to directories of git repositories, e.g. https://github.com/org/repo//config/base?ref=v1.0.0. They are fetched with the git binary.").Bool()
+		remoteBaseCacheDirInput       = app.Flag("remote-base-cache-dir", "Directory that the git repositories of the remote bases of kustomize are cached in. Mount a volume to keep them across restarts.").Default(filepath.Join(os.TempDir(), "templating-controller", "bases")).String()
+		registryConfigInput           = app.Flag("registry-config", "Docker config file that the charts with DockerConfig credentials are fetched with, e.g. a mounted Secret that is rotated. Defaults to $DOCKER_CONFIG/config.json.").String()
+
+		controllerCmd = app.Command("controller", "Start the templating controller.").Default()
+
+		rbacCmd                 = app.Command("rbac", "Render the templates with sample custom resources and print the minimal RBAC manifest that the controller needs.")
+		rbacStackDefinitionFile = rbacCmd.Flag("stack-definition-file", "Path of the file that contains the StackDefinition.").Required().ExistingFile()
+		rbacSampleFiles         = rbacCmd.Flag("sample", "Path of a file that contains sample custom resources to render. Can be given multiple times.").Required().ExistingFiles()
+		rbacName                = rbacCmd.Flag("name", "Name of the generated Role or ClusterRole. Defaults to the name of the StackDefinition.").String()
+
+		crdCmd                 = app.Command("crd", "Print the CustomResourceDefinition of the parent resource with an OpenAPI v3 schema derived from the chart values schema or the overlay bindings.")
+		crdStackDefinitionFile = crdCmd.Flag("stack-definition-file", "Path of the file that contains the StackDefinition.").Required().ExistingFile()
+		crdScope               = crdCmd.Flag("scope", "Scope of the generated CustomResourceDefinition.").Default("Namespaced").Enum("Namespaced", "Cluster")
+
+		digestCmd = app.Command("digest", "Print the digest of the resources directory to pin in the StackDefinition.")
+
+		testCmd    = app.Command("test", "Render the fixture cases in the tests directory and compare the child resources with the expected ones.")
+		testDir    = testCmd.Flag("tests-dir", "Directory whose subdirectories are the fixture cases.").Required().ExistingDir()
+		testUpdate = testCmd.Flag("update", "Replace the expected child resources of the mismatched cases with the rendered ones.").Bool()
+
+		unpackCmd                 = app.Command("unpack", "Print the manifests that are needed to install the controller for a StackDefinition without the stack manager.")
+		unpackStackDefinitionFile = unpackCmd.Flag("stack-definition-file", "Path of the file that contains the StackDefinition.").Required().ExistingFile()
+		unpackSampleFiles         = unpackCmd.Flag("sample", "Path of a file that contains sample custom resources to render for RBAC generation. Can be given multiple times.").ExistingFiles()
+		unpackNamespace           = unpackCmd.Flag("namespace", "Namespace to install the controller into. Defaults to the namespace of the StackDefinition.").String()
+		unpackImage               = unpackCmd.Flag("image", "Image of the templating controller. Defaults to the controller image in the StackDefinition.").String()
+		unpackCRDScope            = unpackCmd.Flag("crd-scope", "Scope of the generated CustomResourceDefinition of the parent resource.").Default("Namespaced").Enum("Namespaced", "Cluster")
+
+		importCmd                 = app.Command("import", "Adopt the live objects of an existing Helm release under a new custom resource whose spec is the values of the release.")
+		importStackDefinitionFile = importCmd.Flag("stack-definition-file", "Path of the file that contains the StackDefinition.").Required().ExistingFile()
+		importRelease             = importCmd.Flag("release", "Name of the Helm release.").Required().String()
+		importReleaseNamespace    = importCmd.Flag("release-namespace", "Namespace of the Helm release.").Default("default").String()
+		importName                = importCmd.Flag("name", "Name of the created custom resource. Defaults to the name of the release.").String()
+		importNamespace           = importCmd.Flag("namespace", "Namespace of the created custom resource. Defaults to the namespace of the release.").String()
+		importForgetRelease       = importCmd.Flag("forget-release", "Delete the history of the release after the import so that Helm no longer tracks the adopted objects.").Bool()
+
+		sandboxRenderCmd                 = app.Command("sandbox-render", "Render the custom resource read from the standard input within the limits of the sandbox annotation of the StackDefinition. Used by the controller to run the engine in a subprocess.").Hidden()
+		sandboxRenderStackDefinitionFile = sandboxRenderCmd.Flag("stack-definition-file", "Path of the file that contains the StackDefinition.").Required().ExistingFile()
 	)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+	ec := engineConfig{
+		ResourceDir:        *resourceDirInput,
+		ChartCacheDir:      *chartCacheDirInput,
+		RemoteBaseCacheDir: *remoteBaseCacheDirInput,
+		AllowRemoteBases:   *allowRemoteBasesInput,
+		RegistryConfig:     *registryConfigInput,
+		Environment:        *environmentInput,
+	}
+	switch cmd {
+	case controllerCmd.FullCommand():
+		if *stackDefinitionNameInput == "" {
+			kingpin.FatalUsage("required flag --stack-definition-name not provided")
+		}
+		if !(*jitterInput >= 0 && *jitterInput < 1) {
+			kingpin.FatalUsage("--jitter must be at least 0 and less than 1")
+		}
+		runController(controllerConfig{
+			StackDefinitionName:      *stackDefinitionNameInput,
+			StackDefinitionNamespace: *stackDefinitionNamespaceInput,
+			engineConfig:             ec,
+			ValuesConfigMap:          *valuesConfigMapInput,
+			ValuesSecret:             *valuesSecretInput,
+			ResyncInterval:           *resyncIntervalInput,
+			Jitter:                   *jitterInput,
+			ParentBackoff:            *parentBackoffInput,
+			MaxParentBackoff:         *maxParentBackoffInput,
+			Debug:                    *debugInput,
+			DryRun:                   *dryRunInput,
+			HealthProbeAddress:       *healthProbeAddressInput,
+			HealthConfigMap:          *healthConfigMapInput,
+			FailingThreshold:         *failingThresholdInput,
+		})
+	case rbacCmd.FullCommand():
+		kingpin.FatalIfError(runRBAC(os.Stdout, *rbacStackDefinitionFile, *rbacSampleFiles, ec, *rbacName), "could not generate RBAC manifest")
+	case crdCmd.FullCommand():
+		kingpin.FatalIfError(runCRD(os.Stdout, *crdStackDefinitionFile, *resourceDirInput, *crdScope), "could not generate CustomResourceDefinition")
+	case digestCmd.FullCommand():
+		digest, err := resource.HashDirectory(*resourceDirInput)
+		kingpin.FatalIfError(err, "could not calculate the digest of the resources directory")
+		fmt.Println(templating.DigestPrefix + digest)
+	case testCmd.FullCommand():
+		kingpin.FatalIfError(runTest(os.Stdout, *testDir, ec, *testUpdate), "fixture cases failed")
+	case unpackCmd.FullCommand():
+		kingpin.FatalIfError(runUnpack(os.Stdout, unpackConfig{
+			StackDefinitionFile: *unpackStackDefinitionFile,
+			SampleFiles:         *unpackSampleFiles,
+			engineConfig:        ec,
+			Namespace:           *unpackNamespace,
+			Image:               *unpackImage,
+			CRDScope:            *unpackCRDScope,
+		}), "could not generate install manifests")
+	case importCmd.FullCommand():
+		kingpin.FatalIfError(runImport(os.Stdout, importConfig{
+			StackDefinitionFile: *importStackDefinitionFile,
+			Release:             *importRelease,
+			ReleaseNamespace:    *importReleaseNamespace,
+			Name:                *importName,
+			Namespace:           *importNamespace,
+			ForgetRelease:       *importForgetRelease,
+		}), "could not import the Helm release")
+	case sandboxRenderCmd.FullCommand():
+		kingpin.FatalIfError(runSandboxRender(os.Stdin, os.Stdout, *sandboxRenderStackDefinitionFile, ec), "could not render in the sandbox")
+	}
+}
+
+// engineConfig is the configuration of the templating engines that are built
+// from the StackDefinition by the subcommands that render, including the
+// subprocess of the sandbox, which gets the same values as flags.
+type engineConfig struct {
+	// ResourceDir is the directory of the resources that are the input of
+	// the templating engine.
+	ResourceDir string
+
+	// ChartCacheDir is the directory that the charts fetched from Helm
+	// repositories are cached in.
+	ChartCacheDir string
+
+	// RemoteBaseCacheDir is the directory that the git repositories of the
+	// remote bases of kustomize are cached in.
+	RemoteBaseCacheDir string
+
+	// AllowRemoteBases lets the kustomization of the StackDefinition refer
+	// to directories of git repositories.
+	AllowRemoteBases bool
+
+	// RegistryConfig is the docker config file that the credentials of the
+	// charts with DockerConfig credentials are read from. The default of the
+	// Fetcher is used if it's empty.
+	RegistryConfig string
+
+	// Environment is the name of the environment that the controller runs
+	// in, whose values in the helm3 environment values annotation are
+	// merged over the values of the custom resources.
+	Environment string
+}
+
+// controllerConfig is the input of the controller subcommand.
+type controllerConfig struct {
+	engineConfig
+
+	StackDefinitionName      string
+	StackDefinitionNamespace string
+	ValuesConfigMap          string
+	ValuesSecret             string
+	ResyncInterval           time.Duration
+	Jitter                   float64
+	ParentBackoff            time.Duration
+	MaxParentBackoff         time.Duration
+	Debug                    bool
+	DryRun                   bool
+	HealthProbeAddress       string
+	HealthConfigMap          string
+	FailingThreshold         int
+}
+
+func runController(cfg controllerConfig) { // nolint:gocyclo
 	sd := &v1alpha1.StackDefinition{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      *stackDefinitionNameInput,
-			Namespace: *stackDefinitionNamespaceInput,
+			Name:      cfg.StackDefinitionName,
+			Namespace: cfg.StackDefinitionNamespace,
 		},
 	}
 	kingpin.FatalIfError(getStackDefinition(sd), "could not fetch the StackDefinition object")
@@ -78,8 +280,9 @@ func main() {
 	kingpin.FatalIfError(packages.AddToScheme(scheme), "could not register stacks group scheme")
 
 	mgrOptions := ctrl.Options{
-		Scheme: scheme,
-		Port:   9443,
+		Scheme:                 scheme,
+		Port:                   9443,
+		HealthProbeBindAddress: cfg.HealthProbeAddress,
 	}
 	// TODO(muvaf): This should be a flag but deployment generation happens in
 	// unpack step which doesn't have information about namespace. So, we have to
@@ -93,8 +296,8 @@ func main() {
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOptions)
 	kingpin.FatalIfError(err, "unable to start manager")
 
-	zl := zap.New(zap.UseDevMode(*debugInput))
-	if *debugInput {
+	zl := zap.New(zap.UseDevMode(cfg.Debug))
+	if cfg.Debug {
 		// The controller-runtime runs with a no-op logger by default. It is
 		// *very* verbose even at info level, so we only provide it a real
 		// logger when we're running in debug mode.
@@ -102,43 +305,724 @@ func main() {
 	}
 	crLogger := logging.NewLogrLogger(zl.WithName(gvk.GroupKind().String()))
 
+	revision, err := resource.HashDirectory(cfg.ResourceDir)
+	kingpin.FatalIfError(err, "could not calculate the digest of the resources directory")
+
+	eng, err := buildEngine(sd, cfg.engineConfig, crLogger, mgr)
+	ready := healthz.Ping
+	if templating.IsUnsupportedEngine(err) {
+		// NOTE: Crash-looping would hide the problem from the users of the
+		// custom resources, so it's reported on them until the
+		// StackDefinition is corrected.
+		crLogger.Info("Custom resources are not rendered until the StackDefinition is corrected", "error", err)
+		fallback := templating.NewFallbackEngine(err)
+		kingpin.FatalIfError(mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			wait.Until(func() { recoverEngine(mgr, sd, cfg.engineConfig, crLogger, fallback) }, engineRecoveryInterval, stop)
+			return nil
+		})), "could not add the engine recovery")
+		eng, err, ready = fallback, nil, fallback.Ready
+	}
+	if err != nil {
+		kingpin.FatalUsage("%s", err)
+	}
+	kingpin.FatalIfError(mgr.AddReadyzCheck("engine", ready), "could not add the readiness check")
+	if expected, ok := sd.GetAnnotations()[templating.ResourcesDigestAnnotationKey]; ok {
+		if err := templating.VerifyDigest(expected, revision); err != nil {
+			crLogger.Info("Refusing to render the custom resources", "error", err)
+			eng = templating.NewDigestMismatchEngine(err)
+		}
+	}
+	// NOTE: The values snapshots are ConfigMaps, which are not written in
+	// dry-run mode.
+	if sd.GetAnnotations()[templating.ValuesSnapshotAnnotationKey] == "true" && !cfg.DryRun {
+		eng = templating.NewValuesSnapshotEngine(eng, mgr.GetClient(), sd.GetNamespace(), crLogger)
+	}
+	var sources []templating.ValuesSource
+	if cfg.ValuesConfigMap != "" {
+		sources = append(sources, templating.NewConfigMapValues(mgr.GetAPIReader(), namespacedName(cfg.ValuesConfigMap)))
+	}
+	if cfg.ValuesSecret != "" {
+		sources = append(sources, templating.NewSecretValues(mgr.GetAPIReader(), namespacedName(cfg.ValuesSecret)))
+	}
+	if len(sources) != 0 {
+		eng = templating.NewValuesMergingEngine(eng, sources...)
+	}
+	var watches []templating.Watch
+	if data, ok := sd.GetAnnotations()[templating.ReferencesAnnotationKey]; ok {
+		refs, err := templating.ParseReferences(data)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.ReferencesAnnotationKey, err)
+		}
+		eng = templating.NewReferenceResolvingEngine(eng, mgr.GetAPIReader(), refs...)
+		for _, kind := range templating.WatchedKinds(refs) {
+			watches = append(watches, templating.Watch{Kind: kind, Handler: templating.NewReferenceHandler(mgr.GetClient(), gvk, kind, refs)})
+		}
+	}
 	options := []templating.ReconcilerOption{
 		templating.WithLogger(crLogger),
+		templating.WithResyncInterval(cfg.ResyncInterval),
+		templating.WithJitter(cfg.Jitter),
 	}
-	switch sd.Spec.Behavior.Engine.Type {
-	case KustomizeEngine:
-		kustOpts := []kustomize.Option{kustomize.WithResourcePath(*resourceDirInput)}
-		kustomization := &kustomizeapi.Kustomization{}
-		if sd.Spec.Behavior.Engine.Kustomize != nil {
-			kustOpts = append(kustOpts, kustomize.WithOverlayGenerator(kustomize.NewPatchOverlayGenerator(sd.Spec.Behavior.Engine.Kustomize.Overlays)))
-			if sd.Spec.Behavior.Engine.Kustomize.Kustomization != nil {
-				kingpin.FatalIfError(runtime.DefaultUnstructuredConverter.FromUnstructured(sd.Spec.Behavior.Engine.Kustomize.Kustomization.UnstructuredContent(), kustomization), "cannot unmarshal into kustomization object")
+	inputs, err := inputFields(sd)
+	kingpin.FatalIfError(err, "could not determine the input fields of the parent resources")
+	if len(inputs) != 0 {
+		options = append(options, templating.WithInputFields(inputs...))
+	}
+	if cfg.ParentBackoff > 0 {
+		if cfg.MaxParentBackoff < cfg.ParentBackoff {
+			kingpin.FatalUsage("--max-parent-backoff cannot be less than --parent-backoff")
+		}
+		options = append(options, templating.WithParentRateLimiter(templating.NewParentRateLimiter(cfg.ParentBackoff, cfg.MaxParentBackoff)))
+	}
+	switch sd.GetAnnotations()[templating.LastKnownGoodAnnotationKey] {
+	case templating.LastKnownGoodMemory:
+		options = append(options, templating.WithRenderStore(templating.NewMemoryRenderStore()))
+	case templating.LastKnownGoodConfigMap:
+		options = append(options, templating.WithRenderStore(templating.NewConfigMapRenderStore(mgr.GetClient(), sd.GetNamespace())))
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.LastKnownGoodAnnotationKey, sd.GetAnnotations()[templating.LastKnownGoodAnnotationKey])
+	}
+	switch mode := sd.GetAnnotations()[templating.UnknownFieldsAnnotationKey]; mode {
+	case templating.UnknownFieldsWarn, templating.UnknownFieldsStrict:
+		s, _, err := parentSchema(sd, cfg.ResourceDir)
+		kingpin.FatalIfError(err, "could not derive the schema of the custom resource")
+		spec := s.Properties["spec"]
+		options = append(options, templating.WithUnknownFieldPruning(func(obj map[string]interface{}) []string {
+			return openapi.Prune(obj, &spec)
+		}, mode == templating.UnknownFieldsStrict))
+	case templating.UnknownFieldsOff, "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.UnknownFieldsAnnotationKey, mode)
+	}
+	if val, ok := sd.GetAnnotations()[templating.SuspendedKindsAnnotationKey]; ok {
+		options = append(options, templating.WithSuspendedKinds(templating.ParseGroupKinds(val)...))
+	}
+	var applyOpts []apply.Option
+	switch s := apply.Strategy(sd.GetAnnotations()[templating.ApplyStrategyAnnotationKey]); s {
+	case apply.MergePatch, apply.ServerSideApply:
+		applyOpts = append(applyOpts, apply.WithStrategy(s))
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.ApplyStrategyAnnotationKey, s)
+	}
+	if val, ok := sd.GetAnnotations()[templating.InformerIdleReconcilesAnnotationKey]; ok {
+		idle, err := templating.ParseIdleReconciles(val)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.InformerIdleReconcilesAnnotationKey, err)
+		}
+		// NOTE: Every kind gets a cache of its own so that its informer can be
+		// stopped independently of the others.
+		ic := templating.NewInformerClient(mgr.GetClient(), func(schema.GroupVersionKind) (cache.Cache, error) {
+			return cache.New(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper(), Namespace: mgrOptions.Namespace})
+		}, idle)
+		options = append(options, templating.WithInformerClient(ic), templating.WithApplier(apply.NewApplier(ic, applyOpts...)))
+	} else if len(applyOpts) != 0 {
+		options = append(options, templating.WithApplier(apply.NewApplier(mgr.GetClient(), applyOpts...)))
+	}
+	if sd.GetAnnotations()[templating.AllowPreviewAnnotationKey] == "true" {
+		options = append(options, templating.WithPreviewer(templating.NewPreviewer(mgr.GetClient(), sd.GetNamespace(), applyOpts...)))
+	}
+	if cfg.DryRun {
+		options = append(options, templating.WithDryRun(templating.NewPreviewer(mgr.GetClient(), sd.GetNamespace(), applyOpts...)))
+	} else {
+		options = append(options, templating.WithManagementPolicies(templating.NewPreviewer(mgr.GetClient(), sd.GetNamespace(), applyOpts...)))
+	}
+	switch mode := sd.GetAnnotations()[templating.ChildResourceOrderAnnotationKey]; mode {
+	case templating.ChildResourceOrderRendered:
+		options = append(options, templating.WithoutChildResourceSorting())
+	case "", templating.ChildResourceOrderSorted:
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.ChildResourceOrderAnnotationKey, mode)
+	}
+	switch mode := sd.GetAnnotations()[templating.PermissionCheckAnnotationKey]; mode {
+	case templating.PermissionCheckEnabled, templating.PermissionCheckWithHints:
+		options = append(options, templating.WithPermissionChecker(rbac.NewAccessReviewer(mgr.GetClient(), mgr.GetRESTMapper()), mode == templating.PermissionCheckWithHints))
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.PermissionCheckAnnotationKey, mode)
+	}
+	switch mode := sd.GetAnnotations()[templating.NamespaceCheckAnnotationKey]; mode {
+	case templating.NamespaceCheckEnabled, templating.NamespaceCheckCreate:
+		options = append(options, templating.WithNamespaceChecker(templating.NewAPINamespaceChecker(mgr.GetAPIReader(), mgr.GetClient(), mode == templating.NamespaceCheckCreate)))
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.NamespaceCheckAnnotationKey, mode)
+	}
+	switch mode := sd.GetAnnotations()[templating.EscalationCheckAnnotationKey]; mode {
+	case "true":
+		options = append(options, templating.WithEscalationChecker(rbac.NewEscalationReviewer(mgr.GetClient(), mgr.GetAPIReader())))
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.EscalationCheckAnnotationKey, mode)
+	}
+	if data, ok := sd.GetAnnotations()[templating.OutputLimitsAnnotationKey]; ok {
+		l, err := templating.ParseOutputLimits(data)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.OutputLimitsAnnotationKey, err)
+		}
+		options = append(options, templating.WithOutputLimits(l))
+	}
+	if data, ok := sd.GetAnnotations()[templating.LintAnnotationKey]; ok {
+		rules, err := templating.ParseLintRules(data)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.LintAnnotationKey, err)
+		}
+		options = append(options, templating.WithLinter(templating.NewRuleLinter(rules...)))
+	}
+	if sd.GetAnnotations()[templating.SchemaValidationAnnotationKey] == "true" {
+		options = append(options, templating.WithSchemaValidator(templating.NewCRDSchemaValidator(mgr.GetClient(), mgr.GetRESTMapper())))
+	}
+	var status templating.StatusWriter = templating.NewAPIStatusWriter(mgr.GetClient())
+	switch mode := sd.GetAnnotations()[templating.ReportAnnotationKey]; mode {
+	case templating.ReportAlongside:
+		status = templating.NewReportWriter(mgr.GetClient(), sd.GetNamespace(), status)
+	case templating.ReportOnly:
+		status = templating.NewReportWriter(mgr.GetClient(), sd.GetNamespace(), nil)
+	case "":
+	default:
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.ReportAnnotationKey, mode)
+	}
+	if data, ok := sd.GetAnnotations()[templating.ScaleAnnotationKey]; ok {
+		scale, err := templating.ParseScale(data)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.ScaleAnnotationKey, err)
+		}
+		options = append(options, templating.WithAdditionalChildResourcePatcher(templating.NewScalePatcher(scale)))
+		status = templating.NewScaleStatusWriter(mgr.GetClient(), scale, status)
+	}
+	switch s := templating.NameConflictStrategy(sd.GetAnnotations()[templating.NameConflictStrategyAnnotationKey]); s {
+	case templating.NameConflictTruncate, templating.NameConflictReject:
+		maxLength := templating.DefaultMaxNameLength
+		if val, ok := sd.GetAnnotations()[templating.MaxNameLengthAnnotationKey]; ok {
+			if maxLength, err = templating.ParseMaxNameLength(val); err != nil {
+				kingpin.FatalUsage("invalid value of %s annotation: %s", templating.MaxNameLengthAnnotationKey, err)
 			}
 		}
-		options = append(options,
-			templating.WithEngine(kustomize.NewKustomizeEngine(kustomization, kustOpts...)))
-	case Helm3Engine:
-		options = append(options,
-			templating.WithEngine(helm3.NewHelm3Engine(
-				helm3.WithResourcePath(*resourceDirInput),
-				helm3.WithLogger(crLogger)),
-			),
-		)
+		options = append(options, templating.WithAdditionalChildResourcePatcher(templating.NewNameConflictPatcher(s, maxLength)))
+	case "":
 	default:
-		kingpin.FatalUsage("the engine type %s is not supported", sd.Spec.Behavior.Engine.Type)
-	}
-	controller := templating.NewReconciler(mgr, gvk, options...)
-	u := &unstructured.Unstructured{}
-	u.SetGroupVersionKind(gvk)
-	kingpin.FatalIfError(
-		ctrl.NewControllerManagedBy(mgr).
-			For(u).
-			Complete(controller),
-		"could not create controller",
-	)
+		kingpin.FatalUsage("unknown value of %s annotation: %s", templating.NameConflictStrategyAnnotationKey, s)
+	}
+	options = append(options, templating.WithStatusWriter(status))
+	if sd.GetAnnotations()[templating.ReportValuesSourcesAnnotationKey] == "true" {
+		options = append(options, templating.WithValuesSourcesReport())
+	}
+	if sd.GetAnnotations()[templating.RenderCacheAnnotationKey] == "true" {
+		options = append(options, templating.WithRenderCache(templating.NewConfigMapRenderCache(mgr.GetClient(), sd.GetNamespace()), revision))
+	}
+	if sd.GetAnnotations()[templating.RenderResultCacheAnnotationKey] == "true" {
+		options = append(options, templating.WithRenderResultCache(templating.NewRenderResultCache(), revision))
+	}
+	if val, ok := sd.GetAnnotations()[templating.RolloutMaxUnavailableAnnotationKey]; ok {
+		maxUnavailable, err := templating.ParseMaxUnavailable(val)
+		if err != nil {
+			kingpin.FatalUsage("invalid value of %s annotation: %s", templating.RolloutMaxUnavailableAnnotationKey, err)
+		}
+		var maxFailureRatio float64
+		if val, ok := sd.GetAnnotations()[templating.RolloutMaxFailureRatioAnnotationKey]; ok {
+			if maxFailureRatio, err = templating.ParseMaxFailureRatio(val); err != nil {
+				kingpin.FatalUsage("invalid value of %s annotation: %s", templating.RolloutMaxFailureRatioAnnotationKey, err)
+			}
+		}
+		options = append(options, templating.WithRollout(templating.NewRollout(revision, maxUnavailable, maxFailureRatio)))
+	}
+	if cfg.FailingThreshold < 1 {
+		kingpin.FatalUsage("--failing-threshold should be at least 1")
+	}
+	health := templating.NewHealthTracker(gvk.GroupKind(), cfg.FailingThreshold)
+	kingpin.FatalIfError(metrics.Registry.Register(health), "could not register the health metrics")
+	options = append(options, templating.WithHealthTracker(health))
+	timings := templating.NewTimingMetrics(gvk.GroupKind())
+	kingpin.FatalIfError(metrics.Registry.Register(timings), "could not register the timing metrics")
+	options = append(options, templating.WithTimingMetrics(timings))
+	if sd.GetAnnotations()[templating.ReportTimingsAnnotationKey] == "true" {
+		options = append(options, templating.WithTimingsReport())
+	}
+	if cfg.HealthConfigMap != "" {
+		key := namespacedName(cfg.HealthConfigMap)
+		kingpin.FatalIfError(mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+			wait.Until(func() {
+				if err := templating.WriteHealthSummary(context.Background(), mgr.GetClient(), key, health.Summary()); err != nil {
+					crLogger.Info("Cannot write the health summary", "error", err)
+				}
+			}, healthSummaryInterval, stop)
+			return nil
+		})), "could not add the health summary writer")
+	}
+	kingpin.FatalIfError(templating.Setup(mgr, templating.SetupOptions{
+		GVK:        gvk,
+		Engine:     eng,
+		Patchers:   []templating.ChildResourcePatcher{templating.NewRevisionLabeler(sd.GetName(), revision)},
+		Predicates: []predicate.Predicate{templating.ParentChanged(inputs...)},
+		Watches:    watches,
+		Options:    options,
+	}), "could not create controller")
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "unable to run the manager")
 }
 
+// recoverEngine reads the given StackDefinition again and makes the given
+// FallbackEngine run its engine once it can be built.
+func recoverEngine(mgr manager.Manager, sd *v1alpha1.StackDefinition, ec engineConfig, log logging.Logger, fallback *templating.FallbackEngine) {
+	if fallback.Recovered() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), chartFetchTimeout)
+	defer cancel()
+	latest := &v1alpha1.StackDefinition{}
+	if err := mgr.GetAPIReader().Get(ctx, types.NamespacedName{Namespace: sd.GetNamespace(), Name: sd.GetName()}, latest); err != nil {
+		log.Info("Cannot get the StackDefinition", "error", err)
+		return
+	}
+	eng, err := buildEngine(latest, ec, log, mgr)
+	if err != nil {
+		log.Debug("Engine of the StackDefinition cannot be built yet", "error", err)
+		return
+	}
+	fallback.Recover(eng)
+	log.Info("Engine of the corrected StackDefinition is built, rendering the custom resources", "type", latest.Spec.Behavior.Engine.Type)
+}
+
+// namespacedName parses the given namespace/name string. The namespace is
+// optional.
+func namespacedName(s string) types.NamespacedName {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return types.NamespacedName{Namespace: s[:i], Name: s[i+1:]}
+	}
+	return types.NamespacedName{Name: s}
+}
+
+// buildEngine returns the engine of the given StackDefinition, which runs in
+// a sandbox if the StackDefinition asks for it and in the controller
+// otherwise.
+func buildEngine(sd *v1alpha1.StackDefinition, ec engineConfig, log logging.Logger, mgr manager.Manager) (templating.Engine, error) {
+	if _, ok := sd.GetAnnotations()[sandbox.AnnotationKey]; ok {
+		return sandboxed(sd, ec)
+	}
+	return newEngine(sd, ec, log, mgr)
+}
+
+// newEngine returns the templating engine that is configured in the behavior
+// of the given StackDefinition with the given engine configuration. The given
+// manager is used to look up the existing objects, to read the values from
+// the objects that the parent resources reference and to discover the
+// capabilities of the cluster if the StackDefinition allows them; nil
+// disables all of them.
+func newEngine(sd *v1alpha1.StackDefinition, ec engineConfig, log logging.Logger, mgr manager.Manager) (templating.Engine, error) {
+	b, err := templatingv1alpha1.BehaviorOf(sd)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := newTemplatingEngine(sd, b, ec, log, mgr)
+	if err != nil {
+		return nil, err
+	}
+	if data, ok := sd.GetAnnotations()[templating.PipelineAnnotationKey]; ok {
+		stages, err := newStages(sd, b, data, ec)
+		if err != nil {
+			return nil, err
+		}
+		eng = templating.NewChainedEngine(eng, stages...)
+	}
+	if name, ok := sd.GetAnnotations()[templating.InstanceNamespaceAnnotationKey]; ok {
+		ns, err := templating.NewInstanceNamespaceEngine(eng, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", templating.InstanceNamespaceAnnotationKey)
+		}
+		return ns, nil
+	}
+	return eng, nil
+}
+
+func newTemplatingEngine(sd *v1alpha1.StackDefinition, b *templatingv1alpha1.Behavior, ec engineConfig, log logging.Logger, mgr manager.Manager) (templating.Engine, error) {
+	lookups := sd.GetAnnotations()[helm3.AllowLookupAnnotationKey] == "true" && mgr != nil
+	switch b.Engine.Type {
+	case KustomizeEngine:
+		return newKustomizeEngine(sd, b.Engine.Kustomize, ec)
+	case Helm3Engine:
+		helmOpts := []helm3.Option{
+			helm3.WithResourcePath(ec.ResourceDir),
+			helm3.WithLogger(log),
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), chartFetchTimeout)
+		defer cancel()
+		var fetcherOpts []helm3.FetcherOption
+		if ec.RegistryConfig != "" {
+			fetcherOpts = append(fetcherOpts, helm3.WithDockerConfig(ec.RegistryConfig))
+		}
+		if mgr != nil {
+			fetcherOpts = append(fetcherOpts, helm3.WithPullSecrets(mgr.GetAPIReader(), sd.GetNamespace()))
+		}
+		fetcher := helm3.NewFetcher(ec.ChartCacheDir, fetcherOpts...)
+		if val, ok := sd.GetAnnotations()[helm3.ChartsAnnotationKey]; ok {
+			charts, err := helm3.ParseCharts(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.ChartsAnnotationKey)
+			}
+			if charts, err = fetcher.FetchCharts(ctx, charts); err != nil {
+				return nil, errors.Wrap(err, "cannot fetch the charts")
+			}
+			if charts, err = fetcher.BuildCharts(ctx, ec.ResourceDir, charts); err != nil {
+				return nil, errors.Wrap(err, "cannot resolve the dependencies of the charts")
+			}
+			helmOpts = append(helmOpts, helm3.WithCharts(charts...))
+		} else {
+			path, err := fetcher.Build(ctx, ec.ResourceDir, helm3.Chart{})
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot resolve the dependencies of the chart")
+			}
+			helmOpts = append(helmOpts, helm3.WithResourcePath(path))
+		}
+		if path, ok := sd.GetAnnotations()[helm3.ValuesPathAnnotationKey]; ok {
+			helmOpts = append(helmOpts, helm3.WithValuesPath(path))
+		}
+		if val, ok := sd.GetAnnotations()[helm3.DefaultValuesAnnotationKey]; ok {
+			values, err := helm3.ParseValues(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.DefaultValuesAnnotationKey)
+			}
+			helmOpts = append(helmOpts, helm3.WithDefaultValues(values))
+		}
+		if val, ok := sd.GetAnnotations()[helm3.EnvironmentValuesAnnotationKey]; ok && ec.Environment != "" {
+			values, err := helm3.ParseEnvironmentValues(val, ec.Environment)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.EnvironmentValuesAnnotationKey)
+			}
+			helmOpts = append(helmOpts, helm3.WithEnvironmentValues(ec.Environment, values))
+		}
+		if sd.GetAnnotations()[helm3.AllowValuesOverrideAnnotationKey] == "true" {
+			helmOpts = append(helmOpts, helm3.WithValuesOverride())
+		}
+		if sd.GetAnnotations()[helm3.SkipHooksAnnotationKey] == "true" {
+			helmOpts = append(helmOpts, helm3.WithoutHooks())
+		}
+		if sd.GetAnnotations()[helm3.CoerceValuesAnnotationKey] == "true" {
+			helmOpts = append(helmOpts, helm3.WithValueCoercion())
+		}
+		if val, ok := sd.GetAnnotations()[helm3.ValueTypesAnnotationKey]; ok {
+			types, err := helm3.ParseValueTypes(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.ValueTypesAnnotationKey)
+			}
+			helmOpts = append(helmOpts, helm3.WithValueTypes(types))
+		}
+		if lookups {
+			helmOpts = append(helmOpts, helm3.WithLookup(lookup.Config(mgr.GetConfig(), newLookup(sd, mgr))))
+		}
+		if sd.GetAnnotations()[helm3.AllowValuesFromAnnotationKey] == "true" && mgr != nil {
+			helmOpts = append(helmOpts, helm3.WithValuesFrom(mgr.GetAPIReader()))
+		}
+		if sd.GetAnnotations()[helm3.ParentMetadataAnnotationKey] == "true" {
+			helmOpts = append(helmOpts, helm3.WithParentMetadata())
+		}
+		if sd.GetAnnotations()[helm3.DiscoverCapabilitiesAnnotationKey] == "true" && mgr != nil {
+			dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot create the discovery client")
+			}
+			helmOpts = append(helmOpts, helm3.WithCapabilitiesDiscovery(helm3.NewCapabilitiesDiscoverer(dc, helm3.DefaultCapabilitiesTTL)))
+		}
+		if val, ok := sd.GetAnnotations()[helm3.KubeVersionAnnotationKey]; ok {
+			v, err := helm3.ParseKubeVersion(val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.KubeVersionAnnotationKey)
+			}
+			helmOpts = append(helmOpts, helm3.WithKubeVersion(v))
+		}
+		if val, ok := sd.GetAnnotations()[helm3.APIVersionsAnnotationKey]; ok {
+			helmOpts = append(helmOpts, helm3.WithAPIVersions(helm3.ParseAPIVersions(val)...))
+		}
+		return helm3.NewHelm3Engine(helmOpts...), nil
+	case GoTemplateEngine:
+		gtOpts := []gotemplate.Option{gotemplate.WithResourcePath(ec.ResourceDir)}
+		if lookups {
+			gtOpts = append(gtOpts, gotemplate.WithLookup(newLookup(sd, mgr).Lookup))
+		}
+		return gotemplate.NewGoTemplateEngine(gtOpts...), nil
+	case CUEEngine:
+		return cue.NewCUEEngine(cue.WithResourcePath(ec.ResourceDir)), nil
+	case ExternalEngine:
+		extOpts := []external.Option{external.WithResourcePath(ec.ResourceDir)}
+		if cmd, ok := sd.GetAnnotations()[external.CommandAnnotationKey]; ok {
+			extOpts = append(extOpts, external.WithCommand(cmd))
+		}
+		return external.NewExternalEngine(extOpts...), nil
+	case WASMEngine:
+		wasmOpts := []wasm.Option{wasm.WithResourcePath(ec.ResourceDir)}
+		if module, ok := sd.GetAnnotations()[wasm.ModuleAnnotationKey]; ok {
+			wasmOpts = append(wasmOpts, wasm.WithModule(module))
+		}
+		return wasm.NewWASMEngine(wasmOpts...), nil
+	}
+	return nil, &templating.UnsupportedEngineError{Type: b.Engine.Type}
+}
+
+// newLookup returns a lookup.Lookup that reads the kinds that the permissions
+// of the given StackDefinition allow from the cache of the given manager.
+func newLookup(sd *v1alpha1.StackDefinition, mgr manager.Manager) *lookup.Lookup {
+	return lookup.New(mgr.GetCache(), mgr.GetRESTMapper(), sd.Spec.Permissions.Rules)
+}
+
+// newKustomizeEngine returns the kustomize engine with the given
+// configuration, the annotations of the given StackDefinition and the given
+// resource path.
+func newKustomizeEngine(sd *v1alpha1.StackDefinition, c *templatingv1alpha1.KustomizeEngineConfiguration, ec engineConfig) (*kustomize.Engine, error) {
+	kustOpts := []kustomize.Option{kustomize.WithResourcePath(ec.ResourceDir)}
+	v, err := variants(sd)
+	if err != nil {
+		return nil, err
+	}
+	if v.Field != "" {
+		if err := v.Validate(ec.ResourceDir); err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %s annotation", kustomize.VariantsAnnotationKey)
+		}
+		kustOpts = append(kustOpts, kustomize.WithVariants(v))
+	}
+	m, err := commonMetadata(sd)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Labels) != 0 || len(m.Annotations) != 0 {
+		kustOpts = append(kustOpts, kustomize.AdditionalPatcher(kustomize.NewCommonMetadataPatcher(m)))
+	}
+	if val, ok := sd.GetAnnotations()[kustomize.NamingAnnotationKey]; ok {
+		n, err := kustomize.ParseNaming(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.NamingAnnotationKey)
+		}
+		kustOpts = append(kustOpts, kustomize.WithNaming(kustomize.NewNamePatcher(n)))
+	}
+	if val, ok := sd.GetAnnotations()[kustomize.BuildOptionsAnnotationKey]; ok {
+		b, err := kustomize.ParseBuildOptions(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.BuildOptionsAnnotationKey)
+		}
+		kustOpts = append(kustOpts, kustomize.WithBuildOptions(b))
+	}
+	if ec.AllowRemoteBases {
+		kustOpts = append(kustOpts, kustomize.WithRemoteBases(kustomize.NewRemoteBaseCache(ec.RemoteBaseCacheDir)))
+	}
+	kustomization := &kustomizeapi.Kustomization{}
+	if c != nil {
+		// NOTE: The engine modifies its kustomization, so the stages of a
+		// pipeline each get a copy of it.
+		c = c.DeepCopy()
+		if err := kustomize.ValidateTransforms(c.Overlays); err != nil {
+			return nil, err
+		}
+		generators := []kustomize.OverlayGenerator{kustomize.NewPatchOverlayGenerator(c.Overlays)}
+		patches, err := json6902Patches(sd)
+		if err != nil {
+			return nil, err
+		}
+		if len(patches) != 0 {
+			generators = append(generators, kustomize.NewJson6902OverlayGenerator(patches))
+		}
+		images, err := imageBindings(sd)
+		if err != nil {
+			return nil, err
+		}
+		if len(images) != 0 {
+			generators = append(generators, kustomize.NewImageOverlayGenerator(images))
+		}
+		replicas, err := replicaBindings(sd)
+		if err != nil {
+			return nil, err
+		}
+		if len(replicas) != 0 {
+			generators = append(generators, kustomize.NewReplicaOverlayGenerator(replicas))
+		}
+		data, err := dataGenerators(sd)
+		if err != nil {
+			return nil, err
+		}
+		if len(data.ConfigMaps) != 0 || len(data.Secrets) != 0 {
+			generators = append(generators, kustomize.NewDataOverlayGenerator(data))
+		}
+		kustOpts = append(kustOpts, kustomize.WithOverlayGenerator(generators...))
+		if c.Kustomization != nil {
+			kustomization = c.Kustomization
+		}
+		components, err := newComponents(sd, c.Components)
+		if err != nil {
+			return nil, err
+		}
+		kustOpts = append(kustOpts, kustomize.WithComponents(components...))
+	}
+	return kustomize.NewKustomizeEngine(kustomization, kustOpts...), nil
+}
+
+// json6902Patches returns the JSON 6902 patches that are declared in the
+// annotation of the given StackDefinition.
+func json6902Patches(sd *v1alpha1.StackDefinition) ([]kustomize.Json6902Patch, error) {
+	val, ok := sd.GetAnnotations()[kustomize.Json6902PatchesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	patches, err := kustomize.ParseJson6902Patches(val)
+	return patches, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.Json6902PatchesAnnotationKey)
+}
+
+// variants returns the variants that are declared in the annotation of the
+// given StackDefinition.
+func variants(sd *v1alpha1.StackDefinition) (kustomize.Variants, error) {
+	val, ok := sd.GetAnnotations()[kustomize.VariantsAnnotationKey]
+	if !ok {
+		return kustomize.Variants{}, nil
+	}
+	v, err := kustomize.ParseVariants(val)
+	return v, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.VariantsAnnotationKey)
+}
+
+// imageBindings returns the image bindings that are declared in the
+// annotation of the given StackDefinition.
+func imageBindings(sd *v1alpha1.StackDefinition) ([]kustomize.ImageBinding, error) {
+	val, ok := sd.GetAnnotations()[kustomize.ImagesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	images, err := kustomize.ParseImageBindings(val)
+	return images, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.ImagesAnnotationKey)
+}
+
+// replicaBindings returns the replica bindings that are declared in the
+// annotation of the given StackDefinition.
+func replicaBindings(sd *v1alpha1.StackDefinition) ([]kustomize.ReplicaBinding, error) {
+	val, ok := sd.GetAnnotations()[kustomize.ReplicasAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	replicas, err := kustomize.ParseReplicaBindings(val)
+	return replicas, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.ReplicasAnnotationKey)
+}
+
+// dataGenerators returns the ConfigMap and Secret generators that are
+// declared in the annotation of the given StackDefinition.
+func dataGenerators(sd *v1alpha1.StackDefinition) (kustomize.DataGenerators, error) {
+	val, ok := sd.GetAnnotations()[kustomize.DataGeneratorsAnnotationKey]
+	if !ok {
+		return kustomize.DataGenerators{}, nil
+	}
+	g, err := kustomize.ParseDataGenerators(val)
+	return g, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.DataGeneratorsAnnotationKey)
+}
+
+// commonMetadata returns the common metadata that is declared in the
+// annotation of the given StackDefinition.
+func commonMetadata(sd *v1alpha1.StackDefinition) (kustomize.CommonMetadata, error) {
+	val, ok := sd.GetAnnotations()[kustomize.CommonMetadataAnnotationKey]
+	if !ok {
+		return kustomize.CommonMetadata{}, nil
+	}
+	m, err := kustomize.ParseCommonMetadata(val)
+	return m, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.CommonMetadataAnnotationKey)
+}
+
+// inputFields returns the fields of the parent resource outside of its spec,
+// e.g. status.endpoint or metadata.uid, that the overlay bindings, the JSON
+// 6902 patches, the common metadata, the image and replica bindings and the
+// data generators of the given StackDefinition read from.
+func inputFields(sd *v1alpha1.StackDefinition) ([]string, error) {
+	var fields []string
+	if k := sd.Spec.Behavior.Engine.Kustomize; k != nil {
+		for _, o := range k.Overlays {
+			for _, b := range o.Bindings {
+				fields = append(fields, b.From)
+			}
+		}
+	}
+	patches, err := json6902Patches(sd)
+	if err != nil {
+		return nil, err
+	}
+	m, err := commonMetadata(sd)
+	if err != nil {
+		return nil, err
+	}
+	images, err := imageBindings(sd)
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := replicaBindings(sd)
+	if err != nil {
+		return nil, err
+	}
+	data, err := dataGenerators(sd)
+	if err != nil {
+		return nil, err
+	}
+	fields = append(fields, kustomize.Json6902ValueFields(patches)...)
+	fields = append(fields, m.Fields()...)
+	fields = append(fields, kustomize.ImageFields(images)...)
+	fields = append(fields, kustomize.ReplicaFields(replicas)...)
+	fields = append(fields, data.Fields()...)
+	seen := map[string]bool{}
+	var inputs []string
+	for _, f := range fields {
+		if strings.HasPrefix(f, "spec.") || seen[f] {
+			continue
+		}
+		seen[f] = true
+		inputs = append(inputs, f)
+	}
+	return inputs, nil
+}
+
+// newComponents returns the given components of the kustomization with the
+// fields that toggle them, which are declared in the components annotation of
+// the given StackDefinition.
+func newComponents(sd *v1alpha1.StackDefinition, paths []string) ([]kustomize.Component, error) {
+	toggles := map[string]string{}
+	if val, ok := sd.GetAnnotations()[kustomize.ComponentsAnnotationKey]; ok {
+		t, err := kustomize.ParseComponentToggles(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", kustomize.ComponentsAnnotationKey)
+		}
+		toggles = t
+	}
+	declared := map[string]bool{}
+	components := make([]kustomize.Component, len(paths))
+	for i, p := range paths {
+		components[i] = kustomize.Component{Path: p, Field: toggles[p]}
+		declared[p] = true
+	}
+	for p := range toggles {
+		if !declared[p] {
+			return nil, errors.Errorf("the component %s in %s annotation is not in the components of the kustomization", p, kustomize.ComponentsAnnotationKey)
+		}
+	}
+	return components, nil
+}
+
+// newStages returns the stages of the engine pipeline that is declared in the
+// given value of the engine-pipeline annotation.
+func newStages(sd *v1alpha1.StackDefinition, b *templatingv1alpha1.Behavior, data string, ec engineConfig) ([]templating.Stage, error) {
+	declared, err := templating.ParsePipeline(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", templating.PipelineAnnotationKey)
+	}
+	stages := make([]templating.Stage, len(declared))
+	for i, st := range declared {
+		switch st.Type {
+		case KustomizeEngine:
+			stage := ec
+			stage.ResourceDir = filepath.Join(ec.ResourceDir, st.Path)
+			k, err := newKustomizeEngine(sd, b.Engine.Kustomize, stage)
+			if err != nil {
+				return nil, err
+			}
+			// NOTE: The child resources are already named by the previous
+			// stage, so they're not prefixed with the name of the parent
+			// resource again.
+			k.Naming = nil
+			stages[i] = k
+		default:
+			return nil, errors.Errorf("the engine type %s cannot be a pipeline stage", st.Type)
+		}
+	}
+	return stages, nil
+}
+
 // TODO: Controller-runtime client doesn't work until manager is started, which
 // is a blocking operation. So, we can't call any controller-runtime client functions
 // here in main.go
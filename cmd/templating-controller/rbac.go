@@ -0,0 +1,206 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/operations/helm3"
+	"github.com/crossplane/templating-controller/pkg/rbac"
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// runRBAC renders the templates with every sample custom resource and writes
+// the minimal Role or ClusterRole that the controller needs to manage the
+// produced kinds.
+func runRBAC(w io.Writer, sdFile string, sampleFiles []string, ec engineConfig, name string) error {
+	sd, err := readStackDefinition(sdFile)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = sd.GetName()
+	}
+	role, err := newRole(sd, sampleFiles, ec, name)
+	if err != nil {
+		return err
+	}
+	return writeManifests(w, role)
+}
+
+// newRole returns a ClusterRole, or a Role if the given StackDefinition is
+// namespace-scoped, with the minimal set of rules that the controller needs
+// to manage the kinds produced by rendering the given samples.
+func newRole(sd *v1alpha1.StackDefinition, sampleFiles []string, ec engineConfig, name string) (interface{}, error) {
+	children, err := renderSamples(sd, sampleFiles, ec)
+	if err != nil {
+		return nil, err
+	}
+	parent := schema.FromAPIVersionAndKind(sd.Spec.Behavior.CRD.APIVersion, sd.Spec.Behavior.CRD.Kind)
+	gvks := rbac.GroupVersionKinds(children)
+	if sd.GetAnnotations()[templating.LastKnownGoodAnnotationKey] == templating.LastKnownGoodConfigMap ||
+		sd.GetAnnotations()[templating.RenderCacheAnnotationKey] == "true" ||
+		sd.GetAnnotations()[templating.ValuesSnapshotAnnotationKey] == "true" {
+		// The last known good child resources, the render records and the
+		// values snapshots are stored in ConfigMaps.
+		gvks = append(gvks, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+	}
+	if sd.GetAnnotations()[helm3.AllowValuesFromAnnotationKey] == "true" {
+		// The values that the parent resources reference are read from
+		// ConfigMaps and Secrets.
+		gvks = append(gvks, corev1.SchemeGroupVersion.WithKind("ConfigMap"), corev1.SchemeGroupVersion.WithKind("Secret"))
+	}
+	if sd.GetAnnotations()[templating.NamespaceCheckAnnotationKey] != "" {
+		// The namespaces of the child resources are looked up, and created
+		// if configured.
+		gvks = append(gvks, corev1.SchemeGroupVersion.WithKind("Namespace"))
+	}
+	if pullsCharts(sd) {
+		// The pull secrets of the charts in OCI registries are read when
+		// the controller starts.
+		gvks = append(gvks, corev1.SchemeGroupVersion.WithKind("Secret"))
+	}
+	if sd.GetAnnotations()[templating.ReportAnnotationKey] != "" {
+		gvks = append(gvks, templating.ReportGroupVersionKind)
+	}
+	rules := rbac.PolicyRules(parent, gvks)
+	if sd.GetAnnotations()[templating.EscalationCheckAnnotationKey] == "true" {
+		// The roles that the bindings among the child resources refer to
+		// are read to check the permissions they grant.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{rbacv1.GroupName},
+			Resources: []string{"roles", "clusterroles"},
+			Verbs:     []string{"get"},
+		})
+	}
+	if sd.GetAnnotations()[templating.SchemaValidationAnnotationKey] == "true" {
+		// The CustomResourceDefinitions of the child resources are read,
+		// and cached, to validate the child resources against their
+		// schemas.
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{"apiextensions.k8s.io"},
+			Resources: []string{"customresourcedefinitions"},
+			Verbs:     []string{"get", "list", "watch"},
+		})
+	}
+	if data, ok := sd.GetAnnotations()[templating.ReferencesAnnotationKey]; ok {
+		refs, err := templating.ParseReferences(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", templating.ReferencesAnnotationKey)
+		}
+		// The resources that the parent resources refer to are only read,
+		// and the watched ones are cached.
+		for _, ref := range refs {
+			plural, _ := meta.UnsafeGuessKindToResource(ref.GroupVersionKind())
+			verbs := []string{"get"}
+			if ref.Watch {
+				verbs = append(verbs, "list", "watch")
+			}
+			rules = append(rules, rbacv1.PolicyRule{
+				APIGroups: []string{plural.Group},
+				Resources: []string{plural.Resource},
+				Verbs:     verbs,
+			})
+		}
+	}
+	if sd.Spec.PermissionScope == string(apiextensions.NamespaceScoped) {
+		return rbac.NewRole(name, sd.GetNamespace(), rules), nil
+	}
+	return rbac.NewClusterRole(name, rules), nil
+}
+
+// pullsCharts returns true if any of the charts of the given StackDefinition
+// is pulled with a pull secret.
+func pullsCharts(sd *v1alpha1.StackDefinition) bool {
+	charts, err := helm3.ParseCharts(sd.GetAnnotations()[helm3.ChartsAnnotationKey])
+	if err != nil {
+		return false
+	}
+	for _, c := range charts {
+		if c.PullSecret != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func renderSamples(sd *v1alpha1.StackDefinition, sampleFiles []string, ec engineConfig) ([]resource.ChildResource, error) {
+	if len(sampleFiles) == 0 {
+		return nil, nil
+	}
+	eng, err := newEngine(sd, ec, logging.NewNopLogger(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var children []resource.ChildResource
+	for _, f := range sampleFiles {
+		data, err := ioutil.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read sample file %s", f)
+		}
+		samples, err := resource.ParseUnstructured(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse sample file %s", f)
+		}
+		for _, cr := range samples {
+			res, err := eng.Run(context.Background(), cr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot render sample %s in file %s", cr.GetName(), f)
+			}
+			children = append(children, res.Children...)
+		}
+	}
+	return children, nil
+}
+
+// writeManifests writes given objects as a multi-document YAML.
+func writeManifests(w io.Writer, objs ...interface{}) error {
+	for _, o := range objs {
+		out, err := yaml.Marshal(o)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal manifest")
+		}
+		if _, err := w.Write(append([]byte("---\n"), out...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readStackDefinition(path string) (*v1alpha1.StackDefinition, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read StackDefinition file")
+	}
+	sd := &v1alpha1.StackDefinition{}
+	return sd, errors.Wrap(yaml.Unmarshal(data, sd), "cannot unmarshal StackDefinition")
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	templatingv1alpha1 "github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/operations/helm3"
+	"github.com/crossplane/templating-controller/pkg/operations/sandbox"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// sandboxOutputHeadroom is the size of the output of the sandbox that is
+// allowed on top of the maximum bytes of the output limits.
+const sandboxOutputHeadroom = 1 << 20
+
+// sandboxed returns an engine that runs the engine of the given
+// StackDefinition in a resource-limited subprocess. The subprocess is the
+// controller binary that builds the engine from a copy of the
+// StackDefinition and the given engine configuration, so the engine is not
+// built in the controller itself. The subprocess does not talk to the API
+// server, so the features of the engine that need it are rejected.
+func sandboxed(sd *v1alpha1.StackDefinition, ec engineConfig) (templating.Engine, error) {
+	l, err := sandbox.ParseLimits(sd.GetAnnotations()[sandbox.AnnotationKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", sandbox.AnnotationKey)
+	}
+	b, err := templatingv1alpha1.BehaviorOf(sd)
+	if err != nil {
+		return nil, err
+	}
+	switch b.Engine.Type {
+	case KustomizeEngine, Helm3Engine, GoTemplateEngine, CUEEngine, ExternalEngine, WASMEngine:
+	default:
+		return nil, &templating.UnsupportedEngineError{Type: b.Engine.Type}
+	}
+	if err := sandboxSupported(sd); err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find the executable of the controller")
+	}
+	sdFile, err := writeStackDefinition(sd)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{
+		"sandbox-render",
+		"--resources-dir", ec.ResourceDir,
+		"--stack-definition-file", sdFile,
+		"--chart-cache-dir", ec.ChartCacheDir,
+		"--remote-base-cache-dir", ec.RemoteBaseCacheDir,
+	}
+	if ec.Environment != "" {
+		args = append(args, "--environment", ec.Environment)
+	}
+	if ec.RegistryConfig != "" {
+		args = append(args, "--registry-config", ec.RegistryConfig)
+	}
+	if ec.AllowRemoteBases {
+		args = append(args, "--allow-remote-bases")
+	}
+	opts := []sandbox.Option{sandbox.WithCommand(exe, args...), sandbox.WithLimits(l)}
+	if data, ok := sd.GetAnnotations()[templating.OutputLimitsAnnotationKey]; ok {
+		ol, err := templating.ParseOutputLimits(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse the value of %s annotation", templating.OutputLimitsAnnotationKey)
+		}
+		// NOTE: The output of the subprocess has the warnings and the JSON
+		// envelope on top of the child resources, which are checked against
+		// the exact limit once they're read.
+		if ol.MaxBytes > 0 {
+			opts = append(opts, sandbox.WithMaxOutput(ol.MaxBytes+sandboxOutputHeadroom))
+		}
+	}
+	return sandbox.NewSandboxEngine(opts...), nil
+}
+
+// sandboxSupported returns an error if the given StackDefinition enables a
+// feature of the helm3 engine that reads from the API server.
+func sandboxSupported(sd *v1alpha1.StackDefinition) error {
+	for _, k := range []string{helm3.AllowLookupAnnotationKey, helm3.AllowValuesFromAnnotationKey, helm3.DiscoverCapabilitiesAnnotationKey} {
+		if sd.GetAnnotations()[k] == "true" {
+			return errors.Errorf("%s annotation cannot be used with %s annotation", k, sandbox.AnnotationKey)
+		}
+	}
+	val, ok := sd.GetAnnotations()[helm3.ChartsAnnotationKey]
+	if !ok {
+		return nil
+	}
+	charts, err := helm3.ParseCharts(val)
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse the value of %s annotation", helm3.ChartsAnnotationKey)
+	}
+	for _, c := range charts {
+		if c.PullSecret != "" {
+			return errors.Errorf("pull secret of chart %s cannot be used with %s annotation", c.Path, sandbox.AnnotationKey)
+		}
+	}
+	return nil
+}
+
+// writeStackDefinition writes the given StackDefinition to a temporary file
+// for the subprocess to read and returns the path of the file.
+func writeStackDefinition(sd *v1alpha1.StackDefinition) (string, error) {
+	data, err := yaml.Marshal(sd)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot marshal StackDefinition")
+	}
+	f, err := ioutil.TempFile("", "stackdefinition-*.yaml")
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create StackDefinition file")
+	}
+	defer f.Close() // nolint:errcheck
+	if _, err := f.Write(data); err != nil {
+		return "", errors.Wrap(err, "cannot write StackDefinition file")
+	}
+	return f.Name(), errors.Wrap(f.Close(), "cannot write StackDefinition file")
+}
+
+// runSandboxRender renders the parent resource that is read from the given
+// input with the engine of the given StackDefinition within the limits of its
+// sandbox annotation, and writes the result to the given output.
+func runSandboxRender(in io.Reader, out io.Writer, sdFile string, ec engineConfig) error {
+	sd, err := readStackDefinition(sdFile)
+	if err != nil {
+		return err
+	}
+	l, err := sandbox.ParseLimits(sd.GetAnnotations()[sandbox.AnnotationKey])
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse the value of %s annotation", sandbox.AnnotationKey)
+	}
+	return sandbox.Serve(in, out, l, func() (sandbox.Runner, error) {
+		return newEngine(sd, ec, logging.NewNopLogger(), nil)
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/fixture"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// runTest runs the fixture cases in the given tests directory against the
+// engines built with the given engine configuration and writes the outcome of
+// every case. If update is true, the expected child resources of the failed cases
+// are replaced with the rendered ones.
+func runTest(w io.Writer, testsDir string, ec engineConfig, update bool) error {
+	cases, err := fixture.Load(testsDir)
+	if err != nil {
+		return err
+	}
+	r := fixture.NewRunner(func(sd *v1alpha1.StackDefinition) (templating.Engine, error) {
+		return newEngine(sd, ec, logging.NewNopLogger(), nil)
+	})
+	failed := 0
+	for _, res := range r.RunAll(cases) {
+		switch {
+		case res.Err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %s\n", res.Case.Name, res.Err)
+		case res.Diff != "" && update:
+			if err := fixture.Update(res); err != nil {
+				return errors.Wrapf(err, "cannot update case %s", res.Case.Name)
+			}
+			fmt.Fprintf(w, "UPDATED %s\n", res.Case.Name)
+		case res.Diff != "":
+			failed++
+			fmt.Fprintf(w, "FAIL %s: -want, +got:\n%s\n", res.Case.Name, res.Diff)
+		default:
+			fmt.Fprintf(w, "ok %s\n", res.Case.Name)
+		}
+	}
+	if failed != 0 {
+		return errors.Errorf("%d of %d cases failed", failed, len(cases))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/crossplane/templating-controller/pkg/install"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// unpackConfig is the input of the unpack subcommand.
+type unpackConfig struct {
+	engineConfig
+
+	StackDefinitionFile string
+	SampleFiles         []string
+	Namespace           string
+	Image               string
+	CRDScope            string
+}
+
+// runUnpack writes the manifests that are needed to install the templating
+// controller for a StackDefinition without the stack manager, i.e. the
+// CustomResourceDefinition of the parent resource, ServiceAccount, RBAC and
+// Deployment.
+func runUnpack(w io.Writer, cfg unpackConfig) error {
+	sd, err := readStackDefinition(cfg.StackDefinitionFile)
+	if err != nil {
+		return err
+	}
+	ns := cfg.Namespace
+	if ns == "" {
+		ns = sd.GetNamespace()
+	}
+	if ns == "" {
+		return errors.New("namespace is not given and StackDefinition does not have a namespace")
+	}
+	// The StackDefinition is expected to be installed in the same namespace
+	// with the controller.
+	sd.SetNamespace(ns)
+	image := cfg.Image
+	if image == "" {
+		image = sd.Spec.Behavior.Engine.ControllerImage
+	}
+	if image == "" {
+		return errors.New("controller image is not given and StackDefinition does not specify one")
+	}
+	name := sd.GetName()
+	role, err := newRole(sd, cfg.SampleFiles, cfg.engineConfig, name)
+	if err != nil {
+		return err
+	}
+	var binding interface{} = install.NewClusterRoleBinding(name, ns)
+	if sd.Spec.PermissionScope == string(apiextensions.NamespaceScoped) {
+		binding = install.NewRoleBinding(name, ns)
+	}
+	crd, err := newCRD(sd, cfg.ResourceDir, cfg.CRDScope)
+	if err != nil {
+		return err
+	}
+	manifests := []interface{}{crd}
+	if sd.GetAnnotations()[templating.ReportAnnotationKey] != "" {
+		manifests = append(manifests, install.NewCustomResourceDefinition(templating.ReportGroupVersionKind, apiextensionsv1.NamespaceScoped, nil))
+	}
+	return writeManifests(w, append(manifests,
+		install.NewServiceAccount(name, ns),
+		role,
+		binding,
+		install.NewDeployment(sd, name, ns, image),
+	)...)
+}
@@ -3,16 +3,21 @@ module github.com/crossplane/templating-controller
 go 1.13
 
 require (
+	cuelang.org/go v0.2.2
 	github.com/crossplane/crossplane v0.11.0
 	github.com/crossplane/crossplane-runtime v0.9.0
 	github.com/google/go-cmp v0.4.0
+	github.com/perlin-network/life v0.0.0-20191203030451-05c0e0f7eaea
 	github.com/pkg/errors v0.9.1
-	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
+	github.com/prometheus/client_golang v1.1.0
+	github.com/xeipuuv/gojsonschema v1.1.0
+	golang.org/x/net v0.0.0-20200226121028-0de0cce0169b
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
 	helm.sh/helm/v3 v3.2.0
 	k8s.io/api v0.18.2
 	k8s.io/apiextensions-apiserver v0.18.2
 	k8s.io/apimachinery v0.18.2
+	k8s.io/cli-runtime v0.18.0
 	k8s.io/client-go v0.18.2
 	sigs.k8s.io/controller-runtime v0.6.0
 	sigs.k8s.io/kustomize/api v0.3.0
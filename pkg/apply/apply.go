@@ -0,0 +1,212 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package apply creates or patches objects in a Kubernetes API server and
+// reports what was done.
+package apply
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rresource "github.com/crossplane/crossplane-runtime/pkg/resource"
+)
+
+// DefaultFieldManager is the name of the field manager of the applied
+// objects unless another one is given with WithFieldManager.
+const DefaultFieldManager = "templating-controller"
+
+const (
+	errCreate           = "cannot create object"
+	errGet              = "cannot get object"
+	errPatch            = "cannot patch object"
+	errFmtNotControlled = "existing object is not controlled by UID %q"
+	errNotAdoptable     = "existing object has no controller and adoption is not allowed"
+)
+
+// An Operation is what Apply did to an object.
+type Operation string
+
+// Operations.
+const (
+	OperationCreated   Operation = "Created"
+	OperationPatched   Operation = "Patched"
+	OperationUnchanged Operation = "Unchanged"
+)
+
+// A Strategy is the kind of patch that existing objects are patched with.
+type Strategy string
+
+// Strategies.
+const (
+	// MergePatch sends the whole desired object as a JSON merge patch. The
+	// fields that are removed from the desired object are kept.
+	MergePatch Strategy = "MergePatch"
+
+	// ServerSideApply sends the desired object as a server-side apply patch
+	// and takes the ownership of the conflicting fields. The fields that are
+	// removed from the desired object are removed if no other field manager
+	// owns them.
+	ServerSideApply Strategy = "ServerSideApply"
+)
+
+// An Option configures an Applier.
+type Option func(*Applier)
+
+// WithStrategy returns an Option that changes the kind of patch that existing
+// objects are patched with. The default is MergePatch.
+func WithStrategy(s Strategy) Option {
+	return func(a *Applier) {
+		a.strategy = s
+	}
+}
+
+// WithDryRun returns an Option that makes the API server validate the
+// creations and patches without persisting them.
+func WithDryRun() Option {
+	return func(a *Applier) {
+		a.dryRun = true
+	}
+}
+
+// WithFieldManager returns an Option that changes the name of the field
+// manager of the applied objects.
+func WithFieldManager(name string) Option {
+	return func(a *Applier) {
+		a.fieldManager = name
+	}
+}
+
+// NewApplier returns a new *Applier.
+func NewApplier(c client.Client, opts ...Option) *Applier {
+	a := &Applier{
+		client:       c,
+		strategy:     MergePatch,
+		fieldManager: DefaultFieldManager,
+	}
+	for _, o := range opts {
+		o(a)
+	}
+	return a
+}
+
+// An Applier creates the objects that don't exist and patches the ones that
+// do.
+type Applier struct {
+	client       client.Client
+	strategy     Strategy
+	dryRun       bool
+	fieldManager string
+}
+
+// An ApplyOption is called with the current and the desired object before an
+// existing object is patched. Returning an error prevents the patch. They are
+// not called if the object does not exist.
+type ApplyOption func(ctx context.Context, current, desired runtime.Object) error
+
+// OwnershipGuard returns an ApplyOption that allows patching an existing
+// object only if it's controlled by an object with the given UID. If adopt is
+// true, the objects without a controller are patched as well, which makes
+// them controlled by the given UID if the desired object has the controller
+// reference.
+func OwnershipGuard(uid types.UID, adopt bool) ApplyOption {
+	return func(_ context.Context, current, _ runtime.Object) error {
+		m, ok := current.(metav1.Object)
+		if !ok {
+			return nil
+		}
+		ref := metav1.GetControllerOf(m)
+		switch {
+		case ref == nil && adopt:
+			return nil
+		case ref == nil:
+			return errors.New(errNotAdoptable)
+		case ref.UID != uid:
+			return errors.Errorf(errFmtNotControlled, uid)
+		}
+		return nil
+	}
+}
+
+// Apply creates the given object if it does not exist. Otherwise, it calls
+// the given ApplyOptions and patches the existing object. The given object is
+// updated with the response of the API server.
+func (a *Applier) Apply(ctx context.Context, o rresource.Object, opts ...ApplyOption) (Operation, error) {
+	if o.GetName() == "" && o.GetGenerateName() != "" {
+		return OperationCreated, errors.Wrap(a.client.Create(ctx, o, a.createOptions()...), errCreate)
+	}
+	current := o.DeepCopyObject()
+	err := a.client.Get(ctx, types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()}, current)
+	if kerrors.IsNotFound(err) {
+		return OperationCreated, errors.Wrap(a.client.Create(ctx, o, a.createOptions()...), errCreate)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, errGet)
+	}
+	for _, fn := range opts {
+		if err := fn(ctx, current, o); err != nil {
+			return "", err
+		}
+	}
+	if err := a.client.Patch(ctx, o, a.patchFor(o.DeepCopyObject()), a.patchOptions()...); err != nil {
+		return "", errors.Wrap(err, errPatch)
+	}
+	if m, ok := current.(metav1.Object); ok && m.GetResourceVersion() != "" && m.GetResourceVersion() == o.GetResourceVersion() {
+		return OperationUnchanged, nil
+	}
+	return OperationPatched, nil
+}
+
+func (a *Applier) patchFor(desired runtime.Object) client.Patch {
+	if a.strategy == ServerSideApply {
+		return client.Apply
+	}
+	return &mergePatch{desired}
+}
+
+func (a *Applier) createOptions() []client.CreateOption {
+	opts := []client.CreateOption{client.FieldOwner(a.fieldManager)}
+	if a.dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	return opts
+}
+
+func (a *Applier) patchOptions() []client.PatchOption {
+	opts := []client.PatchOption{client.FieldOwner(a.fieldManager)}
+	if a.strategy == ServerSideApply {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if a.dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	return opts
+}
+
+// mergePatch is a JSON merge patch of the whole desired object. Unlike
+// client.MergeFrom, it does not compute the difference to the current object
+// so that the fields that are changed by others are corrected.
+type mergePatch struct{ desired runtime.Object }
+
+func (p *mergePatch) Type() types.PatchType                 { return types.MergePatchType }
+func (p *mergePatch) Data(_ runtime.Object) ([]byte, error) { return json.Marshal(p.desired) }
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestApply(t *testing.T) {
+	errBoom := errors.New("boom")
+	uid := types.UID("parent")
+	withVersion := func(rv string, refs ...metav1.OwnerReference) test.ObjectFn {
+		return func(obj runtime.Object) error {
+			m := obj.(metav1.Object)
+			m.SetResourceVersion(rv)
+			m.SetOwnerReferences(refs)
+			return nil
+		}
+	}
+	controller := func(uid types.UID) metav1.OwnerReference {
+		trueVal := true
+		return metav1.OwnerReference{UID: uid, Controller: &trueVal}
+	}
+
+	type args struct {
+		a    *Applier
+		opts []ApplyOption
+	}
+	type want struct {
+		op  Operation
+		err error
+	}
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"Created": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockCreate: test.NewMockCreateFn(nil),
+				}),
+			},
+			want: want{op: OperationCreated},
+		},
+		"CreatedWithDryRun": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockCreate: func(_ context.Context, _ runtime.Object, opts ...client.CreateOption) error {
+						o := &client.CreateOptions{}
+						o.ApplyOptions(opts)
+						if diff := cmp.Diff([]string{metav1.DryRunAll}, o.DryRun); diff != "" {
+							t.Errorf("Create(...): -want dry run, +got dry run:\n%s", diff)
+						}
+						if diff := cmp.Diff("manager", o.FieldManager); diff != "" {
+							t.Errorf("Create(...): -want field manager, +got field manager:\n%s", diff)
+						}
+						return nil
+					},
+				}, WithDryRun(), WithFieldManager("manager")),
+			},
+			want: want{op: OperationCreated},
+		},
+		"CreateFailed": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+					MockCreate: test.NewMockCreateFn(errBoom),
+				}),
+			},
+			want: want{op: OperationCreated, err: errors.Wrap(errBoom, errCreate)},
+		},
+		"GetFailed": {
+			args: args{
+				a: NewApplier(&test.MockClient{MockGet: test.NewMockGetFn(errBoom)}),
+			},
+			want: want{err: errors.Wrap(errBoom, errGet)},
+		},
+		"ApplyOptionFailed": {
+			args: args{
+				a: NewApplier(&test.MockClient{MockGet: test.NewMockGetFn(nil)}),
+				opts: []ApplyOption{func(_ context.Context, _, _ runtime.Object) error {
+					return errBoom
+				}},
+			},
+			want: want{err: errBoom},
+		},
+		"ControlledByOther": {
+			args: args{
+				a:    NewApplier(&test.MockClient{MockGet: test.NewMockGetFn(nil, withVersion("1", controller("other")))}),
+				opts: []ApplyOption{OwnershipGuard(uid, true)},
+			},
+			want: want{err: errors.Errorf(errFmtNotControlled, uid)},
+		},
+		"AdoptionNotAllowed": {
+			args: args{
+				a:    NewApplier(&test.MockClient{MockGet: test.NewMockGetFn(nil, withVersion("1"))}),
+				opts: []ApplyOption{OwnershipGuard(uid, false)},
+			},
+			want: want{err: errors.New(errNotAdoptable)},
+		},
+		"Adopted": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet:   test.NewMockGetFn(nil, withVersion("1")),
+					MockPatch: test.NewMockPatchFn(nil, withVersion("2", controller(uid))),
+				}),
+				opts: []ApplyOption{OwnershipGuard(uid, true)},
+			},
+			want: want{op: OperationPatched},
+		},
+		"Unchanged": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet:   test.NewMockGetFn(nil, withVersion("1", controller(uid))),
+					MockPatch: test.NewMockPatchFn(nil, withVersion("1", controller(uid))),
+				}),
+				opts: []ApplyOption{OwnershipGuard(uid, false)},
+			},
+			want: want{op: OperationUnchanged},
+		},
+		"PatchFailed": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet:   test.NewMockGetFn(nil),
+					MockPatch: test.NewMockPatchFn(errBoom),
+				}),
+			},
+			want: want{err: errors.Wrap(errBoom, errPatch)},
+		},
+		"ServerSideApply": {
+			args: args{
+				a: NewApplier(&test.MockClient{
+					MockGet: test.NewMockGetFn(nil, withVersion("1")),
+					MockPatch: func(_ context.Context, obj runtime.Object, p client.Patch, opts ...client.PatchOption) error {
+						if diff := cmp.Diff(types.ApplyPatchType, p.Type()); diff != "" {
+							t.Errorf("Patch(...): -want patch type, +got patch type:\n%s", diff)
+						}
+						o := &client.PatchOptions{}
+						o.ApplyOptions(opts)
+						if o.Force == nil || !*o.Force {
+							t.Errorf("Patch(...): want forced ownership")
+						}
+						return withVersion("2")(obj)
+					},
+				}, WithStrategy(ServerSideApply)),
+			},
+			want: want{op: OperationPatched},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := &unstructured.Unstructured{}
+			o.SetName("cool")
+			got, err := tc.args.a.Apply(context.Background(), o, tc.args.opts...)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Apply(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.op, got); diff != "" {
+				t.Errorf("Apply(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
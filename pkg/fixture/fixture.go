@@ -0,0 +1,319 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fixture runs the templates of a stack against a directory of test
+// cases. Every subdirectory of the tests directory is a case:
+//
+//	tests/
+//	  stackdefinition.yaml    # optional, shared by all cases
+//	  <case>/
+//	    stackdefinition.yaml  # optional, overrides the shared one
+//	    cr.yaml               # the parent resource
+//	    expected/*.yaml       # the expected child resources
+//
+// The parent resource is rendered with the engine that is configured by the
+// StackDefinition of the case and patched with the default patchers of the
+// reconciler. The result has to match the documents of the expected files,
+// read in the lexical order of the file names, in the same order.
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// File and directory names of a case.
+const (
+	ParentFile          = "cr.yaml"
+	StackDefinitionFile = "stackdefinition.yaml"
+	ExpectedDir         = "expected"
+
+	// UpdatedFile is the file in ExpectedDir that Update writes.
+	UpdatedFile = "rendered.yaml"
+)
+
+const (
+	errReadTestsDir          = "cannot read tests directory"
+	errReadFile              = "cannot read file"
+	errParseFile             = "cannot parse file"
+	errUnmarshalSD           = "cannot unmarshal StackDefinition"
+	errFmtNoStackDefinition  = "case %s has no %s"
+	errFmtParentCount        = "%s must contain exactly one parent resource, found %d"
+	errNewEngine             = "cannot create the engine"
+	errRender                = "cannot render the parent resource"
+	errPatch                 = "cannot patch the child resources"
+	errNormalize             = "cannot normalize the child resources"
+	errMarshalChildResources = "cannot marshal the child resources"
+	errWriteExpected         = "cannot write the expected child resources"
+)
+
+// A Case is a parent resource and the child resources that rendering it is
+// expected to produce.
+type Case struct {
+	// Name of the case, i.e. the name of its directory.
+	Name string
+
+	// Dir is the directory of the case.
+	Dir string
+
+	StackDefinition *v1alpha1.StackDefinition
+	Parent          resource.ParentResource
+	Expected        []resource.ChildResource
+}
+
+// Load returns the cases in the subdirectories of the given tests directory,
+// sorted by name.
+func Load(dir string) ([]Case, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, errReadTestsDir)
+	}
+	shared, err := readStackDefinition(filepath.Join(dir, StackDefinitionFile))
+	if err != nil {
+		return nil, err
+	}
+	var cases []Case
+	for _, info := range infos {
+		if !info.IsDir() {
+			continue
+		}
+		c, err := load(filepath.Join(dir, info.Name()), shared)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load case %s", info.Name())
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+func load(dir string, shared *v1alpha1.StackDefinition) (Case, error) {
+	c := Case{Name: filepath.Base(dir), Dir: dir, StackDefinition: shared}
+	sd, err := readStackDefinition(filepath.Join(dir, StackDefinitionFile))
+	if err != nil {
+		return Case{}, err
+	}
+	if sd != nil {
+		c.StackDefinition = sd
+	}
+	if c.StackDefinition == nil {
+		return Case{}, errors.Errorf(errFmtNoStackDefinition, c.Name, StackDefinitionFile)
+	}
+	parents, err := readObjects(filepath.Join(dir, ParentFile))
+	if err != nil {
+		return Case{}, err
+	}
+	if len(parents) != 1 {
+		return Case{}, errors.Errorf(errFmtParentCount, ParentFile, len(parents))
+	}
+	c.Parent = parents[0]
+	files, err := filepath.Glob(filepath.Join(dir, ExpectedDir, "*.yaml"))
+	if err != nil {
+		return Case{}, errors.Wrap(err, errReadFile)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		objs, err := readObjects(f)
+		if err != nil {
+			return Case{}, err
+		}
+		for _, o := range objs {
+			c.Expected = append(c.Expected, o)
+		}
+	}
+	return c, nil
+}
+
+// readStackDefinition returns nil if the given file does not exist.
+func readStackDefinition(path string) (*v1alpha1.StackDefinition, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s", errReadFile, path)
+	}
+	sd := &v1alpha1.StackDefinition{}
+	return sd, errors.Wrapf(yaml.Unmarshal(data, sd), "%s %s", errUnmarshalSD, path)
+}
+
+func readObjects(path string) ([]*unstructured.Unstructured, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s %s", errReadFile, path)
+	}
+	objs, err := resource.ParseUnstructured(data)
+	return objs, errors.Wrapf(err, "%s %s", errParseFile, path)
+}
+
+// An EngineFactory returns the engine that is configured by the given
+// StackDefinition.
+type EngineFactory func(sd *v1alpha1.StackDefinition) (templating.Engine, error)
+
+// A Result is the outcome of running a Case.
+type Result struct {
+	Case Case
+
+	// Got is the list of child resources that were produced.
+	Got []resource.ChildResource
+
+	// Diff is the difference between the expected and the produced child
+	// resources. It's empty if they are the same.
+	Diff string
+
+	// Err is the error that stopped the case from producing child
+	// resources.
+	Err error
+}
+
+// Failed returns true if the case errored or produced unexpected child
+// resources.
+func (r Result) Failed() bool {
+	return r.Err != nil || r.Diff != ""
+}
+
+// A RunnerOption configures a Runner.
+type RunnerOption func(*Runner)
+
+// WithChildResourcePatchers returns a RunnerOption that replaces the
+// patchers that the child resources are patched with. The default patchers
+// of the reconciler are used by default.
+func WithChildResourcePatchers(p ...templating.ChildResourcePatcher) RunnerOption {
+	return func(r *Runner) {
+		r.patchers = p
+	}
+}
+
+// NewRunner returns a new *Runner that creates the engine of every case with
+// the given EngineFactory.
+func NewRunner(f EngineFactory, opts ...RunnerOption) *Runner {
+	r := &Runner{
+		newEngine: f,
+		patchers:  templating.DefaultChildResourcePatchers(),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Runner renders and patches the parent resources of the cases.
+type Runner struct {
+	newEngine EngineFactory
+	patchers  templating.ChildResourcePatcherChain
+}
+
+// Run runs the given Case.
+func (r *Runner) Run(c Case) Result {
+	res := Result{Case: c}
+	eng, err := r.newEngine(c.StackDefinition)
+	if err != nil {
+		res.Err = errors.Wrap(err, errNewEngine)
+		return res
+	}
+	// NOTE: The patchers modify the parent resource in some cases, so every
+	// run gets its own copy.
+	cr := c.Parent.DeepCopyObject().(resource.ParentResource)
+	ctx := context.Background()
+	rendered, err := eng.Run(ctx, cr)
+	if err != nil {
+		res.Err = errors.Wrap(err, errRender)
+		return res
+	}
+	list, err := r.patchers.Patch(ctx, cr, rendered.Children)
+	if err != nil {
+		res.Err = errors.Wrap(err, errPatch)
+		return res
+	}
+	if res.Got, err = normalize(list); err != nil {
+		res.Err = errors.Wrap(err, errNormalize)
+		return res
+	}
+	res.Diff = cmp.Diff(c.Expected, res.Got, cmpopts.EquateEmpty())
+	return res
+}
+
+// RunAll runs the given cases in order.
+func (r *Runner) RunAll(cases []Case) []Result {
+	results := make([]Result, len(cases))
+	for i, c := range cases {
+		results[i] = r.Run(c)
+	}
+	return results
+}
+
+// Update replaces the expected child resources of the case of the given
+// Result with the child resources that it produced. All files in the
+// ExpectedDir of the case are replaced with a single UpdatedFile.
+func Update(res Result) error {
+	buf := &bytes.Buffer{}
+	for _, o := range res.Got {
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return errors.Wrap(err, errMarshalChildResources)
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+	}
+	dir := filepath.Join(res.Case.Dir, ExpectedDir)
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return errors.Wrap(err, errWriteExpected)
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return errors.Wrap(err, errWriteExpected)
+		}
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return errors.Wrap(err, errWriteExpected)
+	}
+	return errors.Wrap(ioutil.WriteFile(filepath.Join(dir, UpdatedFile), buf.Bytes(), 0600), errWriteExpected)
+}
+
+// normalize round-trips the given child resources through JSON so that they
+// are comparable with the ones that are read from the expected files, e.g.
+// all integers are int64.
+func normalize(list []resource.ChildResource) ([]resource.ChildResource, error) {
+	result := make([]resource.ChildResource, len(list))
+	for i, o := range list {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, err
+		}
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		result[i] = u
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fixture
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	files := map[string]string{
+		StackDefinitionFile:        "metadata:\n  name: shared\n",
+		"a/" + ParentFile:          "kind: Parent\nmetadata:\n  name: a\n",
+		"a/expected/2.yaml":        "kind: Second\n",
+		"a/expected/1.yaml":        "kind: First\n---\nkind: FirstToo\n",
+		"b/" + ParentFile:          "kind: Parent\nmetadata:\n  name: b\n",
+		"b/" + StackDefinitionFile: "metadata:\n  name: own\n",
+	}
+	for f, content := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load(...): unexpected error: %v", err)
+	}
+	type summary struct {
+		Name            string
+		StackDefinition string
+		Parent          string
+		Expected        []string
+	}
+	want := []summary{
+		{Name: "a", StackDefinition: "shared", Parent: "a", Expected: []string{"First", "FirstToo", "Second"}},
+		{Name: "b", StackDefinition: "own", Parent: "b"},
+	}
+	got := make([]summary, len(cases))
+	for i, c := range cases {
+		got[i] = summary{Name: c.Name, StackDefinition: c.StackDefinition.GetName(), Parent: c.Parent.GetName()}
+		for _, o := range c.Expected {
+			got[i].Expected = append(got[i].Expected, o.GetObjectKind().GroupVersionKind().Kind)
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Load(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	errBoom := errors.New("boom")
+	child := func(name string, replicas int) resource.ChildResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"kind":     "Child",
+			"metadata": map[string]interface{}{"name": name},
+			"spec":     map[string]interface{}{"replicas": replicas},
+		}}
+	}
+	engine := func(list ...resource.ChildResource) EngineFactory {
+		return func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+			return templating.EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{Children: list}, nil
+			}), nil
+		}
+	}
+	// NOTE: The expected child resources are read from YAML, so their
+	// integers are int64.
+	expected := &unstructured.Unstructured{Object: map[string]interface{}{
+		"kind":     "Child",
+		"metadata": map[string]interface{}{"name": "a"},
+		"spec":     map[string]interface{}{"replicas": int64(3)},
+	}}
+	c := Case{
+		Name:     "case",
+		Parent:   &unstructured.Unstructured{},
+		Expected: []resource.ChildResource{expected},
+	}
+	type want struct {
+		failed bool
+		err    error
+	}
+	cases := map[string]struct {
+		r    *Runner
+		want want
+	}{
+		"Matched": {
+			r: NewRunner(engine(child("a", 3)), WithChildResourcePatchers()),
+		},
+		"Mismatched": {
+			r:    NewRunner(engine(child("a", 3), child("b", 1)), WithChildResourcePatchers()),
+			want: want{failed: true},
+		},
+		"EngineFailed": {
+			r: NewRunner(func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+				return nil, errBoom
+			}),
+			want: want{failed: true, err: errors.Wrap(errBoom, errNewEngine)},
+		},
+		"RenderFailed": {
+			r: NewRunner(func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+				return templating.EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+					return resource.RenderResult{}, errBoom
+				}), nil
+			}),
+			want: want{failed: true, err: errors.Wrap(errBoom, errRender)},
+		},
+		"PatchFailed": {
+			r: NewRunner(engine(child("a", 3)), WithChildResourcePatchers(templating.ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+				return nil, errBoom
+			}))),
+			want: want{failed: true, err: errors.Wrap(errBoom, errPatch)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			res := tc.r.Run(c)
+			if diff := cmp.Diff(tc.want.err, res.Err, test.EquateErrors()); diff != "" {
+				t.Errorf("Run(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.failed, res.Failed()); diff != "" {
+				t.Errorf("Run(...).Failed(): -want, +got:\n%s\n%s", diff, res.Diff)
+			}
+		})
+	}
+}
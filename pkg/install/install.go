@@ -0,0 +1,209 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"path"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+const (
+	// BehaviorsDir is the directory that the template source is copied to
+	// in the controller Pod.
+	BehaviorsDir = "/behaviors"
+
+	// HealthProbePort is the port of the readiness probe endpoint of the
+	// controller.
+	HealthProbePort = 8081
+
+	behaviorsVolumeName = "behaviors"
+	controllerName      = "templating-controller"
+	appLabelKey         = "app"
+)
+
+// NewServiceAccount returns the ServiceAccount that the controller runs with.
+func NewServiceAccount(name, namespace string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+	}
+}
+
+// NewClusterRoleBinding returns a ClusterRoleBinding that binds the
+// ClusterRole with given name to the ServiceAccount with given name and
+// namespace.
+func NewClusterRoleBinding(name, namespace string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: namespace},
+		},
+	}
+}
+
+// NewRoleBinding returns a RoleBinding that binds the Role with given name to
+// the ServiceAccount with given name. All of them live in the given namespace.
+func NewRoleBinding(name, namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "RoleBinding",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     name,
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: namespace},
+		},
+	}
+}
+
+// NewDeployment returns the Deployment that runs the templating controller
+// for the given StackDefinition. The template source is copied from the
+// source image into a shared volume by an init container, similar to what
+// the Crossplane unpack step does.
+func NewDeployment(sd *v1alpha1.StackDefinition, name, namespace, image string) *appsv1.Deployment {
+	labels := map[string]string{appLabelKey: name}
+	src := strings.TrimSuffix(sd.Spec.Behavior.Source.Path, "/")
+	resourcesDir := path.Join(BehaviorsDir, path.Base(src))
+	args := []string{
+		"--stack-definition-name", sd.GetName(),
+		"--resources-dir", resourcesDir,
+	}
+	if sd.GetNamespace() != "" {
+		args = append(args, "--stack-definition-namespace", sd.GetNamespace())
+	}
+	var replicas int32 = 1
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: name,
+					InitContainers: []corev1.Container{
+						{
+							Name:    "behavior-copy",
+							Image:   sd.Spec.Behavior.Source.Image,
+							Command: []string{"cp", "-R", src, BehaviorsDir + "/"},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: behaviorsVolumeName, MountPath: BehaviorsDir},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  controllerName,
+							Image: image,
+							Args:  args,
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: behaviorsVolumeName, MountPath: BehaviorsDir},
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{
+									HTTPGet: &corev1.HTTPGetAction{Path: "/readyz", Port: intstr.FromInt(HealthProbePort)},
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name:         behaviorsVolumeName,
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// NewCustomResourceDefinition returns a CustomResourceDefinition for the
+// parent resource of given kind with the given OpenAPI v3 schema. If the
+// schema is nil, the returned CRD accepts any field.
+func NewCustomResourceDefinition(gvk schema.GroupVersionKind, scope apiextensionsv1.ResourceScope, s *apiextensionsv1.JSONSchemaProps) *apiextensionsv1.CustomResourceDefinition {
+	plural, singular := meta.UnsafeGuessKindToResource(gvk)
+	if s == nil {
+		preserve := true
+		s = &apiextensionsv1.JSONSchemaProps{
+			Type:                   "object",
+			XPreserveUnknownFields: &preserve,
+		}
+	}
+	return &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+			Kind:       "CustomResourceDefinition",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: plural.GroupResource().String()},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: gvk.Group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Plural:   plural.Resource,
+				Singular: singular.Resource,
+				Kind:     gvk.Kind,
+				ListKind: gvk.Kind + "List",
+			},
+			Scope: scope,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    gvk.Version,
+					Served:  true,
+					Storage: true,
+					Subresources: &apiextensionsv1.CustomResourceSubresources{
+						Status: &apiextensionsv1.CustomResourceSubresourceStatus{},
+					},
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: s,
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package install
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestNewCustomResourceDefinition(t *testing.T) {
+	crd := NewCustomResourceDefinition(fake.MockParentGVK, apiextensionsv1.NamespaceScoped, nil)
+	want := apiextensionsv1.CustomResourceDefinitionNames{
+		Plural:   "mockresources",
+		Singular: "mockresource",
+		Kind:     "MockResource",
+		ListKind: "MockResourceList",
+	}
+	if diff := cmp.Diff(want, crd.Spec.Names); diff != "" {
+		t.Errorf("NewCustomResourceDefinition(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff("mockresources.mock.parent.crossplane.io", crd.GetName()); diff != "" {
+		t.Errorf("NewCustomResourceDefinition(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestNewDeployment(t *testing.T) {
+	sd := &v1alpha1.StackDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "coolstack", Namespace: "coolns"},
+		Spec: v1alpha1.StackDefinitionSpec{
+			Behavior: v1alpha1.Behavior{
+				Source: v1alpha1.StackDefinitionSource{Image: "crossplane/cool:0.1.0", Path: "helm-chart/"},
+			},
+		},
+	}
+	d := NewDeployment(sd, "coolstack", "coolns", "crossplane/templating-controller:v0.1.0")
+	wantArgs := []string{
+		"--stack-definition-name", "coolstack",
+		"--resources-dir", "/behaviors/helm-chart",
+		"--stack-definition-namespace", "coolns",
+	}
+	if diff := cmp.Diff(wantArgs, d.Spec.Template.Spec.Containers[0].Args); diff != "" {
+		t.Errorf("NewDeployment(...): -want, +got:\n%s", diff)
+	}
+	wantCommand := []string{"cp", "-R", "helm-chart", "/behaviors/"}
+	if diff := cmp.Diff(wantCommand, d.Spec.Template.Spec.InitContainers[0].Command); diff != "" {
+		t.Errorf("NewDeployment(...): -want, +got:\n%s", diff)
+	}
+	wantProbe := &corev1.HTTPGetAction{Path: "/readyz", Port: intstr.FromInt(HealthProbePort)}
+	if diff := cmp.Diff(wantProbe, d.Spec.Template.Spec.Containers[0].ReadinessProbe.HTTPGet); diff != "" {
+		t.Errorf("NewDeployment(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lookup reads the existing objects in the cluster for the lookup
+// function of the templates.
+package lookup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Timeout is the timeout of every lookup.
+const Timeout = 10 * time.Second
+
+const (
+	errNotAllowed  = "the permissions of the StackDefinition do not allow to get, list and watch it"
+	errFmtMapping  = "cannot find the resource of %s"
+	errGet         = "cannot get the looked up object"
+	errList        = "cannot list the looked up objects"
+	errSubresource = "the subresources cannot be looked up"
+)
+
+// Verbs are the verbs that the rules of a Lookup have to grant for a
+// resource to be looked up, which are the ones that the cache of the
+// controller needs.
+var Verbs = []string{"get", "list", "watch"}
+
+// New returns a new *Lookup that reads from the given reader, e.g. the cache
+// of the controller, the resources that the given rules, e.g. the permissions
+// of the StackDefinition, allow.
+func New(r client.Reader, m meta.RESTMapper, rules []rbacv1.PolicyRule) *Lookup {
+	return &Lookup{reader: r, mapper: m, rules: rules}
+}
+
+// A Lookup reads the existing objects in the cluster. Only the resources that
+// its rules grant all of Verbs for without limiting their names are read.
+type Lookup struct {
+	reader client.Reader
+	mapper meta.RESTMapper
+	rules  []rbacv1.PolicyRule
+}
+
+// Lookup returns the object of the given kind with the given name in the
+// given namespace, or the list of the objects of that kind in that namespace
+// if the name is empty, like the lookup function of Helm. An object that does
+// not exist is returned as an empty object, and the namespace of the kinds
+// that are not namespaced is ignored.
+func (l *Lookup) Lookup(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	m, err := l.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, errFmtMapping, gvk)
+	}
+	if m.Scope.Name() != meta.RESTScopeNameNamespace {
+		namespace = ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	obj, err := l.read(ctx, m.Resource, gvk, namespace, name)
+	if kerrors.IsNotFound(errors.Cause(err)) {
+		return map[string]interface{}{}, nil
+	}
+	return obj, err
+}
+
+// read returns the object of the given resource and kind with the given name
+// in the given namespace, or the list of them if the name is empty.
+func (l *Lookup) read(ctx context.Context, gvr schema.GroupVersionResource, gvk schema.GroupVersionKind, namespace, name string) (map[string]interface{}, error) {
+	if !l.allows(gvr.GroupResource()) {
+		return nil, kerrors.NewForbidden(gvr.GroupResource(), name, errors.New(errNotAllowed))
+	}
+	if name == "" {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := l.reader.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, errors.Wrap(err, errList)
+		}
+		return list.UnstructuredContent(), nil
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	if err := l.reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, u); err != nil {
+		return nil, errors.Wrap(err, errGet)
+	}
+	return u.UnstructuredContent(), nil
+}
+
+// allows returns true if the rules of the Lookup grant all of Verbs for the
+// given resource.
+func (l *Lookup) allows(gr schema.GroupResource) bool {
+	for _, verb := range Verbs {
+		granted := false
+		for _, r := range l.rules {
+			if len(r.ResourceNames) == 0 && matches(r.Verbs, verb) && matches(r.APIGroups, gr.Group) && matches(r.Resources, gr.Resource) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(list []string, s string) bool {
+	for _, v := range list {
+		if v == rbacv1.ResourceAll || v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Config returns a copy of the given config whose requests of objects are
+// served by the given Lookup, so that the clients of the config, e.g. the
+// lookup function of Helm, read from the cache of the controller within the
+// rules of the Lookup. The discovery requests are still sent to the API
+// server.
+func Config(cfg *rest.Config, l *Lookup) *rest.Config {
+	c := rest.CopyConfig(cfg)
+	wrap := c.WrapTransport
+	c.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return &transport{next: rt, lookup: l}
+	}
+	return c
+}
+
+// transport serves the requests of the objects with a Lookup and sends the
+// others to the next http.RoundTripper.
+type transport struct {
+	next   http.RoundTripper
+	lookup *Lookup
+}
+
+// RoundTrip serves the given request.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	gvr, namespace, name, ok := objectPath(req.URL.Path)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+	if req.Method != http.MethodGet {
+		return respond(req, nil, kerrors.NewMethodNotSupported(gvr.GroupResource(), req.Method))
+	}
+	// NOTE: The subresources are not served, but they're not sent to the API
+	// server either.
+	if strings.Contains(gvr.Resource, "/") {
+		return respond(req, nil, kerrors.NewForbidden(gvr.GroupResource(), name, errors.New(errSubresource)))
+	}
+	gvk, err := t.lookup.mapper.KindFor(gvr)
+	if err != nil {
+		return respond(req, nil, kerrors.NewNotFound(gvr.GroupResource(), name))
+	}
+	obj, err := t.lookup.read(req.Context(), gvr, gvk, namespace, name)
+	return respond(req, obj, err)
+}
+
+// objectPath returns the resource, the namespace and the name of the object
+// in the given path of the API server, e.g. /api/v1/namespaces/a/secrets/b,
+// and false if the path does not refer to objects, e.g. it's a discovery path.
+// The subresources are returned as resource/subresource, e.g. pods/log.
+func objectPath(p string) (schema.GroupVersionResource, string, string, bool) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	var gv schema.GroupVersion
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		gv, parts = schema.GroupVersion{Version: parts[1]}, parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		gv, parts = schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:]
+	default:
+		return schema.GroupVersionResource{}, "", "", false
+	}
+	namespace := ""
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		namespace, parts = parts[1], parts[2:]
+	}
+	switch len(parts) {
+	case 1:
+		return gv.WithResource(parts[0]), namespace, "", true
+	case 2:
+		return gv.WithResource(parts[0]), namespace, parts[1], true
+	}
+	return gv.WithResource(parts[0] + "/" + parts[2]), namespace, parts[1], true
+}
+
+// respond returns the response of the given request with the given object,
+// or with the status of the given error.
+func respond(req *http.Request, obj map[string]interface{}, err error) (*http.Response, error) {
+	code := http.StatusOK
+	var body interface{} = obj
+	if err != nil {
+		status := kerrors.NewInternalError(err).Status()
+		if s, ok := errors.Cause(err).(kerrors.APIStatus); ok {
+			status = s.Status()
+		}
+		status.TypeMeta = metav1.TypeMeta{Kind: "Status", APIVersion: "v1"}
+		code, body = int(status.Code), status
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}, nil
+}
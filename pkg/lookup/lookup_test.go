@@ -0,0 +1,203 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lookup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var errBoom = errors.New("boom")
+
+var (
+	secret = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	node   = schema.GroupVersionKind{Version: "v1", Kind: "Node"}
+)
+
+func mapper() meta.RESTMapper {
+	m := meta.NewDefaultRESTMapper(nil)
+	m.Add(secret, meta.RESTScopeNamespace)
+	m.Add(node, meta.RESTScopeRoot)
+	return m
+}
+
+var readSecrets = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: Verbs}}
+
+func withName(gvk schema.GroupVersionKind, namespace, name string) map[string]interface{} {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u.UnstructuredContent()
+}
+
+func TestLookup(t *testing.T) {
+	type args struct {
+		apiVersion string
+		kind       string
+		namespace  string
+		name       string
+	}
+	type want struct {
+		obj map[string]interface{}
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		reader client.Reader
+		rules  []rbacv1.PolicyRule
+		args   args
+		want   want
+	}{
+		"Get": {
+			reason: "An object of an allowed kind should be read from the reader.",
+			reader: &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				u := obj.(*unstructured.Unstructured)
+				u.SetNamespace(key.Namespace)
+				u.SetName(key.Name)
+				return nil
+			}},
+			rules: readSecrets,
+			args:  args{apiVersion: "v1", kind: "Secret", namespace: "default", name: "admin"},
+			want:  want{obj: withName(secret, "default", "admin")},
+		},
+		"List": {
+			reason: "The objects of an allowed kind should be listed in the namespace if the name is empty.",
+			reader: &test.MockClient{MockList: func(_ context.Context, obj runtime.Object, opts ...client.ListOption) error {
+				o := &client.ListOptions{}
+				o.ApplyOptions(opts)
+				u := &unstructured.Unstructured{Object: withName(secret, o.Namespace, "admin")}
+				obj.(*unstructured.UnstructuredList).Items = []unstructured.Unstructured{*u}
+				return nil
+			}},
+			rules: readSecrets,
+			args:  args{apiVersion: "v1", kind: "Secret", namespace: "default"},
+			want: want{obj: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "SecretList",
+				"items":      []interface{}{withName(secret, "default", "admin")},
+			}},
+		},
+		"NotFound": {
+			reason: "An object that does not exist should be returned as an empty object.",
+			reader: &test.MockClient{MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "admin"))},
+			rules:  readSecrets,
+			args:   args{apiVersion: "v1", kind: "Secret", namespace: "default", name: "admin"},
+			want:   want{obj: map[string]interface{}{}},
+		},
+		"ClusterScoped": {
+			reason: "The namespace of a kind that is not namespaced should be ignored.",
+			reader: &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+				u := obj.(*unstructured.Unstructured)
+				u.SetNamespace(key.Namespace)
+				u.SetName(key.Name)
+				return nil
+			}},
+			rules: []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+			args:  args{apiVersion: "v1", kind: "Node", namespace: "default", name: "a"},
+			want:  want{obj: withName(node, "", "a")},
+		},
+		"NotAllowed": {
+			reason: "A kind that the rules do not allow to get, list and watch should not be read.",
+			reader: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			rules:  []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			args:   args{apiVersion: "v1", kind: "Secret", namespace: "default", name: "admin"},
+			want:   want{err: kerrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "admin", errors.New(errNotAllowed))},
+		},
+		"NamesLimited": {
+			reason: "A rule that limits the names of the objects should not allow a kind to be read.",
+			reader: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			rules:  []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"admin"}, Verbs: Verbs}},
+			args:   args{apiVersion: "v1", kind: "Secret", namespace: "default", name: "admin"},
+			want:   want{err: kerrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "admin", errors.New(errNotAllowed))},
+		},
+		"GetFailed": {
+			reason: "The errors of the reader should be returned.",
+			reader: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			rules:  readSecrets,
+			args:   args{apiVersion: "v1", kind: "Secret", namespace: "default", name: "admin"},
+			want:   want{err: errors.Wrap(errBoom, errGet)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			l := New(tc.reader, mapper(), tc.rules)
+			got, err := l.Lookup(tc.args.apiVersion, tc.args.kind, tc.args.namespace, tc.args.name)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nLookup(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.obj, got); diff != "" {
+				t.Errorf("\n%s\nLookup(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	reader := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			if key.Name != "admin" {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+			}
+			u := obj.(*unstructured.Unstructured)
+			u.SetNamespace(key.Namespace)
+			u.SetName(key.Name)
+			return nil
+		},
+	}
+	// NOTE: Nothing listens on the host, so any request that is not served by
+	// the Lookup fails.
+	cfg := Config(&rest.Config{Host: "http://127.0.0.1:1"}, New(reader, mapper(), readSecrets))
+	c, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewForConfig(...): %s", err)
+	}
+	secrets := c.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"}).Namespace("default")
+
+	got, err := secrets.Get(context.Background(), "admin", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get(...): %s", err)
+	}
+	if diff := cmp.Diff(withName(secret, "default", "admin"), got.UnstructuredContent()); diff != "" {
+		t.Errorf("Get(...): -want, +got:\n%s", diff)
+	}
+	if _, err := secrets.Get(context.Background(), "other", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("Get(...): want a not found error, got %v", err)
+	}
+	if err := secrets.Delete(context.Background(), "admin", metav1.DeleteOptions{}); !kerrors.IsMethodNotSupported(err) {
+		t.Errorf("Delete(...): want a method not supported error, got %v", err)
+	}
+	nodes := c.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"})
+	if _, err := nodes.Get(context.Background(), "a", metav1.GetOptions{}); !kerrors.IsForbidden(err) {
+		t.Errorf("Get(...): want a forbidden error, got %v", err)
+	}
+}
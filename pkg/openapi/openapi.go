@@ -0,0 +1,289 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+const (
+	specField   = "spec"
+	statusField = "status"
+
+	typeObject = "object"
+
+	// ValuesSchemaFileName is the name of the file in a Helm chart that
+	// contains the JSON Schema of the values.
+	ValuesSchemaFileName = "values.schema.json"
+
+	errReadValuesSchema      = "cannot read values schema file"
+	errUnmarshalValuesSchema = "cannot unmarshal values schema"
+	errConvertValuesSchema   = "cannot convert values schema into OpenAPI v3 schema"
+)
+
+// unsupportedKeys are the JSON Schema keywords that are not allowed in a
+// structural schema of a CustomResourceDefinition.
+var unsupportedKeys = []string{"$schema", "$id", "$ref", "$comment", "definitions"}
+
+// FromValuesSchema converts the given JSON Schema, typically content of the
+// values.schema.json file of a Helm chart, into an OpenAPI v3 schema that can
+// be used in a CustomResourceDefinition. The keywords that are not allowed in
+// structural schemas are dropped.
+func FromValuesSchema(data []byte) (*apiextensionsv1.JSONSchemaProps, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalValuesSchema)
+	}
+	dropUnsupported(raw)
+	cleaned, err := json.Marshal(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, errConvertValuesSchema)
+	}
+	s := &apiextensionsv1.JSONSchemaProps{}
+	if err := json.Unmarshal(cleaned, s); err != nil {
+		return nil, errors.Wrap(err, errConvertValuesSchema)
+	}
+	if s.Type == "" {
+		s.Type = typeObject
+	}
+	return s, nil
+}
+
+// ForStackDefinition returns the OpenAPI v3 schema of the parent resource of
+// the given StackDefinition. If the chart in resourceDir has a values schema,
+// it is used as the schema of spec, along with the conditions and tags of the
+// dependencies of the chart. Otherwise, the schema is derived from the
+// declared kustomize overlay bindings. If neither exists, any field is
+// accepted in spec.
+func ForStackDefinition(sd *v1alpha1.StackDefinition, resourceDir string) (*apiextensionsv1.JSONSchemaProps, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(resourceDir, ValuesSchemaFileName)))
+	switch {
+	case err == nil:
+		spec, err := FromValuesSchema(data)
+		if err != nil {
+			return nil, err
+		}
+		deps, err := DependenciesOf(resourceDir)
+		if err != nil {
+			return nil, err
+		}
+		return ForParent(WithToggles(spec, deps)), nil
+	case !os.IsNotExist(err):
+		return nil, errors.Wrap(err, errReadValuesSchema)
+	}
+	if k := sd.Spec.Behavior.Engine.Kustomize; k != nil && len(k.Overlays) != 0 {
+		return ForParent(FromOverlays(k.Overlays)), nil
+	}
+	return ForParent(nil), nil
+}
+
+// FromBindings returns the OpenAPI v3 schema of the parent resource spec that
+// covers all the fields that the given bindings read from. Since the types of
+// the fields cannot be derived from the bindings, the leaves accept any value.
+func FromBindings(bindings []v1alpha1.FieldBinding) *apiextensionsv1.JSONSchemaProps {
+	spec := newObject()
+	for _, b := range bindings {
+		path := strings.Split(b.From, ".")
+		if len(path) < 2 || path[0] != specField {
+			continue
+		}
+		current := &spec
+		for i, f := range path[1:] {
+			child, ok := current.Properties[f]
+			if !ok {
+				child = newObject()
+				if i == len(path)-2 {
+					child = newAny()
+				}
+			}
+			if i != len(path)-2 && child.Type != typeObject {
+				// This field has been declared as a leaf by another binding
+				// but it's used as an object here.
+				child = newObject()
+			}
+			current.Properties[f] = child
+			if child.Type != typeObject {
+				break
+			}
+			current = &child
+		}
+	}
+	return &spec
+}
+
+// FromOverlays returns the OpenAPI v3 schema of the parent resource spec that
+// covers all the fields that the bindings of the given overlays read from.
+func FromOverlays(overlays []v1alpha1.KustomizeEngineOverlay) *apiextensionsv1.JSONSchemaProps {
+	var bindings []v1alpha1.FieldBinding
+	for _, o := range overlays {
+		bindings = append(bindings, o.Bindings...)
+	}
+	return FromBindings(bindings)
+}
+
+// WithFields adds the given dot separated fields of the parent resource, e.g.
+// spec.image, to the given schema of the parent resource as leaves that
+// accept any value, unless they're already declared. The fields outside of
+// spec are skipped since metadata has a fixed schema and status accepts any
+// field already.
+func WithFields(s *apiextensionsv1.JSONSchemaProps, fields ...string) *apiextensionsv1.JSONSchemaProps {
+	if s == nil {
+		return s
+	}
+	for _, f := range fields {
+		path := strings.Split(f, ".")
+		if len(path) < 2 || path[0] != specField {
+			continue
+		}
+		*s = withLeaf(*s, path, newAny())
+	}
+	return s
+}
+
+// WithEnum adds the given dot separated field of the parent resource, e.g.
+// spec.size, to the given schema of the parent resource as a string that
+// accepts only the given values, unless it's already declared.
+func WithEnum(s *apiextensionsv1.JSONSchemaProps, field string, values ...string) *apiextensionsv1.JSONSchemaProps {
+	if s == nil {
+		return s
+	}
+	leaf := apiextensionsv1.JSONSchemaProps{Type: typeString}
+	for _, v := range values {
+		raw, _ := json.Marshal(v)
+		leaf.Enum = append(leaf.Enum, apiextensionsv1.JSON{Raw: raw})
+	}
+	*s = withLeaf(*s, strings.Split(field, "."), leaf)
+	return s
+}
+
+// ForParent returns the OpenAPI v3 schema of a parent resource whose spec
+// has the given schema. If spec schema is nil, any field is accepted in spec.
+func ForParent(spec *apiextensionsv1.JSONSchemaProps) *apiextensionsv1.JSONSchemaProps {
+	if spec == nil {
+		s := newAny()
+		s.Type = typeObject
+		spec = &s
+	}
+	status := newAny()
+	status.Type = typeObject
+	return &apiextensionsv1.JSONSchemaProps{
+		Type: typeObject,
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			specField:   *spec,
+			statusField: status,
+		},
+	}
+}
+
+// Prune removes the fields of the given object that are not declared in the
+// given schema, recursively, and returns their sorted paths relative to the
+// object. The fields of the objects that preserve unknown fields or allow
+// additional properties are kept.
+func Prune(obj map[string]interface{}, s *apiextensionsv1.JSONSchemaProps) []string {
+	var pruned []string
+	prune(obj, s, "", &pruned)
+	sort.Strings(pruned)
+	return pruned
+}
+
+func prune(val interface{}, s *apiextensionsv1.JSONSchemaProps, path string, pruned *[]string) { // nolint:gocyclo
+	if s == nil {
+		return
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		preserve := s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+		for k, field := range v {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			if sub, ok := s.Properties[k]; ok {
+				prune(field, &sub, fieldPath, pruned)
+				continue
+			}
+			if ap := s.AdditionalProperties; ap != nil && (ap.Allows || ap.Schema != nil) {
+				prune(field, ap.Schema, fieldPath, pruned)
+				continue
+			}
+			if preserve {
+				continue
+			}
+			delete(v, k)
+			*pruned = append(*pruned, fieldPath)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, e := range v {
+			prune(e, s.Items.Schema, fmt.Sprintf("%s[%d]", path, i), pruned)
+		}
+	}
+}
+
+func newObject() apiextensionsv1.JSONSchemaProps {
+	return apiextensionsv1.JSONSchemaProps{
+		Type:       typeObject,
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{},
+	}
+}
+
+func newAny() apiextensionsv1.JSONSchemaProps {
+	preserve := true
+	return apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: &preserve}
+}
+
+// dropUnsupported removes the unsupported keywords from the given schema and
+// all of its subschemas.
+func dropUnsupported(schema map[string]interface{}) {
+	for _, k := range unsupportedKeys {
+		delete(schema, k)
+	}
+	for k, v := range schema {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if k != "properties" && k != "patternProperties" {
+				dropUnsupported(val)
+				continue
+			}
+			// Keys of these maps are field names, not keywords.
+			for _, sub := range val {
+				if subSchema, ok := sub.(map[string]interface{}); ok {
+					dropUnsupported(subSchema)
+				}
+			}
+		case []interface{}:
+			for _, e := range val {
+				if subSchema, ok := e.(map[string]interface{}); ok {
+					dropUnsupported(subSchema)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+func TestFromValuesSchema(t *testing.T) {
+	cases := map[string]struct {
+		data    string
+		want    *apiextensionsv1.JSONSchemaProps
+		wantErr bool
+	}{
+		"UnsupportedKeywordsDropped": {
+			data: `{
+  "$schema": "http://json-schema.org/schema#",
+  "properties": {
+    "replicaCount": {"type": "integer", "$comment": "number of pods"},
+    "$ref": {"type": "string"}
+  },
+  "required": ["replicaCount"]
+}`,
+			want: &apiextensionsv1.JSONSchemaProps{
+				Type: "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"replicaCount": {Type: "integer"},
+					"$ref":         {Type: "string"},
+				},
+				Required: []string{"replicaCount"},
+			},
+		},
+		"InvalidJSON": {
+			data:    `{`,
+			wantErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FromValuesSchema([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("FromValuesSchema(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("FromValuesSchema(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromBindings(t *testing.T) {
+	preserve := true
+	anyValue := apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: &preserve}
+	bindings := []v1alpha1.FieldBinding{
+		{From: "spec.replicas", To: "spec.replicas"},
+		{From: "spec.image.tag", To: "metadata.labels.tag"},
+		{From: "spec.image", To: "metadata.labels"},
+		{From: "metadata.name", To: "metadata.labels.name"},
+	}
+	want := &apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"replicas": anyValue,
+			"image": {
+				Type: "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"tag": anyValue,
+				},
+			},
+		},
+	}
+	got := FromBindings(bindings)
+	if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("FromBindings(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := FromBindings([]v1alpha1.FieldBinding{
+		{From: "spec.replicas", To: "spec.replicas"},
+		{From: "spec.image.tag", To: "metadata.labels.tag"},
+	})
+	s.Properties["ports"] = apiextensionsv1.JSONSchemaProps{
+		Type: "array",
+		Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"port": {Type: "integer"},
+			},
+		}},
+	}
+	spec := map[string]interface{}{
+		"replicas": map[string]interface{}{"min": int64(1)},
+		"replcias": int64(3),
+		"image": map[string]interface{}{
+			"tag":  "5.4",
+			"repo": "wordpress",
+		},
+		"ports": []interface{}{
+			map[string]interface{}{"port": int64(80), "name": "http"},
+		},
+	}
+	want := map[string]interface{}{
+		"replicas": map[string]interface{}{"min": int64(1)},
+		"image": map[string]interface{}{
+			"tag": "5.4",
+		},
+		"ports": []interface{}{
+			map[string]interface{}{"port": int64(80)},
+		},
+	}
+	pruned := Prune(spec, s)
+	if diff := cmp.Diff([]string{"image.repo", "ports[0].name", "replcias"}, pruned); diff != "" {
+		t.Errorf("Prune(...): -want pruned, +got pruned:\n%s", diff)
+	}
+	if diff := cmp.Diff(want, spec); diff != "" {
+		t.Errorf("Prune(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWithScale(t *testing.T) {
+	selector := ".status.selector"
+	scale := &apiextensionsv1.CustomResourceSubresourceScale{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
+		LabelSelectorPath:  &selector,
+	}
+	spec := newObject()
+	spec.Properties["replicas"] = apiextensionsv1.JSONSchemaProps{Type: typeInteger, Minimum: new(float64)}
+	got := WithScale(ForParent(&spec), scale)
+
+	wantSpec := newObject()
+	wantSpec.Properties["replicas"] = apiextensionsv1.JSONSchemaProps{Type: typeInteger, Minimum: new(float64)}
+	wantStatus := newAny()
+	wantStatus.Type = typeObject
+	wantStatus.Properties = map[string]apiextensionsv1.JSONSchemaProps{
+		"replicas": {Type: typeInteger},
+		"selector": {Type: typeString},
+	}
+	want := &apiextensionsv1.JSONSchemaProps{
+		Type: typeObject,
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			specField:   wantSpec,
+			statusField: wantStatus,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WithScale(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	spec := newObject()
+	spec.Properties["image"] = apiextensionsv1.JSONSchemaProps{Type: typeString}
+	got := WithFields(ForParent(&spec), "spec.image", "spec.sidecar.image", "metadata.name", "status.endpoint")
+
+	wantSpec := newObject()
+	wantSpec.Properties["image"] = apiextensionsv1.JSONSchemaProps{Type: typeString}
+	sidecar := newObject()
+	sidecar.Properties["image"] = newAny()
+	wantSpec.Properties["sidecar"] = sidecar
+	want := ForParent(&wantSpec)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WithFields(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWithEnum(t *testing.T) {
+	spec := newObject()
+	got := WithEnum(ForParent(&spec), "spec.size", "large", "small")
+
+	wantSpec := newObject()
+	wantSpec.Properties["size"] = apiextensionsv1.JSONSchemaProps{
+		Type: typeString,
+		Enum: []apiextensionsv1.JSON{{Raw: []byte(`"large"`)}, {Raw: []byte(`"small"`)}},
+	}
+	want := ForParent(&wantSpec)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WithEnum(...): -want, +got:\n%s", diff)
+	}
+}
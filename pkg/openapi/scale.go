@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	typeInteger = "integer"
+	typeString  = "string"
+)
+
+// WithScale adds the fields of the given scale subresource to the given
+// schema of a parent resource, unless the schema declares them already. The
+// replicas are integers and the label selector is a string, as the scale
+// subresource requires.
+func WithScale(s *apiextensionsv1.JSONSchemaProps, scale *apiextensionsv1.CustomResourceSubresourceScale) *apiextensionsv1.JSONSchemaProps {
+	if s == nil || scale == nil {
+		return s
+	}
+	integer := apiextensionsv1.JSONSchemaProps{Type: typeInteger}
+	*s = withLeaf(*s, jsonPath(scale.SpecReplicasPath), integer)
+	*s = withLeaf(*s, jsonPath(scale.StatusReplicasPath), integer)
+	if scale.LabelSelectorPath != nil {
+		*s = withLeaf(*s, jsonPath(*scale.LabelSelectorPath), apiextensionsv1.JSONSchemaProps{Type: typeString})
+	}
+	return s
+}
+
+// jsonPath returns the fields of the given JSON path, e.g. .spec.replicas.
+func jsonPath(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "."), ".")
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// ChartFileName is the name of the file in a Helm chart that contains
+	// its metadata, including the dependencies of apiVersion v2 charts.
+	ChartFileName = "Chart.yaml"
+
+	// RequirementsFileName is the name of the file in a Helm chart that
+	// contains the dependencies of apiVersion v1 charts.
+	RequirementsFileName = "requirements.yaml"
+
+	tagsField   = "tags"
+	typeBoolean = "boolean"
+
+	errFmtReadDependencies = "cannot read the dependencies in %s"
+)
+
+// DependenciesOf returns the dependencies of the Helm chart in the given
+// directory that are declared either in its Chart.yaml or requirements.yaml.
+func DependenciesOf(chartDir string) ([]*chart.Dependency, error) {
+	var deps []*chart.Dependency
+	for _, name := range []string{ChartFileName, RequirementsFileName} {
+		data, err := ioutil.ReadFile(filepath.Clean(filepath.Join(chartDir, name)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtReadDependencies, name)
+		}
+		f := struct {
+			Dependencies []*chart.Dependency `json:"dependencies"`
+		}{}
+		if err := sigsyaml.Unmarshal(data, &f); err != nil {
+			return nil, errors.Wrapf(err, errFmtReadDependencies, name)
+		}
+		deps = append(deps, f.Dependencies...)
+	}
+	return deps, nil
+}
+
+// WithToggles adds a boolean field to the given spec schema for every
+// condition and tag of the given dependencies that the schema does not
+// declare yet, so that the subcharts can be enabled or disabled from the
+// parent resources without the toggles being pruned. The conditions are
+// paths relative to spec and the tags are the fields of spec.tags, just as
+// they are relative to the values of the chart.
+func WithToggles(spec *apiextensionsv1.JSONSchemaProps, deps []*chart.Dependency) *apiextensionsv1.JSONSchemaProps {
+	if spec == nil {
+		return nil
+	}
+	for _, d := range deps {
+		if d == nil {
+			continue
+		}
+		for _, c := range strings.Split(d.Condition, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				*spec = withBoolean(*spec, strings.Split(c, "."))
+			}
+		}
+		for _, t := range d.Tags {
+			*spec = withBoolean(*spec, []string{tagsField, t})
+		}
+	}
+	return spec
+}
+
+// withBoolean returns the given schema with a boolean field at the given
+// path, creating the objects along the path as needed. The schema is
+// returned as is if the path already exists or crosses a field that is not
+// an object.
+func withBoolean(s apiextensionsv1.JSONSchemaProps, path []string) apiextensionsv1.JSONSchemaProps {
+	return withLeaf(s, path, apiextensionsv1.JSONSchemaProps{Type: typeBoolean})
+}
+
+// withLeaf returns the given schema with the given leaf schema at the given
+// path, creating the objects along the path as needed. The schema is
+// returned as is if the path already exists or crosses a field that is not
+// an object.
+func withLeaf(s apiextensionsv1.JSONSchemaProps, path []string, leaf apiextensionsv1.JSONSchemaProps) apiextensionsv1.JSONSchemaProps {
+	if s.Type != typeObject || len(path) == 0 {
+		return s
+	}
+	if s.Properties == nil {
+		s.Properties = map[string]apiextensionsv1.JSONSchemaProps{}
+	}
+	child, ok := s.Properties[path[0]]
+	switch {
+	case len(path) == 1 && ok:
+		return s
+	case len(path) == 1:
+		child = leaf
+	case !ok:
+		child = withLeaf(newObject(), path[1:], leaf)
+	default:
+		child = withLeaf(child, path[1:], leaf)
+	}
+	s.Properties[path[0]] = child
+	return s
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"helm.sh/helm/v3/pkg/chart"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestDependenciesOf(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toggles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		ChartFileName:        "apiVersion: v2\nname: app\ndependencies:\n- name: postgresql\n  condition: postgresql.enabled\n",
+		RequirementsFileName: "dependencies:\n- name: redis\n  tags: [cache]\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := DependenciesOf(dir)
+	if err != nil {
+		t.Fatalf("DependenciesOf(...): %s", err)
+	}
+	want := []*chart.Dependency{
+		{Name: "postgresql", Condition: "postgresql.enabled"},
+		{Name: "redis", Tags: []string{"cache"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DependenciesOf(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestWithToggles(t *testing.T) {
+	boolean := apiextensionsv1.JSONSchemaProps{Type: typeBoolean}
+	cases := map[string]struct {
+		reason string
+		spec   *apiextensionsv1.JSONSchemaProps
+		deps   []*chart.Dependency
+		want   *apiextensionsv1.JSONSchemaProps
+	}{
+		"ConditionsAndTags": {
+			reason: "A boolean field should be added for every condition and tag of the dependencies.",
+			spec: &apiextensionsv1.JSONSchemaProps{
+				Type: typeObject,
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"postgresql": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"storage": {Type: "string"},
+					}},
+				},
+			},
+			deps: []*chart.Dependency{
+				{Name: "postgresql", Condition: "postgresql.enabled, global.postgresql.enabled"},
+				{Name: "redis", Tags: []string{"cache", "storage"}},
+			},
+			want: &apiextensionsv1.JSONSchemaProps{
+				Type: typeObject,
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"postgresql": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"storage": {Type: "string"},
+						"enabled": boolean,
+					}},
+					"global": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"postgresql": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"enabled": boolean,
+						}},
+					}},
+					tagsField: {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"cache":   boolean,
+						"storage": boolean,
+					}},
+				},
+			},
+		},
+		"AlreadyDeclared": {
+			reason: "The fields that are already declared in the schema should not be changed.",
+			spec: &apiextensionsv1.JSONSchemaProps{
+				Type: typeObject,
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"postgresql": {Type: "string"},
+					"redis": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"enabled": {Type: "string"},
+					}},
+				},
+			},
+			deps: []*chart.Dependency{
+				{Name: "postgresql", Condition: "postgresql.enabled"},
+				{Name: "redis", Condition: "redis.enabled"},
+			},
+			want: &apiextensionsv1.JSONSchemaProps{
+				Type: typeObject,
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"postgresql": {Type: "string"},
+					"redis": {Type: typeObject, Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"enabled": {Type: "string"},
+					}},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := WithToggles(tc.spec, tc.deps)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nWithToggles(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	typeArray  = "array"
+	typeNumber = "number"
+
+	errFmtType      = "must be of type %s"
+	errIntOrString  = "must be an integer or a string"
+	errNull         = "must not be null"
+	errUnknownField = "unknown field"
+)
+
+// Validate returns the errors of the given object against the given
+// structural schema, e.g. the schema of a CustomResourceDefinition. It checks
+// the types, the enums, the required fields and the unknown fields, which
+// covers the mistakes that templates are likely to make. The apiVersion, kind
+// and metadata of the object and its embedded resources are not checked.
+func Validate(obj map[string]interface{}, s *apiextensionsv1.JSONSchemaProps) field.ErrorList {
+	return validate(obj, s, nil, true)
+}
+
+func validate(val interface{}, s *apiextensionsv1.JSONSchemaProps, path *field.Path, resource bool) field.ErrorList { // nolint:gocyclo
+	if s == nil {
+		return nil
+	}
+	if val == nil {
+		if s.Nullable {
+			return nil
+		}
+		return field.ErrorList{field.Invalid(path, val, errNull)}
+	}
+	if msg, ok := hasType(val, s); !ok {
+		return field.ErrorList{field.Invalid(path, val, msg)}
+	}
+	var errs field.ErrorList
+	if len(s.Enum) != 0 {
+		if allowed, ok := inEnum(val, s.Enum); !ok {
+			errs = append(errs, field.NotSupported(path, val, allowed))
+		}
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				errs = append(errs, field.Required(path.Child(r), ""))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		embedded := resource || s.XEmbeddedResource
+		preserve := s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+		for _, k := range keys {
+			if embedded && (k == "apiVersion" || k == "kind" || k == "metadata") {
+				continue
+			}
+			if sub, ok := s.Properties[k]; ok {
+				errs = append(errs, validate(v[k], &sub, path.Child(k), sub.XEmbeddedResource)...)
+				continue
+			}
+			if ap := s.AdditionalProperties; ap != nil && (ap.Allows || ap.Schema != nil) {
+				errs = append(errs, validate(v[k], ap.Schema, path.Child(k), false)...)
+				continue
+			}
+			if !preserve {
+				errs = append(errs, field.Forbidden(path.Child(k), errUnknownField))
+			}
+		}
+	case []interface{}:
+		if s.Items == nil {
+			break
+		}
+		items := s.Items.Schema
+		for i, e := range v {
+			errs = append(errs, validate(e, items, path.Index(i), items != nil && items.XEmbeddedResource)...)
+		}
+	}
+	return errs
+}
+
+// hasType returns true if the given value has the type of the given schema.
+// Otherwise, it returns the message of the error.
+func hasType(val interface{}, s *apiextensionsv1.JSONSchemaProps) (string, bool) {
+	if s.XIntOrString {
+		return errIntOrString, isInteger(val) || isString(val)
+	}
+	ok := true
+	switch s.Type {
+	case typeObject:
+		_, ok = val.(map[string]interface{})
+	case typeArray:
+		_, ok = val.([]interface{})
+	case typeString:
+		ok = isString(val)
+	case typeInteger:
+		ok = isInteger(val)
+	case typeNumber:
+		switch val.(type) {
+		case int64, int32, int, float64:
+		default:
+			ok = false
+		}
+	case typeBoolean:
+		_, ok = val.(bool)
+	}
+	return fmt.Sprintf(errFmtType, s.Type), ok
+}
+
+func isString(val interface{}) bool {
+	_, ok := val.(string)
+	return ok
+}
+
+func isInteger(val interface{}) bool {
+	switch v := val.(type) {
+	case int64, int32, int:
+		return true
+	case float64:
+		return v == math.Trunc(v)
+	}
+	return false
+}
+
+// inEnum returns true if the given value is one of the given values of an
+// enum. Otherwise, it returns the allowed values.
+func inEnum(val interface{}, enum []apiextensionsv1.JSON) ([]string, bool) {
+	allowed := make([]string, 0, len(enum))
+	for _, e := range enum {
+		var v interface{}
+		if err := json.Unmarshal(e.Raw, &v); err != nil {
+			continue
+		}
+		if reflect.DeepEqual(normalize(val), v) {
+			return nil, true
+		}
+		allowed = append(allowed, string(e.Raw))
+	}
+	return allowed, false
+}
+
+// normalize returns the given value with the integers as float64, like the
+// values that are decoded from JSON.
+func normalize(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int:
+		return float64(v)
+	}
+	return val
+}
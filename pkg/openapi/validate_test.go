@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openapi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidate(t *testing.T) {
+	preserve := true
+	s := &apiextensionsv1.JSONSchemaProps{
+		Type: typeObject,
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"spec": {
+				Type:     typeObject,
+				Required: []string{"engine"},
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"engine": {Type: typeString, Enum: []apiextensionsv1.JSON{{Raw: []byte(`"postgres"`)}, {Raw: []byte(`"mysql"`)}}},
+					"size":   {Type: typeInteger},
+					"port":   {XIntOrString: true},
+					"tags":   {Type: typeArray, Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{Type: typeString}}},
+					"extra":  {Type: typeObject, XPreserveUnknownFields: &preserve},
+					"labels": {Type: typeObject, AdditionalProperties: &apiextensionsv1.JSONSchemaPropsOrBool{Schema: &apiextensionsv1.JSONSchemaProps{Type: typeString}}},
+				},
+			},
+		},
+	}
+	cases := map[string]struct {
+		reason string
+		obj    map[string]interface{}
+		want   field.ErrorList
+	}{
+		"Valid": {
+			reason: "An object that conforms to the schema should have no errors.",
+			obj: map[string]interface{}{
+				"apiVersion": "database.example.org/v1alpha1",
+				"kind":       "Database",
+				"metadata":   map[string]interface{}{"name": "db"},
+				"spec": map[string]interface{}{
+					"engine": "postgres",
+					"size":   float64(20),
+					"port":   "http",
+					"tags":   []interface{}{"a"},
+					"extra":  map[string]interface{}{"any": true},
+					"labels": map[string]interface{}{"team": "a"},
+				},
+			},
+		},
+		"Invalid": {
+			reason: "The field-level errors of the object should be returned.",
+			obj: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"size":    float64(1.5),
+					"port":    true,
+					"tags":    []interface{}{int64(1)},
+					"labels":  map[string]interface{}{"team": nil},
+					"storage": "10Gi",
+				},
+			},
+			want: field.ErrorList{
+				field.Required(field.NewPath("spec", "engine"), ""),
+				field.Invalid(field.NewPath("spec", "labels", "team"), nil, errNull),
+				field.Invalid(field.NewPath("spec", "port"), true, errIntOrString),
+				field.Invalid(field.NewPath("spec", "size"), float64(1.5), "must be of type integer"),
+				field.Forbidden(field.NewPath("spec", "storage"), errUnknownField),
+				field.Invalid(field.NewPath("spec", "tags").Index(0), int64(1), "must be of type string"),
+			},
+		},
+		"NotInEnum": {
+			reason: "A value that is not in the enum should be rejected with the allowed values.",
+			obj:    map[string]interface{}{"spec": map[string]interface{}{"engine": "oracle"}},
+			want: field.ErrorList{
+				field.NotSupported(field.NewPath("spec", "engine"), "oracle", []string{`"postgres"`, `"mysql"`}),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Validate(tc.obj, s)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nValidate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cue
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cuelang "cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	cueerrors "cuelang.org/go/cue/errors"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	defaultRootPath = "resources"
+
+	// FileExtension is the extension of the CUE files in the resource path.
+	// The other files are ignored.
+	FileExtension = ".cue"
+
+	// ParentField is the field that the parent resource is unified with.
+	// The definitions can constrain its spec, e.g. parent: spec: replicas: >0.
+	ParentField = "parent"
+
+	// ResourcesField is the field that the child resources are read from. It
+	// is either a list of manifests or a struct whose fields are manifests.
+	ResourcesField = "resources"
+
+	errSpecCast        = "parent resource spec could not be casted into a map[string]interface{}"
+	errReadFiles       = "cannot read the CUE files"
+	errAddFile         = "cannot add the CUE file"
+	errBuild           = "cannot build the CUE definitions"
+	errFillParent      = "cannot unify the parent resource with the CUE definitions"
+	errNoResources     = "the CUE definitions have no " + ResourcesField + " field"
+	errNotConcrete     = "the child resources are not concrete"
+	errFmtResourceKind = "the " + ResourcesField + " field is neither a list nor a struct but %s"
+	errIterate         = "cannot iterate the child resources"
+	errMarshal         = "cannot marshal a child resource"
+	errUnmarshal       = "cannot convert a child resource to an object"
+)
+
+// WithResourcePath returns an Option that changes the resource path of the Engine.
+func WithResourcePath(path string) Option {
+	return func(e *Engine) {
+		e.ResourcePath = path
+	}
+}
+
+// NewCUEEngine returns a new CUE Engine to be used as templating.Engine.
+func NewCUEEngine(o ...Option) *Engine {
+	e := &Engine{
+		ResourcePath: defaultRootPath,
+	}
+	for _, f := range o {
+		f(e)
+	}
+	return e
+}
+
+// Engine unifies the parent resource with the CUE definitions in its
+// resource path and emits the concrete values of the resources field as the
+// child resources. All CUE files in the resource path, excluding its
+// subfolders, have to belong to the same package. The parent resource is
+// unified with the parent field, so the definitions can both use and
+// constrain it. The values of the parent resource that violate the
+// constraints result in a resource.ValuesError with the path of the field.
+type Engine struct {
+	// ResourcePath is the folder that the CUE files reside in the
+	// filesystem.
+	ResourcePath string
+}
+
+// Run returns the result of the templating operation.
+func (e *Engine) Run(_ context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if _, err := e.Values(cr); err != nil {
+		return resource.RenderResult{}, err
+	}
+	inst, err := e.build()
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	inst, err = inst.Fill(cr.UnstructuredContent(), ParentField)
+	if err != nil {
+		return resource.RenderResult{}, errors.Wrap(err, errFillParent)
+	}
+	if err := inst.Value().Validate(); err != nil {
+		return resource.RenderResult{}, cueError(err)
+	}
+	v := inst.Lookup(ResourcesField)
+	if !v.Exists() {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.New(errNoResources)}
+	}
+	if err := v.Validate(cuelang.Concrete(true)); err != nil {
+		return resource.RenderResult{}, cueError(errors.Wrap(err, errNotConcrete))
+	}
+	list, err := children(v)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	return resource.RenderResult{Children: list}, nil
+}
+
+// Values returns the spec of the given parent resource, which is the part of
+// the parent resource that the definitions are expected to read.
+func (e *Engine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	spec, exists := cr.UnstructuredContent()["spec"]
+	if !exists {
+		return map[string]interface{}{}, nil
+	}
+	values, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}
+	}
+	return values, nil
+}
+
+// build returns the instance of the CUE files in the resource path. The
+// files are named by their base names in the errors.
+func (e *Engine) build() (*cuelang.Instance, error) {
+	infos, err := ioutil.ReadDir(e.ResourcePath)
+	if err != nil {
+		return nil, errors.Wrap(err, errReadFiles)
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == FileExtension {
+			files = append(files, info.Name())
+		}
+	}
+	sort.Strings(files)
+	bi := build.NewContext().NewInstance(e.ResourcePath, nil)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(e.ResourcePath, f))
+		if err != nil {
+			return nil, errors.Wrap(err, errReadFiles)
+		}
+		if err := bi.AddFile(f, data); err != nil {
+			return nil, cueError(errors.Wrap(err, errAddFile))
+		}
+	}
+	var r cuelang.Runtime
+	inst, err := r.Build(bi)
+	if err != nil {
+		return nil, cueError(errors.Wrap(err, errBuild))
+	}
+	return inst, nil
+}
+
+// children returns the child resources in the given list or struct, in the
+// order they are declared.
+func children(v cuelang.Value) ([]resource.ChildResource, error) {
+	var it *cuelang.Iterator
+	var err error
+	switch k := v.Kind(); k {
+	case cuelang.ListKind:
+		var list cuelang.Iterator
+		list, err = v.List()
+		it = &list
+	case cuelang.StructKind:
+		it, err = v.Fields()
+	default:
+		return nil, &resource.RenderError{Err: errors.Errorf(errFmtResourceKind, k)}
+	}
+	if err != nil {
+		return nil, &resource.RenderError{Err: errors.Wrap(err, errIterate)}
+	}
+	var result []resource.ChildResource
+	for it.Next() {
+		data, err := it.Value().MarshalJSON()
+		if err != nil {
+			return nil, cueError(errors.Wrap(err, errMarshal))
+		}
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return nil, &resource.RenderError{Err: errors.Wrap(err, errUnmarshal)}
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// cueError returns a resource.ValuesError if any of the errors that CUE
+// reports is in the parent field. Otherwise, it returns a
+// resource.RenderError with the file and line of the first error, if known.
+func cueError(err error) error {
+	// NOTE: The errors of CUE implement Cause themselves, so errors.Cause
+	// would unwrap past their positions.
+	var list []cueerrors.Error
+	var ce cueerrors.Error
+	if errors.As(err, &ce) {
+		list = cueerrors.Errors(ce)
+	}
+	for _, ce := range list {
+		if p := ce.Path(); len(p) > 1 && p[0] == ParentField {
+			return &resource.ValuesError{Path: strings.Join(p[1:], "."), Err: err}
+		}
+	}
+	re := &resource.RenderError{Err: err}
+	if len(list) == 0 {
+		return re
+	}
+	if pos := list[0].Position(); pos.Line() > 0 {
+		re.File = filepath.ToSlash(pos.Filename())
+		re.Line = pos.Line()
+	}
+	return re
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/fixture"
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+const testYAMLDir = "../../../test/cue"
+
+func TestRun(t *testing.T) {
+	parent := func(spec interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "cool"},
+			"spec":     spec,
+		}}
+	}
+	definitions := `package test
+
+parent: spec: replicas: int & >0
+
+resources: {
+	config: {
+		apiVersion: "v1"
+		kind:       "ConfigMap"
+		metadata: name: parent.metadata.name
+		data: replicas: "\(parent.spec.replicas)"
+	}
+	secret: {
+		apiVersion: "v1"
+		kind:       "Secret"
+		metadata: name: parent.metadata.name
+	}
+}
+`
+	type want struct {
+		result []resource.ChildResource
+		err    error
+		values *resource.ValuesError
+		render *resource.RenderError
+	}
+	cases := map[string]struct {
+		files map[string]string
+		cr    resource.ParentResource
+		want  want
+	}{
+		"SpecNotMap": {
+			cr:   parent("olala"),
+			want: want{err: &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}},
+		},
+		"Success": {
+			files: map[string]string{
+				"resources.cue": definitions,
+				"ignored.yaml":  "apiVersion: v1\nkind: Namespace\n",
+			},
+			cr: parent(map[string]interface{}{"replicas": int64(3)}),
+			want: want{result: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "cool"},
+					"data":       map[string]interface{}{"replicas": "3"},
+				}},
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata":   map[string]interface{}{"name": "cool"},
+				}},
+			}},
+		},
+		"InvalidSpec": {
+			files: map[string]string{"resources.cue": definitions},
+			cr:    parent(map[string]interface{}{"replicas": int64(0)}),
+			want:  want{values: &resource.ValuesError{Path: "spec.replicas"}},
+		},
+		"NoResources": {
+			files: map[string]string{"resources.cue": "package test\n\nobjects: []\n"},
+			cr:    parent(map[string]interface{}{}),
+			want:  want{render: &resource.RenderError{}},
+		},
+		"SyntaxError": {
+			files: map[string]string{"broken.cue": "package test\n\nresources: [}\n"},
+			cr:    parent(map[string]interface{}{}),
+			want:  want{render: &resource.RenderError{File: "broken.cue", Line: 3}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cue")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			for f, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := NewCUEEngine(WithResourcePath(dir)).Run(context.Background(), tc.cr)
+			switch {
+			case tc.want.values != nil:
+				ve := &resource.ValuesError{}
+				if !errors.As(err, &ve) {
+					t.Fatalf("Run(...): want *resource.ValuesError, got %v", err)
+				}
+				if diff := cmp.Diff(tc.want.values, ve, cmpopts.IgnoreFields(resource.ValuesError{}, "Err")); diff != "" {
+					t.Errorf("Run(...): -want, +got:\n%s", diff)
+				}
+			case tc.want.render != nil:
+				re := &resource.RenderError{}
+				if !errors.As(err, &re) {
+					t.Fatalf("Run(...): want *resource.RenderError, got %v", err)
+				}
+				if diff := cmp.Diff(tc.want.render, re, cmpopts.IgnoreFields(resource.RenderError{}, "Err")); diff != "" {
+					t.Errorf("Run(...): -want, +got:\n%s", diff)
+				}
+			default:
+				if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+					t.Errorf("Run(...): -want error, +got error:\n%s", diff)
+				}
+			}
+			if diff := cmp.Diff(tc.want.result, got.Children); diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	cases, err := fixture.Load(filepath.Join(testYAMLDir, "tests"))
+	if err != nil {
+		t.Fatalf("fixture.Load(...): %v", err)
+	}
+	r := fixture.NewRunner(func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+		return NewCUEEngine(WithResourcePath(filepath.Join(testYAMLDir, "resources"))), nil
+	})
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			res := r.Run(c)
+			if res.Err != nil {
+				t.Fatalf("Run(...): %v", res.Err)
+			}
+			if res.Diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", res.Diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	defaultRootPath = "resources"
+	defaultCommand  = "render"
+	defaultTimeout  = 30 * time.Second
+
+	// CommandAnnotationKey is the annotation on the StackDefinition whose
+	// value is the path of the executable that renders the child resources.
+	// Relative paths are relative to the resource path.
+	CommandAnnotationKey = "templatestacks.crossplane.io/external-command"
+
+	// ValuesErrorExitCode is the exit code that the executable returns when
+	// the parent resource has invalid values. The first line of its standard
+	// error is then expected to be the path of the offending field, followed
+	// by a colon and the reason, e.g. spec.replicas: must be positive.
+	ValuesErrorExitCode = 2
+
+	// WarningPrefix is the prefix of the lines of the standard error of the
+	// executable that are reported as the warnings of a successful
+	// rendering, e.g. warning: spec.size is deprecated, use spec.storage.
+	WarningPrefix = "warning:"
+
+	// MaxErrorOutputLength is the maximum length of the standard error of the
+	// executable that is included in the errors.
+	MaxErrorOutputLength = 1024
+
+	errSpecCast    = "parent resource spec could not be casted into a map[string]interface{}"
+	errMarshal     = "cannot marshal the parent resource"
+	errRun         = "cannot run the external command"
+	errFmtTimeout  = "the external command did not finish in %s"
+	errFmtExitCode = "the external command exited with code %d"
+	errParse       = "could not parse the output of the external command"
+)
+
+// WithResourcePath returns an Option that changes the resource path of the
+// Engine.
+func WithResourcePath(path string) Option {
+	return func(e *Engine) {
+		e.ResourcePath = path
+	}
+}
+
+// WithCommand returns an Option that changes the executable of the Engine.
+func WithCommand(cmd string) Option {
+	return func(e *Engine) {
+		e.Command = cmd
+	}
+}
+
+// WithTimeout returns an Option that changes how long the Engine waits for
+// the executable to finish.
+func WithTimeout(t time.Duration) Option {
+	return func(e *Engine) {
+		e.Timeout = t
+	}
+}
+
+// NewExternalEngine returns a new external Engine to be used as
+// templating.Engine.
+func NewExternalEngine(o ...Option) *Engine {
+	e := &Engine{
+		ResourcePath: defaultRootPath,
+		Command:      defaultCommand,
+		Timeout:      defaultTimeout,
+	}
+	for _, f := range o {
+		f(e)
+	}
+	return e
+}
+
+// Engine renders the child resources by running an executable that is
+// provided by the stack, so that any templating tool can be used without
+// changing the controller. The executable is run in the resource path with
+// the parent resource as JSON on its standard input, and it's expected to
+// write the child resources as YAML or JSON documents to its standard output
+// and exit with code 0. Any other exit code fails the rendering with its
+// standard error as the message. See ValuesErrorExitCode for reporting the
+// invalid values of the parent resource.
+type Engine struct {
+	// ResourcePath is the folder that the executable is run in.
+	ResourcePath string
+
+	// Command is the path of the executable. It's relative to the resource
+	// path unless it's absolute.
+	Command string
+
+	// Timeout is how long the executable is allowed to run.
+	Timeout time.Duration
+}
+
+// Run returns the result of the templating operation.
+func (e *Engine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if _, err := e.Values(cr); err != nil {
+		return resource.RenderResult{}, err
+	}
+	in, err := json.Marshal(cr)
+	if err != nil {
+		return resource.RenderResult{}, errors.Wrap(err, errMarshal)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, e.command()) // nolint:gosec
+	cmd.Dir = e.ResourcePath
+	cmd.Stdin = bytes.NewReader(in)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err = cmd.Run()
+	// NOTE: The executable is killed when the reconciliation is cancelled
+	// too, which is not a problem of the executable.
+	if ctx.Err() != nil {
+		return resource.RenderResult{}, ctx.Err()
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Errorf(errFmtTimeout, e.Timeout)}
+	}
+	if err != nil {
+		return resource.RenderResult{}, exitError(err, stderr.String())
+	}
+	result, err := resource.ParseUnstructured(stdout.Bytes())
+	if err != nil {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Wrap(err, errParse)}
+	}
+	list := make([]resource.ChildResource, len(result))
+	for i, u := range result {
+		list[i] = u
+	}
+	return resource.RenderResult{Children: list, Warnings: warnings(stderr.String())}, nil
+}
+
+// warnings returns the lines of the given standard error of the executable
+// that start with WarningPrefix, without the prefix.
+func warnings(stderr string) []string {
+	var result []string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, WarningPrefix) {
+			continue
+		}
+		if w := strings.TrimSpace(strings.TrimPrefix(line, WarningPrefix)); w != "" {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+// Values returns the spec of the given parent resource, which is the part of
+// the parent resource that the executable is expected to read.
+func (e *Engine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	spec, exists := cr.UnstructuredContent()["spec"]
+	if !exists {
+		return map[string]interface{}{}, nil
+	}
+	values, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}
+	}
+	return values, nil
+}
+
+func (e *Engine) command() string {
+	if filepath.IsAbs(e.Command) {
+		return e.Command
+	}
+	// NOTE: A relative path without a separator would be looked up in PATH.
+	return "." + string(filepath.Separator) + filepath.Clean(e.Command)
+}
+
+// exitError returns a resource.ValuesError if the executable exited with
+// ValuesErrorExitCode and a resource.RenderError otherwise.
+func exitError(err error, stderr string) error {
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return &resource.RenderError{Err: errors.Wrap(err, errRun)}
+	}
+	msg := strings.TrimSpace(stderr)
+	if len(msg) > MaxErrorOutputLength {
+		msg = msg[:MaxErrorOutputLength]
+	}
+	if ee.ExitCode() == ValuesErrorExitCode {
+		line := strings.SplitN(msg, "\n", 2)[0]
+		if i := strings.Index(line, ":"); i > 0 {
+			return &resource.ValuesError{Path: strings.TrimSpace(line[:i]), Err: errors.New(strings.TrimSpace(line[i+1:]))}
+		}
+	}
+	if msg == "" {
+		return &resource.RenderError{Err: errors.Errorf(errFmtExitCode, ee.ExitCode())}
+	}
+	return &resource.RenderError{Err: errors.Wrapf(errors.New(msg), errFmtExitCode, ee.ExitCode())}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package external
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestRun(t *testing.T) {
+	parent := func(spec interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "cool"},
+			"spec":     spec,
+		}}
+	}
+	type want struct {
+		result resource.RenderResult
+		err    error
+	}
+	cases := map[string]struct {
+		reason  string
+		script  string
+		timeout time.Duration
+		cr      resource.ParentResource
+		want    want
+	}{
+		"SpecNotMap": {
+			reason: "A spec that is not an object should not be sent to the executable.",
+			cr:     parent("olala"),
+			want:   want{err: &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}},
+		},
+		"Success": {
+			reason: "The documents that the executable writes to its standard output should be returned as child resources.",
+			script: "#!/bin/sh\ngrep -q '\"name\":\"cool\"' || exit 1\nprintf 'apiVersion: v1\\nkind: ConfigMap\\nmetadata:\\n  name: cool\\n---\\n{\"apiVersion\": \"v1\", \"kind\": \"Secret\"}\\n'\n",
+			cr:     parent(map[string]interface{}{}),
+			want: want{result: resource.RenderResult{Children: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "cool"},
+				}},
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+				}},
+			}}},
+		},
+		"Warnings": {
+			reason: "The lines of the standard error that start with WarningPrefix should be returned as warnings.",
+			script: "#!/bin/sh\necho 'rendering cool' >&2\necho 'warning: spec.size is deprecated' >&2\nprintf '{\"apiVersion\": \"v1\", \"kind\": \"Secret\"}\\n'\n",
+			cr:     parent(map[string]interface{}{}),
+			want: want{result: resource.RenderResult{
+				Children: []resource.ChildResource{
+					&unstructured.Unstructured{Object: map[string]interface{}{
+						"apiVersion": "v1",
+						"kind":       "Secret",
+					}},
+				},
+				Warnings: []string{"spec.size is deprecated"},
+			}},
+		},
+		"Failed": {
+			reason: "A non-zero exit code should be returned as a RenderError with the standard error.",
+			script: "#!/bin/sh\necho 'template not found' >&2\nexit 1\n",
+			cr:     parent(map[string]interface{}{}),
+			want:   want{err: &resource.RenderError{Err: errors.Wrapf(errors.New("template not found"), errFmtExitCode, 1)}},
+		},
+		"ValuesError": {
+			reason: "ValuesErrorExitCode should be returned as a ValuesError with the path in the standard error.",
+			script: "#!/bin/sh\necho 'spec.replicas: must be positive' >&2\nexit 2\n",
+			cr:     parent(map[string]interface{}{}),
+			want:   want{err: &resource.ValuesError{Path: "spec.replicas", Err: errors.New("must be positive")}},
+		},
+		"TimedOut": {
+			reason:  "An executable that does not finish in time should be killed.",
+			script:  "#!/bin/sh\nexec sleep 10\n",
+			timeout: 100 * time.Millisecond,
+			cr:      parent(map[string]interface{}{}),
+			want:    want{err: &resource.RenderError{Err: errors.Errorf(errFmtTimeout, 100*time.Millisecond)}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "external")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			if err := ioutil.WriteFile(filepath.Join(dir, defaultCommand), []byte(tc.script), 0700); err != nil {
+				t.Fatal(err)
+			}
+			opts := []Option{WithResourcePath(dir)}
+			if tc.timeout != 0 {
+				opts = append(opts, WithTimeout(tc.timeout))
+			}
+			got, err := NewExternalEngine(opts...).Run(context.Background(), tc.cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gotemplate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	defaultRootPath = "resources"
+
+	// TemplateExtension is the extension of the template files in the
+	// resource path. The other files are ignored.
+	TemplateExtension = ".tmpl"
+
+	// HelperPrefix is the prefix of the names of the template files that
+	// only define named templates. They are parsed but not rendered.
+	HelperPrefix = "_"
+
+	errSpecCast      = "parent resource spec could not be casted into a map[string]interface{}"
+	errReadTemplates = "cannot read the template files"
+	errParseTemplate = "cannot parse the template file"
+	errExecTemplate  = "cannot execute the template file"
+	errParse         = "could not parse the generated YAMLs"
+)
+
+// WithResourcePath returns an Option that changes the resource path of the Engine.
+func WithResourcePath(path string) Option {
+	return func(e *Engine) {
+		e.ResourcePath = path
+	}
+}
+
+// WithLookup returns an Option that makes the lookup function of the
+// templates read the existing objects in the cluster with the given function.
+func WithLookup(fn LookupFunc) Option {
+	return func(e *Engine) {
+		e.Lookup = fn
+	}
+}
+
+// NewGoTemplateEngine returns a new Go template Engine to be used as
+// templating.Engine.
+func NewGoTemplateEngine(o ...Option) *Engine {
+	e := &Engine{
+		ResourcePath: defaultRootPath,
+	}
+	for _, f := range o {
+		f(e)
+	}
+	return e
+}
+
+// Engine renders the Go text/template files in its resource path with the
+// spec of the parent resource as data. The files are rendered in the
+// lexical order of their paths and their output is concatenated, so a file
+// can contain multiple YAML documents. In addition to the built-in functions,
+// the templates can use the following:
+//
+//	parent         returns the whole parent resource, e.g. (parent).metadata.name
+//	toYaml         marshals the given value into YAML
+//	indent         indents every line of the given string by the given number of spaces
+//	quote          quotes the given value as a string
+//	default        returns the first argument if the second one is empty
+//	lookup         returns an existing object in the cluster like the lookup function of Helm
+type Engine struct {
+	// ResourcePath is the folder that the template files reside in the
+	// filesystem, including its subfolders.
+	ResourcePath string
+
+	// Lookup is used by the lookup function of the templates to read the
+	// existing objects in the cluster. If nil, lookup returns an empty
+	// object as in `helm template`.
+	Lookup LookupFunc
+}
+
+// A LookupFunc returns the object of the given kind with the given name in
+// the given namespace, or the list of the objects of that kind in that
+// namespace if the name is empty.
+type LookupFunc func(apiVersion, kind, namespace, name string) (map[string]interface{}, error)
+
+// Run returns the result of the templating operation.
+func (e *Engine) Run(_ context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	values, err := e.Values(cr)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	files, err := e.files()
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	funcs := Funcs(cr)
+	funcs["lookup"] = e.lookup
+	tmpl := template.New("").Funcs(funcs)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(e.ResourcePath, filepath.FromSlash(f)))
+		if err != nil {
+			return resource.RenderResult{}, errors.Wrap(err, errReadTemplates)
+		}
+		if _, err := tmpl.New(f).Parse(string(data)); err != nil {
+			return resource.RenderResult{}, renderError(errors.Wrap(err, errParseTemplate))
+		}
+	}
+	buf := &bytes.Buffer{}
+	for _, f := range files {
+		if strings.HasPrefix(filepath.Base(f), HelperPrefix) {
+			continue
+		}
+		// NOTE: The documents of consecutive files are separated even if the
+		// output of a file does not end with a new line.
+		buf.WriteString("\n---\n")
+		if err := tmpl.ExecuteTemplate(buf, f, values); err != nil {
+			return resource.RenderResult{}, renderError(errors.Wrap(err, errExecTemplate))
+		}
+	}
+	result, err := resource.ParseUnstructured(buf.Bytes())
+	if err != nil {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Wrap(err, errParse)}
+	}
+	list := make([]resource.ChildResource, len(result))
+	for i, u := range result {
+		list[i] = u
+	}
+	return resource.RenderResult{Children: list}, nil
+}
+
+// Values returns the spec of the given parent resource, which is the data
+// that the templates are executed with.
+func (e *Engine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	spec, exists := cr.UnstructuredContent()["spec"]
+	if !exists {
+		return map[string]interface{}{}, nil
+	}
+	values, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}
+	}
+	return values, nil
+}
+
+// files returns the paths of the template files relative to the resource
+// path, with forward slashes, in lexical order.
+func (e *Engine) files() ([]string, error) {
+	var files []string
+	err := filepath.Walk(e.ResourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != TemplateExtension {
+			return nil
+		}
+		rel, err := filepath.Rel(e.ResourcePath, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errReadTemplates)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (e *Engine) lookup(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+	if e.Lookup == nil {
+		return map[string]interface{}{}, nil
+	}
+	return e.Lookup(apiVersion, kind, namespace, name)
+}
+
+// Funcs returns the functions that the templates can use in addition to the
+// built-in ones, with the given parent resource returned by parent.
+func Funcs(cr resource.ParentResource) template.FuncMap {
+	return template.FuncMap{
+		"parent": cr.UnstructuredContent,
+		"toYaml": func(v interface{}) (string, error) {
+			data, err := sigsyaml.Marshal(v)
+			return strings.TrimSuffix(string(data), "\n"), err
+		},
+		"indent": func(n int, s string) string {
+			pad := strings.Repeat(" ", n)
+			return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+		},
+		"quote": func(v interface{}) string {
+			if v == nil {
+				return `""`
+			}
+			return strconv.Quote(toString(v))
+		},
+		"default": func(d, v interface{}) interface{} {
+			if empty(v) {
+				return d
+			}
+			return v
+		},
+	}
+}
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := sigsyaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
+
+func empty(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case bool:
+		return !t
+	case int64:
+		return t == 0
+	case float64:
+		return t == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	}
+	return false
+}
+
+// templateLocation matches the template file and line in the errors of
+// text/template, e.g. "template: deployment.yaml.tmpl:12:3: ...".
+var templateLocation = regexp.MustCompile(`template: ([^:\s]+):(\d+)`)
+
+// renderError returns a resource.RenderError with the template file and line
+// that are reported in the given error, if any.
+func renderError(err error) error {
+	re := &resource.RenderError{Err: err}
+	m := templateLocation.FindStringSubmatch(err.Error())
+	if m == nil {
+		return re
+	}
+	re.File = m[1]
+	re.Line, _ = strconv.Atoi(m[2])
+	return re
+}
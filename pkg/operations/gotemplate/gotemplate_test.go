@@ -0,0 +1,187 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gotemplate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/fixture"
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+const testYAMLDir = "../../../test/gotemplate"
+
+func TestRun(t *testing.T) {
+	parent := func(spec interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "cool"},
+			"spec":     spec,
+		}}
+	}
+	type want struct {
+		result []resource.ChildResource
+		err    error
+		render *resource.RenderError
+	}
+	lookup := func(apiVersion, kind, namespace, name string) (map[string]interface{}, error) {
+		return map[string]interface{}{"data": map[string]interface{}{"password": apiVersion + "/" + kind + "/" + namespace + "/" + name}}, nil
+	}
+	cases := map[string]struct {
+		files map[string]string
+		o     []Option
+		cr    resource.ParentResource
+		want  want
+	}{
+		"SpecNotMap": {
+			cr:   parent("olala"),
+			want: want{err: &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}},
+		},
+		"Success": {
+			files: map[string]string{
+				"_helpers.tmpl":         `{{ define "labels" }}app: {{ (parent).metadata.name }}{{ end }}`,
+				"a/configmap.yaml.tmpl": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .name | default \"cm\" }}\n  labels:\n    {{ template \"labels\" }}\ndata:\n{{ toYaml .data | indent 2 }}",
+				"b/secrets.yaml.tmpl":   "{{ range .secrets }}---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: {{ . }}\n{{ end }}",
+				"c/ignored.yaml":        "apiVersion: v1\nkind: Namespace\n",
+			},
+			cr: parent(map[string]interface{}{
+				"data":    map[string]interface{}{"key": "value"},
+				"secrets": []interface{}{"one", "two"},
+			}),
+			want: want{result: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name":   "cm",
+						"labels": map[string]interface{}{"app": "cool"},
+					},
+					"data": map[string]interface{}{"key": "value"},
+				}},
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata":   map[string]interface{}{"name": "one"},
+				}},
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata":   map[string]interface{}{"name": "two"},
+				}},
+			}},
+		},
+		"ParseFailed": {
+			files: map[string]string{"broken.yaml.tmpl": "kind: Broken\n{{ if }}\n"},
+			cr:    parent(map[string]interface{}{}),
+			want:  want{render: &resource.RenderError{File: "broken.yaml.tmpl", Line: 2}},
+		},
+		"ExecFailed": {
+			files: map[string]string{"broken.yaml.tmpl": "kind: Broken\n\nname: {{ .name.first }}\n"},
+			cr:    parent(map[string]interface{}{"name": "cool"}),
+			want:  want{render: &resource.RenderError{File: "broken.yaml.tmpl", Line: 3}},
+		},
+		"Lookup": {
+			files: map[string]string{"secret.yaml.tmpl": "{{ $s := lookup \"v1\" \"Secret\" \"default\" \"admin\" }}apiVersion: v1\nkind: Secret\nmetadata:\n  name: {{ $s.data.password }}\n"},
+			o:     []Option{WithLookup(lookup)},
+			cr:    parent(map[string]interface{}{}),
+			want: want{result: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata":   map[string]interface{}{"name": "v1/Secret/default/admin"},
+				}},
+			}},
+		},
+		"LookupDisabled": {
+			files: map[string]string{"secret.yaml.tmpl": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: {{ (lookup \"v1\" \"Secret\" \"default\" \"admin\").data | default \"generated\" }}\n"},
+			cr:    parent(map[string]interface{}{}),
+			want: want{result: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "Secret",
+					"metadata":   map[string]interface{}{"name": "generated"},
+				}},
+			}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gotemplate")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			for f, content := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(f))
+				if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := NewGoTemplateEngine(append([]Option{WithResourcePath(dir)}, tc.o...)...).Run(context.Background(), tc.cr)
+			if tc.want.render != nil {
+				re := &resource.RenderError{}
+				if !errors.As(err, &re) {
+					t.Fatalf("Run(...): want *resource.RenderError, got %v", err)
+				}
+				if diff := cmp.Diff(tc.want.render, re, cmpopts.IgnoreFields(resource.RenderError{}, "Err")); diff != "" {
+					t.Errorf("Run(...): -want, +got:\n%s", diff)
+				}
+			} else if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Run(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got.Children); diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	cases, err := fixture.Load(filepath.Join(testYAMLDir, "tests"))
+	if err != nil {
+		t.Fatalf("fixture.Load(...): %v", err)
+	}
+	r := fixture.NewRunner(func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+		return NewGoTemplateEngine(WithResourcePath(filepath.Join(testYAMLDir, "resources"))), nil
+	})
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			res := r.Run(c)
+			if res.Err != nil {
+				t.Fatalf("Run(...): %v", res.Err)
+			}
+			if res.Diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", res.Diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/client-go/discovery"
+)
+
+const (
+	// KubeVersionAnnotationKey is the annotation on the StackDefinition whose
+	// value is the Kubernetes version that the charts are rendered for, e.g.
+	// "v1.18.2". It's available to the templates as
+	// .Capabilities.KubeVersion.
+	KubeVersionAnnotationKey = "templatestacks.crossplane.io/helm3-kube-version"
+
+	// APIVersionsAnnotationKey is the annotation on the StackDefinition whose
+	// value is a comma separated list of API versions, e.g.
+	// "monitoring.coreos.com/v1,monitoring.coreos.com/v1/ServiceMonitor",
+	// that are added to .Capabilities.APIVersions of the charts.
+	APIVersionsAnnotationKey = "templatestacks.crossplane.io/helm3-api-versions"
+
+	// DiscoverCapabilitiesAnnotationKey is the annotation on the
+	// StackDefinition that makes the charts see the Kubernetes version and
+	// the API versions of the cluster when its value is "true".
+	DiscoverCapabilitiesAnnotationKey = "templatestacks.crossplane.io/helm3-discover-capabilities"
+
+	// DefaultCapabilitiesTTL is how long the discovered capabilities of the
+	// cluster are used before they are discovered again.
+	DefaultCapabilitiesTTL = 5 * time.Minute
+
+	errFmtParseKubeVersion = "%q is not a Kubernetes version, e.g. v1.18.2"
+	errServerVersion       = "cannot get the version of the cluster"
+	errAPIVersions         = "cannot get the API versions of the cluster"
+)
+
+// kubeVersion matches the Kubernetes versions with an optional v prefix and
+// an optional patch version, e.g. v1.18 and 1.18.2-eks-1.
+var kubeVersion = regexp.MustCompile(`^v?(\d+)\.(\d+)(\.\d+)?([-+].*)?$`)
+
+// ParseKubeVersion parses the given Kubernetes version, typically the value
+// of KubeVersionAnnotationKey annotation. A missing patch version is 0.
+func ParseKubeVersion(s string) (chartutil.KubeVersion, error) {
+	s = strings.TrimSpace(s)
+	m := kubeVersion.FindStringSubmatch(s)
+	if m == nil {
+		return chartutil.KubeVersion{}, errors.Errorf(errFmtParseKubeVersion, s)
+	}
+	patch := m[3]
+	if patch == "" {
+		patch = ".0"
+	}
+	return chartutil.KubeVersion{
+		Version: "v" + m[1] + "." + m[2] + patch + m[4],
+		Major:   m[1],
+		Minor:   m[2],
+	}, nil
+}
+
+// ParseAPIVersions parses the given comma separated list of API versions,
+// typically the value of APIVersionsAnnotationKey annotation.
+func ParseAPIVersions(s string) []string {
+	var result []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// WithKubeVersion returns an Option that makes the charts see the given
+// Kubernetes version instead of the default of Helm or the discovered one.
+func WithKubeVersion(v chartutil.KubeVersion) Option {
+	return func(e *Engine) {
+		e.KubeVersion = &v
+	}
+}
+
+// WithAPIVersions returns an Option that adds the given API versions to the
+// ones that the charts see.
+func WithAPIVersions(v ...string) Option {
+	return func(e *Engine) {
+		e.APIVersions = append(e.APIVersions, v...)
+	}
+}
+
+// WithCapabilitiesDiscovery returns an Option that makes the charts see the
+// Kubernetes version and the API versions of the cluster that are discovered
+// by the given CapabilitiesDiscoverer instead of the defaults of Helm.
+func WithCapabilitiesDiscovery(d *CapabilitiesDiscoverer) Option {
+	return func(e *Engine) {
+		e.Discoverer = d
+	}
+}
+
+// NewCapabilitiesDiscoverer returns a new *CapabilitiesDiscoverer that
+// discovers the capabilities with the given client at most once in the given
+// duration.
+func NewCapabilitiesDiscoverer(dc discovery.DiscoveryInterface, ttl time.Duration) *CapabilitiesDiscoverer {
+	return &CapabilitiesDiscoverer{client: dc, ttl: ttl, now: time.Now}
+}
+
+// A CapabilitiesDiscoverer discovers the Kubernetes version and the API
+// versions of a cluster, which are cached so that every rendering does not
+// hit the API server. The API versions of the CRDs that are installed after
+// a discovery are seen once the cache expires.
+type CapabilitiesDiscoverer struct {
+	client discovery.DiscoveryInterface
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	caps *chartutil.Capabilities
+	at   time.Time
+}
+
+// Capabilities returns the capabilities of the cluster. The capabilities of
+// the last successful discovery are returned if the discovery fails, so that
+// an unavailable API server does not change the rendered resources.
+func (d *CapabilitiesDiscoverer) Capabilities() (*chartutil.Capabilities, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.caps != nil && d.now().Sub(d.at) < d.ttl {
+		return d.caps, nil
+	}
+	caps, err := discover(d.client)
+	if err != nil {
+		if d.caps != nil {
+			return d.caps, nil
+		}
+		return nil, err
+	}
+	d.caps, d.at = caps, d.now()
+	return caps, nil
+}
+
+// discover returns the capabilities of the cluster that the given client
+// talks to. The API services that are registered but unavailable are
+// skipped as Helm does.
+func discover(dc discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return nil, errors.Wrap(err, errServerVersion)
+	}
+	versions, err := action.GetVersionSet(dc)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, errors.Wrap(err, errAPIVersions)
+	}
+	return &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{
+			Version: info.GitVersion,
+			Major:   info.Major,
+			Minor:   info.Minor,
+		},
+		APIVersions: versions,
+	}, nil
+}
+
+// capabilities returns the capabilities that the charts are rendered with,
+// or nil if none of them are configured, in which case the defaults of Helm
+// are used. The configured Kubernetes version and API versions are applied
+// over the discovered capabilities, if any, or the defaults of Helm.
+func (e *Engine) capabilities() (*chartutil.Capabilities, error) {
+	if e.Discoverer == nil && e.KubeVersion == nil && len(e.APIVersions) == 0 {
+		return nil, nil
+	}
+	base := &chartutil.Capabilities{
+		KubeVersion: chartutil.DefaultCapabilities.KubeVersion,
+		APIVersions: chartutil.DefaultVersionSet,
+	}
+	if e.Discoverer != nil {
+		var err error
+		if base, err = e.Discoverer.Capabilities(); err != nil {
+			return nil, err
+		}
+	}
+	// NOTE: The API versions are copied since the base ones are shared
+	// between the renderings.
+	caps := &chartutil.Capabilities{
+		KubeVersion: base.KubeVersion,
+		APIVersions: make(chartutil.VersionSet, 0, len(base.APIVersions)+len(e.APIVersions)),
+	}
+	caps.APIVersions = append(append(caps.APIVersions, base.APIVersions...), e.APIVersions...)
+	if e.KubeVersion != nil {
+		caps.KubeVersion = *e.KubeVersion
+	}
+	return caps, nil
+}
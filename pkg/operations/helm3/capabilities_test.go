@@ -0,0 +1,206 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	kubetesting "k8s.io/client-go/testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+var errBoom = errors.New("boom")
+
+func TestParseKubeVersion(t *testing.T) {
+	type want struct {
+		v   chartutil.KubeVersion
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		s      string
+		want   want
+	}{
+		"Full": {
+			reason: "A version with a patch version should be parsed as it is.",
+			s:      "v1.18.2",
+			want:   want{v: chartutil.KubeVersion{Version: "v1.18.2", Major: "1", Minor: "18"}},
+		},
+		"NoPrefixNoPatch": {
+			reason: "A version without the v prefix and the patch version should be completed.",
+			s:      " 1.17 ",
+			want:   want{v: chartutil.KubeVersion{Version: "v1.17.0", Major: "1", Minor: "17"}},
+		},
+		"PreRelease": {
+			reason: "The suffix of a version should be kept.",
+			s:      "v1.16.8-eks-e16311",
+			want:   want{v: chartutil.KubeVersion{Version: "v1.16.8-eks-e16311", Major: "1", Minor: "16"}},
+		},
+		"Invalid": {
+			reason: "A value that is not a version should be rejected.",
+			s:      "latest",
+			want:   want{err: errors.Errorf(errFmtParseKubeVersion, "latest")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseKubeVersion(tc.s)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseKubeVersion(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.v, got); diff != "" {
+				t.Errorf("\n%s\nParseKubeVersion(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+// failingDiscovery is a fake discovery client whose ServerVersion fails
+// when err is set.
+type failingDiscovery struct {
+	*fakediscovery.FakeDiscovery
+	err error
+}
+
+func (d *failingDiscovery) ServerVersion() (*version.Info, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.FakeDiscovery.ServerVersion()
+}
+
+func TestCapabilitiesDiscoverer(t *testing.T) {
+	dc := &failingDiscovery{FakeDiscovery: &fakediscovery.FakeDiscovery{
+		Fake: &kubetesting.Fake{Resources: []*metav1.APIResourceList{{
+			GroupVersion: "monitoring.coreos.com/v1",
+			APIResources: []metav1.APIResource{{Name: "servicemonitors", Kind: "ServiceMonitor"}},
+		}}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.18.2", Major: "1", Minor: "18"},
+	}}
+	now := time.Now()
+	d := NewCapabilitiesDiscoverer(dc, time.Minute)
+	d.now = func() time.Time { return now }
+
+	want := &chartutil.Capabilities{
+		KubeVersion: chartutil.KubeVersion{Version: "v1.18.2", Major: "1", Minor: "18"},
+		APIVersions: chartutil.VersionSet{"monitoring.coreos.com/v1", "monitoring.coreos.com/v1/ServiceMonitor"},
+	}
+	got, err := d.Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities(): %s", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Capabilities(): -want, +got:\n%s", diff)
+	}
+
+	// The capabilities should be cached until they expire.
+	dc.FakedServerVersion = &version.Info{GitVersion: "v1.19.0", Major: "1", Minor: "19"}
+	got, _ = d.Capabilities()
+	if diff := cmp.Diff("v1.18.2", got.KubeVersion.Version); diff != "" {
+		t.Errorf("Capabilities(): cached capabilities should be returned: -want, +got:\n%s", diff)
+	}
+
+	// The last discovered capabilities should be returned if the discovery
+	// fails.
+	now = now.Add(2 * time.Minute)
+	dc.err = errBoom
+	got, err = d.Capabilities()
+	if err != nil {
+		t.Errorf("Capabilities(): the last discovered capabilities should be returned instead of %s", err)
+	}
+	if diff := cmp.Diff("v1.18.2", got.KubeVersion.Version); diff != "" {
+		t.Errorf("Capabilities(): -want, +got:\n%s", diff)
+	}
+
+	dc.err = nil
+	got, _ = d.Capabilities()
+	if diff := cmp.Diff("v1.19.0", got.KubeVersion.Version); diff != "" {
+		t.Errorf("Capabilities(): expired capabilities should be discovered again: -want, +got:\n%s", diff)
+	}
+
+	// A discovery without any previous capabilities should fail.
+	dc.err = errBoom
+	_, err = NewCapabilitiesDiscoverer(dc, time.Minute).Capabilities()
+	if diff := cmp.Diff(errors.Wrap(errBoom, errServerVersion), err, test.EquateErrors()); diff != "" {
+		t.Errorf("Capabilities(): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestInstallCapabilities(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "caps", Version: "0.1.0"},
+		Templates: []*chart.File{{
+			Name: "templates/cm.yaml",
+			Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: caps
+data:
+  kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+  monitoring: {{ .Capabilities.APIVersions.Has "monitoring.coreos.com/v1" | quote }}
+`),
+		}},
+	}
+	cases := map[string]struct {
+		reason string
+		o      []Option
+		want   map[string]interface{}
+	}{
+		"Defaults": {
+			reason: "The charts should see the defaults of Helm if no capabilities are configured.",
+			want:   map[string]interface{}{"kubeVersion": "v1.16.0", "monitoring": "false"},
+		},
+		"Configured": {
+			reason: "The charts should see the configured Kubernetes version and API versions.",
+			o: []Option{
+				WithKubeVersion(chartutil.KubeVersion{Version: "v1.18.2", Major: "1", Minor: "18"}),
+				WithAPIVersions("monitoring.coreos.com/v1"),
+			},
+			want: map[string]interface{}{"kubeVersion": "v1.18.2", "monitoring": "true"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := NewHelm3Engine(tc.o...)
+			manifest, err := e.install(c, "test", map[string]interface{}{})
+			if err != nil {
+				t.Fatalf("\n%s\ninstall(...): %s", tc.reason, err)
+			}
+			resources, err := parse([]byte(manifest))
+			if err != nil {
+				t.Fatalf("\n%s\nparse(...): %s", tc.reason, err)
+			}
+			u, ok := resources[0].(*unstructured.Unstructured)
+			if !ok {
+				t.Fatalf("\n%s\nparse(...): %T is not unstructured", tc.reason, resources[0])
+			}
+			if diff := cmp.Diff(tc.want, u.UnstructuredContent()["data"]); diff != "" {
+				t.Errorf("\n%s\ninstall(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
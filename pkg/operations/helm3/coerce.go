@@ -0,0 +1,260 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+const (
+	// CoerceValuesAnnotationKey is the annotation on the StackDefinition that
+	// makes the Engine convert the values to the types that are declared in
+	// the values.schema.json file of the charts when its value is "true".
+	CoerceValuesAnnotationKey = "templatestacks.crossplane.io/helm3-coerce-values"
+
+	// ValueTypesAnnotationKey is the annotation on the StackDefinition whose
+	// value is a YAML map of value paths to the types that the values are
+	// converted to, e.g. image.tag: string.
+	ValueTypesAnnotationKey = "templatestacks.crossplane.io/helm3-value-types"
+
+	errParseValueTypes = "could not parse the value types"
+	errParseSchema     = "could not parse the values schema of the chart"
+	errFmtValueType    = "unknown type %q of value %s"
+	errFmtCoerce       = "cannot convert %v to %s"
+)
+
+// A ValueType is a JSON schema type that a value can be converted to.
+type ValueType string
+
+// Value types.
+const (
+	ValueTypeString  ValueType = "string"
+	ValueTypeInteger ValueType = "integer"
+	ValueTypeNumber  ValueType = "number"
+	ValueTypeBoolean ValueType = "boolean"
+)
+
+// ParseValueTypes parses the given YAML map of value paths to types,
+// typically the value of ValueTypesAnnotationKey annotation.
+func ParseValueTypes(data string) (map[string]ValueType, error) {
+	types := map[string]ValueType{}
+	if err := sigsyaml.Unmarshal([]byte(data), &types); err != nil {
+		return nil, errors.Wrap(err, errParseValueTypes)
+	}
+	for path, t := range types {
+		switch t {
+		case ValueTypeString, ValueTypeInteger, ValueTypeNumber, ValueTypeBoolean:
+		default:
+			return nil, errors.Errorf(errFmtValueType, t, path)
+		}
+	}
+	return types, nil
+}
+
+// A coercer converts the scalar values to the types that are declared for
+// their paths, or in the values schema of the chart if none is declared.
+// Values without a type or with a type other than string, integer, number and
+// boolean are left as is for Helm to validate.
+type coercer struct {
+	types map[string]ValueType
+}
+
+// coerce returns a copy of the given values that are converted according to
+// the given JSON schema, which may be empty. The returned error is a
+// *coerceError with the path of the value that cannot be converted.
+func (c coercer) coerce(values map[string]interface{}, schema []byte) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	if len(schema) != 0 {
+		if err := json.Unmarshal(schema, &root); err != nil {
+			return nil, errors.Wrap(err, errParseSchema)
+		}
+	}
+	result, err := c.value(values, root, "")
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string]interface{}), nil
+}
+
+func (c coercer) value(v interface{}, schema map[string]interface{}, path string) (interface{}, error) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		props, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		result := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			s, ok := props[k].(map[string]interface{})
+			if !ok {
+				s = additional
+			}
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			converted, err := c.value(e, s, p)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = converted
+		}
+		return result, nil
+	case []interface{}:
+		items, _ := schema["items"].(map[string]interface{})
+		result := make([]interface{}, len(t))
+		for i, e := range t {
+			converted, err := c.value(e, items, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+	}
+	targets := schemaTypes(schema)
+	if t, ok := c.types[path]; ok {
+		targets = []ValueType{t}
+	}
+	converted, err := convert(v, targets)
+	if err != nil {
+		return nil, &coerceError{path: path, err: err}
+	}
+	return converted, nil
+}
+
+// schemaTypes returns the scalar types that are allowed by the given JSON
+// schema.
+func schemaTypes(schema map[string]interface{}) []ValueType {
+	var types []interface{}
+	switch t := schema["type"].(type) {
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+	var result []ValueType
+	for _, t := range types {
+		switch vt := ValueType(fmt.Sprint(t)); vt {
+		case ValueTypeString, ValueTypeInteger, ValueTypeNumber, ValueTypeBoolean:
+			result = append(result, vt)
+		}
+	}
+	return result
+}
+
+// convert returns the given scalar value as is if its type is one of the
+// given types. Otherwise, it returns the value converted to the first of the
+// given types that it can be converted to.
+func convert(v interface{}, types []ValueType) (interface{}, error) {
+	if len(types) == 0 || v == nil {
+		return v, nil
+	}
+	for _, t := range types {
+		if is(v, t) {
+			return v, nil
+		}
+	}
+	for _, t := range types {
+		if converted, ok := to(v, t); ok {
+			return converted, nil
+		}
+	}
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return nil, errors.Errorf(errFmtCoerce, v, strings.Join(names, " or "))
+}
+
+func is(v interface{}, t ValueType) bool {
+	switch n := v.(type) {
+	case string:
+		return t == ValueTypeString
+	case bool:
+		return t == ValueTypeBoolean
+	case int, int32, int64:
+		return t == ValueTypeInteger || t == ValueTypeNumber
+	case float64:
+		return t == ValueTypeNumber || (t == ValueTypeInteger && n == math.Trunc(n))
+	}
+	return false
+}
+
+func to(v interface{}, t ValueType) (interface{}, bool) {
+	if t == ValueTypeString {
+		switch n := v.(type) {
+		case float64:
+			return strconv.FormatFloat(n, 'f', -1, 64), true
+		case int, int32, int64, bool:
+			return fmt.Sprint(n), true
+		}
+		return nil, false
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, false
+	}
+	switch t {
+	case ValueTypeInteger:
+		i, err := strconv.ParseInt(s, 10, 64)
+		return i, err == nil
+	case ValueTypeNumber:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	case ValueTypeBoolean:
+		b, err := strconv.ParseBool(s)
+		return b, err == nil
+	}
+	return nil, false
+}
+
+// A coerceError is returned when a value cannot be converted. Its path is
+// the path of the value in the values of the chart.
+type coerceError struct {
+	path string
+	err  error
+}
+
+func (e *coerceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.err)
+}
+
+// fieldPath returns the path of the field of the parent resource that the
+// value at the given path of the values is built from. The values that are
+// not bound are read from the field at the given root path, or the spec if
+// it's empty.
+func fieldPath(root string, bindings []v1alpha1.FieldBinding, path string) string {
+	if len(bindings) == 0 {
+		return rootPath(root) + "." + path
+	}
+	for _, b := range bindings {
+		if path == b.To || strings.HasPrefix(path, b.To+".") || strings.HasPrefix(path, b.To+"[") {
+			return b.From + strings.TrimPrefix(path, b.To)
+		}
+	}
+	// NOTE: The values that are not bound from the parent resource can only
+	// come from the values override annotation.
+	return "metadata.annotations[" + ValuesOverrideAnnotationKey + "]." + path
+}
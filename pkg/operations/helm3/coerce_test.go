@@ -0,0 +1,140 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+)
+
+func TestCoerce(t *testing.T) {
+	schema := []byte(`{
+  "properties": {
+    "image": {"properties": {"tag": {"type": "string"}}},
+    "replicas": {"type": "integer"},
+    "debug": {"type": "boolean"},
+    "ratio": {"type": ["number", "null"]},
+    "ports": {"items": {"type": "integer"}},
+    "labels": {"additionalProperties": {"type": "string"}}
+  }
+}`)
+	type args struct {
+		values map[string]interface{}
+		schema []byte
+		types  map[string]ValueType
+	}
+	type want struct {
+		values map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"Schema": {
+			args: args{
+				values: map[string]interface{}{
+					"image":    map[string]interface{}{"tag": int64(8)},
+					"replicas": "3",
+					"debug":    "true",
+					"ratio":    "0.5",
+					"ports":    []interface{}{"80", int64(443)},
+					"labels":   map[string]interface{}{"version": 1.5},
+					"other":    int64(1),
+				},
+				schema: schema,
+			},
+			want: want{values: map[string]interface{}{
+				"image":    map[string]interface{}{"tag": "8"},
+				"replicas": int64(3),
+				"debug":    true,
+				"ratio":    0.5,
+				"ports":    []interface{}{int64(80), int64(443)},
+				"labels":   map[string]interface{}{"version": "1.5"},
+				"other":    int64(1),
+			}},
+		},
+		"DeclaredTypesTakePrecedence": {
+			args: args{
+				values: map[string]interface{}{"replicas": int64(3), "other": int64(1)},
+				schema: schema,
+				types:  map[string]ValueType{"replicas": ValueTypeString, "other": ValueTypeString},
+			},
+			want: want{values: map[string]interface{}{"replicas": "3", "other": "1"}},
+		},
+		"CannotConvert": {
+			args: args{
+				values: map[string]interface{}{"ports": []interface{}{"http"}},
+				schema: schema,
+			},
+			want: want{err: &coerceError{path: "ports[0]", err: errors.Errorf(errFmtCoerce, "http", "integer")}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := coercer{types: tc.args.types}.coerce(tc.args.values, tc.args.schema)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("coerce(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.values, got); diff != "" {
+				t.Errorf("coerce(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	bindings := []v1alpha1.FieldBinding{{From: "spec.frontend.ports", To: "service.ports"}}
+	cases := map[string]struct {
+		root     string
+		bindings []v1alpha1.FieldBinding
+		path     string
+		want     string
+	}{
+		"NoBindings": {
+			path: "image.tag",
+			want: "spec.image.tag",
+		},
+		"ValuesPath": {
+			root: "spec.values",
+			path: "image.tag",
+			want: "spec.values.image.tag",
+		},
+		"Bound": {
+			bindings: bindings,
+			path:     "service.ports[1]",
+			want:     "spec.frontend.ports[1]",
+		},
+		"Override": {
+			bindings: bindings,
+			path:     "image.tag",
+			want:     "metadata.annotations[" + ValuesOverrideAnnotationKey + "].image.tag",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, fieldPath(tc.root, tc.bindings, tc.path)); diff != "" {
+				t.Errorf("fieldPath(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/templating-controller/pkg/workloadidentity"
+)
+
+// The sources of the credentials that a chart can refer to with its
+// Credentials field.
+const (
+	// CredentialsDockerConfig reads the credentials from the docker config
+	// file that is mounted to the controller.
+	CredentialsDockerConfig = "DockerConfig"
+
+	// CredentialsGCP exchanges the identity of the workload in GCP for an
+	// access token of Artifact Registry or Container Registry.
+	CredentialsGCP = "GCP"
+
+	// CredentialsAzure exchanges the identity of the workload in Azure for a
+	// refresh token of Azure Container Registry.
+	CredentialsAzure = "Azure"
+
+	// CredentialsAWS exchanges the identity of the workload in AWS for an
+	// authorization token of Elastic Container Registry.
+	CredentialsAWS = "AWS"
+)
+
+// RefreshMargin is how long before their expiry the cached credentials are
+// refreshed.
+const RefreshMargin = 5 * time.Minute
+
+const (
+	errNoSecretReader      = "the pull secret of the chart cannot be read without access to the cluster"
+	errGetPullSecret       = "cannot get the pull secret"
+	errParsePullSecret     = "cannot parse the pull secret"
+	errFmtNoCredentials    = "the pull secret has no credentials for %s"
+	errReadDockerConfig    = "cannot read the docker config"
+	errParseDockerConfig   = "cannot parse the docker config"
+	errFmtNoConfigEntry    = "the docker config has no credentials for %s"
+	errBothCredentials     = "a chart cannot have both a pull secret and credentials"
+	errFmtCredentials      = "unknown credentials %q"
+	errFmtSourceCredential = "cannot get the credentials for %s"
+)
+
+// Credentials are the username and the password that authenticate to a
+// registry or a repository.
+type Credentials struct {
+	Username string
+	Password string
+
+	// Expires is when the credentials expire. The credentials that do not
+	// expire are not cached.
+	Expires time.Time
+}
+
+// A CredentialSource returns the credentials for the given registry or
+// repository host.
+type CredentialSource interface {
+	Credentials(ctx context.Context, host string) (Credentials, error)
+}
+
+// A CredentialSourceFn is a function that satisfies the CredentialSource
+// interface.
+type CredentialSourceFn func(ctx context.Context, host string) (Credentials, error)
+
+// Credentials calls the CredentialSourceFn.
+func (fn CredentialSourceFn) Credentials(ctx context.Context, host string) (Credentials, error) {
+	return fn(ctx, host)
+}
+
+// WithCredentialSource returns a FetcherOption that makes the charts whose
+// Credentials is the given name use the given source.
+func WithCredentialSource(name string, s CredentialSource) FetcherOption {
+	return func(f *Fetcher) {
+		f.Sources[name] = s
+	}
+}
+
+// WithDockerConfig returns a FetcherOption that reads the credentials of the
+// CredentialsDockerConfig source from the docker config file at the given
+// path.
+func WithDockerConfig(path string) FetcherOption {
+	return WithCredentialSource(CredentialsDockerConfig, DockerConfigFile(path))
+}
+
+// DefaultCredentialSources returns the sources of the credentials that a
+// Fetcher uses by default. The docker config is read from $DOCKER_CONFIG, or
+// ~/.docker if it's not set, and the tokens of the workload identities are
+// cached until shortly before they expire.
+func DefaultCredentialSources() map[string]CredentialSource {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".docker")
+	}
+	return map[string]CredentialSource{
+		CredentialsDockerConfig: DockerConfigFile(filepath.Join(dir, "config.json")),
+		CredentialsGCP:          NewCachingSource(WorkloadIdentity(workloadidentity.NewGCPSource())),
+		CredentialsAzure:        NewCachingSource(WorkloadIdentity(workloadidentity.NewAzureSource())),
+		CredentialsAWS:          NewCachingSource(WorkloadIdentity(workloadidentity.NewAWSSource())),
+	}
+}
+
+// WorkloadIdentity returns a CredentialSource that returns the credentials
+// that the given source exchanges the identity of the workload for.
+func WorkloadIdentity(s workloadidentity.Source) CredentialSource {
+	return CredentialSourceFn(func(ctx context.Context, host string) (Credentials, error) {
+		c, err := s.Credentials(ctx, host)
+		if err != nil {
+			return Credentials{}, err
+		}
+		return Credentials{Username: c.Username, Password: c.Password, Expires: c.Expires}, nil
+	})
+}
+
+// credentials returns the credentials that the given chart is fetched with
+// from the given host. The charts without a pull secret or credentials are
+// fetched anonymously.
+func (f *Fetcher) credentials(ctx context.Context, c Chart, host string) (Credentials, error) {
+	switch {
+	case c.PullSecret != "" && c.Credentials != "":
+		return Credentials{}, errors.New(errBothCredentials)
+	case c.PullSecret != "":
+		return f.pullSecret(ctx, c.PullSecret, host)
+	case c.Credentials != "":
+		s, ok := f.Sources[c.Credentials]
+		if !ok {
+			return Credentials{}, errors.Errorf(errFmtCredentials, c.Credentials)
+		}
+		cr, err := s.Credentials(ctx, host)
+		return cr, errors.Wrapf(err, errFmtSourceCredential, host)
+	}
+	return Credentials{}, nil
+}
+
+// pullSecret returns the credentials for the given host in the pull secret
+// with the given name.
+func (f *Fetcher) pullSecret(ctx context.Context, name, host string) (Credentials, error) {
+	if f.Secrets == nil {
+		return Credentials{}, errors.New(errNoSecretReader)
+	}
+	s := &corev1.Secret{}
+	if err := f.Secrets.Get(ctx, types.NamespacedName{Namespace: f.Namespace, Name: name}, s); err != nil {
+		return Credentials{}, errors.Wrap(err, errGetPullSecret)
+	}
+	cr, ok, err := fromDockerConfig(s.Data[corev1.DockerConfigJsonKey], host)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, errParsePullSecret)
+	}
+	if !ok {
+		return Credentials{}, errors.Errorf(errFmtNoCredentials, host)
+	}
+	return cr, nil
+}
+
+// DockerConfigFile is a CredentialSource that reads the credentials from the
+// docker config file at its path. The file is read every time so that the
+// credentials that are rotated in a mounted file are picked up.
+type DockerConfigFile string
+
+// Credentials returns the credentials for the given host in the file.
+func (path DockerConfigFile) Credentials(_ context.Context, host string) (Credentials, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(string(path)))
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, errReadDockerConfig)
+	}
+	cr, ok, err := fromDockerConfig(data, host)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, errParseDockerConfig)
+	}
+	if !ok {
+		return Credentials{}, errors.Errorf(errFmtNoConfigEntry, host)
+	}
+	return cr, nil
+}
+
+// dockerConfig is the content of a docker config file, and of a Secret of
+// kubernetes.io/dockerconfigjson type.
+type dockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+}
+
+type dockerAuth struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+// fromDockerConfig returns the credentials for the given host in the given
+// docker config, and whether it has any.
+func fromDockerConfig(data []byte, host string) (Credentials, bool, error) {
+	cfg := &dockerConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return Credentials{}, false, err
+	}
+	for _, key := range []string{host, "https://" + host, "https://" + host + "/"} {
+		a, ok := cfg.Auths[key]
+		if !ok {
+			continue
+		}
+		if a.Auth == "" {
+			return Credentials{Username: a.Username, Password: a.Password}, true, nil
+		}
+		cr, err := decodeBasic(a.Auth)
+		return cr, err == nil, err
+	}
+	return Credentials{}, false, nil
+}
+
+// decodeBasic decodes the given base64 encoded username:password pair.
+func decodeBasic(s string) (Credentials, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return Credentials{}, err
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return Credentials{}, errors.New("credentials are not in username:password form")
+	}
+	return Credentials{Username: parts[0], Password: parts[1]}, nil
+}
+
+// NewCachingSource returns a new *CachingSource that caches the credentials
+// of the given source.
+func NewCachingSource(s CredentialSource) *CachingSource {
+	return &CachingSource{Source: s, now: time.Now, cached: map[string]Credentials{}}
+}
+
+// A CachingSource caches the credentials that its Source returns per host
+// until RefreshMargin before they expire, so that the short-lived tokens of
+// the workload identities are exchanged once per their lifetime and never
+// used when they are about to expire.
+type CachingSource struct {
+	Source CredentialSource
+
+	now    func() time.Time
+	mu     sync.Mutex
+	cached map[string]Credentials
+}
+
+// Credentials returns the cached credentials for the given host, refreshing
+// them if they expire soon.
+func (s *CachingSource) Credentials(ctx context.Context, host string) (Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cr, ok := s.cached[host]; ok && s.now().Add(RefreshMargin).Before(cr.Expires) {
+		return cr, nil
+	}
+	cr, err := s.Source.Credentials(ctx, host)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if !cr.Expires.IsZero() {
+		s.cached[host] = cr
+	}
+	return cr, nil
+}
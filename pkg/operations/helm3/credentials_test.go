@@ -0,0 +1,156 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestCredentials(t *testing.T) {
+	errBoom := errors.New("boom")
+	static := CredentialSourceFn(func(_ context.Context, host string) (Credentials, error) {
+		return Credentials{Username: host, Password: "secret"}, nil
+	})
+	failing := CredentialSourceFn(func(context.Context, string) (Credentials, error) {
+		return Credentials{}, errBoom
+	})
+	type want struct {
+		cr  Credentials
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		c      Chart
+		want   want
+	}{
+		"Anonymous": {
+			reason: "A chart without a pull secret or credentials should be fetched anonymously.",
+			c:      Chart{Name: "cool"},
+			want:   want{},
+		},
+		"Source": {
+			reason: "The credentials should be returned from the source that the chart refers to.",
+			c:      Chart{Name: "cool", Credentials: "static"},
+			want:   want{cr: Credentials{Username: "registry.io", Password: "secret"}},
+		},
+		"SourceFailed": {
+			reason: "The error of the source should be wrapped with the host.",
+			c:      Chart{Name: "cool", Credentials: "failing"},
+			want:   want{err: errors.Wrapf(errBoom, errFmtSourceCredential, "registry.io")},
+		},
+		"UnknownSource": {
+			reason: "A chart that refers to an unknown source should be rejected.",
+			c:      Chart{Name: "cool", Credentials: "unknown"},
+			want:   want{err: errors.Errorf(errFmtCredentials, "unknown")},
+		},
+		"Both": {
+			reason: "A chart with both a pull secret and credentials should be rejected.",
+			c:      Chart{Name: "cool", Credentials: "static", PullSecret: "pull"},
+			want:   want{err: errors.New(errBothCredentials)},
+		},
+	}
+	f := NewFetcher("", WithCredentialSource("static", static), WithCredentialSource("failing", failing))
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := f.credentials(context.Background(), tc.c, "registry.io")
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ncredentials(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.cr, got); diff != "" {
+				t.Errorf("\n%s\ncredentials(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDockerConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	path := filepath.Join(dir, "config.json")
+	write := func(data string) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := DockerConfigFile(path)
+
+	write(`{"auths": {"https://registry.io": {"auth": "Y29vbDpzZWNyZXQ="}}}`)
+	got, err := s.Credentials(context.Background(), "registry.io")
+	if err != nil {
+		t.Fatalf("Credentials(...): %s", err)
+	}
+	if diff := cmp.Diff(Credentials{Username: "cool", Password: "secret"}, got); diff != "" {
+		t.Errorf("Credentials(...): -want, +got:\n%s", diff)
+	}
+
+	// The rotated credentials should be read from the file.
+	write(`{"auths": {"registry.io": {"username": "cool", "password": "rotated"}}}`)
+	got, err = s.Credentials(context.Background(), "registry.io")
+	if err != nil {
+		t.Fatalf("Credentials(...): %s", err)
+	}
+	if diff := cmp.Diff(Credentials{Username: "cool", Password: "rotated"}, got); diff != "" {
+		t.Errorf("Credentials(...): -want rotated, +got:\n%s", diff)
+	}
+
+	_, err = s.Credentials(context.Background(), "other.io")
+	if diff := cmp.Diff(errors.Errorf(errFmtNoConfigEntry, "other.io"), err, test.EquateErrors()); diff != "" {
+		t.Errorf("Credentials(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestCachingSource(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	s := NewCachingSource(CredentialSourceFn(func(context.Context, string) (Credentials, error) {
+		calls++
+		return Credentials{Username: "cool", Password: "token", Expires: now.Add(time.Hour)}, nil
+	}))
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.Credentials(ctx, "registry.io"); err != nil {
+			t.Fatalf("Credentials(...): %s", err)
+		}
+	}
+	if diff := cmp.Diff(1, calls); diff != "" {
+		t.Errorf("Credentials(...): the credentials should be cached until they expire: -want calls, +got calls:\n%s", diff)
+	}
+
+	// The credentials that expire soon should be refreshed.
+	now = now.Add(time.Hour - RefreshMargin)
+	if _, err := s.Credentials(ctx, "registry.io"); err != nil {
+		t.Fatalf("Credentials(...): %s", err)
+	}
+	if diff := cmp.Diff(2, calls); diff != "" {
+		t.Errorf("Credentials(...): the credentials should be refreshed before they expire: -want calls, +got calls:\n%s", diff)
+	}
+}
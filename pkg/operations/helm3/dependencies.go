@@ -0,0 +1,202 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	fileScheme = "file://"
+
+	// builtDir is the directory in the cache directory of the Fetcher that
+	// the charts built with their dependencies are written to.
+	builtDir = "built"
+
+	errLoadChart         = "cannot load the chart"
+	errFmtDependency     = "dependency %s"
+	errNotVendored       = "dependency has no repository and is not vendored in the charts directory"
+	errFmtRepositoryName = "repository %s refers to a repository added with helm repo add, use its URL instead"
+	errLocalInArchive    = "file:// repositories cannot be resolved from a chart archive"
+	errBuildChart        = "cannot write the chart with its dependencies"
+)
+
+// BuildCharts returns a copy of the given charts in which the ones with
+// dependencies that are not vendored refer to an archive that has them,
+// which is built with Build. The paths of the charts are relative to the
+// given directory, and the charts fetched from a repository are read from
+// their archives.
+func (f *Fetcher) BuildCharts(ctx context.Context, dir string, charts []Chart) ([]Chart, error) {
+	result := make([]Chart, len(charts))
+	for i, c := range charts {
+		result[i] = c
+		path := filepath.Join(dir, c.Path)
+		if c.Archive != "" {
+			path = c.Archive
+		}
+		built, err := f.Build(ctx, path, c)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtChart, c.id())
+		}
+		if built != path {
+			result[i].Archive = built
+		}
+	}
+	return result, nil
+}
+
+// Build returns the path of an archive of the chart at the given path, which
+// is either a directory or an archive, with all of its dependencies, or the
+// given path if the chart has no dependencies to resolve. The dependencies
+// that are vendored in the charts directory are used as they are. The others
+// are read from the directory of their file:// repository, or fetched from
+// their repository at the version in the Chart.lock file if the chart has
+// one. The fetched dependencies use the credentials of the given chart if
+// they are in the same repository host. The dependencies of the
+// dependencies are resolved the same way.
+func (f *Fetcher) Build(ctx context.Context, path string, c Chart) (string, error) {
+	ch, err := loader.Load(path)
+	if err != nil {
+		return "", errors.Wrap(err, errLoadChart)
+	}
+	changed, err := f.resolve(ctx, localDir(path), ch, c)
+	if err != nil {
+		return "", err
+	}
+	if !changed {
+		return path, nil
+	}
+	sum := sha256.Sum256([]byte(path))
+	dir := filepath.Join(f.CacheDir, builtDir, hex.EncodeToString(sum[:]))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, errBuildChart)
+	}
+	built, err := chartutil.Save(ch, dir)
+	return built, errors.Wrap(err, errBuildChart)
+}
+
+// resolve adds the dependencies of the given chart, whose directory is the
+// given one, that are not vendored to it, and returns whether it added any.
+// The directory is empty if the chart is read from an archive.
+func (f *Fetcher) resolve(ctx context.Context, dir string, ch *chart.Chart, c Chart) (bool, error) {
+	if ch.Metadata == nil {
+		return false, nil
+	}
+	vendored := map[string]bool{}
+	for _, sub := range ch.Dependencies() {
+		vendored[sub.Name()] = true
+	}
+	locked := map[string]string{}
+	if ch.Lock != nil {
+		for _, d := range ch.Lock.Dependencies {
+			locked[d.Name] = d.Version
+		}
+	}
+	changed := false
+	subDirs := map[*chart.Chart]string{}
+	for _, d := range ch.Metadata.Dependencies {
+		if vendored[d.Name] {
+			continue
+		}
+		sub, subDir, err := f.dependency(ctx, dir, d, locked[d.Name], c)
+		if err != nil {
+			return false, errors.Wrapf(err, errFmtDependency, d.Name)
+		}
+		ch.AddDependency(sub)
+		vendored[d.Name] = true
+		subDirs[sub] = subDir
+		changed = true
+	}
+	for _, sub := range ch.Dependencies() {
+		subDir, ok := subDirs[sub]
+		if !ok && dir != "" {
+			subDir = localDir(filepath.Join(dir, "charts", sub.Name()))
+		}
+		subChanged, err := f.resolve(ctx, subDir, sub, c)
+		if err != nil {
+			return false, errors.Wrapf(err, errFmtDependency, sub.Name())
+		}
+		changed = changed || subChanged
+	}
+	return changed, nil
+}
+
+// dependency returns the given dependency of a chart in the given directory,
+// and the directory of the dependency if it's read from one.
+func (f *Fetcher) dependency(ctx context.Context, dir string, d *chart.Dependency, locked string, c Chart) (*chart.Chart, string, error) {
+	switch {
+	case d.Repository == "":
+		return nil, "", errors.New(errNotVendored)
+	case strings.HasPrefix(d.Repository, "@") || strings.HasPrefix(d.Repository, "alias:"):
+		return nil, "", errors.Errorf(errFmtRepositoryName, d.Repository)
+	case strings.HasPrefix(d.Repository, fileScheme):
+		if dir == "" {
+			return nil, "", errors.New(errLocalInArchive)
+		}
+		subDir := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(d.Repository, fileScheme)))
+		sub, err := loader.Load(subDir)
+		return sub, subDir, errors.Wrap(err, errLoadChart)
+	}
+	dc := Chart{Repository: d.Repository, Name: d.Name, Version: d.Version}
+	if locked != "" {
+		dc.Version = locked
+	}
+	if sameHost(d.Repository, c.Repository) {
+		dc.PullSecret = c.PullSecret
+		dc.Credentials = c.Credentials
+	}
+	archive, err := f.Fetch(ctx, dc)
+	if err != nil {
+		return nil, "", err
+	}
+	sub, err := loader.Load(archive)
+	return sub, "", errors.Wrap(err, errLoadChart)
+}
+
+// localDir returns the given path if it's a directory, or an empty string
+// otherwise.
+func localDir(path string) string {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return path
+	}
+	return ""
+}
+
+// sameHost returns true if the given repositories are served by the same
+// host.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && ua.Host == ub.Host
+}
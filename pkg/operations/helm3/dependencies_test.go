@@ -0,0 +1,256 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestBuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dependencies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	write := func(path, content string) {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The remote chart is served by a repository whose latest version is
+	// newer than the locked one.
+	archive, err := chartutil.Save(&chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "remote", Version: "1.0.0"}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			fmt.Fprint(w, `apiVersion: v1
+entries:
+  remote:
+  - name: remote
+    version: 1.0.0
+    urls: [remote-1.0.0.tgz]
+  - name: remote
+    version: 1.1.0
+    urls: [remote-1.1.0.tgz]
+`)
+		case "/remote-1.0.0.tgz":
+			_, _ = w.Write(data)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	write("app/Chart.yaml", fmt.Sprintf(`apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+- name: vendored
+  version: 0.1.0
+- name: local
+  version: 0.1.0
+  repository: file://../local
+- name: remote
+  version: ^1.0.0
+  repository: %s
+`, srv.URL))
+	write("app/Chart.lock", fmt.Sprintf(`dependencies:
+- name: remote
+  version: 1.0.0
+  repository: %s
+digest: sha256:0
+generated: "2020-06-01T00:00:00Z"
+`, srv.URL))
+	write("app/charts/vendored/Chart.yaml", "apiVersion: v2\nname: vendored\nversion: 0.1.0\n")
+	write("local/Chart.yaml", "apiVersion: v2\nname: local\nversion: 0.1.0\n")
+	write("standalone/Chart.yaml", "apiVersion: v2\nname: standalone\nversion: 0.1.0\ndependencies:\n- name: vendored\n  version: 0.1.0\n")
+	write("standalone/charts/vendored/Chart.yaml", "apiVersion: v2\nname: vendored\nversion: 0.1.0\n")
+	write("missing/Chart.yaml", "apiVersion: v2\nname: missing\nversion: 0.1.0\ndependencies:\n- name: gone\n  version: 0.1.0\n")
+
+	f := NewFetcher(filepath.Join(dir, "cache"))
+	ctx := context.Background()
+
+	// The dependencies that are not vendored should be added to the built
+	// chart, the remote one at its locked version.
+	built, err := f.Build(ctx, filepath.Join(dir, "app"), Chart{})
+	if err != nil {
+		t.Fatalf("Build(...): %s", err)
+	}
+	ch, err := loader.Load(built)
+	if err != nil {
+		t.Fatalf("Build(...): cannot load the built chart: %s", err)
+	}
+	var got []string
+	for _, sub := range ch.Dependencies() {
+		got = append(got, sub.Name()+"-"+sub.Metadata.Version)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"local-0.1.0", "remote-1.0.0", "vendored-0.1.0"}, got); diff != "" {
+		t.Errorf("Build(...): -want dependencies, +got dependencies:\n%s", diff)
+	}
+
+	// A chart whose dependencies are all vendored should be used as it is.
+	path := filepath.Join(dir, "standalone")
+	if built, err := f.Build(ctx, path, Chart{}); err != nil || built != path {
+		t.Errorf("Build(...): a chart with vendored dependencies should not be built: %s, %v", built, err)
+	}
+
+	// A dependency without a repository should be vendored.
+	_, err = f.Build(ctx, filepath.Join(dir, "missing"), Chart{})
+	want := errors.Wrapf(errors.New(errNotVendored), errFmtDependency, "gone")
+	if diff := cmp.Diff(want.Error(), fmt.Sprint(err)); diff != "" {
+		t.Errorf("Build(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestSameHost(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		a      string
+		b      string
+		want   bool
+	}{
+		"Same": {
+			reason: "Repositories on the same host should match.",
+			a:      "https://charts.example.org/stable",
+			b:      "https://charts.example.org/incubator",
+			want:   true,
+		},
+		"Different": {
+			reason: "Repositories on different hosts should not match.",
+			a:      "https://charts.example.org",
+			b:      "https://evil.example.org",
+		},
+		"Empty": {
+			reason: "A chart without a repository should not share its credentials.",
+			a:      "https://charts.example.org",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, sameHost(tc.a, tc.b)); diff != "" {
+				t.Errorf("\n%s\nsameHost(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestInstallConditionsAndTags(t *testing.T) {
+	db := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "db", Version: "0.1.0"},
+		Templates: []*chart.File{{
+			Name: "templates/cm.yaml",
+			Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: db\n"),
+		}},
+	}
+	newChart := func() *chart.Chart {
+		c := &chart.Chart{
+			Metadata: &chart.Metadata{
+				APIVersion: chart.APIVersionV2,
+				Name:       "app",
+				Version:    "0.1.0",
+				Dependencies: []*chart.Dependency{
+					{Name: "db", Version: "0.1.0", Condition: "db.enabled", Tags: []string{"storage"}},
+				},
+			},
+			Templates: []*chart.File{{
+				Name: "templates/cm.yaml",
+				Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"),
+			}},
+		}
+		c.AddDependency(db)
+		return c
+	}
+	cases := map[string]struct {
+		reason string
+		values map[string]interface{}
+		want   []string
+	}{
+		"Default": {
+			reason: "A subchart should be deployed if neither its condition nor its tags are set.",
+			want:   []string{"app", "db"},
+		},
+		"ConditionFalse": {
+			reason: "A subchart should not be deployed if its condition is false.",
+			values: map[string]interface{}{"db": map[string]interface{}{"enabled": false}},
+			want:   []string{"app"},
+		},
+		"TagFalse": {
+			reason: "A subchart should not be deployed if its tag is false.",
+			values: map[string]interface{}{"tags": map[string]interface{}{"storage": false}},
+			want:   []string{"app"},
+		},
+		"ConditionOverridesTag": {
+			reason: "The condition of a subchart should take precedence over its tags.",
+			values: map[string]interface{}{
+				"db":   map[string]interface{}{"enabled": true},
+				"tags": map[string]interface{}{"storage": false},
+			},
+			want: []string{"app", "db"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			values := tc.values
+			if values == nil {
+				values = map[string]interface{}{}
+			}
+			manifest, err := NewHelm3Engine().install(newChart(), "test", values)
+			if err != nil {
+				t.Fatalf("\n%s\ninstall(...): %s", tc.reason, err)
+			}
+			resources, err := parse([]byte(manifest))
+			if err != nil {
+				t.Fatalf("\n%s\nparse(...): %s", tc.reason, err)
+			}
+			got := make([]string, len(resources))
+			for i, r := range resources {
+				got[i] = r.GetName()
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ninstall(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -18,16 +18,34 @@ package helm3
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
 
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
 
 	"github.com/crossplane/templating-controller/pkg/resource"
 )
@@ -35,11 +53,120 @@ import (
 const (
 	defaultRootPath = "resources"
 
-	errSpecCast      = "parent resource spec could not be casted into a map[string]interface{}"
-	errParse         = "could not parse the generated YAMLs"
-	errHelm3Template = "helm3 template call failed"
+	// ChartsAnnotationKey is the annotation on the StackDefinition whose
+	// value is the YAML list of the charts to render for every parent
+	// resource.
+	ChartsAnnotationKey = "templatestacks.crossplane.io/helm3-charts"
+
+	// AllowValuesOverrideAnnotationKey is the annotation on the
+	// StackDefinition that allows the parent resources to override the
+	// values with ValuesOverrideAnnotationKey when its value is "true".
+	AllowValuesOverrideAnnotationKey = "templatestacks.crossplane.io/allow-values-override"
+
+	// ValuesOverrideAnnotationKey is the annotation on the parent resource
+	// whose value is a YAML or JSON document of values that is merged over
+	// the values computed from the parent resource. It is meant for emergency
+	// patching of the rendered resources.
+	ValuesOverrideAnnotationKey = "templatestacks.crossplane.io/values-override"
+
+	// ValuesPathAnnotationKey is the annotation on the StackDefinition whose
+	// value is the path of the field of the parent resources that is used
+	// as values instead of the whole spec, e.g. "spec.values".
+	ValuesPathAnnotationKey = "templatestacks.crossplane.io/helm3-values-path"
+
+	// AllowLookupAnnotationKey is the annotation on the StackDefinition that
+	// enables the lookup template function of the helm3 and go-template
+	// engines when its value is "true".
+	AllowLookupAnnotationKey = "templatestacks.crossplane.io/allow-lookup"
+
+	// ParentMetadataAnnotationKey is the annotation on the StackDefinition
+	// that passes the metadata of the parent resource to the charts in the
+	// ParentValuesKey value when its value is "true".
+	ParentMetadataAnnotationKey = "templatestacks.crossplane.io/helm3-parent-metadata"
+
+	// ParentValuesKey is the top level value that has the metadata of the
+	// parent resource, i.e. its apiVersion, kind, name, namespace, uid,
+	// labels and annotations.
+	ParentValuesKey = "__parent"
+
+	errSpecCast       = "parent resource spec could not be casted into a map[string]interface{}"
+	errValuesCast     = "values field could not be casted into a map[string]interface{}"
+	errParse          = "could not parse the generated YAMLs"
+	errHelm3Template  = "helm3 template call failed"
+	errParseCharts    = "could not parse the chart list"
+	errBindValues     = "could not bind parent resource fields to chart values"
+	errFmtChart       = "chart %s"
+	errValuesOverride = "could not parse the values override annotation"
+	errClientOnly     = "only REST config is available for client-only installs"
+	errFmtDeprecated  = "chart %s is deprecated"
 )
 
+// Chart is a Helm chart that is rendered as part of a multi-chart stack.
+type Chart struct {
+	// Path of the chart relative to the resource path of the Engine. It's
+	// only used to name the chart if the chart has a Repository.
+	Path string `json:"path,omitempty"`
+
+	// Repository is the URL of the Helm repository to fetch the chart from
+	// instead of reading it from Path. A URL with oci scheme, e.g.
+	// oci://ghcr.io/org/charts, refers to an OCI registry.
+	Repository string `json:"repository,omitempty"`
+
+	// Name of the chart in the Repository.
+	Name string `json:"name,omitempty"`
+
+	// Version of the chart in the Repository. It can be a semantic version
+	// constraint, e.g. ~1.2.0. The latest version is used if it's empty.
+	// The charts in OCI registries require an exact version, which is the
+	// tag of the chart.
+	Version string `json:"version,omitempty"`
+
+	// PullSecret is the name of the Secret of kubernetes.io/dockerconfigjson
+	// type whose credentials are used to fetch the chart from the
+	// Repository. It's in the namespace of the StackDefinition.
+	PullSecret string `json:"pullSecret,omitempty"`
+
+	// Credentials is the name of the source of the credentials that are used
+	// to fetch the chart from the Repository instead of a PullSecret, e.g.
+	// CredentialsAWS.
+	Credentials string `json:"credentials,omitempty"`
+
+	// Archive is the path of the archive of the chart that is fetched from
+	// the Repository, or built with its dependencies. It's set by the
+	// Fetcher.
+	Archive string `json:"-"`
+
+	// ReleaseNameSuffix is appended to the name of the parent resource to
+	// form the release name of the chart.
+	ReleaseNameSuffix string `json:"releaseNameSuffix,omitempty"`
+
+	// Bindings map the fields of the parent resource to the values of the
+	// chart. If empty, the whole spec of the parent resource is used as
+	// values.
+	Bindings []v1alpha1.FieldBinding `json:"bindings,omitempty"`
+
+	// ValueTypes are the types that the values of the chart are converted
+	// to, keyed by their paths in the values. They take precedence over the
+	// value types of the Engine.
+	ValueTypes map[string]ValueType `json:"valueTypes,omitempty"`
+}
+
+// id returns the path of the chart, or its name if it's fetched from a
+// repository and has no path.
+func (c Chart) id() string {
+	if c.Path == "" && c.Repository != "" {
+		return c.Name
+	}
+	return c.Path
+}
+
+// ParseCharts parses the given YAML list of charts, typically the value of
+// ChartsAnnotationKey annotation.
+func ParseCharts(data string) ([]Chart, error) {
+	var charts []Chart
+	return charts, errors.Wrap(sigsyaml.Unmarshal([]byte(data), &charts), errParseCharts)
+}
+
 // WithResourcePath returns an Option that changes the resource path of the Engine.
 func WithResourcePath(path string) Option {
 	return func(e *Engine) {
@@ -59,6 +186,78 @@ func WithLogger(l logging.Logger) Option {
 	}
 }
 
+// WithCharts returns an Option that makes the Engine render the given charts
+// and concatenate their output instead of rendering the chart in the resource
+// path.
+func WithCharts(c ...Chart) Option {
+	return func(e *Engine) {
+		e.Charts = c
+	}
+}
+
+// WithInstallOrder returns an Option that makes the Engine return the
+// rendered resources in the order that Helm installs them, i.e. sorted by
+// kind, instead of the order they appear in the templates.
+func WithInstallOrder() Option {
+	return func(e *Engine) {
+		e.InstallOrder = true
+	}
+}
+
+// WithValuesOverride returns an Option that makes the Engine merge the values
+// in ValuesOverrideAnnotationKey annotation of the parent resource over the
+// computed values.
+func WithValuesOverride() Option {
+	return func(e *Engine) {
+		e.ValuesOverride = true
+	}
+}
+
+// WithValuesPath returns an Option that makes the Engine use the field at the
+// given dot separated path of the parent resource as values instead of the
+// whole spec.
+func WithValuesPath(path string) Option {
+	return func(e *Engine) {
+		e.ValuesPath = path
+	}
+}
+
+// WithLookup returns an Option that enables the lookup template function of
+// Helm, which reads the existing objects in the cluster with the given
+// config, e.g. one that lookup.Config returns to read them from the cache of
+// the controller. Nothing is written with the config.
+func WithLookup(cfg *rest.Config) Option {
+	return func(e *Engine) {
+		e.LookupConfig = cfg
+	}
+}
+
+// WithParentMetadata returns an Option that makes the Engine pass the
+// metadata of the parent resource to the charts in the ParentValuesKey value.
+func WithParentMetadata() Option {
+	return func(e *Engine) {
+		e.ParentMetadata = true
+	}
+}
+
+// WithValueCoercion returns an Option that makes the Engine convert the
+// values to the types that are declared in the values.schema.json file of the
+// charts, e.g. an integer field of the parent resource to a string value.
+func WithValueCoercion() Option {
+	return func(e *Engine) {
+		e.CoerceValues = true
+	}
+}
+
+// WithValueTypes returns an Option that makes the Engine convert the values
+// at the given paths to the given types. They take precedence over the types
+// in the values.schema.json file of the charts.
+func WithValueTypes(t map[string]ValueType) Option {
+	return func(e *Engine) {
+		e.ValueTypes = t
+	}
+}
+
 // NewHelm3Engine returns a new Helm3 Engine to be used as resource.TemplatingEngine.
 func NewHelm3Engine(o ...Option) *Engine {
 	h := &Engine{
@@ -73,34 +272,408 @@ func NewHelm3Engine(o ...Option) *Engine {
 // Engine is used to do the templating operation via Helm3.
 type Engine struct {
 	// ResourcePath is the folder that the base resources reside in the
-	// filesystem. It should be given as absolute path.
+	// filesystem. It should be given as absolute path. If there are no
+	// Charts, it can be the archive of the chart as well.
 	ResourcePath string
 
+	// Charts are rendered in order and their output is concatenated. If
+	// empty, the chart in ResourcePath is rendered.
+	Charts []Chart
+
+	// InstallOrder makes the Engine return the rendered resources sorted by
+	// kind as Helm does during installation. By default, the resources are
+	// returned in the order they appear in the templates.
+	InstallOrder bool
+
+	// SkipHooks makes the Engine skip the hooks of the charts. By default,
+	// the pre-install and pre-upgrade hooks are returned before the other
+	// resources and the post-install and post-upgrade ones after them.
+	SkipHooks bool
+
+	// ValuesPath is the dot separated path of the field of the parent
+	// resource that is used as values, e.g. spec.values, so that the other
+	// fields of the spec are not passed to the charts. If empty, the whole
+	// spec is used. It's not used by the charts with Bindings.
+	ValuesPath string
+
+	// DefaultValues are merged over the default values of every chart and
+	// beneath the values of the parent resources.
+	DefaultValues map[string]interface{}
+
+	// Environment is the name of the environment that the controller runs
+	// in. It's only used to report the source of the EnvironmentValues.
+	Environment string
+
+	// EnvironmentValues are merged over the values of the parent resources
+	// and beneath the values override annotation of the parent resources.
+	EnvironmentValues map[string]interface{}
+
+	// ValuesOverride makes the Engine merge the values in
+	// ValuesOverrideAnnotationKey annotation of the parent resource over the
+	// computed values of every chart.
+	ValuesOverride bool
+
+	// LookupConfig is used by the lookup template function to read the
+	// existing objects in the cluster. If nil, lookup returns an empty
+	// object as in `helm template`.
+	LookupConfig *rest.Config
+
+	// ParentMetadata makes the Engine pass the metadata of the parent
+	// resource to the charts in the ParentValuesKey value, so that the
+	// templates can derive names and selectors from it. The value is added
+	// after the values are coerced and validated by the Engine, and it's not
+	// included in the values snapshots.
+	ParentMetadata bool
+
+	// CoerceValues makes the Engine convert the values to the types that
+	// are declared in the values.schema.json file of the charts.
+	CoerceValues bool
+
+	// ValueTypes are the types that the values of every chart are converted
+	// to, keyed by their paths in the values.
+	ValueTypes map[string]ValueType
+
+	// KubeVersion is the Kubernetes version that the charts see. If nil,
+	// the discovered version or the default of Helm is used.
+	KubeVersion *chartutil.KubeVersion
+
+	// APIVersions are added to the API versions that the charts see, which
+	// are the discovered ones or the defaults of Helm.
+	APIVersions []string
+
+	// Discoverer discovers the Kubernetes version and the API versions of
+	// the cluster that the charts see. If nil, the charts see the defaults
+	// of Helm as in `helm template`.
+	Discoverer *CapabilitiesDiscoverer
+
+	// ValuesReader reads the ConfigMaps and Secrets that are referenced in
+	// the ValuesFromField of the parent resources. If nil, the field is
+	// passed to the charts as a value like any other field of the spec.
+	ValuesReader client.Reader
+
 	// debugLog is used by helm library to debugLog the debugging level logs.
 	debugLog action.DebugLog
 }
 
 // Run returns the result of the templating operation.
-func (e *Engine) Run(cr resource.ParentResource) ([]resource.ChildResource, error) {
-	values := map[string]interface{}{}
+func (e *Engine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	inputs, err := e.inputs(ctx, cr, true)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	if len(e.Charts) == 0 {
+		rawResult, warnings, err := e.template(e.ResourcePath, inputs[0])
+		if err != nil {
+			return resource.RenderResult{}, err
+		}
+		resources, err := parse([]byte(rawResult))
+		if err != nil {
+			return resource.RenderResult{}, &resource.RenderError{Err: errors.Wrap(err, errParse)}
+		}
+		hookPriorities(resources)
+		return resource.RenderResult{Children: resources, Warnings: warnings}, nil
+	}
+	result := resource.RenderResult{}
+	for _, in := range inputs {
+		rawResult, warnings, err := e.template(in.dir, in)
+		if err != nil {
+			return resource.RenderResult{}, errors.Wrapf(err, errFmtChart, in.path)
+		}
+		resources, err := parse([]byte(rawResult))
+		if err != nil {
+			return resource.RenderResult{}, errors.Wrapf(&resource.RenderError{Err: errors.Wrap(err, errParse)}, errFmtChart, in.path)
+		}
+		hookPriorities(resources)
+		result.Children = append(result.Children, resources...)
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+	return result, nil
+}
+
+// Values returns the values that the charts are rendered with for the given
+// parent resource, keyed by the chart path relative to the resource path.
+// The values that are read from the references in the ValuesFromField are not
+// included so that the content of the referenced Secrets is not exposed.
+func (e *Engine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	inputs, err := e.inputs(context.Background(), cr, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(inputs))
+	for _, in := range inputs {
+		result[in.path] = in.values
+	}
+	return result, nil
+}
+
+// chartInput is the input of the rendering of a single chart.
+type chartInput struct {
+	path        string
+	dir         string
+	releaseName string
+	values      map[string]interface{}
+	root        string
+	bindings    []v1alpha1.FieldBinding
+	types       map[string]ValueType
+	parent      map[string]interface{}
+}
+
+// inputs returns the input of every chart for the given parent resource. The
+// values of every chart are layered as documented in layer. The values that
+// are read from the references in the ValuesFromField are skipped unless
+// resolve is true.
+func (e *Engine) inputs(ctx context.Context, cr resource.ParentResource, resolve bool) ([]chartInput, error) {
+	spec := map[string]interface{}{}
 	valuesMap, exists := cr.UnstructuredContent()["spec"]
 	if exists {
 		valuesCasted, ok := valuesMap.(map[string]interface{})
 		if !ok {
-			return nil, errors.New(errSpecCast)
+			return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}
 		}
-		values = valuesCasted
+		spec = valuesCasted
 	}
-	rawResult, err := e.template(cr.GetName(), values)
+	values, err := e.rootValues(cr, spec)
 	if err != nil {
-		return nil, errors.Wrap(err, errHelm3Template)
+		return nil, err
 	}
-	resources, err := parse([]byte(rawResult))
-	return resources, errors.Wrap(err, errParse)
+	var from map[string]interface{}
+	if e.ValuesReader != nil && resolve {
+		if from, err = e.valuesFrom(ctx, cr, spec); err != nil {
+			return nil, err
+		}
+		if e.ValuesPath == "" {
+			values = withoutValuesFrom(values)
+		}
+	}
+	if len(e.Charts) == 0 {
+		values, err := e.layer(cr, from, values)
+		if err != nil {
+			return nil, err
+		}
+		return []chartInput{{path: ".", releaseName: cr.GetName(), values: values, root: e.ValuesPath, types: e.ValueTypes, parent: e.parent(cr)}}, nil
+	}
+	result := make([]chartInput, len(e.Charts))
+	for i, c := range e.Charts {
+		chartValues := values
+		if len(c.Bindings) != 0 {
+			bound, err := bind(cr, c.Bindings)
+			if err != nil {
+				return nil, errors.Wrapf(errors.Wrap(err, errBindValues), errFmtChart, c.id())
+			}
+			chartValues = bound
+		}
+		chartValues, err := e.layer(cr, from, chartValues)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtChart, c.id())
+		}
+		result[i] = chartInput{
+			path:        c.id(),
+			dir:         filepath.Join(e.ResourcePath, c.Path),
+			releaseName: cr.GetName() + c.ReleaseNameSuffix,
+			values:      chartValues,
+			root:        e.ValuesPath,
+			bindings:    c.Bindings,
+			types:       mergeTypes(e.ValueTypes, c.ValueTypes),
+			parent:      e.parent(cr),
+		}
+		switch {
+		case c.Archive != "":
+			result[i].dir = c.Archive
+		case c.Repository != "":
+			return nil, &resource.RenderError{Err: errors.Errorf(errFmtNotFetched, c.Name, c.Repository)}
+		}
+	}
+	return result, nil
+}
+
+// rootValues returns the values at the ValuesPath of the given parent
+// resource whose spec is the given one. A missing field results in empty
+// values.
+func (e *Engine) rootValues(cr resource.ParentResource, spec map[string]interface{}) (map[string]interface{}, error) {
+	if e.ValuesPath == "" {
+		return spec, nil
+	}
+	raw, exists, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(e.ValuesPath, ".")...)
+	if err != nil {
+		return nil, &resource.ValuesError{Path: e.ValuesPath, Err: err}
+	}
+	if !exists || raw == nil {
+		return map[string]interface{}{}, nil
+	}
+	values, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: e.ValuesPath, Err: errors.New(errValuesCast)}
+	}
+	return values, nil
+}
+
+// rootPath returns the given path of the values, or spec if it's empty.
+func rootPath(path string) string {
+	if path == "" {
+		return "spec"
+	}
+	return path
+}
+
+// override returns the result of merging the values in the values override
+// annotation of the given parent resource over the given values, if enabled.
+// A null value in the override removes the field. The given values are not
+// modified.
+func (e *Engine) override(cr resource.ParentResource, values map[string]interface{}) (map[string]interface{}, error) {
+	data, ok := cr.GetAnnotations()[ValuesOverrideAnnotationKey]
+	if !e.ValuesOverride || !ok {
+		return values, nil
+	}
+	override := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal([]byte(data), &override); err != nil {
+		return nil, &resource.ValuesError{Path: "metadata.annotations[" + ValuesOverrideAnnotationKey + "]", Err: errors.Wrap(err, errValuesOverride)}
+	}
+	return chartutil.CoalesceTables(override, values), nil
+}
+
+// parent returns the metadata of the given parent resource that is passed to
+// the charts, or nil if it's not enabled.
+func (e *Engine) parent(cr resource.ParentResource) map[string]interface{} {
+	if !e.ParentMetadata {
+		return nil
+	}
+	result := map[string]interface{}{
+		"apiVersion": cr.GroupVersionKind().GroupVersion().String(),
+		"kind":       cr.GroupVersionKind().Kind,
+		"name":       cr.GetName(),
+		"namespace":  cr.GetNamespace(),
+		"uid":        string(cr.GetUID()),
+	}
+	for _, f := range []string{"labels", "annotations"} {
+		m, _, _ := unstructured.NestedMap(cr.UnstructuredContent(), "metadata", f)
+		if m == nil {
+			m = map[string]interface{}{}
+		}
+		result[f] = m
+	}
+	return result
+}
+
+// withParent returns a copy of the given values with the given metadata of
+// the parent resource in the ParentValuesKey value, or the given values if
+// there is no metadata.
+func withParent(values, parent map[string]interface{}) map[string]interface{} {
+	if parent == nil {
+		return values
+	}
+	result := make(map[string]interface{}, len(values)+1)
+	for k, v := range values {
+		result[k] = v
+	}
+	result[ParentValuesKey] = parent
+	return result
+}
+
+// bind returns the values that are built by copying the fields of the parent
+// resource to the paths in values as declared by the given bindings. The
+// bindings whose source field does not exist are skipped.
+func bind(cr resource.ParentResource, bindings []v1alpha1.FieldBinding) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	for _, b := range bindings {
+		val, exists, err := unstructured.NestedFieldCopy(cr.UnstructuredContent(), strings.Split(b.From, ".")...)
+		if err != nil {
+			return nil, &resource.ValuesError{Path: b.From, Err: err}
+		}
+		if !exists {
+			continue
+		}
+		if err := unstructured.SetNestedField(values, val, strings.Split(b.To, ".")...); err != nil {
+			return nil, &resource.ValuesError{Path: b.From, Err: err}
+		}
+	}
+	return values, nil
+}
+
+// renderError returns a resource.RenderError with the template file and line
+// that are reported in the given Helm error, if any.
+func renderError(err error) error {
+	re := &resource.RenderError{Err: err}
+	m := templateLocation.FindStringSubmatch(err.Error())
+	if m == nil {
+		return re
+	}
+	re.File = m[1]
+	re.Line, _ = strconv.Atoi(m[2])
+	return re
+}
+
+// mergeTypes returns the union of the given value types. The latter ones
+// take precedence.
+func mergeTypes(types ...map[string]ValueType) map[string]ValueType {
+	result := map[string]ValueType{}
+	for _, t := range types {
+		for path, vt := range t {
+			result[path] = vt
+		}
+	}
+	return result
+}
+
+// coerce returns the values of the given input converted to the declared
+// types, if any. A value that cannot be converted results in a
+// resource.ValuesError with the path of the field of the parent resource that
+// it is built from.
+func (e *Engine) coerce(c *chart.Chart, in chartInput) (map[string]interface{}, error) {
+	if !e.CoerceValues && len(in.types) == 0 {
+		return in.values, nil
+	}
+	var schema []byte
+	if e.CoerceValues {
+		schema = c.Schema
+	}
+	values, err := coercer{types: in.types}.coerce(in.values, schema)
+	var ce *coerceError
+	switch {
+	case errors.As(err, &ce):
+		return nil, &resource.ValuesError{Path: fieldPath(in.root, in.bindings, ce.path), Err: ce.err}
+	case err != nil:
+		return nil, &resource.RenderError{Err: err}
+	}
+	return values, nil
 }
 
-func (e *Engine) template(releaseName string, values map[string]interface{}) (string, error) {
-	chart, err := loader.Load(e.ResourcePath)
+// template renders the chart in the given path with the given input and
+// returns the manifest along with the warnings about the chart. The errors
+// are either a resource.RenderError or a resource.ValuesError.
+func (e *Engine) template(chartPath string, in chartInput) (string, []string, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return "", nil, renderError(errors.Wrap(err, errHelm3Template))
+	}
+	values, err := e.coerce(c, in)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := validate(c, in, values); err != nil {
+		return "", nil, err
+	}
+	manifest, err := e.install(c, in.releaseName, withParent(values, in.parent))
+	if err != nil {
+		return "", nil, renderError(errors.Wrap(err, errHelm3Template))
+	}
+	return manifest, deprecations(c), nil
+}
+
+// deprecations returns a warning for the given chart and every subchart of
+// it that is marked as deprecated in its Chart.yaml.
+func deprecations(c *chart.Chart) []string {
+	var result []string
+	if c.Metadata != nil && c.Metadata.Deprecated {
+		result = append(result, fmt.Sprintf(errFmtDeprecated, c.Name()))
+	}
+	for _, d := range c.Dependencies() {
+		result = append(result, deprecations(d)...)
+	}
+	return result
+}
+
+func (e *Engine) install(c *chart.Chart, releaseName string, values map[string]interface{}) (string, error) {
+	caps, err := e.capabilities()
 	if err != nil {
 		return "", err
 	}
@@ -108,7 +681,11 @@ func (e *Engine) template(releaseName string, values map[string]interface{}) (st
 	// NOTE(muvaf): RESTGetter is skipped because we don't need to talk with cluster.
 	// namespace is skipped because we use "memory" as storage rather than actual
 	// ConfigMap or Secret objects.
-	if err := config.Init(nil, "", "memory", e.debugLog); err != nil {
+	var getter genericclioptions.RESTClientGetter
+	if e.LookupConfig != nil {
+		getter = restConfigGetter{config: e.LookupConfig}
+	}
+	if err := config.Init(getter, "", "memory", e.debugLog); err != nil {
 		return "", err
 	}
 
@@ -120,11 +697,102 @@ func (e *Engine) template(releaseName string, values map[string]interface{}) (st
 	i.Replace = true
 	i.ClientOnly = true
 
-	release, err := i.Run(chart, values)
+	// NOTE: Helm renders with a cluster connection, which lookup needs, only
+	// if it's not a dry run. Since the Kubernetes client of a client-only
+	// install is a fake that discards everything and the release is stored
+	// in memory, nothing is written to the cluster either way.
+	if e.LookupConfig != nil {
+		i.DryRun = false
+	}
+
+	// NOTE: Helm replaces the capabilities with its defaults in client-only
+	// installs, so the configured ones are used with an equivalent setup
+	// instead. The CRDs are skipped since they're not rendered by a
+	// client-only install either.
+	if caps != nil {
+		i.ClientOnly = false
+		i.SkipCRDs = true
+		config.Capabilities = caps
+		config.KubeClient = &kubefake.PrintingKubeClient{Out: ioutil.Discard}
+	}
+
+	release, err := i.Run(c, values)
 	if err != nil {
 		return "", err
 	}
-	return release.Manifest, nil
+	manifest := release.Manifest
+	if !e.InstallOrder {
+		manifest = documentOrder(manifest)
+	}
+	if e.SkipHooks {
+		return manifest, nil
+	}
+	return withHooks(release.Hooks, manifest), nil
+}
+
+// sourceHeader is the header that Helm writes before every document of the
+// release manifest.
+var sourceHeader = regexp.MustCompile(`(?m)^---\n# Source: (.*)\n`)
+
+// templateLocation matches the template file and line in the errors of the
+// template engine, e.g. "template: chart/templates/a.yaml:12:3: ..." or
+// "parse error at (chart/templates/a.yaml:12): ...".
+var templateLocation = regexp.MustCompile(`(?:template: |at \()([^:()\s]+):(\d+)`)
+
+// documentOrder reorders the documents of the given release manifest so that
+// they follow the order of the template files and the order of documents
+// within them, undoing the kind based sorting of Helm.
+func documentOrder(manifest string) string {
+	type document struct {
+		source  string
+		content string
+	}
+	locs := sourceHeader.FindAllStringSubmatchIndex(manifest, -1)
+	docs := make([]document, len(locs))
+	for i, loc := range locs {
+		end := len(manifest)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		docs[i] = document{source: manifest[loc[2]:loc[3]], content: manifest[loc[0]:end]}
+	}
+	// NOTE: Helm processes the template files in lexical order and the sort
+	// by kind is stable, so the documents of the same file are still in their
+	// original order.
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].source < docs[j].source
+	})
+	b := &strings.Builder{}
+	if len(locs) != 0 {
+		b.WriteString(manifest[:locs[0][0]])
+	}
+	for _, d := range docs {
+		b.WriteString(d.content)
+	}
+	return b.String()
+}
+
+// restConfigGetter is a genericclioptions.RESTClientGetter that serves only
+// the REST config, which is all Helm needs for the lookup function during a
+// client-only install.
+type restConfigGetter struct {
+	config *rest.Config
+}
+
+func (g restConfigGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.config, nil
+}
+
+func (g restConfigGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return nil, errors.New(errClientOnly)
+}
+
+func (g restConfigGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	return nil, errors.New(errClientOnly)
+}
+
+func (g restConfigGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return nil
 }
 
 func parse(source []byte) ([]resource.ChildResource, error) {
@@ -17,6 +17,7 @@ limitations under the License.
 package helm3
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -25,9 +26,14 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/fixture"
 	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
 )
 
 const testYAMLDir = "../../../test/helm3"
@@ -39,6 +45,32 @@ var errContains = cmp.Comparer(func(a, b error) bool {
 	return strings.Contains(a.Error(), b.Error()) || strings.Contains(b.Error(), a.Error())
 })
 
+func TestParseCharts(t *testing.T) {
+	data := `
+- path: frontend
+  releaseNameSuffix: -frontend
+  bindings:
+  - from: spec.frontend.replicas
+    to: replicaCount
+- path: backend
+`
+	want := []Chart{
+		{
+			Path:              "frontend",
+			ReleaseNameSuffix: "-frontend",
+			Bindings:          []v1alpha1.FieldBinding{{From: "spec.frontend.replicas", To: "replicaCount"}},
+		},
+		{Path: "backend"},
+	}
+	got, err := ParseCharts(data)
+	if err != nil {
+		t.Fatalf("ParseCharts(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseCharts(...): -want, +got:\n%s", diff)
+	}
+}
+
 func TestRun(t *testing.T) {
 	testYaml, err := ioutil.ReadFile(filepath.Join(testYAMLDir, "test-cr.yaml"))
 	if err != nil {
@@ -82,7 +114,7 @@ func TestRun(t *testing.T) {
 				e: NewHelm3Engine(),
 			},
 			want: want{
-				errContains: errors.New(errSpecCast),
+				errContains: &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)},
 			},
 		},
 		"TemplateFailed": {
@@ -91,7 +123,7 @@ func TestRun(t *testing.T) {
 				e:  NewHelm3Engine(WithResourcePath("/i-dont-exist")),
 			},
 			want: want{
-				errContains: errors.Wrap(fmt.Errorf(""), errHelm3Template),
+				errContains: &resource.RenderError{Err: errors.Wrap(fmt.Errorf(""), errHelm3Template)},
 			},
 		},
 		"Success": {
@@ -104,18 +136,325 @@ func TestRun(t *testing.T) {
 				errContains: nil,
 			},
 		},
+		"ValuesOverridden": {
+			args: args{
+				cr: withAnnotations(parentCR, map[string]string{ValuesOverrideAnnotationKey: `engineVersion: mysql-8`}),
+				e:  NewHelm3Engine(WithResourcePath(filepath.Join(testYAMLDir, "helm-chart")), WithValuesOverride()),
+			},
+			want: want{
+				result: []resource.ChildResource{withEngineVersion(results[0], "mysql-8")},
+			},
+		},
+		"ValuesOverrideNotAllowed": {
+			args: args{
+				cr: withAnnotations(parentCR, map[string]string{ValuesOverrideAnnotationKey: `engineVersion: mysql-8`}),
+				e:  NewHelm3Engine(WithResourcePath(filepath.Join(testYAMLDir, "helm-chart"))),
+			},
+			want: want{
+				result: results,
+			},
+		},
+		"MultipleCharts": {
+			args: args{
+				cr: parentCR,
+				e: NewHelm3Engine(
+					WithResourcePath(testYAMLDir),
+					WithCharts(
+						Chart{Path: "helm-chart"},
+						Chart{
+							Path:              "helm-chart",
+							ReleaseNameSuffix: "-replica",
+							Bindings:          []v1alpha1.FieldBinding{{From: "metadata.name", To: "engineVersion"}},
+						},
+					),
+				),
+			},
+			want: want{
+				result: append(results, &unstructured.Unstructured{
+					Object: map[string]interface{}{
+						"apiVersion": "database.crossplane.io/v1alpha1",
+						"kind":       "MySQLInstance",
+						"metadata": map[string]interface{}{
+							"name": "test-replica-sql",
+						},
+						"spec": map[string]interface{}{
+							"engineVersion": "test",
+							"writeConnectionSecretToRef": map[string]interface{}{
+								"name": "sql",
+							},
+						},
+					},
+				}),
+			},
+		},
+		"ChartNotFound": {
+			args: args{
+				cr: parentCR,
+				e:  NewHelm3Engine(WithResourcePath(testYAMLDir), WithCharts(Chart{Path: "i-dont-exist"})),
+			},
+			want: want{
+				errContains: errors.Wrap(fmt.Errorf(""), errHelm3Template),
+			},
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			got, err := tc.args.e.Run(tc.args.cr)
+			got, err := tc.args.e.Run(context.Background(), tc.args.cr)
 			if diff := cmp.Diff(tc.want.errContains, err, errContains); diff != "" {
 				// NOTE(muvaf): Some functions return errors from syscalls , we
 				// are not able to construct them.
 				t.Errorf("Run(...): -want, +got:\n%s", diff)
 			}
-			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+			if diff := cmp.Diff(tc.want.result, got.Children); diff != "" {
 				t.Errorf("Run(...): -want, +got:\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestFixtures(t *testing.T) {
+	cases, err := fixture.Load(filepath.Join(testYAMLDir, "tests"))
+	if err != nil {
+		t.Fatalf("fixture.Load(...): %v", err)
+	}
+	r := fixture.NewRunner(func(_ *v1alpha1.StackDefinition) (templating.Engine, error) {
+		return NewHelm3Engine(WithResourcePath(filepath.Join(testYAMLDir, "helm-chart"))), nil
+	})
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			res := r.Run(c)
+			if res.Err != nil {
+				t.Fatalf("Run(...): %v", res.Err)
+			}
+			if res.Diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", res.Diff)
+			}
+		})
+	}
+}
+
+func withAnnotations(cr resource.ParentResource, a map[string]string) resource.ParentResource {
+	cp := cr.DeepCopyObject().(resource.ParentResource)
+	cp.SetAnnotations(a)
+	return cp
+}
+
+func withEngineVersion(o resource.ChildResource, v string) resource.ChildResource {
+	cp := o.DeepCopyObject().(*unstructured.Unstructured)
+	_ = unstructured.SetNestedField(cp.Object, v, "spec", "engineVersion")
+	return cp
+}
+
+func TestDocumentOrder(t *testing.T) {
+	manifest := `---
+# Source: stack/templates/namespace.yaml
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: b
+---
+# Source: stack/templates/app.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+# Source: stack/templates/namespace.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`
+	want := `---
+# Source: stack/templates/app.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+# Source: stack/templates/namespace.yaml
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: b
+---
+# Source: stack/templates/namespace.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`
+	if diff := cmp.Diff(want, documentOrder(manifest)); diff != "" {
+		t.Errorf("documentOrder(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want *resource.RenderError
+	}{
+		"ExecutionError": {
+			err:  errors.New(`template: wordpress/templates/deployment.yaml:12:20: executing "wordpress/templates/deployment.yaml" at <.Values.image.tag>: nil pointer evaluating interface {}.tag`),
+			want: &resource.RenderError{File: "wordpress/templates/deployment.yaml", Line: 12},
+		},
+		"ParseError": {
+			err:  errors.New(`parse error at (wordpress/templates/service.yaml:5): function "foo" not defined`),
+			want: &resource.RenderError{File: "wordpress/templates/service.yaml", Line: 5},
+		},
+		"NoLocation": {
+			err:  errors.New("no Chart.yaml exists in directory"),
+			want: &resource.RenderError{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := renderError(tc.err).(*resource.RenderError)
+			if !ok {
+				t.Fatalf("renderError(...): want *resource.RenderError, got %T", got)
+			}
+			tc.want.Err = tc.err
+			if diff := cmp.Diff(tc.want, got, errContains); diff != "" {
+				t.Errorf("renderError(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParentMetadata(t *testing.T) {
+	cr := &unstructured.Unstructured{}
+	cr.SetAPIVersion("wordpress.samples.stacks.crossplane.io/v1alpha1")
+	cr.SetKind("WordpressInstance")
+	cr.SetName("wp")
+	cr.SetNamespace("default")
+	cr.SetUID("5c2d")
+	cr.SetLabels(map[string]string{"team": "blog"})
+	values := map[string]interface{}{"replicas": int64(2)}
+
+	cases := map[string]struct {
+		reason string
+		e      *Engine
+		want   map[string]interface{}
+	}{
+		"Disabled": {
+			reason: "The values should be passed as they are if the parent metadata is not enabled.",
+			e:      NewHelm3Engine(),
+			want:   map[string]interface{}{"replicas": int64(2)},
+		},
+		"Enabled": {
+			reason: "The metadata of the parent resource should be added to the values.",
+			e:      NewHelm3Engine(WithParentMetadata()),
+			want: map[string]interface{}{
+				"replicas": int64(2),
+				ParentValuesKey: map[string]interface{}{
+					"apiVersion":  "wordpress.samples.stacks.crossplane.io/v1alpha1",
+					"kind":        "WordpressInstance",
+					"name":        "wp",
+					"namespace":   "default",
+					"uid":         "5c2d",
+					"labels":      map[string]interface{}{"team": "blog"},
+					"annotations": map[string]interface{}{},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := withParent(values, tc.e.parent(cr))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nwithParent(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if _, ok := values[ParentValuesKey]; ok {
+				t.Errorf("\n%s\nwithParent(...): the given values should not be modified", tc.reason)
+			}
+		})
+	}
+}
+
+func TestValuesPath(t *testing.T) {
+	parent := func(spec interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	}
+	type want struct {
+		values map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		path   string
+		cr     resource.ParentResource
+		want   want
+	}{
+		"Spec": {
+			reason: "The whole spec should be used as values if there is no values path.",
+			cr:     parent(map[string]interface{}{"targetRef": "a", "values": map[string]interface{}{"replicas": int64(2)}}),
+			want:   want{values: map[string]interface{}{".": map[string]interface{}{"targetRef": "a", "values": map[string]interface{}{"replicas": int64(2)}}}},
+		},
+		"Subtree": {
+			reason: "Only the field at the values path should be used as values.",
+			path:   "spec.values",
+			cr:     parent(map[string]interface{}{"targetRef": "a", "values": map[string]interface{}{"replicas": int64(2)}}),
+			want:   want{values: map[string]interface{}{".": map[string]interface{}{"replicas": int64(2)}}},
+		},
+		"Missing": {
+			reason: "A missing field at the values path should result in empty values.",
+			path:   "spec.values",
+			cr:     parent(map[string]interface{}{"targetRef": "a"}),
+			want:   want{values: map[string]interface{}{".": map[string]interface{}{}}},
+		},
+		"NotAnObject": {
+			reason: "A field at the values path that is not an object should be reported.",
+			path:   "spec.values",
+			cr:     parent(map[string]interface{}{"values": "replicas=2"}),
+			want:   want{err: &resource.ValuesError{Path: "spec.values", Err: errors.New(errValuesCast)}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewHelm3Engine(WithValuesPath(tc.path)).Values(tc.cr)
+			if diff := cmp.Diff(fmt.Sprint(tc.want.err), fmt.Sprint(err)); diff != "" {
+				t.Errorf("\n%s\nValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.values, got); diff != "" {
+				t.Errorf("\n%s\nValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeprecations(t *testing.T) {
+	newChart := func(name string, deprecated bool) *chart.Chart {
+		return &chart.Chart{Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "0.1.0", Deprecated: deprecated}}
+	}
+	cases := map[string]struct {
+		reason string
+		chart  *chart.Chart
+		want   []string
+	}{
+		"NotDeprecated": {
+			reason: "A chart that is not deprecated should not result in a warning.",
+			chart:  newChart("app", false),
+		},
+		"Deprecated": {
+			reason: "A deprecated chart should result in a warning.",
+			chart:  newChart("app", true),
+			want:   []string{fmt.Sprintf(errFmtDeprecated, "app")},
+		},
+		"DeprecatedSubchart": {
+			reason: "Every deprecated subchart should result in a warning.",
+			chart: func() *chart.Chart {
+				c := newChart("app", false)
+				c.AddDependency(newChart("db", true), newChart("cache", false))
+				return c
+			}(),
+			want: []string{fmt.Sprintf(errFmtDeprecated, "db")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, deprecations(tc.chart)); diff != "" {
+				t.Errorf("\n%s\ndeprecations(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+const (
+	// SkipHooksAnnotationKey is the annotation on the StackDefinition that
+	// makes the Engine skip the hooks of the charts when its value is "true".
+	SkipHooksAnnotationKey = "templatestacks.crossplane.io/helm3-skip-hooks"
+
+	// PreHookDeletionPriority is the deletion priority of the pre-install and
+	// pre-upgrade hooks, which are deleted after the other child resources.
+	PreHookDeletionPriority = "-1"
+
+	// PostHookDeletionPriority is the deletion priority of the post-install
+	// and post-upgrade hooks, which are deleted before the other child
+	// resources.
+	PostHookDeletionPriority = "1"
+)
+
+// WithoutHooks returns an Option that makes the Engine skip the hooks of the
+// charts.
+func WithoutHooks() Option {
+	return func(e *Engine) {
+		e.SkipHooks = true
+	}
+}
+
+// withHooks returns the given release manifest with the manifests of the
+// given hooks of the install and upgrade events. The pre-install and
+// pre-upgrade hooks come before the manifest and the post-install and
+// post-upgrade ones after it, sorted by their weights as Helm runs them. The
+// hooks of the other events, e.g. pre-delete and test, are skipped since the
+// controller has no equivalent of them.
+func withHooks(hooks []*release.Hook, manifest string) string {
+	var pre, post []*release.Hook
+	for _, h := range hooks {
+		switch {
+		case hasEvent(h, release.HookPreInstall, release.HookPreUpgrade):
+			pre = append(pre, h)
+		case hasEvent(h, release.HookPostInstall, release.HookPostUpgrade):
+			post = append(post, h)
+		}
+	}
+	b := &strings.Builder{}
+	for _, h := range sortHooks(pre) {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	b.WriteString(manifest)
+	for _, h := range sortHooks(post) {
+		fmt.Fprintf(b, "---\n# Source: %s\n%s\n", h.Path, h.Manifest)
+	}
+	return b.String()
+}
+
+// hookPriorities sets the deletion priority of the given child resources that
+// are hooks, unless their templates set one.
+func hookPriorities(list []resource.ChildResource) {
+	for _, o := range list {
+		events, ok := o.GetAnnotations()[release.HookAnnotation]
+		if !ok {
+			continue
+		}
+		if _, ok := o.GetAnnotations()[templating.DeletionPriorityAnnotationKey]; ok {
+			continue
+		}
+		p := PostHookDeletionPriority
+		for _, e := range strings.Split(events, ",") {
+			if e = strings.TrimSpace(e); e == release.HookPreInstall.String() || e == release.HookPreUpgrade.String() {
+				p = PreHookDeletionPriority
+				break
+			}
+		}
+		meta.AddAnnotations(o, map[string]string{templating.DeletionPriorityAnnotationKey: p})
+	}
+}
+
+func hasEvent(h *release.Hook, events ...release.HookEvent) bool {
+	for _, e := range h.Events {
+		for _, want := range events {
+			if e == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortHooks sorts the given hooks by their weights, and by their names if
+// their weights are the same.
+func sortHooks(hooks []*release.Hook) []*release.Hook {
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].Weight != hooks[j].Weight {
+			return hooks[i].Weight < hooks[j].Weight
+		}
+		return hooks[i].Name < hooks[j].Name
+	})
+	return hooks
+}
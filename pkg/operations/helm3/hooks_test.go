@@ -0,0 +1,146 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+func TestWithHooks(t *testing.T) {
+	hook := func(name string, weight int, events ...release.HookEvent) *release.Hook {
+		return &release.Hook{
+			Name:     name,
+			Path:     "stack/templates/" + name + ".yaml",
+			Manifest: "kind: Job\nmetadata:\n  name: " + name,
+			Weight:   weight,
+			Events:   events,
+		}
+	}
+	hooks := []*release.Hook{
+		hook("notify", 0, release.HookPostInstall, release.HookPostUpgrade),
+		hook("migrate", 5, release.HookPreInstall, release.HookPreUpgrade),
+		hook("cleanup", 0, release.HookPreDelete),
+		hook("secret", -5, release.HookPreInstall),
+		hook("test", 0, release.HookTest),
+	}
+	manifest := "---\n# Source: stack/templates/app.yaml\nkind: Deployment\nmetadata:\n  name: app\n"
+	want := `---
+# Source: stack/templates/secret.yaml
+kind: Job
+metadata:
+  name: secret
+---
+# Source: stack/templates/migrate.yaml
+kind: Job
+metadata:
+  name: migrate
+---
+# Source: stack/templates/app.yaml
+kind: Deployment
+metadata:
+  name: app
+---
+# Source: stack/templates/notify.yaml
+kind: Job
+metadata:
+  name: notify
+`
+	if diff := cmp.Diff(want, withHooks(hooks, manifest)); diff != "" {
+		t.Errorf("withHooks(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestHookPriorities(t *testing.T) {
+	withAnnotations := func(a map[string]string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetAnnotations(a)
+		return u
+	}
+	list := []resource.ChildResource{
+		withAnnotations(map[string]string{release.HookAnnotation: "pre-install,pre-upgrade"}),
+		withAnnotations(map[string]string{release.HookAnnotation: "post-install"}),
+		withAnnotations(map[string]string{release.HookAnnotation: "post-install", templating.DeletionPriorityAnnotationKey: "7"}),
+		withAnnotations(nil),
+	}
+	hookPriorities(list)
+	want := []string{PreHookDeletionPriority, PostHookDeletionPriority, "7", ""}
+	got := make([]string, len(list))
+	for i, o := range list {
+		got[i] = o.GetAnnotations()[templating.DeletionPriorityAnnotationKey]
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("hookPriorities(...): -want priorities, +got priorities:\n%s", diff)
+	}
+}
+
+func TestHookApplyOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	hook := func(kind, name, events, weight string) string {
+		return "apiVersion: v1\nkind: " + kind + "\nmetadata:\n  name: " + name + "\n  annotations:\n" +
+			"    helm.sh/hook: " + events + "\n    helm.sh/hook-weight: \"" + weight + "\"\n"
+	}
+	files := map[string]string{
+		"Chart.yaml":             "apiVersion: v2\nname: stack\nversion: 0.1.0\n",
+		"templates/app.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n",
+		"templates/config.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a-config\n",
+		"templates/migrate.yaml": hook("ConfigMap", "migrate", "pre-install,pre-upgrade", "5"),
+		"templates/secret.yaml":  hook("Secret", "z-secret", "pre-install", "-5"),
+		"templates/notify.yaml":  hook("ConfigMap", "a-notify", "post-install", "0"),
+	}
+	for f, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cr := fake.NewMockResource(fake.WithNamespaceName("cool", "default"))
+	res, err := NewHelm3Engine(WithResourcePath(dir)).Run(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("Run(...): %s", err)
+	}
+	list, err := templating.DefaultChildResourcePatchers().Patch(context.Background(), cr, res.Children)
+	if err != nil {
+		t.Fatalf("Patch(...): %s", err)
+	}
+	got := make([]string, len(list))
+	for i, o := range list {
+		got[i] = o.GetName()
+	}
+	want := []string{"z-secret", "migrate", "a-config", "app", "a-notify"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Patch(...): the pre hooks should be applied first and the post hooks last: -want, +got:\n%s", diff)
+	}
+}
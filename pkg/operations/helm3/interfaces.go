@@ -18,3 +18,6 @@ package helm3
 
 // Option is used to manipulate the given *Engine instance.
 type Option func(*Engine)
+
+// FetcherOption is used to manipulate the given *Fetcher instance.
+type FetcherOption func(*Fetcher)
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// DefaultValuesAnnotationKey is the annotation on the StackDefinition
+	// whose value is a YAML or JSON document of values that is merged over
+	// the default values of the charts and beneath the values of the parent
+	// resources.
+	DefaultValuesAnnotationKey = "templatestacks.crossplane.io/helm3-default-values"
+
+	// EnvironmentValuesAnnotationKey is the annotation on the StackDefinition
+	// whose value is a YAML or JSON document of values per environment, keyed
+	// by the name of the environment. The values of the environment that the
+	// controller runs in are merged over the values of the parent resources.
+	EnvironmentValuesAnnotationKey = "templatestacks.crossplane.io/helm3-environment-values"
+
+	errParseValues            = "could not parse the values"
+	errFmtParseEnvValues      = "could not parse the values of environment %s"
+	errParseEnvironmentValues = "could not parse the values per environment"
+)
+
+// ParseValues parses the given YAML or JSON document of values, typically
+// the value of DefaultValuesAnnotationKey annotation.
+func ParseValues(data string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	return values, errors.Wrap(sigsyaml.Unmarshal([]byte(data), &values), errParseValues)
+}
+
+// ParseEnvironmentValues parses the given YAML or JSON document of values
+// per environment, typically the value of EnvironmentValuesAnnotationKey
+// annotation, and returns the values of the given environment, or nil if it
+// has none.
+func ParseEnvironmentValues(data, env string) (map[string]interface{}, error) {
+	envs := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal([]byte(data), &envs); err != nil {
+		return nil, errors.Wrap(err, errParseEnvironmentValues)
+	}
+	raw, ok := envs[env]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf(errFmtParseEnvValues, env)
+	}
+	return values, nil
+}
+
+// WithDefaultValues returns an Option that makes the Engine merge the given
+// values over the default values of the charts and beneath the values of the
+// parent resources.
+func WithDefaultValues(values map[string]interface{}) Option {
+	return func(e *Engine) {
+		e.DefaultValues = values
+	}
+}
+
+// WithEnvironmentValues returns an Option that makes the Engine merge the
+// given values of the given environment over the values of the parent
+// resources.
+func WithEnvironmentValues(env string, values map[string]interface{}) Option {
+	return func(e *Engine) {
+		e.Environment = env
+		e.EnvironmentValues = values
+	}
+}
+
+// layer returns the values of a chart for the given parent resource that are
+// built by merging the layers of values in increasing order of precedence:
+//
+//  1. The default values of the StackDefinition.
+//  2. The given values that are read from the references in the
+//     ValuesFromField.
+//  3. The given values of the parent resource, i.e. its spec, the field at
+//     the ValuesPath or the bindings of the chart.
+//  4. The values of the environment.
+//  5. The values override annotation of the parent resource.
+//
+// The default values of the chart are merged beneath all of them by Helm. A
+// null value in a layer removes the field of the layers beneath it. None of
+// the given maps is modified.
+func (e *Engine) layer(cr resource.ParentResource, from, values map[string]interface{}) (map[string]interface{}, error) {
+	result := values
+	if len(e.DefaultValues) != 0 || len(from) != 0 || len(e.EnvironmentValues) != 0 {
+		result = map[string]interface{}{}
+		for _, l := range []map[string]interface{}{e.DefaultValues, from, values, e.EnvironmentValues} {
+			if len(l) != 0 {
+				result = chartutil.CoalesceTables(runtime.DeepCopyJSON(l), result)
+			}
+		}
+	}
+	return e.override(cr, result)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseEnvironmentValues(t *testing.T) {
+	data := `
+prod:
+  replicas: 3
+staging: {}
+broken: 3
+`
+	type want struct {
+		values map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		env    string
+		want   want
+	}{
+		"Found": {
+			reason: "The values of the given environment should be returned.",
+			env:    "prod",
+			want:   want{values: map[string]interface{}{"replicas": float64(3)}},
+		},
+		"NotFound": {
+			reason: "An environment without values should have no values.",
+			env:    "dev",
+		},
+		"NotObject": {
+			reason: "The values of an environment that are not an object should be rejected.",
+			env:    "broken",
+			want:   want{err: errors.Errorf(errFmtParseEnvValues, "broken")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEnvironmentValues(data, tc.env)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseEnvironmentValues(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.values, got); diff != "" {
+				t.Errorf("\n%s\nParseEnvironmentValues(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestLayer(t *testing.T) {
+	cr := &unstructured.Unstructured{}
+	cr.SetAnnotations(map[string]string{ValuesOverrideAnnotationKey: "image: {repository: mirror/app}"})
+	from := map[string]interface{}{"replicas": int64(2), "region": "us-east-1"}
+	values := map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}, "replicas": int64(4)}
+
+	cases := map[string]struct {
+		reason string
+		e      *Engine
+		from   map[string]interface{}
+		want   map[string]interface{}
+	}{
+		"ValuesOnly": {
+			reason: "The values should be used as they are if there are no other layers.",
+			e:      NewHelm3Engine(),
+			want:   values,
+		},
+		"AllLayers": {
+			reason: "The layers should be merged in increasing order of precedence.",
+			e: NewHelm3Engine(
+				WithValuesOverride(),
+				WithDefaultValues(map[string]interface{}{
+					"image":    map[string]interface{}{"repository": "app", "tag": "1.0"},
+					"replicas": int64(1),
+					"debug":    true,
+				}),
+				WithEnvironmentValues("prod", map[string]interface{}{"replicas": int64(5), "debug": nil}),
+			),
+			from: from,
+			want: map[string]interface{}{
+				"image":    map[string]interface{}{"repository": "mirror/app", "tag": "2.0"},
+				"replicas": int64(5),
+				"region":   "us-east-1",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.e.layer(cr, tc.from, values)
+			if err != nil {
+				t.Fatalf("\n%s\nlayer(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nlayer(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+	if diff := cmp.Diff(map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}, "replicas": int64(4)}, values); diff != "" {
+		t.Errorf("layer(...): the given values should not be modified: -want, +got:\n%s", diff)
+	}
+}
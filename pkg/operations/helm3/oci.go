@@ -0,0 +1,222 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ociScheme = "oci://"
+
+	// OCIManifestMediaType is the media type of the manifests of the charts
+	// in OCI registries.
+	OCIManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+	// OCIChartLayerMediaType is the media type of the layer that contains
+	// the archive of a chart in an OCI registry.
+	OCIChartLayerMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	// OCILegacyChartLayerMediaType is the media type of the layer that
+	// contains the archive of a chart that is pushed by the experimental OCI
+	// support of Helm 3.0 to 3.6.
+	OCILegacyChartLayerMediaType = "application/tar+gzip"
+
+	errOCIVersion      = "a chart in an OCI registry requires an exact version"
+	errOCIReference    = "the repository of the chart must be in oci://host/path form"
+	errFmtNoChartLayer = "the manifest of the chart has no layer of %s type"
+	errFmtLayerDigest  = "digest of the chart is %s, but the manifest of the chart says %s"
+	errParseManifest   = "cannot parse the manifest of the chart"
+	errFetchManifest   = "cannot fetch the manifest of the chart"
+	errAuthenticate    = "cannot authenticate to the registry"
+	errParseToken      = "cannot parse the token of the registry"
+)
+
+// ociManifest is the part of an OCI image manifest that locates the layers.
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// fetchOCI returns the path of the cached archive of the given chart in an
+// OCI registry, pulling it first if it's not cached. The chart is pulled from
+// the repository that is the Repository of the chart followed by its Name,
+// with its Version as the tag.
+func (f *Fetcher) fetchOCI(ctx context.Context, c Chart) (string, error) {
+	if c.Version == "" {
+		return "", errors.New(errOCIVersion)
+	}
+	cached := f.cachePath(c.Repository, c.Name, c.Version)
+	if exists(cached) {
+		return cached, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(c.Repository, ociScheme), "/", 2)
+	if parts[0] == "" {
+		return "", errors.New(errOCIReference)
+	}
+	r := &registry{client: f.Client, host: parts[0], repository: c.Name}
+	if len(parts) == 2 {
+		r.repository = path.Join(parts[1], c.Name)
+	}
+	cr, err := f.credentials(ctx, c, r.host)
+	if err != nil {
+		return "", err
+	}
+	r.user, r.pass = cr.Username, cr.Password
+	data, err := r.get(ctx, "manifests/"+c.Version, OCIManifestMediaType)
+	if err != nil {
+		return "", errors.Wrap(err, errFetchManifest)
+	}
+	m := &ociManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return "", errors.Wrap(err, errParseManifest)
+	}
+	var layer *ociDescriptor
+	for i, l := range m.Layers {
+		if l.MediaType == OCIChartLayerMediaType || l.MediaType == OCILegacyChartLayerMediaType {
+			layer = &m.Layers[i]
+			break
+		}
+	}
+	if layer == nil {
+		return "", errors.Errorf(errFmtNoChartLayer, OCIChartLayerMediaType)
+	}
+	data, err = r.get(ctx, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return "", errors.Wrap(err, errFetchChart)
+	}
+	if sum := sha256.Sum256(data); "sha256:"+hex.EncodeToString(sum[:]) != layer.Digest {
+		return "", errors.Errorf(errFmtLayerDigest, "sha256:"+hex.EncodeToString(sum[:]), layer.Digest)
+	}
+	return cached, errors.Wrap(writeAtomically(cached, data), errCacheChart)
+}
+
+// A registry pulls from a repository of an OCI registry with the token
+// authentication of the Docker registry API, which the major registries
+// implement.
+type registry struct {
+	client     *http.Client
+	host       string
+	repository string
+	user       string
+	pass       string
+
+	// authorization is the value of the Authorization header, once the
+	// registry asked for one.
+	authorization string
+}
+
+// challengeParam matches the parameters of a WWW-Authenticate header.
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// get returns the content of the given path of the repository, e.g.
+// manifests/1.2.0, authenticating if the registry asks for it.
+func (r *registry) get(ctx context.Context, p, accept string) ([]byte, error) {
+	u := "https://" + r.host + "/v2/" + r.repository + "/" + p
+	resp, err := r.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode == http.StatusUnauthorized && r.authorization == "" {
+		if err := r.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, errors.Wrap(err, errAuthenticate)
+		}
+		return r.get(ctx, p, accept)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(errFmtStatus, u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (r *registry) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+	return r.client.Do(req)
+}
+
+// authenticate sets the authorization for the given challenge of the
+// registry. A bearer challenge is answered with a token from the realm of
+// the challenge, which is requested with the credentials, if any.
+func (r *registry) authenticate(ctx context.Context, challenge string) error {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		r.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(r.user+":"+r.pass))
+		return nil
+	}
+	params := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	q := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := params[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if r.user != "" || r.pass != "" {
+		req.SetBasicAuth(r.user, r.pass)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(errFmtStatus, params["realm"], resp.Status)
+	}
+	t := &struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(t); err != nil {
+		return errors.Wrap(err, errParseToken)
+	}
+	if t.Token == "" {
+		t.Token = t.AccessToken
+	}
+	r.authorization = "Bearer " + t.Token
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestFetchOCI(t *testing.T) {
+	archive := []byte("not really a chart")
+	sum := sha256.Sum256(archive)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if u, p, ok := r.BasicAuth(); !ok || u != "cool" || p != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if diff := cmp.Diff("repository:charts/wordpress:pull", r.URL.Query().Get("scope")); diff != "" {
+				t.Errorf("token request: -want scope, +got scope:\n%s", diff)
+			}
+			_, _ = w.Write([]byte(`{"token": "t0k3n"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:charts/wordpress:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/charts/wordpress/manifests/1.2.0":
+			fmt.Fprintf(w, `{"layers": [{"mediaType": "%s", "digest": "%s"}]}`, OCIChartLayerMediaType, digest)
+		case "/v2/charts/wordpress/manifests/1.1.0":
+			fmt.Fprintf(w, `{"layers": [{"mediaType": "%s", "digest": "sha256:bad"}]}`, OCIChartLayerMediaType)
+		case "/v2/charts/wordpress/blobs/" + digest, "/v2/charts/wordpress/blobs/sha256:bad":
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "charts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	host := strings.TrimPrefix(srv.URL, "https://")
+	secrets := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		if key.Namespace != "cool-ns" || key.Name != "pull" {
+			return errors.New("not found")
+		}
+		obj.(*corev1.Secret).Data = map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(fmt.Sprintf(`{"auths": {"%s": {"auth": "Y29vbDpzZWNyZXQ="}}}`, host)),
+		}
+		return nil
+	}}
+	f := NewFetcher(dir, WithPullSecrets(secrets, "cool-ns"))
+	f.Client = srv.Client()
+	ctx := context.Background()
+	repo := "oci://" + host + "/charts"
+
+	// The chart should be pulled with the token that the credentials in the
+	// pull secret are exchanged for.
+	path, err := f.Fetch(ctx, Chart{Repository: repo, Name: "wordpress", Version: "1.2.0", PullSecret: "pull"})
+	if err != nil {
+		t.Fatalf("Fetch(...): %s", err)
+	}
+	if diff := cmp.Diff(f.cachePath(repo, "wordpress", "1.2.0"), path); diff != "" {
+		t.Errorf("Fetch(...): -want path, +got path:\n%s", diff)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != string(archive) {
+		t.Errorf("Fetch(...): the archive should be cached")
+	}
+
+	// A chart whose digest does not match its manifest should be rejected.
+	_, err = f.Fetch(ctx, Chart{Repository: repo, Name: "wordpress", Version: "1.1.0", PullSecret: "pull"})
+	if diff := cmp.Diff(errors.Errorf(errFmtLayerDigest, digest, "sha256:bad").Error(), fmt.Sprint(err)); diff != "" {
+		t.Errorf("Fetch(...): -want error, +got error:\n%s", diff)
+	}
+
+	// A chart without an exact version cannot be pulled.
+	_, err = f.Fetch(ctx, Chart{Repository: repo, Name: "wordpress"})
+	if diff := cmp.Diff(errOCIVersion, fmt.Sprint(err)); diff != "" {
+		t.Errorf("Fetch(...): -want error, +got error:\n%s", diff)
+	}
+
+	// A private chart cannot be pulled without credentials.
+	if _, err := f.Fetch(ctx, Chart{Repository: repo, Name: "wordpress", Version: "1.3.0"}); err == nil {
+		t.Errorf("Fetch(...): a private chart should not be pulled without credentials")
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultFetchTimeout is the default timeout of every request to a
+	// chart repository.
+	DefaultFetchTimeout = 2 * time.Minute
+
+	indexFileName = "index.yaml"
+
+	errChartName     = "name of a chart with a repository is empty"
+	errFetchIndex    = "cannot fetch the index of the repository"
+	errParseIndex    = "cannot parse the index of the repository"
+	errFmtFindChart  = "cannot find version %q of the chart in the repository"
+	errNoChartURL    = "the index of the repository has no URL for the chart"
+	errResolveURL    = "cannot resolve the URL of the chart"
+	errFetchChart    = "cannot fetch the chart"
+	errFmtDigest     = "digest of the chart is %s, but the index of the repository says %s"
+	errCacheChart    = "cannot write the chart to the cache"
+	errFmtStatus     = "GET %s responded with %s"
+	errFmtNotFetched = "chart %s of repository %s is not fetched"
+)
+
+// WithPullSecrets returns a FetcherOption that makes the Fetcher read the
+// pull secrets of the charts from the given namespace with the given reader.
+func WithPullSecrets(c client.Reader, namespace string) FetcherOption {
+	return func(f *Fetcher) {
+		f.Secrets = c
+		f.Namespace = namespace
+	}
+}
+
+// NewFetcher returns a new *Fetcher that caches the charts in the given
+// directory.
+func NewFetcher(dir string, o ...FetcherOption) *Fetcher {
+	f := &Fetcher{CacheDir: dir, Client: &http.Client{Timeout: DefaultFetchTimeout}, Sources: DefaultCredentialSources()}
+	for _, fn := range o {
+		fn(f)
+	}
+	return f
+}
+
+// A Fetcher fetches the charts from Helm repositories and OCI registries and
+// caches their archives on the filesystem. The archives are keyed by the
+// repository, the name and the version of the chart, so a chart is downloaded once per
+// version; changing the version of a chart fetches the new version while the
+// cached ones stay untouched.
+type Fetcher struct {
+	// CacheDir is the directory that the archives are cached in.
+	CacheDir string
+
+	// Client is used to fetch the indexes and the archives.
+	Client *http.Client
+
+	// Secrets is used to read the pull secrets of the charts in OCI
+	// registries. The pull secrets cannot be used if it's nil.
+	Secrets client.Reader
+
+	// Namespace of the pull secrets.
+	Namespace string
+
+	// Sources of the credentials that the charts can refer to by name.
+	Sources map[string]CredentialSource
+}
+
+// FetchCharts returns a copy of the given charts in which the ones with a
+// repository refer to their cached archive, fetching the archives that are
+// not cached yet.
+func (f *Fetcher) FetchCharts(ctx context.Context, charts []Chart) ([]Chart, error) {
+	result := make([]Chart, len(charts))
+	for i, c := range charts {
+		result[i] = c
+		if c.Repository == "" {
+			continue
+		}
+		path, err := f.Fetch(ctx, c)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtChart, c.Name)
+		}
+		result[i].Archive = path
+	}
+	return result, nil
+}
+
+// Fetch returns the path of the cached archive of the given chart, fetching
+// it first if it's not cached. The Version of the chart can be an exact
+// version or a semantic version constraint, which is resolved to the latest
+// matching version in the index of the repository. An empty Version resolves
+// to the latest version. The charts in OCI registries are pulled instead, see
+// fetchOCI.
+func (f *Fetcher) Fetch(ctx context.Context, c Chart) (string, error) {
+	if c.Name == "" {
+		return "", errors.New(errChartName)
+	}
+	if strings.HasPrefix(c.Repository, ociScheme) {
+		return f.fetchOCI(ctx, c)
+	}
+	base, err := url.Parse(c.Repository)
+	if err != nil {
+		return "", errors.Wrap(err, errResolveURL)
+	}
+	cr, err := f.credentials(ctx, c, base.Host)
+	if err != nil {
+		return "", err
+	}
+	auth := &basicAuth{host: base.Host, Credentials: cr}
+	idx, err := f.index(ctx, c.Repository, auth)
+	if err != nil {
+		// NOTE: An exact version that is cached is good enough to start with
+		// when the repository is unreachable.
+		if path := f.cachePath(c.Repository, c.Name, c.Version); c.Version != "" && exists(path) {
+			return path, nil
+		}
+		return "", err
+	}
+	cv, err := idx.Get(c.Name, c.Version)
+	if err != nil {
+		return "", errors.Wrapf(err, errFmtFindChart, c.Version)
+	}
+	path := f.cachePath(c.Repository, c.Name, cv.Version)
+	if exists(path) {
+		return path, nil
+	}
+	if len(cv.URLs) == 0 {
+		return "", errors.New(errNoChartURL)
+	}
+	u, err := repo.ResolveReferenceURL(c.Repository, cv.URLs[0])
+	if err != nil {
+		return "", errors.Wrap(err, errResolveURL)
+	}
+	data, err := f.get(ctx, u, auth)
+	if err != nil {
+		return "", errors.Wrap(err, errFetchChart)
+	}
+	if sum := sha256.Sum256(data); cv.Digest != "" && hex.EncodeToString(sum[:]) != cv.Digest {
+		return "", errors.Errorf(errFmtDigest, hex.EncodeToString(sum[:]), cv.Digest)
+	}
+	return path, errors.Wrap(writeAtomically(path, data), errCacheChart)
+}
+
+// index returns the index of the given repository.
+func (f *Fetcher) index(ctx context.Context, repository string, auth *basicAuth) (*repo.IndexFile, error) {
+	data, err := f.get(ctx, strings.TrimSuffix(repository, "/")+"/"+indexFileName, auth)
+	if err != nil {
+		return nil, errors.Wrap(err, errFetchIndex)
+	}
+	idx := &repo.IndexFile{}
+	if err := sigsyaml.Unmarshal(data, idx); err != nil {
+		return nil, errors.Wrap(err, errParseIndex)
+	}
+	// NOTE: The versions have to be sorted from the latest to the oldest for
+	// Get to return the latest matching version.
+	idx.SortEntries()
+	return idx, nil
+}
+
+// A basicAuth is the credentials of the host of a repository. They are
+// not sent to the other hosts that the index of the repository may refer to.
+type basicAuth struct {
+	Credentials
+	host string
+}
+
+func (f *Fetcher) get(ctx context.Context, u string, auth *basicAuth) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if auth.Username != "" && req.URL.Host == auth.host {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(errFmtStatus, u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// cachePath returns the path of the cached archive of the given version of
+// the given chart. The path is a hash so that neither the repository nor the
+// chart can choose where the archive is written.
+func (f *Fetcher) cachePath(repository, name, version string) string {
+	sum := sha256.Sum256([]byte(repository + "\n" + name + "\n" + version))
+	return filepath.Join(f.CacheDir, hex.EncodeToString(sum[:])+".tgz")
+}
+
+// writeAtomically writes the given data to the given path such that the file
+// is either complete or does not exist, even if the process is killed.
+func writeAtomically(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+func TestFetch(t *testing.T) {
+	archive := []byte("not really a chart")
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	downloads := 0
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/index.yaml":
+			fmt.Fprintf(w, `apiVersion: v1
+entries:
+  wordpress:
+  - name: wordpress
+    version: 1.2.0
+    digest: %s
+    urls: [charts/wordpress-1.2.0.tgz]
+  - name: wordpress
+    version: 1.3.0
+    digest: %s
+    urls: [charts/wordpress-1.3.0.tgz]
+  - name: wordpress
+    version: 1.1.0
+    digest: bad
+    urls: [charts/wordpress-1.1.0.tgz]
+`, digest, digest)
+		default:
+			downloads++
+			_, _ = w.Write(archive)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "charts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	f := NewFetcher(dir)
+	ctx := context.Background()
+
+	// A constraint should resolve to the latest matching version.
+	path, err := f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress", Version: "~1.2"})
+	if err != nil {
+		t.Fatalf("Fetch(...): %s", err)
+	}
+	if diff := cmp.Diff(f.cachePath(srv.URL, "wordpress", "1.2.0"), path); diff != "" {
+		t.Errorf("Fetch(...): -want path, +got path:\n%s", diff)
+	}
+	if got, _ := ioutil.ReadFile(path); string(got) != string(archive) {
+		t.Errorf("Fetch(...): the archive should be cached")
+	}
+
+	// A cached version should not be downloaded again.
+	if _, err := f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress", Version: "1.2.0"}); err != nil {
+		t.Fatalf("Fetch(...): %s", err)
+	}
+	if diff := cmp.Diff(1, downloads); diff != "" {
+		t.Errorf("Fetch(...): -want downloads, +got downloads:\n%s", diff)
+	}
+
+	// An empty version should resolve to the latest version.
+	path, err = f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress"})
+	if err != nil {
+		t.Fatalf("Fetch(...): %s", err)
+	}
+	if diff := cmp.Diff(f.cachePath(srv.URL, "wordpress", "1.3.0"), path); diff != "" {
+		t.Errorf("Fetch(...): -want path, +got path:\n%s", diff)
+	}
+
+	// An archive whose digest does not match the index should be rejected.
+	_, err = f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress", Version: "1.1.0"})
+	if diff := cmp.Diff(errors.Errorf(errFmtDigest, digest, "bad").Error(), fmt.Sprint(err)); diff != "" {
+		t.Errorf("Fetch(...): -want error, +got error:\n%s", diff)
+	}
+
+	// A cached exact version should be used when the repository is down.
+	up = false
+	if _, err := f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress", Version: "1.2.0"}); err != nil {
+		t.Errorf("Fetch(...): a cached exact version should be used when the repository is down: %s", err)
+	}
+	if _, err := f.Fetch(ctx, Chart{Repository: srv.URL, Name: "wordpress", Version: "~1.2"}); err == nil {
+		t.Errorf("Fetch(...): a constraint should not be resolved when the repository is down")
+	}
+}
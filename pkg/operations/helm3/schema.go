@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// schemaRoot is the field of the violations of the root of the values.
+	schemaRoot = "(root)"
+
+	errCoalesceValues = "cannot merge the values with the defaults of the chart"
+	errMarshalValues  = "cannot marshal the values"
+	errValidateSchema = "cannot validate the values against the schema of the chart"
+	errFmtViolations  = "%d values violate the schema of the chart: %s"
+)
+
+// A SchemaError lists the violations of the values.schema.json file of a
+// chart by the values. Every violation is prefixed with the path of the field
+// of the parent resource that the value is built from.
+type SchemaError struct {
+	Violations []string
+}
+
+func (e *SchemaError) Error() string {
+	if len(e.Violations) == 1 {
+		return e.Violations[0]
+	}
+	return fmt.Sprintf(errFmtViolations, len(e.Violations), strings.Join(e.Violations, "; "))
+}
+
+// validate returns a resource.ValuesError with a *SchemaError if the given
+// values, merged with the defaults of the given chart as Helm does, violate
+// the values.schema.json file of the chart. Its path is the field of the
+// parent resource of the first violation. The schemas of the subcharts are
+// left to Helm to validate.
+func validate(c *chart.Chart, in chartInput, values map[string]interface{}) error {
+	if len(c.Schema) == 0 {
+		return nil
+	}
+	// NOTE: The values are merged into a copy since CoalesceValues modifies
+	// the given values.
+	data, err := json.Marshal(values)
+	if err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errMarshalValues)}
+	}
+	copied := map[string]interface{}{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errMarshalValues)}
+	}
+	merged, err := chartutil.CoalesceValues(c, copied)
+	if err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errCoalesceValues)}
+	}
+	data, err = json.Marshal(merged)
+	if err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errMarshalValues)}
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(c.Schema), gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errValidateSchema)}
+	}
+	if result.Valid() {
+		return nil
+	}
+	se := &SchemaError{}
+	var first string
+	for i, re := range result.Errors() {
+		path := schemaFieldPath(in, re.Field())
+		if i == 0 {
+			first = path
+		}
+		se.Violations = append(se.Violations, path+": "+re.Description())
+	}
+	return &resource.ValuesError{Path: first, Err: se}
+}
+
+// schemaFieldPath returns the path of the field of the parent resource that
+// the value at the given path of a schema violation is built from. The
+// violations of the root are reported at the root of the values.
+func schemaFieldPath(in chartInput, field string) string {
+	if field == schemaRoot {
+		return rootPath(in.root)
+	}
+	segments := strings.Split(field, ".")
+	b := &strings.Builder{}
+	for i, s := range segments {
+		switch {
+		case isIndex(s):
+			b.WriteString("[" + s + "]")
+		case i == 0:
+			b.WriteString(s)
+		default:
+			b.WriteString("." + s)
+		}
+	}
+	return fieldPath(in.root, in.bindings, b.String())
+}
+
+func isIndex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestValidate(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "cool"},
+		Values:   map[string]interface{}{"image": map[string]interface{}{"repository": "wordpress"}},
+		Schema: []byte(`{
+  "type": "object",
+  "required": ["image"],
+  "properties": {
+    "replicas": {"type": "integer", "minimum": 1},
+    "image": {
+      "type": "object",
+      "required": ["repository"],
+      "properties": {"tag": {"type": "string"}}
+    },
+    "hosts": {"type": "array", "items": {"type": "string"}}
+  }
+}`),
+	}
+	type want struct {
+		path       string
+		violations []string
+	}
+	cases := map[string]struct {
+		reason string
+		in     chartInput
+		values map[string]interface{}
+		want   want
+	}{
+		"Valid": {
+			reason: "Values that match the schema with the defaults of the chart should be valid.",
+			values: map[string]interface{}{"replicas": 2},
+		},
+		"Invalid": {
+			reason: "A violation should be reported at the field of the spec that the value is built from.",
+			values: map[string]interface{}{"replicas": 0},
+			want:   want{path: "spec.replicas", violations: []string{"spec.replicas"}},
+		},
+		"InvalidItem": {
+			reason: "A violation of an array item should be reported with the index of the item.",
+			values: map[string]interface{}{"hosts": []interface{}{"a", 3}},
+			want:   want{path: "spec.hosts[1]", violations: []string{"spec.hosts[1]"}},
+		},
+		"Bound": {
+			reason: "A violation of a bound value should be reported at the field it is bound from.",
+			in:     chartInput{bindings: []v1alpha1.FieldBinding{{From: "spec.wordpress.image", To: "image"}}},
+			values: map[string]interface{}{"image": map[string]interface{}{"tag": 5}},
+			want:   want{path: "spec.wordpress.image.tag", violations: []string{"spec.wordpress.image.tag"}},
+		},
+		"Many": {
+			reason: "All violations should be reported.",
+			values: map[string]interface{}{"replicas": "two", "hosts": "a"},
+			want:   want{violations: []string{"spec.hosts", "spec.replicas"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := validate(c, tc.in, tc.values)
+			var ve *resource.ValuesError
+			var se *SchemaError
+			if !errors.As(err, &ve) || !errors.As(err, &se) {
+				if tc.want.violations != nil {
+					t.Fatalf("\n%s\nvalidate(...): want a ValuesError with a SchemaError, got: %v", tc.reason, err)
+				}
+				if err != nil {
+					t.Errorf("\n%s\nvalidate(...): %s", tc.reason, err)
+				}
+				return
+			}
+			if tc.want.path != "" {
+				if diff := cmp.Diff(tc.want.path, ve.Path); diff != "" {
+					t.Errorf("\n%s\nvalidate(...): -want path, +got path:\n%s", tc.reason, diff)
+				}
+			}
+			paths := make([]string, len(se.Violations))
+			for i, v := range se.Violations {
+				paths[i] = strings.SplitN(v, ": ", 2)[0]
+			}
+			sort.Strings(paths)
+			if diff := cmp.Diff(tc.want.violations, paths); diff != "" {
+				t.Errorf("\n%s\nvalidate(...): -want violations, +got violations:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// AllowValuesFromAnnotationKey is the annotation on the StackDefinition
+	// that makes the Engine read the values from the ConfigMaps and Secrets
+	// that are referenced in the ValuesFromField of the parent resources when
+	// its value is "true".
+	AllowValuesFromAnnotationKey = "templatestacks.crossplane.io/allow-values-from"
+
+	// ValuesFromField is the field in the spec of the parent resource whose
+	// value is the list of ValuesReferences.
+	ValuesFromField = "valuesFrom"
+
+	// DefaultValuesKey is the key in the data of the referenced ConfigMap or
+	// Secret that is read if the reference does not name one.
+	DefaultValuesKey = "values.yaml"
+
+	// KindConfigMap is the kind of a reference to a ConfigMap.
+	KindConfigMap = "ConfigMap"
+
+	// KindSecret is the kind of a reference to a Secret.
+	KindSecret = "Secret"
+
+	valuesFromTimeout = 10 * time.Second
+
+	errValuesFromList         = "must be a list of references"
+	errValuesFromRef          = "cannot parse the reference"
+	errValuesFromName         = "name of the reference is empty"
+	errFmtValuesFromKind      = "kind of the reference must be ConfigMap or Secret, not %q"
+	errValuesFromNamespace    = "namespace of the reference must be the namespace of the parent resource"
+	errValuesFromNoNamespace  = "namespace of the reference must be set for a cluster-scoped parent resource"
+	errFmtGetValuesFrom       = "cannot get %s %s"
+	errFmtValuesFromKey       = "%s %s has no %s key"
+	errFmtUnmarshalValuesFrom = "cannot unmarshal the values in %s key"
+)
+
+// A ValuesReference references a key of a ConfigMap or a Secret whose value
+// is a YAML document of values.
+type ValuesReference struct {
+	// Kind of the referenced object, either ConfigMap or Secret.
+	Kind string `json:"kind"`
+
+	// Name of the referenced object.
+	Name string `json:"name"`
+
+	// Namespace of the referenced object. It defaults to the namespace of the
+	// parent resource, and has to be the same unless the parent resource is
+	// cluster-scoped.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ValuesKey is the key in the data of the referenced object. It defaults
+	// to DefaultValuesKey.
+	ValuesKey string `json:"valuesKey,omitempty"`
+
+	// Optional makes the reference resolve to no values if the object or the
+	// key does not exist.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// WithValuesFrom returns an Option that makes the Engine read the values from
+// the ConfigMaps and Secrets that are referenced in the ValuesFromField of
+// the parent resource with the given reader. The values are merged in the
+// order of the references and beneath the values of every chart.
+func WithValuesFrom(c client.Reader) Option {
+	return func(e *Engine) {
+		e.ValuesReader = c
+	}
+}
+
+// valuesFrom returns the values that are read from the references in the
+// ValuesFromField of the given spec of the given parent resource. The later
+// references take precedence over the earlier ones. A reference that cannot
+// be resolved results in a resource.ValuesError with its path.
+func (e *Engine) valuesFrom(ctx context.Context, cr resource.ParentResource, spec map[string]interface{}) (map[string]interface{}, error) {
+	raw, ok := spec[ValuesFromField]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	refs, ok := raw.([]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec." + ValuesFromField, Err: errors.New(errValuesFromList)}
+	}
+	ctx, cancel := context.WithTimeout(ctx, valuesFromTimeout)
+	defer cancel()
+	result := map[string]interface{}{}
+	for i, ref := range refs {
+		values, err := e.resolve(ctx, cr, ref)
+		if err != nil {
+			return nil, &resource.ValuesError{Path: fmt.Sprintf("spec.%s[%d]", ValuesFromField, i), Err: err}
+		}
+		result = chartutil.CoalesceTables(values, result)
+	}
+	return result, nil
+}
+
+// resolve returns the values that the given reference in the spec of the
+// given parent resource resolves to.
+func (e *Engine) resolve(ctx context.Context, cr resource.ParentResource, raw interface{}) (map[string]interface{}, error) {
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errValuesFromRef)
+	}
+	ref := ValuesReference{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &ref); err != nil {
+		return nil, errors.Wrap(err, errValuesFromRef)
+	}
+	if ref.Name == "" {
+		return nil, errors.New(errValuesFromName)
+	}
+	ns, err := namespaceOf(cr, ref)
+	if err != nil {
+		return nil, err
+	}
+	key := ref.ValuesKey
+	if key == "" {
+		key = DefaultValuesKey
+	}
+	nn := types.NamespacedName{Namespace: ns, Name: ref.Name}
+	var data []byte
+	var found bool
+	switch ref.Kind {
+	case KindConfigMap:
+		cm := &corev1.ConfigMap{}
+		err = e.ValuesReader.Get(ctx, nn, cm)
+		var s string
+		s, found = cm.Data[key]
+		data = []byte(s)
+	case KindSecret:
+		s := &corev1.Secret{}
+		err = e.ValuesReader.Get(ctx, nn, s)
+		data, found = s.Data[key]
+	default:
+		return nil, errors.Errorf(errFmtValuesFromKind, ref.Kind)
+	}
+	switch {
+	case kerrors.IsNotFound(err) && ref.Optional:
+		return map[string]interface{}{}, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, errFmtGetValuesFrom, ref.Kind, nn)
+	case !found && ref.Optional:
+		return map[string]interface{}{}, nil
+	case !found:
+		return nil, errors.Errorf(errFmtValuesFromKey, ref.Kind, nn, key)
+	}
+	values := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal(data, &values); err != nil {
+		return nil, errors.Wrapf(err, errFmtUnmarshalValuesFrom, key)
+	}
+	return values, nil
+}
+
+// namespaceOf returns the namespace that the given reference in the given
+// parent resource is resolved in. A namespaced parent resource cannot
+// reference the objects in other namespaces.
+func namespaceOf(cr resource.ParentResource, ref ValuesReference) (string, error) {
+	switch {
+	case cr.GetNamespace() == "" && ref.Namespace == "":
+		return "", errors.New(errValuesFromNoNamespace)
+	case cr.GetNamespace() == "":
+		return ref.Namespace, nil
+	case ref.Namespace != "" && ref.Namespace != cr.GetNamespace():
+		return "", errors.New(errValuesFromNamespace)
+	}
+	return cr.GetNamespace(), nil
+}
+
+// ValuesSources returns the sources that the values of the given parent
+// resource are merged from, in increasing order of precedence: the default
+// values of the StackDefinition, the references in its ValuesFromField, its
+// spec or the bindings of its fields, the values of the environment and its
+// values override annotation.
+func (e *Engine) ValuesSources(cr resource.ParentResource) []string {
+	var sources []string
+	if len(e.DefaultValues) != 0 {
+		sources = append(sources, "annotation "+DefaultValuesAnnotationKey)
+	}
+	spec, _ := cr.UnstructuredContent()["spec"].(map[string]interface{})
+	if refs, ok := spec[ValuesFromField].([]interface{}); ok && e.ValuesReader != nil {
+		for _, raw := range refs {
+			if desc, ok := describeRef(cr, raw); ok {
+				sources = append(sources, desc)
+			}
+		}
+	}
+	bound, unbound := false, len(e.Charts) == 0
+	for _, c := range e.Charts {
+		if len(c.Bindings) != 0 {
+			bound = true
+			continue
+		}
+		unbound = true
+	}
+	if unbound {
+		sources = append(sources, rootPath(e.ValuesPath))
+	}
+	if bound {
+		sources = append(sources, "spec bindings")
+	}
+	if len(e.EnvironmentValues) != 0 {
+		sources = append(sources, "environment "+e.Environment)
+	}
+	if _, ok := cr.GetAnnotations()[ValuesOverrideAnnotationKey]; ok && e.ValuesOverride {
+		sources = append(sources, "annotation "+ValuesOverrideAnnotationKey)
+	}
+	return sources
+}
+
+// describeRef returns the description of the given reference in the given
+// parent resource, if it's valid.
+func describeRef(cr resource.ParentResource, raw interface{}) (string, bool) {
+	obj, ok := raw.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	ref := ValuesReference{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj, &ref); err != nil {
+		return "", false
+	}
+	ns, err := namespaceOf(cr, ref)
+	if err != nil {
+		return "", false
+	}
+	desc := fmt.Sprintf("%s %s", ref.Kind, types.NamespacedName{Namespace: ns, Name: ref.Name})
+	if ref.Kind == KindSecret {
+		desc += " (redacted)"
+	}
+	if ref.Optional {
+		desc += " (optional)"
+	}
+	return desc, true
+}
+
+// withoutValuesFrom returns a copy of the given spec without the
+// ValuesFromField. The given spec is not modified.
+func withoutValuesFrom(spec map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(spec))
+	for k, v := range spec {
+		if k != ValuesFromField {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm3
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestValuesFrom(t *testing.T) {
+	parent := func(ns string, refs ...interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"image":      map[string]interface{}{"tag": "5.4"},
+				"valuesFrom": refs,
+			},
+		}}
+		u.SetNamespace(ns)
+		return u
+	}
+	ref := func(kind, name string) map[string]interface{} {
+		return map[string]interface{}{"kind": kind, "name": name}
+	}
+	reader := &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			if key.Name != "defaults" || key.Namespace != "cool" {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)
+			}
+			o.Data = map[string]string{DefaultValuesKey: "image:\n  repository: wordpress\n  tag: \"5.3\"\nreplicas: 1\n"}
+		case *corev1.Secret:
+			if key.Name != "db" || key.Namespace != "cool" {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+			}
+			o.Data = map[string][]byte{"db.yaml": []byte("replicas: 2\ndb:\n  password: hunter2\n")}
+		}
+		return nil
+	}}
+	type want struct {
+		values map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		want   want
+	}{
+		"Merged": {
+			reason: "The referenced values should be merged in order and beneath the spec, which loses its valuesFrom field.",
+			cr: parent("cool", ref(KindConfigMap, "defaults"), map[string]interface{}{
+				"kind": KindSecret, "name": "db", "valuesKey": "db.yaml",
+			}),
+			want: want{values: map[string]interface{}{
+				"image":    map[string]interface{}{"repository": "wordpress", "tag": "5.4"},
+				"replicas": float64(2),
+				"db":       map[string]interface{}{"password": "hunter2"},
+			}},
+		},
+		"Optional": {
+			reason: "An optional reference to an object that does not exist should resolve to no values.",
+			cr: parent("cool", map[string]interface{}{
+				"kind": KindConfigMap, "name": "missing", "optional": true,
+			}),
+			want: want{values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "5.4"},
+			}},
+		},
+		"NotFound": {
+			reason: "A reference to an object that does not exist should result in a ValuesError with its path.",
+			cr:     parent("cool", ref(KindConfigMap, "defaults"), ref(KindConfigMap, "missing")),
+			want: want{err: &resource.ValuesError{
+				Path: "spec.valuesFrom[1]",
+				Err:  errors.Wrapf(kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "missing"), errFmtGetValuesFrom, KindConfigMap, "cool/missing"),
+			}},
+		},
+		"MissingKey": {
+			reason: "A reference to a key that does not exist should result in a ValuesError.",
+			cr:     parent("cool", ref(KindSecret, "db")),
+			want: want{err: &resource.ValuesError{
+				Path: "spec.valuesFrom[0]",
+				Err:  errors.Errorf(errFmtValuesFromKey, KindSecret, "cool/db", DefaultValuesKey),
+			}},
+		},
+		"OtherNamespace": {
+			reason: "A namespaced parent resource should not be able to reference the objects in other namespaces.",
+			cr: parent("cool", map[string]interface{}{
+				"kind": KindSecret, "name": "db", "namespace": "kube-system",
+			}),
+			want: want{err: &resource.ValuesError{Path: "spec.valuesFrom[0]", Err: errors.New(errValuesFromNamespace)}},
+		},
+		"UnknownKind": {
+			reason: "A reference to a kind other than ConfigMap and Secret should be rejected.",
+			cr:     parent("cool", ref("Pod", "db")),
+			want:   want{err: &resource.ValuesError{Path: "spec.valuesFrom[0]", Err: errors.Errorf(errFmtValuesFromKind, "Pod")}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := NewHelm3Engine(WithValuesFrom(reader))
+			inputs, err := e.inputs(context.Background(), tc.cr, true)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ninputs(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			var got map[string]interface{}
+			if len(inputs) != 0 {
+				got = inputs[0].values
+			}
+			if diff := cmp.Diff(tc.want.values, got); diff != "" {
+				t.Errorf("\n%s\ninputs(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestValuesSources(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"valuesFrom": []interface{}{
+				map[string]interface{}{"kind": KindConfigMap, "name": "defaults"},
+				map[string]interface{}{"kind": KindSecret, "name": "db", "optional": true},
+			},
+		},
+	}}
+	cr.SetNamespace("cool")
+	cr.SetAnnotations(map[string]string{ValuesOverrideAnnotationKey: "replicas: 2"})
+
+	cases := map[string]struct {
+		reason string
+		e      *Engine
+		want   []string
+	}{
+		"Spec": {
+			reason: "The spec should be the only source if no other source is enabled.",
+			e:      NewHelm3Engine(),
+			want:   []string{"spec"},
+		},
+		"All": {
+			reason: "The default values, the references, the spec bindings, the environment and the override should be reported in increasing order of precedence.",
+			e: NewHelm3Engine(WithValuesFrom(&test.MockClient{}), WithValuesOverride(), WithCharts(
+				Chart{Path: "frontend", Bindings: []v1alpha1.FieldBinding{{From: "spec.replicas", To: "replicaCount"}}},
+			), WithDefaultValues(map[string]interface{}{"replicaCount": 1}), WithEnvironmentValues("prod", map[string]interface{}{"replicaCount": 3})),
+			want: []string{
+				"annotation " + DefaultValuesAnnotationKey,
+				"ConfigMap cool/defaults",
+				"Secret cool/db (redacted) (optional)",
+				"spec bindings",
+				"environment prod",
+				"annotation " + ValuesOverrideAnnotationKey,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.e.ValuesSources(cr)); diff != "" {
+				t.Errorf("\n%s\nValuesSources(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -17,18 +17,36 @@ limitations under the License.
 package kustomize
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"text/template"
 
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 
-	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
-
+	"github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/operations/gotemplate"
 	"github.com/crossplane/templating-controller/pkg/resource"
 )
 
+const (
+	overlayFilePrefix         = "overlaypatch-"
+	overlayJson6902FilePrefix = "overlayjson6902patch-"
+
+	errFmtBindingTo          = "invalid path %s of the binding"
+	errFmtRequiredBinding    = "not set, but required by the binding to %s of %s %s"
+	errFmtParseTransform     = "cannot parse the transform of the binding to %s of %s %s"
+	errFmtExecTransform      = "cannot execute the transform of the binding to %s of %s %s"
+	errFmtTransformOutput    = "cannot parse the output of the transform of the binding to %s of %s %s"
+	errFmtMarshalOverlay     = "cannot marshal overlay %d"
+	errFmtMarshalBindingsOps = "cannot marshal the list bindings of overlay %d"
+)
+
 // NewNamePrefixer returns a new *NamePrefixer.
 func NewNamePrefixer() NamePrefixer {
 	return NamePrefixer{}
@@ -51,8 +69,10 @@ func NewPatchOverlayGenerator(overlays []v1alpha1.KustomizeEngineOverlay) PatchO
 	}
 }
 
-// PatchOverlayGenerator generates PatchStrategicMerge files with the given overlay
-// settings.
+// PatchOverlayGenerator generates a PatchStrategicMerge file for every given
+// overlay. The bindings that address the items of lists are generated as
+// JSON 6902 patches instead since a strategic merge patch cannot address them
+// by their index.
 type PatchOverlayGenerator struct {
 	Overlays []v1alpha1.KustomizeEngineOverlay
 }
@@ -63,52 +83,136 @@ func (pog PatchOverlayGenerator) Generate(cr resource.ParentResource, k *types.K
 	if len(pog.Overlays) == 0 {
 		return nil, nil
 	}
-	finalOverlayYAML := ""
-	for _, overlay := range pog.Overlays {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// patches of the previous one are dropped first.
+	k.PatchesStrategicMerge = withoutGeneratedStrategicMerge(k.PatchesStrategicMerge, overlayFilePrefix)
+	k.PatchesJson6902 = withoutGeneratedJson6902(k.PatchesJson6902, overlayJson6902FilePrefix)
+	var files []OverlayFile
+	for i, overlay := range pog.Overlays {
 		obj := &unstructured.Unstructured{}
 		obj.SetAPIVersion(overlay.APIVersion)
 		obj.SetKind(overlay.Kind)
 		obj.SetName(overlay.Name)
-		// todo: stackdefinition does not support namespace yet.
-		// obj.SetNamespace(overlay.Namespace)
+		obj.SetNamespace(overlay.Namespace)
 
+		var ops []map[string]interface{}
 		for _, binding := range overlay.Bindings {
 			// First make sure there is a value in the referred path.
 			val, exists, err := unstructured.NestedFieldCopy(cr.UnstructuredContent(), strings.Split(binding.From, ".")...)
 			if err != nil {
 				return nil, err
 			}
+			if !exists && binding.Default != nil {
+				val, exists = runtime.DeepCopyJSONValue(binding.Default), true
+			}
+			if !exists && binding.Required {
+				return nil, &resource.ValuesError{Path: binding.From, Err: errors.Errorf(errFmtRequiredBinding, binding.To, overlay.Kind, overlay.Name)}
+			}
+			if binding.Transform != "" {
+				if val, exists, err = transform(cr, overlay, binding, val); err != nil {
+					return nil, err
+				}
+			}
 			if !exists {
 				continue
 			}
+			if binding.IndexesLists() {
+				op, err := bindingOperation(binding, val)
+				if err != nil {
+					return nil, err
+				}
+				ops = append(ops, op)
+				continue
+			}
 			if err := unstructured.SetNestedField(obj.Object, val, strings.Split(binding.To, ".")...); err != nil {
 				return nil, err
 			}
 		}
 		overlayYAML, err := yaml.Marshal(obj)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrapf(err, errFmtMarshalOverlay, i)
+		}
+		// NOTE: Every overlay is written to its own file rather than to a
+		// stream of documents so that no value can break the documents.
+		fileName := fmt.Sprintf("%s%d.yaml", overlayFilePrefix, i)
+		k.PatchesStrategicMerge = append(k.PatchesStrategicMerge, types.PatchStrategicMerge(fileName))
+		files = append(files, OverlayFile{Name: fileName, Data: overlayYAML})
+		if len(ops) == 0 {
+			continue
+		}
+		data, err := json.Marshal(ops)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtMarshalBindingsOps, i)
 		}
-		// TODO(muvaf): yaml.Marshal does not support outputting multiple YAML
-		// documents. That's temporary solution.
-		finalOverlayYAML = fmt.Sprintf("%s---\n%s", finalOverlayYAML, string(overlayYAML))
+		name := fmt.Sprintf("%s%d.json", overlayJson6902FilePrefix, i)
+		target := Json6902Target{APIVersion: overlay.APIVersion, Kind: overlay.Kind, Name: overlay.Name, Namespace: overlay.Namespace}
+		k.PatchesJson6902 = append(k.PatchesJson6902, types.PatchJson6902{Target: patchTarget(target), Path: name})
+		files = append(files, OverlayFile{Name: name, Data: data})
+	}
+	return files, nil
+}
+
+// ValidateTransforms returns an error if the transform of a binding of the
+// given overlays cannot be parsed.
+func ValidateTransforms(overlays []v1alpha1.KustomizeEngineOverlay) error {
+	for _, o := range overlays {
+		for _, b := range o.Bindings {
+			if _, err := template.New(b.To).Funcs(gotemplate.Funcs(&unstructured.Unstructured{})).Parse(b.Transform); err != nil {
+				return errors.Wrapf(err, errFmtParseTransform, b.To, o.Kind, o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// transform executes the transform of the given binding of the given overlay
+// with the given value, and returns its output parsed as YAML and false if
+// the output is empty.
+func transform(cr resource.ParentResource, o v1alpha1.KustomizeEngineOverlay, b v1alpha1.FieldBinding, val interface{}) (interface{}, bool, error) {
+	tmpl, err := template.New(b.To).Funcs(gotemplate.Funcs(cr)).Parse(b.Transform)
+	if err != nil {
+		return nil, false, &resource.RenderError{Err: errors.Wrapf(err, errFmtParseTransform, b.To, o.Kind, o.Name)}
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, val); err != nil {
+		return nil, false, &resource.RenderError{Err: errors.Wrapf(err, errFmtExecTransform, b.To, o.Kind, o.Name)}
+	}
+	out := strings.TrimSpace(buf.String())
+	if out == "" {
+		return nil, false, nil
+	}
+	var result interface{}
+	if err := yaml.Unmarshal([]byte(out), &result); err != nil {
+		return nil, false, &resource.RenderError{Err: errors.Wrapf(err, errFmtTransformOutput, b.To, o.Kind, o.Name)}
+	}
+	return result, true, nil
+}
+
+// bindingOperation returns the JSON 6902 operation that sets the To field of
+// the given binding, which addresses the items of lists, to the given value.
+// An index at the end of the path replaces the item, [-] appends a new one
+// and the fields of the items are added or replaced.
+func bindingOperation(b v1alpha1.FieldBinding, val interface{}) (map[string]interface{}, error) {
+	pointer, ok := b.ToPointer()
+	if !ok {
+		return nil, errors.Errorf(errFmtBindingTo, b.To)
+	}
+	op := Json6902OpAdd
+	if strings.HasSuffix(b.To, "]") && !strings.HasSuffix(b.To, "["+v1alpha1.AppendIndex+"]") {
+		op = Json6902OpReplace
 	}
-	fileName := "overlaypatch.yaml"
-	k.PatchesStrategicMerge = appendPatchMergeIfNotExists(k.PatchesStrategicMerge, types.PatchStrategicMerge(fileName))
-	return []OverlayFile{
-		{
-			Name: fileName,
-			Data: []byte(finalOverlayYAML),
-		},
-	}, nil
+	return map[string]interface{}{"op": op, "path": pointer, "value": val}, nil
 }
 
-// todo: temporary.
-func appendPatchMergeIfNotExists(arr []types.PatchStrategicMerge, obj types.PatchStrategicMerge) []types.PatchStrategicMerge {
-	for _, e := range arr {
-		if e == obj {
-			return arr
+// withoutGeneratedStrategicMerge returns the given patches without the ones
+// whose files have the given prefix, i.e. that are generated by an overlay
+// generator.
+func withoutGeneratedStrategicMerge(patches []types.PatchStrategicMerge, prefix string) []types.PatchStrategicMerge {
+	result := make([]types.PatchStrategicMerge, 0, len(patches))
+	for _, p := range patches {
+		if !strings.HasPrefix(string(p), prefix) {
+			result = append(result, p)
 		}
 	}
-	return append(arr, obj)
+	return result
 }
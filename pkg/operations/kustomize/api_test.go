@@ -15,6 +15,131 @@ limitations under the License.
 */
 package kustomize
 
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/resid"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
 var (
 	_ Patcher = NamePrefixer{}
 )
+
+func TestPatchOverlayGenerator(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"image": "nginx:1.19", "replicas": int64(3), "arg": "--verbose"},
+	}}
+	static := types.PatchJson6902{Target: &types.PatchTarget{Name: "static"}, Path: "static.json"}
+	overlays := []v1alpha1.KustomizeEngineOverlay{{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Name:       "app",
+		Bindings: []v1alpha1.FieldBinding{
+			{From: "spec.replicas", To: "spec.replicas"},
+			{From: "spec.paused", To: "spec.paused", Default: false},
+			{From: "spec.image", To: "metadata.annotations.image", Transform: `{{ quote . }}`},
+			{From: "spec.replicas", To: "spec.minReadySeconds", Transform: `{{ if . }}{{ . }}0{{ end }}`},
+			{From: "spec.missing", To: "spec.revisionHistoryLimit", Transform: `{{ default 5 . }}`},
+			{From: "spec.missing", To: "spec.progressDeadlineSeconds", Transform: `{{ with . }}{{ . }}{{ end }}`},
+			{From: "spec.image", To: "spec.template.spec.containers[0].image"},
+			{From: "spec.arg", To: "spec.template.spec.containers[0].args[-]"},
+			{From: "spec.arg", To: "spec.template.spec.containers[1].args[0]"},
+			{From: "spec.missing", To: "spec.template.spec.containers[2].image"},
+		},
+	}}
+	k := &types.Kustomization{PatchesJson6902: []types.PatchJson6902{static, {Path: "overlayjson6902patch-0.json"}}}
+
+	got, err := NewPatchOverlayGenerator(overlays).Generate(cr, k)
+	if err != nil {
+		t.Fatalf("Generate(...): %s", err)
+	}
+	want := []OverlayFile{
+		{
+			Name: "overlaypatch-0.yaml",
+			Data: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  annotations:\n    image: nginx:1.19\n  name: app\nspec:\n  minReadySeconds: 30\n  paused: false\n  replicas: 3\n  revisionHistoryLimit: 5\n"),
+		},
+		{
+			Name: "overlayjson6902patch-0.json",
+			Data: []byte(`[{"op":"add","path":"/spec/template/spec/containers/0/image","value":"nginx:1.19"},` +
+				`{"op":"add","path":"/spec/template/spec/containers/0/args/-","value":"--verbose"},` +
+				`{"op":"replace","path":"/spec/template/spec/containers/1/args/0","value":"--verbose"}]`),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Generate(...): -want, +got:\n%s", diff)
+	}
+	wantPatches := []types.PatchJson6902{static, {
+		Target: &types.PatchTarget{Gvk: resid.Gvk{Group: "apps", Version: "v1", Kind: "Deployment"}, Name: "app"},
+		Path:   "overlayjson6902patch-0.json",
+	}}
+	if diff := cmp.Diff(wantPatches, k.PatchesJson6902); diff != "" {
+		t.Errorf("Generate(...): -want patches, +got patches:\n%s", diff)
+	}
+}
+
+func TestPatchOverlayGeneratorRequired(t *testing.T) {
+	overlays := []v1alpha1.KustomizeEngineOverlay{{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Name:       "config",
+		Bindings:   []v1alpha1.FieldBinding{{From: "spec.region", To: "data.region", Required: true}},
+	}}
+	_, err := NewPatchOverlayGenerator(overlays).Generate(&unstructured.Unstructured{}, &types.Kustomization{})
+	want := &resource.ValuesError{Path: "spec.region", Err: errors.Errorf(errFmtRequiredBinding, "data.region", "ConfigMap", "config")}
+	if diff := cmp.Diff(want, err, test.EquateErrors()); diff != "" {
+		t.Errorf("Generate(...): -want error, +got error:\n%s", diff)
+	}
+}
+
+func TestPatchOverlayGeneratorMultipleKinds(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"config": "---\nkey: value\n", "replicas": int64(2)},
+	}}
+	overlays := []v1alpha1.KustomizeEngineOverlay{
+		{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       "config",
+			Namespace:  "monitoring",
+			Bindings:   []v1alpha1.FieldBinding{{From: "spec.config", To: "data.config"}},
+		},
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "app",
+			Bindings:   []v1alpha1.FieldBinding{{From: "spec.replicas", To: "spec.replicas"}},
+		},
+	}
+	k := &types.Kustomization{PatchesStrategicMerge: []types.PatchStrategicMerge{"static.yaml", "overlaypatch-2.yaml"}}
+
+	got, err := NewPatchOverlayGenerator(overlays).Generate(cr, k)
+	if err != nil {
+		t.Fatalf("Generate(...): %s", err)
+	}
+	want := []OverlayFile{
+		{
+			Name: "overlaypatch-0.yaml",
+			Data: []byte("apiVersion: v1\ndata:\n  config: |\n    ---\n    key: value\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: monitoring\n"),
+		},
+		{
+			Name: "overlaypatch-1.yaml",
+			Data: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 2\n"),
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Generate(...): -want, +got:\n%s", diff)
+	}
+	wantPatches := []types.PatchStrategicMerge{"static.yaml", "overlaypatch-0.yaml", "overlaypatch-1.yaml"}
+	if diff := cmp.Diff(wantPatches, k.PatchesStrategicMerge); diff != "" {
+		t.Errorf("Generate(...): -want patches, +got patches:\n%s", diff)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// BuildOptionsAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of BuildOptions.
+	BuildOptionsAnnotationKey = "templatestacks.crossplane.io/kustomize-build-options"
+
+	errParseBuildOptions     = "could not parse the build options"
+	errFmtLoadRestrictor     = "unknown load restrictor %s"
+	errFmtPlugins            = "unknown plugins mode %s"
+	errFmtReorder            = "unknown reorder mode %s"
+	errPluginHomeRequired    = "pluginHome is required when plugins are enabled"
+	errFmtPluginHomeAbsolute = "pluginHome %s should be an absolute path"
+)
+
+// A LoadRestrictor restricts the files that a kustomization can load.
+type LoadRestrictor string
+
+// Load restrictors.
+const (
+	// LoadRestrictorRootOnly allows a kustomization to load only the files
+	// in its directory and its subdirectories.
+	LoadRestrictorRootOnly LoadRestrictor = "rootOnly"
+
+	// LoadRestrictorNone allows a kustomization to load any file.
+	LoadRestrictorNone LoadRestrictor = "none"
+)
+
+// A PluginsMode determines which kustomize plugins can be used in the
+// generators and transformers of a kustomization.
+type PluginsMode string
+
+// Plugins modes.
+const (
+	// PluginsBuiltinsOnly allows only the plugins that are built into
+	// kustomize.
+	PluginsBuiltinsOnly PluginsMode = "builtinsOnly"
+
+	// PluginsEnabled allows the exec and Go plugins in the plugin home as
+	// well.
+	PluginsEnabled PluginsMode = "enabled"
+)
+
+// A Reorder determines the order of the rendered resources.
+type Reorder string
+
+// Reorder modes.
+const (
+	// ReorderNone keeps the order in which the resources are declared.
+	ReorderNone Reorder = "none"
+
+	// ReorderLegacy sorts the resources by kind, as kustomize does by
+	// default.
+	ReorderLegacy Reorder = "legacy"
+)
+
+// BuildOptions are the options of the kustomize build.
+type BuildOptions struct {
+	// LoadRestrictor is one of rootOnly and none. Defaults to rootOnly.
+	// +optional
+	LoadRestrictor LoadRestrictor `json:"loadRestrictor,omitempty"`
+
+	// Plugins is one of builtinsOnly and enabled. Defaults to builtinsOnly.
+	// +optional
+	Plugins PluginsMode `json:"plugins,omitempty"`
+
+	// PluginHome is the absolute path of the directory that the plugins are
+	// loaded from, laid out as ${PluginHome}/${apiVersion}/LOWERCASE(${kind}).
+	// It's required if the plugins are enabled.
+	// +optional
+	PluginHome string `json:"pluginHome,omitempty"`
+
+	// Reorder is one of none and legacy. Defaults to none.
+	// +optional
+	Reorder Reorder `json:"reorder,omitempty"`
+}
+
+// ParseBuildOptions parses and validates the given YAML representation of
+// the build options, typically the value of BuildOptionsAnnotationKey
+// annotation.
+func ParseBuildOptions(data string) (BuildOptions, error) {
+	b := BuildOptions{}
+	if err := yaml.Unmarshal([]byte(data), &b); err != nil {
+		return BuildOptions{}, errors.Wrap(err, errParseBuildOptions)
+	}
+	switch b.LoadRestrictor {
+	case "", LoadRestrictorRootOnly, LoadRestrictorNone:
+	default:
+		return BuildOptions{}, errors.Errorf(errFmtLoadRestrictor, b.LoadRestrictor)
+	}
+	switch b.Plugins {
+	case "", PluginsBuiltinsOnly:
+	case PluginsEnabled:
+		if b.PluginHome == "" {
+			return BuildOptions{}, errors.New(errPluginHomeRequired)
+		}
+		if !filepath.IsAbs(b.PluginHome) {
+			return BuildOptions{}, errors.Errorf(errFmtPluginHomeAbsolute, b.PluginHome)
+		}
+	default:
+		return BuildOptions{}, errors.Errorf(errFmtPlugins, b.Plugins)
+	}
+	switch b.Reorder {
+	case "", ReorderNone, ReorderLegacy:
+	default:
+		return BuildOptions{}, errors.Errorf(errFmtReorder, b.Reorder)
+	}
+	return b, nil
+}
+
+// WithBuildOptions allows you to change the options of the kustomize build.
+func WithBuildOptions(b BuildOptions) Option {
+	return func(ko *Engine) {
+		ko.BuildOptions = b
+	}
+}
+
+// krustyOptions returns the options of krusty for the Engine.
+func (o *Engine) krustyOptions() *krusty.Options {
+	opts := krusty.MakeDefaultOptions()
+	opts.DoLegacyResourceSort = o.LegacyResourceSort || o.BuildOptions.Reorder == ReorderLegacy
+	if o.BuildOptions.LoadRestrictor == LoadRestrictorNone {
+		opts.LoadRestrictions = types.LoadRestrictionsNone
+	}
+	if o.BuildOptions.Plugins == PluginsEnabled {
+		opts.PluginConfig = konfig.MakePluginConfig(types.PluginRestrictionsNone, o.BuildOptions.PluginHome)
+	}
+	return opts
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseBuildOptions(t *testing.T) {
+	type want struct {
+		b   BuildOptions
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid build options should be parsed.",
+			data:   "{loadRestrictor: none, plugins: enabled, pluginHome: /plugins, reorder: legacy}",
+			want: want{b: BuildOptions{
+				LoadRestrictor: LoadRestrictorNone,
+				Plugins:        PluginsEnabled,
+				PluginHome:     "/plugins",
+				Reorder:        ReorderLegacy,
+			}},
+		},
+		"UnknownLoadRestrictor": {
+			reason: "An unknown load restrictor should be rejected.",
+			data:   "{loadRestrictor: some}",
+			want:   want{err: errors.Errorf(errFmtLoadRestrictor, "some")},
+		},
+		"NoPluginHome": {
+			reason: "The plugin home should be given when the plugins are enabled.",
+			data:   "{plugins: enabled}",
+			want:   want{err: errors.New(errPluginHomeRequired)},
+		},
+		"RelativePluginHome": {
+			reason: "The plugin home should be an absolute path.",
+			data:   "{plugins: enabled, pluginHome: plugins}",
+			want:   want{err: errors.Errorf(errFmtPluginHomeAbsolute, "plugins")},
+		},
+		"UnknownReorder": {
+			reason: "An unknown reorder mode should be rejected.",
+			data:   "{reorder: kind}",
+			want:   want{err: errors.Errorf(errFmtReorder, "kind")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseBuildOptions(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseBuildOptions(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.b, got); diff != "" {
+				t.Errorf("\n%s\nParseBuildOptions(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestKrustyOptions(t *testing.T) {
+	got := NewKustomizeEngine(nil).krustyOptions()
+	if got.DoLegacyResourceSort || got.LoadRestrictions != types.LoadRestrictionsRootOnly || got.PluginConfig.PluginRestrictions != types.PluginRestrictionsBuiltinsOnly {
+		t.Errorf("krustyOptions(): want the declared order, root only loading and builtin plugins by default, got %+v", got)
+	}
+	got = NewKustomizeEngine(nil, WithBuildOptions(BuildOptions{
+		LoadRestrictor: LoadRestrictorNone,
+		Plugins:        PluginsEnabled,
+		PluginHome:     "/plugins",
+		Reorder:        ReorderLegacy,
+	})).krustyOptions()
+	if !got.DoLegacyResourceSort || got.LoadRestrictions != types.LoadRestrictionsNone {
+		t.Errorf("krustyOptions(): want the legacy order and unrestricted loading, got %+v", got)
+	}
+	if got.PluginConfig.PluginRestrictions != types.PluginRestrictionsNone || got.PluginConfig.AbsPluginHome != "/plugins" {
+		t.Errorf("krustyOptions(): want the plugins in the plugin home to be enabled, got %+v", got.PluginConfig)
+	}
+}
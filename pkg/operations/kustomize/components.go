@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/filesys"
+	kustomizeapi "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ComponentsAnnotationKey is the annotation on the StackDefinition whose
+	// value maps the components of the kustomization to the boolean fields
+	// of the parent resources that enable them, e.g.
+	// components/monitoring: spec.monitoring.enabled
+	ComponentsAnnotationKey = "templatestacks.crossplane.io/kustomize-components"
+
+	componentKind = "Component"
+
+	errParseComponentToggles = "could not parse the component toggles"
+	errComponents            = "cannot apply the components"
+	errFmtComponentToggle    = "field %s that toggles the component %s is not a boolean"
+	errFmtNotComponent       = "the kustomization of %s is not a Component"
+)
+
+// A Component is a kustomize component, i.e. a directory whose
+// kustomization of kind Component is applied on top of the base resources.
+type Component struct {
+	// Path is the directory of the component, relative to the resource path
+	// of the Engine.
+	Path string
+
+	// Field is the path of the boolean field of the parent resource that
+	// enables the component. The component is always enabled if it's empty,
+	// and disabled if the field is not set.
+	Field string
+}
+
+// ParseComponentToggles parses the given YAML representation of the map of
+// component directories to the fields that enable them, typically the value
+// of ComponentsAnnotationKey annotation.
+func ParseComponentToggles(data string) (map[string]string, error) {
+	t := map[string]string{}
+	return t, errors.Wrap(yaml.Unmarshal([]byte(data), &t), errParseComponentToggles)
+}
+
+// WithComponents allows you to apply the given components on top of the base
+// resources, in the given order, if the parent resources enable them.
+func WithComponents(c ...Component) Option {
+	return func(ko *Engine) {
+		ko.Components = c
+	}
+}
+
+// enabledComponents returns the components that the given parent resource
+// enables.
+func (o *Engine) enabledComponents(cr resource.ParentResource) ([]Component, error) {
+	var enabled []Component
+	for _, c := range o.Components {
+		if c.Field == "" {
+			enabled = append(enabled, c)
+			continue
+		}
+		on, _, err := unstructured.NestedBool(cr.UnstructuredContent(), strings.Split(c.Field, ".")...)
+		if err != nil {
+			return nil, &resource.ValuesError{Path: c.Field, Err: errors.Wrapf(err, errFmtComponentToggle, c.Field, c.Path)}
+		}
+		if on {
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled, nil
+}
+
+// applyComponents returns a directory whose kustomization lists the base
+// resources in the given resource path with the components that the given
+// parent resource enables applied on top of them, or the resource path if
+// it enables none. Kustomize doesn't support components natively, so every
+// component is applied as a kustomization whose resources are the output of
+// the previous one. The returned directory should be removed by the caller
+// if it's not the resource path.
+func (o *Engine) applyComponents(cr resource.ParentResource, resourcePath string) (string, error) {
+	enabled, err := o.enabledComponents(cr)
+	if err != nil || len(enabled) == 0 {
+		return resourcePath, err
+	}
+	list, err := o.kustomize(resourcePath)
+	if err != nil {
+		return resourcePath, err
+	}
+	for _, c := range enabled {
+		dir, err := prepareComponent(filepath.Join(o.ResourcePath, c.Path), list)
+		if err == nil {
+			list, err = o.kustomize(dir)
+		}
+		_ = os.RemoveAll(dir)
+		if err != nil {
+			return resourcePath, err
+		}
+	}
+	tempConfirmedDir, err := filesys.NewTmpConfirmedDir()
+	if err != nil {
+		return resourcePath, err
+	}
+	dir := string(tempConfirmedDir)
+	return dir, writeInput(dir, list)
+}
+
+// prepareComponent returns a temporary copy of the given component directory
+// whose kustomization is turned into a regular one that lists the given child
+// resources among its resources.
+func prepareComponent(path string, list []resource.ChildResource) (string, error) {
+	dir, err := prepareInput(path, list)
+	if err != nil {
+		return dir, err
+	}
+	file := filepath.Join(dir, kustomizationFileName)
+	data, err := ioutil.ReadFile(filepath.Clean(file))
+	if err != nil {
+		return dir, err
+	}
+	k := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &k); err != nil {
+		return dir, err
+	}
+	if k["kind"] != componentKind {
+		return dir, errors.Errorf(errFmtNotComponent, path)
+	}
+	k["apiVersion"] = kustomizeapi.KustomizationVersion
+	k["kind"] = kustomizeapi.KustomizationKind
+	resources, _ := k["resources"].([]interface{})
+	k["resources"] = append(resources, InputFileName)
+	if data, err = yaml.Marshal(k); err != nil {
+		return dir, err
+	}
+	return dir, ioutil.WriteFile(file, data, os.ModePerm)
+}
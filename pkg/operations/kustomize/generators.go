@@ -0,0 +1,275 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// DataGeneratorsAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of DataGenerators.
+	DataGeneratorsAnnotationKey = "templatestacks.crossplane.io/kustomize-generators"
+
+	dataGeneratorFilePrefix = "datagenerator-"
+
+	errParseDataGenerators    = "could not parse the generators"
+	errFmtGeneratorName       = "%s generator %d should have a name"
+	errFmtGeneratorDuplicate  = "%s generator %s is declared more than once"
+	errFmtGeneratorKey        = "key %q of %s generator %s is not a valid key"
+	errFmtGeneratorFromField  = "key %s of %s generator %s should have a fromField"
+	errFmtGeneratorNotValue   = "value of %s is not a string, number or boolean"
+	errFmtGeneratorMarshalVal = "cannot marshal the value of %s"
+)
+
+// DataGenerators declare the configMapGenerator and secretGenerator of the
+// kustomization whose data is read from the fields of the parent resource.
+type DataGenerators struct {
+	// ConfigMaps are added to the configMapGenerator of the kustomization.
+	// +optional
+	ConfigMaps []DataGenerator `json:"configMaps,omitempty"`
+
+	// Secrets are added to the secretGenerator of the kustomization.
+	// +optional
+	Secrets []DataGenerator `json:"secrets,omitempty"`
+}
+
+// A DataGenerator generates a ConfigMap or a Secret whose data is bound to
+// the fields of the parent resource. The generator with the same name in the
+// kustomization, if any, is replaced.
+type DataGenerator struct {
+	// Name of the generated ConfigMap or Secret. Kustomize appends the hash
+	// of its data to it and updates the references to it.
+	Name string `json:"name"`
+
+	// Namespace of the generated ConfigMap or Secret.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Behavior is one of create, replace and merge.
+	// +optional
+	Behavior string `json:"behavior,omitempty"`
+
+	// Type of the generated Secret, e.g. kubernetes.io/tls.
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// Literals are the keys whose values are strings, numbers or booleans,
+	// e.g. a setting.
+	// +optional
+	Literals []DataBinding `json:"literals,omitempty"`
+
+	// Files are the keys whose values are written to files that the
+	// generator reads, e.g. a configuration file. A value that is not a
+	// string is written as YAML.
+	// +optional
+	Files []DataBinding `json:"files,omitempty"`
+}
+
+// A DataBinding binds a key of the data of a generated ConfigMap or Secret to
+// a field of the parent resource. The key is left out if the parent resource
+// doesn't have the field.
+type DataBinding struct {
+	// Key of the data.
+	Key string `json:"key"`
+
+	// FromField is the dot separated path of the field of the parent
+	// resource whose value is used, e.g. spec.config.
+	FromField string `json:"fromField"`
+}
+
+// Fields returns the fields of the parent resources that the data of the
+// generators is read from.
+func (g DataGenerators) Fields() []string {
+	var fields []string
+	for _, gen := range append(append([]DataGenerator{}, g.ConfigMaps...), g.Secrets...) {
+		for _, b := range append(append([]DataBinding{}, gen.Literals...), gen.Files...) {
+			fields = append(fields, b.FromField)
+		}
+	}
+	return fields
+}
+
+// ParseDataGenerators parses and validates the given YAML representation of
+// the generators, typically the value of DataGeneratorsAnnotationKey
+// annotation.
+func ParseDataGenerators(data string) (DataGenerators, error) {
+	g := DataGenerators{}
+	if err := yaml.Unmarshal([]byte(data), &g); err != nil {
+		return DataGenerators{}, errors.Wrap(err, errParseDataGenerators)
+	}
+	if err := validateDataGenerators("configMap", g.ConfigMaps); err != nil {
+		return DataGenerators{}, err
+	}
+	if err := validateDataGenerators("secret", g.Secrets); err != nil {
+		return DataGenerators{}, err
+	}
+	return g, nil
+}
+
+// validateDataGenerators returns an error if any of the given ConfigMap or
+// Secret generators is not valid.
+func validateDataGenerators(kind string, gens []DataGenerator) error {
+	seen := map[string]bool{}
+	for i, gen := range gens {
+		if gen.Name == "" {
+			return errors.Errorf(errFmtGeneratorName, kind, i)
+		}
+		if seen[gen.Name] {
+			return errors.Errorf(errFmtGeneratorDuplicate, kind, gen.Name)
+		}
+		seen[gen.Name] = true
+		for _, b := range append(append([]DataBinding{}, gen.Literals...), gen.Files...) {
+			if len(validation.IsConfigMapKey(b.Key)) != 0 {
+				return errors.Errorf(errFmtGeneratorKey, b.Key, kind, gen.Name)
+			}
+			if b.FromField == "" {
+				return errors.Errorf(errFmtGeneratorFromField, b.Key, kind, gen.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// NewDataOverlayGenerator returns a new DataOverlayGenerator.
+func NewDataOverlayGenerator(g DataGenerators) DataOverlayGenerator {
+	return DataOverlayGenerator{
+		Generators: g,
+	}
+}
+
+// DataOverlayGenerator adds the ConfigMap and Secret generators to the
+// kustomization, and writes the files that they read to the overlay folder.
+type DataOverlayGenerator struct {
+	Generators DataGenerators
+}
+
+// Generate produces files to be written to the overlay folder of kustomization
+// process.
+func (dog DataOverlayGenerator) Generate(cr resource.ParentResource, k *types.Kustomization) ([]OverlayFile, error) {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// generators of the previous one are replaced.
+	var files []OverlayFile
+	configMaps := make([]types.ConfigMapArgs, 0, len(k.ConfigMapGenerator)+len(dog.Generators.ConfigMaps))
+	for _, a := range k.ConfigMapGenerator {
+		if !declared(dog.Generators.ConfigMaps, a.Name) {
+			configMaps = append(configMaps, a)
+		}
+	}
+	for i, gen := range dog.Generators.ConfigMaps {
+		args, f, err := generatorArgs(cr, gen, fmt.Sprintf("%sconfigmap-%d-", dataGeneratorFilePrefix, i))
+		if err != nil {
+			return nil, err
+		}
+		configMaps = append(configMaps, types.ConfigMapArgs{GeneratorArgs: args})
+		files = append(files, f...)
+	}
+	secrets := make([]types.SecretArgs, 0, len(k.SecretGenerator)+len(dog.Generators.Secrets))
+	for _, a := range k.SecretGenerator {
+		if !declared(dog.Generators.Secrets, a.Name) {
+			secrets = append(secrets, a)
+		}
+	}
+	for i, gen := range dog.Generators.Secrets {
+		args, f, err := generatorArgs(cr, gen, fmt.Sprintf("%ssecret-%d-", dataGeneratorFilePrefix, i))
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, types.SecretArgs{GeneratorArgs: args, Type: gen.Type})
+		files = append(files, f...)
+	}
+	k.ConfigMapGenerator, k.SecretGenerator = configMaps, secrets
+	return files, nil
+}
+
+// declared returns true if a generator with the given name is among the given
+// generators.
+func declared(gens []DataGenerator, name string) bool {
+	for _, gen := range gens {
+		if gen.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// generatorArgs returns the kustomize representation of the given generator
+// with the values read from the given parent resource, and the files with the
+// given prefix that it reads.
+func generatorArgs(cr resource.ParentResource, gen DataGenerator, prefix string) (types.GeneratorArgs, []OverlayFile, error) {
+	args := types.GeneratorArgs{Namespace: gen.Namespace, Name: gen.Name, Behavior: gen.Behavior}
+	for _, b := range gen.Literals {
+		val, ok, err := generatorValue(cr, b.FromField, false)
+		if err != nil {
+			return types.GeneratorArgs{}, nil, err
+		}
+		if ok {
+			args.LiteralSources = append(args.LiteralSources, fmt.Sprintf("%s=%s", b.Key, val))
+		}
+	}
+	var files []OverlayFile
+	for j, b := range gen.Files {
+		val, ok, err := generatorValue(cr, b.FromField, true)
+		if err != nil {
+			return types.GeneratorArgs{}, nil, err
+		}
+		if !ok {
+			continue
+		}
+		name := fmt.Sprintf("%s%d", prefix, j)
+		args.FileSources = append(args.FileSources, fmt.Sprintf("%s=%s", b.Key, name))
+		files = append(files, OverlayFile{Name: name, Data: []byte(val)})
+	}
+	return args, files, nil
+}
+
+// generatorValue returns the value of the field of the given parent resource
+// at the given path and whether it's set. The values of files that are not
+// strings are marshalled to YAML, the values of literals should be strings,
+// numbers or booleans.
+func generatorValue(cr resource.ParentResource, path string, file bool) (string, bool, error) {
+	val, ok, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(path, ".")...)
+	if err != nil {
+		return "", false, &resource.ValuesError{Path: path, Err: err}
+	}
+	if !ok || val == nil {
+		return "", false, nil
+	}
+	switch v := val.(type) {
+	case string:
+		return v, true, nil
+	case bool, int64, float64:
+		return fmt.Sprint(v), true, nil
+	}
+	if !file {
+		return "", false, &resource.ValuesError{Path: path, Err: errors.Errorf(errFmtGeneratorNotValue, path)}
+	}
+	data, err := yaml.Marshal(val)
+	if err != nil {
+		return "", false, &resource.ValuesError{Path: path, Err: errors.Wrapf(err, errFmtGeneratorMarshalVal, path)}
+	}
+	return string(data), true, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseDataGenerators(t *testing.T) {
+	type want struct {
+		g   DataGenerators
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid generators should be parsed.",
+			data: `
+configMaps:
+- name: app-config
+  literals: [{key: LOG_LEVEL, fromField: spec.logLevel}]
+  files: [{key: app.yaml, fromField: spec.config}]
+secrets:
+- name: app-credentials
+  type: Opaque
+  literals: [{key: password, fromField: spec.password}]
+`,
+			want: want{g: DataGenerators{
+				ConfigMaps: []DataGenerator{{
+					Name:     "app-config",
+					Literals: []DataBinding{{Key: "LOG_LEVEL", FromField: "spec.logLevel"}},
+					Files:    []DataBinding{{Key: "app.yaml", FromField: "spec.config"}},
+				}},
+				Secrets: []DataGenerator{{
+					Name:     "app-credentials",
+					Type:     "Opaque",
+					Literals: []DataBinding{{Key: "password", FromField: "spec.password"}},
+				}},
+			}},
+		},
+		"NoName": {
+			reason: "A generator without a name should be rejected.",
+			data:   `configMaps: [{literals: []}]`,
+			want:   want{err: errors.Errorf(errFmtGeneratorName, "configMap", 0)},
+		},
+		"Duplicate": {
+			reason: "A generator should not be declared more than once.",
+			data:   `secrets: [{name: creds}, {name: creds}]`,
+			want:   want{err: errors.Errorf(errFmtGeneratorDuplicate, "secret", "creds")},
+		},
+		"InvalidKey": {
+			reason: "A key that is not a valid ConfigMap key should be rejected.",
+			data:   `configMaps: [{name: app, literals: [{key: "a b", fromField: spec.a}]}]`,
+			want:   want{err: errors.Errorf(errFmtGeneratorKey, "a b", "configMap", "app")},
+		},
+		"NoFromField": {
+			reason: "A key without a fromField should be rejected.",
+			data:   `configMaps: [{name: app, files: [{key: app.yaml}]}]`,
+			want:   want{err: errors.Errorf(errFmtGeneratorFromField, "app.yaml", "configMap", "app")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseDataGenerators(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseDataGenerators(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.g, got); diff != "" {
+				t.Errorf("\n%s\nParseDataGenerators(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDataOverlayGenerator(t *testing.T) {
+	g := DataGenerators{
+		ConfigMaps: []DataGenerator{{
+			Name: "app-config",
+			Literals: []DataBinding{
+				{Key: "LOG_LEVEL", FromField: "spec.logLevel"},
+				{Key: "REPLICAS", FromField: "spec.replicas"},
+			},
+			Files: []DataBinding{{Key: "app.yaml", FromField: "spec.config"}},
+		}},
+		Secrets: []DataGenerator{{
+			Name:     "app-credentials",
+			Literals: []DataBinding{{Key: "password", FromField: "spec.password"}},
+		}},
+	}
+	static := types.ConfigMapArgs{GeneratorArgs: types.GeneratorArgs{Name: "static"}}
+	parent := func(spec map[string]interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	}
+
+	type want struct {
+		files      []OverlayFile
+		configMaps []types.ConfigMapArgs
+		secrets    []types.SecretArgs
+		err        error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		k      *types.Kustomization
+		want   want
+	}{
+		"Generated": {
+			reason: "The generators should read their data from the parent resource and replace the ones with the same name.",
+			cr: parent(map[string]interface{}{
+				"logLevel": "debug",
+				"replicas": int64(3),
+				"config":   map[string]interface{}{"port": int64(8080)},
+				"password": "s3cr3t",
+			}),
+			k: &types.Kustomization{ConfigMapGenerator: []types.ConfigMapArgs{static, {GeneratorArgs: types.GeneratorArgs{Name: "app-config"}}}},
+			want: want{
+				files: []OverlayFile{{Name: "datagenerator-configmap-0-0", Data: []byte("port: 8080\n")}},
+				configMaps: []types.ConfigMapArgs{static, {GeneratorArgs: types.GeneratorArgs{
+					Name: "app-config",
+					KvPairSources: types.KvPairSources{
+						LiteralSources: []string{"LOG_LEVEL=debug", "REPLICAS=3"},
+						FileSources:    []string{"app.yaml=datagenerator-configmap-0-0"},
+					},
+				}}},
+				secrets: []types.SecretArgs{{GeneratorArgs: types.GeneratorArgs{
+					Name:          "app-credentials",
+					KvPairSources: types.KvPairSources{LiteralSources: []string{"password=s3cr3t"}},
+				}}},
+			},
+		},
+		"Unset": {
+			reason: "The keys whose fields the parent resource doesn't have should be left out.",
+			cr:     parent(map[string]interface{}{}),
+			k:      &types.Kustomization{},
+			want: want{
+				configMaps: []types.ConfigMapArgs{{GeneratorArgs: types.GeneratorArgs{Name: "app-config"}}},
+				secrets:    []types.SecretArgs{{GeneratorArgs: types.GeneratorArgs{Name: "app-credentials"}}},
+			},
+		},
+		"NotLiteral": {
+			reason: "A literal whose value is not a string, number or boolean should be rejected.",
+			cr:     parent(map[string]interface{}{"logLevel": []interface{}{"debug"}}),
+			k:      &types.Kustomization{},
+			want: want{
+				err: &resource.ValuesError{Path: "spec.logLevel", Err: errors.Errorf(errFmtGeneratorNotValue, "spec.logLevel")},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewDataOverlayGenerator(g).Generate(tc.cr, tc.k)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.files, got); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want files, +got files:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.configMaps, tc.k.ConfigMapGenerator); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want configMapGenerator, +got configMapGenerator:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.secrets, tc.k.SecretGenerator); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want secretGenerator, +got secretGenerator:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
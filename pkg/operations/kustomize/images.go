@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ImagesAnnotationKey is the annotation on the StackDefinition whose value
+	// is the YAML representation of a list of ImageBinding.
+	ImagesAnnotationKey = "templatestacks.crossplane.io/kustomize-images"
+
+	errParseImages       = "could not parse the image bindings"
+	errFmtImageName      = "image binding %d should have a name"
+	errFmtImageDuplicate = "image %s is bound more than once"
+	errFmtImageNotString = "value of %s is not a string"
+)
+
+// An ImageBinding binds the new name, tag or digest of an image in the images
+// transformer of the kustomization to the fields of the parent resource, so
+// that the image of the rendered containers can be pinned in the parent
+// resource. The entry of the image in the images of the kustomization, if
+// any, is replaced.
+type ImageBinding struct {
+	// Name is the name of the image in the resources, e.g. nginx.
+	Name string `json:"name"`
+
+	// NewName is the name that the image is replaced with unless
+	// NewNameFrom is set in the parent resource.
+	// +optional
+	NewName string `json:"newName,omitempty"`
+
+	// NewNameFrom is the dot separated path of the field of the parent
+	// resource whose value the image is renamed to, e.g. spec.image.
+	// +optional
+	NewNameFrom string `json:"newNameFrom,omitempty"`
+
+	// NewTag is the tag that the image is pinned to unless NewTagFrom is set
+	// in the parent resource.
+	// +optional
+	NewTag string `json:"newTag,omitempty"`
+
+	// NewTagFrom is the dot separated path of the field of the parent
+	// resource whose value the image is pinned to as its tag, e.g. spec.tag.
+	// +optional
+	NewTagFrom string `json:"newTagFrom,omitempty"`
+
+	// DigestFrom is the dot separated path of the field of the parent
+	// resource whose value the image is pinned to as its digest, e.g.
+	// spec.digest. It takes precedence over the tag if both are set.
+	// +optional
+	DigestFrom string `json:"digestFrom,omitempty"`
+}
+
+// ParseImageBindings parses and validates the given YAML representation of
+// the image bindings, typically the value of ImagesAnnotationKey annotation.
+func ParseImageBindings(data string) ([]ImageBinding, error) {
+	var images []ImageBinding
+	if err := yaml.Unmarshal([]byte(data), &images); err != nil {
+		return nil, errors.Wrap(err, errParseImages)
+	}
+	seen := map[string]bool{}
+	for i, img := range images {
+		if img.Name == "" {
+			return nil, errors.Errorf(errFmtImageName, i)
+		}
+		if seen[img.Name] {
+			return nil, errors.Errorf(errFmtImageDuplicate, img.Name)
+		}
+		seen[img.Name] = true
+	}
+	return images, nil
+}
+
+// ImageFields returns the fields of the parent resources that the given image
+// bindings read from.
+func ImageFields(images []ImageBinding) []string {
+	var fields []string
+	for _, img := range images {
+		for _, f := range []string{img.NewNameFrom, img.NewTagFrom, img.DigestFrom} {
+			if f != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// NewImageOverlayGenerator returns a new ImageOverlayGenerator.
+func NewImageOverlayGenerator(images []ImageBinding) ImageOverlayGenerator {
+	return ImageOverlayGenerator{
+		Images: images,
+	}
+}
+
+// ImageOverlayGenerator sets the images of the kustomization from the fields
+// of the parent resource.
+type ImageOverlayGenerator struct {
+	Images []ImageBinding
+}
+
+// Generate produces files to be written to the overlay folder of kustomization
+// process.
+func (iog ImageOverlayGenerator) Generate(cr resource.ParentResource, k *types.Kustomization) ([]OverlayFile, error) {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// bound images are dropped first rather than left with the values of the
+	// previous one. An image without a new name, tag or digest is not added.
+	bound := make(map[string]bool, len(iog.Images))
+	for _, img := range iog.Images {
+		bound[img.Name] = true
+	}
+	images := make([]types.Image, 0, len(k.Images)+len(iog.Images))
+	for _, img := range k.Images {
+		if !bound[img.Name] {
+			images = append(images, img)
+		}
+	}
+	for _, b := range iog.Images {
+		img := types.Image{Name: b.Name, NewName: b.NewName, NewTag: b.NewTag}
+		for _, f := range []struct {
+			path  string
+			value *string
+		}{{b.NewNameFrom, &img.NewName}, {b.NewTagFrom, &img.NewTag}, {b.DigestFrom, &img.Digest}} {
+			if f.path == "" {
+				continue
+			}
+			val, ok, err := imageValue(cr, f.path)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				*f.value = val
+			}
+		}
+		if img.NewName == "" && img.NewTag == "" && img.Digest == "" {
+			continue
+		}
+		images = append(images, img)
+	}
+	k.Images = images
+	return nil, nil
+}
+
+// imageValue returns the string value of the field of the given parent
+// resource at the given path and whether it's set.
+func imageValue(cr resource.ParentResource, path string) (string, bool, error) {
+	val, ok, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(path, ".")...)
+	if err != nil {
+		return "", false, &resource.ValuesError{Path: path, Err: err}
+	}
+	if !ok || val == nil {
+		return "", false, nil
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", false, &resource.ValuesError{Path: path, Err: errors.Errorf(errFmtImageNotString, path)}
+	}
+	return s, s != "", nil
+}
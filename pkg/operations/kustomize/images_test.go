@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseImageBindings(t *testing.T) {
+	type want struct {
+		images []ImageBinding
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid image bindings should be parsed.",
+			data:   `[{name: nginx, newTag: "1.19", newTagFrom: spec.version, digestFrom: spec.digest}]`,
+			want: want{images: []ImageBinding{
+				{Name: "nginx", NewTag: "1.19", NewTagFrom: "spec.version", DigestFrom: "spec.digest"},
+			}},
+		},
+		"NoName": {
+			reason: "A binding without a name should be rejected.",
+			data:   `[{newTagFrom: spec.version}]`,
+			want:   want{err: errors.Errorf(errFmtImageName, 0)},
+		},
+		"Duplicate": {
+			reason: "An image should not be bound more than once.",
+			data:   `[{name: nginx, newTagFrom: spec.version}, {name: nginx, newNameFrom: spec.image}]`,
+			want:   want{err: errors.Errorf(errFmtImageDuplicate, "nginx")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseImageBindings(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseImageBindings(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.images, got); diff != "" {
+				t.Errorf("\n%s\nParseImageBindings(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestImageOverlayGenerator(t *testing.T) {
+	images := []ImageBinding{
+		{Name: "nginx", NewTag: "1.19", NewTagFrom: "spec.version", DigestFrom: "spec.digest"},
+		{Name: "sidecar", NewNameFrom: "spec.sidecar"},
+	}
+	static := types.Image{Name: "busybox", NewTag: "1.32"}
+	parent := func(spec map[string]interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	}
+
+	type want struct {
+		images []types.Image
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		k      *types.Kustomization
+		want   want
+	}{
+		"Bound": {
+			reason: "The images should be read from the fields of the parent resource and replace the entries with the same name.",
+			cr:     parent(map[string]interface{}{"version": "1.20", "sidecar": "example.com/sidecar"}),
+			k:      &types.Kustomization{Images: []types.Image{static, {Name: "nginx", NewTag: "1.18"}}},
+			want: want{images: []types.Image{
+				static,
+				{Name: "nginx", NewTag: "1.20"},
+				{Name: "sidecar", NewName: "example.com/sidecar"},
+			}},
+		},
+		"Defaults": {
+			reason: "The literal values should be used if the parent resource doesn't have the fields, and an image without any should be left out.",
+			cr:     parent(map[string]interface{}{"digest": "sha256:abc"}),
+			k:      &types.Kustomization{Images: []types.Image{{Name: "sidecar", NewName: "previous"}}},
+			want: want{images: []types.Image{
+				{Name: "nginx", NewTag: "1.19", Digest: "sha256:abc"},
+			}},
+		},
+		"NotString": {
+			reason: "A field whose value is not a string should be rejected.",
+			cr:     parent(map[string]interface{}{"version": int64(2)}),
+			k:      &types.Kustomization{},
+			want: want{
+				err: &resource.ValuesError{Path: "spec.version", Err: errors.Errorf(errFmtImageNotString, "spec.version")},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewImageOverlayGenerator(images).Generate(tc.cr, tc.k)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.images, tc.k.Images); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
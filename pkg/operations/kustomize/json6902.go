@@ -0,0 +1,249 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/api/resid"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// Json6902PatchesAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of a list of Json6902Patch.
+	Json6902PatchesAnnotationKey = "templatestacks.crossplane.io/kustomize-json6902-patches"
+
+	json6902FilePrefix = "json6902patch-"
+
+	errParseJson6902Patches    = "could not parse the JSON 6902 patches"
+	errFmtJson6902Target       = "target of JSON 6902 patch %d should have a valid apiVersion, kind and name"
+	errFmtJson6902Op           = "operation %d of JSON 6902 patch %d has unsupported op %q"
+	errFmtJson6902Path         = "operation %d of JSON 6902 patch %d should have a path"
+	errFmtJson6902From         = "operation %d of JSON 6902 patch %d should have a from path"
+	errFmtJson6902Value        = "operation %d of JSON 6902 patch %d should have either a value or a valueFrom field"
+	errFmtJson6902NoValue      = "operation %d of JSON 6902 patch %d should not have a value"
+	errFmtJson6902MarshalPatch = "cannot marshal JSON 6902 patch %d"
+)
+
+// The operations of RFC 6902.
+const (
+	Json6902OpAdd     = "add"
+	Json6902OpRemove  = "remove"
+	Json6902OpReplace = "replace"
+	Json6902OpMove    = "move"
+	Json6902OpCopy    = "copy"
+	Json6902OpTest    = "test"
+)
+
+// A Json6902Patch is a JSON 6902 patch of an object in the resources of the
+// kustomization. Unlike a strategic merge patch, it can address the items of
+// lists by their index and remove fields.
+type Json6902Patch struct {
+	// Target is the object that the patch applies to.
+	Target Json6902Target `json:"target"`
+
+	// Operations are applied to the target in order.
+	Operations []Json6902Operation `json:"operations"`
+}
+
+// Json6902Target identifies the object that a Json6902Patch applies to. The
+// name is the one in the resources, i.e. before the name prefix is added.
+type Json6902Target struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// Json6902Operation is an operation of a Json6902Patch whose value is either
+// given or bound to a field of the parent resource.
+type Json6902Operation struct {
+	// Op is one of add, remove, replace, move, copy and test.
+	Op string `json:"op"`
+
+	// Path is the JSON pointer of the field that the operation applies to,
+	// e.g. /spec/template/spec/containers/0/image.
+	Path string `json:"path"`
+
+	// From is the JSON pointer of the field that is moved or copied.
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// Value is the value of add, replace and test operations.
+	// +optional
+	Value interface{} `json:"value,omitempty"`
+
+	// ValueFrom is the dot separated path of the field of the parent resource
+	// whose value is the value of the operation, e.g. spec.image. The
+	// operation is skipped if the field is not set.
+	// +optional
+	ValueFrom string `json:"valueFrom,omitempty"`
+}
+
+// ParseJson6902Patches parses and validates the given YAML representation of
+// the JSON 6902 patches, typically the value of Json6902PatchesAnnotationKey
+// annotation.
+func ParseJson6902Patches(data string) ([]Json6902Patch, error) {
+	var patches []Json6902Patch
+	if err := yaml.Unmarshal([]byte(data), &patches); err != nil {
+		return nil, errors.Wrap(err, errParseJson6902Patches)
+	}
+	for i, p := range patches {
+		if _, err := schema.ParseGroupVersion(p.Target.APIVersion); err != nil || p.Target.APIVersion == "" || p.Target.Kind == "" || p.Target.Name == "" {
+			return nil, errors.Errorf(errFmtJson6902Target, i)
+		}
+		for j, op := range p.Operations {
+			if err := validateJson6902Operation(op, i, j); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return patches, nil
+}
+
+// validateJson6902Operation returns an error if the given operation, at the
+// given index of the given patch, is not valid.
+func validateJson6902Operation(op Json6902Operation, patch, index int) error {
+	if op.Path == "" {
+		return errors.Errorf(errFmtJson6902Path, index, patch)
+	}
+	hasValue := op.Value != nil || op.ValueFrom != ""
+	switch op.Op {
+	case Json6902OpAdd, Json6902OpReplace, Json6902OpTest:
+		if !hasValue || (op.Value != nil && op.ValueFrom != "") {
+			return errors.Errorf(errFmtJson6902Value, index, patch)
+		}
+	case Json6902OpMove, Json6902OpCopy:
+		if op.From == "" {
+			return errors.Errorf(errFmtJson6902From, index, patch)
+		}
+		if hasValue {
+			return errors.Errorf(errFmtJson6902NoValue, index, patch)
+		}
+	case Json6902OpRemove:
+		if hasValue {
+			return errors.Errorf(errFmtJson6902NoValue, index, patch)
+		}
+	default:
+		return errors.Errorf(errFmtJson6902Op, index, patch, op.Op)
+	}
+	return nil
+}
+
+// Json6902ValueFields returns the fields of the parent resources that the
+// given patches read from.
+func Json6902ValueFields(patches []Json6902Patch) []string {
+	var fields []string
+	for _, p := range patches {
+		for _, op := range p.Operations {
+			if op.ValueFrom != "" {
+				fields = append(fields, op.ValueFrom)
+			}
+		}
+	}
+	return fields
+}
+
+// NewJson6902OverlayGenerator returns a new Json6902OverlayGenerator.
+func NewJson6902OverlayGenerator(patches []Json6902Patch) Json6902OverlayGenerator {
+	return Json6902OverlayGenerator{
+		Patches: patches,
+	}
+}
+
+// Json6902OverlayGenerator generates a JSON 6902 patch file for every given
+// patch and adds it to the patchesJson6902 of the kustomization.
+type Json6902OverlayGenerator struct {
+	Patches []Json6902Patch
+}
+
+// Generate produces files to be written to the overlay folder of kustomization
+// process.
+func (jog Json6902OverlayGenerator) Generate(cr resource.ParentResource, k *types.Kustomization) ([]OverlayFile, error) {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// patches of the previous one are dropped first. A patch all of whose
+	// operations are skipped is not added since kustomize rejects empty
+	// patches.
+	k.PatchesJson6902 = withoutGeneratedJson6902(k.PatchesJson6902, json6902FilePrefix)
+	var files []OverlayFile
+	for i, p := range jog.Patches {
+		ops := make([]map[string]interface{}, 0, len(p.Operations))
+		for _, op := range p.Operations {
+			o := map[string]interface{}{"op": op.Op, "path": op.Path}
+			if op.From != "" {
+				o["from"] = op.From
+			}
+			switch {
+			case op.ValueFrom != "":
+				val, exists, err := unstructured.NestedFieldCopy(cr.UnstructuredContent(), strings.Split(op.ValueFrom, ".")...)
+				if err != nil {
+					return nil, &resource.ValuesError{Path: op.ValueFrom, Err: err}
+				}
+				if !exists {
+					continue
+				}
+				o["value"] = val
+			case op.Value != nil:
+				o["value"] = op.Value
+			}
+			ops = append(ops, o)
+		}
+		if len(ops) == 0 {
+			continue
+		}
+		data, err := json.Marshal(ops)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtJson6902MarshalPatch, i)
+		}
+		name := fmt.Sprintf("%s%d.json", json6902FilePrefix, i)
+		k.PatchesJson6902 = append(k.PatchesJson6902, types.PatchJson6902{Target: patchTarget(p.Target), Path: name})
+		files = append(files, OverlayFile{Name: name, Data: data})
+	}
+	return files, nil
+}
+
+// patchTarget returns the kustomize representation of the given target.
+func patchTarget(t Json6902Target) *types.PatchTarget {
+	gv, _ := schema.ParseGroupVersion(t.APIVersion)
+	return &types.PatchTarget{
+		Gvk:       resid.Gvk{Group: gv.Group, Version: gv.Version, Kind: t.Kind},
+		Namespace: t.Namespace,
+		Name:      t.Name,
+	}
+}
+
+// withoutGeneratedJson6902 returns the given patches without the ones whose
+// files have the given prefix, i.e. that are generated by an overlay
+// generator.
+func withoutGeneratedJson6902(patches []types.PatchJson6902, prefix string) []types.PatchJson6902 {
+	result := make([]types.PatchJson6902, 0, len(patches))
+	for _, p := range patches {
+		if !strings.HasPrefix(p.Path, prefix) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
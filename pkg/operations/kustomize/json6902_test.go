@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/resid"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseJson6902Patches(t *testing.T) {
+	type want struct {
+		patches []Json6902Patch
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid patches should be parsed.",
+			data: `
+- target: {apiVersion: apps/v1, kind: Deployment, name: app}
+  operations:
+  - {op: replace, path: /spec/template/spec/containers/0/image, valueFrom: spec.image}
+  - {op: remove, path: /spec/template/spec/containers/1}
+  - {op: add, path: /metadata/labels/tier, value: web}
+`,
+			want: want{patches: []Json6902Patch{{
+				Target: Json6902Target{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"},
+				Operations: []Json6902Operation{
+					{Op: Json6902OpReplace, Path: "/spec/template/spec/containers/0/image", ValueFrom: "spec.image"},
+					{Op: Json6902OpRemove, Path: "/spec/template/spec/containers/1"},
+					{Op: Json6902OpAdd, Path: "/metadata/labels/tier", Value: "web"},
+				},
+			}}},
+		},
+		"NoTargetName": {
+			reason: "A patch whose target has no name should be rejected.",
+			data:   `[{target: {apiVersion: apps/v1, kind: Deployment}, operations: []}]`,
+			want:   want{err: errors.Errorf(errFmtJson6902Target, 0)},
+		},
+		"UnsupportedOp": {
+			reason: "An operation that is not in RFC 6902 should be rejected.",
+			data:   `[{target: {apiVersion: v1, kind: ConfigMap, name: cm}, operations: [{op: merge, path: /data}]}]`,
+			want:   want{err: errors.Errorf(errFmtJson6902Op, 0, 0, "merge")},
+		},
+		"ValueAndValueFrom": {
+			reason: "An operation should not have both a value and a valueFrom field.",
+			data:   `[{target: {apiVersion: v1, kind: ConfigMap, name: cm}, operations: [{op: add, path: /data/a, value: b, valueFrom: spec.a}]}]`,
+			want:   want{err: errors.Errorf(errFmtJson6902Value, 0, 0)},
+		},
+		"RemoveWithValue": {
+			reason: "A remove operation should not have a value.",
+			data:   `[{target: {apiVersion: v1, kind: ConfigMap, name: cm}, operations: [{op: remove, path: /data/a, valueFrom: spec.a}]}]`,
+			want:   want{err: errors.Errorf(errFmtJson6902NoValue, 0, 0)},
+		},
+		"MoveWithoutFrom": {
+			reason: "A move operation should have a from path.",
+			data:   `[{target: {apiVersion: v1, kind: ConfigMap, name: cm}, operations: [{op: move, path: /data/a}]}]`,
+			want:   want{err: errors.Errorf(errFmtJson6902From, 0, 0)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseJson6902Patches(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseJson6902Patches(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patches, got); diff != "" {
+				t.Errorf("\n%s\nParseJson6902Patches(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestJson6902OverlayGenerator(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"image": "nginx:1.19"},
+	}}
+	static := types.PatchJson6902{Target: &types.PatchTarget{Name: "static"}, Path: "static.json"}
+	target := Json6902Target{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"}
+	kustomizeTarget := &types.PatchTarget{Gvk: resid.Gvk{Group: "apps", Version: "v1", Kind: "Deployment"}, Name: "app"}
+
+	type want struct {
+		files   []OverlayFile
+		patches []types.PatchJson6902
+	}
+	cases := map[string]struct {
+		reason  string
+		patches []Json6902Patch
+		k       *types.Kustomization
+		want    want
+	}{
+		"Generated": {
+			reason: "The operations should get their values from the parent resource and the patch should be added to the kustomization.",
+			patches: []Json6902Patch{{
+				Target: target,
+				Operations: []Json6902Operation{
+					{Op: Json6902OpReplace, Path: "/spec/template/spec/containers/0/image", ValueFrom: "spec.image"},
+					{Op: Json6902OpRemove, Path: "/spec/template/spec/containers/1"},
+				},
+			}},
+			k: &types.Kustomization{PatchesJson6902: []types.PatchJson6902{static}},
+			want: want{
+				files: []OverlayFile{{
+					Name: "json6902patch-0.json",
+					Data: []byte(`[{"op":"replace","path":"/spec/template/spec/containers/0/image","value":"nginx:1.19"},{"op":"remove","path":"/spec/template/spec/containers/1"}]`),
+				}},
+				patches: []types.PatchJson6902{static, {Target: kustomizeTarget, Path: "json6902patch-0.json"}},
+			},
+		},
+		"Skipped": {
+			reason: "A patch whose operations are all skipped should not be added, and the patches generated for the previous parent resource should be dropped.",
+			patches: []Json6902Patch{{
+				Target:     target,
+				Operations: []Json6902Operation{{Op: Json6902OpAdd, Path: "/spec/replicas", ValueFrom: "spec.replicas"}},
+			}},
+			k: &types.Kustomization{PatchesJson6902: []types.PatchJson6902{static, {Target: kustomizeTarget, Path: "json6902patch-0.json"}}},
+			want: want{
+				patches: []types.PatchJson6902{static},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewJson6902OverlayGenerator(tc.patches).Generate(cr, tc.k)
+			if err != nil {
+				t.Fatalf("\n%s\nGenerate(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want.files, got); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.patches, tc.k.PatchesJson6902); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want patches, +got patches:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -17,9 +17,14 @@ limitations under the License.
 package kustomize
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -28,6 +33,7 @@ import (
 	kustomizeapi "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 
+	"github.com/crossplane/templating-controller/pkg/operations/gotemplate"
 	"github.com/crossplane/templating-controller/pkg/resource"
 )
 
@@ -35,12 +41,84 @@ const (
 	defaultResourcesPath  = "resources"
 	kustomizationFileName = "kustomization.yaml"
 
-	errPatch              = "patch call failed"
-	errOverlayPreparation = "overlay preparation failed"
-	errOverlayGeneration  = "overlay generation failed"
-	errKustomizeCall      = "kustomize call failed"
+	// TemplateFileName is the name of the Go template file in the resource
+	// path that is rendered into its kustomization.yaml, see
+	// Engine.renderTemplate.
+	TemplateFileName = "kustomization.yaml.tmpl"
+
+	// InputFileName is the name of the file that the child resources
+	// rendered by the previous stage of an engine pipeline are written to.
+	InputFileName = "rendered.yaml"
+
+	// VariantsAnnotationKey is the annotation on the StackDefinition whose
+	// value is the YAML representation of Variants.
+	VariantsAnnotationKey = "templatestacks.crossplane.io/kustomize-variants"
+
+	errPatch               = "patch call failed"
+	errOverlayPreparation  = "overlay preparation failed"
+	errInputPreparation    = "input preparation failed"
+	errOverlayGeneration   = "overlay generation failed"
+	errKustomizeCall       = "kustomize call failed"
+	errTemplatePreparation = "kustomization template preparation failed"
+	errParseTemplate       = "cannot parse the kustomization template"
+	errExecTemplate        = "cannot execute the kustomization template"
+	errParseVariants       = "could not parse the variants"
+	errVariantSelection    = "variant selection failed"
+	errFmtVariantNotFound  = "variant %s is not declared"
+	errFmtVariantNotStr    = "variant field %s is not a string"
+	errVariantField        = "variants should have a field"
+	errFmtVariantNoDir     = "overlay directory %s of variant %s does not exist"
 )
 
+// Variants are the named overlay directories that a parent resource can
+// choose from to be composed on top of the base resources.
+type Variants struct {
+	// Field is the path of the field in the parent resource whose value is
+	// the name of the selected variant, e.g. spec.variant. The base resources
+	// are used as is if the field is empty.
+	Field string `json:"field"`
+
+	// Overlays maps the name of the variant to its overlay directory. The
+	// path is relative to the resource path of the Engine and the overlay
+	// is expected to refer to the base resources.
+	Overlays map[string]string `json:"overlays"`
+}
+
+// ParseVariants parses the given YAML representation of the variants,
+// typically the value of VariantsAnnotationKey annotation.
+func ParseVariants(data string) (Variants, error) {
+	v := Variants{}
+	if err := yaml.Unmarshal([]byte(data), &v); err != nil {
+		return Variants{}, errors.Wrap(err, errParseVariants)
+	}
+	if v.Field == "" && len(v.Overlays) != 0 {
+		return Variants{}, errors.New(errVariantField)
+	}
+	return v, nil
+}
+
+// Names returns the sorted names of the variants.
+func (v Variants) Names() []string {
+	names := make([]string, 0, len(v.Overlays))
+	for name := range v.Overlays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate returns an error if the overlay directory of any of the variants
+// does not exist in the given resource path.
+func (v Variants) Validate(resourcePath string) error {
+	for _, name := range v.Names() {
+		info, err := os.Stat(filepath.Join(resourcePath, v.Overlays[name]))
+		if err != nil || !info.IsDir() {
+			return errors.Errorf(errFmtVariantNoDir, v.Overlays[name], name)
+		}
+	}
+	return nil
+}
+
 // WithResourcePath allows you to specify a kustomization folder other than default.
 func WithResourcePath(path string) Option {
 	return func(ko *Engine) {
@@ -64,6 +142,40 @@ func WithOverlayGenerator(op ...OverlayGenerator) Option {
 	}
 }
 
+// WithVariants allows you to let the parent resources select one of the
+// given overlay directories to be used instead of the base resources.
+func WithVariants(v Variants) Option {
+	return func(ko *Engine) {
+		ko.Variants = v
+	}
+}
+
+// WithLegacyResourceSort makes the Engine return the resources sorted by
+// kind as kustomize does by default, instead of the order they are declared.
+func WithLegacyResourceSort() Option {
+	return func(ko *Engine) {
+		ko.LegacyResourceSort = true
+	}
+}
+
+// WithNaming replaces the NamePrefixer of the Engine with the given Patcher
+// that names the child resources. The names are left as they are if it's
+// nil.
+func WithNaming(p Patcher) Option {
+	return func(ko *Engine) {
+		ko.Naming = p
+	}
+}
+
+// WithRemoteBases allows the resources of the kustomization to refer to
+// directories of git repositories, which are fetched and cached by the given
+// *RemoteBaseCache.
+func WithRemoteBases(c *RemoteBaseCache) Option {
+	return func(ko *Engine) {
+		ko.RemoteBases = c
+	}
+}
+
 // NewKustomizeEngine returns a Engine object. rootPath should
 // point to the folder where your base kustomization.yaml resides and patcher
 // is the chain of Patcher that makes modifications of Kustomization
@@ -75,11 +187,9 @@ func NewKustomizeEngine(k *kustomizeapi.Kustomization, opt ...Option) *Engine {
 	ko := &Engine{
 		ResourcePath:  defaultResourcesPath,
 		Kustomization: k,
-		Patchers: PatcherChain{
-			// TODO(muvaf): think how this should work if name prefix is already
-			// given.
-			NewNamePrefixer(),
-		},
+		// TODO(muvaf): think how this should work if name prefix is already
+		// given.
+		Naming: NewNamePrefixer(),
 	}
 
 	for _, f := range opt {
@@ -100,6 +210,11 @@ type Engine struct {
 	// Kustomize config.
 	Kustomization *kustomizeapi.Kustomization
 
+	// Naming gives the child resources of different parent resources
+	// unique names by patching the overlay Kustomization object before the
+	// Patchers. The names are left as they are if it's nil.
+	Naming Patcher
+
 	// Patchers contains the modifications that you'd like to make to
 	// the overlay Kustomization object before calling kustomize.
 	Patchers PatcherChain
@@ -107,11 +222,86 @@ type Engine struct {
 	// OverlayGenerators contains the overlay generators that will be added
 	// to the file system alongside kustomization.yaml
 	OverlayGenerators OverlayGeneratorChain
+
+	// Variants are the overlay directories that can be selected by the
+	// parent resource.
+	Variants Variants
+
+	// Components are the kustomize components that are applied on top of
+	// the base resources if the parent resource enables them.
+	Components []Component
+
+	// LegacyResourceSort makes kustomize sort the resources by kind. By
+	// default, the resources are returned in the order they are declared.
+	LegacyResourceSort bool
+
+	// BuildOptions are the options of the kustomize build.
+	BuildOptions BuildOptions
+
+	// RemoteBases fetches the remote bases in the resources of the
+	// kustomization. The remote bases are rejected if it's nil.
+	RemoteBases *RemoteBaseCache
 }
 
 // Run is called to trigger kustomization operation and returns the generated
 // raw Kubernetes objects.
-func (o *Engine) Run(cr resource.ParentResource) ([]resource.ChildResource, error) {
+func (o *Engine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	resourcePath, err := o.selectVariant(cr)
+	if err != nil {
+		return resource.RenderResult{}, errors.Wrap(err, errVariantSelection)
+	}
+	list, err := o.run(ctx, cr, resourcePath)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	return resource.RenderResult{Children: list}, nil
+}
+
+// Transform kustomizes the given child resources, which are rendered by the
+// previous stage of an engine pipeline. The resource path is copied with the
+// child resources written to its InputFileName file, so its
+// kustomization.yaml is expected to list InputFileName in its resources. A
+// kustomization.yaml that lists only InputFileName is used if the resource
+// path does not have one.
+func (o *Engine) Transform(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	resourcePath, err := o.selectVariant(cr)
+	if err != nil {
+		return nil, errors.Wrap(err, errVariantSelection)
+	}
+	dir, err := prepareInput(resourcePath, list)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	if err != nil {
+		return nil, errors.Wrap(err, errInputPreparation)
+	}
+	return o.run(context.Background(), cr, dir)
+}
+
+func (o *Engine) run(ctx context.Context, cr resource.ParentResource, resourcePath string) ([]resource.ChildResource, error) {
+	rendered, err := renderTemplate(cr, resourcePath)
+	if rendered != resourcePath {
+		defer func() {
+			_ = os.RemoveAll(rendered)
+		}()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errTemplatePreparation)
+	}
+	base, err := o.applyComponents(cr, rendered)
+	if base != rendered {
+		defer func() {
+			_ = os.RemoveAll(base)
+		}()
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errComponents)
+	}
+	if o.Naming != nil {
+		if err := o.Naming.Patch(cr, o.Kustomization); err != nil {
+			return nil, errors.Wrap(err, errPatch)
+		}
+	}
 	if err := o.Patchers.Patch(cr, o.Kustomization); err != nil {
 		return nil, errors.Wrap(err, errPatch)
 	}
@@ -120,7 +310,7 @@ func (o *Engine) Run(cr resource.ParentResource) ([]resource.ChildResource, erro
 		return nil, errors.Wrap(err, errOverlayGeneration)
 	}
 
-	dir, err := o.prepareOverlay(o.Kustomization, extraFiles)
+	dir, err := o.prepareOverlay(ctx, o.Kustomization, base, extraFiles)
 	defer func() {
 		_ = os.RemoveAll(dir)
 	}()
@@ -128,10 +318,16 @@ func (o *Engine) Run(cr resource.ParentResource) ([]resource.ChildResource, erro
 		return nil, errors.Wrap(err, errOverlayPreparation)
 	}
 
-	kustomizer := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), krusty.MakeDefaultOptions())
+	return o.kustomize(dir)
+}
+
+// kustomize returns the resources that kustomize builds from the
+// kustomization in the given directory.
+func (o *Engine) kustomize(dir string) ([]resource.ChildResource, error) {
+	kustomizer := krusty.MakeKustomizer(filesys.MakeFsOnDisk(), o.krustyOptions())
 	resMap, err := kustomizer.Run(dir)
 	if err != nil {
-		return nil, errors.Wrap(err, errKustomizeCall)
+		return nil, &resource.RenderError{Err: errors.Wrap(err, errKustomizeCall)}
 	}
 
 	objects := make([]resource.ChildResource, len(resMap.Resources()))
@@ -143,7 +339,28 @@ func (o *Engine) Run(cr resource.ParentResource) ([]resource.ChildResource, erro
 	return objects, nil
 }
 
-func (o *Engine) prepareOverlay(k *kustomizeapi.Kustomization, extraFiles []OverlayFile) (string, error) {
+// selectVariant returns the path of the overlay directory of the variant
+// that the given parent resource selects, or the resource path if it does not
+// select any.
+func (o *Engine) selectVariant(cr resource.ParentResource) (string, error) {
+	if o.Variants.Field == "" {
+		return o.ResourcePath, nil
+	}
+	name, _, err := unstructured.NestedString(cr.UnstructuredContent(), strings.Split(o.Variants.Field, ".")...)
+	if err != nil {
+		return "", &resource.ValuesError{Path: o.Variants.Field, Err: errors.Wrapf(err, errFmtVariantNotStr, o.Variants.Field)}
+	}
+	if name == "" {
+		return o.ResourcePath, nil
+	}
+	dir, ok := o.Variants.Overlays[name]
+	if !ok {
+		return "", &resource.ValuesError{Path: o.Variants.Field, Err: errors.Errorf(errFmtVariantNotFound, name)}
+	}
+	return filepath.Join(o.ResourcePath, dir), nil
+}
+
+func (o *Engine) prepareOverlay(ctx context.Context, k *kustomizeapi.Kustomization, resourcePath string, extraFiles []OverlayFile) (string, error) {
 	// NOTE(muvaf): Kustomize does not work with symlinked paths, so, we're
 	// using their temp directory generation function that handles this instead
 	// of Golang's.
@@ -156,7 +373,7 @@ func (o *Engine) prepareOverlay(k *kustomizeapi.Kustomization, extraFiles []Over
 	// NOTE(muvaf): Kustomize doesn't work with absolute paths, all paths have
 	// to be relative to the root path of the folder where kustomize points to,
 	// which is the temporary directory we created.
-	absPath, err := filepath.Abs(o.ResourcePath)
+	absPath, err := filepath.Abs(resourcePath)
 	if err != nil {
 		return "", err
 	}
@@ -164,8 +381,16 @@ func (o *Engine) prepareOverlay(k *kustomizeapi.Kustomization, extraFiles []Over
 	if err != nil {
 		return "", err
 	}
-	k.Resources = appendIfNotExists(k.Resources, relPath)
-	yamlData, err := yaml.Marshal(k)
+	// NOTE: The resource path may differ between parent resources depending
+	// on the selected variant, so we don't record it in the shared
+	// Kustomization object.
+	kc := *k
+	resources, err := o.localResources(ctx, tempDir, k.Resources)
+	if err != nil {
+		return tempDir, err
+	}
+	kc.Resources = appendIfNotExists(resources, relPath)
+	yamlData, err := yaml.Marshal(kc)
 	if err != nil {
 		return "", err
 	}
@@ -180,6 +405,102 @@ func (o *Engine) prepareOverlay(k *kustomizeapi.Kustomization, extraFiles []Over
 	return tempDir, nil
 }
 
+// prepareInput returns a temporary copy of the given resource path with the
+// given child resources written to its InputFileName file.
+func prepareInput(resourcePath string, list []resource.ChildResource) (string, error) {
+	dir, err := copyDir(resourcePath)
+	if err != nil {
+		return dir, err
+	}
+	return dir, writeInput(dir, list)
+}
+
+// renderTemplate returns a temporary copy of the given resource path whose
+// kustomization.yaml is its TemplateFileName file executed as a Go template
+// with the spec of the given parent resource as data, or the resource path
+// if it doesn't have one. The templates can use the functions of the
+// gotemplate engine. The kustomization of the Engine is then applied on top
+// of it like on any other base. The returned directory should be removed by
+// the caller if it's not the resource path.
+func renderTemplate(cr resource.ParentResource, resourcePath string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(resourcePath, TemplateFileName))
+	if os.IsNotExist(err) {
+		return resourcePath, nil
+	}
+	if err != nil {
+		return resourcePath, err
+	}
+	tmpl, err := template.New(TemplateFileName).Funcs(gotemplate.Funcs(cr)).Parse(string(data))
+	if err != nil {
+		return resourcePath, &resource.RenderError{File: TemplateFileName, Err: errors.Wrap(err, errParseTemplate)}
+	}
+	values, err := gotemplate.NewGoTemplateEngine().Values(cr)
+	if err != nil {
+		return resourcePath, err
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, values); err != nil {
+		return resourcePath, &resource.RenderError{File: TemplateFileName, Err: errors.Wrap(err, errExecTemplate)}
+	}
+	dir, err := copyDir(resourcePath)
+	if err != nil {
+		return dir, err
+	}
+	return dir, ioutil.WriteFile(filepath.Join(dir, kustomizationFileName), buf.Bytes(), os.ModePerm)
+}
+
+// copyDir returns a temporary copy of the given directory.
+func copyDir(path string) (string, error) {
+	tempConfirmedDir, err := filesys.NewTmpConfirmedDir()
+	if err != nil {
+		return "", err
+	}
+	dir := string(tempConfirmedDir)
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), os.ModePerm)
+		}
+		data, err := ioutil.ReadFile(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), data, os.ModePerm)
+	})
+	return dir, err
+}
+
+// writeInput writes the given child resources to the InputFileName file of
+// the given directory, along with a kustomization.yaml that lists only that
+// file if the directory does not have one.
+func writeInput(dir string, list []resource.ChildResource) error {
+	var buf []byte
+	for _, o := range list {
+		data, err := yaml.Marshal(o)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, "---\n"...), data...)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, InputFileName), buf, os.ModePerm); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, kustomizationFileName)); !os.IsNotExist(err) {
+		return err
+	}
+	data, err := yaml.Marshal(kustomizeapi.Kustomization{Resources: []string{InputFileName}})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, kustomizationFileName), data, os.ModePerm)
+}
+
 // todo: temporary.
 func appendIfNotExists(arr []string, obj string) []string {
 	for _, e := range arr {
@@ -18,8 +18,10 @@ package kustomize
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -33,7 +35,10 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
 
+	templatingv1alpha1 "github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/fixture"
 	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/templating"
 )
 
 const testYAMLDir = "../../../test/kustomize"
@@ -44,11 +49,30 @@ func TestEngine_Run(t *testing.T) {
 	if err != nil {
 		panic(fmt.Sprintf("cannot read %s", "test-overlays.yaml"))
 	}
-	kc := &v1alpha1.KustomizeEngineConfiguration{}
+	kc := &templatingv1alpha1.KustomizeEngineConfiguration{}
 	if err := yaml.Unmarshal(kcData, kc); err != nil {
 		panic(fmt.Sprintf("cannot parse %s", "test-overlays.yaml"))
 	}
 
+	variants := Variants{
+		Field:    "spec.variant",
+		Overlays: map[string]string{"ha": "../variants/ha"},
+	}
+	haResult := parse(filepath.Join(testYAMLDir, "want.yaml"))
+	haResult.SetLabels(map[string]string{"variant": "ha"})
+	monitoring := Component{Path: "../components/monitoring", Field: "spec.monitoring"}
+	monitoredResult := parse(filepath.Join(testYAMLDir, "want.yaml"))
+	monitoredResult.SetLabels(map[string]string{"monitoring": "enabled"})
+	json6902Patches := []Json6902Patch{{
+		Target: Json6902Target{APIVersion: "database.crossplane.io/v1alpha1", Kind: "MySQLInstance", Name: "sql"},
+		Operations: []Json6902Operation{
+			{Op: Json6902OpReplace, Path: "/spec/engineVersion", ValueFrom: "spec.engineVersion"},
+			{Op: Json6902OpRemove, Path: "/spec/writeConnectionSecretToRef"},
+		},
+	}}
+	json6902Result := parse(filepath.Join(testYAMLDir, "want.yaml"))
+	unstructured.RemoveNestedField(json6902Result.Object, "spec", "writeConnectionSecretToRef")
+
 	type args struct {
 		cr resource.ParentResource
 		e  *Engine
@@ -97,20 +121,130 @@ func TestEngine_Run(t *testing.T) {
 				result: []resource.ChildResource{parse(filepath.Join(testYAMLDir, "want.yaml"))},
 			},
 		},
+		"VariantSelected": {
+			args: args{
+				cr: withVariant(parse(filepath.Join(testYAMLDir, "test-cr.yaml")), "ha"),
+				e: NewKustomizeEngine(nil,
+					WithResourcePath(filepath.Join(testYAMLDir, "resources")),
+					WithOverlayGenerator(NewPatchOverlayGenerator(kc.Overlays)),
+					WithVariants(variants),
+				),
+			},
+			want: want{
+				result: []resource.ChildResource{haResult},
+			},
+		},
+		"VariantNotDeclared": {
+			args: args{
+				cr: withVariant(parse(filepath.Join(testYAMLDir, "test-cr.yaml")), "dev"),
+				e:  NewKustomizeEngine(nil, WithResourcePath(filepath.Join(testYAMLDir, "resources")), WithVariants(variants)),
+			},
+			want: want{
+				err: errors.Wrap(&resource.ValuesError{Path: variants.Field, Err: errors.Errorf(errFmtVariantNotFound, "dev")}, errVariantSelection),
+			},
+		},
+		"ComponentEnabled": {
+			args: args{
+				cr: withField(parse(filepath.Join(testYAMLDir, "test-cr.yaml")), true, "spec", "monitoring"),
+				e: NewKustomizeEngine(nil,
+					WithResourcePath(filepath.Join(testYAMLDir, "resources")),
+					WithOverlayGenerator(NewPatchOverlayGenerator(kc.Overlays)),
+					WithComponents(monitoring),
+				),
+			},
+			want: want{
+				result: []resource.ChildResource{monitoredResult},
+			},
+		},
+		"ComponentDisabled": {
+			args: args{
+				cr: parse(filepath.Join(testYAMLDir, "test-cr.yaml")),
+				e: NewKustomizeEngine(nil,
+					WithResourcePath(filepath.Join(testYAMLDir, "resources")),
+					WithOverlayGenerator(NewPatchOverlayGenerator(kc.Overlays)),
+					WithComponents(monitoring),
+				),
+			},
+			want: want{
+				result: []resource.ChildResource{parse(filepath.Join(testYAMLDir, "want.yaml"))},
+			},
+		},
+		"Json6902Patched": {
+			args: args{
+				cr: parse(filepath.Join(testYAMLDir, "test-cr.yaml")),
+				e: NewKustomizeEngine(nil,
+					WithResourcePath(filepath.Join(testYAMLDir, "resources")),
+					WithOverlayGenerator(NewJson6902OverlayGenerator(json6902Patches)),
+				),
+			},
+			want: want{
+				result: []resource.ChildResource{json6902Result},
+			},
+		},
+		"ComponentToggleNotBool": {
+			args: args{
+				cr: withField(parse(filepath.Join(testYAMLDir, "test-cr.yaml")), "yes", "spec", "monitoring"),
+				e:  NewKustomizeEngine(nil, WithResourcePath(filepath.Join(testYAMLDir, "resources")), WithComponents(monitoring)),
+			},
+			want: want{
+				err: errors.Wrap(&resource.ValuesError{
+					Path: monitoring.Field,
+					Err:  errors.Wrapf(errors.New(".spec.monitoring accessor error: yes is of the type string, expected bool"), errFmtComponentToggle, monitoring.Field, monitoring.Path),
+				}, errComponents),
+			},
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			got, err := tc.args.e.Run(tc.args.cr)
+			got, err := tc.args.e.Run(context.Background(), tc.args.cr)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Run(...): -want, +got:\n%s", diff)
 			}
-			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+			if diff := cmp.Diff(tc.want.result, got.Children); diff != "" {
 				t.Errorf("Run(...): -want, +got:\n%s", diff)
 			}
 		})
 	}
 }
 
+func TestFixtures(t *testing.T) {
+	cases, err := fixture.Load(filepath.Join(testYAMLDir, "tests"))
+	if err != nil {
+		t.Fatalf("fixture.Load(...): %v", err)
+	}
+	r := fixture.NewRunner(func(sd *v1alpha1.StackDefinition) (templating.Engine, error) {
+		b, err := templatingv1alpha1.BehaviorOf(sd)
+		if err != nil {
+			return nil, err
+		}
+		return NewKustomizeEngine(nil,
+			WithResourcePath(filepath.Join(testYAMLDir, "resources")),
+			WithOverlayGenerator(NewPatchOverlayGenerator(b.Engine.Kustomize.Overlays)),
+		), nil
+	})
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			res := r.Run(c)
+			if res.Err != nil {
+				t.Fatalf("Run(...): %v", res.Err)
+			}
+			if res.Diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", res.Diff)
+			}
+		})
+	}
+}
+
+func withField(u *unstructured.Unstructured, val interface{}, path ...string) *unstructured.Unstructured {
+	_ = unstructured.SetNestedField(u.Object, val, path...)
+	return u
+}
+
+func withVariant(u *unstructured.Unstructured, variant string) *unstructured.Unstructured {
+	_ = unstructured.SetNestedField(u.Object, variant, "spec", "variant")
+	return u
+}
+
 func parse(path string) *unstructured.Unstructured {
 	resultData, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -123,3 +257,140 @@ func parse(path string) *unstructured.Unstructured {
 	}
 	return u
 }
+
+func TestEngine_Transform(t *testing.T) {
+	cm := func(labels map[string]interface{}) resource.ChildResource {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "cool"},
+			"data":       map[string]interface{}{"key": "value"},
+		}}
+		if labels != nil {
+			_ = unstructured.SetNestedMap(u.Object, labels, "metadata", "labels")
+		}
+		return u
+	}
+	type want struct {
+		result []resource.ChildResource
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		files  map[string]string
+		k      *types.Kustomization
+		want   want
+	}{
+		"NoKustomization": {
+			reason: "The child resources should be kustomized with the given kustomization if the resource path has no kustomization.yaml.",
+			k:      &types.Kustomization{CommonLabels: map[string]string{"stage": "kustomize"}},
+			want:   want{result: []resource.ChildResource{cm(map[string]interface{}{"stage": "kustomize"})}},
+		},
+		"Kustomization": {
+			reason: "The kustomization.yaml of the resource path should be able to patch the child resources.",
+			files: map[string]string{
+				kustomizationFileName: "resources:\n- " + InputFileName + "\npatchesStrategicMerge:\n- patch.yaml\n",
+				"patch.yaml":          "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cool\n  labels:\n    patched: \"true\"\n",
+			},
+			k:    &types.Kustomization{},
+			want: want{result: []resource.ChildResource{cm(map[string]interface{}{"patched": "true"})}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kustomize")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			for f, content := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			e := NewKustomizeEngine(tc.k, WithResourcePath(dir), WithNaming(nil))
+			got, err := e.Transform(&unstructured.Unstructured{}, []resource.ChildResource{cm(nil)})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nTransform(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nTransform(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	resources := filepath.Join(testYAMLDir, "resources")
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   error
+	}{
+		"Valid": {
+			reason: "Variants whose overlay directories exist should be accepted.",
+			data:   "{field: spec.size, overlays: {ha: ../variants/ha}}",
+		},
+		"NoField": {
+			reason: "Variants without a field to select them should be rejected.",
+			data:   "{overlays: {ha: ../variants/ha}}",
+			want:   errors.New(errVariantField),
+		},
+		"NoDirectory": {
+			reason: "A variant whose overlay directory does not exist should be rejected.",
+			data:   "{field: spec.size, overlays: {large: ../variants/large}}",
+			want:   errors.Errorf(errFmtVariantNoDir, "../variants/large", "large"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v, err := ParseVariants(tc.data)
+			if err == nil {
+				err = v.Validate(resources)
+			}
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseVariants(...).Validate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	resources, err := ioutil.TempDir("", "kustomize-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(resources) // nolint:errcheck
+	tmpl := "resources:\n- cm.yaml\nnamespace: {{ .namespace }}\n"
+	if err := ioutil.WriteFile(filepath.Join(resources, TemplateFileName), []byte(tmpl), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(resources, "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"namespace": "team-a"}}}
+
+	dir, err := renderTemplate(cr, resources)
+	defer os.RemoveAll(dir) // nolint:errcheck
+	if err != nil {
+		t.Fatalf("renderTemplate(...): %s", err)
+	}
+	if dir == resources {
+		t.Fatalf("renderTemplate(...): want a copy of the resource path")
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, kustomizationFileName))
+	if err != nil {
+		t.Fatalf("renderTemplate(...): %s", err)
+	}
+	if diff := cmp.Diff("resources:\n- cm.yaml\nnamespace: team-a\n", string(got)); diff != "" {
+		t.Errorf("renderTemplate(...): -want kustomization, +got kustomization:\n%s", diff)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cm.yaml")); err != nil {
+		t.Errorf("renderTemplate(...): want the resources to be copied: %s", err)
+	}
+
+	// The resource path is used as is if it doesn't have a template.
+	if got, err := renderTemplate(cr, filepath.Join(testYAMLDir, "resources")); err != nil || got != filepath.Join(testYAMLDir, "resources") {
+		t.Errorf("renderTemplate(...): want the resource path without a template, got %s, %v", got, err)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// CommonMetadataAnnotationKey is the annotation on the StackDefinition
+	// whose value is the YAML representation of CommonMetadata.
+	CommonMetadataAnnotationKey = "templatestacks.crossplane.io/kustomize-common-metadata"
+
+	errParseCommonMetadata = "could not parse the common metadata"
+	errFmtMetadataKey      = "%s binding %d should have a key"
+	errFmtMetadataSource   = "%s binding %s should have either a fromField or a fromAnnotation"
+	errFmtMetadataNotValue = "value of %s is not a string, number or boolean"
+	errFmtInvalidLabel     = "value of %s is not a valid label value: %s"
+)
+
+// CommonMetadata binds the commonLabels and commonAnnotations of the
+// kustomization to the fields or annotations of the parent resource, so that
+// every rendered object carries them.
+type CommonMetadata struct {
+	// Labels are added to the commonLabels of the kustomization.
+	// +optional
+	Labels []MetadataBinding `json:"labels,omitempty"`
+
+	// Annotations are added to the commonAnnotations of the kustomization.
+	// +optional
+	Annotations []MetadataBinding `json:"annotations,omitempty"`
+}
+
+// MetadataBinding binds a label or an annotation of the rendered objects to
+// either a field or an annotation of the parent resource. The label or
+// annotation is not added if the parent resource doesn't have the value.
+type MetadataBinding struct {
+	// Key is the key of the label or annotation, e.g. example.com/tenant.
+	Key string `json:"key"`
+
+	// FromField is the dot separated path of the field of the parent
+	// resource whose value is used, e.g. spec.tenant. Its value should be a
+	// string, number or boolean.
+	// +optional
+	FromField string `json:"fromField,omitempty"`
+
+	// FromAnnotation is the key of the annotation of the parent resource
+	// whose value is used.
+	// +optional
+	FromAnnotation string `json:"fromAnnotation,omitempty"`
+}
+
+// Fields returns the fields of the parent resources that the labels and
+// annotations are read from.
+func (m CommonMetadata) Fields() []string {
+	var fields []string
+	for _, b := range append(append([]MetadataBinding{}, m.Labels...), m.Annotations...) {
+		if b.FromField != "" {
+			fields = append(fields, b.FromField)
+		}
+	}
+	return fields
+}
+
+// ParseCommonMetadata parses and validates the given YAML representation of
+// the common metadata, typically the value of CommonMetadataAnnotationKey
+// annotation.
+func ParseCommonMetadata(data string) (CommonMetadata, error) {
+	m := CommonMetadata{}
+	if err := yaml.Unmarshal([]byte(data), &m); err != nil {
+		return CommonMetadata{}, errors.Wrap(err, errParseCommonMetadata)
+	}
+	if err := validateBindings("label", m.Labels); err != nil {
+		return CommonMetadata{}, err
+	}
+	if err := validateBindings("annotation", m.Annotations); err != nil {
+		return CommonMetadata{}, err
+	}
+	return m, nil
+}
+
+// validateBindings returns an error if any of the given label or annotation
+// bindings is not valid.
+func validateBindings(kind string, bindings []MetadataBinding) error {
+	for i, b := range bindings {
+		if b.Key == "" {
+			return errors.Errorf(errFmtMetadataKey, kind, i)
+		}
+		if (b.FromField == "") == (b.FromAnnotation == "") {
+			return errors.Errorf(errFmtMetadataSource, kind, b.Key)
+		}
+	}
+	return nil
+}
+
+// NewCommonMetadataPatcher returns a new CommonMetadataPatcher.
+func NewCommonMetadataPatcher(m CommonMetadata) CommonMetadataPatcher {
+	return CommonMetadataPatcher{Metadata: m}
+}
+
+// CommonMetadataPatcher sets the commonLabels and commonAnnotations of the
+// kustomization from the fields and annotations of the parent resource.
+type CommonMetadataPatcher struct {
+	Metadata CommonMetadata
+}
+
+// Patch patches the *types.Kustomization object with information from
+// resource.ParentResource.
+func (mp CommonMetadataPatcher) Patch(cr resource.ParentResource, k *types.Kustomization) error {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// keys whose value the parent resource doesn't have are removed rather
+	// than left with the value of the previous one.
+	labels, err := bindMetadata(cr, k.CommonLabels, mp.Metadata.Labels, true)
+	if err != nil {
+		return err
+	}
+	annotations, err := bindMetadata(cr, k.CommonAnnotations, mp.Metadata.Annotations, false)
+	if err != nil {
+		return err
+	}
+	k.CommonLabels, k.CommonAnnotations = labels, annotations
+	return nil
+}
+
+// bindMetadata returns the given map with the values of the given bindings
+// read from the given parent resource.
+func bindMetadata(cr resource.ParentResource, m map[string]string, bindings []MetadataBinding, label bool) (map[string]string, error) {
+	if len(bindings) == 0 {
+		return m, nil
+	}
+	result := make(map[string]string, len(m)+len(bindings))
+	for k, v := range m {
+		result[k] = v
+	}
+	for _, b := range bindings {
+		val, path, ok, err := metadataValue(cr, b)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			delete(result, b.Key)
+			continue
+		}
+		if label {
+			if errs := validation.IsValidLabelValue(val); len(errs) != 0 {
+				return nil, &resource.ValuesError{Path: path, Err: errors.Errorf(errFmtInvalidLabel, path, strings.Join(errs, ", "))}
+			}
+		}
+		result[b.Key] = val
+	}
+	return result, nil
+}
+
+// metadataValue returns the value of the given binding in the given parent
+// resource, the path it's read from and whether the parent resource has it.
+func metadataValue(cr resource.ParentResource, b MetadataBinding) (string, string, bool, error) {
+	if b.FromAnnotation != "" {
+		path := fmt.Sprintf("metadata.annotations[%s]", b.FromAnnotation)
+		val, ok := cr.GetAnnotations()[b.FromAnnotation]
+		return val, path, ok, nil
+	}
+	val, ok, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(b.FromField, ".")...)
+	if err != nil {
+		return "", b.FromField, false, &resource.ValuesError{Path: b.FromField, Err: err}
+	}
+	if !ok || val == nil {
+		return "", b.FromField, false, nil
+	}
+	switch v := val.(type) {
+	case string, bool, int64, float64:
+		return fmt.Sprint(v), b.FromField, true, nil
+	default:
+		return "", b.FromField, false, &resource.ValuesError{Path: b.FromField, Err: errors.Errorf(errFmtMetadataNotValue, b.FromField)}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseCommonMetadata(t *testing.T) {
+	type want struct {
+		m   CommonMetadata
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid common metadata should be parsed.",
+			data: `
+labels:
+- {key: example.com/tenant, fromField: spec.tenant}
+annotations:
+- {key: example.com/owner, fromAnnotation: example.com/owner}
+`,
+			want: want{m: CommonMetadata{
+				Labels:      []MetadataBinding{{Key: "example.com/tenant", FromField: "spec.tenant"}},
+				Annotations: []MetadataBinding{{Key: "example.com/owner", FromAnnotation: "example.com/owner"}},
+			}},
+		},
+		"NoKey": {
+			reason: "A binding without a key should be rejected.",
+			data:   `labels: [{fromField: spec.tenant}]`,
+			want:   want{err: errors.Errorf(errFmtMetadataKey, "label", 0)},
+		},
+		"NoSource": {
+			reason: "A binding without a field or an annotation should be rejected.",
+			data:   `annotations: [{key: example.com/owner}]`,
+			want:   want{err: errors.Errorf(errFmtMetadataSource, "annotation", "example.com/owner")},
+		},
+		"BothSources": {
+			reason: "A binding with both a field and an annotation should be rejected.",
+			data:   `labels: [{key: tenant, fromField: spec.tenant, fromAnnotation: tenant}]`,
+			want:   want{err: errors.Errorf(errFmtMetadataSource, "label", "tenant")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseCommonMetadata(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseCommonMetadata(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.m, got); diff != "" {
+				t.Errorf("\n%s\nParseCommonMetadata(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestCommonMetadataPatcher(t *testing.T) {
+	m := CommonMetadata{
+		Labels: []MetadataBinding{
+			{Key: "example.com/tenant", FromField: "spec.tenant"},
+			{Key: "example.com/tier", FromField: "spec.tier"},
+		},
+		Annotations: []MetadataBinding{{Key: "example.com/owner", FromAnnotation: "example.com/owner"}},
+	}
+	parent := func(spec map[string]interface{}, annotations map[string]string) resource.ParentResource {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetAnnotations(annotations)
+		return u
+	}
+
+	type want struct {
+		k   *types.Kustomization
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		k      *types.Kustomization
+		want   want
+	}{
+		"Bound": {
+			reason: "The labels and annotations should be read from the fields and annotations of the parent resource.",
+			cr:     parent(map[string]interface{}{"tenant": "acme", "tier": int64(2)}, map[string]string{"example.com/owner": "Jane Doe"}),
+			k:      &types.Kustomization{CommonLabels: map[string]string{"app": "db"}},
+			want: want{k: &types.Kustomization{
+				CommonLabels:      map[string]string{"app": "db", "example.com/tenant": "acme", "example.com/tier": "2"},
+				CommonAnnotations: map[string]string{"example.com/owner": "Jane Doe"},
+			}},
+		},
+		"Unset": {
+			reason: "The labels and annotations that the parent resource doesn't have should be removed.",
+			cr:     parent(map[string]interface{}{"tenant": "acme"}, nil),
+			k: &types.Kustomization{
+				CommonLabels:      map[string]string{"example.com/tenant": "other", "example.com/tier": "1"},
+				CommonAnnotations: map[string]string{"example.com/owner": "Jane Doe"},
+			},
+			want: want{k: &types.Kustomization{
+				CommonLabels:      map[string]string{"example.com/tenant": "acme"},
+				CommonAnnotations: map[string]string{},
+			}},
+		},
+		"NotScalar": {
+			reason: "A field whose value is not a string, number or boolean should be rejected.",
+			cr:     parent(map[string]interface{}{"tenant": map[string]interface{}{"name": "acme"}}, nil),
+			k:      &types.Kustomization{},
+			want: want{
+				k:   &types.Kustomization{},
+				err: &resource.ValuesError{Path: "spec.tenant", Err: errors.Errorf(errFmtMetadataNotValue, "spec.tenant")},
+			},
+		},
+		"InvalidLabelValue": {
+			reason: "A value that is not a valid label value should be rejected.",
+			cr:     parent(map[string]interface{}{"tenant": "Acme Corp"}, nil),
+			k:      &types.Kustomization{},
+			want: want{
+				k:   &types.Kustomization{},
+				err: &resource.ValuesError{Path: "spec.tenant", Err: errors.Errorf(errFmtInvalidLabel, "spec.tenant", strings.Join(validation.IsValidLabelValue("Acme Corp"), ", "))},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := NewCommonMetadataPatcher(m).Patch(tc.cr, tc.k)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.k, tc.k); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/operations/gotemplate"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// NamingAnnotationKey is the annotation on the StackDefinition whose
+	// value is the YAML representation of Naming.
+	NamingAnnotationKey = "templatestacks.crossplane.io/kustomize-naming"
+
+	errParseNaming        = "could not parse the naming"
+	errFmtNamingMode      = "unknown naming mode %s"
+	errNamingTemplateNone = "naming template cannot be used with the none mode"
+	errParseNamingTmpl    = "cannot parse the naming template"
+	errExecNamingTmpl     = "cannot execute the naming template"
+)
+
+// A NamingMode determines how the name of the parent resource is added to the
+// names of the child resources.
+type NamingMode string
+
+// Naming modes.
+const (
+	// NamingPrefix sets the namePrefix of the kustomization.
+	NamingPrefix NamingMode = "prefix"
+
+	// NamingSuffix sets the nameSuffix of the kustomization.
+	NamingSuffix NamingMode = "suffix"
+
+	// NamingNone leaves the names of the child resources as they are
+	// declared in the resources and the kustomization.
+	NamingNone NamingMode = "none"
+)
+
+// Naming configures how the child resources of different parent resources
+// are given unique names.
+type Naming struct {
+	// Mode is one of prefix, suffix and none. Defaults to prefix.
+	// +optional
+	Mode NamingMode `json:"mode,omitempty"`
+
+	// Template is a Go template that is executed with the whole parent
+	// resource as data, and whose output is used as the prefix or the
+	// suffix. Defaults to "{{ .metadata.name }}-" for the prefix and
+	// "-{{ .metadata.name }}" for the suffix.
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// ParseNaming parses and validates the given YAML representation of the
+// naming, typically the value of NamingAnnotationKey annotation.
+func ParseNaming(data string) (Naming, error) {
+	n := Naming{}
+	if err := yaml.Unmarshal([]byte(data), &n); err != nil {
+		return Naming{}, errors.Wrap(err, errParseNaming)
+	}
+	switch n.Mode {
+	case "":
+		n.Mode = NamingPrefix
+	case NamingPrefix, NamingSuffix:
+	case NamingNone:
+		if n.Template != "" {
+			return Naming{}, errors.New(errNamingTemplateNone)
+		}
+	default:
+		return Naming{}, errors.Errorf(errFmtNamingMode, n.Mode)
+	}
+	if _, err := template.New("").Funcs(gotemplate.Funcs(&unstructured.Unstructured{})).Parse(n.Template); err != nil {
+		return Naming{}, errors.Wrap(err, errParseNamingTmpl)
+	}
+	return n, nil
+}
+
+// NewNamePatcher returns a new NamePatcher. It returns nil for the none mode,
+// i.e. there is nothing to patch.
+func NewNamePatcher(n Naming) Patcher {
+	switch n.Mode {
+	case NamingNone:
+		return nil
+	case NamingSuffix:
+		return NamePatcher{Naming: n}
+	}
+	if n.Template == "" {
+		return NewNamePrefixer()
+	}
+	return NamePatcher{Naming: n}
+}
+
+// NamePatcher sets the namePrefix or the nameSuffix of the kustomization to
+// the output of the template of its Naming.
+type NamePatcher struct {
+	Naming Naming
+}
+
+// Patch patches the *types.Kustomization object with information from resource.ParentResource
+func (np NamePatcher) Patch(cr resource.ParentResource, k *types.Kustomization) error {
+	text := np.Naming.Template
+	switch {
+	case text != "":
+	case np.Naming.Mode == NamingSuffix:
+		text = "-{{ .metadata.name }}"
+	default:
+		text = "{{ .metadata.name }}-"
+	}
+	tmpl, err := template.New(NamingAnnotationKey).Funcs(gotemplate.Funcs(cr)).Parse(text)
+	if err != nil {
+		return errors.Wrap(err, errParseNamingTmpl)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, cr.UnstructuredContent()); err != nil {
+		return &resource.RenderError{Err: errors.Wrap(err, errExecNamingTmpl)}
+	}
+	if np.Naming.Mode == NamingSuffix {
+		k.NameSuffix = buf.String()
+		return nil
+	}
+	k.NamePrefix = buf.String()
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseNaming(t *testing.T) {
+	type want struct {
+		n   Naming
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Default": {
+			reason: "The mode should default to prefix.",
+			data:   "{}",
+			want:   want{n: Naming{Mode: NamingPrefix}},
+		},
+		"Suffix": {
+			reason: "A suffix with a template should be parsed.",
+			data:   `{mode: suffix, template: "-{{ .metadata.namespace }}"}`,
+			want:   want{n: Naming{Mode: NamingSuffix, Template: "-{{ .metadata.namespace }}"}},
+		},
+		"UnknownMode": {
+			reason: "An unknown mode should be rejected.",
+			data:   "{mode: infix}",
+			want:   want{err: errors.Errorf(errFmtNamingMode, "infix")},
+		},
+		"NoneWithTemplate": {
+			reason: "A template should not be given with the none mode.",
+			data:   `{mode: none, template: "x-"}`,
+			want:   want{err: errors.New(errNamingTemplateNone)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseNaming(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseNaming(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.n, got); diff != "" {
+				t.Errorf("\n%s\nParseNaming(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestNamePatcher(t *testing.T) {
+	cr := &unstructured.Unstructured{}
+	cr.SetName("db")
+	cr.SetNamespace("team-a")
+
+	cases := map[string]struct {
+		reason string
+		n      Naming
+		want   types.Kustomization
+	}{
+		"Prefix": {
+			reason: "The name of the parent resource should be the prefix by default.",
+			n:      Naming{Mode: NamingPrefix},
+			want:   types.Kustomization{NamePrefix: "db-"},
+		},
+		"Suffix": {
+			reason: "The name of the parent resource should be the suffix by default in the suffix mode.",
+			n:      Naming{Mode: NamingSuffix},
+			want:   types.Kustomization{NameSuffix: "-db"},
+		},
+		"Template": {
+			reason: "The output of the template should be the prefix.",
+			n:      Naming{Mode: NamingPrefix, Template: "{{ .metadata.namespace }}-{{ .metadata.name }}-"},
+			want:   types.Kustomization{NamePrefix: "team-a-db-"},
+		},
+		"None": {
+			reason: "The names should be left as they are in the none mode.",
+			n:      Naming{Mode: NamingNone},
+			want:   types.Kustomization{},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := types.Kustomization{}
+			if p := NewNamePatcher(tc.n); p != nil {
+				if err := p.Patch(cr, &got); err != nil {
+					t.Fatalf("\n%s\nPatch(...): %s", tc.reason, err)
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultRemoteFetchTimeout is the default timeout of fetching a remote
+	// base.
+	DefaultRemoteFetchTimeout = 2 * time.Minute
+
+	errFmtRemoteNotAllowed = "remote base %s is not allowed"
+	errFmtParseRemoteBase  = "cannot parse remote base %s"
+	errFmtFetchRemoteBase  = "cannot fetch remote base %s"
+	errFmtRemoteNoPath     = "remote base %s has no directory %s"
+	errRemoteNoRepository  = "repository is empty"
+	errFmtGit              = "git %s failed: %s"
+)
+
+// hostedGit are the hosts whose repositories can be given without a scheme
+// and without the // separator of the directory, e.g.
+// github.com/org/repo/config/base.
+var hostedGit = map[string]bool{
+	"github.com":    true,
+	"gitlab.com":    true,
+	"bitbucket.org": true,
+}
+
+// IsRemoteBase returns true if the given entry of the resources of a
+// kustomization refers to a git repository rather than a local path.
+func IsRemoteBase(entry string) bool {
+	for _, p := range []string{"git::", "https://", "http://", "ssh://", "git@"} {
+		if strings.HasPrefix(entry, p) {
+			return true
+		}
+	}
+	return hostedGit[strings.SplitN(entry, "/", 2)[0]]
+}
+
+// A RemoteBase is a directory of a git repository that an entry of the
+// resources of a kustomization refers to, in the format kustomize accepts,
+// e.g. https://github.com/org/repo//config/base?ref=v1.0.0.
+type RemoteBase struct {
+	// Repository is the URL of the git repository.
+	Repository string
+
+	// Path is the directory in the repository, relative to its root.
+	Path string
+
+	// Ref is the tag, branch or commit to check out. The default branch is
+	// checked out if it's empty.
+	Ref string
+}
+
+// ParseRemoteBase parses the given entry of the resources of a kustomization.
+func ParseRemoteBase(entry string) (RemoteBase, error) {
+	s := strings.TrimPrefix(entry, "git::")
+	rb := RemoteBase{}
+	if i := strings.Index(s, "?"); i != -1 {
+		q, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return RemoteBase{}, errors.Wrapf(err, errFmtParseRemoteBase, entry)
+		}
+		rb.Ref = q.Get("ref")
+		s = s[:i]
+	}
+	scheme := ""
+	if i := strings.Index(s, "://"); i != -1 {
+		scheme, s = s[:i+3], s[i+3:]
+	}
+	if i := strings.Index(s, "//"); i != -1 {
+		s, rb.Path = s[:i], s[i+2:]
+	} else if parts := strings.SplitN(s, "/", 4); len(parts) == 4 && hostedGit[parts[0]] {
+		s, rb.Path = strings.Join(parts[:3], "/"), parts[3]
+	}
+	if s == "" {
+		return RemoteBase{}, errors.Wrapf(errors.New(errRemoteNoRepository), errFmtParseRemoteBase, entry)
+	}
+	if scheme == "" && !strings.HasPrefix(s, "git@") {
+		scheme = "https://"
+	}
+	rb.Repository = scheme + s
+	// NOTE: The directory cannot point outside of the repository.
+	rb.Path = strings.TrimPrefix(path.Clean("/"+rb.Path), "/")
+	return rb, nil
+}
+
+// A CloneFn checks out the given ref of the given repository into the given
+// empty directory.
+type CloneFn func(ctx context.Context, dir, repository, ref string) error
+
+// NewRemoteBaseCache returns a new *RemoteBaseCache that caches the
+// repositories in the given directory.
+func NewRemoteBaseCache(dir string) *RemoteBaseCache {
+	return &RemoteBaseCache{CacheDir: dir, Clone: GitClone, Timeout: DefaultRemoteFetchTimeout}
+}
+
+// A RemoteBaseCache fetches the repositories of the remote bases and caches
+// their checkouts on the filesystem. The checkouts are keyed by the repository
+// and the ref, so a ref is fetched once; the remote bases are expected to
+// refer to a tag or a commit since a cached branch is not fetched again.
+type RemoteBaseCache struct {
+	// CacheDir is the directory that the checkouts are cached in.
+	CacheDir string
+
+	// Clone is used to check out the repositories.
+	Clone CloneFn
+
+	// Timeout of fetching a repository.
+	Timeout time.Duration
+
+	mu sync.Mutex
+}
+
+// Fetch returns the path of the directory of the given remote base in its
+// cached checkout, checking it out first if it's not cached.
+func (c *RemoteBaseCache) Fetch(ctx context.Context, rb RemoteBase) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sum := sha256.Sum256([]byte(rb.Repository + "\n" + rb.Ref))
+	checkout := filepath.Join(c.CacheDir, hex.EncodeToString(sum[:]))
+	if _, err := os.Stat(checkout); os.IsNotExist(err) {
+		if err := c.checkout(ctx, rb, checkout); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(checkout, filepath.FromSlash(rb.Path))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", errors.Errorf(errFmtRemoteNoPath, rb.Repository, rb.Path)
+	}
+	return dir, nil
+}
+
+// checkout checks out the given remote base into a temporary directory and
+// moves it to the given path so that a checkout is either complete or does
+// not exist, even if the process is killed.
+func (c *RemoteBaseCache) checkout(ctx context.Context, rb RemoteBase, path string) error {
+	if err := os.MkdirAll(c.CacheDir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(c.CacheDir, filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp) // nolint:errcheck
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	if err := c.Clone(ctx, tmp, rb.Repository, rb.Ref); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// GitClone checks out the given ref of the given repository into the given
+// empty directory with the git binary. Only the given ref is fetched, without
+// its history.
+func GitClone(ctx context.Context, dir, repository, ref string) error {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", repository, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...) // nolint:gosec
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return errors.Wrapf(err, errFmtGit, args[0], strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// localResources returns the given entries of the resources of a
+// kustomization with the remote bases replaced by the paths of their cached
+// checkouts relative to the given directory.
+func (o *Engine) localResources(ctx context.Context, dir string, entries []string) ([]string, error) {
+	result := make([]string, len(entries))
+	for i, e := range entries {
+		result[i] = e
+		if !IsRemoteBase(e) {
+			continue
+		}
+		if o.RemoteBases == nil {
+			return nil, errors.Errorf(errFmtRemoteNotAllowed, e)
+		}
+		rb, err := ParseRemoteBase(e)
+		if err != nil {
+			return nil, err
+		}
+		path, err := o.RemoteBases.Fetch(ctx, rb)
+		if err != nil {
+			return nil, errors.Wrapf(err, errFmtFetchRemoteBase, e)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if result[i], err = filepath.Rel(dir, abs); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestParseRemoteBase(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		entry  string
+		remote bool
+		want   RemoteBase
+	}{
+		"HTTPS": {
+			reason: "A repository URL with a directory and a ref should be parsed.",
+			entry:  "https://github.com/org/repo.git//config/base?ref=v1.0.0",
+			remote: true,
+			want:   RemoteBase{Repository: "https://github.com/org/repo.git", Path: "config/base", Ref: "v1.0.0"},
+		},
+		"HostedWithoutScheme": {
+			reason: "A repository of a known host should be fetched over HTTPS and its directory can follow the repository.",
+			entry:  "github.com/org/repo/config/base?ref=3f2a1b",
+			remote: true,
+			want:   RemoteBase{Repository: "https://github.com/org/repo", Path: "config/base", Ref: "3f2a1b"},
+		},
+		"SSH": {
+			reason: "An SSH repository should be used as is.",
+			entry:  "git::git@gitlab.com:org/repo.git//base",
+			remote: true,
+			want:   RemoteBase{Repository: "git@gitlab.com:org/repo.git", Path: "base"},
+		},
+		"OutsideOfRepository": {
+			reason: "The directory should not point outside of the repository.",
+			entry:  "https://example.com/repo//../../etc",
+			remote: true,
+			want:   RemoteBase{Repository: "https://example.com/repo", Path: "etc"},
+		},
+		"Local": {
+			reason: "A local path should not be a remote base.",
+			entry:  "../base",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.remote, IsRemoteBase(tc.entry)); diff != "" {
+				t.Errorf("\n%s\nIsRemoteBase(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if !tc.remote {
+				return
+			}
+			got, err := ParseRemoteBase(tc.entry)
+			if err != nil {
+				t.Fatalf("\n%s\nParseRemoteBase(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParseRemoteBase(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRemoteBaseCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "remote-bases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+
+	clones := 0
+	c := NewRemoteBaseCache(dir)
+	c.Clone = func(_ context.Context, dir, repository, ref string) error {
+		clones++
+		if ref == "broken" {
+			return errors.New("boom")
+		}
+		return os.MkdirAll(filepath.Join(dir, "base"), 0700)
+	}
+	rb := RemoteBase{Repository: "https://github.com/org/repo", Path: "base", Ref: "v1"}
+	for i := 0; i < 2; i++ {
+		got, err := c.Fetch(context.Background(), rb)
+		if err != nil {
+			t.Fatalf("Fetch(...): %s", err)
+		}
+		if filepath.Base(got) != "base" {
+			t.Errorf("Fetch(...): want the directory of the remote base, got %s", got)
+		}
+	}
+	if clones != 1 {
+		t.Errorf("Fetch(...): want the repository to be cloned once, got %d clones", clones)
+	}
+
+	_, err = c.Fetch(context.Background(), RemoteBase{Repository: "https://github.com/org/repo", Path: "missing", Ref: "v1"})
+	if diff := cmp.Diff(errors.Errorf(errFmtRemoteNoPath, "https://github.com/org/repo", "missing"), err, test.EquateErrors()); diff != "" {
+		t.Errorf("Fetch(...): -want error, +got error:\n%s", diff)
+	}
+	if _, err := c.Fetch(context.Background(), RemoteBase{Repository: "https://github.com/org/repo", Ref: "broken"}); err == nil {
+		t.Errorf("Fetch(...): want an error when the repository cannot be cloned")
+	}
+	if _, err := c.Fetch(context.Background(), RemoteBase{Repository: "https://github.com/org/repo", Ref: "broken"}); err == nil {
+		t.Errorf("Fetch(...): want a failed clone not to be cached")
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ReplicasAnnotationKey is the annotation on the StackDefinition whose
+	// value is the YAML representation of a list of ReplicaBinding.
+	ReplicasAnnotationKey = "templatestacks.crossplane.io/kustomize-replicas"
+
+	errParseReplicas        = "could not parse the replica bindings"
+	errFmtReplicaName       = "replica binding %d should have a name"
+	errFmtReplicaDuplicate  = "replicas of %s are bound more than once"
+	errFmtReplicaCountFrom  = "replica binding %s should have a countFrom field"
+	errFmtReplicaNegative   = "replica binding %s should not have a negative count"
+	errFmtReplicaNotInteger = "value of %s is not a non-negative integer"
+)
+
+// A ReplicaBinding binds the count of an entry in the replicas transformer of
+// the kustomization to a field of the parent resource, so that the number of
+// replicas of a rendered object can be set in the parent resource. The entry
+// with the same name in the replicas of the kustomization, if any, is
+// replaced.
+type ReplicaBinding struct {
+	// Name is the name of the object in the resources whose replicas are
+	// set, e.g. app.
+	Name string `json:"name"`
+
+	// CountFrom is the dot separated path of the field of the parent
+	// resource whose value is the number of replicas, e.g. spec.replicas.
+	CountFrom string `json:"countFrom"`
+
+	// Count is the number of replicas if the parent resource doesn't have
+	// the CountFrom field. The replicas in the resources are left as they
+	// are if neither is set.
+	// +optional
+	Count *int64 `json:"count,omitempty"`
+}
+
+// ParseReplicaBindings parses and validates the given YAML representation of
+// the replica bindings, typically the value of ReplicasAnnotationKey
+// annotation.
+func ParseReplicaBindings(data string) ([]ReplicaBinding, error) {
+	var replicas []ReplicaBinding
+	if err := yaml.Unmarshal([]byte(data), &replicas); err != nil {
+		return nil, errors.Wrap(err, errParseReplicas)
+	}
+	seen := map[string]bool{}
+	for i, r := range replicas {
+		switch {
+		case r.Name == "":
+			return nil, errors.Errorf(errFmtReplicaName, i)
+		case seen[r.Name]:
+			return nil, errors.Errorf(errFmtReplicaDuplicate, r.Name)
+		case r.CountFrom == "":
+			return nil, errors.Errorf(errFmtReplicaCountFrom, r.Name)
+		case r.Count != nil && *r.Count < 0:
+			return nil, errors.Errorf(errFmtReplicaNegative, r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return replicas, nil
+}
+
+// ReplicaFields returns the fields of the parent resources that the given
+// replica bindings read from.
+func ReplicaFields(replicas []ReplicaBinding) []string {
+	fields := make([]string, 0, len(replicas))
+	for _, r := range replicas {
+		fields = append(fields, r.CountFrom)
+	}
+	return fields
+}
+
+// NewReplicaOverlayGenerator returns a new ReplicaOverlayGenerator.
+func NewReplicaOverlayGenerator(replicas []ReplicaBinding) ReplicaOverlayGenerator {
+	return ReplicaOverlayGenerator{
+		Replicas: replicas,
+	}
+}
+
+// ReplicaOverlayGenerator sets the replicas of the kustomization from the
+// fields of the parent resource.
+type ReplicaOverlayGenerator struct {
+	Replicas []ReplicaBinding
+}
+
+// Generate produces files to be written to the overlay folder of kustomization
+// process.
+func (rog ReplicaOverlayGenerator) Generate(cr resource.ParentResource, k *types.Kustomization) ([]OverlayFile, error) {
+	// NOTE: The kustomization is reused for every parent resource, so the
+	// bound replicas are dropped first rather than left with the count of the
+	// previous one.
+	bound := make(map[string]bool, len(rog.Replicas))
+	for _, r := range rog.Replicas {
+		bound[r.Name] = true
+	}
+	replicas := make([]types.Replica, 0, len(k.Replicas)+len(rog.Replicas))
+	for _, r := range k.Replicas {
+		if !bound[r.Name] {
+			replicas = append(replicas, r)
+		}
+	}
+	for _, b := range rog.Replicas {
+		count, ok, err := replicaCount(cr, b.CountFrom)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if b.Count == nil {
+				continue
+			}
+			count = *b.Count
+		}
+		replicas = append(replicas, types.Replica{Name: b.Name, Count: count})
+	}
+	k.Replicas = replicas
+	return nil, nil
+}
+
+// replicaCount returns the number of replicas in the field of the given parent
+// resource at the given path and whether it's set.
+func replicaCount(cr resource.ParentResource, path string) (int64, bool, error) {
+	val, ok, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(path, ".")...)
+	if err != nil {
+		return 0, false, &resource.ValuesError{Path: path, Err: err}
+	}
+	if !ok || val == nil {
+		return 0, false, nil
+	}
+	var count int64
+	switch v := val.(type) {
+	case int64:
+		count = v
+	case float64:
+		// NOTE: Numbers in JSON are decoded as float64 unless they're read
+		// through the unstructured decoder of the API machinery.
+		count = int64(v)
+		if float64(count) != v {
+			count = -1
+		}
+	default:
+		count = -1
+	}
+	if count < 0 {
+		return 0, false, &resource.ValuesError{Path: path, Err: errors.Errorf(errFmtReplicaNotInteger, path)}
+	}
+	return count, true, nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kustomize
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseReplicaBindings(t *testing.T) {
+	one := int64(1)
+	type want struct {
+		replicas []ReplicaBinding
+		err      error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "Valid replica bindings should be parsed.",
+			data:   `[{name: app, countFrom: spec.replicas, count: 1}]`,
+			want:   want{replicas: []ReplicaBinding{{Name: "app", CountFrom: "spec.replicas", Count: &one}}},
+		},
+		"NoName": {
+			reason: "A binding without a name should be rejected.",
+			data:   `[{countFrom: spec.replicas}]`,
+			want:   want{err: errors.Errorf(errFmtReplicaName, 0)},
+		},
+		"Duplicate": {
+			reason: "The replicas of an object should not be bound more than once.",
+			data:   `[{name: app, countFrom: spec.replicas}, {name: app, countFrom: spec.size}]`,
+			want:   want{err: errors.Errorf(errFmtReplicaDuplicate, "app")},
+		},
+		"NoCountFrom": {
+			reason: "A binding without a countFrom field should be rejected.",
+			data:   `[{name: app, count: 2}]`,
+			want:   want{err: errors.Errorf(errFmtReplicaCountFrom, "app")},
+		},
+		"Negative": {
+			reason: "A binding with a negative count should be rejected.",
+			data:   `[{name: app, countFrom: spec.replicas, count: -1}]`,
+			want:   want{err: errors.Errorf(errFmtReplicaNegative, "app")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReplicaBindings(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseReplicaBindings(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.replicas, got); diff != "" {
+				t.Errorf("\n%s\nParseReplicaBindings(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReplicaOverlayGenerator(t *testing.T) {
+	two := int64(2)
+	replicas := []ReplicaBinding{
+		{Name: "app", CountFrom: "spec.replicas", Count: &two},
+		{Name: "worker", CountFrom: "spec.workers"},
+	}
+	static := types.Replica{Name: "cache", Count: 1}
+	parent := func(spec map[string]interface{}) resource.ParentResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	}
+
+	type want struct {
+		replicas []types.Replica
+		err      error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		k      *types.Kustomization
+		want   want
+	}{
+		"Bound": {
+			reason: "The counts should be read from the fields of the parent resource and replace the entries with the same name.",
+			cr:     parent(map[string]interface{}{"replicas": int64(3), "workers": float64(5)}),
+			k:      &types.Kustomization{Replicas: []types.Replica{static, {Name: "app", Count: 1}}},
+			want: want{replicas: []types.Replica{
+				static,
+				{Name: "app", Count: 3},
+				{Name: "worker", Count: 5},
+			}},
+		},
+		"Defaults": {
+			reason: "The literal count should be used if the parent resource doesn't have the field, and an object without a count should be left out.",
+			cr:     parent(map[string]interface{}{}),
+			k:      &types.Kustomization{Replicas: []types.Replica{{Name: "worker", Count: 4}}},
+			want: want{replicas: []types.Replica{
+				{Name: "app", Count: 2},
+			}},
+		},
+		"NotInteger": {
+			reason: "A field whose value is not a non-negative integer should be rejected.",
+			cr:     parent(map[string]interface{}{"replicas": "three"}),
+			k:      &types.Kustomization{},
+			want: want{
+				err: &resource.ValuesError{Path: "spec.replicas", Err: errors.Errorf(errFmtReplicaNotInteger, "spec.replicas")},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReplicaOverlayGenerator(replicas).Generate(tc.cr, tc.k)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.replicas, tc.k.Replicas); diff != "" {
+				t.Errorf("\n%s\nGenerate(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
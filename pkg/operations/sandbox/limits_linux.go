@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandbox
+
+import (
+	"math"
+	"syscall"
+	"time"
+)
+
+// enforce sets the resource limits of the current process. The process is
+// killed with SIGXCPU once it uses up its CPU time, and its allocations fail
+// once its address space reaches the memory limit, which the Go runtime
+// reports as a fatal out of memory error.
+func enforce(l Limits) error {
+	if l.Memory > 0 {
+		if err := setrlimit(syscall.RLIMIT_AS, uint64(l.Memory)); err != nil {
+			return err
+		}
+	}
+	if l.CPU > 0 {
+		return setrlimit(syscall.RLIMIT_CPU, uint64(math.Ceil(float64(l.CPU)/float64(time.Second))))
+	}
+	return nil
+}
+
+func setrlimit(resource int, v uint64) error {
+	return syscall.Setrlimit(resource, &syscall.Rlimit{Cur: v, Max: v})
+}
@@ -0,0 +1,388 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// AnnotationKey is the annotation on the StackDefinition whose value is
+	// the YAML representation of the Limits of the subprocess that the
+	// engine runs in. The engine runs in the controller process if it's not
+	// set.
+	AnnotationKey = "templatestacks.crossplane.io/sandbox"
+
+	// DefaultMemory is the default maximum address space of the subprocess.
+	DefaultMemory = 1 << 30
+
+	// DefaultTimeout is the default maximum time the subprocess can run.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultMaxOutput is the default maximum size of the standard output of
+	// the subprocess.
+	DefaultMaxOutput = 64 << 20
+
+	// MaxErrorOutputLength is the maximum length of the standard error of the
+	// subprocess that is included in the errors.
+	MaxErrorOutputLength = 1024
+
+	errParseLimits = "could not parse the sandbox limits"
+	errFmtMemory   = "cannot parse the memory limit %q"
+	errFmtCPU      = "cannot parse the CPU limit %q"
+	errFmtTimeout  = "cannot parse the timeout %q"
+	errFmtNegative = "%s limit cannot be negative"
+	errMarshal     = "cannot marshal the parent resource"
+	errUnmarshal   = "cannot unmarshal the output of the sandbox"
+	errRun         = "cannot run the sandbox"
+	errFmtTimedOut = "the sandbox did not finish in %s"
+	errFmtExited   = "the sandbox failed with %s, it may have run out of memory or CPU time"
+	errFmtOutput   = "the output of the sandbox exceeds %d bytes"
+	errReadRequest = "cannot read the parent resource"
+	errBuildEngine = "cannot build the engine"
+	errConvert     = "cannot convert the child resource"
+	errWriteResult = "cannot write the result"
+	errApplyLimits = "cannot apply the limits"
+
+	reasonValues = "Values"
+	reasonRender = "Render"
+)
+
+// Limits are the limits of the subprocess that the engine runs in.
+type Limits struct {
+	// Memory is the maximum address space of the subprocess in bytes.
+	// Defaults to DefaultMemory. Zero means unlimited.
+	Memory int64
+
+	// CPU is the maximum CPU time of the subprocess. Zero means unlimited.
+	CPU time.Duration
+
+	// Timeout is the maximum time the subprocess can run. Defaults to
+	// DefaultTimeout. Zero means unlimited.
+	Timeout time.Duration
+}
+
+// limits is the YAML representation of Limits.
+type limits struct {
+	Memory  *string `json:"memory,omitempty"`
+	CPU     string  `json:"cpu,omitempty"`
+	Timeout string  `json:"timeout,omitempty"`
+}
+
+// ParseLimits parses the given YAML representation of the limits, typically
+// the value of AnnotationKey annotation, e.g. {memory: 512Mi, cpu: 10s,
+// timeout: 20s}. The memory is a Kubernetes quantity and the times are Go
+// durations.
+func ParseLimits(data string) (Limits, error) {
+	raw := limits{}
+	if err := yaml.Unmarshal([]byte(data), &raw); err != nil {
+		return Limits{}, errors.Wrap(err, errParseLimits)
+	}
+	l := Limits{Memory: DefaultMemory, Timeout: DefaultTimeout}
+	if raw.Memory != nil {
+		q, err := kresource.ParseQuantity(*raw.Memory)
+		if err != nil {
+			return Limits{}, errors.Wrapf(err, errFmtMemory, *raw.Memory)
+		}
+		l.Memory = q.Value()
+	}
+	var err error
+	if raw.CPU != "" {
+		if l.CPU, err = time.ParseDuration(raw.CPU); err != nil {
+			return Limits{}, errors.Wrapf(err, errFmtCPU, raw.CPU)
+		}
+	}
+	if raw.Timeout != "" {
+		if l.Timeout, err = time.ParseDuration(raw.Timeout); err != nil {
+			return Limits{}, errors.Wrapf(err, errFmtTimeout, raw.Timeout)
+		}
+	}
+	for name, v := range map[string]int64{"memory": l.Memory, "cpu": int64(l.CPU), "timeout": int64(l.Timeout)} {
+		if v < 0 {
+			return Limits{}, errors.Errorf(errFmtNegative, name)
+		}
+	}
+	return l, nil
+}
+
+// WithCommand returns an Option that changes the executable of the
+// subprocess and its arguments. The executable is expected to call Serve.
+func WithCommand(cmd string, args ...string) Option {
+	return func(e *Engine) {
+		e.Command = cmd
+		e.Args = args
+	}
+}
+
+// WithLimits returns an Option that changes the limits of the subprocess.
+func WithLimits(l Limits) Option {
+	return func(e *Engine) {
+		e.Limits = l
+	}
+}
+
+// WithMaxOutput returns an Option that changes the maximum size of the
+// standard output of the subprocess.
+func WithMaxOutput(n int64) Option {
+	return func(e *Engine) {
+		e.MaxOutput = n
+	}
+}
+
+// NewSandboxEngine returns a new sandbox Engine to be used as
+// templating.Engine.
+func NewSandboxEngine(o ...Option) *Engine {
+	e := &Engine{Limits: Limits{Memory: DefaultMemory, Timeout: DefaultTimeout}, MaxOutput: DefaultMaxOutput}
+	for _, f := range o {
+		f(e)
+	}
+	return e
+}
+
+// Engine renders the child resources in a subprocess, typically the
+// controller binary itself, so that a chart or a kustomization that exhausts
+// the memory or the CPU kills only the subprocess rather than the controller
+// and all parent resources it manages. The parent resource is written to the
+// standard input of the subprocess as JSON, and the subprocess writes the
+// child resources and the warnings, or the error of the engine, to its
+// standard output. It's killed when its timeout passes or the given context is done, and it's
+// expected to enforce the other limits on itself.
+type Engine struct {
+	// Command is the path of the executable of the subprocess.
+	Command string
+
+	// Args are the arguments of the executable.
+	Args []string
+
+	// Limits of the subprocess.
+	Limits Limits
+
+	// MaxOutput is the maximum size of the standard output of the subprocess
+	// in bytes so that a subprocess that writes without bounds does not
+	// exhaust the memory of the controller. Zero means unlimited.
+	MaxOutput int64
+}
+
+// result is what the subprocess writes to its standard output.
+type result struct {
+	Resources []map[string]interface{} `json:"resources,omitempty"`
+	Warnings  []string                 `json:"warnings,omitempty"`
+	Error     *failure                 `json:"error,omitempty"`
+}
+
+// failure is an error of the engine in the subprocess. The reason keeps the
+// types of the errors whose types matter to the reconciler.
+type failure struct {
+	Reason  string `json:"reason,omitempty"`
+	Path    string `json:"path,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// Run returns the result of the templating operation.
+func (e *Engine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	in, err := json.Marshal(cr)
+	if err != nil {
+		return resource.RenderResult{}, errors.Wrap(err, errMarshal)
+	}
+	var (
+		runCtx context.Context
+		cancel context.CancelFunc
+	)
+	if e.Limits.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, e.Limits.Timeout)
+	} else {
+		runCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, e.Command, e.Args...) // nolint:gosec
+	cmd.Stdin = bytes.NewReader(in)
+	stdout, stderr := &limitedBuffer{max: e.MaxOutput, cancel: cancel}, &tailBuffer{max: MaxErrorOutputLength}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return resource.RenderResult{}, ctx.Err()
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Errorf(errFmtTimedOut, e.Limits.Timeout)}
+	}
+	if stdout.exceeded {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Errorf(errFmtOutput, e.MaxOutput)}
+	}
+	if err != nil {
+		return resource.RenderResult{}, exitError(err, stderr.String())
+	}
+	res := result{}
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return resource.RenderResult{}, &resource.RenderError{Err: errors.Wrap(err, errUnmarshal)}
+	}
+	if res.Error != nil {
+		return resource.RenderResult{}, res.Error.err()
+	}
+	list := make([]resource.ChildResource, len(res.Resources))
+	for i, obj := range res.Resources {
+		list[i] = &unstructured.Unstructured{Object: obj}
+	}
+	return resource.RenderResult{Children: list, Warnings: res.Warnings}, nil
+}
+
+// A limitedBuffer keeps at most max bytes that are written to it and cancels
+// the subprocess once more is written. The writes never fail so that the
+// subprocess is not blocked on a full pipe until it's killed.
+type limitedBuffer struct {
+	// NOTE: The buffer is not embedded, otherwise its ReadFrom would be used
+	// to copy the output of the subprocess and bypass the limit of Write.
+	buf      bytes.Buffer
+	max      int64
+	cancel   context.CancelFunc
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.exceeded {
+		return len(p), nil
+	}
+	if b.max > 0 && int64(b.buf.Len()+len(p)) > b.max {
+		b.exceeded = true
+		b.buf.Reset()
+		b.cancel()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// A tailBuffer keeps only the last max bytes that are written to it.
+type tailBuffer struct {
+	data []byte
+	max  int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.data = append(b.data, p...)
+	if len(b.data) > b.max {
+		b.data = append(b.data[:0], b.data[len(b.data)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	return string(b.data)
+}
+
+// exitError returns a resource.RenderError with the given standard error of
+// the subprocess that did not exit successfully.
+func exitError(err error, stderr string) error {
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return &resource.RenderError{Err: errors.Wrap(err, errRun)}
+	}
+	// NOTE: The end of the output is where a crashing Go program reports
+	// why, e.g. that it ran out of memory, so only that is kept.
+	msg := strings.TrimSpace(stderr)
+	if msg == "" {
+		return &resource.RenderError{Err: errors.Errorf(errFmtExited, ee.String())}
+	}
+	return &resource.RenderError{Err: errors.Wrapf(errors.New(msg), errFmtExited, ee.String())}
+}
+
+// err returns the error that the failure in the subprocess stands for.
+func (f *failure) err() error {
+	switch f.Reason {
+	case reasonValues:
+		return &resource.ValuesError{Path: f.Path, Err: errors.New(f.Message)}
+	case reasonRender:
+		return &resource.RenderError{File: f.File, Line: f.Line, Err: errors.New(f.Message)}
+	}
+	return errors.New(f.Message)
+}
+
+// failureOf returns the failure that stands for the given error of the engine.
+func failureOf(err error) *failure {
+	var (
+		ve *resource.ValuesError
+		re *resource.RenderError
+	)
+	switch {
+	case errors.As(err, &ve):
+		return &failure{Reason: reasonValues, Path: ve.Path, Message: ve.Err.Error()}
+	case errors.As(err, &re):
+		return &failure{Reason: reasonRender, File: re.File, Line: re.Line, Message: re.Err.Error()}
+	}
+	return &failure{Message: err.Error()}
+}
+
+// Serve is the entrypoint of the subprocess. It enforces the given limits on
+// the process, builds the engine with the given function, renders the parent
+// resource that is read from the given input and writes the result to the
+// given output. The errors of the engine are written to the output as well;
+// the returned error means that the subprocess itself failed.
+func Serve(in io.Reader, out io.Writer, l Limits, newRunner func() (Runner, error)) error {
+	if err := enforce(l); err != nil {
+		return errors.Wrap(err, errApplyLimits)
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return errors.Wrap(err, errReadRequest)
+	}
+	cr := &unstructured.Unstructured{}
+	if err := cr.UnmarshalJSON(data); err != nil {
+		return errors.Wrap(err, errReadRequest)
+	}
+	r, err := newRunner()
+	if err != nil {
+		return errors.Wrap(err, errBuildEngine)
+	}
+	// NOTE: The controller kills the subprocess when the reconciliation is
+	// cancelled or times out.
+	return errors.Wrap(json.NewEncoder(out).Encode(render(r, cr)), errWriteResult)
+}
+
+func render(r Runner, cr resource.ParentResource) result {
+	rendered, err := r.Run(context.Background(), cr)
+	if err != nil {
+		return result{Error: failureOf(err)}
+	}
+	res := result{Resources: make([]map[string]interface{}, len(rendered.Children)), Warnings: rendered.Warnings}
+	for i, o := range rendered.Children {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return result{Error: failureOf(errors.Wrap(err, errConvert))}
+		}
+		res.Resources[i] = obj
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sandbox
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+type runnerFunc func(context.Context, resource.ParentResource) (resource.RenderResult, error)
+
+func (f runnerFunc) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	return f(ctx, cr)
+}
+
+func TestParseLimits(t *testing.T) {
+	type want struct {
+		limits Limits
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Defaults": {
+			reason: "The limits that are not given should have their defaults.",
+			data:   "{}",
+			want:   want{limits: Limits{Memory: DefaultMemory, Timeout: DefaultTimeout}},
+		},
+		"Full": {
+			reason: "All limits should be parsed.",
+			data:   "memory: 512Mi\ncpu: 10s\ntimeout: 20s\n",
+			want:   want{limits: Limits{Memory: 512 << 20, CPU: 10 * time.Second, Timeout: 20 * time.Second}},
+		},
+		"Unlimited": {
+			reason: "Zero should remove the default limits.",
+			data:   "memory: \"0\"\ntimeout: 0s\n",
+			want:   want{limits: Limits{}},
+		},
+		"InvalidMemory": {
+			reason: "A memory limit that is not a quantity should be rejected.",
+			data:   "memory: lots\n",
+			want:   want{err: errors.Wrapf(kresource.ErrFormatWrong, errFmtMemory, "lots")},
+		},
+		"NegativeTimeout": {
+			reason: "A negative limit should be rejected.",
+			data:   "timeout: -1s\n",
+			want:   want{err: errors.Errorf(errFmtNegative, "timeout")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseLimits(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseLimits(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.limits, got); diff != "" {
+				t.Errorf("\n%s\nParseLimits(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	cr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "cool"},
+	}}
+	type want struct {
+		result resource.RenderResult
+		err    error
+	}
+	cases := map[string]struct {
+		reason    string
+		script    string
+		timeout   time.Duration
+		maxOutput int64
+		want      want
+	}{
+		"Success": {
+			reason: "The child resources and the warnings that the subprocess writes should be returned.",
+			script: "#!/bin/sh\ngrep -q '\"name\":\"cool\"' || exit 1\necho '{\"resources\": [{\"apiVersion\": \"v1\", \"kind\": \"ConfigMap\"}], \"warnings\": [\"careful\"]}'\n",
+			want: want{result: resource.RenderResult{
+				Children: []resource.ChildResource{
+					&unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}},
+				},
+				Warnings: []string{"careful"},
+			}},
+		},
+		"ValuesError": {
+			reason: "A values error of the engine in the subprocess should be returned as a ValuesError.",
+			script: "#!/bin/sh\necho '{\"error\": {\"reason\": \"Values\", \"path\": \"spec.replicas\", \"message\": \"must be positive\"}}'\n",
+			want:   want{err: &resource.ValuesError{Path: "spec.replicas", Err: errors.New("must be positive")}},
+		},
+		"Crashed": {
+			reason: "A subprocess that crashes should result in a RenderError with the end of its standard error.",
+			script: "#!/bin/sh\necho 'fatal error: out of memory' >&2\nexit 2\n",
+			want:   want{err: &resource.RenderError{Err: errors.Wrapf(errors.New("fatal error: out of memory"), errFmtExited, "exit status 2")}},
+		},
+		"CrashedVerbosely": {
+			reason: "Only the end of a long standard error should be kept.",
+			script: "#!/bin/sh\nhead -c 4096 /dev/zero | tr '\\0' x >&2\necho 'fatal error: out of memory' >&2\nexit 2\n",
+			want: want{err: &resource.RenderError{Err: errors.Wrapf(
+				errors.New(strings.Repeat("x", MaxErrorOutputLength-len("fatal error: out of memory\n"))+"fatal error: out of memory"),
+				errFmtExited, "exit status 2")}},
+		},
+		"OutputTooLarge": {
+			reason:    "A subprocess that writes more than the maximum output should be killed.",
+			script:    "#!/bin/sh\nhead -c 4096 /dev/zero\nexec sleep 10\n",
+			maxOutput: 1024,
+			want:      want{err: &resource.RenderError{Err: errors.Errorf(errFmtOutput, 1024)}},
+		},
+		"TimedOut": {
+			reason:  "A subprocess that does not finish in time should be killed.",
+			script:  "#!/bin/sh\nexec sleep 10\n",
+			timeout: 100 * time.Millisecond,
+			want:    want{err: &resource.RenderError{Err: errors.Errorf(errFmtTimedOut, 100*time.Millisecond)}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "sandbox")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir) // nolint:errcheck
+			cmd := filepath.Join(dir, "render")
+			if err := ioutil.WriteFile(cmd, []byte(tc.script), 0700); err != nil {
+				t.Fatal(err)
+			}
+			opts := []Option{WithCommand(cmd)}
+			if tc.timeout != 0 {
+				opts = append(opts, WithLimits(Limits{Timeout: tc.timeout}))
+			}
+			if tc.maxOutput != 0 {
+				opts = append(opts, WithMaxOutput(tc.maxOutput))
+			}
+			got, err := NewSandboxEngine(opts...).Run(context.Background(), cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	cr := &unstructured.Unstructured{}
+	cases := map[string]struct {
+		reason string
+		runner Runner
+		want   result
+	}{
+		"Success": {
+			reason: "The child resources should be written as their unstructured content along with the warnings.",
+			runner: runnerFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{
+					Children: []resource.ChildResource{&unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}}},
+					Warnings: []string{"careful"},
+				}, nil
+			}),
+			want: result{Resources: []map[string]interface{}{{"kind": "ConfigMap"}}, Warnings: []string{"careful"}},
+		},
+		"RenderError": {
+			reason: "The file and the line of a RenderError should be kept.",
+			runner: runnerFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{}, errors.Wrap(&resource.RenderError{File: "deployment.yaml", Line: 3, Err: errors.New("boom")}, "cannot render")
+			}),
+			want: result{Error: &failure{Reason: reasonRender, File: "deployment.yaml", Line: 3, Message: "boom"}},
+		},
+		"OtherError": {
+			reason: "The message of other errors should be kept.",
+			runner: runnerFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{}, errors.New("boom")
+			}),
+			want: result{Error: &failure{Message: "boom"}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := render(tc.runner, cr)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nrender(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/perlin-network/life/compiler"
+	"github.com/perlin-network/life/exec"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	defaultRootPath = "resources"
+	defaultModule   = "engine.wasm"
+
+	// ModuleAnnotationKey is the annotation on the StackDefinition whose
+	// value is the path of the WebAssembly module that renders the child
+	// resources. Relative paths are relative to the resource path.
+	ModuleAnnotationKey = "templatestacks.crossplane.io/wasm-module"
+
+	// AllocateFunction is the function that the module exports to allocate
+	// the given number of bytes in its memory. It returns the address of the
+	// allocated bytes.
+	AllocateFunction = "allocate"
+
+	// RenderFunction is the function that the module exports to render the
+	// parent resource, given the address and the length of its JSON in the
+	// memory of the module. It returns the address of the output in the
+	// upper 32 bits and its length in the lower 32 bits.
+	RenderFunction = "render"
+
+	// DefaultMaxMemoryPages is the default maximum number of 64KiB pages of
+	// memory that the module can use.
+	DefaultMaxMemoryPages = 1024
+
+	// DefaultGasLimit is the default maximum number of instructions that the
+	// module can run for a single rendering.
+	DefaultGasLimit = 1 << 30
+
+	// gasSlice is the number of instructions that the module runs between
+	// the checks of the context of the rendering.
+	gasSlice = 1 << 20
+
+	errSpecCast      = "parent resource spec could not be casted into a map[string]interface{}"
+	errReadModule    = "cannot read the WebAssembly module"
+	errLoadModule    = "cannot load the WebAssembly module"
+	errFmtNoExport   = "the WebAssembly module does not export the %s function"
+	errFmtImport     = "the WebAssembly module imports %s.%s, but imports are not allowed"
+	errMarshal       = "cannot marshal the parent resource"
+	errAllocate      = "cannot allocate memory in the WebAssembly module"
+	errRender        = "the WebAssembly module failed to render"
+	errFmtOutOfRange = "the WebAssembly module returned %d bytes at address %d, which is out of its memory"
+	errParse         = "could not parse the output of the WebAssembly module"
+	errFmtGasLimit   = "the WebAssembly module exceeded its gas limit of %d instructions"
+	errCancelled     = "the WebAssembly module was stopped"
+)
+
+// WithResourcePath returns an Option that changes the resource path of the
+// Engine.
+func WithResourcePath(path string) Option {
+	return func(e *Engine) {
+		e.ResourcePath = path
+	}
+}
+
+// WithModule returns an Option that changes the WebAssembly module of the
+// Engine.
+func WithModule(path string) Option {
+	return func(e *Engine) {
+		e.Module = path
+	}
+}
+
+// WithMaxMemoryPages returns an Option that changes the maximum number of
+// 64KiB pages of memory that the module can use.
+func WithMaxMemoryPages(n int) Option {
+	return func(e *Engine) {
+		e.MaxMemoryPages = n
+	}
+}
+
+// WithGasLimit returns an Option that changes the maximum number of
+// instructions that the module can run for a single rendering.
+func WithGasLimit(n uint64) Option {
+	return func(e *Engine) {
+		e.GasLimit = n
+	}
+}
+
+// NewWASMEngine returns a new WebAssembly Engine to be used as
+// templating.Engine.
+func NewWASMEngine(o ...Option) *Engine {
+	e := &Engine{
+		ResourcePath:   defaultRootPath,
+		Module:         defaultModule,
+		MaxMemoryPages: DefaultMaxMemoryPages,
+		GasLimit:       DefaultGasLimit,
+	}
+	for _, f := range o {
+		f(e)
+	}
+	return e
+}
+
+// Engine renders the child resources by running a WebAssembly module that is
+// provided by the stack in an in-process interpreter. The module is
+// sandboxed; it cannot import any function, so it has no access to the
+// filesystem, the network or the clock. Every rendering runs in a fresh
+// instance of the module as follows:
+//
+//  1. The JSON of the parent resource is written to the memory that the
+//     AllocateFunction of the module allocates.
+//  2. The RenderFunction of the module is called with the address and the
+//     length of the JSON, and it returns the address and the length of its
+//     output.
+//  3. The output is read as a JSON object whose resources field is the list
+//     of child resources. If its error field is set instead, the rendering
+//     fails with a resource.RenderError, or with a resource.ValuesError if
+//     its path field names the offending field of the parent resource.
+type Engine struct {
+	// ResourcePath is the folder that the module resides in.
+	ResourcePath string
+
+	// Module is the path of the WebAssembly module. It's relative to the
+	// resource path unless it's absolute.
+	Module string
+
+	// MaxMemoryPages is the maximum number of 64KiB pages of memory that the
+	// module can use.
+	MaxMemoryPages int
+
+	// GasLimit is the maximum number of instructions that the module can run
+	// for a single rendering, so that a module that loops forever does not
+	// block the reconciliation.
+	GasLimit uint64
+}
+
+// An output is what the RenderFunction of the module returns.
+type output struct {
+	Resources []map[string]interface{} `json:"resources,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+	Path      string                   `json:"path,omitempty"`
+}
+
+// Run returns the result of the templating operation.
+func (e *Engine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if _, err := e.Values(cr); err != nil {
+		return resource.RenderResult{}, err
+	}
+	in, err := json.Marshal(cr)
+	if err != nil {
+		return resource.RenderResult{}, errors.Wrap(err, errMarshal)
+	}
+	vm, err := e.instantiate()
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	out, err := call(ctx, vm, e.GasLimit, in)
+	if err != nil {
+		return resource.RenderResult{}, &resource.RenderError{Err: err}
+	}
+	list, err := parseOutput(out)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	return resource.RenderResult{Children: list}, nil
+}
+
+// Values returns the spec of the given parent resource, which is the part of
+// the parent resource that the module is expected to read.
+func (e *Engine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	spec, exists := cr.UnstructuredContent()["spec"]
+	if !exists {
+		return map[string]interface{}{}, nil
+	}
+	values, ok := spec.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}
+	}
+	return values, nil
+}
+
+// instantiate returns a new instance of the module.
+func (e *Engine) instantiate() (vm *exec.VirtualMachine, err error) {
+	path := e.Module
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.ResourcePath, path)
+	}
+	code, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, errReadModule)
+	}
+	// NOTE: The resolver panics on imports, which the interpreter does not
+	// recover from on its own.
+	defer func() {
+		if r := recover(); r != nil {
+			vm, err = nil, &resource.RenderError{Err: errors.Wrap(fmt.Errorf("%v", r), errLoadModule)}
+		}
+	}()
+	vm, err = exec.NewVirtualMachine(code, exec.VMConfig{
+		DefaultMemoryPages:       1,
+		MaxMemoryPages:           e.MaxMemoryPages,
+		DefaultTableSize:         65536,
+		GasLimit:                 gasSlice,
+		ReturnOnGasLimitExceeded: true,
+	}, noImports{}, &compiler.SimpleGasPolicy{GasPerInstruction: 1})
+	if err != nil {
+		return nil, &resource.RenderError{Err: errors.Wrap(err, errLoadModule)}
+	}
+	return vm, nil
+}
+
+// call writes the given input to the memory of the given instance, calls its
+// RenderFunction and returns the output. The instance runs at most the given
+// number of instructions, and stops when the given context is done.
+func call(ctx context.Context, vm *exec.VirtualMachine, gasLimit uint64, in []byte) (out []byte, err error) {
+	// NOTE: The interpreter panics on some traps, e.g. out of bounds memory
+	// accesses of the module.
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, errors.Wrap(fmt.Errorf("%v", r), errRender)
+		}
+	}()
+	allocate, ok := vm.GetFunctionExport(AllocateFunction)
+	if !ok {
+		return nil, errors.Errorf(errFmtNoExport, AllocateFunction)
+	}
+	render, ok := vm.GetFunctionExport(RenderFunction)
+	if !ok {
+		return nil, errors.Errorf(errFmtNoExport, RenderFunction)
+	}
+	ptr, err := run(ctx, vm, gasLimit, allocate, int64(len(in)))
+	if err != nil {
+		return nil, errors.Wrap(err, errAllocate)
+	}
+	if ptr < 0 || ptr+int64(len(in)) > int64(len(vm.Memory)) {
+		return nil, errors.Errorf(errFmtOutOfRange, len(in), ptr)
+	}
+	copy(vm.Memory[ptr:], in)
+	ret, err := run(ctx, vm, gasLimit, render, ptr, int64(len(in)))
+	if err != nil {
+		return nil, errors.Wrap(err, errRender)
+	}
+	addr, length := int64(uint64(ret)>>32), int64(uint32(ret))
+	if addr+length > int64(len(vm.Memory)) {
+		return nil, errors.Errorf(errFmtOutOfRange, length, addr)
+	}
+	out = make([]byte, length)
+	copy(out, vm.Memory[addr:addr+length])
+	return out, nil
+}
+
+// run calls the function with the given ID of the given instance. The
+// instance returns every time it runs out of its gas, which is raised by
+// gasSlice until the given limit is reached, so that the context can be
+// checked in between.
+func run(ctx context.Context, vm *exec.VirtualMachine, gasLimit uint64, id int, params ...int64) (int64, error) {
+	vm.Ignite(id, params...)
+	for !vm.Exited {
+		if err := ctx.Err(); err != nil {
+			return 0, errors.Wrap(err, errCancelled)
+		}
+		if gasLimit > 0 && vm.Gas >= gasLimit {
+			return 0, errors.Errorf(errFmtGasLimit, gasLimit)
+		}
+		vm.Config.GasLimit = vm.Gas + gasSlice
+		if gasLimit > 0 && vm.Config.GasLimit > gasLimit {
+			vm.Config.GasLimit = gasLimit
+		}
+		vm.Execute()
+	}
+	if vm.ExitError != nil {
+		return 0, fmt.Errorf("%v", vm.ExitError)
+	}
+	return vm.ReturnValue, nil
+}
+
+// parseOutput returns the child resources in the given output of the
+// RenderFunction, or the error that it reports.
+func parseOutput(data []byte) ([]resource.ChildResource, error) {
+	o := &output{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, &resource.RenderError{Err: errors.Wrap(err, errParse)}
+	}
+	switch {
+	case o.Error != "" && o.Path != "":
+		return nil, &resource.ValuesError{Path: o.Path, Err: errors.New(o.Error)}
+	case o.Error != "":
+		return nil, &resource.RenderError{Err: errors.New(o.Error)}
+	}
+	list := make([]resource.ChildResource, len(o.Resources))
+	for i, obj := range o.Resources {
+		list[i] = &unstructured.Unstructured{Object: obj}
+	}
+	return list, nil
+}
+
+// noImports is an exec.ImportResolver that resolves no imports, so that the
+// module has no access to anything outside of its own memory.
+type noImports struct{}
+
+func (noImports) ResolveFunc(module, field string) exec.FunctionImport {
+	panic(fmt.Sprintf(errFmtImport, module, field))
+}
+
+func (noImports) ResolveGlobal(module, field string) int64 {
+	panic(fmt.Sprintf(errFmtImport, module, field))
+}
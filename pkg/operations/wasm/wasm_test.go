@@ -0,0 +1,158 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wasm
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// loop is a WebAssembly module whose allocate function returns 0 and whose
+// render function loops forever.
+var loop = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+	// Types: (i32) -> i32 and (i32, i32) -> i64.
+	0x01, 0x0c, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e,
+	// Functions.
+	0x03, 0x03, 0x02, 0x00, 0x01,
+	// Memory of one page.
+	0x05, 0x03, 0x01, 0x00, 0x01,
+	// Exports: allocate and render.
+	0x07, 0x15, 0x02,
+	0x08, 'a', 'l', 'l', 'o', 'c', 'a', 't', 'e', 0x00, 0x00,
+	0x06, 'r', 'e', 'n', 'd', 'e', 'r', 0x00, 0x01,
+	// Code: i32.const 0 and loop br 0 end i64.const 0.
+	0x0a, 0x10, 0x02,
+	0x04, 0x00, 0x41, 0x00, 0x0b,
+	0x09, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00, 0x0b,
+}
+
+func TestRun(t *testing.T) {
+	invalid := &unstructured.Unstructured{Object: map[string]interface{}{"spec": "olala"}}
+	valid := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	dir, err := ioutil.TempDir("", "wasm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	if err := ioutil.WriteFile(filepath.Join(dir, defaultModule), loop, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	type want struct {
+		result []resource.ChildResource
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		ctx    context.Context
+		e      *Engine
+		cr     resource.ParentResource
+		want   want
+	}{
+		"SpecNotMap": {
+			reason: "A spec that is not an object should not be sent to the module.",
+			ctx:    context.Background(),
+			e:      NewWASMEngine(),
+			cr:     invalid,
+			want:   want{err: &resource.ValuesError{Path: "spec", Err: errors.New(errSpecCast)}},
+		},
+		"GasLimitExceeded": {
+			reason: "A module that runs more instructions than its gas limit should be stopped with a RenderError.",
+			ctx:    context.Background(),
+			e:      NewWASMEngine(WithResourcePath(dir), WithGasLimit(1000)),
+			cr:     valid,
+			want:   want{err: &resource.RenderError{Err: errors.Wrap(errors.Errorf(errFmtGasLimit, 1000), errRender)}},
+		},
+		"Cancelled": {
+			reason: "A module should be stopped with a RenderError when the context is done.",
+			ctx:    cancelled,
+			e:      NewWASMEngine(WithResourcePath(dir)),
+			cr:     valid,
+			want:   want{err: &resource.RenderError{Err: errors.Wrap(errors.Wrap(context.Canceled, errCancelled), errAllocate)}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.e.Run(tc.ctx, tc.cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got.Children); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	type want struct {
+		result []resource.ChildResource
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Resources": {
+			reason: "The resources field should be returned as the child resources.",
+			data:   `{"resources": [{"apiVersion": "v1", "kind": "ConfigMap"}]}`,
+			want: want{result: []resource.ChildResource{
+				&unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}},
+			}},
+		},
+		"RenderError": {
+			reason: "An error without a path should be returned as a RenderError.",
+			data:   `{"error": "boom"}`,
+			want:   want{err: &resource.RenderError{Err: errors.New("boom")}},
+		},
+		"ValuesError": {
+			reason: "An error with a path should be returned as a ValuesError.",
+			data:   `{"error": "must be positive", "path": "spec.replicas"}`,
+			want:   want{err: &resource.ValuesError{Path: "spec.replicas", Err: errors.New("must be positive")}},
+		},
+		"NotJSON": {
+			reason: "An output that is not a JSON object should be returned as a RenderError.",
+			data:   `olala`,
+			want:   want{err: &resource.RenderError{Err: errors.Wrap(errors.New("invalid character 'o' looking for beginning of value"), errParse)}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseOutput([]byte(tc.data))
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nparseOutput(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nparseOutput(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const errReviewAccess = "cannot review access"
+
+// NewAccessReviewer returns a new *AccessReviewer.
+func NewAccessReviewer(c client.Client, m meta.RESTMapper) *AccessReviewer {
+	return &AccessReviewer{client: c, mapper: m, allowed: map[access]bool{}}
+}
+
+// AccessReviewer reviews whether the controller has the permissions to manage
+// the child resources with SelfSubjectAccessReviews. The granted permissions
+// are remembered, while the missing ones are reviewed again every time so that
+// new grants are noticed.
+type AccessReviewer struct {
+	client client.Client
+	mapper meta.RESTMapper
+
+	mu      sync.RWMutex
+	allowed map[access]bool
+}
+
+type access struct {
+	namespace string
+	resource  schema.GroupResource
+	verb      string
+}
+
+// Missing returns the sorted group resources of the given child resources
+// that the controller does not have any of ChildVerbs for.
+func (a *AccessReviewer) Missing(ctx context.Context, list []resource.ChildResource) ([]schema.GroupResource, error) {
+	missing := map[schema.GroupResource]bool{}
+	for _, o := range list {
+		gr, namespaced := a.groupResource(o.GetObjectKind().GroupVersionKind())
+		if missing[gr] {
+			continue
+		}
+		ns := o.GetNamespace()
+		if !namespaced {
+			ns = ""
+		}
+		for _, verb := range ChildVerbs {
+			ok, err := a.allows(ctx, access{namespace: ns, resource: gr, verb: verb})
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				missing[gr] = true
+				break
+			}
+		}
+	}
+	result := make([]schema.GroupResource, 0, len(missing))
+	for gr := range missing {
+		result = append(result, gr)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].String() < result[j].String() })
+	return result, nil
+}
+
+// groupResource returns the group resource of the given kind and whether it's
+// namespaced. The resource name is guessed and it's assumed to be namespaced
+// if the kind is not served by the API server, e.g. its
+// CustomResourceDefinition is not installed yet.
+func (a *AccessReviewer) groupResource(gvk schema.GroupVersionKind) (schema.GroupResource, bool) {
+	if m, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+		return m.Resource.GroupResource(), m.Scope.Name() == meta.RESTScopeNameNamespace
+	}
+	plural, _ := meta.UnsafeGuessKindToResource(gvk)
+	return plural.GroupResource(), true
+}
+
+func (a *AccessReviewer) allows(ctx context.Context, acc access) (bool, error) {
+	a.mu.RLock()
+	ok := a.allowed[acc]
+	a.mu.RUnlock()
+	if ok {
+		return true, nil
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: acc.namespace,
+				Verb:      acc.verb,
+				Group:     acc.resource.Group,
+				Resource:  acc.resource.Resource,
+			},
+		},
+	}
+	if err := a.client.Create(ctx, review); err != nil {
+		return false, errors.Wrap(err, errReviewAccess)
+	}
+	if review.Status.Allowed {
+		a.mu.Lock()
+		a.allowed[acc] = true
+		a.mu.Unlock()
+	}
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestAccessReviewerMissing(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	clusterRole := schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(deployment, meta.RESTScopeNamespace)
+	mapper.Add(clusterRole, meta.RESTScopeRoot)
+
+	child := func(gvk schema.GroupVersionKind, ns string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		u.SetNamespace(ns)
+		return u
+	}
+	var reviews []authorizationv1.ResourceAttributes
+	c := &test.MockClient{
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			r := obj.(*authorizationv1.SelfSubjectAccessReview)
+			reviews = append(reviews, *r.Spec.ResourceAttributes)
+			// Only namespaced deployments can be managed.
+			r.Status.Allowed = r.Spec.ResourceAttributes.Resource == "deployments" && r.Spec.ResourceAttributes.Namespace == "default"
+			return nil
+		},
+	}
+	a := NewAccessReviewer(c, mapper)
+	list := []resource.ChildResource{
+		child(deployment, "default"),
+		child(clusterRole, "default"),
+		child(clusterRole, ""),
+	}
+	got, err := a.Missing(context.Background(), list)
+	if err != nil {
+		t.Fatalf("Missing(...): unexpected error: %v", err)
+	}
+	want := []schema.GroupResource{{Group: "rbac.authorization.k8s.io", Resource: "clusterroles"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Missing(...): -want, +got:\n%s", diff)
+	}
+	for _, r := range reviews {
+		if r.Resource == "clusterroles" && r.Namespace != "" {
+			t.Errorf("Missing(...): cluster-scoped resource is reviewed in namespace %s", r.Namespace)
+		}
+	}
+
+	// The granted permissions should not be reviewed again.
+	reviews = nil
+	if _, err := a.Missing(context.Background(), list[:1]); err != nil {
+		t.Fatalf("Missing(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(0, len(reviews)); diff != "" {
+		t.Errorf("Missing(...): -want reviews, +got reviews:\n%s", diff)
+	}
+}
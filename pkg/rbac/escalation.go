@@ -0,0 +1,293 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	errConvertRBAC = "cannot convert the child resource"
+	errGetRole     = "cannot get the role that the binding refers to"
+)
+
+// An Escalation is a child resource that grants permissions that the
+// controller does not hold.
+type Escalation struct {
+	Kind      string
+	Namespace string
+	Name      string
+
+	// Reason is what the child resource grants, e.g. "grants get secrets in
+	// namespace default".
+	Reason string
+}
+
+func (e Escalation) String() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("%s %s %s", e.Kind, e.Name, e.Reason)
+	}
+	return fmt.Sprintf("%s %s/%s %s", e.Kind, e.Namespace, e.Name, e.Reason)
+}
+
+// NewEscalationReviewer returns a new *EscalationReviewer that reviews the
+// permissions with the given client, and gets the roles that the bindings
+// refer to with the given reader.
+func NewEscalationReviewer(c client.Client, r client.Reader) *EscalationReviewer {
+	return &EscalationReviewer{client: c, reader: r, allowed: map[grant]bool{}}
+}
+
+// EscalationReviewer reviews whether the Roles, ClusterRoles, RoleBindings and
+// ClusterRoleBindings among the child resources grant only the permissions
+// that the controller holds itself, so that the parent resources cannot be
+// used to escalate privileges. The API server does the same check when they
+// are applied, but skips it if the controller is allowed to escalate or bind
+// the roles, e.g. when it runs as cluster-admin. Every permission is reviewed
+// with a SelfSubjectAccessReview. The granted permissions are remembered,
+// while the missing ones are reviewed again every time so that new grants are
+// noticed.
+type EscalationReviewer struct {
+	client client.Client
+	reader client.Reader
+
+	mu      sync.RWMutex
+	allowed map[grant]bool
+}
+
+// grant is a single permission of a policy rule. Path is set for the
+// permissions of the non-resource URLs.
+type grant struct {
+	attributes authorizationv1.ResourceAttributes
+	path       string
+}
+
+func (g grant) String() string {
+	if g.path != "" {
+		return g.attributes.Verb + " " + g.path
+	}
+	a := g.attributes
+	s := a.Verb + " " + a.Resource
+	if a.Subresource != "" {
+		s += "/" + a.Subresource
+	}
+	if a.Group != "" {
+		s += "." + a.Group
+	}
+	if a.Name != "" {
+		s += " " + a.Name
+	}
+	if a.Namespace == "" {
+		return s + " cluster-wide"
+	}
+	return s + " in namespace " + a.Namespace
+}
+
+type roleKey struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// Escalations returns the given child resources that grant permissions that
+// the controller does not hold, in their order. The bindings are checked
+// against the rules of the roles they refer to, which are read from the
+// given child resources if they are among them, or from the API server if
+// not.
+func (e *EscalationReviewer) Escalations(ctx context.Context, list []resource.ChildResource) ([]Escalation, error) { // nolint:gocyclo
+	roles := map[roleKey]*rbacv1.ClusterRole{}
+	for _, o := range list {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if gvk.Group != rbacv1.GroupName || (gvk.Kind != "Role" && gvk.Kind != "ClusterRole") {
+			continue
+		}
+		// NOTE: The ClusterRoles are converted to Roles as well, only their
+		// aggregation rule is lost.
+		r := &rbacv1.ClusterRole{}
+		if err := fromChild(o, r); err != nil {
+			return nil, err
+		}
+		roles[roleKey{kind: gvk.Kind, namespace: o.GetNamespace(), name: o.GetName()}] = r
+	}
+	var result []Escalation
+	for _, o := range list {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if gvk.Group != rbacv1.GroupName {
+			continue
+		}
+		var reasons []string
+		switch gvk.Kind {
+		case "Role", "ClusterRole":
+			r := roles[roleKey{kind: gvk.Kind, namespace: o.GetNamespace(), name: o.GetName()}]
+			if r.AggregationRule != nil {
+				reasons = append(reasons, "aggregates the rules of other ClusterRoles, which cannot be checked")
+			}
+			missing, err := e.missing(ctx, o.GetNamespace(), r.Rules)
+			if err != nil {
+				return nil, err
+			}
+			reasons = append(reasons, missing...)
+		case "RoleBinding", "ClusterRoleBinding":
+			b := &rbacv1.RoleBinding{}
+			if err := fromChild(o, b); err != nil {
+				return nil, err
+			}
+			key := roleKey{kind: b.RoleRef.Kind, name: b.RoleRef.Name}
+			if key.kind == "Role" {
+				key.namespace = o.GetNamespace()
+			}
+			r, err := e.role(ctx, roles, key)
+			if err != nil {
+				return nil, err
+			}
+			if r == nil {
+				reasons = append(reasons, fmt.Sprintf("binds %s %s that does not exist, which cannot be checked", key.kind, key.name))
+				break
+			}
+			missing, err := e.missing(ctx, o.GetNamespace(), r.Rules)
+			if err != nil {
+				return nil, err
+			}
+			reasons = append(reasons, missing...)
+		}
+		if len(reasons) > 0 {
+			result = append(result, Escalation{Kind: gvk.Kind, Namespace: o.GetNamespace(), Name: o.GetName(), Reason: strings.Join(reasons, ", ")})
+		}
+	}
+	return result, nil
+}
+
+// role returns the role with the given key from the given rendered roles, or
+// from the API server if it's not rendered. It returns nil if the role does
+// not exist.
+func (e *EscalationReviewer) role(ctx context.Context, rendered map[roleKey]*rbacv1.ClusterRole, key roleKey) (*rbacv1.ClusterRole, error) {
+	if r, ok := rendered[key]; ok {
+		return r, nil
+	}
+	nn := types.NamespacedName{Namespace: key.namespace, Name: key.name}
+	var err error
+	r := &rbacv1.ClusterRole{}
+	if key.kind == "Role" {
+		role := &rbacv1.Role{}
+		err = e.reader.Get(ctx, nn, role)
+		r.Rules = role.Rules
+	} else {
+		err = e.reader.Get(ctx, nn, r)
+	}
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return r, errors.Wrap(err, errGetRole)
+}
+
+// missing returns the permissions of the given rules in the given namespace,
+// or cluster-wide if it's empty, that the controller does not hold. The
+// non-resource URLs are granted only cluster-wide.
+func (e *EscalationReviewer) missing(ctx context.Context, namespace string, rules []rbacv1.PolicyRule) ([]string, error) {
+	var missing []string
+	for _, rule := range rules {
+		for _, g := range grants(namespace, rule) {
+			ok, err := e.allows(ctx, g)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				missing = append(missing, "grants "+g.String())
+			}
+		}
+	}
+	return missing, nil
+}
+
+// grants returns the permissions of the given rule in the given namespace.
+// The wildcards are reviewed as they are, since the SelfSubjectAccessReviews
+// of a wildcard are allowed only if the wildcard itself is granted.
+func grants(namespace string, rule rbacv1.PolicyRule) []grant {
+	var result []grant
+	if namespace == "" {
+		for _, path := range rule.NonResourceURLs {
+			for _, verb := range rule.Verbs {
+				result = append(result, grant{attributes: authorizationv1.ResourceAttributes{Verb: verb}, path: path})
+			}
+		}
+	}
+	names := rule.ResourceNames
+	if len(names) == 0 {
+		names = []string{""}
+	}
+	for _, group := range rule.APIGroups {
+		for _, res := range rule.Resources {
+			parts := strings.SplitN(res, "/", 2)
+			for _, verb := range rule.Verbs {
+				for _, name := range names {
+					a := authorizationv1.ResourceAttributes{Namespace: namespace, Verb: verb, Group: group, Resource: parts[0], Name: name}
+					if len(parts) == 2 {
+						a.Subresource = parts[1]
+					}
+					result = append(result, grant{attributes: a})
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (e *EscalationReviewer) allows(ctx context.Context, g grant) (bool, error) {
+	e.mu.RLock()
+	ok := e.allowed[g]
+	e.mu.RUnlock()
+	if ok {
+		return true, nil
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{}
+	if g.path != "" {
+		review.Spec.NonResourceAttributes = &authorizationv1.NonResourceAttributes{Path: g.path, Verb: g.attributes.Verb}
+	} else {
+		a := g.attributes
+		review.Spec.ResourceAttributes = &a
+	}
+	if err := e.client.Create(ctx, review); err != nil {
+		return false, errors.Wrap(err, errReviewAccess)
+	}
+	if review.Status.Allowed {
+		e.mu.Lock()
+		e.allowed[g] = true
+		e.mu.Unlock()
+	}
+	return review.Status.Allowed, nil
+}
+
+// fromChild converts the given child resource to the given typed object.
+func fromChild(o resource.ChildResource, into interface{}) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+	if err != nil {
+		return errors.Wrap(err, errConvertRBAC)
+	}
+	return errors.Wrap(runtime.DefaultUnstructuredConverter.FromUnstructured(u, into), errConvertRBAC)
+}
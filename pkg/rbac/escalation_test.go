@@ -0,0 +1,177 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestEscalationReviewerEscalations(t *testing.T) {
+	errBoom := errors.New("boom")
+	child := func(kind, namespace, name string, content map[string]interface{}) resource.ChildResource {
+		u := &unstructured.Unstructured{Object: content}
+		if u.Object == nil {
+			u.Object = map[string]interface{}{}
+		}
+		u.SetGroupVersionKind(rbacv1.SchemeGroupVersion.WithKind(kind))
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	rules := func(verbs ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"rules": []interface{}{map[string]interface{}{
+			"apiGroups": []interface{}{""},
+			"resources": []interface{}{"secrets"},
+			"verbs":     verbs,
+		}}}
+	}
+	roleRef := func(kind, name string) map[string]interface{} {
+		return map[string]interface{}{"roleRef": map[string]interface{}{"apiGroup": rbacv1.GroupName, "kind": kind, "name": name}}
+	}
+	// Only getting the secrets in the default namespace is allowed.
+	create := func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+		r := obj.(*authorizationv1.SelfSubjectAccessReview)
+		a := r.Spec.ResourceAttributes
+		r.Status.Allowed = a != nil && a.Namespace == "default" && a.Resource == "secrets" && a.Verb == "get"
+		return nil
+	}
+	get := func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+		if key.Name != "admin" {
+			return kerrors.NewNotFound(schema.GroupResource{Resource: "clusterroles"}, key.Name)
+		}
+		obj.(*rbacv1.ClusterRole).Rules = []rbacv1.PolicyRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}}
+		return nil
+	}
+	type want struct {
+		escalations []Escalation
+		err         error
+	}
+	cases := map[string]struct {
+		reason string
+		list   []resource.ChildResource
+		create test.MockCreateFn
+		want   want
+	}{
+		"Subset": {
+			reason: "No escalations should be returned if the controller holds all permissions.",
+			list: []resource.ChildResource{
+				child("Role", "default", "reader", rules("get")),
+				child("RoleBinding", "default", "reader", roleRef("Role", "reader")),
+				child("ConfigMap", "default", "config", nil),
+			},
+			create: create,
+		},
+		"Role": {
+			reason: "The permissions of a Role that the controller does not hold should be returned.",
+			list: []resource.ChildResource{
+				child("Role", "default", "reader", rules("get", "list")),
+				child("Role", "other", "reader", rules("get")),
+			},
+			create: create,
+			want: want{escalations: []Escalation{
+				{Kind: "Role", Namespace: "default", Name: "reader", Reason: "grants list secrets in namespace default"},
+				{Kind: "Role", Namespace: "other", Name: "reader", Reason: "grants get secrets in namespace other"},
+			}},
+		},
+		"Bindings": {
+			reason: "The bindings should be checked against the rules of the roles they refer to in their scope.",
+			list: []resource.ChildResource{
+				child("ClusterRole", "", "reader", rules("get")),
+				child("RoleBinding", "default", "reader", roleRef("ClusterRole", "reader")),
+				child("ClusterRoleBinding", "", "admin", roleRef("ClusterRole", "admin")),
+				child("ClusterRoleBinding", "", "missing", roleRef("ClusterRole", "missing")),
+			},
+			create: create,
+			want: want{escalations: []Escalation{
+				{Kind: "ClusterRole", Name: "reader", Reason: "grants get secrets cluster-wide"},
+				{Kind: "ClusterRoleBinding", Name: "admin", Reason: "grants * *.* cluster-wide"},
+				{Kind: "ClusterRoleBinding", Name: "missing", Reason: "binds ClusterRole missing that does not exist, which cannot be checked"},
+			}},
+		},
+		"ReviewFailed": {
+			reason: "The errors of the SelfSubjectAccessReviews should be returned.",
+			list: []resource.ChildResource{
+				child("Role", "default", "reader", rules("get")),
+			},
+			create: test.NewMockCreateFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errReviewAccess)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := &test.MockClient{MockCreate: tc.create, MockGet: get}
+			got, err := NewEscalationReviewer(c, c).Escalations(context.Background(), tc.list)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nEscalations(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.escalations, got); diff != "" {
+				t.Errorf("\n%s\nEscalations(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestGrants(t *testing.T) {
+	rule := rbacv1.PolicyRule{
+		APIGroups:       []string{"apps"},
+		Resources:       []string{"deployments/scale"},
+		ResourceNames:   []string{"web"},
+		Verbs:           []string{"update"},
+		NonResourceURLs: []string{"/healthz"},
+	}
+	cases := map[string]struct {
+		reason    string
+		namespace string
+		want      []string
+	}{
+		"Namespaced": {
+			reason:    "The non-resource URLs should not be granted in a namespace.",
+			namespace: "default",
+			want:      []string{"update deployments/scale.apps web in namespace default"},
+		},
+		"ClusterWide": {
+			reason: "The non-resource URLs should be granted cluster-wide.",
+			want:   []string{"update /healthz", "update deployments/scale.apps web cluster-wide"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, g := range grants(tc.namespace, rule) {
+				got = append(got, g.String())
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ngrants(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
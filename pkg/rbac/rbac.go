@@ -0,0 +1,153 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	stackDefinitionResource = "stackdefinitions"
+)
+
+var (
+	// ChildVerbs are the verbs that are needed to apply and delete the child
+	// resources.
+	ChildVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+	// ParentVerbs are the verbs that are needed to reconcile and manage the
+	// finalizer of the parent resource.
+	ParentVerbs = []string{"get", "list", "watch", "update", "patch"}
+
+	// ParentStatusVerbs are the verbs that are needed to report the status of
+	// the parent resource.
+	ParentStatusVerbs = []string{"get", "update", "patch"}
+
+	// StackDefinitionVerbs are the verbs that are needed to fetch the
+	// configuration during the startup.
+	StackDefinitionVerbs = []string{"get"}
+)
+
+// GroupVersionKinds returns the unique set of GroupVersionKinds of the given
+// child resources.
+func GroupVersionKinds(list []resource.ChildResource) []schema.GroupVersionKind {
+	seen := map[schema.GroupVersionKind]bool{}
+	var result []schema.GroupVersionKind
+	for _, o := range list {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if seen[gvk] {
+			continue
+		}
+		seen[gvk] = true
+		result = append(result, gvk)
+	}
+	return result
+}
+
+// PolicyRules returns the minimal set of rules that the templating controller
+// needs to reconcile the parent resource of given kind and the child resources
+// of given kinds.
+func PolicyRules(parent schema.GroupVersionKind, children []schema.GroupVersionKind) []rbacv1.PolicyRule {
+	parentResource, _ := meta.UnsafeGuessKindToResource(parent)
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{v1alpha1.SchemeGroupVersion.Group},
+			Resources: []string{stackDefinitionResource},
+			Verbs:     StackDefinitionVerbs,
+		},
+		{
+			APIGroups: []string{parent.Group},
+			Resources: []string{parentResource.Resource},
+			Verbs:     ParentVerbs,
+		},
+		{
+			APIGroups: []string{parent.Group},
+			Resources: []string{parentResource.Resource + "/status"},
+			Verbs:     ParentStatusVerbs,
+		},
+	}
+	grs := make([]schema.GroupResource, len(children))
+	for i, gvk := range children {
+		plural, _ := meta.UnsafeGuessKindToResource(gvk)
+		grs[i] = plural.GroupResource()
+	}
+	return append(rules, ChildPolicyRules(grs)...)
+}
+
+// ChildPolicyRules returns the rules that are needed to apply and delete the
+// child resources of given group resources, one rule per group.
+func ChildPolicyRules(children []schema.GroupResource) []rbacv1.PolicyRule {
+	groups := map[string]map[string]bool{}
+	for _, gr := range children {
+		if groups[gr.Group] == nil {
+			groups[gr.Group] = map[string]bool{}
+		}
+		groups[gr.Group][gr.Resource] = true
+	}
+	groupNames := make([]string, 0, len(groups))
+	for g := range groups {
+		groupNames = append(groupNames, g)
+	}
+	sort.Strings(groupNames)
+	rules := make([]rbacv1.PolicyRule, 0, len(groupNames))
+	for _, g := range groupNames {
+		resources := make([]string, 0, len(groups[g]))
+		for r := range groups[g] {
+			resources = append(resources, r)
+		}
+		sort.Strings(resources)
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{g},
+			Resources: resources,
+			Verbs:     ChildVerbs,
+		})
+	}
+	return rules
+}
+
+// NewClusterRole returns a ClusterRole with given name and rules.
+func NewClusterRole(name string, rules []rbacv1.PolicyRule) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRole",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Rules:      rules,
+	}
+}
+
+// NewRole returns a Role with given name, namespace and rules.
+func NewRole(name, namespace string, rules []rbacv1.PolicyRule) *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: rbacv1.SchemeGroupVersion.String(),
+			Kind:       "Role",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Rules:      rules,
+	}
+}
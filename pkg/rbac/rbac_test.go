@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rbac
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestPolicyRules(t *testing.T) {
+	deployment := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	service := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+
+	type args struct {
+		parent   schema.GroupVersionKind
+		children []resource.ChildResource
+	}
+	cases := map[string]struct {
+		args
+		want []rbacv1.PolicyRule
+	}{
+		"GroupedAndDeduplicated": {
+			args: args{
+				parent: fake.MockParentGVK,
+				children: []resource.ChildResource{
+					fake.NewMockResource(fake.WithGVK(service)),
+					fake.NewMockResource(fake.WithGVK(deployment)),
+					fake.NewMockResource(fake.WithGVK(configMap)),
+					fake.NewMockResource(fake.WithGVK(service)),
+				},
+			},
+			want: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{v1alpha1.SchemeGroupVersion.Group},
+					Resources: []string{"stackdefinitions"},
+					Verbs:     StackDefinitionVerbs,
+				},
+				{
+					APIGroups: []string{fake.MockParentGVK.Group},
+					Resources: []string{"mockresources"},
+					Verbs:     ParentVerbs,
+				},
+				{
+					APIGroups: []string{fake.MockParentGVK.Group},
+					Resources: []string{"mockresources/status"},
+					Verbs:     ParentStatusVerbs,
+				},
+				{
+					APIGroups: []string{""},
+					Resources: []string{"configmaps", "services"},
+					Verbs:     ChildVerbs,
+				},
+				{
+					APIGroups: []string{"apps"},
+					Resources: []string{"deployments"},
+					Verbs:     ChildVerbs,
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := PolicyRules(tc.args.parent, GroupVersionKinds(tc.args.children))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("PolicyRules(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// A RenderError is returned by the templating engines when the templates
+// cannot be rendered.
+type RenderError struct {
+	// File is the path of the template that failed, if known.
+	File string
+
+	// Line is the line in the template that failed, if known.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	switch {
+	case e.File != "" && e.Line != 0:
+		return fmt.Sprintf("cannot render %s:%d: %s", e.File, e.Line, e.Err)
+	case e.File != "":
+		return fmt.Sprintf("cannot render %s: %s", e.File, e.Err)
+	}
+	return fmt.Sprintf("cannot render: %s", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// A ValuesError is returned by the templating engines when a field of the
+// parent resource cannot be used as input of the templates.
+type ValuesError struct {
+	// Path is the field path in the parent resource, e.g. spec.replicas.
+	Path string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ValuesError) Error() string {
+	return fmt.Sprintf("invalid value at %s: %s", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValuesError) Unwrap() error {
+	return e.Err
+}
+
+// A PatchError is returned by the child resource patchers when a child
+// resource cannot be patched.
+type PatchError struct {
+	// Target is the reference of the child resource.
+	Target corev1.ObjectReference
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("cannot patch %s %s: %s", e.Target.Kind, e.Target.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
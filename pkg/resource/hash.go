@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// ShortHashLength is the length of the hashes that are used in places with
+	// length restrictions, like label values.
+	ShortHashLength = 16
+
+	errMarshalChild  = "cannot marshal child resource"
+	errMarshalParent = "cannot marshal parent resource"
+	errWalkDir       = "cannot walk the directory"
+	errReadFile      = "cannot read file"
+)
+
+// HashChildren returns hex encoded SHA-256 digest of the given list of child
+// resources. The order of the list affects the result.
+func HashChildren(list []ChildResource) (string, error) {
+	h := sha256.New()
+	for _, o := range list {
+		b, err := json.Marshal(o)
+		if err != nil {
+			return "", errors.Wrap(err, errMarshalChild)
+		}
+		// NOTE(muvaf): hash.Hash never returns an error on Write.
+		_, _ = h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashParent returns hex encoded SHA-256 digest of the fields of the given
+// parent resource that may affect the rendering, i.e. spec, identity, labels
+// and annotations, together with the given revision of the template source.
+// The given dot separated fields, e.g. status.endpoint, are included too.
+func HashParent(cr ParentResource, revision string, fields ...string) (string, error) {
+	in := map[string]interface{}{
+		"revision":    revision,
+		"uid":         cr.GetUID(),
+		"name":        cr.GetName(),
+		"namespace":   cr.GetNamespace(),
+		"labels":      cr.GetLabels(),
+		"annotations": cr.GetAnnotations(),
+		"spec":        cr.UnstructuredContent()["spec"],
+	}
+	if len(fields) != 0 {
+		values := map[string]interface{}{}
+		for _, f := range fields {
+			values[f], _, _ = unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(f, ".")...)
+		}
+		in["fields"] = values
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return "", errors.Wrap(err, errMarshalParent)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HashDirectory returns hex encoded SHA-256 digest of the content of all files
+// in the given directory, including the ones in its subdirectories. Relative
+// paths of the files are included in the digest so that renaming a file
+// results in a different digest.
+func HashDirectory(dir string) (string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, errWalkDir)
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", errors.Wrap(err, errWalkDir)
+		}
+		_, _ = h.Write([]byte(filepath.ToSlash(rel)))
+		if err := copyFile(h, path); err != nil {
+			return "", errors.Wrap(err, errReadFile)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ShortHash returns the first ShortHashLength characters of the given hash.
+func ShortHash(hash string) string {
+	if len(hash) <= ShortHashLength {
+		return hash
+	}
+	return hash[:ShortHashLength]
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	_, err = io.Copy(w, f)
+	return err
+}
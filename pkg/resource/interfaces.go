@@ -38,3 +38,13 @@ type ChildResource interface {
 	runtime.Object
 	metav1.Object
 }
+
+// A RenderResult is the output of a templating engine.
+type RenderResult struct {
+	// Children are the rendered child resources.
+	Children []ChildResource
+
+	// Warnings are the problems that did not stop the engine from rendering
+	// the child resources but should be reported, e.g. a deprecated chart.
+	Warnings []string
+}
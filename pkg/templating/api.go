@@ -40,6 +40,7 @@ const (
 	errDeleteChildResource = "cannot delete child resource"
 	errPriorityToInt       = "cannot convert deletion priority into integer"
 	errNotController       = "child resource is not controlled by given parent"
+	errHashChildren        = "cannot calculate hash of child resources"
 )
 
 // Constants used for annotations.
@@ -48,14 +49,26 @@ const (
 	RemoveDefaultAnnotationsTrueValue   = "true"
 	DeletionPriorityAnnotationKey       = "templatestacks.crossplane.io/deletion-priority"
 	DeletionPriorityAnnotationZeroValue = "0"
+
+	// ReconcileAtAnnotationKey forces a full render and apply of the parent
+	// resource regardless of the render cache when it has a non-empty value,
+	// e.g. a timestamp or "now". It is removed after a successful apply.
+	ReconcileAtAnnotationKey = "templatestacks.crossplane.io/reconcile-at"
+)
+
+// Constants used for labels.
+const (
+	StackNameLabelKey        = "templatestacks.crossplane.io/stack"
+	TemplateRevisionLabelKey = "templatestacks.crossplane.io/template-revision"
+	RenderHashLabelKey       = "templatestacks.crossplane.io/render-hash"
 )
 
 // NopEngine is a no-op templating engine.
 type NopEngine struct{}
 
 // Run does nothing.
-func (n *NopEngine) Run(_ resource.ParentResource) ([]resource.ChildResource, error) {
-	return nil, nil
+func (n *NopEngine) Run(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+	return resource.RenderResult{}, nil
 }
 
 // NewOwnerReferenceAdder returns a new *OwnerReferenceAdder
@@ -69,7 +82,7 @@ func NewOwnerReferenceAdder() OwnerReferenceAdder {
 type OwnerReferenceAdder struct{}
 
 // Patch patches the child resources with information in resource.ParentResource.
-func (lo OwnerReferenceAdder) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+func (lo OwnerReferenceAdder) Patch(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	ref := meta.AsController(meta.ReferenceTo(cr, cr.GroupVersionKind()))
 	trueVal := true
 	ref.BlockOwnerDeletion = &trueVal
@@ -89,7 +102,7 @@ func NewDefaultingAnnotationRemover() DefaultingAnnotationRemover {
 type DefaultingAnnotationRemover struct{}
 
 // Patch patches the child resources with information in resource.ParentResource.
-func (lo DefaultingAnnotationRemover) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+func (lo DefaultingAnnotationRemover) Patch(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	if cr.GetAnnotations()[RemoveDefaultAnnotationsKey] != RemoveDefaultAnnotationsTrueValue {
 		return list, nil
 	}
@@ -111,7 +124,7 @@ func NewNamespacePatcher() NamespacePatcher {
 type NamespacePatcher struct{}
 
 // Patch patches the child resources with information in resource.ParentResource.
-func (lo NamespacePatcher) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+func (lo NamespacePatcher) Patch(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	if cr.GetNamespace() == "" {
 		return list, nil
 	}
@@ -133,7 +146,7 @@ func NewLabelPropagator() LabelPropagator {
 type LabelPropagator struct{}
 
 // Patch patches the child resources with information in resource.ParentResource.
-func (lo LabelPropagator) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+func (lo LabelPropagator) Patch(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	for _, o := range list {
 		meta.AddLabels(o, cr.GetLabels())
 	}
@@ -150,13 +163,53 @@ func NewParentLabelSetAdder() ParentLabelSetAdder {
 type ParentLabelSetAdder struct{}
 
 // Patch patches the child resources with information in resource.ParentResource.
-func (lo ParentLabelSetAdder) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+func (lo ParentLabelSetAdder) Patch(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	for _, o := range list {
 		meta.AddLabels(o, packages.ParentLabels(cr))
 	}
 	return list, nil
 }
 
+// NewRevisionLabeler returns a new RevisionLabeler.
+func NewRevisionLabeler(stack, revision string) RevisionLabeler {
+	return RevisionLabeler{Stack: stack, Revision: revision}
+}
+
+// RevisionLabeler stamps all child resources with labels that record the name
+// of the stack, revision of the template source and the hash of the rendered
+// child resources so that everything produced by a given revision can be
+// selected.
+type RevisionLabeler struct {
+	// Stack is the name of the stack whose templates are rendered.
+	Stack string
+
+	// Revision is the revision or the digest of the template source.
+	Revision string
+}
+
+// Patch patches the child resources with information in resource.ParentResource.
+func (lo RevisionLabeler) Patch(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	// NOTE(muvaf): The hash is calculated before the labels are added so that
+	// it represents only the rendered content.
+	hash, err := resource.HashChildren(list)
+	if err != nil {
+		return nil, errors.Wrap(err, errHashChildren)
+	}
+	l := map[string]string{
+		RenderHashLabelKey: resource.ShortHash(hash),
+	}
+	if lo.Stack != "" {
+		l[StackNameLabelKey] = lo.Stack
+	}
+	if lo.Revision != "" {
+		l[TemplateRevisionLabelKey] = resource.ShortHash(lo.Revision)
+	}
+	for _, o := range list {
+		meta.AddLabels(o, l)
+	}
+	return list, nil
+}
+
 // NewAPIOrderedDeleter returns a new *APIOrderedDeleter.
 func NewAPIOrderedDeleter(c client.Client) *APIOrderedDeleter {
 	return &APIOrderedDeleter{kube: c}
@@ -47,6 +47,7 @@ var (
 	_ ChildResourcePatcher = NamespacePatcher{}
 	_ ChildResourcePatcher = LabelPropagator{}
 	_ ChildResourcePatcher = ParentLabelSetAdder{}
+	_ ChildResourcePatcher = RevisionLabeler{}
 
 	_ ChildResourceDeleter = &APIOrderedDeleter{}
 )
@@ -100,7 +101,7 @@ func TestDefaultingAnnotationRemover(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			p := NewDefaultingAnnotationRemover()
-			got, err := p.Patch(tc.args.cr, tc.args.list)
+			got, err := p.Patch(context.Background(), tc.args.cr, tc.args.list)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Patch(...): -want, +got:\n%s", diff)
 			}
@@ -140,7 +141,7 @@ func TestOwnerReferenceAdder(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			p := NewOwnerReferenceAdder()
-			got, err := p.Patch(tc.args.cr, tc.args.list)
+			got, err := p.Patch(context.Background(), tc.args.cr, tc.args.list)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Patch(...): -want, +got:\n%s", diff)
 			}
@@ -190,7 +191,7 @@ func TestNamespacePatcher(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			p := NewNamespacePatcher()
-			got, err := p.Patch(tc.args.cr, tc.args.list)
+			got, err := p.Patch(context.Background(), tc.args.cr, tc.args.list)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Patch(...): -want, +got:\n%s", diff)
 			}
@@ -229,7 +230,7 @@ func TestLabelPropagator(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			p := NewLabelPropagator()
-			got, err := p.Patch(tc.args.cr, tc.args.list)
+			got, err := p.Patch(context.Background(), tc.args.cr, tc.args.list)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Patch(...): -want, +got:\n%s", diff)
 			}
@@ -265,7 +266,79 @@ func TestParentLabelSetAdder(t *testing.T) {
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
 			p := NewParentLabelSetAdder()
-			got, err := p.Patch(tc.args.cr, tc.args.list)
+			got, err := p.Patch(context.Background(), tc.args.cr, tc.args.list)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Patch(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("Patch(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRevisionLabeler(t *testing.T) {
+	list := []resource.ChildResource{
+		fake.NewMockResource(fake.WithNamespaceName("cool", namespace)),
+		fake.NewMockResource(fake.WithNamespaceName("olala", namespace)),
+	}
+	hash, _ := resource.HashChildren(list)
+	type args struct {
+		stack    string
+		revision string
+		list     []resource.ChildResource
+	}
+	cases := map[string]struct {
+		args
+		want
+	}{
+		"AllLabels": {
+			args: args{
+				stack:    "mystack",
+				revision: "0123456789abcdef0123456789abcdef",
+				list: []resource.ChildResource{
+					fake.NewMockResource(fake.WithNamespaceName("cool", namespace)),
+					fake.NewMockResource(fake.WithNamespaceName("olala", namespace)),
+				},
+			},
+			want: want{
+				result: []resource.ChildResource{
+					fake.NewMockResource(fake.WithNamespaceName("cool", namespace), fake.WithAdditionalLabels(map[string]string{
+						StackNameLabelKey:        "mystack",
+						TemplateRevisionLabelKey: "0123456789abcdef",
+						RenderHashLabelKey:       resource.ShortHash(hash),
+					})),
+					fake.NewMockResource(fake.WithNamespaceName("olala", namespace), fake.WithAdditionalLabels(map[string]string{
+						StackNameLabelKey:        "mystack",
+						TemplateRevisionLabelKey: "0123456789abcdef",
+						RenderHashLabelKey:       resource.ShortHash(hash),
+					})),
+				},
+			},
+		},
+		"OnlyRenderHash": {
+			args: args{
+				list: []resource.ChildResource{
+					fake.NewMockResource(fake.WithNamespaceName("cool", namespace)),
+					fake.NewMockResource(fake.WithNamespaceName("olala", namespace)),
+				},
+			},
+			want: want{
+				result: []resource.ChildResource{
+					fake.NewMockResource(fake.WithNamespaceName("cool", namespace), fake.WithAdditionalLabels(map[string]string{
+						RenderHashLabelKey: resource.ShortHash(hash),
+					})),
+					fake.NewMockResource(fake.WithNamespaceName("olala", namespace), fake.WithAdditionalLabels(map[string]string{
+						RenderHashLabelKey: resource.ShortHash(hash),
+					})),
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := NewRevisionLabeler(tc.args.stack, tc.args.revision)
+			got, err := p.Patch(context.Background(), fake.NewMockResource(), tc.args.list)
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
 				t.Errorf("Patch(...): -want, +got:\n%s", diff)
 			}
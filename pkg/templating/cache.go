@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// RenderCacheAnnotationKey is the annotation on the StackDefinition that
+	// enables the persisted render cache when its value is "true".
+	RenderCacheAnnotationKey = "templatestacks.crossplane.io/render-cache"
+
+	renderCacheConfigMapPrefix = "render-cache-"
+	renderCacheDataKey         = "record.json"
+
+	errGetRenderCache       = "cannot get the render record"
+	errStoreRenderCache     = "cannot store the render record"
+	errDeleteRenderCache    = "cannot delete the render record"
+	errMarshalRenderCache   = "cannot marshal render record"
+	errUnmarshalRenderCache = "cannot unmarshal render record"
+)
+
+// RenderRecord is the record of a successful reconciliation of a parent
+// resource.
+type RenderRecord struct {
+	// InputHash is the hash of the parent resource and the template revision
+	// that were rendered.
+	InputHash string `json:"inputHash"`
+
+	// RenderHash is the hash of the applied child resources.
+	RenderHash string `json:"renderHash"`
+
+	// Inventory lists the applied child resources.
+	Inventory []ChildReference `json:"inventory,omitempty"`
+
+	// AppliedAt is the time the child resources were applied.
+	AppliedAt metav1.Time `json:"appliedAt"`
+}
+
+// ChildReference identifies a child resource.
+type ChildReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+
+	// CreatedAt is the time a child resource with a TTL was first applied.
+	// It's set only in the status.resourceRefs field of the parent resource.
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+
+	// ExpiredAt is the time a child resource with a TTL was deleted since
+	// its TTL passed. It's set only in the status.resourceRefs field of the
+	// parent resource.
+	ExpiredAt *metav1.Time `json:"expiredAt,omitempty"`
+}
+
+// NewInventory returns the references of the given child resources.
+func NewInventory(list []resource.ChildResource) []ChildReference {
+	result := make([]ChildReference, len(list))
+	for i, o := range list {
+		apiVersion, kind := o.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+		result[i] = ChildReference{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  o.GetNamespace(),
+			Name:       o.GetName(),
+		}
+	}
+	return result
+}
+
+// NewConfigMapRenderCache returns a new *ConfigMapRenderCache. The ConfigMaps
+// of cluster-scoped parent resources are stored in the given namespace.
+func NewConfigMapRenderCache(c client.Client, namespace string) *ConfigMapRenderCache {
+	return &ConfigMapRenderCache{
+		client:    c,
+		applier:   apply.NewApplier(c),
+		namespace: namespace,
+	}
+}
+
+// ConfigMapRenderCache keeps the render record in a ConfigMap per parent
+// resource. The ConfigMap is owned by the parent resource if they are in the
+// same namespace so that it is garbage collected with the parent.
+type ConfigMapRenderCache struct {
+	client    client.Client
+	applier   *apply.Applier
+	namespace string
+}
+
+// Get returns the record stored in the ConfigMap of the given parent.
+func (c *ConfigMapRenderCache) Get(ctx context.Context, cr resource.ParentResource) (*RenderRecord, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.client.Get(ctx, c.key(cr), cm); err != nil {
+		return nil, errors.Wrap(client.IgnoreNotFound(err), errGetRenderCache)
+	}
+	data, ok := cm.Data[renderCacheDataKey]
+	if !ok {
+		return nil, nil
+	}
+	rec := &RenderRecord{}
+	return rec, errors.Wrap(json.Unmarshal([]byte(data), rec), errUnmarshalRenderCache)
+}
+
+// Store writes the given record to the ConfigMap of the given parent.
+func (c *ConfigMapRenderCache) Store(ctx context.Context, cr resource.ParentResource, rec RenderRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return errors.Wrap(err, errMarshalRenderCache)
+	}
+	key := c.key(cr)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Data:       map[string]string{renderCacheDataKey: string(data)},
+	}
+	if cr.GetNamespace() == key.Namespace {
+		meta.AddOwnerReference(cm, meta.AsOwner(meta.ReferenceTo(cr, cr.GroupVersionKind())))
+	}
+	_, err = c.applier.Apply(ctx, cm)
+	return errors.Wrap(err, errStoreRenderCache)
+}
+
+// Delete deletes the ConfigMap of the given parent.
+func (c *ConfigMapRenderCache) Delete(ctx context.Context, cr resource.ParentResource) error {
+	key := c.key(cr)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	if err := c.client.Delete(ctx, cm); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errDeleteRenderCache)
+	}
+	return nil
+}
+
+func (c *ConfigMapRenderCache) key(cr resource.ParentResource) types.NamespacedName {
+	ns := cr.GetNamespace()
+	if ns == "" {
+		ns = c.namespace
+	}
+	return types.NamespacedName{Name: renderCacheConfigMapPrefix + string(cr.GetUID()), Namespace: ns}
+}
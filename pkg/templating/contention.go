@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// TypeResourceContention indicates whether a cluster-scoped child resource
+// of the parent resource is controlled by another parent resource.
+const TypeResourceContention v1alpha1.ConditionType = "ResourceContention"
+
+// Reasons a parent resource is or is not in contention.
+const (
+	ReasonContended    v1alpha1.ConditionReason = "A child resource is controlled by another parent resource"
+	ReasonNotContended v1alpha1.ConditionReason = "All child resources are controlled by this parent resource"
+)
+
+// ResourceContention returns a condition that indicates a child resource of
+// the parent resource is controlled by another parent resource.
+func ResourceContention(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeResourceContention,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonContended,
+		Message:            err.Error(),
+	}
+}
+
+// NoResourceContention returns a condition that indicates no child resource
+// of the parent resource is controlled by another parent resource.
+func NoResourceContention() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeResourceContention,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNotContended,
+	}
+}
+
+// A ContentionError is returned when a cluster-scoped child resource is
+// already controlled by another parent resource.
+type ContentionError struct {
+	// Child is the contended child resource.
+	Child ChildReference
+
+	// Controller is the reference of the parent resource that controls the
+	// child resource.
+	Controller metav1.OwnerReference
+}
+
+func (e *ContentionError) Error() string {
+	return fmt.Sprintf("cluster-scoped %s %s is controlled by %s %s with UID %s", e.Child.Kind, e.Child.Name, e.Controller.Kind, e.Controller.Name, e.Controller.UID)
+}
+
+// IsContention returns true if the given error is a ContentionError.
+func IsContention(err error) bool {
+	_, ok := errors.Cause(err).(*ContentionError)
+	return ok
+}
+
+// contention returns a ContentionError if the given desired child resource is
+// cluster-scoped and its current state is controlled by a parent resource
+// other than the given one.
+func contention(cr resource.ParentResource, desired, current resource.ChildResource) error {
+	// NOTE: The desired state of a cluster-scoped child resource may have the
+	// namespace of its parent set by NamespacePatcher, but the API server
+	// never returns a namespace for it.
+	if current.GetNamespace() != "" {
+		return nil
+	}
+	ref := metav1.GetControllerOf(current)
+	if ref == nil || ref.UID == cr.GetUID() {
+		return nil
+	}
+	return &ContentionError{
+		Child:      NewInventory([]resource.ChildResource{desired})[0],
+		Controller: *ref,
+	}
+}
+
+// contended returns true if the given parent resource is marked as in
+// contention.
+func contended(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypeResourceContention)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
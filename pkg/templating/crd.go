@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// AllowCRDDeletionAnnotationKey is the annotation on the parent resource that
+// allows the deletion of its CustomResourceDefinition child resources when
+// its value is "true".
+const AllowCRDDeletionAnnotationKey = "templatestacks.crossplane.io/allow-crd-deletion"
+
+// TypeCRDDeletionBlocked indicates whether the deletion of a
+// CustomResourceDefinition child resource of the parent resource is blocked.
+const TypeCRDDeletionBlocked v1alpha1.ConditionType = "CRDDeletionBlocked"
+
+// Reasons the deletion of a CustomResourceDefinition child resource is
+// blocked.
+const (
+	ReasonCRDDeletionNotAllowed v1alpha1.ConditionReason = "CustomResourceDefinition deletion is not allowed"
+	ReasonCRDHasInstances       v1alpha1.ConditionReason = "CustomResourceDefinition has instances that are not controlled by this parent resource"
+)
+
+// MaxReportedInstances is the maximum number of instances that are named in
+// the message of a CRDDeletionError.
+const MaxReportedInstances = 5
+
+const (
+	errListInstances = "cannot list the instances of the CustomResourceDefinition"
+	errFmtCRDSpec    = "cannot read the group, kind and version of CustomResourceDefinition %s"
+)
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// A CRDDeletionError is returned when a CustomResourceDefinition child
+// resource is not deleted because its deletion is not allowed or it has
+// instances that would be deleted with it.
+type CRDDeletionError struct {
+	// CRD is the name of the CustomResourceDefinition.
+	CRD string
+
+	// Instances are the namespaced names of the instances that are not
+	// controlled by the parent resource. It's empty if the deletion is not
+	// allowed at all.
+	Instances []string
+}
+
+func (e *CRDDeletionError) Error() string {
+	if len(e.Instances) == 0 {
+		return fmt.Sprintf("deletion of CustomResourceDefinition %s is not allowed, set the %s annotation to \"true\" to allow it", e.CRD, AllowCRDDeletionAnnotationKey)
+	}
+	names := e.Instances
+	if len(names) > MaxReportedInstances {
+		names = append(names[:MaxReportedInstances:MaxReportedInstances], "...")
+	}
+	return fmt.Sprintf("CustomResourceDefinition %s has %d instances that are not controlled by this parent resource: %s", e.CRD, len(e.Instances), strings.Join(names, ", "))
+}
+
+// IsCRDDeletionBlocked returns true if the given error is a
+// CRDDeletionError.
+func IsCRDDeletionBlocked(err error) bool {
+	_, ok := errors.Cause(err).(*CRDDeletionError)
+	return ok
+}
+
+// CRDDeletionBlocked returns a condition that indicates the deletion of a
+// CustomResourceDefinition child resource of the parent resource is blocked.
+func CRDDeletionBlocked(err error) v1alpha1.Condition {
+	reason := ReasonCRDDeletionNotAllowed
+	if e, ok := errors.Cause(err).(*CRDDeletionError); ok && len(e.Instances) != 0 {
+		reason = ReasonCRDHasInstances
+	}
+	return v1alpha1.Condition{
+		Type:               TypeCRDDeletionBlocked,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            err.Error(),
+	}
+}
+
+// NewCRDDeletionGuard returns a new *CRDDeletionGuard that deletes the child
+// resources with the given ChildResourceDeleter.
+func NewCRDDeletionGuard(c client.Client, d ChildResourceDeleter) *CRDDeletionGuard {
+	return &CRDDeletionGuard{client: c, deleter: d}
+}
+
+// CRDDeletionGuard is a ChildResourceDeleter that deletes the
+// CustomResourceDefinition child resources only after all other child
+// resources are gone, only if the parent resource allows it with the
+// AllowCRDDeletionAnnotationKey annotation and only if the
+// CustomResourceDefinition has no instances other than the ones controlled
+// by the parent resource. Otherwise, it returns a CRDDeletionError, so the
+// deletion of the parent resource stalls until it's resolved. Deleting a
+// CustomResourceDefinition deletes all of its instances in the cluster.
+type CRDDeletionGuard struct {
+	client  client.Client
+	deleter ChildResourceDeleter
+}
+
+// Delete deletes the given child resources.
+func (g *CRDDeletionGuard) Delete(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	var crds, rest []resource.ChildResource
+	for _, o := range list {
+		if o.GetObjectKind().GroupVersionKind().GroupKind() == crdGroupKind {
+			crds = append(crds, o)
+			continue
+		}
+		rest = append(rest, o)
+	}
+	deleting, err := g.deleter.Delete(ctx, cr, rest)
+	if err != nil || len(deleting) != 0 || len(crds) == 0 {
+		return deleting, err
+	}
+	for _, crd := range crds {
+		if err := g.check(ctx, cr, crd); err != nil {
+			return nil, err
+		}
+	}
+	return g.deleter.Delete(ctx, cr, crds)
+}
+
+// check returns a CRDDeletionError if the given CustomResourceDefinition
+// must not be deleted.
+func (g *CRDDeletionGuard) check(ctx context.Context, cr resource.ParentResource, crd resource.ChildResource) error {
+	if cr.GetAnnotations()[AllowCRDDeletionAnnotationKey] != "true" {
+		return &CRDDeletionError{CRD: crd.GetName()}
+	}
+	gvk, err := servedGVK(crd)
+	if err != nil {
+		return err
+	}
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+	err = g.client.List(ctx, l)
+	// NOTE: The CustomResourceDefinition may not exist or may not be
+	// established, in which case it has no instances.
+	if meta.IsNoMatchError(err) || kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, errListInstances)
+	}
+	var foreign []string
+	for i := range l.Items {
+		item := &l.Items[i]
+		if ref := metav1.GetControllerOf(item); ref != nil && ref.UID == cr.GetUID() {
+			continue
+		}
+		foreign = append(foreign, strings.TrimPrefix(item.GetNamespace()+"/"+item.GetName(), "/"))
+	}
+	if len(foreign) != 0 {
+		return &CRDDeletionError{CRD: crd.GetName(), Instances: foreign}
+	}
+	return nil
+}
+
+// servedGVK returns the GroupVersionKind of the instances of the given
+// CustomResourceDefinition with its first served version.
+func servedGVK(crd resource.ChildResource) (schema.GroupVersionKind, error) {
+	u, ok := crd.(*unstructured.Unstructured)
+	if !ok {
+		return schema.GroupVersionKind{}, errors.Errorf(errFmtCRDSpec, crd.GetName())
+	}
+	group, _, _ := unstructured.NestedString(u.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(u.Object, "spec", "names", "kind")
+	version, _, _ := unstructured.NestedString(u.Object, "spec", "version")
+	versions, _, _ := unstructured.NestedSlice(u.Object, "spec", "versions")
+	for _, v := range versions {
+		m, ok := v.(map[string]interface{})
+		if served, _ := m["served"].(bool); ok && served {
+			version, _ = m["name"].(string)
+			break
+		}
+	}
+	if group == "" || kind == "" || version == "" {
+		return schema.GroupVersionKind{}, errors.Errorf(errFmtCRDSpec, crd.GetName())
+	}
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: kind}, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestCRDDeletionGuard(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]interface{}{"name": "widgets.example.org"},
+		"spec": map[string]interface{}{
+			"group": "example.org",
+			"names": map[string]interface{}{"kind": "Widget"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": false},
+				map[string]interface{}{"name": "v1beta1", "served": true},
+			},
+		},
+	}}
+	cm := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cool", "namespace": "default"},
+	}}
+	parent := func(allow bool) resource.ParentResource {
+		cr := fake.NewMockResource()
+		cr.SetUID(types.UID("parent"))
+		if allow {
+			cr.SetAnnotations(map[string]string{AllowCRDDeletionAnnotationKey: "true"})
+		}
+		return cr
+	}
+	// deleter reports the CustomResourceDefinitions as being deleted and the
+	// ConfigMap as being deleted only if othersDeleting is true.
+	deleter := func(othersDeleting bool) ChildResourceDeleterFunc {
+		return func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+			if len(list) == 1 && list[0] == resource.ChildResource(cm) && !othersDeleting {
+				return nil, nil
+			}
+			return list, nil
+		}
+	}
+	instances := func(owners ...types.UID) test.MockListFn {
+		return func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+			l := obj.(*unstructured.UnstructuredList)
+			if diff := cmp.Diff("example.org/v1beta1, Kind=WidgetList", l.GroupVersionKind().String()); diff != "" {
+				t.Errorf("List(...): -want, +got:\n%s", diff)
+			}
+			trueVal := true
+			for i, uid := range owners {
+				u := unstructured.Unstructured{}
+				u.SetName(string(rune('a' + i)))
+				u.SetOwnerReferences([]metav1.OwnerReference{{UID: uid, Controller: &trueVal}})
+				l.Items = append(l.Items, u)
+			}
+			return nil
+		}
+	}
+	type want struct {
+		deleting []resource.ChildResource
+		err      error
+	}
+	cases := map[string]struct {
+		reason  string
+		cr      resource.ParentResource
+		deleter ChildResourceDeleter
+		list    test.MockListFn
+		want    want
+	}{
+		"OthersFirst": {
+			reason:  "The CustomResourceDefinitions should not be deleted while other child resources are being deleted",
+			cr:      parent(false),
+			deleter: deleter(true),
+			want:    want{deleting: []resource.ChildResource{cm}},
+		},
+		"NotAllowed": {
+			reason:  "The CustomResourceDefinitions should not be deleted without the opt-in annotation",
+			cr:      parent(false),
+			deleter: deleter(false),
+			want:    want{err: &CRDDeletionError{CRD: "widgets.example.org"}},
+		},
+		"ForeignInstances": {
+			reason:  "The CustomResourceDefinitions with instances that are not controlled by the parent should not be deleted",
+			cr:      parent(true),
+			deleter: deleter(false),
+			list:    instances("parent", "other", ""),
+			want:    want{err: &CRDDeletionError{CRD: "widgets.example.org", Instances: []string{"b", "c"}}},
+		},
+		"ListFailed": {
+			reason:  "The error of listing the instances should be returned",
+			cr:      parent(true),
+			deleter: deleter(false),
+			list:    test.NewMockListFn(errBoom),
+			want:    want{err: errors.Wrap(errBoom, errListInstances)},
+		},
+		"Allowed": {
+			reason:  "The CustomResourceDefinitions should be deleted if allowed and they have no foreign instances",
+			cr:      parent(true),
+			deleter: deleter(false),
+			list:    instances("parent"),
+			want:    want{deleting: []resource.ChildResource{crd}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			g := NewCRDDeletionGuard(&test.MockClient{MockList: tc.list}, tc.deleter)
+			got, err := g.Delete(context.Background(), tc.cr, []resource.ChildResource{crd, cm})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nDelete(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.deleting, got); diff != "" {
+				t.Errorf("\n%s\nDelete(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
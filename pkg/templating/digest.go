@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ResourcesDigestAnnotationKey is the annotation on the StackDefinition
+	// whose value pins the expected digest of the resources directory, e.g.
+	// "sha256:<hex>". The child resources are not rendered if the content of
+	// the directory does not match.
+	ResourcesDigestAnnotationKey = "templatestacks.crossplane.io/resources-digest"
+
+	// DigestPrefix is the prefix of the digests of resources directories.
+	DigestPrefix = "sha256:"
+
+	errFmtDigestMismatch = "digest of the resources directory %s%s does not match the expected %s"
+)
+
+// VerifyDigest returns an error if the given digest of the resources
+// directory does not match the expected one. The expected digest may or may
+// not have the DigestPrefix.
+func VerifyDigest(expected, actual string) error {
+	if strings.TrimPrefix(strings.TrimSpace(expected), DigestPrefix) == actual {
+		return nil
+	}
+	return errors.Errorf(errFmtDigestMismatch, DigestPrefix, actual, expected)
+}
+
+// NewDigestMismatchEngine returns an Engine that refuses to render any parent
+// resource with the given digest mismatch error.
+func NewDigestMismatchEngine(err error) Engine {
+	return EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+		return resource.RenderResult{}, &resource.RenderError{Err: err}
+	})
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	cases := map[string]struct {
+		expected string
+		actual   string
+		want     error
+	}{
+		"Prefixed": {
+			expected: "sha256:abc",
+			actual:   "abc",
+		},
+		"NotPrefixed": {
+			expected: "abc",
+			actual:   "abc",
+		},
+		"Mismatch": {
+			expected: "sha256:abc",
+			actual:   "def",
+			want:     errors.Errorf(errFmtDigestMismatch, DigestPrefix, "def", "sha256:abc"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := VerifyDigest(tc.expected, tc.actual)
+			if diff := cmp.Diff(tc.want, got, test.EquateErrors()); diff != "" {
+				t.Errorf("VerifyDigest(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
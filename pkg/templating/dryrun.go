@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// TypeDryRun indicates whether the child resources of the parent resource
+// would be changed if the controller were not running in dry-run mode or the
+// parent resource were not observed. It's set only for observed parent
+// resources.
+const TypeDryRun v1alpha1.ConditionType = "DryRun"
+
+// Reasons the child resources would or would not be changed.
+const (
+	ReasonDryRunChanges v1alpha1.ConditionReason = "Child resources would be changed"
+	ReasonDryRunNoop    v1alpha1.ConditionReason = "Child resources would not be changed"
+)
+
+// EventReasonDryRun is the reason of the event that reports the changes that
+// would be made to the child resources in dry-run mode.
+const EventReasonDryRun event.Reason = "DryRun"
+
+// DryRun returns a condition that summarizes the given changes that would be
+// made to the child resources.
+func DryRun(changes []ChildChange) v1alpha1.Condition {
+	c := v1alpha1.Condition{
+		Type:               TypeDryRun,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDryRunNoop,
+	}
+	if msg, ok := summarize(changes); ok {
+		c.Reason = ReasonDryRunChanges
+		c.Message = msg
+	}
+	return c
+}
+
+// SetDryRunChanges sets the status.dryRunChanges field of the given parent
+// resource.
+func SetDryRunChanges(cr interface{ UnstructuredContent() map[string]interface{} }, changes []ChildChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	list := []interface{}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(cr.UnstructuredContent(), list, "status", "dryRunChanges")
+}
+
+// summarize returns the number of changes per operation and true if any of
+// the given changes would change a child resource or failed.
+func summarize(changes []ChildChange) (string, bool) {
+	count := map[ChangeOperation]int{}
+	for _, c := range changes {
+		count[c.Operation]++
+	}
+	if count[ChangeOperationCreate]+count[ChangeOperationUpdate]+count[ChangeOperationDelete]+count[ChangeOperationFailed] == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%d to create, %d to update, %d to delete, %d failed", count[ChangeOperationCreate], count[ChangeOperationUpdate], count[ChangeOperationDelete], count[ChangeOperationFailed]), true
+}
+
+// dryRun returns the changes that reconciling the given parent resource would
+// make to the given child resources, computed with the given Previewer
+// without making them. The child resources of a deleted parent resource would
+// be deleted.
+func (r *Reconciler) dryRun(ctx context.Context, p *Previewer, cr resource.ParentResource, list []resource.ChildResource) []ChildChange {
+	if meta.WasDeleted(cr) {
+		changes := make([]ChildChange, len(list))
+		for i, o := range list {
+			changes[i] = ChildChange{ChildReference: NewInventory([]resource.ChildResource{o})[0], Operation: ChangeOperationDelete}
+			if r.suspended(cr, o) {
+				changes[i].Operation = ChangeOperationSuspended
+			}
+		}
+		return changes
+	}
+	return r.changes(ctx, p, cr, list)
+}
+
+// dryRunEvent returns the event that reports the given changes, or false if
+// they would not change anything.
+func dryRunEvent(changes []ChildChange) (event.Event, bool) {
+	msg, ok := summarize(changes)
+	if !ok {
+		return event.Event{}, false
+	}
+	return event.Normal(EventReasonDryRun, msg), true
+}
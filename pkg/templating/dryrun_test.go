@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestDryRun(t *testing.T) {
+	type want struct {
+		reason  v1alpha1.ConditionReason
+		message string
+	}
+	cases := map[string]struct {
+		reason  string
+		changes []ChildChange
+		want    want
+	}{
+		"NoChanges": {
+			reason:  "Unchanged and suspended child resources should not be reported as changes.",
+			changes: []ChildChange{{Operation: ChangeOperationNone}, {Operation: ChangeOperationSuspended}},
+			want:    want{reason: ReasonDryRunNoop},
+		},
+		"Changes": {
+			reason: "The changes should be counted per operation.",
+			changes: []ChildChange{
+				{Operation: ChangeOperationCreate},
+				{Operation: ChangeOperationCreate},
+				{Operation: ChangeOperationUpdate},
+				{Operation: ChangeOperationNone},
+				{Operation: ChangeOperationFailed},
+			},
+			want: want{reason: ReasonDryRunChanges, message: "2 to create, 1 to update, 0 to delete, 1 failed"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := DryRun(tc.changes)
+			if diff := cmp.Diff(tc.want.reason, got.Reason); diff != "" {
+				t.Errorf("\n%s\nDryRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.message, got.Message); diff != "" {
+				t.Errorf("\n%s\nDryRun(...): -want message, +got message:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReconcilerDryRunDeleted(t *testing.T) {
+	cr := fake.NewMockResource()
+	now := metav1.Now()
+	cr.SetDeletionTimestamp(&now)
+	child := func(apiVersion, kind string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetName("cool")
+		return u
+	}
+	r := &Reconciler{suspendedKinds: []schema.GroupKind{{Group: "apps", Kind: "Deployment"}}}
+	got := r.dryRun(context.Background(), nil, cr, []resource.ChildResource{child("v1", "ConfigMap"), child("apps/v1", "Deployment")})
+	want := []ChildChange{
+		{ChildReference: ChildReference{APIVersion: "v1", Kind: "ConfigMap", Name: "cool"}, Operation: ChangeOperationDelete},
+		{ChildReference: ChildReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "cool"}, Operation: ChangeOperationSuspended},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("dryRun(...): -want, +got:\n%s", diff)
+	}
+}
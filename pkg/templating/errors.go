@@ -0,0 +1,94 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// Reasons of the Synced condition when the child resources cannot be
+// rendered.
+const (
+	ReasonRenderError v1alpha1.ConditionReason = "Encountered an error while rendering the templates"
+	ReasonValuesError v1alpha1.ConditionReason = "Encountered an invalid value in the parent resource"
+	ReasonPatchError  v1alpha1.ConditionReason = "Encountered an error while patching a child resource"
+	ReasonLintError   v1alpha1.ConditionReason = "Encountered child resources that violate lint rules"
+	ReasonSchemaError v1alpha1.ConditionReason = "Encountered child resources that do not conform to their schemas"
+
+	ReasonOutputLimitExceeded v1alpha1.ConditionReason = "Rendered child resources that exceed the output limits"
+)
+
+// Reasons of the events that are emitted when the child resources cannot be
+// rendered.
+const (
+	EventReasonCannotRender event.Reason = "CannotRender"
+	EventReasonRenderError  event.Reason = "RenderError"
+	EventReasonValuesError  event.Reason = "ValuesError"
+	EventReasonPatchError   event.Reason = "PatchError"
+	EventReasonLintError    event.Reason = "LintError"
+	EventReasonSchemaError  event.Reason = "SchemaError"
+
+	EventReasonOutputLimitExceeded event.Reason = "OutputLimitExceeded"
+)
+
+// RenderFailed returns a Synced condition whose reason tells whether the
+// given error is a resource.RenderError, resource.ValuesError,
+// resource.PatchError, LintError, SchemaError or OutputLimitError. Other errors result in a generic reconcile error.
+func RenderFailed(err error) v1alpha1.Condition {
+	c := v1alpha1.ReconcileError(err)
+	if reason, _ := classify(err); reason != "" {
+		c.Reason = reason
+	}
+	return c
+}
+
+// renderFailedEvent returns the warning event for the given rendering error.
+func renderFailedEvent(err error) event.Event {
+	_, reason := classify(err)
+	return event.Warning(reason, err)
+}
+
+func classify(err error) (v1alpha1.ConditionReason, event.Reason) {
+	var (
+		re *resource.RenderError
+		ve *resource.ValuesError
+		pe *resource.PatchError
+		le *LintError
+		se *SchemaError
+		oe *OutputLimitError
+	)
+	switch {
+	case errors.As(err, &ve):
+		return ReasonValuesError, EventReasonValuesError
+	case errors.As(err, &re):
+		return ReasonRenderError, EventReasonRenderError
+	case errors.As(err, &pe):
+		return ReasonPatchError, EventReasonPatchError
+	case errors.As(err, &le):
+		return ReasonLintError, EventReasonLintError
+	case errors.As(err, &se):
+		return ReasonSchemaError, EventReasonSchemaError
+	case errors.As(err, &oe):
+		return ReasonOutputLimitExceeded, EventReasonOutputLimitExceeded
+	}
+	return "", EventReasonCannotRender
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestRenderFailed(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want v1alpha1.ConditionReason
+	}{
+		"RenderError": {
+			err:  errors.Wrap(&resource.RenderError{File: "templates/a.yaml", Line: 3, Err: errBoom}, errTemplatingOperation),
+			want: ReasonRenderError,
+		},
+		"ValuesError": {
+			err:  errors.Wrap(&resource.ValuesError{Path: "spec.replicas", Err: errBoom}, errTemplatingOperation),
+			want: ReasonValuesError,
+		},
+		"PatchError": {
+			err:  errors.Wrap(&resource.PatchError{Err: errBoom}, errChildResourcePatchers),
+			want: ReasonPatchError,
+		},
+		"LintError": {
+			err:  &LintError{Violations: []LintViolation{{Child: ChildReference{Kind: "Deployment", Name: "cool"}, Rule: LintRuleNoLatestTag, Message: "boom"}}},
+			want: ReasonLintError,
+		},
+		"SchemaError": {
+			err:  &SchemaError{Violations: []SchemaViolation{{Child: ChildReference{Kind: "Database", Name: "cool"}, Error: field.Required(field.NewPath("spec", "engine"), "")}}},
+			want: ReasonSchemaError,
+		},
+		"OutputLimitError": {
+			err:  errors.Wrap(&OutputLimitError{Err: errBoom}, errTemplatingOperation),
+			want: ReasonOutputLimitExceeded,
+		},
+		"Other": {
+			err:  errors.Wrap(errBoom, errTemplatingOperation),
+			want: v1alpha1.ReasonReconcileError,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := RenderFailed(tc.err)
+			if diff := cmp.Diff(tc.want, got.Reason); diff != "" {
+				t.Errorf("RenderFailed(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.err.Error(), got.Message); diff != "" {
+				t.Errorf("RenderFailed(...): -want message, +got message:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/rbac"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// EscalationCheckAnnotationKey is the annotation on the StackDefinition
+	// that enables the check of the permissions that the Roles, ClusterRoles
+	// and their bindings among the child resources grant when its value is
+	// "true".
+	EscalationCheckAnnotationKey = "templatestacks.crossplane.io/escalation-check"
+
+	errCheckEscalations = "cannot check the permissions that the child resources grant"
+	errFmtEscalations   = "child resources grant permissions that the controller does not hold: %s"
+)
+
+// TypePrivilegeEscalation indicates whether some child resources of the
+// parent resource grant permissions that the controller does not hold.
+const TypePrivilegeEscalation v1alpha1.ConditionType = "PrivilegeEscalation"
+
+// Reasons the child resources do or do not escalate privileges.
+const (
+	ReasonPrivilegeEscalation   v1alpha1.ConditionReason = "Child resources grant permissions the controller does not hold"
+	ReasonNoPrivilegeEscalation v1alpha1.ConditionReason = "Child resources grant only permissions the controller holds"
+)
+
+// PrivilegeEscalation returns a condition that indicates some child resources
+// of the parent resource grant permissions that the controller does not hold.
+func PrivilegeEscalation(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypePrivilegeEscalation,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonPrivilegeEscalation,
+		Message:            err.Error(),
+	}
+}
+
+// NoPrivilegeEscalation returns a condition that indicates the child
+// resources of the parent resource grant only permissions that the controller
+// holds.
+func NoPrivilegeEscalation() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypePrivilegeEscalation,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoPrivilegeEscalation,
+	}
+}
+
+// An EscalationChecker returns the given child resources that grant
+// permissions that the controller does not hold.
+type EscalationChecker interface {
+	Escalations(ctx context.Context, list []resource.ChildResource) ([]rbac.Escalation, error)
+}
+
+// A PrivilegeEscalationError is returned when some child resources grant
+// permissions that the controller does not hold.
+type PrivilegeEscalationError struct {
+	Escalations []rbac.Escalation
+}
+
+func (e *PrivilegeEscalationError) Error() string {
+	msgs := make([]string, len(e.Escalations))
+	for i, esc := range e.Escalations {
+		msgs[i] = esc.String()
+	}
+	return fmt.Sprintf(errFmtEscalations, strings.Join(msgs, "; "))
+}
+
+// IsPrivilegeEscalation returns true if the given error is a
+// PrivilegeEscalationError.
+func IsPrivilegeEscalation(err error) bool {
+	_, ok := errors.Cause(err).(*PrivilegeEscalationError)
+	return ok
+}
+
+// checkEscalations returns a PrivilegeEscalationError if some of the given
+// child resources grant permissions that the controller does not hold.
+func (r *Reconciler) checkEscalations(ctx context.Context, list []resource.ChildResource) error {
+	if r.escalations == nil {
+		return nil
+	}
+	esc, err := r.escalations.Escalations(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, errCheckEscalations)
+	}
+	if len(esc) == 0 {
+		return nil
+	}
+	return &PrivilegeEscalationError{Escalations: esc}
+}
+
+// escalated returns true if the given parent resource is marked as having
+// child resources that escalate privileges.
+func escalated(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypePrivilegeEscalation)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
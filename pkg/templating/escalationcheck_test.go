@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/rbac"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+type escalationCheckerFn func(ctx context.Context, list []resource.ChildResource) ([]rbac.Escalation, error)
+
+func (fn escalationCheckerFn) Escalations(ctx context.Context, list []resource.ChildResource) ([]rbac.Escalation, error) {
+	return fn(ctx, list)
+}
+
+func TestCheckEscalations(t *testing.T) {
+	escalations := []rbac.Escalation{{Kind: "ClusterRoleBinding", Name: "admin", Reason: "grants * *.* cluster-wide"}}
+	cases := map[string]struct {
+		reason string
+		ec     EscalationChecker
+		want   error
+	}{
+		"Disabled": {
+			reason: "No child resources should be checked if there is no EscalationChecker.",
+		},
+		"NoEscalations": {
+			reason: "No error should be returned if the child resources do not escalate privileges.",
+			ec: escalationCheckerFn(func(context.Context, []resource.ChildResource) ([]rbac.Escalation, error) {
+				return nil, nil
+			}),
+		},
+		"Escalations": {
+			reason: "The child resources that escalate privileges should be reported.",
+			ec: escalationCheckerFn(func(context.Context, []resource.ChildResource) ([]rbac.Escalation, error) {
+				return escalations, nil
+			}),
+			want: &PrivilegeEscalationError{Escalations: escalations},
+		},
+		"CheckFailed": {
+			reason: "The error of the EscalationChecker should be wrapped.",
+			ec: escalationCheckerFn(func(context.Context, []resource.ChildResource) ([]rbac.Escalation, error) {
+				return nil, errBoom
+			}),
+			want: errors.Wrap(errBoom, errCheckEscalations),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{escalations: tc.ec}
+			err := r.checkEscalations(context.Background(), nil)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ncheckEscalations(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestPrivilegeEscalationError(t *testing.T) {
+	err := &PrivilegeEscalationError{Escalations: []rbac.Escalation{
+		{Kind: "Role", Namespace: "default", Name: "reader", Reason: "grants list secrets in namespace default"},
+		{Kind: "ClusterRoleBinding", Name: "admin", Reason: "grants * *.* cluster-wide"},
+	}}
+	want := "child resources grant permissions that the controller does not hold: Role default/reader grants list secrets in namespace default; ClusterRoleBinding admin grants * *.* cluster-wide"
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Errorf("Error(): -want, +got:\n%s", diff)
+	}
+}
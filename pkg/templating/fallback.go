@@ -0,0 +1,154 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// TypeUnsupportedEngine indicates whether the engine type of the
+// StackDefinition is supported by the controller.
+const TypeUnsupportedEngine v1alpha1.ConditionType = "UnsupportedEngine"
+
+// Reasons the engine type of the StackDefinition is or is not supported.
+const (
+	ReasonUnsupportedEngine v1alpha1.ConditionReason = "Engine type of the StackDefinition is not supported"
+	ReasonSupportedEngine   v1alpha1.ConditionReason = "Engine type of the StackDefinition is supported"
+)
+
+// An UnsupportedEngineError is returned when the engine type of the
+// StackDefinition is not supported by the controller.
+type UnsupportedEngineError struct {
+	// Type is the engine type of the StackDefinition.
+	Type string
+}
+
+// Error returns the message of the error.
+func (e *UnsupportedEngineError) Error() string {
+	return fmt.Sprintf("the engine type %s is not supported", e.Type)
+}
+
+// IsUnsupportedEngine returns true if the cause of the given error is an
+// UnsupportedEngineError.
+func IsUnsupportedEngine(err error) bool {
+	_, ok := errors.Cause(err).(*UnsupportedEngineError)
+	return ok
+}
+
+// UnsupportedEngine returns a condition that indicates the engine type of the
+// StackDefinition is not supported, so the parent resource is not rendered.
+func UnsupportedEngine(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeUnsupportedEngine,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnsupportedEngine,
+		Message:            err.Error(),
+	}
+}
+
+// SupportedEngine returns a condition that indicates the engine type of the
+// StackDefinition is supported.
+func SupportedEngine() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeUnsupportedEngine,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonSupportedEngine,
+	}
+}
+
+// unsupportedEngine returns true if the given parent resource has the
+// UnsupportedEngine condition set to true.
+func unsupportedEngine(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypeUnsupportedEngine)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
+
+// NewFallbackEngine returns a new *FallbackEngine that fails with the given
+// error, typically an UnsupportedEngineError, until it recovers.
+func NewFallbackEngine(err error) *FallbackEngine {
+	return &FallbackEngine{err: err}
+}
+
+// FallbackEngine stands in for the Engine of a StackDefinition that cannot be
+// built, so that the controller keeps running and reports the problem on the
+// parent resources instead of crash-looping. It fails to render every parent
+// resource until it recovers with the Engine of the corrected
+// StackDefinition, which renders them from then on.
+type FallbackEngine struct {
+	mu     sync.RWMutex
+	err    error
+	engine Engine
+}
+
+// Run runs the recovered Engine, or returns the error of the FallbackEngine
+// if it has not recovered yet.
+func (e *FallbackEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	e.mu.RLock()
+	eng := e.engine
+	e.mu.RUnlock()
+	if eng == nil {
+		return resource.RenderResult{}, e.err
+	}
+	return eng.Run(ctx, cr)
+}
+
+// Recover makes the FallbackEngine run the given Engine.
+func (e *FallbackEngine) Recover(eng Engine) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.engine = eng
+}
+
+// Recovered returns true if the FallbackEngine has recovered.
+func (e *FallbackEngine) Recovered() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.engine != nil
+}
+
+// Ready is a readiness check that fails until the FallbackEngine recovers.
+func (e *FallbackEngine) Ready(_ *http.Request) error {
+	if e.Recovered() {
+		return nil
+	}
+	return e.err
+}
+
+// ValuesSources returns the sources of the recovered Engine, or none if it
+// has not recovered yet.
+func (e *FallbackEngine) ValuesSources(cr resource.ParentResource) []string {
+	e.mu.RLock()
+	eng := e.engine
+	e.mu.RUnlock()
+	if eng == nil {
+		return nil
+	}
+	return valuesSourcesOf(eng, cr)
+}
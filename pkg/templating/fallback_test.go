@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestFallbackEngine(t *testing.T) {
+	unsupported := &UnsupportedEngineError{Type: "jsonnet"}
+	e := NewFallbackEngine(unsupported)
+	cr := fake.NewMockResource()
+
+	_, err := e.Run(context.Background(), cr)
+	if diff := cmp.Diff(unsupported, err, test.EquateErrors()); diff != "" {
+		t.Errorf("Run(...): -want error, +got error:\n%s", diff)
+	}
+	if !IsUnsupportedEngine(errors.Wrap(err, errTemplatingOperation)) {
+		t.Errorf("IsUnsupportedEngine(...): the wrapped error should be reported as an unsupported engine")
+	}
+	if diff := cmp.Diff(unsupported, e.Ready(nil), test.EquateErrors()); diff != "" {
+		t.Errorf("Ready(...): the FallbackEngine should not be ready before it recovers: -want error, +got error:\n%s", diff)
+	}
+
+	child := fake.NewMockResource(fake.WithGVK(fake.MockChildGVK))
+	e.Recover(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+		return resource.RenderResult{Children: []resource.ChildResource{child}}, nil
+	}))
+	got, err := e.Run(context.Background(), cr)
+	if err != nil {
+		t.Errorf("Run(...): %s", err)
+	}
+	if diff := cmp.Diff([]resource.ChildResource{child}, got.Children); diff != "" {
+		t.Errorf("Run(...): the recovered engine should be run: -want, +got:\n%s", diff)
+	}
+	if err := e.Ready(nil); err != nil {
+		t.Errorf("Ready(...): the FallbackEngine should be ready once it recovers: %s", err)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// HealthSummaryKey is the key of the ConfigMap data that the HealthSummary
+// is written to.
+const HealthSummaryKey = "summary.yaml"
+
+const (
+	errMarshalHealthSummary = "cannot marshal the health summary"
+	errGetHealthConfigMap   = "cannot get the health summary ConfigMap"
+	errWriteHealthConfigMap = "cannot write the health summary ConfigMap"
+)
+
+// NewHealthTracker returns a new *HealthTracker for the parent resources of
+// the given kind that reports the ones whose last given number of
+// reconciliations in a row failed as failing.
+func NewHealthTracker(gk schema.GroupKind, threshold int) *HealthTracker {
+	labels := prometheus.Labels{"parent": strings.ToLower(gk.String())}
+	return &HealthTracker{
+		threshold: threshold,
+		parents:   map[types.NamespacedName]health{},
+		instances: prometheus.NewDesc("templating_controller_instances",
+			"Number of the parent resources that the controller reconciled.", nil, labels),
+		failing: prometheus.NewDesc("templating_controller_failing_instances",
+			"Number of the parent resources whose reconciliations failed at least the threshold number of times in a row.", nil, labels),
+	}
+}
+
+// A HealthTracker tracks the consecutive failed reconciliations of every
+// parent resource, so that the share of the parent resources that are
+// failing, e.g. after a new revision of the templates is rolled out, can be
+// alerted on without watching every one of them. It's a Prometheus collector
+// of the number of parent resources and the number of failing ones.
+type HealthTracker struct {
+	threshold int
+	instances *prometheus.Desc
+	failing   *prometheus.Desc
+
+	mu      sync.Mutex
+	parents map[types.NamespacedName]health
+}
+
+type health struct {
+	failures int
+	message  string
+}
+
+// A HealthSummary is the health of all parent resources that a HealthTracker
+// tracks.
+type HealthSummary struct {
+	// Instances is the number of the parent resources.
+	Instances int `json:"instances"`
+
+	// Failing is the number of the failing parent resources.
+	Failing int `json:"failing"`
+
+	// FailingInstances are the failing parent resources, sorted by their
+	// namespace and name.
+	FailingInstances []FailingInstance `json:"failingInstances,omitempty"`
+}
+
+// A FailingInstance is a parent resource whose reconciliations failed at
+// least the threshold number of times in a row.
+type FailingInstance struct {
+	Namespace           string `json:"namespace,omitempty"`
+	Name                string `json:"name"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+
+	// Message is the message of the Synced condition of the parent resource
+	// after its last reconciliation.
+	Message string `json:"message,omitempty"`
+}
+
+// Observe records the outcome of a reconciliation of the given parent
+// resource.
+func (h *HealthTracker) Observe(cr resource.ParentResource, succeeded bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := keyOf(cr)
+	if succeeded {
+		h.parents[key] = health{}
+		return
+	}
+	p := h.parents[key]
+	p.failures++
+	p.message = ""
+	if c, err := resource.GetCondition(cr, v1alpha1.TypeSynced); err == nil {
+		p.message = c.Message
+	}
+	h.parents[key] = p
+}
+
+// Forget stops tracking the given parent resource, e.g. once it's deleted.
+func (h *HealthTracker) Forget(cr resource.ParentResource) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.parents, keyOf(cr))
+}
+
+// Summary returns the health of all tracked parent resources.
+func (h *HealthTracker) Summary() HealthSummary {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HealthSummary{Instances: len(h.parents)}
+	for key, p := range h.parents {
+		if p.failures < h.threshold || p.failures == 0 {
+			continue
+		}
+		s.FailingInstances = append(s.FailingInstances, FailingInstance{
+			Namespace:           key.Namespace,
+			Name:                key.Name,
+			ConsecutiveFailures: p.failures,
+			Message:             p.message,
+		})
+	}
+	sort.Slice(s.FailingInstances, func(i, j int) bool {
+		a, b := s.FailingInstances[i], s.FailingInstances[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	s.Failing = len(s.FailingInstances)
+	return s
+}
+
+// Describe sends the descriptors of the metrics of the HealthTracker.
+func (h *HealthTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- h.instances
+	ch <- h.failing
+}
+
+// Collect sends the metrics of the HealthTracker.
+func (h *HealthTracker) Collect(ch chan<- prometheus.Metric) {
+	s := h.Summary()
+	ch <- prometheus.MustNewConstMetric(h.instances, prometheus.GaugeValue, float64(s.Instances))
+	ch <- prometheus.MustNewConstMetric(h.failing, prometheus.GaugeValue, float64(s.Failing))
+}
+
+// WriteHealthSummary writes the given summary to the HealthSummaryKey key of
+// the ConfigMap with the given namespace and name, which is created if it
+// doesn't exist.
+func WriteHealthSummary(ctx context.Context, c client.Client, key types.NamespacedName, s HealthSummary) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, errMarshalHealthSummary)
+	}
+	cm := &corev1.ConfigMap{}
+	err = c.Get(ctx, key, cm)
+	if kerrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string]string{HealthSummaryKey: string(data)},
+		}
+		return errors.Wrap(c.Create(ctx, cm), errWriteHealthConfigMap)
+	}
+	if err != nil {
+		return errors.Wrap(err, errGetHealthConfigMap)
+	}
+	if cm.Data[HealthSummaryKey] == string(data) {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[HealthSummaryKey] = string(data)
+	return errors.Wrap(c.Update(ctx, cm), errWriteHealthConfigMap)
+}
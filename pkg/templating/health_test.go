@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestHealthTracker(t *testing.T) {
+	h := NewHealthTracker(schema.GroupKind{Group: "example.org", Kind: "App"}, 2)
+
+	failing, healthy := fake.NewMockResource(), fake.NewMockResource()
+	failing.SetNamespace("default")
+	failing.SetName("failing")
+	healthy.SetNamespace("default")
+	healthy.SetName("healthy")
+	_ = resource.SetConditions(failing, v1alpha1.ReconcileError(errors.New("boom")))
+
+	// A parent resource is not failing until it fails the threshold number
+	// of times in a row.
+	h.Observe(failing, false)
+	h.Observe(healthy, true)
+	if diff := cmp.Diff(HealthSummary{Instances: 2}, h.Summary()); diff != "" {
+		t.Errorf("Summary(...): -want, +got:\n%s", diff)
+	}
+
+	h.Observe(failing, false)
+	want := HealthSummary{
+		Instances: 2,
+		Failing:   1,
+		FailingInstances: []FailingInstance{
+			{Namespace: "default", Name: "failing", ConsecutiveFailures: 2, Message: "boom"},
+		},
+	}
+	if diff := cmp.Diff(want, h.Summary()); diff != "" {
+		t.Errorf("Summary(...): -want, +got:\n%s", diff)
+	}
+
+	// A success resets the count of consecutive failures.
+	h.Observe(failing, true)
+	h.Observe(failing, false)
+	if diff := cmp.Diff(HealthSummary{Instances: 2}, h.Summary()); diff != "" {
+		t.Errorf("Summary(...): a success should reset the failures: -want, +got:\n%s", diff)
+	}
+
+	// A forgotten parent resource is not counted.
+	h.Forget(healthy)
+	if diff := cmp.Diff(HealthSummary{Instances: 1}, h.Summary()); diff != "" {
+		t.Errorf("Summary(...): a forgotten parent resource should not be counted: -want, +got:\n%s", diff)
+	}
+}
+
+func TestWriteHealthSummary(t *testing.T) {
+	errBoom := errors.New("boom")
+	key := types.NamespacedName{Namespace: "system", Name: "health"}
+	summary := HealthSummary{Instances: 1}
+	data := "failing: 0\ninstances: 1\n"
+
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		kube   client.Client
+		want   want
+	}{
+		"Created": {
+			reason: "The ConfigMap should be created if it doesn't exist.",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, key.Name)),
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					if diff := cmp.Diff(data, obj.(*corev1.ConfigMap).Data[HealthSummaryKey]); diff != "" {
+						t.Errorf("Create(...): -want, +got:\n%s", diff)
+					}
+					return nil
+				},
+			},
+		},
+		"Updated": {
+			reason: "The summary in the existing ConfigMap should be replaced.",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+					obj.(*corev1.ConfigMap).Data = map[string]string{HealthSummaryKey: "instances: 0\n"}
+					return nil
+				}),
+				MockUpdate: func(_ context.Context, obj runtime.Object, _ ...client.UpdateOption) error {
+					if diff := cmp.Diff(data, obj.(*corev1.ConfigMap).Data[HealthSummaryKey]); diff != "" {
+						t.Errorf("Update(...): -want, +got:\n%s", diff)
+					}
+					return nil
+				},
+			},
+		},
+		"Unchanged": {
+			reason: "The ConfigMap should not be updated if the summary didn't change.",
+			kube: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+					obj.(*corev1.ConfigMap).Data = map[string]string{HealthSummaryKey: data}
+					return nil
+				}),
+				MockUpdate: test.NewMockUpdateFn(errBoom),
+			},
+		},
+		"GetError": {
+			reason: "Errors getting the ConfigMap should be returned.",
+			kube:   &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			want:   want{err: errors.Wrap(errBoom, errGetHealthConfigMap)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := WriteHealthSummary(context.Background(), tc.kube, key, summary)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nWriteHealthSummary(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// A Phase of the reconciliation of a parent resource. The phases run in the
+// order they are declared in.
+type Phase string
+
+// The phases of the reconciliation of a parent resource.
+const (
+	// PhaseFetch reads the parent resource. Its pre hooks get a parent
+	// resource with only its name and namespace.
+	PhaseFetch Phase = "Fetch"
+
+	// PhaseRender renders the child resources with the Engine.
+	PhaseRender Phase = "Render"
+
+	// PhasePatch runs the ChildResourcePatchers on the rendered child
+	// resources.
+	PhasePatch Phase = "Patch"
+
+	// PhaseValidate lints the patched child resources, if a Linter is
+	// configured. The errors of the phases up to and including this one are
+	// render errors, so the last known good child resources are applied
+	// instead, if configured.
+	PhaseValidate Phase = "Validate"
+
+	// PhaseApply checks the permissions, the namespaces and the privilege
+	// escalations of the child resources, if configured, and applies them.
+	PhaseApply Phase = "Apply"
+
+	// PhaseStatus writes the status of the parent resource. Its hooks run
+	// at the end of every reconciliation that writes the status, whether it
+	// succeeded or not, so they can observe the outcome in the conditions of
+	// the parent resource.
+	PhaseStatus Phase = "Status"
+)
+
+const (
+	errFmtPreHook  = "pre-%s hook failed"
+	errFmtPostHook = "post-%s hook failed"
+)
+
+// WithPreHook returns a ReconcilerOption that runs the given hooks before the
+// given phase of every reconciliation, in order. The hooks of multiple calls
+// are appended.
+func WithPreHook(p Phase, h ...Hook) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		if reconciler.hooks.pre == nil {
+			reconciler.hooks.pre = map[Phase][]Hook{}
+		}
+		reconciler.hooks.pre[p] = append(reconciler.hooks.pre[p], h...)
+	}
+}
+
+// WithPostHook returns a ReconcilerOption that runs the given hooks after the
+// given phase of every reconciliation succeeds, in order. The hooks of
+// multiple calls are appended.
+func WithPostHook(p Phase, h ...Hook) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		if reconciler.hooks.post == nil {
+			reconciler.hooks.post = map[Phase][]Hook{}
+		}
+		reconciler.hooks.post[p] = append(reconciler.hooks.post[p], h...)
+	}
+}
+
+// hooks are the pre and post hooks of the phases.
+type hooks struct {
+	pre  map[Phase][]Hook
+	post map[Phase][]Hook
+}
+
+// before runs the pre hooks of the given phase and returns the first error.
+func (h hooks) before(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource) error {
+	for _, hook := range h.pre[p] {
+		if err := hook.Run(ctx, p, cr, list); err != nil {
+			return errors.Wrapf(err, errFmtPreHook, p)
+		}
+	}
+	return nil
+}
+
+// after runs the post hooks of the given phase and returns the first error.
+func (h hooks) after(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource) error {
+	for _, hook := range h.post[p] {
+		if err := hook.Run(ctx, p, cr, list); err != nil {
+			return errors.Wrapf(err, errFmtPostHook, p)
+		}
+	}
+	return nil
+}
+
+// around runs the pre hooks of the given phase, the given function, and the
+// post hooks of the phase with the child resources that the function returns
+// if it succeeds.
+func (h hooks) around(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource, fn func() ([]resource.ChildResource, error)) ([]resource.ChildResource, error) {
+	if err := h.before(ctx, p, cr, list); err != nil {
+		return nil, err
+	}
+	startPhase(ctx, p)
+	out, err := fn()
+	stopPhase(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return out, h.after(ctx, p, cr, out)
+}
+
+// writeStatus writes the status of the given parent resource with the given
+// observation, running the hooks of PhaseStatus around it. The timings of the
+// reconciliation are recorded and reported, if configured.
+func (r *Reconciler) writeStatus(ctx context.Context, cr resource.ParentResource, o Observation) error {
+	t := timingsOf(ctx, len(o.Children))
+	if r.timingMetrics != nil {
+		r.timingMetrics.Observe(t)
+	}
+	if r.reportTimings {
+		if err := SetTimings(cr, t); err != nil {
+			return err
+		}
+	}
+	_, err := r.hooks.around(ctx, PhaseStatus, cr, o.Children, func() ([]resource.ChildResource, error) {
+		return o.Children, r.status.WriteStatus(ctx, cr, o)
+	})
+	return err
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestRenderHooks(t *testing.T) {
+	child := fake.NewMockResource()
+	var calls []string
+	record := func(when string, err error) Hook {
+		return HookFunc(func(_ context.Context, p Phase, _ resource.ParentResource, list []resource.ChildResource) error {
+			calls = append(calls, fmt.Sprintf("%s-%s:%d", when, p, len(list)))
+			return err
+		})
+	}
+	engine := EngineFunc(func(context.Context, resource.ParentResource) (resource.RenderResult, error) {
+		calls = append(calls, "engine")
+		return resource.RenderResult{Children: []resource.ChildResource{child}}, nil
+	})
+	type want struct {
+		calls []string
+		err   error
+	}
+	cases := map[string]struct {
+		reason string
+		o      []ReconcilerOption
+		want   want
+	}{
+		"NoHooks": {
+			reason: "The engine should run without any hooks.",
+			want:   want{calls: []string{"engine"}},
+		},
+		"Phases": {
+			reason: "The hooks should run around their phases with the child resources of the phases.",
+			o: []ReconcilerOption{
+				WithPreHook(PhaseRender, record("pre", nil)),
+				WithPostHook(PhaseRender, record("post", nil)),
+				WithPreHook(PhasePatch, record("pre", nil)),
+				WithPostHook(PhaseValidate, record("post", nil), record("post", nil)),
+			},
+			want: want{calls: []string{"pre-Render:0", "engine", "post-Render:1", "pre-Patch:1", "post-Validate:1", "post-Validate:1"}},
+		},
+		"PreHookFailed": {
+			reason: "A failing pre hook should stop the phase.",
+			o:      []ReconcilerOption{WithPreHook(PhaseRender, record("pre", errBoom))},
+			want: want{
+				calls: []string{"pre-Render:0"},
+				err:   errors.Wrapf(errBoom, errFmtPreHook, PhaseRender),
+			},
+		},
+		"PostHookFailed": {
+			reason: "A failing post hook should fail the phase.",
+			o:      []ReconcilerOption{WithPostHook(PhasePatch, record("post", errBoom))},
+			want: want{
+				calls: []string{"engine", "post-Patch:1"},
+				err:   errors.Wrapf(errBoom, errFmtPostHook, PhasePatch),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			calls = nil
+			r := &Reconciler{templating: engine, children: crChildren{ChildResourcePatcherChain: ChildResourcePatcherChain{}}}
+			for _, o := range tc.o {
+				o(r)
+			}
+			_, err := r.render(context.Background(), fake.NewMockResource())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nrender(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.calls, calls); diff != "" {
+				t.Errorf("\n%s\nrender(...): -want calls, +got calls:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWriteStatusHooks(t *testing.T) {
+	var written bool
+	r := &Reconciler{status: StatusWriterFunc(func(context.Context, resource.ParentResource, Observation) error {
+		written = true
+		return nil
+	})}
+	WithPreHook(PhaseStatus, HookFunc(func(context.Context, Phase, resource.ParentResource, []resource.ChildResource) error {
+		return errBoom
+	}))(r)
+	err := r.writeStatus(context.Background(), fake.NewMockResource(), Observation{})
+	if diff := cmp.Diff(errors.Wrapf(errBoom, errFmtPreHook, PhaseStatus), err, test.EquateErrors()); diff != "" {
+		t.Errorf("writeStatus(...): -want error, +got error:\n%s", diff)
+	}
+	if written {
+		t.Errorf("writeStatus(...): the status should not be written if a pre hook fails")
+	}
+}
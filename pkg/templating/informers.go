@@ -0,0 +1,193 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// InformerIdleReconcilesAnnotationKey is the annotation on the StackDefinition
+// that makes the controller read the child resources from informers instead
+// of the API server. Its value is the number of reconciliations after which
+// the informer of a kind that is not rendered by any of them is stopped.
+const InformerIdleReconcilesAnnotationKey = "templatestacks.crossplane.io/informer-idle-reconciles"
+
+const (
+	errParseIdleReconciles = "the number of idle reconciliations must be a positive integer"
+	errNewInformer         = "cannot create the informer"
+	errStartInformer       = "cannot start the informer"
+	errFmtSyncInformer     = "cannot sync the informer of %s"
+)
+
+// ParseIdleReconciles parses the given number of idle reconciliations,
+// typically the value of InformerIdleReconcilesAnnotationKey annotation.
+func ParseIdleReconciles(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return 0, errors.New(errParseIdleReconciles)
+	}
+	return n, nil
+}
+
+// A NewCacheFn returns a new cache that is used for the child resources of
+// the given kind only. It's typically scoped to the namespace and the
+// permissions of the controller.
+type NewCacheFn func(gvk schema.GroupVersionKind) (cache.Cache, error)
+
+// NewInformerClient returns a new *InformerClient that reads the child
+// resources from the caches that are returned by the given function and
+// stops the ones that are not rendered in the given number of
+// reconciliations. The other objects are read and all objects are written
+// with the given client.
+func NewInformerClient(c client.Client, fn NewCacheFn, idle int) *InformerClient {
+	return &InformerClient{Client: c, newCache: fn, idle: int64(idle), informers: map[schema.GroupVersionKind]*informer{}}
+}
+
+// An InformerClient reads the unstructured objects from an informer per kind
+// that is started lazily on the first read of the kind. An informer of a kind
+// that is neither read nor rendered in the last given number of
+// reconciliations is stopped so that the memory use of a long-lived
+// controller does not grow with every kind its templates ever rendered.
+// Reads from an informer may be stale, e.g. a child resource that is just
+// created may not be found, in which case the write fails and is retried in
+// a later reconciliation.
+type InformerClient struct {
+	client.Client
+	newCache NewCacheFn
+	idle     int64
+
+	mu         sync.Mutex
+	reconciles int64
+	informers  map[schema.GroupVersionKind]*informer
+}
+
+type informer struct {
+	cache    cache.Cache
+	stop     chan struct{}
+	lastUsed int64
+}
+
+// Get reads the given object from the informer of its kind if it's
+// unstructured, and from the underlying client otherwise.
+func (c *InformerClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok || u.GroupVersionKind().Empty() {
+		return c.Client.Get(ctx, key, obj)
+	}
+	ca, err := c.cacheFor(ctx, u.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	return ca.Get(ctx, key, obj)
+}
+
+// List lists the given objects from the informer of their kind if they're
+// unstructured, and from the underlying client otherwise.
+func (c *InformerClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	l, ok := list.(*unstructured.UnstructuredList)
+	if !ok || l.GroupVersionKind().Empty() {
+		return c.Client.List(ctx, list, opts...)
+	}
+	gvk := l.GroupVersionKind()
+	ca, err := c.cacheFor(ctx, gvk.GroupVersion().WithKind(strings.TrimSuffix(gvk.Kind, "List")))
+	if err != nil {
+		return err
+	}
+	return ca.List(ctx, list, opts...)
+}
+
+// Observe records a reconciliation that rendered the given child resources
+// and stops the informers that became idle.
+func (c *InformerClient) Observe(list []resource.ChildResource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reconciles++
+	for _, o := range list {
+		if i, ok := c.informers[o.GetObjectKind().GroupVersionKind()]; ok {
+			i.lastUsed = c.reconciles
+		}
+	}
+	for gvk, i := range c.informers {
+		if c.reconciles-i.lastUsed >= c.idle {
+			close(i.stop)
+			delete(c.informers, gvk)
+		}
+	}
+}
+
+// Kinds returns the number of kinds whose informers are running.
+func (c *InformerClient) Kinds() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.informers)
+}
+
+// cacheFor returns the cache of the given kind, starting it if necessary.
+func (c *InformerClient) cacheFor(ctx context.Context, gvk schema.GroupVersionKind) (cache.Cache, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i, ok := c.informers[gvk]; ok {
+		i.lastUsed = c.reconciles
+		return i.cache, nil
+	}
+	ca, err := c.newCache(gvk)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewInformer)
+	}
+	i := &informer{cache: ca, stop: make(chan struct{}), lastUsed: c.reconciles}
+	started := make(chan error, 1)
+	go func() { started <- ca.Start(i.stop) }()
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	// NOTE: The informer does not sync if the controller cannot list and
+	// watch the kind, in which case we give up when the context is done.
+	if !ca.WaitForCacheSync(ctx.Done()) {
+		close(i.stop)
+		return nil, errors.Wrap(startErr(ctx, started), errStartInformer)
+	}
+	if _, err := ca.GetInformer(ctx, u); err != nil {
+		close(i.stop)
+		return nil, errors.Wrapf(err, errFmtSyncInformer, gvk)
+	}
+	c.informers[gvk] = i
+	return ca, nil
+}
+
+// startErr returns the error that the cache failed to start with, or the
+// error of the given context if it did not fail.
+func startErr(ctx context.Context, started <-chan error) error {
+	select {
+	case err := <-started:
+		if err != nil {
+			return err
+		}
+	default:
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+type mockCache struct {
+	cache.Cache
+	stop chan (<-chan struct{})
+}
+
+func (c *mockCache) Start(stop <-chan struct{}) error {
+	c.stop <- stop
+	return nil
+}
+
+func (c *mockCache) WaitForCacheSync(_ <-chan struct{}) bool { return true }
+
+func (c *mockCache) GetInformer(_ context.Context, _ runtime.Object) (cache.Informer, error) {
+	return nil, nil
+}
+
+func (c *mockCache) Get(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	obj.(*unstructured.Unstructured).SetName("cached")
+	return nil
+}
+
+func TestInformerClient(t *testing.T) {
+	of := func(kind string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: kind})
+		return u
+	}
+	caches := map[string]*mockCache{}
+	ic := NewInformerClient(&test.MockClient{MockGet: test.NewMockGetFn(nil)}, func(gvk schema.GroupVersionKind) (cache.Cache, error) {
+		caches[gvk.Kind] = &mockCache{stop: make(chan (<-chan struct{}), 1)}
+		return caches[gvk.Kind], nil
+	}, 2)
+
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		u := of(kind)
+		if err := ic.Get(context.Background(), types.NamespacedName{Name: "cool"}, u); err != nil {
+			t.Fatalf("Get(...): %s", err)
+		}
+		if diff := cmp.Diff("cached", u.GetName()); diff != "" {
+			t.Errorf("Get(...): the %s should be read from its informer:\n%s", kind, diff)
+		}
+	}
+	if diff := cmp.Diff(2, ic.Kinds()); diff != "" {
+		t.Errorf("Kinds(): -want, +got:\n%s", diff)
+	}
+
+	// The ConfigMaps are rendered in both reconciliations but the Secrets
+	// are not, so the informer of the Secrets becomes idle.
+	ic.Observe([]resource.ChildResource{of("ConfigMap")})
+	ic.Observe([]resource.ChildResource{of("ConfigMap")})
+	if diff := cmp.Diff(1, ic.Kinds()); diff != "" {
+		t.Errorf("Observe(...): -want kinds, +got kinds:\n%s", diff)
+	}
+	// NOTE: The caches are started asynchronously, so we wait for their stop
+	// channels.
+	secretStop, configMapStop := <-caches["Secret"].stop, <-caches["ConfigMap"].stop
+	select {
+	case <-secretStop:
+	default:
+		t.Errorf("Observe(...): the informer of an idle kind should be stopped")
+	}
+	select {
+	case <-configMapStop:
+		t.Errorf("Observe(...): the informer of a rendered kind should not be stopped")
+	default:
+	}
+
+	// The objects whose kind is unknown are read with the underlying client.
+	cm := &unstructured.Unstructured{}
+	if err := ic.Get(context.Background(), types.NamespacedName{Name: "cool"}, cm); err != nil {
+		t.Fatalf("Get(...): %s", err)
+	}
+	if diff := cmp.Diff("", cm.GetName()); diff != "" {
+		t.Errorf("Get(...): an object without a kind should be read with the underlying client:\n%s", diff)
+	}
+}
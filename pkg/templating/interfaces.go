@@ -24,44 +24,78 @@ import (
 
 // Engine is used as main generation engine by the templating reconciler.
 // Its input is typically a Custom Resource instance and output is various
-// Kubernetes objects generated by the given implementation of the Engine.
+// Kubernetes objects generated by the given implementation of the Engine,
+// along with the warnings that the reconciler reports on the Custom Resource.
+// The context is cancelled when the reconciliation times out, so the engines
+// that do I/O, e.g. read the resources in the cluster or run executables,
+// should stop then.
 type Engine interface {
-	Run(resource.ParentResource) ([]resource.ChildResource, error)
+	Run(context.Context, resource.ParentResource) (resource.RenderResult, error)
 }
 
 // EngineFunc used for supplying only one function as templating engine.
-type EngineFunc func(resource.ParentResource) ([]resource.ChildResource, error)
+type EngineFunc func(context.Context, resource.ParentResource) (resource.RenderResult, error)
 
 // Run calls the EngineFunc function.
-func (t EngineFunc) Run(cr resource.ParentResource) ([]resource.ChildResource, error) {
-	return t(cr)
+func (t EngineFunc) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	return t(ctx, cr)
+}
+
+// An InputDigester is an Engine that renders with inputs other than the
+// parent resource, e.g. the resources it refers to, and reports their digest
+// so that the render cache is invalidated when they change.
+type InputDigester interface {
+	InputDigest(resource.ParentResource) (string, error)
+}
+
+// A Stage post-processes the child resources rendered by the previous stage
+// of a ChainedEngine. The returned list replaces the given one.
+type Stage interface {
+	Transform(resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
+}
+
+// StageFunc makes it easier to provide only a function as Stage.
+type StageFunc func(resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
+
+// Transform calls the StageFunc function.
+func (fn StageFunc) Transform(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	return fn(cr, list)
 }
 
 // ChildResourcePatcher operates on the resources rendered by the templating
-// engine.
+// engine. The list is in the order the engine produced the resources and the
+// child resources are applied in the order of the final list, so patchers
+// should preserve the order unless reordering is their purpose. Patchers that
+// sort the list should use a stable sort so that the original order is the
+// tiebreaker.
 type ChildResourcePatcher interface {
-	Patch(resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
+	Patch(context.Context, resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
 }
 
 // ChildResourcePatcherFunc makes it easier to provide only a function as
 // ChildResourcePatcher
-type ChildResourcePatcherFunc func(resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
+type ChildResourcePatcherFunc func(context.Context, resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error)
 
 // Patch calls the ChildResourcePatcherFunc function.
-func (pre ChildResourcePatcherFunc) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
-	return pre(cr, list)
+func (pre ChildResourcePatcherFunc) Patch(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	return pre(ctx, cr, list)
 }
 
 // ChildResourcePatcherChain makes it easier to provide a list of ChildResourcePatcher
 // to be called in order.
 type ChildResourcePatcherChain []ChildResourcePatcher
 
-// Patch calls the ChildResourcePatcherChain functions in order.
-func (pre ChildResourcePatcherChain) Patch(cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+// Patch calls the ChildResourcePatcherChain functions in order. It stops
+// with the error of the given context if it's done before all of them are
+// called.
+func (pre ChildResourcePatcherChain) Patch(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	currentList := list
 	var err error
 	for _, f := range pre {
-		currentList, err = f.Patch(cr, currentList)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		currentList, err = f.Patch(ctx, cr, currentList)
 		if err != nil {
 			return nil, err
 		}
@@ -82,3 +116,49 @@ type ChildResourceDeleterFunc func(ctx context.Context, cr resource.ParentResour
 func (pre ChildResourceDeleterFunc) Delete(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 	return pre(ctx, cr, list)
 }
+
+// RenderStore keeps the last child resources that were successfully rendered
+// and patched for a parent resource so that they can be maintained while the
+// rendering is failing.
+type RenderStore interface {
+	// Get returns the stored child resources of the given parent resource.
+	// It returns an empty list if nothing is stored.
+	Get(ctx context.Context, cr resource.ParentResource) ([]resource.ChildResource, error)
+
+	// Store replaces the stored child resources of the given parent resource.
+	Store(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error
+
+	// Delete removes the stored child resources of the given parent resource.
+	Delete(ctx context.Context, cr resource.ParentResource) error
+}
+
+// RenderCache keeps a compact record of the last successful reconciliation of
+// a parent resource so that the unchanged parent resources can skip rendering
+// and applying after the controller restarts.
+type RenderCache interface {
+	// Get returns the record of the given parent resource. It returns nil if
+	// there is no record.
+	Get(ctx context.Context, cr resource.ParentResource) (*RenderRecord, error)
+
+	// Store replaces the record of the given parent resource.
+	Store(ctx context.Context, cr resource.ParentResource, rec RenderRecord) error
+
+	// Delete removes the record of the given parent resource.
+	Delete(ctx context.Context, cr resource.ParentResource) error
+}
+
+// A Hook runs before or after a Phase of the reconciliation of a parent
+// resource. The child resources are nil before they are rendered. A Hook
+// should not modify the parent resource or the child resources, and an error
+// fails the reconciliation as the Phase itself would.
+type Hook interface {
+	Run(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource) error
+}
+
+// HookFunc makes it easier to provide only a function as Hook.
+type HookFunc func(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource) error
+
+// Run calls the HookFunc function.
+func (fn HookFunc) Run(ctx context.Context, p Phase, cr resource.ParentResource, list []resource.ChildResource) error {
+	return fn(ctx, p, cr, list)
+}
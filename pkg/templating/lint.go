@@ -0,0 +1,347 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// LintAnnotationKey is the annotation on the StackDefinition whose value is
+// the YAML list of LintRules that the rendered child resources are checked
+// against.
+const LintAnnotationKey = "templatestacks.crossplane.io/lint"
+
+// Names of the lint rules.
+const (
+	// LintRuleRequiredLabels requires the labels that are listed in the rule
+	// on every child resource.
+	LintRuleRequiredLabels = "RequiredLabels"
+
+	// LintRuleContainerProbes requires a readiness and a liveness probe on
+	// every container of the workloads.
+	LintRuleContainerProbes = "ContainerProbes"
+
+	// LintRuleContainerResources requires resource requests and limits on
+	// every container and init container of the workloads.
+	LintRuleContainerResources = "ContainerResources"
+
+	// LintRuleNoLatestTag forbids the images of the workloads that are
+	// tagged latest or not tagged at all.
+	LintRuleNoLatestTag = "NoLatestTag"
+)
+
+// A LintSeverity tells what a violation of a lint rule results in.
+type LintSeverity string
+
+// Lint severities.
+const (
+	// LintSeverityWarning reports the violations in the LintViolations
+	// condition and applies the child resources anyway. It's the default.
+	LintSeverityWarning LintSeverity = "Warning"
+
+	// LintSeverityError reports the violations and refuses to apply the
+	// child resources as if they could not be rendered.
+	LintSeverityError LintSeverity = "Error"
+)
+
+// TypeLintViolations indicates whether the child resources of the parent
+// resource violate any lint rules.
+const TypeLintViolations v1alpha1.ConditionType = "LintViolations"
+
+// Reasons the child resources of a parent resource do or do not violate lint
+// rules.
+const (
+	ReasonLintViolations   v1alpha1.ConditionReason = "Child resources violate lint rules"
+	ReasonNoLintViolations v1alpha1.ConditionReason = "Child resources pass all lint rules"
+)
+
+// EventReasonLintViolations is the reason of the event that is emitted when
+// the child resources violate lint rules whose severity is warning.
+const EventReasonLintViolations event.Reason = "LintViolations"
+
+const (
+	errParseLintRules      = "cannot parse the lint rules"
+	errFmtUnknownLintRule  = "unknown lint rule %q"
+	errFmtUnknownSeverity  = "unknown severity %q of lint rule %s"
+	errFmtLintViolations   = "child resources violate lint rules: %s"
+	errNoRequiredLabels    = "lint rule RequiredLabels has no labels"
+	errFmtMissingLabel     = "label %s is missing"
+	errFmtMissingProbe     = "container %s has no %s"
+	errFmtMissingResources = "container %s has no resource %s"
+	errFmtLatestTag        = "container %s uses image %s without a fixed tag"
+)
+
+// A LintRule is a check that every rendered child resource has to pass.
+type LintRule struct {
+	// Name of the rule, e.g. RequiredLabels.
+	Name string `json:"name"`
+
+	// Severity of the violations of the rule. Defaults to Warning.
+	Severity LintSeverity `json:"severity,omitempty"`
+
+	// Labels that are required by the RequiredLabels rule.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// ParseLintRules parses the given YAML list of lint rules, typically the
+// value of LintAnnotationKey annotation.
+func ParseLintRules(data string) ([]LintRule, error) {
+	var rules []LintRule
+	if err := yaml.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, errors.Wrap(err, errParseLintRules)
+	}
+	for i, r := range rules {
+		switch r.Name {
+		case LintRuleRequiredLabels:
+			if len(r.Labels) == 0 {
+				return nil, errors.New(errNoRequiredLabels)
+			}
+		case LintRuleContainerProbes, LintRuleContainerResources, LintRuleNoLatestTag:
+		default:
+			return nil, errors.Errorf(errFmtUnknownLintRule, r.Name)
+		}
+		switch r.Severity {
+		case "":
+			rules[i].Severity = LintSeverityWarning
+		case LintSeverityWarning, LintSeverityError:
+		default:
+			return nil, errors.Errorf(errFmtUnknownSeverity, r.Severity, r.Name)
+		}
+	}
+	return rules, nil
+}
+
+// A LintViolation is a child resource that does not pass a lint rule.
+type LintViolation struct {
+	Child    ChildReference
+	Rule     string
+	Severity LintSeverity
+	Message  string
+}
+
+func (v LintViolation) String() string {
+	ns := ""
+	if v.Child.Namespace != "" {
+		ns = v.Child.Namespace + "/"
+	}
+	return fmt.Sprintf("%s %s%s: %s (%s)", v.Child.Kind, ns, v.Child.Name, v.Message, v.Rule)
+}
+
+// A LintError is returned when the child resources violate lint rules whose
+// severity is error.
+type LintError struct {
+	Violations []LintViolation
+}
+
+func (e *LintError) Error() string {
+	return fmt.Sprintf(errFmtLintViolations, joinViolations(e.Violations))
+}
+
+// A Linter checks the rendered child resources.
+type Linter interface {
+	Lint(list []resource.ChildResource) []LintViolation
+}
+
+// NewRuleLinter returns a new *RuleLinter.
+func NewRuleLinter(rules ...LintRule) *RuleLinter {
+	return &RuleLinter{Rules: rules}
+}
+
+// A RuleLinter checks the child resources against LintRules.
+type RuleLinter struct {
+	Rules []LintRule
+}
+
+// Lint returns the violations of the rules by the given child resources in
+// the order of the child resources.
+func (l *RuleLinter) Lint(list []resource.ChildResource) []LintViolation {
+	var result []LintViolation
+	for i, ref := range NewInventory(list) {
+		u, ok := list[i].(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		for _, r := range l.Rules {
+			for _, msg := range check(r, u) {
+				result = append(result, LintViolation{Child: ref, Rule: r.Name, Severity: r.Severity, Message: msg})
+			}
+		}
+	}
+	return result
+}
+
+func check(r LintRule, u *unstructured.Unstructured) []string {
+	var result []string
+	if r.Name == LintRuleRequiredLabels {
+		for _, l := range r.Labels {
+			if _, ok := u.GetLabels()[l]; !ok {
+				result = append(result, fmt.Sprintf(errFmtMissingLabel, l))
+			}
+		}
+		return result
+	}
+	containers, initContainers := containersOf(u)
+	switch r.Name {
+	case LintRuleContainerProbes:
+		for _, c := range containers {
+			for _, p := range []string{"readinessProbe", "livenessProbe"} {
+				if _, ok := c[p]; !ok {
+					result = append(result, fmt.Sprintf(errFmtMissingProbe, c["name"], p))
+				}
+			}
+		}
+	case LintRuleContainerResources:
+		for _, c := range append(containers, initContainers...) {
+			for _, f := range []string{"requests", "limits"} {
+				if v, _, _ := unstructured.NestedMap(c, "resources", f); len(v) == 0 {
+					result = append(result, fmt.Sprintf(errFmtMissingResources, c["name"], f))
+				}
+			}
+		}
+	case LintRuleNoLatestTag:
+		for _, c := range append(containers, initContainers...) {
+			if image, _ := c["image"].(string); !pinned(image) {
+				result = append(result, fmt.Sprintf(errFmtLatestTag, c["name"], image))
+			}
+		}
+	}
+	return result
+}
+
+// podSpecPaths are the paths of the pod spec in the workloads, e.g. in
+// Deployments, StatefulSets, DaemonSets, Jobs and CronJobs.
+var podSpecPaths = [][]string{
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containersOf returns the containers and the init containers of the given
+// workload. It returns nothing if the given object is not a workload.
+func containersOf(u *unstructured.Unstructured) ([]map[string]interface{}, []map[string]interface{}) {
+	paths := podSpecPaths
+	if u.GetKind() == "Pod" {
+		paths = [][]string{{"spec"}}
+	}
+	for _, p := range paths {
+		spec, ok, _ := unstructured.NestedMap(u.Object, p...)
+		if !ok {
+			continue
+		}
+		if _, ok := spec["containers"]; !ok {
+			continue
+		}
+		return maps(spec["containers"]), maps(spec["initContainers"])
+	}
+	return nil, nil
+}
+
+func maps(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, e := range list {
+		if m, ok := e.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// pinned returns true if the given image has a digest or a tag other than
+// latest.
+func pinned(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i >= 0 && name[i+1:] != "latest"
+}
+
+func joinViolations(list []LintViolation) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = v.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// LintViolations returns a condition that indicates the child resources of
+// the parent resource violate lint rules.
+func LintViolations(list []LintViolation) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeLintViolations,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonLintViolations,
+		Message:            joinViolations(list),
+	}
+}
+
+// NoLintViolations returns a condition that indicates the child resources of
+// the parent resource pass all lint rules.
+func NoLintViolations() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeLintViolations,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoLintViolations,
+	}
+}
+
+// lint checks the given child resources with the configured Linter and sets
+// the LintViolations condition of the given parent resource. The violations
+// whose severity is warning are emitted as an event, and a LintError is
+// returned if any violation has the error severity.
+func (r *Reconciler) lint(cr resource.ParentResource, list []resource.ChildResource) error {
+	violations := r.linter.Lint(list)
+	if len(violations) == 0 {
+		if c, err := resource.GetCondition(cr, TypeLintViolations); err == nil && c.Status == corev1.ConditionTrue {
+			return resource.SetConditions(cr, NoLintViolations())
+		}
+		return nil
+	}
+	if err := resource.SetConditions(cr, LintViolations(violations)); err != nil {
+		return err
+	}
+	var warnings, errs []LintViolation
+	for _, v := range violations {
+		if v.Severity == LintSeverityError {
+			errs = append(errs, v)
+			continue
+		}
+		warnings = append(warnings, v)
+	}
+	if len(warnings) != 0 {
+		r.recorder.Event(cr, event.Warning(EventReasonLintViolations, errors.Errorf(errFmtLintViolations, joinViolations(warnings))))
+	}
+	if len(errs) != 0 {
+		return &LintError{Violations: errs}
+	}
+	return nil
+}
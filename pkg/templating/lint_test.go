@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseLintRules(t *testing.T) {
+	type want struct {
+		rules []LintRule
+		err   error
+	}
+	cases := map[string]struct {
+		data string
+		want want
+	}{
+		"Valid": {
+			data: "- name: RequiredLabels\n  labels: [app]\n- name: NoLatestTag\n  severity: Error\n",
+			want: want{rules: []LintRule{
+				{Name: LintRuleRequiredLabels, Severity: LintSeverityWarning, Labels: []string{"app"}},
+				{Name: LintRuleNoLatestTag, Severity: LintSeverityError},
+			}},
+		},
+		"UnknownRule": {
+			data: "- name: NoSecrets\n",
+			want: want{err: errors.Errorf(errFmtUnknownLintRule, "NoSecrets")},
+		},
+		"UnknownSeverity": {
+			data: "- name: ContainerProbes\n  severity: Fatal\n",
+			want: want{err: errors.Errorf(errFmtUnknownSeverity, "Fatal", LintRuleContainerProbes)},
+		},
+		"NoLabels": {
+			data: "- name: RequiredLabels\n",
+			want: want{err: errors.New(errNoRequiredLabels)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseLintRules(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ParseLintRules(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.rules, got); diff != "" {
+				t.Errorf("ParseLintRules(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRuleLinterLint(t *testing.T) {
+	deployment := func(containers ...interface{}) resource.ChildResource {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":   "cool",
+				"labels": map[string]interface{}{"app": "cool"},
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{"containers": containers},
+				},
+			},
+		}}
+	}
+	good := map[string]interface{}{
+		"name":           "good",
+		"image":          "mysql:8.0",
+		"readinessProbe": map[string]interface{}{},
+		"livenessProbe":  map[string]interface{}{},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "100m"},
+			"limits":   map[string]interface{}{"cpu": "1"},
+		},
+	}
+	ref := ChildReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "cool"}
+	cases := map[string]struct {
+		rules []LintRule
+		list  []resource.ChildResource
+		want  []LintViolation
+	}{
+		"NoViolations": {
+			rules: []LintRule{
+				{Name: LintRuleRequiredLabels, Labels: []string{"app"}},
+				{Name: LintRuleContainerProbes},
+				{Name: LintRuleContainerResources},
+				{Name: LintRuleNoLatestTag},
+			},
+			list: []resource.ChildResource{deployment(good)},
+		},
+		"MissingLabel": {
+			rules: []LintRule{{Name: LintRuleRequiredLabels, Severity: LintSeverityError, Labels: []string{"app", "team"}}},
+			list:  []resource.ChildResource{deployment(good)},
+			want: []LintViolation{
+				{Child: ref, Rule: LintRuleRequiredLabels, Severity: LintSeverityError, Message: "label team is missing"},
+			},
+		},
+		"MissingProbesAndResources": {
+			rules: []LintRule{{Name: LintRuleContainerProbes, Severity: LintSeverityWarning}, {Name: LintRuleContainerResources, Severity: LintSeverityWarning}},
+			list:  []resource.ChildResource{deployment(map[string]interface{}{"name": "bad", "image": "mysql:8.0"})},
+			want: []LintViolation{
+				{Child: ref, Rule: LintRuleContainerProbes, Severity: LintSeverityWarning, Message: "container bad has no readinessProbe"},
+				{Child: ref, Rule: LintRuleContainerProbes, Severity: LintSeverityWarning, Message: "container bad has no livenessProbe"},
+				{Child: ref, Rule: LintRuleContainerResources, Severity: LintSeverityWarning, Message: "container bad has no resource requests"},
+				{Child: ref, Rule: LintRuleContainerResources, Severity: LintSeverityWarning, Message: "container bad has no resource limits"},
+			},
+		},
+		"LatestTag": {
+			rules: []LintRule{{Name: LintRuleNoLatestTag, Severity: LintSeverityWarning}},
+			list: []resource.ChildResource{deployment(
+				map[string]interface{}{"name": "latest", "image": "mysql:latest"},
+				map[string]interface{}{"name": "untagged", "image": "registry:5000/mysql"},
+				map[string]interface{}{"name": "digest", "image": "mysql@sha256:0123"},
+			)},
+			want: []LintViolation{
+				{Child: ref, Rule: LintRuleNoLatestTag, Severity: LintSeverityWarning, Message: "container latest uses image mysql:latest without a fixed tag"},
+				{Child: ref, Rule: LintRuleNoLatestTag, Severity: LintSeverityWarning, Message: "container untagged uses image registry:5000/mysql without a fixed tag"},
+			},
+		},
+		"NotWorkload": {
+			rules: []LintRule{{Name: LintRuleContainerProbes}, {Name: LintRuleNoLatestTag}},
+			list: []resource.ChildResource{&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "ConfigMap",
+				"metadata":   map[string]interface{}{"name": "cool"},
+			}}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewRuleLinter(tc.rules...).Lint(tc.list)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Lint(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
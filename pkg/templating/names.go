@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// NameConflictStrategyAnnotationKey is the annotation on the
+	// StackDefinition whose value is the NameConflictStrategy for the child
+	// resources whose names are too long or collide with each other.
+	NameConflictStrategyAnnotationKey = "templatestacks.crossplane.io/name-conflict-strategy"
+
+	// MaxNameLengthAnnotationKey is the annotation on the StackDefinition
+	// whose value is the maximum length of the names of the child resources
+	// that the NameConflictStrategy enforces. DefaultMaxNameLength is used
+	// if it's not set.
+	MaxNameLengthAnnotationKey = "templatestacks.crossplane.io/max-name-length"
+
+	// DefaultMaxNameLength is the length of a DNS label, which is the limit
+	// of the names of kinds such as Service and Namespace.
+	DefaultMaxNameLength = 63
+
+	// nameHashLength is the length of the hash suffix of the truncated names.
+	nameHashLength = 8
+
+	errFmtMaxNameLength = "maximum name length should be an integer between %d and 253"
+)
+
+// A NameConflictStrategy determines what happens to the child resources whose
+// names are too long or collide with each other after they are patched.
+type NameConflictStrategy string
+
+// Name conflict strategies.
+const (
+	// NameConflictTruncate truncates the names that are too long and appends
+	// a hash of the full name, and appends a hash to the names of the child
+	// resources that collide with a previous one of the same kind in the same
+	// namespace.
+	NameConflictTruncate NameConflictStrategy = "Truncate"
+
+	// NameConflictReject rejects the child resources with a
+	// NameConflictError, which is reported in the RenderFailed condition of
+	// the parent resource.
+	NameConflictReject NameConflictStrategy = "Reject"
+)
+
+// ParseMaxNameLength parses the given maximum name length, typically the value
+// of MaxNameLengthAnnotationKey annotation.
+func ParseMaxNameLength(val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < nameHashLength+2 || n > 253 {
+		return 0, errors.Errorf(errFmtMaxNameLength, nameHashLength+2)
+	}
+	return n, nil
+}
+
+// A NameConflictError is returned when the names of some child resources are
+// too long or collide with each other.
+type NameConflictError struct {
+	// TooLong are the child resources whose names are too long.
+	TooLong []ChildReference
+
+	// Colliding are the child resources whose names collide with a previous
+	// child resource of the same kind in the same namespace.
+	Colliding []ChildReference
+
+	// MaxLength is the maximum length of the names.
+	MaxLength int
+}
+
+func (e *NameConflictError) Error() string {
+	var msgs []string
+	if len(e.TooLong) != 0 {
+		msgs = append(msgs, fmt.Sprintf("names longer than %d characters: %s", e.MaxLength, referenceNames(e.TooLong)))
+	}
+	if len(e.Colliding) != 0 {
+		msgs = append(msgs, fmt.Sprintf("names that collide with another child resource: %s", referenceNames(e.Colliding)))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// IsNameConflict returns true if the given error is a NameConflictError.
+func IsNameConflict(err error) bool {
+	_, ok := errors.Cause(err).(*NameConflictError)
+	return ok
+}
+
+// referenceNames returns the kinds and the names of the given references.
+func referenceNames(refs []ChildReference) string {
+	names := make([]string, len(refs))
+	for i, ref := range refs {
+		names[i] = ref.Kind + " " + ref.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// NewNameConflictPatcher returns a new NameConflictPatcher.
+func NewNameConflictPatcher(s NameConflictStrategy, maxLength int) NameConflictPatcher {
+	return NameConflictPatcher{Strategy: s, MaxLength: maxLength}
+}
+
+// NameConflictPatcher resolves the names of the child resources that are too
+// long, e.g. because a long name of the parent resource is used as their
+// prefix, or that collide with each other, with its NameConflictStrategy.
+// Note that the references to the renamed child resources in the other child
+// resources are not updated.
+type NameConflictPatcher struct {
+	Strategy  NameConflictStrategy
+	MaxLength int
+}
+
+// Patch patches the child resources with information in resource.ParentResource.
+func (np NameConflictPatcher) Patch(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	type key struct {
+		gk   schema.GroupKind
+		name types.NamespacedName
+	}
+	seen := map[key]int{}
+	e := &NameConflictError{MaxLength: np.MaxLength}
+	for _, o := range list {
+		ref := NewInventory([]resource.ChildResource{o})[0]
+		name := o.GetName()
+		if len(name) > np.MaxLength {
+			e.TooLong = append(e.TooLong, ref)
+			name = withHashSuffix(name, o.GetName(), np.MaxLength)
+		}
+		k := key{gk: o.GetObjectKind().GroupVersionKind().GroupKind(), name: types.NamespacedName{Namespace: o.GetNamespace(), Name: name}}
+		if n := seen[k]; n > 0 {
+			// NOTE: The suffix of the colliding child resources depends on
+			// their order so that they keep their names across the
+			// reconciliations.
+			e.Colliding = append(e.Colliding, ref)
+			name = withHashSuffix(name, fmt.Sprintf("%s/%d", o.GetName(), n), np.MaxLength)
+		}
+		seen[k]++
+		if np.Strategy == NameConflictTruncate {
+			o.SetName(name)
+		}
+	}
+	if np.Strategy == NameConflictReject && (len(e.TooLong) != 0 || len(e.Colliding) != 0) {
+		return nil, e
+	}
+	return list, nil
+}
+
+// withHashSuffix returns the given name with the hash of the given seed as
+// its suffix, truncated so that it's not longer than the given length.
+func withHashSuffix(name, seed string, length int) string {
+	if max := length - nameHashLength - 1; len(name) > max {
+		name = name[:max]
+	}
+	return fmt.Sprintf("%s-%s", strings.TrimRight(name, "-."), shortNameHash(seed))
+}
+
+// shortNameHash returns the first nameHashLength hexadecimal characters of
+// the SHA-256 hash of the given string.
+func shortNameHash(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[:nameHashLength]
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestNameConflictPatcher(t *testing.T) {
+	long := strings.Repeat("a", 20)
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	child := func(name string) resource.ChildResource {
+		return fake.NewMockResource(fake.WithGVK(configMap), fake.WithNamespaceName(name, "default"))
+	}
+	names := func(list []resource.ChildResource) []string {
+		result := make([]string, len(list))
+		for i, o := range list {
+			result[i] = o.GetName()
+		}
+		return result
+	}
+
+	type want struct {
+		names []string
+		err   error
+	}
+	cases := map[string]struct {
+		reason   string
+		strategy NameConflictStrategy
+		list     []resource.ChildResource
+		want     want
+	}{
+		"Valid": {
+			reason:   "The names that are short enough and unique should be left as they are.",
+			strategy: NameConflictTruncate,
+			list:     []resource.ChildResource{child("a"), child("b")},
+			want:     want{names: []string{"a", "b"}},
+		},
+		"Truncated": {
+			reason:   "The names that are too long should be truncated with a hash suffix.",
+			strategy: NameConflictTruncate,
+			list:     []resource.ChildResource{child(long)},
+			want:     want{names: []string{"aaaaaa-" + shortNameHash(long)}},
+		},
+		"Colliding": {
+			reason:   "The names that collide with a previous one should get a hash suffix.",
+			strategy: NameConflictTruncate,
+			list:     []resource.ChildResource{child("app"), child("app")},
+			want:     want{names: []string{"app", "app-" + shortNameHash("app/1")}},
+		},
+		"Rejected": {
+			reason:   "The names that are too long or collide should be rejected.",
+			strategy: NameConflictReject,
+			list:     []resource.ChildResource{child(long), child("app"), child("app")},
+			want: want{err: &NameConflictError{
+				TooLong:   []ChildReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: long}},
+				Colliding: []ChildReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "app"}},
+				MaxLength: 15,
+			}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewNameConflictPatcher(tc.strategy, 15).Patch(context.Background(), fake.NewMockResource(), tc.list)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.want.names, names(got)); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestParseMaxNameLength(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		val    string
+		want   int
+		err    error
+	}{
+		"Valid": {
+			reason: "A length within the limits should be parsed.",
+			val:    "52",
+			want:   52,
+		},
+		"TooShort": {
+			reason: "A length that leaves no room for the hash suffix should be rejected.",
+			val:    "5",
+			err:    errors.Errorf(errFmtMaxNameLength, nameHashLength+2),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMaxNameLength(tc.val)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseMaxNameLength(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParseMaxNameLength(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
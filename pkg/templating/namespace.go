@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// InstanceNamespaceAnnotationKey is the annotation on the StackDefinition
+// whose value is the template of the name of the namespace that is created
+// for every cluster-scoped parent resource, e.g. "{{ .metadata.name }}".
+const InstanceNamespaceAnnotationKey = "templatestacks.crossplane.io/instance-namespace"
+
+const (
+	errParseNamespaceTemplate = "cannot parse the instance namespace template"
+	errExecNamespaceTemplate  = "cannot execute the instance namespace template"
+	errFmtInvalidNamespace    = "instance namespace name %q is invalid: %s"
+)
+
+// NewInstanceNamespaceEngine returns a new *InstanceNamespaceEngine that
+// names the namespaces with the given template. The template is executed with
+// the content of the parent resource.
+func NewInstanceNamespaceEngine(e Engine, name string) (*InstanceNamespaceEngine, error) {
+	t, err := template.New("namespace").Option("missingkey=error").Parse(name)
+	if err != nil {
+		return nil, errors.Wrap(err, errParseNamespaceTemplate)
+	}
+	return &InstanceNamespaceEngine{Engine: e, Name: t}, nil
+}
+
+// InstanceNamespaceEngine gives every cluster-scoped parent resource a
+// dedicated namespace. The namespace is prepended to the child resources
+// rendered by the underlying Engine so that it's created first, it's the
+// default namespace of the child resources, and it's deleted last. The
+// child resources of namespaced parent resources are left untouched.
+type InstanceNamespaceEngine struct {
+	Engine Engine
+	Name   *template.Template
+}
+
+// Run runs the underlying Engine and places the resulting child resources in
+// the namespace of the given parent resource.
+func (e *InstanceNamespaceEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	res, err := e.Engine.Run(ctx, cr)
+	if err != nil || cr.GetNamespace() != "" {
+		return res, err
+	}
+	name, err := e.namespaceOf(cr)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	for _, o := range res.Children {
+		if o.GetNamespace() == "" {
+			o.SetNamespace(name)
+		}
+	}
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	ns.SetName(name)
+	// NOTE: The child resources with the lowest deletion priority are deleted
+	// after all others are gone.
+	meta.AddAnnotations(ns, map[string]string{DeletionPriorityAnnotationKey: strconv.FormatInt(math.MinInt64, 10)})
+	res.Children = append([]resource.ChildResource{ns}, res.Children...)
+	return res, nil
+}
+
+// Values returns the values of the underlying Engine for the given parent
+// resource.
+func (e *InstanceNamespaceEngine) Values(cr resource.ParentResource) (map[string]interface{}, error) {
+	return valuesOf(e.Engine, cr)
+}
+
+// ValuesSources returns the sources of the values of the underlying Engine
+// for the given parent resource.
+func (e *InstanceNamespaceEngine) ValuesSources(cr resource.ParentResource) []string {
+	return valuesSourcesOf(e.Engine, cr)
+}
+
+func (e *InstanceNamespaceEngine) namespaceOf(cr resource.ParentResource) (string, error) {
+	b := &strings.Builder{}
+	if err := e.Name.Execute(b, cr.UnstructuredContent()); err != nil {
+		return "", errors.Wrap(err, errExecNamespaceTemplate)
+	}
+	name := strings.TrimSpace(b.String())
+	if errs := validation.IsDNS1123Label(name); len(errs) != 0 {
+		return "", errors.Errorf(errFmtInvalidNamespace, name, strings.Join(errs, ", "))
+	}
+	return name, nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestInstanceNamespaceEngine(t *testing.T) {
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	ns.SetName("resname-system")
+	ns.SetAnnotations(map[string]string{DeletionPriorityAnnotationKey: strconv.FormatInt(math.MinInt64, 10)})
+
+	type want struct {
+		list []resource.ChildResource
+		err  bool
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		name   string
+		list   []resource.ChildResource
+		want   want
+	}{
+		"ClusterScoped": {
+			reason: "The namespace should be created first and be the default namespace of the child resources",
+			cr:     fake.NewMockResource(fake.WithNamespaceName(fakeName, "")),
+			name:   "{{ .metadata.name }}-system",
+			list: []resource.ChildResource{
+				fake.NewMockResource(fake.WithNamespaceName("a", "")),
+				fake.NewMockResource(fake.WithNamespaceName("b", "other")),
+			},
+			want: want{
+				list: []resource.ChildResource{
+					ns,
+					fake.NewMockResource(fake.WithNamespaceName("a", "resname-system")),
+					fake.NewMockResource(fake.WithNamespaceName("b", "other")),
+				},
+			},
+		},
+		"Namespaced": {
+			reason: "The child resources of namespaced parents should be left untouched",
+			cr:     fake.NewMockResource(fake.WithNamespaceName(fakeName, fakeNamespace)),
+			name:   "{{ .metadata.name }}-system",
+			list:   []resource.ChildResource{fake.NewMockResource(fake.WithNamespaceName("a", ""))},
+			want: want{
+				list: []resource.ChildResource{fake.NewMockResource(fake.WithNamespaceName("a", ""))},
+			},
+		},
+		"InvalidName": {
+			reason: "An error should be returned if the name is not a valid namespace name",
+			cr:     fake.NewMockResource(fake.WithNamespaceName(fakeName, "")),
+			name:   "{{ .metadata.name }}_system",
+			want: want{
+				err: true,
+			},
+		},
+		"MissingField": {
+			reason: "An error should be returned if the template refers to a missing field",
+			cr:     fake.NewMockResource(fake.WithNamespaceName(fakeName, "")),
+			name:   "{{ .spec.tenant }}",
+			want: want{
+				err: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e, err := NewInstanceNamespaceEngine(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{Children: tc.list}, nil
+			}), tc.name)
+			if err != nil {
+				t.Fatalf("NewInstanceNamespaceEngine(...): unexpected error: %v", err)
+			}
+			got, err := e.Run(context.Background(), tc.cr)
+			if diff := cmp.Diff(tc.want.err, err != nil); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.list, got.Children); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
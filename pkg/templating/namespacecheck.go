@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// NamespaceCheckAnnotationKey is the annotation on the StackDefinition
+	// that enables the check of the namespaces of the child resources before
+	// they are applied.
+	NamespaceCheckAnnotationKey = "templatestacks.crossplane.io/namespace-check"
+
+	// NamespaceCheckEnabled reports the missing namespaces in the
+	// NamespacesMissing condition of the parent resource.
+	NamespaceCheckEnabled = "true"
+
+	// NamespaceCheckCreate creates the missing namespaces instead.
+	NamespaceCheckCreate = "create"
+
+	// DefaultNamespaceCacheTTL is how long a namespace that is known to exist
+	// is not looked up again.
+	DefaultNamespaceCacheTTL = time.Minute
+
+	errCheckNamespaces = "cannot check the namespaces of the child resources"
+	errGetNamespace    = "cannot get the namespace"
+	errCreateNamespace = "cannot create the namespace"
+)
+
+// TypeNamespacesMissing indicates whether the namespaces of some child
+// resources of the parent resource do not exist.
+const TypeNamespacesMissing v1alpha1.ConditionType = "NamespacesMissing"
+
+// Reasons the namespaces of the child resources do or do not exist.
+const (
+	ReasonNamespacesMissing v1alpha1.ConditionReason = "Namespaces of some child resources do not exist"
+	ReasonNamespacesExist   v1alpha1.ConditionReason = "Namespaces of all child resources exist"
+)
+
+// NamespacesMissing returns a condition that indicates the namespaces of some
+// child resources of the parent resource do not exist.
+func NamespacesMissing(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeNamespacesMissing,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNamespacesMissing,
+		Message:            err.Error(),
+	}
+}
+
+// NamespacesExist returns a condition that indicates the namespaces of all
+// child resources of the parent resource exist.
+func NamespacesExist() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeNamespacesMissing,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNamespacesExist,
+	}
+}
+
+// A NamespaceChecker returns the given namespaces that do not exist.
+type NamespaceChecker interface {
+	Missing(ctx context.Context, namespaces []string) ([]string, error)
+}
+
+// A MissingNamespacesError is returned when the namespaces of some child
+// resources do not exist.
+type MissingNamespacesError struct {
+	// Children are the child resources that need the missing namespaces,
+	// keyed by the namespaces.
+	Children map[string][]ChildReference
+}
+
+func (e *MissingNamespacesError) Error() string {
+	namespaces := make([]string, 0, len(e.Children))
+	for ns := range e.Children {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	msgs := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		names := make([]string, len(e.Children[ns]))
+		for j, ref := range e.Children[ns] {
+			names[j] = ref.Kind + " " + ref.Name
+		}
+		msgs[i] = fmt.Sprintf("namespace %s does not exist, it is needed by %s", ns, strings.Join(names, ", "))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// IsMissingNamespaces returns true if the given error is a
+// MissingNamespacesError.
+func IsMissingNamespaces(err error) bool {
+	_, ok := errors.Cause(err).(*MissingNamespacesError)
+	return ok
+}
+
+// checkNamespaces returns a MissingNamespacesError if the namespaces of some
+// of the given child resources do not exist. The cluster-scoped child
+// resources, and the namespaced ones in the namespace of the given
+// parent resource or in a namespace that is among the child resources, are
+// not checked since their namespaces exist, or are created before the child
+// resources in them are applied.
+func (r *Reconciler) checkNamespaces(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	if r.namespaces == nil {
+		return nil
+	}
+	rendered := map[string]bool{cr.GetNamespace(): true}
+	for _, o := range list {
+		if o.GetObjectKind().GroupVersionKind() == corev1.SchemeGroupVersion.WithKind("Namespace") {
+			rendered[o.GetName()] = true
+		}
+	}
+	children := map[string][]ChildReference{}
+	var namespaces []string
+	for _, o := range list {
+		ns := o.GetNamespace()
+		if ns == "" || rendered[ns] {
+			continue
+		}
+		if _, ok := children[ns]; !ok {
+			namespaces = append(namespaces, ns)
+		}
+		children[ns] = append(children[ns], NewInventory([]resource.ChildResource{o})[0])
+	}
+	if len(namespaces) == 0 {
+		return nil
+	}
+	missing, err := r.namespaces.Missing(ctx, namespaces)
+	if err != nil {
+		return errors.Wrap(err, errCheckNamespaces)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	e := &MissingNamespacesError{Children: map[string][]ChildReference{}}
+	for _, ns := range missing {
+		e.Children[ns] = children[ns]
+	}
+	return e
+}
+
+// namespacesMissing returns true if the given parent resource is marked as
+// missing namespaces.
+func namespacesMissing(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypeNamespacesMissing)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
+
+// NewAPINamespaceChecker returns a new *APINamespaceChecker that looks up the
+// namespaces with the given reader, and creates the missing ones with the
+// given writer if create is true. The reader is typically not backed by a
+// cache so that the controller does not need to watch all namespaces.
+func NewAPINamespaceChecker(r client.Reader, w client.Writer, create bool) *APINamespaceChecker {
+	return &APINamespaceChecker{reader: r, writer: w, create: create, ttl: DefaultNamespaceCacheTTL, now: time.Now, exists: map[string]time.Time{}}
+}
+
+// An APINamespaceChecker looks up the namespaces in the API server. The
+// namespaces that exist are cached for a while so that the namespaces of
+// every child resource are not looked up in every reconciliation, while the
+// missing ones are looked up every time so that they are picked up as soon
+// as they are created.
+type APINamespaceChecker struct {
+	reader client.Reader
+	writer client.Writer
+	create bool
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	exists map[string]time.Time
+}
+
+// Missing returns the given namespaces that do not exist, after creating
+// them if the APINamespaceChecker is configured to.
+func (c *APINamespaceChecker) Missing(ctx context.Context, namespaces []string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var missing []string
+	for _, name := range namespaces {
+		if seen, ok := c.exists[name]; ok && c.now().Sub(seen) < c.ttl {
+			continue
+		}
+		err := c.reader.Get(ctx, types.NamespacedName{Name: name}, &corev1.Namespace{})
+		if kerrors.IsNotFound(err) && c.create {
+			err = c.writer.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+			if err != nil && !kerrors.IsAlreadyExists(err) {
+				return nil, errors.Wrap(err, errCreateNamespace)
+			}
+			err = nil
+		}
+		switch {
+		case kerrors.IsNotFound(err):
+			missing = append(missing, name)
+			continue
+		case err != nil:
+			return nil, errors.Wrap(err, errGetNamespace)
+		}
+		c.exists[name] = c.now()
+	}
+	return missing, nil
+}
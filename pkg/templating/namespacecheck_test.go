@@ -0,0 +1,203 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+type namespaceCheckerFn func(ctx context.Context, namespaces []string) ([]string, error)
+
+func (fn namespaceCheckerFn) Missing(ctx context.Context, namespaces []string) ([]string, error) {
+	return fn(ctx, namespaces)
+}
+
+func TestCheckNamespaces(t *testing.T) {
+	child := func(kind, namespace, name string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(schema.GroupVersionKind{Version: "v1", Kind: kind})
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	missing := func(namespaces ...string) NamespaceChecker {
+		return namespaceCheckerFn(func(_ context.Context, checked []string) ([]string, error) {
+			if diff := cmp.Diff([]string{"team-a", "team-b"}, checked); diff != "" {
+				t.Errorf("Missing(...): -want namespaces, +got namespaces:\n%s", diff)
+			}
+			return namespaces, nil
+		})
+	}
+	list := []resource.ChildResource{
+		child("ConfigMap", "team-a", "config"),
+		child("Service", "team-b", "web"),
+		child("Deployment", "team-b", "web"),
+		child("ClusterRole", "", "reader"),
+		child("Secret", "cool-ns", "password"),
+		child("Namespace", "", "rendered"),
+		child("ConfigMap", "rendered", "config"),
+	}
+	cases := map[string]struct {
+		reason string
+		nc     NamespaceChecker
+		want   error
+	}{
+		"Disabled": {
+			reason: "No namespaces should be checked if there is no NamespaceChecker.",
+		},
+		"AllExist": {
+			reason: "No error should be returned if all namespaces exist.",
+			nc:     missing(),
+		},
+		"Missing": {
+			reason: "The missing namespaces should be reported with the child resources that need them.",
+			nc:     missing("team-b"),
+			want: &MissingNamespacesError{Children: map[string][]ChildReference{
+				"team-b": {
+					{APIVersion: "v1", Kind: "Service", Namespace: "team-b", Name: "web"},
+					{APIVersion: "v1", Kind: "Deployment", Namespace: "team-b", Name: "web"},
+				},
+			}},
+		},
+		"CheckFailed": {
+			reason: "The error of the NamespaceChecker should be wrapped.",
+			nc: namespaceCheckerFn(func(context.Context, []string) ([]string, error) {
+				return nil, errBoom
+			}),
+			want: errors.Wrap(errBoom, errCheckNamespaces),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{namespaces: tc.nc}
+			cr := fake.NewMockResource()
+			cr.SetNamespace("cool-ns")
+			err := r.checkNamespaces(context.Background(), cr, list)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\ncheckNamespaces(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestMissingNamespacesError(t *testing.T) {
+	err := &MissingNamespacesError{Children: map[string][]ChildReference{
+		"team-b": {{Kind: "Service", Name: "web"}, {Kind: "Deployment", Name: "web"}},
+		"team-a": {{Kind: "ConfigMap", Name: "config"}},
+	}}
+	want := "namespace team-a does not exist, it is needed by ConfigMap config; namespace team-b does not exist, it is needed by Service web, Deployment web"
+	if diff := cmp.Diff(want, err.Error()); diff != "" {
+		t.Errorf("Error(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestAPINamespaceChecker(t *testing.T) {
+	notFound := kerrors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "")
+	type want struct {
+		missing []string
+		created []string
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		create bool
+		want   want
+	}{
+		"Missing": {
+			reason: "The namespaces that are not found should be returned.",
+			get: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+				if key.Name == "team-b" {
+					return notFound
+				}
+				return nil
+			},
+			want: want{missing: []string{"team-b"}},
+		},
+		"Create": {
+			reason: "The namespaces that are not found should be created if configured.",
+			get:    test.NewMockGetFn(notFound),
+			create: true,
+			want:   want{created: []string{"team-a", "team-b"}},
+		},
+		"GetFailed": {
+			reason: "The errors other than NotFound should be returned.",
+			get:    test.NewMockGetFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errGetNamespace)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created []string
+			c := &test.MockClient{
+				MockGet: tc.get,
+				MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+					created = append(created, obj.(*corev1.Namespace).GetName())
+					return nil
+				},
+			}
+			got, err := NewAPINamespaceChecker(c, c, tc.create).Missing(context.Background(), []string{"team-a", "team-b"})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nMissing(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.missing, got); diff != "" {
+				t.Errorf("\n%s\nMissing(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Errorf("\n%s\nMissing(...): -want created, +got created:\n%s", tc.reason, diff)
+			}
+		})
+	}
+
+	// The namespaces that exist should not be looked up again until the
+	// cache expires.
+	gets := 0
+	now := time.Now()
+	nc := NewAPINamespaceChecker(&test.MockClient{MockGet: func(context.Context, client.ObjectKey, runtime.Object) error {
+		gets++
+		return nil
+	}}, nil, false)
+	nc.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		if _, err := nc.Missing(context.Background(), []string{"team-a"}); err != nil {
+			t.Fatalf("Missing(...): %s", err)
+		}
+	}
+	now = now.Add(DefaultNamespaceCacheTTL)
+	if _, err := nc.Missing(context.Background(), []string{"team-a"}); err != nil {
+		t.Fatalf("Missing(...): %s", err)
+	}
+	if diff := cmp.Diff(2, gets); diff != "" {
+		t.Errorf("Missing(...): -want gets, +got gets:\n%s", diff)
+	}
+}
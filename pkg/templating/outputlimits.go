@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// OutputLimitsAnnotationKey is the annotation on the StackDefinition whose
+// value is the YAML representation of the OutputLimits of the rendered child
+// resources.
+const OutputLimitsAnnotationKey = "templatestacks.crossplane.io/output-limits"
+
+const (
+	errParseOutputLimits   = "cannot parse the output limits"
+	errFmtMaxBytes         = "cannot parse the maximum bytes %q"
+	errNegativeOutputLimit = "output limits cannot be negative"
+	errFmtTooManyObjects   = "rendered %d child resources, more than the limit of %d"
+	errFmtTooManyBytes     = "rendered more than %d bytes of child resources"
+	errMarshalChild        = "cannot marshal the child resource"
+)
+
+// OutputLimits are the limits of the child resources that the templating
+// engine renders for a single parent resource. Zero means unlimited.
+type OutputLimits struct {
+	// MaxObjects is the maximum number of child resources.
+	MaxObjects int
+
+	// MaxBytes is the maximum total size of the JSON representations of the
+	// child resources.
+	MaxBytes int64
+}
+
+// outputLimits is the YAML representation of OutputLimits.
+type outputLimits struct {
+	MaxObjects int    `json:"maxObjects,omitempty"`
+	MaxBytes   string `json:"maxBytes,omitempty"`
+}
+
+// ParseOutputLimits parses the given YAML representation of the output
+// limits, typically the value of OutputLimitsAnnotationKey annotation, e.g.
+// {maxObjects: 200, maxBytes: 2Mi}. The bytes are a Kubernetes quantity.
+func ParseOutputLimits(data string) (OutputLimits, error) {
+	raw := outputLimits{}
+	if err := yaml.Unmarshal([]byte(data), &raw); err != nil {
+		return OutputLimits{}, errors.Wrap(err, errParseOutputLimits)
+	}
+	l := OutputLimits{MaxObjects: raw.MaxObjects}
+	if raw.MaxBytes != "" {
+		q, err := kresource.ParseQuantity(raw.MaxBytes)
+		if err != nil {
+			return OutputLimits{}, errors.Wrapf(err, errFmtMaxBytes, raw.MaxBytes)
+		}
+		l.MaxBytes = q.Value()
+	}
+	if l.MaxObjects < 0 || l.MaxBytes < 0 {
+		return OutputLimits{}, errors.New(errNegativeOutputLimit)
+	}
+	return l, nil
+}
+
+// An OutputLimitError is returned when the rendered child resources exceed
+// the OutputLimits. Rendering the same parent resource again results in the
+// same error, so it's not retried until the parent resource changes or its
+// resync interval passes.
+type OutputLimitError struct {
+	Err error
+}
+
+func (e *OutputLimitError) Error() string {
+	return e.Err.Error()
+}
+
+// IsOutputLimitExceeded returns true if the given error is or wraps an
+// OutputLimitError.
+func IsOutputLimitExceeded(err error) bool {
+	var le *OutputLimitError
+	return errors.As(err, &le)
+}
+
+// Check returns an OutputLimitError if the given child resources exceed the
+// limits.
+func (l OutputLimits) Check(list []resource.ChildResource) error {
+	if l.MaxObjects > 0 && len(list) > l.MaxObjects {
+		return &OutputLimitError{Err: errors.Errorf(errFmtTooManyObjects, len(list), l.MaxObjects)}
+	}
+	if l.MaxBytes == 0 {
+		return nil
+	}
+	var size int64
+	for _, o := range list {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return errors.Wrap(err, errMarshalChild)
+		}
+		// NOTE: The size is checked after every child resource so that the
+		// rest of a huge output is not marshalled.
+		if size += int64(len(data)); size > l.MaxBytes {
+			return &OutputLimitError{Err: errors.Errorf(errFmtTooManyBytes, l.MaxBytes)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestParseOutputLimits(t *testing.T) {
+	type want struct {
+		limits OutputLimits
+		err    error
+	}
+	cases := map[string]struct {
+		data string
+		want want
+	}{
+		"Valid": {
+			data: "maxObjects: 200\nmaxBytes: 2Mi\n",
+			want: want{limits: OutputLimits{MaxObjects: 200, MaxBytes: 2 << 20}},
+		},
+		"Empty": {
+			data: "{}",
+			want: want{limits: OutputLimits{}},
+		},
+		"Negative": {
+			data: "maxObjects: -1\n",
+			want: want{err: errors.New(errNegativeOutputLimit)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseOutputLimits(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ParseOutputLimits(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.limits, got); diff != "" {
+				t.Errorf("ParseOutputLimits(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOutputLimitsCheck(t *testing.T) {
+	// NOTE: Every child resource is {"kind":"ConfigMap"}, 20 bytes.
+	list := []resource.ChildResource{
+		&unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}},
+		&unstructured.Unstructured{Object: map[string]interface{}{"kind": "ConfigMap"}},
+	}
+	cases := map[string]struct {
+		reason string
+		limits OutputLimits
+		want   error
+	}{
+		"Unlimited": {
+			reason: "Zero limits should allow any output.",
+		},
+		"WithinLimits": {
+			reason: "The output that is exactly at the limits should be allowed.",
+			limits: OutputLimits{MaxObjects: 2, MaxBytes: 40},
+		},
+		"TooManyObjects": {
+			reason: "More child resources than the limit should result in an OutputLimitError.",
+			limits: OutputLimits{MaxObjects: 1},
+			want:   &OutputLimitError{Err: errors.Errorf(errFmtTooManyObjects, 2, 1)},
+		},
+		"TooManyBytes": {
+			reason: "Child resources that are larger than the limit in total should result in an OutputLimitError.",
+			limits: OutputLimits{MaxBytes: 30},
+			want:   &OutputLimitError{Err: errors.Errorf(errFmtTooManyBytes, 30)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.limits.Check(list)
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nCheck(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/rbac"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// PermissionCheckAnnotationKey is the annotation on the StackDefinition
+	// that enables the check of the permissions of the controller for the
+	// child resources before they are applied.
+	PermissionCheckAnnotationKey = "templatestacks.crossplane.io/permission-check"
+
+	// PermissionCheckEnabled reports the missing permissions in the status
+	// of the parent resource.
+	PermissionCheckEnabled = "true"
+
+	// PermissionCheckWithHints additionally emits an event with the Role or
+	// ClusterRole that grants the missing permissions.
+	PermissionCheckWithHints = "hints"
+
+	// EventReasonMissingPermissions is the reason of the event that contains
+	// the Role or ClusterRole that grants the missing permissions.
+	EventReasonMissingPermissions event.Reason = "MissingPermissions"
+
+	errFmtMissingPermissions = "controller is not permitted to manage %s"
+	errCheckPermissions      = "cannot check the permissions of the controller"
+	errMarshalRole           = "cannot marshal the role that grants the missing permissions"
+	errFmtRoleHint           = "%s, they can be granted with:\n%s"
+)
+
+// A PermissionChecker returns the group resources of the given child
+// resources that the controller is not permitted to manage.
+type PermissionChecker interface {
+	Missing(ctx context.Context, list []resource.ChildResource) ([]schema.GroupResource, error)
+}
+
+// MissingPermission is a group resource that the controller is not permitted
+// to manage. They are reported in the status.missingPermissions field of the
+// parent resource.
+type MissingPermission struct {
+	Group    string `json:"group"`
+	Resource string `json:"resource"`
+}
+
+// SetMissingPermissions sets the status.missingPermissions field of the given
+// parent resource. The field is removed if there are no missing permissions.
+func SetMissingPermissions(cr interface{ UnstructuredContent() map[string]interface{} }, grs []schema.GroupResource) error {
+	if len(grs) == 0 {
+		unstructured.RemoveNestedField(cr.UnstructuredContent(), "status", "missingPermissions")
+		return nil
+	}
+	list := make([]interface{}, len(grs))
+	for i, gr := range grs {
+		list[i] = map[string]interface{}{"group": gr.Group, "resource": gr.Resource}
+	}
+	return unstructured.SetNestedSlice(cr.UnstructuredContent(), list, "status", "missingPermissions")
+}
+
+// checkPermissions reports the group resources of the given child resources
+// that the controller is not permitted to manage in the status of the given
+// parent resource and returns a single error that lists all of them. If
+// configured, an event with the Role or ClusterRole that grants the missing
+// permissions is emitted.
+func (r *Reconciler) checkPermissions(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	if r.permissions == nil {
+		return nil
+	}
+	missing, err := r.permissions.Missing(ctx, list)
+	if err != nil {
+		return errors.Wrap(err, errCheckPermissions)
+	}
+	if err := SetMissingPermissions(cr, missing); err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	names := make([]string, len(missing))
+	for i, gr := range missing {
+		names[i] = gr.String()
+	}
+	err = errors.Errorf(errFmtMissingPermissions, strings.Join(names, ", "))
+	if r.roleHints {
+		r.recorder.Event(cr, event.Warning(EventReasonMissingPermissions, errors.Errorf(errFmtRoleHint, err, roleHint(cr, missing))))
+	}
+	return err
+}
+
+// roleHint returns the YAML of the Role, or the ClusterRole if the given
+// parent resource is cluster-scoped, that grants the given missing
+// permissions.
+func roleHint(cr resource.ParentResource, missing []schema.GroupResource) string {
+	var role interface{} = rbac.NewClusterRole(NameOf(cr.GroupVersionKind())+"-missing", rbac.ChildPolicyRules(missing))
+	if cr.GetNamespace() != "" {
+		role = rbac.NewRole(NameOf(cr.GroupVersionKind())+"-missing", cr.GetNamespace(), rbac.ChildPolicyRules(missing))
+	}
+	data, err := yaml.Marshal(role)
+	if err != nil {
+		return errMarshalRole
+	}
+	return string(data)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// PipelineAnnotationKey is the annotation on the StackDefinition whose value
+// is the YAML list of the PipelineStages that post-process the child
+// resources rendered by the engine of the StackDefinition, in order.
+const PipelineAnnotationKey = "templatestacks.crossplane.io/engine-pipeline"
+
+const (
+	errParsePipeline = "could not parse the engine pipeline"
+	errFmtStageType  = "type of pipeline stage %d is empty"
+	errFmtStage      = "pipeline stage %d failed"
+)
+
+// A PipelineStage declares a stage of the engine pipeline.
+type PipelineStage struct {
+	// Type is the engine type of the stage, e.g. kustomize.
+	Type string `json:"type"`
+
+	// Path is the directory of the stage relative to the resources
+	// directory. The resources directory itself is used if it's empty.
+	Path string `json:"path,omitempty"`
+}
+
+// ParsePipeline parses the given YAML list of PipelineStages, typically the
+// value of PipelineAnnotationKey annotation.
+func ParsePipeline(data string) ([]PipelineStage, error) {
+	var stages []PipelineStage
+	if err := yaml.Unmarshal([]byte(data), &stages); err != nil {
+		return nil, errors.Wrap(err, errParsePipeline)
+	}
+	for i, s := range stages {
+		if s.Type == "" {
+			return nil, errors.Errorf(errFmtStageType, i+1)
+		}
+	}
+	return stages, nil
+}
+
+// NewChainedEngine returns a new *ChainedEngine that feeds the child
+// resources rendered by the given Engine through the given Stages.
+func NewChainedEngine(e Engine, stages ...Stage) *ChainedEngine {
+	return &ChainedEngine{Engine: e, Stages: stages}
+}
+
+// ChainedEngine renders the child resources with its Engine and then passes
+// them through its Stages in order, each stage receiving the output of the
+// previous one. The errors of the stages are wrapped with the number of the
+// stage, starting with 1, and keep their type, e.g. a resource.RenderError.
+type ChainedEngine struct {
+	Engine Engine
+	Stages []Stage
+}
+
+// Run runs the Engine and the Stages.
+func (e *ChainedEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	res, err := e.Engine.Run(ctx, cr)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	for i, s := range e.Stages {
+		if err := ctx.Err(); err != nil {
+			return resource.RenderResult{}, err
+		}
+		if res.Children, err = s.Transform(cr, res.Children); err != nil {
+			return resource.RenderResult{}, errors.Wrapf(err, errFmtStage, i+1)
+		}
+	}
+	return res, nil
+}
+
+// ValuesSources returns the sources of the values of the Engine for the given
+// parent resource. The Stages do not read any values.
+func (e *ChainedEngine) ValuesSources(cr resource.ParentResource) []string {
+	return valuesSourcesOf(e.Engine, cr)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestParsePipeline(t *testing.T) {
+	type want struct {
+		stages []PipelineStage
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Valid": {
+			reason: "The stages should be parsed in order.",
+			data:   "- type: kustomize\n  path: post\n- type: kustomize\n",
+			want:   want{stages: []PipelineStage{{Type: "kustomize", Path: "post"}, {Type: "kustomize"}}},
+		},
+		"NoType": {
+			reason: "A stage without a type should be rejected.",
+			data:   "- path: post\n",
+			want:   want{err: errors.Errorf(errFmtStageType, 1)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParsePipeline(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParsePipeline(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.stages, got); diff != "" {
+				t.Errorf("\n%s\nParsePipeline(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestChainedEngine(t *testing.T) {
+	named := func(name string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetName(name)
+		return u
+	}
+	appendStage := func(name string) Stage {
+		return StageFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+			return append(list, named(name)), nil
+		})
+	}
+	type want struct {
+		result resource.RenderResult
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		e      *ChainedEngine
+		want   want
+	}{
+		"EngineFailed": {
+			reason: "The error of the engine should be returned as is.",
+			e: NewChainedEngine(EngineFunc(func(context.Context, resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{}, errBoom
+			}), appendStage("b")),
+			want: want{err: errBoom},
+		},
+		"StageFailed": {
+			reason: "The error of a stage should be wrapped with its number.",
+			e: NewChainedEngine(EngineFunc(func(context.Context, resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{Children: []resource.ChildResource{named("a")}}, nil
+			}), appendStage("b"), StageFunc(func(resource.ParentResource, []resource.ChildResource) ([]resource.ChildResource, error) {
+				return nil, errBoom
+			})),
+			want: want{err: errors.Wrapf(errBoom, errFmtStage, 2)},
+		},
+		"Success": {
+			reason: "Every stage should receive the output of the previous one and the warnings of the engine should be kept.",
+			e: NewChainedEngine(EngineFunc(func(context.Context, resource.ParentResource) (resource.RenderResult, error) {
+				return resource.RenderResult{Children: []resource.ChildResource{named("a")}, Warnings: []string{"careful"}}, nil
+			}), appendStage("b"), appendStage("c")),
+			want: want{result: resource.RenderResult{Children: []resource.ChildResource{named("a"), named("b"), named("c")}, Warnings: []string{"careful"}}},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.e.Run(context.Background(), fake.NewMockResource())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
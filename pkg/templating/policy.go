@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// A ManagementPolicy determines whether the reconciler changes the child
+// resources of a parent resource or only observes them.
+type ManagementPolicy string
+
+// The management policies of the parent resources.
+const (
+	// ManagementPolicyManage renders, applies and deletes the child
+	// resources. It's the default.
+	ManagementPolicyManage ManagementPolicy = "Manage"
+
+	// ManagementPolicyObserve renders the child resources and reports the
+	// changes that applying them would make, but nothing is created,
+	// patched or deleted. The child resources of a deleted parent resource
+	// are orphaned.
+	ManagementPolicyObserve ManagementPolicy = "Observe"
+)
+
+const (
+	// ManagementPolicyAnnotationKey is the annotation on the parent resource
+	// whose value is its ManagementPolicy. The spec.managementPolicy field
+	// of the parent resource takes precedence over it.
+	ManagementPolicyAnnotationKey = "templatestacks.crossplane.io/management-policy"
+
+	errFmtManagementPolicy = "unknown management policy %q, must be Manage or Observe"
+)
+
+// ReasonManaged is the reason of the DryRun condition of a parent resource
+// whose child resources are managed again after they were observed.
+const ReasonManaged v1alpha1.ConditionReason = "Child resources are managed"
+
+// Managed returns a condition that indicates the child resources of the
+// parent resource are managed instead of observed.
+func Managed() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeDryRun,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonManaged,
+	}
+}
+
+// ManagementPolicyOf returns the ManagementPolicy of the given parent
+// resource, which is read from its spec.managementPolicy field or its
+// ManagementPolicyAnnotationKey annotation.
+func ManagementPolicyOf(cr resource.ParentResource) (ManagementPolicy, error) {
+	p, _, _ := unstructured.NestedString(cr.UnstructuredContent(), "spec", "managementPolicy")
+	if p == "" {
+		p = cr.GetAnnotations()[ManagementPolicyAnnotationKey]
+	}
+	switch ManagementPolicy(p) {
+	case ManagementPolicyManage, "":
+		return ManagementPolicyManage, nil
+	case ManagementPolicyObserve:
+		return ManagementPolicyObserve, nil
+	}
+	return "", errors.Errorf(errFmtManagementPolicy, p)
+}
+
+// WithManagementPolicies returns a ReconcilerOption that makes the reconciler
+// honor the ManagementPolicy of every parent resource. The changes to the
+// child resources of the parent resources with ManagementPolicyObserve are
+// computed with the dry-run applies of the given Previewer and reported as in
+// dry-run mode. The policy can be switched at any time.
+func WithManagementPolicies(p *Previewer) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.observer = p
+	}
+}
+
+// observerOf returns the Previewer that computes the changes to the child
+// resources of the given parent resource instead of applying them, or nil if
+// the child resources are managed. A parent resource is observed if the
+// reconciler is in dry-run mode or its ManagementPolicy is
+// ManagementPolicyObserve. A parent resource with an unknown policy is
+// observed too, so that a typo never changes anything.
+func (r *Reconciler) observerOf(cr resource.ParentResource) *Previewer {
+	if r.dryRunner != nil {
+		return r.dryRunner
+	}
+	if r.observer == nil {
+		return nil
+	}
+	if p, err := ManagementPolicyOf(cr); err != nil || p == ManagementPolicyObserve {
+		return r.observer
+	}
+	return nil
+}
+
+// markedObserved returns true if the given parent resource is marked as observed
+// by the DryRun condition.
+func markedObserved(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypeDryRun)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
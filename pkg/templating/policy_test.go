@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestManagementPolicyOf(t *testing.T) {
+	parent := func(field, annotation string) resource.ParentResource {
+		cr := fake.NewMockResource()
+		if field != "" {
+			cr.Object["spec"] = map[string]interface{}{"managementPolicy": field}
+		}
+		if annotation != "" {
+			cr.SetAnnotations(map[string]string{ManagementPolicyAnnotationKey: annotation})
+		}
+		return cr
+	}
+	type want struct {
+		p   ManagementPolicy
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		want   want
+	}{
+		"Default": {
+			reason: "A parent resource without a policy should be managed.",
+			cr:     parent("", ""),
+			want:   want{p: ManagementPolicyManage},
+		},
+		"Annotation": {
+			reason: "The policy should be read from the annotation.",
+			cr:     parent("", "Observe"),
+			want:   want{p: ManagementPolicyObserve},
+		},
+		"FieldOverAnnotation": {
+			reason: "The spec.managementPolicy field should take precedence over the annotation.",
+			cr:     parent("Manage", "Observe"),
+			want:   want{p: ManagementPolicyManage},
+		},
+		"Unknown": {
+			reason: "An unknown policy should be rejected.",
+			cr:     parent("observe", ""),
+			want:   want{err: errors.Errorf(errFmtManagementPolicy, "observe")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ManagementPolicyOf(tc.cr)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nManagementPolicyOf(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.p, got); diff != "" {
+				t.Errorf("\n%s\nManagementPolicyOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestObserverOf(t *testing.T) {
+	observer, dryRunner := &Previewer{namespace: "observer"}, &Previewer{namespace: "dry-run"}
+	parent := func(policy string) resource.ParentResource {
+		cr := fake.NewMockResource()
+		cr.SetAnnotations(map[string]string{ManagementPolicyAnnotationKey: policy})
+		return cr
+	}
+	cases := map[string]struct {
+		reason string
+		r      *Reconciler
+		cr     resource.ParentResource
+		want   *Previewer
+	}{
+		"NotHonored": {
+			reason: "The policies should be ignored unless they are enabled.",
+			r:      &Reconciler{},
+			cr:     parent("Observe"),
+		},
+		"Managed": {
+			reason: "A managed parent resource should not be observed.",
+			r:      &Reconciler{observer: observer},
+			cr:     parent("Manage"),
+		},
+		"Observed": {
+			reason: "An observed parent resource should be observed by the observer.",
+			r:      &Reconciler{observer: observer},
+			cr:     parent("Observe"),
+			want:   observer,
+		},
+		"Unknown": {
+			reason: "A parent resource with an unknown policy should be observed.",
+			r:      &Reconciler{observer: observer},
+			cr:     parent("Delete"),
+			want:   observer,
+		},
+		"DryRun": {
+			reason: "Every parent resource should be observed in dry-run mode.",
+			r:      &Reconciler{observer: observer, dryRunner: dryRunner},
+			cr:     parent("Manage"),
+			want:   dryRunner,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.r.observerOf(tc.cr); got != tc.want {
+				t.Errorf("\n%s\nobserverOf(...): want %v, got %v", tc.reason, tc.want, got)
+			}
+		})
+	}
+}
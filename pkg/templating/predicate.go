@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ParentChanged returns a predicate that accepts the update events of the
+// parent resources only if they change the input of the rendering, i.e. the
+// spec, labels, annotations or the values of the given dot separated fields,
+// e.g. status.endpoint, or mark the parent resource for deletion. The other
+// updates of the status and the finalizers, which the reconciler makes
+// itself, are ignored and the drift is corrected by the periodic resync
+// instead. All other events are accepted.
+func ParentChanged(fields ...string) predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.MetaOld == nil || e.MetaNew == nil {
+				return true
+			}
+			if e.MetaNew.GetDeletionTimestamp() != nil {
+				return true
+			}
+			return !reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) ||
+				!reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations()) ||
+				!reflect.DeepEqual(specOf(e.ObjectOld), specOf(e.ObjectNew)) ||
+				!reflect.DeepEqual(fieldValuesOf(e.ObjectOld, fields), fieldValuesOf(e.ObjectNew, fields))
+		},
+	}
+}
+
+// specOf returns the spec of the given object if it has an unstructured
+// content. Otherwise, it returns the object itself so that any change is
+// considered a spec change.
+func specOf(o runtime.Object) interface{} {
+	u, ok := o.(interface{ UnstructuredContent() map[string]interface{} })
+	if !ok {
+		return o
+	}
+	return u.UnstructuredContent()["spec"]
+}
+
+// fieldValuesOf returns the values of the given dot separated fields of the given
+// object if it has an unstructured content. Otherwise, it returns the object
+// itself so that any change is considered a change of the fields.
+func fieldValuesOf(o runtime.Object, fields []string) interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	u, ok := o.(interface{ UnstructuredContent() map[string]interface{} })
+	if !ok {
+		return o
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		values[i], _, _ = unstructured.NestedFieldNoCopy(u.UnstructuredContent(), strings.Split(f, ".")...)
+	}
+	return values
+}
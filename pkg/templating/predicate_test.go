@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestParentChanged(t *testing.T) {
+	withSpec := func(spec map[string]interface{}) fake.MockResourceOption {
+		return func(r *fake.MockResource) {
+			r.Object["spec"] = spec
+		}
+	}
+	withStatus := func(status map[string]interface{}) fake.MockResourceOption {
+		return func(r *fake.MockResource) {
+			r.Object["status"] = status
+		}
+	}
+	cases := map[string]struct {
+		old    *fake.MockResource
+		new    *fake.MockResource
+		fields []string
+		want   bool
+	}{
+		"SpecChanged": {
+			old:  fake.NewMockResource(withSpec(map[string]interface{}{"replicas": int64(1)})),
+			new:  fake.NewMockResource(withSpec(map[string]interface{}{"replicas": int64(2)})),
+			want: true,
+		},
+		"AnnotationsChanged": {
+			old:  fake.NewMockResource(),
+			new:  fake.NewMockResource(fake.WithAdditionalAnnotations(map[string]string{ReconcileAtAnnotationKey: "now"})),
+			want: true,
+		},
+		"OnlyStatusChanged": {
+			old:  fake.NewMockResource(withSpec(map[string]interface{}{"replicas": int64(1)})),
+			new:  fake.NewMockResource(withSpec(map[string]interface{}{"replicas": int64(1)}), withStatus(map[string]interface{}{"ready": true})),
+			want: false,
+		},
+		"BoundStatusChanged": {
+			old:    fake.NewMockResource(withStatus(map[string]interface{}{"endpoint": "a", "ready": true})),
+			new:    fake.NewMockResource(withStatus(map[string]interface{}{"endpoint": "b", "ready": true})),
+			fields: []string{"status.endpoint"},
+			want:   true,
+		},
+		"UnboundStatusChanged": {
+			old:    fake.NewMockResource(withStatus(map[string]interface{}{"endpoint": "a", "ready": false})),
+			new:    fake.NewMockResource(withStatus(map[string]interface{}{"endpoint": "a", "ready": true})),
+			fields: []string{"status.endpoint"},
+			want:   false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := ParentChanged(tc.fields...).Update(event.UpdateEvent{MetaOld: tc.old, ObjectOld: tc.old, MetaNew: tc.new, ObjectNew: tc.new})
+			if got != tc.want {
+				t.Errorf("Update(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// AllowPreviewAnnotationKey is the annotation on the StackDefinition that
+	// allows the parent resources to request a preview with
+	// PreviewAnnotationKey when its value is "true".
+	AllowPreviewAnnotationKey = "templatestacks.crossplane.io/allow-preview"
+
+	// PreviewAnnotationKey is the annotation on the parent resource that
+	// requests a preview of the changes that the next reconciliation would
+	// make to the child resources. Any non-empty value, e.g. a timestamp,
+	// requests a preview. The annotation is removed once the preview is
+	// stored.
+	PreviewAnnotationKey = "templatestacks.crossplane.io/preview"
+
+	// PreviewConfigMapPrefix is the prefix of the name of the ConfigMap that
+	// keeps the last preview of a parent resource. It's followed by the UID
+	// of the parent resource.
+	PreviewConfigMapPrefix = "preview-"
+
+	// PreviewDataKey is the key in the data of the preview ConfigMap whose
+	// value is the YAML of the Preview.
+	PreviewDataKey = "preview.yaml"
+
+	errGetCurrent     = "cannot get the current child resource"
+	errConvertDesired = "cannot convert the desired child resource"
+	errMarshalPreview = "cannot marshal preview"
+	errStorePreview   = "cannot store preview"
+)
+
+// A ChangeOperation is what the next reconciliation would do to a child
+// resource.
+type ChangeOperation string
+
+// Change operations.
+const (
+	ChangeOperationCreate    ChangeOperation = "Create"
+	ChangeOperationUpdate    ChangeOperation = "Update"
+	ChangeOperationNone      ChangeOperation = "None"
+	ChangeOperationDelete    ChangeOperation = "Delete"
+	ChangeOperationSuspended ChangeOperation = "Suspended"
+	ChangeOperationFailed    ChangeOperation = "Failed"
+)
+
+// A ChildChange is the change that the next reconciliation would make to a
+// child resource. The fields are given as dot separated paths, and lists are
+// compared as a whole.
+type ChildChange struct {
+	ChildReference `json:",inline"`
+
+	// Operation that would be done.
+	Operation ChangeOperation `json:"operation"`
+
+	// Added are the paths of the fields that would be added.
+	Added []string `json:"added,omitempty"`
+
+	// Removed are the paths of the fields that would be removed.
+	Removed []string `json:"removed,omitempty"`
+
+	// Changed are the paths of the fields whose values would change.
+	Changed []string `json:"changed,omitempty"`
+
+	// Message is the error message if the dry-run apply failed.
+	Message string `json:"message,omitempty"`
+}
+
+// A Preview lists the changes that the next reconciliation of a parent
+// resource would make.
+type Preview struct {
+	// GeneratedAt is the time the preview is generated.
+	GeneratedAt metav1.Time `json:"generatedAt"`
+
+	// Changes of the child resources in the order they would be applied.
+	Changes []ChildChange `json:"changes"`
+}
+
+// ignoredFields are the fields that are set by the API server on every
+// write, so they are not reported as changes.
+var ignoredFields = map[string]bool{
+	"status":                     true,
+	"metadata.resourceVersion":   true,
+	"metadata.generation":        true,
+	"metadata.managedFields":     true,
+	"metadata.creationTimestamp": true,
+	"metadata.uid":               true,
+	"metadata.selfLink":          true,
+}
+
+// NewPreviewer returns a new *Previewer. The dry-run applies are done with
+// the given apply options, which should match the ones of the Applier of the
+// reconciler. The ConfigMaps of cluster-scoped parent resources are stored in
+// the given namespace.
+func NewPreviewer(c client.Client, namespace string, opts ...apply.Option) *Previewer {
+	return &Previewer{
+		client:    c,
+		dryRun:    apply.NewApplier(c, append(opts, apply.WithDryRun())...),
+		store:     apply.NewApplier(c),
+		namespace: namespace,
+	}
+}
+
+// A Previewer computes the changes that would be made to the child resources
+// with dry-run applies and stores them in a ConfigMap per parent resource.
+// The ConfigMap is owned by the parent resource so that it is garbage
+// collected with the parent.
+type Previewer struct {
+	client    client.Client
+	dryRun    *apply.Applier
+	store     *apply.Applier
+	namespace string
+}
+
+// Change returns the change that applying the given child resource would
+// make. The child resource is not modified.
+func (p *Previewer) Change(ctx context.Context, cr resource.ParentResource, o resource.ChildResource) ChildChange {
+	c := ChildChange{ChildReference: NewInventory([]resource.ChildResource{o})[0]}
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(o.GetObjectKind().GroupVersionKind())
+	err := p.client.Get(ctx, types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()}, current)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return failed(c, errors.Wrap(err, errGetCurrent))
+	}
+	desired, ok := o.DeepCopyObject().(resource.ChildResource)
+	if !ok {
+		return failed(c, errors.New(errConvertDesired))
+	}
+	op, aerr := applyChild(ctx, p.dryRun, cr, desired)
+	if aerr != nil {
+		return failed(c, aerr)
+	}
+	if op == ApplyOperationCreated {
+		c.Operation = ChangeOperationCreate
+		return c
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return failed(c, errors.Wrap(err, errConvertDesired))
+	}
+	c.Added, c.Removed, c.Changed = diffFields(current.Object, after)
+	c.Operation = ChangeOperationNone
+	if len(c.Added)+len(c.Removed)+len(c.Changed) != 0 {
+		c.Operation = ChangeOperationUpdate
+	}
+	return c
+}
+
+// Store stores the given changes as the last preview of the given parent
+// resource.
+func (p *Previewer) Store(ctx context.Context, cr resource.ParentResource, changes []ChildChange) error {
+	data, err := yaml.Marshal(Preview{GeneratedAt: metav1.Now(), Changes: changes})
+	if err != nil {
+		return errors.Wrap(err, errMarshalPreview)
+	}
+	ns := cr.GetNamespace()
+	if ns == "" {
+		ns = p.namespace
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: PreviewConfigMapPrefix + string(cr.GetUID()), Namespace: ns},
+		Data:       map[string]string{PreviewDataKey: string(data)},
+	}
+	// NOTE: A namespaced ConfigMap can be owned by a cluster-scoped parent.
+	meta.AddOwnerReference(cm, meta.AsOwner(meta.ReferenceTo(cr, cr.GroupVersionKind())))
+	_, err = p.store.Apply(ctx, cm)
+	return errors.Wrap(err, errStorePreview)
+}
+
+func failed(c ChildChange, err error) ChildChange {
+	c.Operation = ChangeOperationFailed
+	c.Message = truncateMessage(err.Error())
+	return c
+}
+
+// diffFields returns the paths of the fields that are added, removed and
+// changed from the given current object to the given desired one, in
+// lexical order.
+func diffFields(current, desired map[string]interface{}) (added, removed, changed []string) {
+	before, after := map[string]interface{}{}, map[string]interface{}{}
+	flatten(current, "", before)
+	flatten(desired, "", after)
+	for path, v := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			added = append(added, path)
+		case !reflect.DeepEqual(old, v):
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return added, removed, changed
+}
+
+// flatten writes the leaf fields of the given object, i.e. the fields that
+// are not objects, to the given map keyed by their paths. The ignoredFields
+// are skipped.
+func flatten(obj map[string]interface{}, prefix string, into map[string]interface{}) {
+	for k, v := range obj {
+		path := strings.TrimPrefix(prefix+"."+k, ".")
+		if ignoredFields[path] {
+			continue
+		}
+		if m, ok := v.(map[string]interface{}); ok && len(m) != 0 {
+			flatten(m, path, into)
+			continue
+		}
+		into[path] = v
+	}
+}
+
+// previewRequested returns true if a preview of the given parent resource is
+// requested via PreviewAnnotationKey annotation.
+func previewRequested(cr resource.ParentResource) bool {
+	return cr.GetAnnotations()[PreviewAnnotationKey] != ""
+}
+
+// preview stores the changes that applying the given child resources would
+// make as the last preview of the given parent resource.
+func (r *Reconciler) preview(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	return r.previewer.Store(ctx, cr, r.changes(ctx, r.previewer, cr, list))
+}
+
+// changes returns the changes that applying the given child resources would
+// make, computed with the given Previewer.
+func (r *Reconciler) changes(ctx context.Context, p *Previewer, cr resource.ParentResource, list []resource.ChildResource) []ChildChange {
+	changes := make([]ChildChange, len(list))
+	for i, o := range list {
+		if r.suspended(cr, o) {
+			changes[i] = ChildChange{ChildReference: NewInventory([]resource.ChildResource{o})[0], Operation: ChangeOperationSuspended}
+			continue
+		}
+		changes[i] = p.Change(ctx, cr, o)
+	}
+	return changes
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestPreviewerChange(t *testing.T) {
+	cr := fake.NewMockResource()
+	cr.SetUID(types.UID("parent"))
+	trueVal := true
+	child := func(rv string, owner types.UID, spec map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "cool", "namespace": "default"},
+			"data":       spec,
+		}}
+		u.SetResourceVersion(rv)
+		u.SetOwnerReferences([]metav1.OwnerReference{{UID: owner, Controller: &trueVal}})
+		return u
+	}
+	withObject := func(u *unstructured.Unstructured) test.ObjectFn {
+		return func(obj runtime.Object) error {
+			obj.(*unstructured.Unstructured).Object = u.DeepCopy().Object
+			return nil
+		}
+	}
+	dryRunOnly := func(t *testing.T) test.MockPatchFn {
+		return func(_ context.Context, _ runtime.Object, _ client.Patch, opts ...client.PatchOption) error {
+			o := &client.PatchOptions{}
+			o.ApplyOptions(opts)
+			if diff := cmp.Diff([]string{metav1.DryRunAll}, o.DryRun); diff != "" {
+				t.Errorf("Patch(...): -want dry run, +got dry run:\n%s", diff)
+			}
+			return nil
+		}
+	}
+	ref := ChildReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cool"}
+	cases := map[string]struct {
+		c       client.Client
+		desired *unstructured.Unstructured
+		want    ChildChange
+	}{
+		"Create": {
+			c: &test.MockClient{
+				MockGet:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "")),
+				MockCreate: test.NewMockCreateFn(nil),
+			},
+			desired: child("", "parent", map[string]interface{}{"a": "1"}),
+			want:    ChildChange{ChildReference: ref, Operation: ChangeOperationCreate},
+		},
+		"Update": {
+			c: &test.MockClient{
+				MockGet:   test.NewMockGetFn(nil, withObject(child("1", "parent", map[string]interface{}{"a": "1", "b": "2"}))),
+				MockPatch: dryRunOnly(t),
+			},
+			desired: child("", "parent", map[string]interface{}{"a": "3", "c": "4"}),
+			want: ChildChange{
+				ChildReference: ref,
+				Operation:      ChangeOperationUpdate,
+				Added:          []string{"data.c"},
+				Removed:        []string{"data.b"},
+				Changed:        []string{"data.a"},
+			},
+		},
+		"None": {
+			c: &test.MockClient{
+				MockGet:   test.NewMockGetFn(nil, withObject(child("1", "parent", map[string]interface{}{"a": "1"}))),
+				MockPatch: dryRunOnly(t),
+			},
+			desired: child("", "parent", map[string]interface{}{"a": "1"}),
+			want:    ChildChange{ChildReference: ref, Operation: ChangeOperationNone},
+		},
+		"ControlledByOther": {
+			c: &test.MockClient{
+				MockGet: test.NewMockGetFn(nil, withObject(child("1", "other", map[string]interface{}{"a": "1"}))),
+			},
+			desired: child("", "parent", map[string]interface{}{"a": "1"}),
+			want: ChildChange{
+				ChildReference: ref,
+				Operation:      ChangeOperationFailed,
+				Message:        `existing object is not controlled by UID "parent"`,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			desired := tc.desired.DeepCopy()
+			got := NewPreviewer(tc.c, "").Change(context.Background(), cr, desired)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Change(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.desired, desired); diff != "" {
+				t.Errorf("Change(...): the desired child resource should not be modified:\n%s", diff)
+			}
+		})
+	}
+}
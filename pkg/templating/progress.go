@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// applyDeadlineMargin is the time before the deadline of the reconciliation
+// at which the apply stops, so that there is time left to persist its
+// progress.
+const applyDeadlineMargin = 10 * time.Second
+
+const msgApplyInProgress = "reconciliation deadline is near, the remaining child resources are applied in the next reconciliation"
+
+// ApplyProgress is the progress of an apply that stopped before the deadline
+// of its reconciliation. It's reported in the status.applyProgress field of
+// the parent resource, and the next reconciliation resumes the apply from
+// where it stopped if the child resources didn't change, so that the child
+// resources at the end of a long list are applied eventually even if the
+// whole list cannot be applied in a single reconciliation.
+type ApplyProgress struct {
+	// RenderHash is the hash of the child resources that are being applied.
+	RenderHash string `json:"renderHash"`
+
+	// Applied is the number of child resources, in the order they are
+	// applied, whose apply is complete.
+	Applied int `json:"applied"`
+}
+
+// applyProgressOf returns the ApplyProgress in the status.applyProgress field
+// of the given parent resource and true if it has one.
+func applyProgressOf(cr resource.ParentResource) (ApplyProgress, bool) {
+	m, ok, err := unstructured.NestedMap(cr.UnstructuredContent(), "status", "applyProgress")
+	if err != nil || !ok {
+		return ApplyProgress{}, false
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return ApplyProgress{}, false
+	}
+	p := ApplyProgress{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return ApplyProgress{}, false
+	}
+	return p, true
+}
+
+// SetApplyProgress sets the status.applyProgress field of the given parent
+// resource.
+func SetApplyProgress(cr interface{ UnstructuredContent() map[string]interface{} }, p ApplyProgress) error {
+	return unstructured.SetNestedMap(cr.UnstructuredContent(), map[string]interface{}{
+		"renderHash": p.RenderHash,
+		"applied":    int64(p.Applied),
+	}, "status", "applyProgress")
+}
+
+// resumeFrom returns the hash of the given child resources and the index of
+// the first one that the apply should start from, i.e. the one after the last
+// child resource that the previous reconciliation applied if it stopped
+// before its deadline and the child resources didn't change since.
+func resumeFrom(cr resource.ParentResource, list []resource.ChildResource) (string, int) {
+	hash, err := resource.HashChildren(list)
+	if err != nil {
+		return "", 0
+	}
+	p, ok := applyProgressOf(cr)
+	if !ok || p.RenderHash != hash || p.Applied < 0 || p.Applied > len(list) {
+		return hash, 0
+	}
+	return hash, p.Applied
+}
+
+// previousApplyResults returns the results in the status.applyResults field
+// of the given parent resource by the references of their child resources.
+func previousApplyResults(cr resource.ParentResource) map[ChildReference]ApplyResult {
+	list, ok, err := unstructured.NestedSlice(cr.UnstructuredContent(), "status", "applyResults")
+	if err != nil || !ok {
+		return nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil
+	}
+	var results []ApplyResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil
+	}
+	m := make(map[ChildReference]ApplyResult, len(results))
+	for _, r := range results {
+		m[r.ChildReference] = r
+	}
+	return m
+}
+
+// deadlineNear returns true if the deadline of the given context is closer
+// than applyDeadlineMargin.
+func deadlineNear(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Until(deadline) < applyDeadlineMargin
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestResumeFrom(t *testing.T) {
+	a, b := fake.NewMockResource(), fake.NewMockResource()
+	a.SetName("a")
+	b.SetName("b")
+	list := []resource.ChildResource{a, b}
+	hash, _ := resource.HashChildren(list)
+
+	cases := map[string]struct {
+		reason   string
+		progress *ApplyProgress
+		want     int
+	}{
+		"NoProgress": {
+			reason: "The apply should start from the first child resource if the previous one was complete.",
+			want:   0,
+		},
+		"Resumed": {
+			reason:   "The apply should resume after the child resources that the previous reconciliation applied.",
+			progress: &ApplyProgress{RenderHash: hash, Applied: 1},
+			want:     1,
+		},
+		"Changed": {
+			reason:   "The apply should start over if the child resources changed.",
+			progress: &ApplyProgress{RenderHash: "other", Applied: 1},
+			want:     0,
+		},
+		"OutOfRange": {
+			reason:   "The apply should start over if the progress is beyond the child resources.",
+			progress: &ApplyProgress{RenderHash: hash, Applied: 3},
+			want:     0,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := fake.NewMockResource()
+			if tc.progress != nil {
+				_ = SetApplyProgress(cr, *tc.progress)
+			}
+			gotHash, got := resumeFrom(cr, list)
+			if diff := cmp.Diff(hash, gotHash); diff != "" {
+				t.Errorf("\n%s\nresumeFrom(...): -want hash, +got hash:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nresumeFrom(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestDeadlineNear(t *testing.T) {
+	cases := map[string]struct {
+		reason  string
+		timeout time.Duration
+		want    bool
+	}{
+		"Near": {
+			reason:  "A deadline closer than the margin should be near.",
+			timeout: applyDeadlineMargin / 2,
+			want:    true,
+		},
+		"Far": {
+			reason:  "A deadline further than the margin should not be near.",
+			timeout: 2 * applyDeadlineMargin,
+			want:    false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			if diff := cmp.Diff(tc.want, deadlineNear(ctx)); diff != "" {
+				t.Errorf("\n%s\ndeadlineNear(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
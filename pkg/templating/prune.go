@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// UnknownFieldsAnnotationKey is the annotation on the StackDefinition that
+// configures how the fields of the parent resource spec that are not declared
+// in its schema are handled.
+const UnknownFieldsAnnotationKey = "templatestacks.crossplane.io/unknown-fields"
+
+// Values of UnknownFieldsAnnotationKey annotation.
+const (
+	// UnknownFieldsOff passes the spec to the engine as is. It's the default.
+	UnknownFieldsOff = "off"
+
+	// UnknownFieldsWarn prunes the unknown fields and reports them in the
+	// UnknownFields condition.
+	UnknownFieldsWarn = "warn"
+
+	// UnknownFieldsStrict refuses to render the parent resources that have
+	// unknown fields.
+	UnknownFieldsStrict = "strict"
+)
+
+// TypeUnknownFields indicates whether the spec of the parent resource has
+// fields that are not declared in its schema.
+const TypeUnknownFields v1alpha1.ConditionType = "UnknownFields"
+
+// Reasons a parent resource does or does not have unknown fields.
+const (
+	ReasonUnknownFields   v1alpha1.ConditionReason = "Spec has fields that are not declared in the schema"
+	ReasonNoUnknownFields v1alpha1.ConditionReason = "All fields of spec are declared in the schema"
+)
+
+const errFmtUnknownFields = "unknown fields in spec: %s"
+
+// A PruneFunc removes the fields of the given spec that are not declared in
+// the schema of the parent resource and returns their paths relative to spec.
+type PruneFunc func(spec map[string]interface{}) []string
+
+// UnknownFields returns a condition that indicates the spec of the parent
+// resource has unknown fields.
+func UnknownFields(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeUnknownFields,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnknownFields,
+		Message:            err.Error(),
+	}
+}
+
+// NoUnknownFields returns a condition that indicates the spec of the parent
+// resource has no unknown fields.
+func NoUnknownFields() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeUnknownFields,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoUnknownFields,
+	}
+}
+
+// pruneUnknownFields returns a copy of the given parent resource whose spec
+// is pruned by the given PruneFunc and sets the UnknownFields condition of
+// the given parent resource. In strict mode, a resource.ValuesError is
+// returned if there are unknown fields.
+func pruneUnknownFields(cr resource.ParentResource, prune PruneFunc, strict bool) (resource.ParentResource, error) {
+	cp, ok := cr.DeepCopyObject().(resource.ParentResource)
+	if !ok {
+		return nil, errors.New(errCopyParent)
+	}
+	s, ok := cp.UnstructuredContent()["spec"]
+	if !ok {
+		return cp, nil
+	}
+	spec, ok := s.(map[string]interface{})
+	if !ok {
+		return nil, &resource.ValuesError{Path: "spec", Err: errors.New(errSpecNotObject)}
+	}
+	unknown := prune(spec)
+	if len(unknown) == 0 {
+		if c, err := resource.GetCondition(cr, TypeUnknownFields); err == nil && c.Status == corev1.ConditionTrue {
+			return cp, resource.SetConditions(cr, NoUnknownFields())
+		}
+		return cp, nil
+	}
+	err := errors.Errorf(errFmtUnknownFields, strings.Join(unknown, ", "))
+	if cerr := resource.SetConditions(cr, UnknownFields(err)); cerr != nil {
+		return nil, cerr
+	}
+	if strict {
+		return nil, &resource.ValuesError{Path: "spec." + unknown[0], Err: err}
+	}
+	return cp, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestPruneUnknownFields(t *testing.T) {
+	prune := func(spec map[string]interface{}) []string {
+		if _, ok := spec["replcias"]; !ok {
+			return nil
+		}
+		delete(spec, "replcias")
+		return []string{"replcias"}
+	}
+	withSpec := func(spec map[string]interface{}) *fake.MockResource {
+		cr := fake.NewMockResource()
+		cr.Object["spec"] = spec
+		return cr
+	}
+	type want struct {
+		spec      map[string]interface{}
+		condition corev1.ConditionStatus
+		err       error
+	}
+	cases := map[string]struct {
+		reason string
+		cr     resource.ParentResource
+		strict bool
+		want   want
+	}{
+		"Known": {
+			reason: "The spec should be passed as is if it has no unknown fields",
+			cr:     withSpec(map[string]interface{}{"replicas": int64(3)}),
+			want: want{
+				spec:      map[string]interface{}{"replicas": int64(3)},
+				condition: corev1.ConditionUnknown,
+			},
+		},
+		"Warn": {
+			reason: "The unknown fields should be pruned and reported in the condition",
+			cr:     withSpec(map[string]interface{}{"replicas": int64(3), "replcias": int64(3)}),
+			want: want{
+				spec:      map[string]interface{}{"replicas": int64(3)},
+				condition: corev1.ConditionTrue,
+			},
+		},
+		"Strict": {
+			reason: "An error should be returned if there are unknown fields in strict mode",
+			cr:     withSpec(map[string]interface{}{"replcias": int64(3)}),
+			strict: true,
+			want: want{
+				condition: corev1.ConditionTrue,
+				err:       &resource.ValuesError{Path: "spec.replcias", Err: errors.Errorf(errFmtUnknownFields, "replcias")},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := pruneUnknownFields(tc.cr, prune, tc.strict)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\npruneUnknownFields(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if got != nil {
+				if diff := cmp.Diff(tc.want.spec, got.UnstructuredContent()["spec"]); diff != "" {
+					t.Errorf("\n%s\npruneUnknownFields(...): -want, +got:\n%s", tc.reason, diff)
+				}
+			}
+			c, _ := resource.GetCondition(tc.cr, TypeUnknownFields)
+			if diff := cmp.Diff(tc.want.condition, c.Status); diff != "" {
+				t.Errorf("\n%s\npruneUnknownFields(...): -want condition, +got condition:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
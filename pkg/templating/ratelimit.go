@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// NewParentRateLimiter returns a new *ParentRateLimiter whose backoff starts
+// with the given base delay and doubles with every consecutive failure up to
+// the given maximum delay.
+func NewParentRateLimiter(base, max time.Duration) *ParentRateLimiter {
+	return &ParentRateLimiter{
+		limiter: workqueue.NewItemExponentialFailureRateLimiter(base, max),
+		backoff: map[types.NamespacedName]backoff{},
+		now:     time.Now,
+	}
+}
+
+// A ParentRateLimiter limits the rate of the reconciliations of every parent
+// resource independently of the others. A parent resource whose
+// reconciliations fail backs off exponentially, and the reconciliations that
+// are triggered before its backoff is over, e.g. by the changes of its child
+// resources, are skipped. So a parent resource that fails in every
+// reconciliation cannot keep the workers busy and starve the healthy ones.
+// A change of the generation of the parent resource ends its backoff so that
+// a fix is reconciled right away.
+type ParentRateLimiter struct {
+	limiter workqueue.RateLimiter
+	now     func() time.Time
+
+	mu      sync.Mutex
+	backoff map[types.NamespacedName]backoff
+}
+
+type backoff struct {
+	until      time.Time
+	generation int64
+}
+
+// Wait returns how long the given parent resource has to wait before it can
+// be reconciled, and whether it has to wait at all.
+func (l *ParentRateLimiter) Wait(cr resource.ParentResource) (time.Duration, bool) {
+	key := keyOf(cr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.backoff[key]
+	if !ok {
+		return 0, false
+	}
+	if b.generation != cr.GetGeneration() {
+		l.forget(key)
+		return 0, false
+	}
+	wait := b.until.Sub(l.now())
+	return wait, wait > 0
+}
+
+// Failed records a failed reconciliation of the given parent resource and
+// returns how long it has to wait before its next reconciliation.
+func (l *ParentRateLimiter) Failed(cr resource.ParentResource) time.Duration {
+	key := keyOf(cr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	wait := l.limiter.When(key)
+	l.backoff[key] = backoff{until: l.now().Add(wait), generation: cr.GetGeneration()}
+	return wait
+}
+
+// Succeeded records a successful reconciliation of the given parent
+// resource, which ends its backoff.
+func (l *ParentRateLimiter) Succeeded(cr resource.ParentResource) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.forget(keyOf(cr))
+}
+
+// Len returns the number of parent resources that are backing off.
+func (l *ParentRateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.backoff)
+}
+
+func (l *ParentRateLimiter) forget(key types.NamespacedName) {
+	l.limiter.Forget(key)
+	delete(l.backoff, key)
+}
+
+func keyOf(cr resource.ParentResource) types.NamespacedName {
+	return types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}
+}
+
+// synced returns true if the Synced condition of the given parent resource is
+// true.
+func synced(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, v1alpha1.TypeSynced)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
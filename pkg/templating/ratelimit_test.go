@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestParentRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewParentRateLimiter(time.Second, 3*time.Second)
+	l.now = func() time.Time { return now }
+
+	failing, healthy := fake.NewMockResource(), fake.NewMockResource()
+	failing.SetName("failing")
+	healthy.SetName("healthy")
+
+	// The backoff doubles with every consecutive failure up to the maximum.
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if diff := cmp.Diff(want, l.Failed(failing)); diff != "" {
+			t.Errorf("Failed(...) #%d: -want, +got:\n%s", i+1, diff)
+		}
+	}
+	if wait, ok := l.Wait(failing); !ok || wait != 3*time.Second {
+		t.Errorf("Wait(...): want a wait of %s, got %s", 3*time.Second, wait)
+	}
+	if _, ok := l.Wait(healthy); ok {
+		t.Errorf("Wait(...): a parent resource that did not fail should not wait")
+	}
+
+	// The backoff is over once its time has passed.
+	now = now.Add(3 * time.Second)
+	if _, ok := l.Wait(failing); ok {
+		t.Errorf("Wait(...): a parent resource should not wait after its backoff is over")
+	}
+
+	// A new generation ends the backoff.
+	l.Failed(failing)
+	failing.SetGeneration(failing.GetGeneration() + 1)
+	if _, ok := l.Wait(failing); ok {
+		t.Errorf("Wait(...): a parent resource should not wait after its generation changed")
+	}
+	if diff := cmp.Diff(time.Second, l.Failed(failing)); diff != "" {
+		t.Errorf("Failed(...): the backoff of a new generation should start over: -want, +got:\n%s", diff)
+	}
+
+	// A success ends the backoff.
+	l.Succeeded(failing)
+	if diff := cmp.Diff(0, l.Len()); diff != "" {
+		t.Errorf("Succeeded(...): -want backing off, +got backing off:\n%s", diff)
+	}
+}
@@ -18,10 +18,13 @@ package templating
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -30,10 +33,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	rresource "github.com/crossplane/crossplane-runtime/pkg/resource"
 
+	"github.com/crossplane/templating-controller/pkg/apply"
 	"github.com/crossplane/templating-controller/pkg/resource"
 )
 
@@ -44,9 +49,9 @@ const (
 	// need this tinyWait.
 	tinyWait = 1 * time.Second
 
-	defaultShortWait = 30 * time.Second
-	defaultLongWait  = 1 * time.Minute
-	finalizer        = "templating-controller.crossplane.io"
+	defaultShortWait      = 30 * time.Second
+	defaultResyncInterval = 1 * time.Minute
+	finalizer             = "templating-controller.crossplane.io"
 
 	errUpdateResourceStatus  = "could not update status of the parent resource"
 	errGetResource           = "could not get the parent resource"
@@ -57,6 +62,10 @@ const (
 	errRemoveFinalizer       = "cannot remove finalizer from parent resource"
 	errApply                 = "apply failed"
 	errGetChildResource      = "could not get child resource"
+	errLastKnownGood         = "rendering failed, maintaining the last known good child resources"
+	errClearRefresh          = "cannot remove the reconcile-at annotation from parent resource"
+	errPreview               = "cannot preview the changes to the child resources"
+	errClearPreview          = "cannot remove the preview annotation from parent resource"
 
 	msgWaitingForDeletion = "waiting for deletion of child resources"
 )
@@ -89,6 +98,14 @@ func WithChildResourcePatcher(op ...ChildResourcePatcher) ReconcilerOption {
 	}
 }
 
+// WithAdditionalChildResourcePatcher returns a ReconcilerOption that appends
+// given ChildResourcePatchers to the existing ones.
+func WithAdditionalChildResourcePatcher(op ...ChildResourcePatcher) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.children.ChildResourcePatcherChain = append(reconciler.children.ChildResourcePatcherChain, op...)
+	}
+}
+
 // WithEngine returns a ReconcilerOption that changes the
 // templating engine.
 func WithEngine(eng Engine) ReconcilerOption {
@@ -109,9 +126,288 @@ func WithShortWait(d time.Duration) ReconcilerOption {
 // WithLongWait returns a ReconcilerOption that changes the wait
 // duration that determines after how much time another reconcile should be triggered
 // after a successful pass.
+//
+// Deprecated: Use WithResyncInterval.
 func WithLongWait(d time.Duration) ReconcilerOption {
+	return WithResyncInterval(d)
+}
+
+// WithResyncInterval returns a ReconcilerOption that changes the interval of
+// the periodic re-render and apply that corrects the drift of the child
+// resources. It is independent of the reaction to the changes of the parent
+// resource, which are reconciled as soon as they are observed.
+func WithResyncInterval(d time.Duration) ReconcilerOption {
 	return func(reconciler *Reconciler) {
-		reconciler.longWait = d
+		reconciler.resyncInterval = d
+	}
+}
+
+// WithoutChildResourceSorting returns a ReconcilerOption that removes the
+// ChildResourceSorter from the ChildResourcePatchers so that the child
+// resources are applied in the order in which they're rendered.
+func WithoutChildResourceSorting() ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		chain := ChildResourcePatcherChain{}
+		for _, p := range reconciler.children.ChildResourcePatcherChain {
+			if _, ok := p.(ChildResourceSorter); !ok {
+				chain = append(chain, p)
+			}
+		}
+		reconciler.children.ChildResourcePatcherChain = chain
+	}
+}
+
+// WithJitter returns a ReconcilerOption that randomly spreads the short wait
+// and the resync interval of every parent resource by up to the given
+// fraction of them so that the parent resources created at the same time are
+// not requeued at the same time. The fraction is clamped to [0, 1) so that
+// the requeues are never immediate.
+func WithJitter(fraction float64) ReconcilerOption {
+	switch {
+	case !(fraction > 0):
+		// NOTE: NaN is clamped to 0 as well.
+		fraction = 0
+	case fraction >= 1:
+		fraction = math.Nextafter(1, 0)
+	}
+	return func(reconciler *Reconciler) {
+		reconciler.jitter = fraction
+	}
+}
+
+// WithRenderStore returns a ReconcilerOption that makes the reconciler
+// store the last successfully rendered and patched child resources in the
+// given RenderStore and keep applying them while the rendering fails.
+func WithRenderStore(s RenderStore) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.lastKnownGood = s
+	}
+}
+
+// WithRenderCache returns a ReconcilerOption that makes the reconciler
+// record every successful reconciliation in the given RenderCache and skip
+// rendering and applying the parent resources that have not changed since
+// their last reconciliation within the resync interval, such as right after
+// a restart of the controller. The revision of the template source is part
+// of the recorded input.
+func WithRenderCache(c RenderCache, revision string) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.cache = c
+		reconciler.revision = revision
+	}
+}
+
+// WithRenderResultCache returns a ReconcilerOption that makes the reconciler
+// keep the child resources rendered by the templating engine in the given
+// RenderResultCache and reuse them as long as the parent resource, the given
+// revision of the template source and the other inputs of the engine do not
+// change. Unlike WithRenderCache, the child resources are still patched and
+// applied in every reconciliation.
+func WithRenderResultCache(c *RenderResultCache, revision string) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.results = c
+		reconciler.revision = revision
+	}
+}
+
+// WithUnknownFieldPruning returns a ReconcilerOption that makes the
+// reconciler prune the fields of the parent resource spec that are not
+// declared in its schema before rendering and report them in the
+// UnknownFields condition. In strict mode, the parent resources with unknown
+// fields are not rendered.
+func WithUnknownFieldPruning(p PruneFunc, strict bool) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.prune = p
+		reconciler.strictPruning = strict
+	}
+}
+
+// WithSuspendedKinds returns a ReconcilerOption that makes the reconciler
+// render the child resources of the given kinds but neither apply nor delete
+// them. They are reported with ApplyOperationSuspended in the apply results.
+func WithSuspendedKinds(gk ...schema.GroupKind) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.suspendedKinds = gk
+	}
+}
+
+// WithPermissionChecker returns a ReconcilerOption that makes the reconciler
+// check whether the controller is permitted to manage the child resources
+// before applying them. The missing permissions are reported at once in the
+// status.missingPermissions field and the Synced condition of the parent
+// resource instead of failing the apply one child resource at a time. If
+// hints is true, an event with the Role or ClusterRole that grants the
+// missing permissions is emitted as well.
+func WithPermissionChecker(pc PermissionChecker, hints bool) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.permissions = pc
+		reconciler.roleHints = hints
+	}
+}
+
+// WithNamespaceChecker returns a ReconcilerOption that makes the reconciler
+// check whether the namespaces of the child resources exist before applying
+// them. The missing namespaces and the child resources that need them are
+// reported in the NamespacesMissing condition of the parent resource instead
+// of failing the apply of the first child resource with a NotFound error.
+func WithNamespaceChecker(nc NamespaceChecker) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.namespaces = nc
+	}
+}
+
+// WithEscalationChecker returns a ReconcilerOption that makes the reconciler
+// check whether the Roles, ClusterRoles and their bindings among the child
+// resources grant only the permissions that the controller holds before
+// applying them. The child resources that grant more are reported in the
+// PrivilegeEscalation condition of the parent resource and none of the child
+// resources are applied.
+func WithEscalationChecker(ec EscalationChecker) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.escalations = ec
+	}
+}
+
+// WithLinter returns a ReconcilerOption that makes the reconciler check the
+// rendered and patched child resources with the given Linter and report the
+// violations in the LintViolations condition of the parent resource. The
+// child resources with violations of error severity are not applied.
+func WithLinter(l Linter) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.linter = l
+	}
+}
+
+// WithOutputLimits returns a ReconcilerOption that makes the reconciler
+// refuse to patch and apply the rendered child resources that exceed the
+// given limits.
+func WithOutputLimits(l OutputLimits) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.outputLimits = &l
+	}
+}
+
+// WithSchemaValidator returns a ReconcilerOption that makes the reconciler
+// check the rendered and patched child resources with the given
+// SchemaValidator before applying them. The fields that do not conform to
+// their schemas are reported in the Synced condition of the parent resource
+// and none of the child resources are applied.
+func WithSchemaValidator(v SchemaValidator) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.schemas = v
+	}
+}
+
+// WithStatusWriter returns a ReconcilerOption that changes how the status of
+// the parent resources is written, e.g. for parent resources with a bespoke
+// status schema.
+func WithStatusWriter(w StatusWriter) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.status = w
+	}
+}
+
+// WithPreviewer returns a ReconcilerOption that allows the parent resources
+// to request a preview of the changes that the next reconciliation would make
+// with the PreviewAnnotationKey annotation. A requested preview is computed
+// with dry-run applies and stored with the given Previewer instead of
+// applying the child resources, and then the annotation is removed.
+func WithPreviewer(p *Previewer) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.previewer = p
+	}
+}
+
+// WithDryRun returns a ReconcilerOption that makes the reconciler only
+// observe the parent resources. The child resources are rendered and the
+// changes that applying or deleting them would make are computed with the
+// dry-run applies of the given Previewer and reported in the status and
+// events of the parent resource, but nothing is created, patched or deleted,
+// including the finalizer of the parent resource.
+func WithDryRun(p *Previewer) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.dryRunner = p
+	}
+}
+
+// WithInformerClient returns a ReconcilerOption that reports the kinds of
+// the rendered child resources to the given InformerClient so that it can
+// stop the informers of the kinds that are no longer rendered. The Applier
+// has to be built with the same client for the child resources to be read
+// from the informers.
+func WithInformerClient(c *InformerClient) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.informers = c
+	}
+}
+
+// WithParentRateLimiter returns a ReconcilerOption that backs off the
+// reconciliations of every parent resource that fails independently of the
+// other parent resources.
+func WithParentRateLimiter(l *ParentRateLimiter) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.limiter = l
+	}
+}
+
+// WithRollout returns a ReconcilerOption that makes the reconciler upgrade
+// the parent resources to a new revision of the template source gradually,
+// as the given Rollout admits them. The parent resources that are waiting are
+// reported in the RolloutPending condition and reconciled again after the
+// short wait.
+func WithRollout(ro *Rollout) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.rollout = ro
+	}
+}
+
+// WithTimingMetrics returns a ReconcilerOption that records the durations of
+// the phases of every reconciliation in the given TimingMetrics.
+func WithTimingMetrics(m *TimingMetrics) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.timingMetrics = m
+	}
+}
+
+// WithTimingsReport returns a ReconcilerOption that reports the durations of
+// the phases of the last reconciliation and the number of the rendered child
+// resources in the status.timings field of the parent resource.
+func WithTimingsReport() ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.reportTimings = true
+	}
+}
+
+// WithInputFields returns a ReconcilerOption that considers the given dot
+// separated fields of the parent resource outside of its spec, e.g.
+// status.endpoint, as an input of the rendering so that the render cache is
+// invalidated when their values change.
+func WithInputFields(fields ...string) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.inputFields = fields
+	}
+}
+
+// WithHealthTracker returns a ReconcilerOption that records the outcome of
+// every reconciliation in the given HealthTracker.
+func WithHealthTracker(h *HealthTracker) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.health = h
+	}
+}
+
+// WithApplier returns a ReconcilerOption that changes how the child resources
+// are created and patched, e.g. with server-side apply.
+func WithApplier(a *apply.Applier) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.applier = a
+	}
+}
+
+// WithRecorder returns a ReconcilerOption that changes the event recorder.
+func WithRecorder(er event.Recorder) ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.recorder = er
 	}
 }
 
@@ -122,16 +418,24 @@ func WithLogger(l logging.Logger) ReconcilerOption {
 	}
 }
 
+// DefaultChildResourcePatchers returns the ChildResourcePatchers that the
+// reconciler runs on the rendered child resources unless they are replaced
+// with WithChildResourcePatcher.
+func DefaultChildResourcePatchers() ChildResourcePatcherChain {
+	return ChildResourcePatcherChain{
+		NewOwnerReferenceAdder(),
+		NewDefaultingAnnotationRemover(),
+		NewNamespacePatcher(),
+		NewLabelPropagator(),
+		NewParentLabelSetAdder(),
+		NewChildResourceSorter(),
+	}
+}
+
 func defaultCRChildren(c client.Client) crChildren {
 	return crChildren{
-		ChildResourcePatcherChain: ChildResourcePatcherChain{
-			NewOwnerReferenceAdder(),
-			NewDefaultingAnnotationRemover(),
-			NewNamespacePatcher(),
-			NewLabelPropagator(),
-			NewParentLabelSetAdder(),
-		},
-		ChildResourceDeleter: NewAPIOrderedDeleter(c),
+		ChildResourcePatcherChain: DefaultChildResourcePatchers(),
+		ChildResourceDeleter:      NewCRDDeletionGuard(c, NewAPIOrderedDeleter(c)),
 	}
 }
 
@@ -150,17 +454,17 @@ func NewReconciler(m manager.Manager, of schema.GroupVersionKind, options ...Rec
 	}
 
 	r := &Reconciler{
-		client: rresource.ClientApplicator{
-			Client:     m.GetClient(),
-			Applicator: rresource.NewAPIPatchingApplicator(m.GetClient()),
-		},
+		client:            m.GetClient(),
+		applier:           apply.NewApplier(m.GetClient()),
 		newParentResource: nr,
 		shortWait:         defaultShortWait,
-		longWait:          defaultLongWait,
+		resyncInterval:    defaultResyncInterval,
 		log:               logging.NewNopLogger(),
+		recorder:          event.NewNopRecorder(),
 		templating:        &NopEngine{},
 		finalizer:         rresource.NewAPIFinalizer(m.GetClient(), finalizer),
 		children:          defaultCRChildren(m.GetClient()),
+		status:            NewAPIStatusWriter(m.GetClient()),
 	}
 
 	for _, opt := range options {
@@ -172,85 +476,538 @@ func NewReconciler(m manager.Manager, of schema.GroupVersionKind, options ...Rec
 // Reconciler is used to reconcile an arbitrary CRD whose GroupVersionKind
 // is supplied.
 type Reconciler struct {
-	client            rresource.ClientApplicator
+	client            client.Client
+	applier           *apply.Applier
 	newParentResource func() resource.ParentResource
 	shortWait         time.Duration
-	longWait          time.Duration
+	resyncInterval    time.Duration
+	jitter            float64
 	log               logging.Logger
-
-	templating Engine
-	finalizer  rresource.Finalizer
-	children   crChildren
+	recorder          event.Recorder
+
+	templating     Engine
+	finalizer      rresource.Finalizer
+	children       crChildren
+	lastKnownGood  RenderStore
+	cache          RenderCache
+	results        *RenderResultCache
+	revision       string
+	prune          PruneFunc
+	strictPruning  bool
+	suspendedKinds []schema.GroupKind
+	permissions    PermissionChecker
+	roleHints      bool
+	namespaces     NamespaceChecker
+	escalations    EscalationChecker
+	outputLimits   *OutputLimits
+	linter         Linter
+	schemas        SchemaValidator
+	status         StatusWriter
+	previewer      *Previewer
+	dryRunner      *Previewer
+	observer       *Previewer
+	informers      *InformerClient
+	limiter        *ParentRateLimiter
+	rollout        *Rollout
+	health         *HealthTracker
+	hooks          hooks
+	reportSources  bool
+	timingMetrics  *TimingMetrics
+	reportTimings  bool
+	inputFields    []string
 }
 
 // Reconcile is called by controller-runtime for reconciliation.
-func (r *Reconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) { // nolint:gocyclo
+func (r *Reconciler) Reconcile(req ctrl.Request) (result ctrl.Result, err error) { // nolint:gocyclo
 	// NOTE(muvaf): This method is well over our cyclomatic complexity goal.
 	// Be wary of adding additional complexity.
 
 	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
 	defer cancel()
+	if r.timingMetrics != nil || r.reportTimings {
+		ctx = withStopwatch(ctx)
+	}
 	log := r.log.WithValues("parent-resource", req)
 
 	cr := r.newParentResource()
+	cr.SetNamespace(req.Namespace)
+	cr.SetName(req.Name)
+	if err := r.hooks.before(ctx, PhaseFetch, cr, nil); err != nil {
+		log.Info("Cannot get the requested resource", "error", err)
+		return reconcile.Result{}, err
+	}
 	if err := r.client.Get(ctx, req.NamespacedName, cr); err != nil {
 		// There's no need to requeue if the resource no longer exists. Otherwise
 		// we'll be requeued implicitly because we return an error.
 		log.Info("Cannot get the requested resource", "error", err)
 		return reconcile.Result{Requeue: false}, errors.Wrap(client.IgnoreNotFound(err), errGetResource)
 	}
+	if err := r.hooks.after(ctx, PhaseFetch, cr, nil); err != nil {
+		log.Info("Cannot get the requested resource", "error", err)
+		return reconcile.Result{}, err
+	}
+	if r.limiter != nil {
+		if wait, ok := r.limiter.Wait(cr); ok && !refreshRequested(cr) {
+			log.Debug("Parent resource is backing off after failed reconciliations, skipping", "wait", wait)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+		defer func() { result = r.backoff(cr, result, err) }()
+	}
 
-	childResources, err := r.templating.Run(cr)
-	if err != nil {
-		log.Info("Cannot run templating operation", "error", err)
-		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errTemplatingOperation))))
-		return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+	if r.rollout != nil && !meta.WasDeleted(cr) {
+		if err := r.rollout.Admit(cr); err != nil {
+			log.Debug("Parent resource is waiting for the rollout of the template revision", "reason", err)
+			omitError(log, resource.SetConditions(cr, RolloutPending(err)))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+		}
+		if rolloutPending(cr) {
+			omitError(log, resource.SetConditions(cr, RolloutAdmitted()))
+		}
+		defer func() { r.rollout.Observe(cr, err == nil && synced(cr)) }()
+	}
+	if r.health != nil {
+		defer func() {
+			// NOTE: A deleted parent resource is forgotten once its deletion
+			// completes, so only the failures of the deletion are recorded.
+			if meta.WasDeleted(cr) && err == nil {
+				return
+			}
+			r.health.Observe(cr, err == nil && synced(cr))
+		}()
 	}
 
-	childResources, err = r.children.Patch(cr, childResources)
-	if err != nil {
-		log.Info("Cannot run patchers on the child resources", "error", err)
-		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errChildResourcePatchers))))
-		return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+	observed := Observation{Generation: cr.GetGeneration(), Revision: r.revision}
+	if wait, ok := r.unchanged(ctx, cr); ok {
+		log.Debug("Parent resource is unchanged since the last reconciliation, skipping")
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	if r.reportSources {
+		omitError(log, resource.SetConditions(cr, ValuesResolved(valuesSourcesOf(r.templating, cr))))
+	}
+	childResources, renderErr := r.render(ctx, cr)
+	switch {
+	case IsUnsupportedEngine(renderErr):
+		omitError(log, resource.SetConditions(cr, UnsupportedEngine(renderErr)))
+	case renderErr == nil && unsupportedEngine(cr):
+		omitError(log, resource.SetConditions(cr, SupportedEngine()))
+	}
+	if renderErr != nil {
+		log.Info("Cannot render the child resources", "error", renderErr)
+		lastGood, err := r.getLastKnownGood(ctx, cr)
+		if err != nil || len(lastGood) == 0 {
+			omitError(log, err)
+			r.recorder.Event(cr, renderFailedEvent(renderErr))
+			omitError(log, resource.SetConditions(cr, RenderFailed(renderErr)))
+			wait := r.shortWait
+			if IsOutputLimitExceeded(renderErr) {
+				// NOTE: The same input renders the same output, and the
+				// changes of the parent resource are reconciled right away.
+				wait = r.resyncInterval
+			}
+			return ctrl.Result{RequeueAfter: jitter(wait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		r.recorder.Event(cr, renderFailedEvent(renderErr))
+		childResources = lastGood
+		observed.LastKnownGood = true
+	}
+	observed.ResourceRefs = trackExpiry(inventoryOf(cr), NewInventory(childResources))
+	observed.Children = childResources
+	if r.informers != nil {
+		r.informers.Observe(childResources)
+	}
+
+	if p := r.observerOf(cr); p != nil {
+		// NOTE: The child resources of an observed parent resource are
+		// orphaned when it's deleted, unlike in dry-run mode, since the
+		// parent resource would never go away otherwise.
+		if r.dryRunner == nil && meta.WasDeleted(cr) {
+			if err := r.finalizer.RemoveFinalizer(ctx, cr); client.IgnoreNotFound(err) != nil {
+				log.Info(errRemoveFinalizer, "error", err)
+				omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errRemoveFinalizer))))
+				return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+			}
+			r.forget(ctx, log, cr)
+			return reconcile.Result{Requeue: false}, nil
+		}
+		changes := r.dryRun(ctx, p, cr, childResources)
+		if e, ok := dryRunEvent(changes); ok {
+			r.recorder.Event(cr, e)
+		}
+		omitError(log, SetDryRunChanges(cr, changes))
+		omitError(log, resource.SetConditions(cr, DryRun(changes)))
+		if renderErr != nil {
+			omitError(log, resource.SetConditions(cr, RenderFailed(errors.Wrap(renderErr, errLastKnownGood))))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		if _, err := ManagementPolicyOf(cr); r.dryRunner == nil && err != nil {
+			log.Info("Cannot determine the management policy", "error", err)
+			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		log.Debug("Dry run finished with success")
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileSuccess()))
+		return ctrl.Result{RequeueAfter: jitter(r.resyncInterval, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	if markedObserved(cr) {
+		omitError(log, resource.SetConditions(cr, Managed()))
+		unstructured.RemoveNestedField(cr.UnstructuredContent(), "status", "dryRunChanges")
 	}
 
 	if meta.WasDeleted(cr) {
-		deleting, err := r.children.Delete(ctx, cr, childResources)
+		deleting, err := r.children.Delete(ctx, cr, r.unsuspended(cr, childResources))
 		if err != nil {
 			log.Info(errDeleter, "error", err)
+			if IsCRDDeletionBlocked(err) {
+				omitError(log, resource.SetConditions(cr, CRDDeletionBlocked(err)))
+			}
 			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errDeleter))))
-			return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
 		}
 
 		if len(deleting) > 0 {
 			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileSuccess().WithMessage(msgWaitingForDeletion)))
-			return ctrl.Result{RequeueAfter: tinyWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+			return ctrl.Result{RequeueAfter: tinyWait}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
 		}
 
 		if err := r.finalizer.RemoveFinalizer(ctx, cr); client.IgnoreNotFound(err) != nil {
 			log.Info(errRemoveFinalizer, "error", err)
 			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errRemoveFinalizer))))
-			return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
 		}
+		r.forget(ctx, log, cr)
 		return reconcile.Result{Requeue: false}, nil
 	}
 
+	if r.previewer != nil && previewRequested(cr) {
+		if err := r.preview(ctx, cr, childResources); err != nil {
+			log.Info(errPreview, "error", err)
+			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errPreview))))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		meta.RemoveAnnotations(cr, PreviewAnnotationKey)
+		if err := r.client.Update(ctx, cr); err != nil {
+			log.Info(errClearPreview, "error", err)
+			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errClearPreview))))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		log.Debug("Preview is stored")
+		return ctrl.Result{RequeueAfter: tinyWait}, nil
+	}
+
 	if err := r.finalizer.AddFinalizer(ctx, cr); err != nil {
 		log.Info(errAddFinalizer, "error", err)
 		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errAddFinalizer))))
-		return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
 	}
 
-	for _, o := range childResources {
-		if err := r.client.Apply(ctx, o, rresource.MustBeControllableBy(cr.GetUID())); err != nil {
+	live, expired, expiresIn, err := r.expire(ctx, cr, childResources, observed.ResourceRefs, metav1.Now())
+	if err != nil {
+		log.Info("Cannot delete the expired child resources", "error", err)
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+
+	if err := r.hooks.before(ctx, PhaseApply, cr, childResources); err != nil {
+		log.Info("Cannot apply the changes to the child resources", "error", err)
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	startPhase(ctx, PhaseApply)
+
+	if err := r.checkPermissions(ctx, cr, r.unsuspended(cr, live)); err != nil {
+		log.Info("Missing permissions for the child resources", "error", err)
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+
+	if err := r.checkNamespaces(ctx, cr, r.unsuspended(cr, live)); err != nil {
+		log.Info("Missing namespaces for the child resources", "error", err)
+		if IsMissingNamespaces(err) {
+			omitError(log, resource.SetConditions(cr, NamespacesMissing(err)))
+		}
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	if namespacesMissing(cr) {
+		omitError(log, resource.SetConditions(cr, NamespacesExist()))
+	}
+
+	if err := r.checkEscalations(ctx, r.unsuspended(cr, live)); err != nil {
+		log.Info("Child resources escalate privileges", "error", err)
+		if IsPrivilegeEscalation(err) {
+			omitError(log, resource.SetConditions(cr, PrivilegeEscalation(err)))
+		}
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	if escalated(cr) {
+		omitError(log, resource.SetConditions(cr, NoPrivilegeEscalation()))
+	}
+
+	results := make([]ApplyResult, 0, len(childResources))
+	for _, o := range expired {
+		results = append(results, NewApplyResult(o, ApplyOperationExpired, nil))
+	}
+	hash, start := resumeFrom(cr, live)
+	previous := previousApplyResults(cr)
+	for i, o := range live {
+		if r.suspended(cr, o) {
+			results = append(results, NewApplyResult(o, ApplyOperationSuspended, nil))
+			continue
+		}
+		if i < start {
+			// NOTE: The child resources that the previous reconciliation
+			// applied before it stopped keep their results.
+			res, ok := previous[NewInventory([]resource.ChildResource{o})[0]]
+			if !ok {
+				res = NewApplyResult(o, ApplyOperationUnchanged, nil)
+			}
+			results = append(results, res)
+			continue
+		}
+		if i > start && deadlineNear(ctx) {
+			log.Info("Reconciliation deadline is near, stopping the apply", "applied", i, "total", len(live))
+			omitError(log, SetApplyResults(cr, results))
+			omitError(log, SetApplyProgress(cr, ApplyProgress{RenderHash: hash, Applied: i}))
+			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileSuccess().WithMessage(msgApplyInProgress)))
+			return ctrl.Result{RequeueAfter: tinyWait}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+		op, err := applyChild(ctx, r.applier, cr, o)
+		results = append(results, NewApplyResult(o, op, err))
+		if err != nil {
 			log.Info("Cannot apply the changes to the child resources", "error", err)
+			omitError(log, SetApplyResults(cr, results))
+			if IsContention(err) {
+				omitError(log, resource.SetConditions(cr, ResourceContention(err)))
+			}
 			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, fmt.Sprintf("%s: %s/%s of type %s", errApply, o.GetName(), o.GetNamespace(), o.GetObjectKind().GroupVersionKind().String())))))
-			return ctrl.Result{RequeueAfter: r.shortWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+		}
+	}
+	omitError(log, SetApplyResults(cr, results))
+	unstructured.RemoveNestedField(cr.UnstructuredContent(), "status", "applyProgress")
+	stopPhase(ctx, PhaseApply)
+	if contended(cr) {
+		omitError(log, resource.SetConditions(cr, NoResourceContention()))
+	}
+	if err := r.hooks.after(ctx, PhaseApply, cr, childResources); err != nil {
+		log.Info("Cannot apply the changes to the child resources", "error", err)
+		omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(err)))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	if renderErr != nil {
+		omitError(log, resource.SetConditions(cr, RenderFailed(errors.Wrap(renderErr, errLastKnownGood))))
+		return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+	}
+	if refreshRequested(cr) {
+		meta.RemoveAnnotations(cr, ReconcileAtAnnotationKey)
+		if err := r.client.Update(ctx, cr); err != nil {
+			log.Info(errClearRefresh, "error", err)
+			omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileError(errors.Wrap(err, errClearRefresh))))
+			return ctrl.Result{RequeueAfter: jitter(r.shortWait, r.jitter)}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
 		}
 	}
+	omitError(log, r.record(ctx, cr, childResources))
+	if r.rollout != nil {
+		omitError(log, SetTemplateRevision(cr, r.rollout.Revision()))
+	}
 	log.Debug("Reconciliation finished with success")
 	omitError(log, resource.SetConditions(cr, v1alpha1.ReconcileSuccess()))
-	return ctrl.Result{RequeueAfter: r.longWait}, errors.Wrap(r.client.Status().Update(ctx, cr), errUpdateResourceStatus)
+	requeue := jitter(r.resyncInterval, r.jitter)
+	if expiresIn > 0 && expiresIn < requeue {
+		// NOTE: The child resources are deleted as soon as their TTL passes
+		// rather than at the next resync.
+		requeue = expiresIn
+	}
+	return ctrl.Result{RequeueAfter: requeue}, errors.Wrap(r.writeStatus(ctx, cr, observed), errUpdateResourceStatus)
+}
+
+// backoff returns the given result of the reconciliation of the given parent
+// resource delayed until the end of its backoff if the reconciliation failed.
+// A parent resource whose deletion is complete is forgotten.
+func (r *Reconciler) backoff(cr resource.ParentResource, result ctrl.Result, err error) ctrl.Result {
+	if (err == nil && synced(cr)) || (meta.WasDeleted(cr) && len(cr.GetFinalizers()) == 0) {
+		r.limiter.Succeeded(cr)
+		return result
+	}
+	if wait := r.limiter.Failed(cr); wait > result.RequeueAfter {
+		result.RequeueAfter = wait
+	}
+	return result
+}
+
+// render runs the templating engine, the patchers, and the linter and the
+// schema validator, if configured, as PhaseRender, PhasePatch and
+// PhaseValidate with their hooks. The unknown fields of the spec are pruned first if configured,
+// and the output of the engine is checked against the OutputLimits if configured.
+// The warnings of the engine are reported in the RenderWarnings condition.
+// If a RenderStore is configured, the result is stored as the last known good
+// child resources unless the parent resource is observed.
+func (r *Reconciler) render(ctx context.Context, cr resource.ParentResource) ([]resource.ChildResource, error) {
+	in := cr
+	if r.prune != nil {
+		pruned, err := pruneUnknownFields(cr, r.prune, r.strictPruning)
+		if err != nil {
+			return nil, errors.Wrap(err, errTemplatingOperation)
+		}
+		in = pruned
+	}
+	var warnings []string
+	childResources, err := r.hooks.around(ctx, PhaseRender, cr, nil, func() ([]resource.ChildResource, error) {
+		res, err := r.runEngine(ctx, cr, in)
+		if err != nil {
+			return res.Children, errors.Wrap(err, errTemplatingOperation)
+		}
+		warnings = res.Warnings
+		if r.outputLimits != nil {
+			return res.Children, r.outputLimits.Check(res.Children)
+		}
+		return res.Children, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	childResources, err = r.hooks.around(ctx, PhasePatch, cr, childResources, func() ([]resource.ChildResource, error) {
+		list, err := r.children.Patch(ctx, cr, childResources)
+		return list, errors.Wrap(err, errChildResourcePatchers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	childResources, err = r.hooks.around(ctx, PhaseValidate, cr, childResources, func() ([]resource.ChildResource, error) {
+		if err := r.warn(cr, warnings, childResources); err != nil {
+			return childResources, err
+		}
+		if r.linter != nil {
+			if err := r.lint(cr, childResources); err != nil {
+				return childResources, err
+			}
+		}
+		if r.schemas == nil {
+			return childResources, nil
+		}
+		return childResources, r.validateSchemas(ctx, childResources)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if r.lastKnownGood == nil || r.observerOf(cr) != nil {
+		return childResources, nil
+	}
+	// NOTE: A failure to store the result should not block the reconciliation
+	// since it only affects the fallback.
+	if err := r.lastKnownGood.Store(ctx, cr, childResources); err != nil {
+		r.log.Info("Cannot store the last known good child resources", "error", err)
+	}
+	return childResources, nil
+}
+
+// forget deletes the last known good child resources, the record of the last
+// reconciliation, the cached render result and the health of the given
+// deleted parent resource, if configured.
+func (r *Reconciler) forget(ctx context.Context, log logging.Logger, cr resource.ParentResource) {
+	if r.lastKnownGood != nil {
+		omitError(log, r.lastKnownGood.Delete(ctx, cr))
+	}
+	if r.cache != nil {
+		omitError(log, r.cache.Delete(ctx, cr))
+	}
+	if r.results != nil {
+		r.results.Delete(cr)
+	}
+	if r.health != nil {
+		r.health.Forget(cr)
+	}
+}
+
+// getLastKnownGood returns the last known good child resources of the given
+// parent resource, if a RenderStore is configured.
+func (r *Reconciler) getLastKnownGood(ctx context.Context, cr resource.ParentResource) ([]resource.ChildResource, error) {
+	if r.lastKnownGood == nil {
+		return nil, nil
+	}
+	return r.lastKnownGood.Get(ctx, cr)
+}
+
+// unchanged returns the remaining time until the next reconciliation and true
+// if the given parent resource has not changed since its last successful
+// reconciliation and the resync interval has not passed yet. A parent
+// resource with child resources that are about to expire is never skipped.
+func (r *Reconciler) unchanged(ctx context.Context, cr resource.ParentResource) (time.Duration, bool) {
+	if r.cache == nil || r.observerOf(cr) != nil || meta.WasDeleted(cr) || refreshRequested(cr) || previewRequested(cr) || expiring(cr) {
+		return 0, false
+	}
+	rec, err := r.cache.Get(ctx, cr)
+	if err != nil || rec == nil {
+		return 0, false
+	}
+	revision, err := r.inputRevision(cr)
+	if err != nil {
+		return 0, false
+	}
+	hash, err := resource.HashParent(cr, revision, r.inputFields...)
+	if err != nil || hash != rec.InputHash {
+		return 0, false
+	}
+	// NOTE: The recorded time has a precision of seconds once persisted.
+	wait := (r.resyncInterval - time.Since(rec.AppliedAt.Time)).Round(time.Second)
+	return wait, wait > 0
+}
+
+// inputRevision returns the revision of the template source, combined with
+// the digest of the other inputs of the engine if it reports them.
+func (r *Reconciler) inputRevision(cr resource.ParentResource) (string, error) {
+	d, ok := r.templating.(InputDigester)
+	if !ok {
+		return r.revision, nil
+	}
+	digest, err := d.InputDigest(cr)
+	if err != nil {
+		return "", err
+	}
+	return r.revision + "/" + digest, nil
+}
+
+// record stores the record of a successful reconciliation of the given parent
+// resource that produced the given child resources.
+func (r *Reconciler) record(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	if r.cache == nil {
+		return nil
+	}
+	revision, err := r.inputRevision(cr)
+	if err != nil {
+		return err
+	}
+	in, err := resource.HashParent(cr, revision, r.inputFields...)
+	if err != nil {
+		return err
+	}
+	out, err := resource.HashChildren(list)
+	if err != nil {
+		return err
+	}
+	return r.cache.Store(ctx, cr, RenderRecord{
+		InputHash:  in,
+		RenderHash: out,
+		Inventory:  NewInventory(list),
+		AppliedAt:  metav1.Now(),
+	})
+}
+
+// refreshRequested returns true if an immediate full reconciliation of the
+// given parent resource is requested via ReconcileAtAnnotationKey annotation.
+func refreshRequested(cr resource.ParentResource) bool {
+	return cr.GetAnnotations()[ReconcileAtAnnotationKey] != ""
+}
+
+// jitter returns the given duration randomly increased or decreased by up to
+// the given fraction of it.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d)) // nolint:gosec
 }
 
 func omitError(log logging.Logger, err error) {
@@ -19,7 +19,9 @@ package templating
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
@@ -30,6 +32,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	rresource "github.com/crossplane/crossplane-runtime/pkg/resource"
 	runtimefake "github.com/crossplane/crossplane-runtime/pkg/resource/fake"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
@@ -53,7 +56,38 @@ func withNewParentResourceFunc(f func() resource.ParentResource) ReconcilerOptio
 	}
 }
 
+func withStored(s RenderStore, list ...resource.ChildResource) RenderStore {
+	_ = s.Store(context.Background(), fake.NewMockResource(), list)
+	return s
+}
+
+type mockRenderCache struct {
+	rec *RenderRecord
+}
+
+func (c *mockRenderCache) Get(_ context.Context, _ resource.ParentResource) (*RenderRecord, error) {
+	return c.rec, nil
+}
+
+func (c *mockRenderCache) Store(_ context.Context, _ resource.ParentResource, rec RenderRecord) error {
+	c.rec = &rec
+	return nil
+}
+
+func (c *mockRenderCache) Delete(_ context.Context, _ resource.ParentResource) error {
+	c.rec = nil
+	return nil
+}
+
 func TestReconcile(t *testing.T) {
+	unchangedHash, _ := resource.HashParent(fake.NewMockResource(fake.WithGVK(schema.EmptyObjectKind.GroupVersionKind())), "rev")
+	trueVal := true
+	otherController := metav1.OwnerReference{APIVersion: "example.org/v1", Kind: "Other", Name: "other", UID: "other-uid", Controller: &trueVal}
+	refreshHash, _ := resource.HashParent(fake.NewMockResource(
+		fake.WithGVK(schema.EmptyObjectKind.GroupVersionKind()),
+		fake.WithAdditionalAnnotations(map[string]string{ReconcileAtAnnotationKey: "now"}),
+	), "rev")
+
 	type args struct {
 		kube client.Client
 		opts []ReconcilerOption
@@ -76,6 +110,50 @@ func TestReconcile(t *testing.T) {
 				err: errors.Wrap(errBoom, errGetResource),
 			},
 		},
+		"UnchangedSkipped": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithEngine(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+						t.Errorf("Reconcile(...): unchanged parent resource should not be rendered")
+						return resource.RenderResult{}, nil
+					})),
+					WithResyncInterval(time.Hour),
+					WithRenderCache(&mockRenderCache{rec: &RenderRecord{InputHash: unchangedHash, AppliedAt: metav1.Now()}}, "rev"),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: time.Hour},
+			},
+		},
+		"RefreshRequested": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(nil, func(obj runtime.Object) error {
+						meta.AddAnnotations(obj.(metav1.Object), map[string]string{ReconcileAtAnnotationKey: "now"})
+						meta.AddFinalizer(obj.(metav1.Object), finalizer)
+						return nil
+					}),
+					MockUpdate: test.NewMockUpdateFn(nil, func(obj runtime.Object) error {
+						if _, ok := obj.(metav1.Object).GetAnnotations()[ReconcileAtAnnotationKey]; ok {
+							t.Errorf("Reconcile(...): %s annotation should be removed", ReconcileAtAnnotationKey)
+						}
+						return nil
+					}),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil),
+				},
+				opts: []ReconcilerOption{
+					WithEngine(&NopEngine{}),
+					WithResyncInterval(time.Hour),
+					WithRenderCache(&mockRenderCache{rec: &RenderRecord{InputHash: refreshHash, AppliedAt: metav1.Now()}}, "rev"),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: time.Hour},
+			},
+		},
 		"TemplatingFailed": {
 			args: args{
 				kube: &test.MockClient{
@@ -94,8 +172,8 @@ func TestReconcile(t *testing.T) {
 					}),
 				},
 				opts: []ReconcilerOption{
-					WithEngine(EngineFunc(func(_ resource.ParentResource) ([]resource.ChildResource, error) {
-						return nil, errBoom
+					WithEngine(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+						return resource.RenderResult{}, errBoom
 					})),
 				},
 			},
@@ -103,6 +181,36 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: defaultShortWait},
 			},
 		},
+		"TemplatingFailedLastKnownGoodApplied": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet:    test.NewMockGetFn(nil),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockPatch:  test.NewMockPatchFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(obj runtime.Object) error {
+						got := obj.(*fake.MockResource)
+						gotCond, err := resource.GetCondition(got, v1alpha1.TypeSynced)
+						if err != nil {
+							t.Errorf("Reconcile(...): error getting condition\n%s", err.Error())
+						}
+						wantCond := v1alpha1.ReconcileError(errors.Wrap(errors.Wrap(errBoom, errTemplatingOperation), errLastKnownGood))
+						if diff := cmp.Diff(wantCond, gotCond); diff != "" {
+							t.Errorf("Reconcile(...): -want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				opts: []ReconcilerOption{
+					WithEngine(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+						return resource.RenderResult{}, errBoom
+					})),
+					WithRenderStore(withStored(NewMemoryRenderStore(), fake.NewMockResource(fake.WithNamespaceName(fakeName, fakeNamespace)))),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: defaultShortWait},
+			},
+		},
 		"ChildResourcePatchFailed": {
 			args: args{
 				kube: &test.MockClient{
@@ -122,7 +230,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
 						return nil, errBoom
 					})),
 				},
@@ -155,7 +263,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 						return list, nil
 					})),
 					WithChildResourceDeleter(ChildResourceDeleterFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
@@ -191,7 +299,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 						return list, nil
 					})),
 					WithChildResourceDeleter(ChildResourceDeleterFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
@@ -227,7 +335,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 						return list, nil
 					})),
 					WithChildResourceDeleter(ChildResourceDeleterFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
@@ -254,7 +362,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 						return list, nil
 					})),
 					WithChildResourceDeleter(ChildResourceDeleterFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
@@ -269,6 +377,36 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{Requeue: false},
 			},
 		},
+		"ObservedDeletionOrphaned": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+						mobj, _ := obj.(metav1.Object)
+						now := metav1.Now()
+						mobj.SetDeletionTimestamp(&now)
+						meta.AddAnnotations(mobj, map[string]string{ManagementPolicyAnnotationKey: string(ManagementPolicyObserve)})
+						return nil
+					},
+				},
+				opts: []ReconcilerOption{
+					WithEngine(&NopEngine{}),
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+						return list, nil
+					})),
+					WithChildResourceDeleter(ChildResourceDeleterFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+						t.Errorf("Reconcile(...): the child resources of an observed parent resource should not be deleted")
+						return nil, nil
+					})),
+					WithFinalizer(rresource.FinalizerFns{RemoveFinalizerFn: func(_ context.Context, _ rresource.Object) error {
+						return nil
+					}}),
+					WithManagementPolicies(&Previewer{}),
+				},
+			},
+			want: want{
+				result: reconcile.Result{Requeue: false},
+			},
+		},
 		"FinalizerAdditionFailed": {
 			args: args{
 				kube: &test.MockClient{
@@ -288,7 +426,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
 						return list, nil
 					})),
 					WithFinalizer(rresource.FinalizerFns{AddFinalizerFn: func(_ context.Context, _ rresource.Object) error {
@@ -326,7 +464,7 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
 						res := fake.NewMockResource()
 						res.SetName(fakeName)
 						res.SetNamespace(fakeNamespace)
@@ -339,6 +477,75 @@ func TestReconcile(t *testing.T) {
 				result: reconcile.Result{RequeueAfter: defaultShortWait},
 			},
 		},
+		"ResourceContended": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+						if key.Name == fakeName {
+							obj.(metav1.Object).SetOwnerReferences([]metav1.OwnerReference{otherController})
+						}
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(obj runtime.Object) error {
+						got := obj.(*fake.MockResource)
+						gotCond, err := resource.GetCondition(got, TypeResourceContention)
+						if err != nil {
+							t.Errorf("Reconcile(...): error getting condition\n%s", err.Error())
+						}
+						wantCond := ResourceContention(&ContentionError{
+							Child:      ChildReference{APIVersion: fake.MockChildGVK.GroupVersion().String(), Kind: fake.MockChildGVK.Kind, Name: fakeName},
+							Controller: otherController,
+						})
+						if diff := cmp.Diff(wantCond, gotCond); diff != "" {
+							t.Errorf("Reconcile(...): -want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				opts: []ReconcilerOption{
+					WithEngine(&NopEngine{}),
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+						return []resource.ChildResource{fake.NewMockResource(fake.WithGVK(fake.MockChildGVK), fake.WithNamespaceName(fakeName, ""))}, nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: defaultShortWait},
+			},
+		},
+		"KindSuspended": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet:    test.NewMockGetFn(nil),
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockPatch: test.NewMockPatchFn(nil, func(_ runtime.Object) error {
+						return errBoom
+					}),
+					MockStatusUpdate: test.NewMockStatusUpdateFn(nil, func(obj runtime.Object) error {
+						got := obj.(*fake.MockResource)
+						gotCond, err := resource.GetCondition(got, v1alpha1.TypeSynced)
+						if err != nil {
+							t.Errorf("Reconcile(...): error getting condition\n%s", err.Error())
+						}
+						if diff := cmp.Diff(v1alpha1.ReconcileSuccess(), gotCond); diff != "" {
+							t.Errorf("Reconcile(...): -want, +got:\n%s", diff)
+						}
+						return nil
+					}),
+				},
+				opts: []ReconcilerOption{
+					WithEngine(&NopEngine{}),
+					WithSuspendedKinds(fake.MockChildGVK.GroupKind()),
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+						return []resource.ChildResource{fake.NewMockResource(fake.WithGVK(fake.MockChildGVK), fake.WithNamespaceName(fakeName, fakeNamespace))}, nil
+					})),
+				},
+			},
+			want: want{
+				result: reconcile.Result{RequeueAfter: defaultResyncInterval},
+			},
+		},
 		"Success": {
 			args: args{
 				kube: &test.MockClient{
@@ -362,13 +569,13 @@ func TestReconcile(t *testing.T) {
 				},
 				opts: []ReconcilerOption{
 					WithEngine(&NopEngine{}),
-					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
+					WithChildResourcePatcher(ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, _ []resource.ChildResource) ([]resource.ChildResource, error) {
 						return nil, nil
 					})),
 				},
 			},
 			want: want{
-				result: reconcile.Result{RequeueAfter: defaultLongWait},
+				result: reconcile.Result{RequeueAfter: defaultResyncInterval},
 			},
 		},
 	}
@@ -394,3 +601,66 @@ func TestReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestJitter(t *testing.T) {
+	d := time.Minute
+	if got := jitter(d, 0); got != d {
+		t.Errorf("jitter(...): want %s, got %s", d, got)
+	}
+	for i := 0; i < 100; i++ {
+		if got := jitter(d, 0.1); got < 54*time.Second || got > 66*time.Second {
+			t.Errorf("jitter(...): %s is out of bounds", got)
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	cases := map[string]struct {
+		reason   string
+		fraction float64
+		want     float64
+	}{
+		"InRange": {
+			reason:   "A fraction in [0, 1) should be kept.",
+			fraction: 0.1,
+			want:     0.1,
+		},
+		"Negative": {
+			reason:   "A negative fraction should be clamped to 0.",
+			fraction: -0.5,
+			want:     0,
+		},
+		"NaN": {
+			reason:   "NaN should be clamped to 0.",
+			fraction: math.NaN(),
+			want:     0,
+		},
+		"One": {
+			reason:   "A fraction of 1 or more should be clamped below 1.",
+			fraction: 2,
+			want:     math.Nextafter(1, 0),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{}
+			WithJitter(tc.fraction)(r)
+			if diff := cmp.Diff(tc.want, r.jitter); diff != "" {
+				t.Errorf("\n%s\nWithJitter(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWithoutChildResourceSorting(t *testing.T) {
+	r := &Reconciler{children: crChildren{ChildResourcePatcherChain: DefaultChildResourcePatchers()}}
+	WithoutChildResourceSorting()(r)
+	if got, want := len(r.children.ChildResourcePatcherChain), len(DefaultChildResourcePatchers())-1; got != want {
+		t.Errorf("WithoutChildResourceSorting(): want %d patchers, got %d", want, got)
+	}
+	for _, p := range r.children.ChildResourcePatcherChain {
+		if _, ok := p.(ChildResourceSorter); ok {
+			t.Errorf("WithoutChildResourceSorting(): the ChildResourceSorter was not removed")
+		}
+	}
+}
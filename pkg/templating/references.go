@@ -0,0 +1,359 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	pkgv1alpha1 "github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ReferencesAnnotationKey is the annotation on the StackDefinition whose
+	// value is the YAML list of the References of the parent resources to
+	// other resources, typically the parent resources of other stacks, whose
+	// fields are copied to the spec of the parent resources before
+	// rendering.
+	ReferencesAnnotationKey = "templatestacks.crossplane.io/references"
+
+	// maxReferenceDepth is the maximum length of a chain of references that
+	// is followed to detect cycles.
+	maxReferenceDepth = 10
+
+	errParseReferences      = "cannot parse the references"
+	errFmtReferenceTo       = "bindings of reference %s must copy to a field of the spec, not %s"
+	errFmtReference         = "reference %s"
+	errReferenceNotObject   = "reference is not an object with a name"
+	errReferenceNamespace   = "a namespaced parent resource can refer only to the resources in its namespace"
+	errGetReferenced        = "cannot get the referenced resource"
+	errFmtReferencedField   = "field %s of %s %s is not set yet"
+	errFmtReferenceCycle    = "references form a cycle: %s"
+	errFmtReferenceTooDeep  = "references are nested deeper than %d levels"
+	errFmtSetReferenceValue = "cannot set %s"
+	errFmtDecodeSecretValue = "field %s of %s %s is not base64 encoded"
+)
+
+// secretGVK is the GroupVersionKind of the Secrets, whose data is base64
+// encoded.
+var secretGVK = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+
+// A Reference of a parent resource to another resource, whose fields are
+// copied to the spec of the parent resource before rendering.
+type Reference struct {
+	// From is the path of the field of the parent resource that refers to
+	// the other resource, e.g. spec.networkRef. The field is an object with
+	// the name of the other resource, and its namespace if the parent
+	// resource is cluster-scoped. A namespaced parent resource refers to the
+	// resources in its namespace.
+	From string `json:"from"`
+
+	// APIVersion of the referenced resource.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the referenced resource.
+	Kind string `json:"kind"`
+
+	// Bindings copy the fields of the referenced resource at their From
+	// paths, e.g. status.outputs.vpcId, to the fields of the spec of the
+	// parent resource at their To paths, e.g. spec.network.vpcId.
+	Bindings []pkgv1alpha1.FieldBinding `json:"bindings"`
+
+	// Optional makes the parent resource render without the bindings if
+	// its From field is not set.
+	Optional bool `json:"optional,omitempty"`
+
+	// Watch makes the parent resources render again when the referenced
+	// resources change, rather than with the next resync. All resources of
+	// the referenced kind are cached by the controller.
+	Watch bool `json:"watch,omitempty"`
+}
+
+// GroupVersionKind returns the GroupVersionKind of the referenced resource.
+func (r Reference) GroupVersionKind() schema.GroupVersionKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind)
+}
+
+// ParseReferences parses the given YAML list of references, typically the
+// value of ReferencesAnnotationKey annotation.
+func ParseReferences(data string) ([]Reference, error) {
+	var refs []Reference
+	if err := yaml.Unmarshal([]byte(data), &refs); err != nil {
+		return nil, errors.Wrap(err, errParseReferences)
+	}
+	for _, ref := range refs {
+		for _, b := range ref.Bindings {
+			if !strings.HasPrefix(b.To, "spec.") {
+				return nil, errors.Errorf(errFmtReferenceTo, ref.From, b.To)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// NewReferenceResolvingEngine returns a new *ReferenceResolvingEngine that
+// reads the referenced resources with the given reader.
+func NewReferenceResolvingEngine(e Engine, r client.Reader, refs ...Reference) *ReferenceResolvingEngine {
+	return &ReferenceResolvingEngine{Engine: e, Reader: r, References: refs}
+}
+
+// ReferenceResolvingEngine copies the fields of the resources that the parent
+// resource refers to to its spec and runs the underlying Engine with the
+// result. The bound fields take precedence over the spec. A referenced field
+// that is not set yet, e.g. an output that another stack has not reported
+// yet, fails the rendering until it's set. The references between the parent
+// resources of the same kind are followed to reject the cycles, since the
+// parent resources in a cycle would wait for each other forever. The parent
+// resource is not reconciled when a referenced resource changes, so the
+// changes are picked up by the next resync, unless the reference is watched.
+// The values of the data of the referenced Secrets are decoded.
+type ReferenceResolvingEngine struct {
+	Engine     Engine
+	Reader     client.Reader
+	References []Reference
+}
+
+// Run runs the underlying Engine with a copy of the parent resource whose
+// spec has the fields of the referenced resources.
+func (e *ReferenceResolvingEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if len(e.References) == 0 {
+		return e.Engine.Run(ctx, cr)
+	}
+	readCtx, cancel := context.WithTimeout(ctx, valuesTimeout)
+	defer cancel()
+	cp, ok := cr.DeepCopyObject().(resource.ParentResource)
+	if !ok {
+		return resource.RenderResult{}, errors.New(errCopyParent)
+	}
+	// NOTE: The cycles are checked first since the parent resources in a
+	// cycle would otherwise be reported as waiting for each other.
+	if err := e.checkCycles(readCtx, cr); err != nil {
+		return resource.RenderResult{}, err
+	}
+	for _, ref := range e.References {
+		if err := e.resolve(readCtx, cr, cp, ref); err != nil {
+			return resource.RenderResult{}, errors.Wrapf(err, errFmtReference, ref.From)
+		}
+	}
+	return e.Engine.Run(ctx, cp)
+}
+
+// resolve copies the fields of the resource that the given parent resource
+// refers to with the given reference to the given copy of it.
+func (e *ReferenceResolvingEngine) resolve(ctx context.Context, cr, into resource.ParentResource, ref Reference) error {
+	key, ok, err := referenceKey(cr, ref)
+	if err != nil || !ok {
+		return err
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(ref.GroupVersionKind())
+	if err := e.Reader.Get(ctx, key, u); err != nil {
+		return errors.Wrap(err, errGetReferenced)
+	}
+	for _, b := range ref.Bindings {
+		val, exists, err := unstructured.NestedFieldCopy(u.Object, strings.Split(b.From, ".")...)
+		if err != nil || !exists {
+			return errors.Errorf(errFmtReferencedField, b.From, ref.Kind, key)
+		}
+		if s, ok := val.(string); ok && ref.GroupVersionKind() == secretGVK && strings.HasPrefix(b.From, "data.") {
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return errors.Errorf(errFmtDecodeSecretValue, b.From, ref.Kind, key)
+			}
+			val = string(data)
+		}
+		if err := unstructured.SetNestedField(into.UnstructuredContent(), val, strings.Split(b.To, ".")...); err != nil {
+			return &resource.ValuesError{Path: b.To, Err: errors.Wrapf(err, errFmtSetReferenceValue, b.To)}
+		}
+	}
+	return nil
+}
+
+// checkCycles returns an error if following the references between the
+// parent resources of the same kind as the given one leads back to a parent
+// resource on the way.
+func (e *ReferenceResolvingEngine) checkCycles(ctx context.Context, cr resource.ParentResource) error {
+	gvk := cr.GroupVersionKind()
+	var visit func(cr resource.ParentResource, path []types.NamespacedName) error
+	visit = func(cr resource.ParentResource, path []types.NamespacedName) error {
+		if len(path) > maxReferenceDepth {
+			return errors.Errorf(errFmtReferenceTooDeep, maxReferenceDepth)
+		}
+		for _, ref := range e.References {
+			if ref.GroupVersionKind() != gvk {
+				continue
+			}
+			key, ok, err := referenceKey(cr, ref)
+			if err != nil || !ok {
+				continue
+			}
+			next := append(path[:len(path):len(path)], key)
+			for _, seen := range path {
+				if seen == key {
+					return errors.Errorf(errFmtReferenceCycle, describePath(next))
+				}
+			}
+			u := &unstructured.Unstructured{}
+			u.SetGroupVersionKind(gvk)
+			if err := e.Reader.Get(ctx, key, u); err != nil {
+				// NOTE: A missing referenced resource is reported by
+				// resolve if it matters.
+				continue
+			}
+			if err := visit(u, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return visit(cr, []types.NamespacedName{{Namespace: cr.GetNamespace(), Name: cr.GetName()}})
+}
+
+// InputDigest returns the digest of the resource versions of the resources
+// that the given parent resource refers to with the watched references,
+// combined with the digest of the underlying Engine if it reports one, so that
+// the render cache is invalidated when they change.
+func (e *ReferenceResolvingEngine) InputDigest(cr resource.ParentResource) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), valuesTimeout)
+	defer cancel()
+	h := sha256.New()
+	for _, ref := range e.References {
+		if !ref.Watch {
+			continue
+		}
+		key, ok, err := referenceKey(cr, ref)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(ref.GroupVersionKind())
+		if err := e.Reader.Get(ctx, key, u); err != nil {
+			return "", errors.Wrap(err, errGetReferenced)
+		}
+		fmt.Fprintf(h, "%s %s %s\n", ref.From, key, u.GetResourceVersion())
+	}
+	return withInputDigest(e.Engine, cr, hex.EncodeToString(h.Sum(nil)))
+}
+
+// WatchedKinds returns the kinds of the resources that the given references
+// refer to and watch.
+func WatchedKinds(refs []Reference) []schema.GroupVersionKind {
+	var kinds []schema.GroupVersionKind
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, ref := range refs {
+		if gvk := ref.GroupVersionKind(); ref.Watch && !seen[gvk] {
+			seen[gvk] = true
+			kinds = append(kinds, gvk)
+		}
+	}
+	return kinds
+}
+
+// NewReferenceHandler returns an event handler that enqueues the parent
+// resources of the given kind that refer to the changed resource of the given
+// kind with one of the given watched references. The parent resources are
+// listed with the given reader, typically the cache of the manager.
+func NewReferenceHandler(r client.Reader, parent, gvk schema.GroupVersionKind, refs []Reference) handler.EventHandler {
+	return &handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+		ctx, cancel := context.WithTimeout(context.Background(), valuesTimeout)
+		defer cancel()
+		l := &unstructured.UnstructuredList{}
+		l.SetGroupVersionKind(parent.GroupVersion().WithKind(parent.Kind + "List"))
+		if err := r.List(ctx, l); err != nil {
+			return nil
+		}
+		changed := types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: o.Meta.GetName()}
+		var requests []reconcile.Request
+		for i := range l.Items {
+			for _, ref := range refs {
+				if !ref.Watch || ref.GroupVersionKind() != gvk {
+					continue
+				}
+				if key, ok, err := referenceKey(&l.Items[i], ref); err == nil && ok && key == changed {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: l.Items[i].GetNamespace(), Name: l.Items[i].GetName()}})
+					break
+				}
+			}
+		}
+		return requests
+	})}
+}
+
+// ValuesSources returns the sources of the underlying Engine followed by the
+// resources that the given parent resource refers to.
+func (e *ReferenceResolvingEngine) ValuesSources(cr resource.ParentResource) []string {
+	sources := valuesSourcesOf(e.Engine, cr)
+	for _, ref := range e.References {
+		if key, ok, err := referenceKey(cr, ref); err == nil && ok {
+			sources = append(sources, fmt.Sprintf("%s %s", ref.Kind, key))
+		}
+	}
+	return sources
+}
+
+// referenceKey returns the key of the resource that the given parent
+// resource refers to with the given reference, or false if the reference is
+// optional and not set.
+func referenceKey(cr resource.ParentResource, ref Reference) (types.NamespacedName, bool, error) {
+	raw, exists, err := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), strings.Split(ref.From, ".")...)
+	if err != nil || !exists || raw == nil {
+		if ref.Optional {
+			return types.NamespacedName{}, false, nil
+		}
+		return types.NamespacedName{}, false, &resource.ValuesError{Path: ref.From, Err: errors.New(errReferenceNotObject)}
+	}
+	obj, ok := raw.(map[string]interface{})
+	name, _ := obj["name"].(string)
+	if !ok || name == "" {
+		return types.NamespacedName{}, false, &resource.ValuesError{Path: ref.From, Err: errors.New(errReferenceNotObject)}
+	}
+	ns, _ := obj["namespace"].(string)
+	if cr.GetNamespace() != "" {
+		if ns != "" && ns != cr.GetNamespace() {
+			return types.NamespacedName{}, false, &resource.ValuesError{Path: ref.From + ".namespace", Err: errors.New(errReferenceNamespace)}
+		}
+		ns = cr.GetNamespace()
+	}
+	return types.NamespacedName{Namespace: ns, Name: name}, true, nil
+}
+
+// describePath returns the given chain of references as a string.
+func describePath(path []types.NamespacedName) string {
+	s := make([]string, len(path))
+	for i, key := range path {
+		s[i] = key.String()
+	}
+	return strings.Join(s, " -> ")
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	pkgv1alpha1 "github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestParseReferences(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   []Reference
+		err    error
+	}{
+		"Valid": {
+			reason: "A list of references should be parsed.",
+			data: `
+- from: spec.networkRef
+  apiVersion: network.example.org/v1alpha1
+  kind: Network
+  bindings:
+  - from: status.outputs.vpcId
+    to: spec.network.vpcId
+`,
+			want: []Reference{{
+				From:       "spec.networkRef",
+				APIVersion: "network.example.org/v1alpha1",
+				Kind:       "Network",
+				Bindings:   []pkgv1alpha1.FieldBinding{{From: "status.outputs.vpcId", To: "spec.network.vpcId"}},
+			}},
+		},
+		"NotToSpec": {
+			reason: "A binding to a field outside of the spec should be rejected.",
+			data: `
+- from: spec.networkRef
+  bindings:
+  - from: status.outputs.vpcId
+    to: metadata.labels.vpc
+`,
+			err: errors.Errorf(errFmtReferenceTo, "spec.networkRef", "metadata.labels.vpc"),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReferences(tc.data)
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseReferences(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParseReferences(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReferenceResolvingEngine(t *testing.T) {
+	network := schema.GroupVersionKind{Group: "network.example.org", Version: "v1alpha1", Kind: "Network"}
+	object := func(gvk schema.GroupVersionKind, name string, content map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: content}
+		u.SetGroupVersionKind(gvk)
+		u.SetNamespace("default")
+		u.SetName(name)
+		return u
+	}
+	reader := func(objs ...*unstructured.Unstructured) client.Reader {
+		return &test.MockClient{MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			u := obj.(*unstructured.Unstructured)
+			for _, o := range objs {
+				if o.GroupVersionKind() == u.GroupVersionKind() && o.GetNamespace() == key.Namespace && o.GetName() == key.Name {
+					o.DeepCopyInto(u)
+					return nil
+				}
+			}
+			return kerrors.NewNotFound(schema.GroupResource{Resource: u.GetKind()}, key.Name)
+		}}
+	}
+	networkRef := Reference{
+		From:       "spec.networkRef",
+		APIVersion: network.GroupVersion().String(),
+		Kind:       network.Kind,
+		Bindings:   []pkgv1alpha1.FieldBinding{{From: "status.outputs.vpcId", To: "spec.network.vpcId"}},
+	}
+	dependsOn := Reference{
+		From:       "spec.dependsOn",
+		APIVersion: fake.MockParentGVK.GroupVersion().String(),
+		Kind:       fake.MockParentGVK.Kind,
+		Bindings:   []pkgv1alpha1.FieldBinding{{From: "status.endpoint", To: "spec.upstream"}},
+		Optional:   true,
+	}
+	secretRef := Reference{
+		From:       "spec.passwordSecretRef",
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Bindings:   []pkgv1alpha1.FieldBinding{{From: "data.password", To: "spec.password"}},
+	}
+	parent := func(name string, spec map[string]interface{}) *unstructured.Unstructured {
+		return object(fake.MockParentGVK, name, map[string]interface{}{"spec": spec})
+	}
+	ref := func(name string) map[string]interface{} {
+		return map[string]interface{}{"name": name}
+	}
+
+	type want struct {
+		spec interface{}
+		err  error
+	}
+	cases := map[string]struct {
+		reason string
+		refs   []Reference
+		reader client.Reader
+		cr     *unstructured.Unstructured
+		want   want
+	}{
+		"Resolved": {
+			reason: "The fields of the referenced resources should be copied to the spec, and the optional references that are not set should be skipped.",
+			refs:   []Reference{networkRef, dependsOn},
+			reader: reader(object(network, "net", map[string]interface{}{"status": map[string]interface{}{"outputs": map[string]interface{}{"vpcId": "vpc-1"}}})),
+			cr:     parent("app", map[string]interface{}{"networkRef": ref("net"), "replicas": int64(2)}),
+			want: want{spec: map[string]interface{}{
+				"networkRef": ref("net"),
+				"replicas":   int64(2),
+				"network":    map[string]interface{}{"vpcId": "vpc-1"},
+			}},
+		},
+		"SecretDecoded": {
+			reason: "The values of the data of the referenced Secrets should be decoded.",
+			refs:   []Reference{secretRef},
+			reader: reader(object(secretGVK, "db", map[string]interface{}{"data": map[string]interface{}{"password": "czNjcmV0"}})),
+			cr:     parent("app", map[string]interface{}{"passwordSecretRef": ref("db")}),
+			want: want{spec: map[string]interface{}{
+				"passwordSecretRef": ref("db"),
+				"password":          "s3cret",
+			}},
+		},
+		"NotSetYet": {
+			reason: "A referenced field that is not set should fail the rendering.",
+			refs:   []Reference{networkRef},
+			reader: reader(object(network, "net", map[string]interface{}{})),
+			cr:     parent("app", map[string]interface{}{"networkRef": ref("net")}),
+			want: want{err: errors.Wrapf(
+				errors.Errorf(errFmtReferencedField, "status.outputs.vpcId", "Network", "default/net"),
+				errFmtReference, "spec.networkRef")},
+		},
+		"OtherNamespace": {
+			reason: "A namespaced parent resource should not refer to another namespace.",
+			refs:   []Reference{networkRef},
+			reader: reader(),
+			cr:     parent("app", map[string]interface{}{"networkRef": map[string]interface{}{"name": "net", "namespace": "kube-system"}}),
+			want: want{err: errors.Wrapf(
+				&resource.ValuesError{Path: "spec.networkRef.namespace", Err: errors.New(errReferenceNamespace)},
+				errFmtReference, "spec.networkRef")},
+		},
+		"Cycle": {
+			reason: "References between parent resources that lead back to the parent resource should be rejected.",
+			refs:   []Reference{dependsOn},
+			reader: reader(
+				parent("b", map[string]interface{}{"dependsOn": ref("c")}),
+				parent("c", map[string]interface{}{"dependsOn": ref("a")}),
+			),
+			cr:   parent("a", map[string]interface{}{"dependsOn": ref("b")}),
+			want: want{err: errors.Errorf(errFmtReferenceCycle, "default/a -> default/b -> default/c -> default/a")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var gotSpec interface{}
+			e := NewReferenceResolvingEngine(EngineFunc(func(_ context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+				gotSpec = cr.UnstructuredContent()["spec"]
+				return resource.RenderResult{}, nil
+			}), tc.reader, tc.refs...)
+			_, err := e.Run(context.Background(), &fake.MockResource{Unstructured: *tc.cr})
+			if diff := cmp.Diff(fmt.Sprint(tc.want.err), fmt.Sprint(err)); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.spec, gotSpec); diff != "" {
+				t.Errorf("\n%s\nRun(...): -want spec, +got spec:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestReferenceHandler(t *testing.T) {
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	refs := []Reference{
+		{From: "spec.settingsRef", APIVersion: "v1", Kind: "ConfigMap", Watch: true},
+		{From: "spec.otherRef", APIVersion: "v1", Kind: "ConfigMap"},
+	}
+	parent := func(name string, spec map[string]interface{}) unstructured.Unstructured {
+		u := unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(fake.MockParentGVK)
+		u.SetNamespace("default")
+		u.SetName(name)
+		return u
+	}
+	c := &test.MockClient{MockList: func(_ context.Context, obj runtime.Object, _ ...client.ListOption) error {
+		l := obj.(*unstructured.UnstructuredList)
+		l.Items = []unstructured.Unstructured{
+			parent("watching", map[string]interface{}{"settingsRef": map[string]interface{}{"name": "settings"}}),
+			parent("unwatched", map[string]interface{}{"otherRef": map[string]interface{}{"name": "settings"}}),
+			parent("other", map[string]interface{}{"settingsRef": map[string]interface{}{"name": "other"}}),
+		}
+		return nil
+	}}
+	changed := &unstructured.Unstructured{}
+	changed.SetNamespace("default")
+	changed.SetName("settings")
+
+	h := NewReferenceHandler(c, fake.MockParentGVK, configMap, refs).(*handler.EnqueueRequestsFromMapFunc)
+	got := h.ToRequests.Map(handler.MapObject{Meta: changed, Object: changed})
+	want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "default", Name: "watching"}}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Map(...): -want, +got:\n%s", diff)
+	}
+}
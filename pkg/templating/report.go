@@ -0,0 +1,280 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// ReportAnnotationKey is the annotation on the StackDefinition that makes the
+// controller write a TemplatingReport per parent resource. Its value is one
+// of ReportAlongside and ReportOnly.
+const ReportAnnotationKey = "templatestacks.crossplane.io/templating-report"
+
+// Values of ReportAnnotationKey annotation.
+const (
+	// ReportAlongside writes the reports in addition to the status of the
+	// parent resources.
+	ReportAlongside = "alongside"
+
+	// ReportOnly writes the reports instead of the status of the parent
+	// resources, e.g. for parent resources whose status schema is closed.
+	ReportOnly = "only"
+)
+
+// MaxReportHistory is the maximum number of entries that the histories of a
+// TemplatingReport keep. The oldest entries are dropped first.
+const MaxReportHistory = 5
+
+// ReportGroupVersionKind is the kind of the reports.
+var ReportGroupVersionKind = schema.GroupVersionKind{
+	Group:   "templatestacks.crossplane.io",
+	Version: "v1alpha1",
+	Kind:    "TemplatingReport",
+}
+
+const (
+	errGetReport      = "cannot get the templating report"
+	errWriteReport    = "cannot write the templating report"
+	errReadReport     = "cannot read the templating report"
+	errReadConditions = "cannot read the conditions of the parent resource"
+)
+
+// A Report is the summary of the reconciliations of a parent resource that is
+// kept in the report field of its TemplatingReport.
+type Report struct {
+	// Parent is the reported parent resource.
+	Parent ParentReference `json:"parent"`
+
+	// ObservedGeneration is the generation of the parent resource that is
+	// reconciled last.
+	ObservedGeneration int64 `json:"observedGeneration"`
+
+	// Revision of the template source that the child resources are rendered
+	// with.
+	Revision string `json:"revision,omitempty"`
+
+	// Children counts the child resources that are rendered last.
+	Children ChildrenCount `json:"children"`
+
+	// RenderHash is the hash of the child resources that are rendered last.
+	RenderHash string `json:"renderHash,omitempty"`
+
+	// LastKnownGood is true if the child resources could not be rendered and
+	// the last known good ones are used instead.
+	LastKnownGood bool `json:"lastKnownGood,omitempty"`
+
+	// Conditions of the parent resource.
+	Conditions []v1alpha1.Condition `json:"conditions,omitempty"`
+
+	// LastErrors are the last distinct errors of the reconciliations.
+	LastErrors []ReportEntry `json:"lastErrors,omitempty"`
+
+	// PruneHistory lists the last distinct sets of unknown fields that are
+	// pruned from the spec.
+	PruneHistory []ReportEntry `json:"pruneHistory,omitempty"`
+}
+
+// A ParentReference identifies a parent resource.
+type ParentReference struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	UID        types.UID `json:"uid"`
+}
+
+// ChildrenCount counts the child resources in total and per kind.
+type ChildrenCount struct {
+	Total int `json:"total"`
+
+	// Kinds maps the kinds, in Kind.group form, to their counts.
+	Kinds map[string]int `json:"kinds,omitempty"`
+}
+
+// A ReportEntry is an entry of a history of a Report.
+type ReportEntry struct {
+	Time    metav1.Time `json:"time"`
+	Message string      `json:"message"`
+}
+
+// NewReportWriter returns a new *ReportWriter. The reports of cluster-scoped
+// parent resources are written in the given namespace. The given StatusWriter
+// writes the status of the parent resources; nil leaves it unwritten.
+func NewReportWriter(c client.Client, namespace string, w StatusWriter) *ReportWriter {
+	return &ReportWriter{client: c, namespace: namespace, status: w, now: metav1.Now}
+}
+
+// A ReportWriter writes what is observed about a parent resource to a
+// TemplatingReport that is owned by the parent resource, in addition to or
+// instead of its status.
+type ReportWriter struct {
+	client    client.Client
+	namespace string
+	status    StatusWriter
+	now       func() metav1.Time
+}
+
+// WriteStatus writes the status of the given parent resource, if configured,
+// and its TemplatingReport.
+func (w *ReportWriter) WriteStatus(ctx context.Context, cr resource.ParentResource, o Observation) error {
+	if w.status != nil {
+		if err := w.status.WriteStatus(ctx, cr, o); err != nil {
+			return err
+		}
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(ReportGroupVersionKind)
+	err := w.client.Get(ctx, w.key(cr), u)
+	if client.IgnoreNotFound(err) != nil {
+		return errors.Wrap(err, errGetReport)
+	}
+	exists := err == nil
+	r := &Report{}
+	if raw, ok := u.Object["report"]; ok {
+		if err := convert(raw, r); err != nil {
+			return errors.Wrap(err, errReadReport)
+		}
+	}
+	if err := w.observe(r, cr, o); err != nil {
+		return err
+	}
+	raw := map[string]interface{}{}
+	if err := convert(r, &raw); err != nil {
+		return errors.Wrap(err, errWriteReport)
+	}
+	u.Object["report"] = raw
+	if exists {
+		return errors.Wrap(w.client.Update(ctx, u), errWriteReport)
+	}
+	u.SetNamespace(w.key(cr).Namespace)
+	u.SetName(w.key(cr).Name)
+	meta.AddOwnerReference(u, meta.AsOwner(meta.ReferenceTo(cr, cr.GroupVersionKind())))
+	err = w.client.Create(ctx, u)
+	if kerrors.IsAlreadyExists(err) {
+		// NOTE: The report is created by a concurrent reconciliation, which
+		// reports the same, so there is nothing left to write.
+		return nil
+	}
+	return errors.Wrap(err, errWriteReport)
+}
+
+// observe updates the given report with the given observation of the given
+// parent resource.
+func (w *ReportWriter) observe(r *Report, cr resource.ParentResource, o Observation) error {
+	r.Parent = ParentReference{
+		APIVersion: cr.GroupVersionKind().GroupVersion().String(),
+		Kind:       cr.GroupVersionKind().Kind,
+		Namespace:  cr.GetNamespace(),
+		Name:       cr.GetName(),
+		UID:        cr.GetUID(),
+	}
+	r.ObservedGeneration = o.Generation
+	r.Revision = o.Revision
+	r.LastKnownGood = o.LastKnownGood
+	if o.Children != nil {
+		r.Children = countChildren(o.Children)
+		if h, err := resource.HashChildren(o.Children); err == nil {
+			r.RenderHash = h
+		}
+	}
+	conditions := []v1alpha1.Condition{}
+	if raw, ok, _ := unstructured.NestedFieldNoCopy(cr.UnstructuredContent(), "status", "conditions"); ok {
+		if err := convert(raw, &conditions); err != nil {
+			return errors.Wrap(err, errReadConditions)
+		}
+	}
+	r.Conditions = conditions
+	for _, c := range conditions {
+		switch {
+		case c.Type == v1alpha1.TypeSynced && c.Status == corev1.ConditionFalse:
+			r.LastErrors = w.record(r.LastErrors, message(c))
+		case c.Type == TypeUnknownFields && c.Status == corev1.ConditionTrue:
+			r.PruneHistory = w.record(r.PruneHistory, message(c))
+		}
+	}
+	return nil
+}
+
+// record appends an entry with the given message to the given history unless
+// it's the same as the last entry, and drops the oldest entries beyond
+// MaxReportHistory.
+func (w *ReportWriter) record(history []ReportEntry, msg string) []ReportEntry {
+	if len(history) != 0 && history[len(history)-1].Message == msg {
+		return history
+	}
+	history = append(history, ReportEntry{Time: w.now(), Message: msg})
+	if len(history) > MaxReportHistory {
+		history = history[len(history)-MaxReportHistory:]
+	}
+	return history
+}
+
+// key returns the key of the report of the given parent resource. The name
+// includes the kind of the parent resource so that the reports of the parent
+// resources of different kinds do not collide.
+func (w *ReportWriter) key(cr resource.ParentResource) types.NamespacedName {
+	ns := cr.GetNamespace()
+	if ns == "" {
+		ns = w.namespace
+	}
+	return types.NamespacedName{Namespace: ns, Name: strings.ToLower(cr.GroupVersionKind().Kind) + "-" + cr.GetName()}
+}
+
+// countChildren returns the counts of the given child resources.
+func countChildren(list []resource.ChildResource) ChildrenCount {
+	c := ChildrenCount{Total: len(list), Kinds: map[string]int{}}
+	for _, o := range list {
+		c.Kinds[o.GetObjectKind().GroupVersionKind().GroupKind().String()]++
+	}
+	return c
+}
+
+// message returns the message of the given condition, or its reason if it
+// has no message.
+func message(c v1alpha1.Condition) string {
+	if c.Message != "" {
+		return c.Message
+	}
+	return string(c.Reason)
+}
+
+// fromUnstructured converts the given JSON compatible value to the given
+// type through JSON.
+func convert(in interface{}, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestReportWriter(t *testing.T) {
+	cr := fake.NewMockResource(fake.WithGVK(fake.MockParentGVK))
+	cr.SetName("cool")
+	cr.SetUID("cool-uid")
+	if err := resource.SetConditions(cr, v1alpha1.ReconcileError(errBoom)); err != nil {
+		t.Fatal(err)
+	}
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	o := Observation{Generation: 2, Revision: "sha256:abc", Children: []resource.ChildResource{cm, cm.DeepCopy()}}
+	hash, _ := resource.HashChildren(o.Children)
+
+	var written *unstructured.Unstructured
+	c := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+			if diff := cmp.Diff(client.ObjectKey{Namespace: "stacks", Name: "mockresource-cool"}, key); diff != "" {
+				t.Errorf("Get(...): -want key, +got key:\n%s", diff)
+			}
+			return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+		},
+		MockCreate: func(_ context.Context, obj runtime.Object, _ ...client.CreateOption) error {
+			written = obj.(*unstructured.Unstructured)
+			return nil
+		},
+	}
+	w := NewReportWriter(c, "stacks", nil)
+	if err := w.WriteStatus(context.Background(), cr, o); err != nil {
+		t.Fatalf("WriteStatus(...): %s", err)
+	}
+	got := &Report{}
+	if err := convert(written.Object["report"], got); err != nil {
+		t.Fatal(err)
+	}
+	want := &Report{
+		Parent:             ParentReference{APIVersion: cr.GetAPIVersion(), Kind: cr.GetKind(), Name: "cool", UID: "cool-uid"},
+		ObservedGeneration: 2,
+		Revision:           "sha256:abc",
+		Children:           ChildrenCount{Total: 2, Kinds: map[string]int{"ConfigMap": 2}},
+		RenderHash:         hash,
+		Conditions:         []v1alpha1.Condition{v1alpha1.ReconcileError(errBoom)},
+		LastErrors:         []ReportEntry{{Message: errBoom.Error()}},
+	}
+	ignoreTime := cmpopts.IgnoreTypes(metav1.Time{})
+	if diff := cmp.Diff(want, got, ignoreTime); diff != "" {
+		t.Errorf("WriteStatus(...): -want report, +got report:\n%s", diff)
+	}
+	if diff := cmp.Diff(1, len(written.GetOwnerReferences())); diff != "" {
+		t.Errorf("WriteStatus(...): the report should be owned by the parent resource:\n%s", diff)
+	}
+
+	// The histories keep the last distinct entries only.
+	for i := 0; i < MaxReportHistory+1; i++ {
+		got.LastErrors = w.record(got.LastErrors, fmt.Sprintf("error %d", i))
+		got.LastErrors = w.record(got.LastErrors, fmt.Sprintf("error %d", i))
+	}
+	if diff := cmp.Diff(MaxReportHistory, len(got.LastErrors)); diff != "" {
+		t.Errorf("record(...): -want entries, +got entries:\n%s", diff)
+	}
+	if diff := cmp.Diff(fmt.Sprintf("error %d", MaxReportHistory), got.LastErrors[len(got.LastErrors)-1].Message); diff != "" {
+		t.Errorf("record(...): -want last entry, +got last entry:\n%s", diff)
+	}
+
+	// The status of the parent resource is written first if configured.
+	w = NewReportWriter(c, "stacks", StatusWriterFunc(func(context.Context, resource.ParentResource, Observation) error {
+		return errBoom
+	}))
+	if diff := cmp.Diff(errBoom, w.WriteStatus(context.Background(), cr, o), test.EquateErrors()); diff != "" {
+		t.Errorf("WriteStatus(...): -want error, +got error:\n%s", diff)
+	}
+}
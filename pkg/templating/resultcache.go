@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// RenderResultCacheAnnotationKey is the annotation on the StackDefinition
+// that enables the in-memory cache of the rendered child resources when its
+// value is "true".
+const RenderResultCacheAnnotationKey = "templatestacks.crossplane.io/render-result-cache"
+
+// NewRenderResultCache returns a new empty *RenderResultCache.
+func NewRenderResultCache() *RenderResultCache {
+	return &RenderResultCache{entries: map[types.UID]renderResult{}}
+}
+
+// A RenderResultCache keeps the child resources and the warnings that the
+// templating engine rendered last for every parent resource in memory, along
+// with the hash of the input they were rendered from, so that the engine does
+// not have to run again for the same input. It keeps a single result per
+// parent resource.
+type RenderResultCache struct {
+	mu      sync.Mutex
+	entries map[types.UID]renderResult
+}
+
+type renderResult struct {
+	key    string
+	result resource.RenderResult
+}
+
+// Get returns a copy of the result that is cached for the given parent
+// resource and true if it was rendered from the input with the given hash.
+func (c *RenderResultCache) Get(cr resource.ParentResource, key string) (resource.RenderResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[cr.GetUID()]
+	if !ok || e.key != key {
+		return resource.RenderResult{}, false
+	}
+	return copyResult(e.result), true
+}
+
+// Store caches a copy of the given result of the given parent resource that
+// was rendered from the input with the given hash, replacing the one cached
+// before.
+func (c *RenderResultCache) Store(cr resource.ParentResource, key string, res resource.RenderResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[cr.GetUID()] = renderResult{key: key, result: copyResult(res)}
+}
+
+// Delete removes the child resources that are cached for the given parent
+// resource.
+func (c *RenderResultCache) Delete(cr resource.ParentResource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cr.GetUID())
+}
+
+// copyResult returns a deep copy of the given result, since the patchers
+// modify the rendered child resources in place.
+func copyResult(res resource.RenderResult) resource.RenderResult {
+	children := make([]resource.ChildResource, 0, len(res.Children))
+	for _, o := range res.Children {
+		cp, ok := o.DeepCopyObject().(resource.ChildResource)
+		if !ok {
+			continue
+		}
+		children = append(children, cp)
+	}
+	return resource.RenderResult{Children: children, Warnings: append([]string(nil), res.Warnings...)}
+}
+
+// runEngine runs the templating engine with the given input, or returns the
+// result that it rendered last for the given parent resource if neither the
+// parent resource nor the revision of the template source and the other
+// inputs of the engine changed since then.
+func (r *Reconciler) runEngine(ctx context.Context, cr, in resource.ParentResource) (resource.RenderResult, error) {
+	key, cacheable := r.resultKey(cr)
+	if cacheable {
+		if res, ok := r.results.Get(cr, key); ok {
+			return res, nil
+		}
+	}
+	res, err := r.templating.Run(ctx, in)
+	if err != nil {
+		return res, err
+	}
+	if cacheable {
+		r.results.Store(cr, key, res)
+	}
+	return res, nil
+}
+
+// resultKey returns the hash of the input of the rendering of the given
+// parent resource and true if its render result can be cached.
+func (r *Reconciler) resultKey(cr resource.ParentResource) (string, bool) {
+	if r.results == nil || refreshRequested(cr) {
+		return "", false
+	}
+	revision, err := r.inputRevision(cr)
+	if err != nil {
+		return "", false
+	}
+	hash, err := resource.HashParent(cr, revision, r.inputFields...)
+	return hash, err == nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestRenderResultCache(t *testing.T) {
+	runs := 0
+	engine := EngineFunc(func(_ context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+		runs++
+		u := &unstructured.Unstructured{}
+		u.SetName(cr.GetName())
+		return resource.RenderResult{Children: []resource.ChildResource{u}}, nil
+	})
+	patcher := ChildResourcePatcherFunc(func(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+		for _, o := range list {
+			o.SetLabels(map[string]string{"patched": o.GetLabels()["patched"] + "x"})
+		}
+		return list, nil
+	})
+	defaults := map[string]interface{}{"registry": "mirror.local"}
+	values := ValuesSourceFunc(func(_ context.Context) (map[string]interface{}, error) {
+		return defaults, nil
+	})
+	r := &Reconciler{templating: NewValuesMergingEngine(engine, values), children: crChildren{ChildResourcePatcherChain: ChildResourcePatcherChain{patcher}}}
+	WithRenderResultCache(NewRenderResultCache(), "v1")(r)
+	cr := fake.NewMockResource()
+	cr.SetUID("uid")
+	cr.SetName("cool")
+
+	render := func(reason string, wantRuns int) {
+		t.Helper()
+		list, err := r.render(context.Background(), cr)
+		if err != nil {
+			t.Fatalf("\n%s\nrender(...): %s", reason, err)
+		}
+		if runs != wantRuns {
+			t.Errorf("\n%s\nrender(...): want %d runs of the engine, got %d", reason, wantRuns, runs)
+		}
+		if got := list[0].GetLabels()["patched"]; got != "x" {
+			t.Errorf("\n%s\nrender(...): want the child resources to be patched once, got label %q", reason, got)
+		}
+	}
+	render("The engine should run for the first reconciliation.", 1)
+	render("The cached child resources should be used for the same input.", 1)
+	cr.SetAnnotations(map[string]string{"changed": "true"})
+	render("The engine should run again when the parent resource changes.", 2)
+	WithRenderResultCache(r.results, "v2")(r)
+	render("The engine should run again when the template revision changes.", 3)
+	r.results.Delete(cr)
+	render("The engine should run again when the cached result is deleted.", 4)
+	defaults = map[string]interface{}{"registry": "other.local"}
+	render("The engine should run again when only the merged values change.", 5)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ApplyStrategyAnnotationKey is the annotation on the StackDefinition
+	// whose value is the apply.Strategy that the existing child resources
+	// are patched with.
+	ApplyStrategyAnnotationKey = "templatestacks.crossplane.io/apply-strategy"
+
+	// MaxApplyResultMessageLength is the maximum length of the error message
+	// in an ApplyResult.
+	MaxApplyResultMessageLength = 256
+)
+
+// An ApplyOperation is the operation that is done on a child resource during
+// the apply.
+type ApplyOperation string
+
+// Apply operations.
+const (
+	ApplyOperationCreated   = ApplyOperation(apply.OperationCreated)
+	ApplyOperationPatched   = ApplyOperation(apply.OperationPatched)
+	ApplyOperationUnchanged = ApplyOperation(apply.OperationUnchanged)
+
+	// ApplyOperationFailed is reported for the child resource whose apply
+	// failed.
+	ApplyOperationFailed ApplyOperation = "Failed"
+
+	// ApplyOperationSuspended is reported for the child resources whose
+	// kinds are suspended. They are rendered but not applied.
+	ApplyOperationSuspended ApplyOperation = "Suspended"
+
+	// ApplyOperationExpired is reported for the child resources whose TTL
+	// has passed. They are deleted and not applied again.
+	ApplyOperationExpired ApplyOperation = "Expired"
+)
+
+// ApplyResult is the result of the last apply of a child resource. The apply
+// results are reported in the status.applyResults field of the parent
+// resource.
+type ApplyResult struct {
+	ChildReference `json:",inline"`
+
+	// Operation is the operation that is done during the last apply.
+	Operation ApplyOperation `json:"operation"`
+
+	// LastApplyTime is the time of the last apply.
+	LastApplyTime metav1.Time `json:"lastApplyTime"`
+
+	// Message is the truncated error message if the apply failed.
+	Message string `json:"message,omitempty"`
+}
+
+// NewApplyResult returns the ApplyResult of the given child resource.
+func NewApplyResult(o resource.ChildResource, op ApplyOperation, err error) ApplyResult {
+	r := ApplyResult{
+		ChildReference: NewInventory([]resource.ChildResource{o})[0],
+		Operation:      op,
+		LastApplyTime:  metav1.Now(),
+	}
+	if err != nil {
+		r.Operation = ApplyOperationFailed
+		r.Message = truncateMessage(err.Error())
+	}
+	return r
+}
+
+// truncateMessage returns the given message cut to at most
+// MaxApplyResultMessageLength bytes without splitting a multi-byte rune.
+func truncateMessage(msg string) string {
+	if len(msg) <= MaxApplyResultMessageLength {
+		return msg
+	}
+	i := MaxApplyResultMessageLength
+	for i > 0 && !utf8.RuneStart(msg[i]) {
+		i--
+	}
+	return msg[:i]
+}
+
+// SetApplyResults sets the status.applyResults field of the given parent
+// resource.
+func SetApplyResults(cr interface{ UnstructuredContent() map[string]interface{} }, results []ApplyResult) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	list := []interface{}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(cr.UnstructuredContent(), list, "status", "applyResults")
+}
+
+// applyChild applies the given child resource and returns the operation that
+// is done on it. A cluster-scoped child resource that is controlled by another
+// parent resource is not applied and a ContentionError is returned. The child
+// resources without a controller are adopted.
+func applyChild(ctx context.Context, a *apply.Applier, cr resource.ParentResource, o resource.ChildResource) (ApplyOperation, error) {
+	noContention := func(_ context.Context, current, desired runtime.Object) error {
+		c, cok := current.(resource.ChildResource)
+		d, dok := desired.(resource.ChildResource)
+		if !cok || !dok {
+			return nil
+		}
+		return contention(cr, d, c)
+	}
+	op, err := a.Apply(ctx, o, noContention, apply.OwnershipGuard(cr.GetUID(), true))
+	if err != nil {
+		return ApplyOperationFailed, err
+	}
+	return ApplyOperation(op), nil
+}
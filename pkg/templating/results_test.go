@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestApplyResults(t *testing.T) {
+	child := fake.NewMockResource(fake.WithGVK(fake.MockChildGVK), fake.WithNamespaceName(fakeName, fakeNamespace))
+	results := []ApplyResult{
+		NewApplyResult(child, ApplyOperationCreated, nil),
+		NewApplyResult(child, ApplyOperationPatched, errors.New(strings.Repeat("a", 2*MaxApplyResultMessageLength))),
+		NewApplyResult(child, ApplyOperationPatched, errors.New("a"+strings.Repeat("é", MaxApplyResultMessageLength))),
+	}
+	want := []ApplyResult{
+		{
+			ChildReference: ChildReference{APIVersion: fake.MockChildGVK.GroupVersion().String(), Kind: fake.MockChildGVK.Kind, Namespace: fakeNamespace, Name: fakeName},
+			Operation:      ApplyOperationCreated,
+		},
+		{
+			ChildReference: ChildReference{APIVersion: fake.MockChildGVK.GroupVersion().String(), Kind: fake.MockChildGVK.Kind, Namespace: fakeNamespace, Name: fakeName},
+			Operation:      ApplyOperationFailed,
+			Message:        strings.Repeat("a", MaxApplyResultMessageLength),
+		},
+		{
+			ChildReference: ChildReference{APIVersion: fake.MockChildGVK.GroupVersion().String(), Kind: fake.MockChildGVK.Kind, Namespace: fakeNamespace, Name: fakeName},
+			Operation:      ApplyOperationFailed,
+			// NOTE: The last rune that fits is split, so it's dropped.
+			Message: "a" + strings.Repeat("é", MaxApplyResultMessageLength/2-1),
+		},
+	}
+	if diff := cmp.Diff(want, results, cmpopts.IgnoreFields(ApplyResult{}, "LastApplyTime")); diff != "" {
+		t.Errorf("NewApplyResult(...): -want, +got:\n%s", diff)
+	}
+
+	cr := fake.NewMockResource()
+	if err := SetApplyResults(cr, results); err != nil {
+		t.Fatalf("SetApplyResults(...): unexpected error: %v", err)
+	}
+	got, _, _ := unstructured.NestedSlice(cr.Object, "status", "applyResults")
+	if diff := cmp.Diff(3, len(got)); diff != "" {
+		t.Errorf("SetApplyResults(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// RolloutMaxUnavailableAnnotationKey is the annotation on the
+	// StackDefinition that rolls a new revision of the template source out
+	// gradually. Its value is the number of parent resources that can be
+	// upgraded to the new revision at the same time.
+	RolloutMaxUnavailableAnnotationKey = "templatestacks.crossplane.io/rollout-max-unavailable"
+
+	// RolloutMaxFailureRatioAnnotationKey is the annotation on the
+	// StackDefinition whose value is the ratio of the upgraded parent
+	// resources that can fail before the rollout is paused, e.g. "0.1".
+	RolloutMaxFailureRatioAnnotationKey = "templatestacks.crossplane.io/rollout-max-failure-ratio"
+
+	errParseMaxUnavailable   = "the maximum number of unavailable instances must be a positive integer"
+	errParseMaxFailureRatio  = "the maximum failure ratio must be a number between 0 and 1"
+	errFmtRolloutUnavailable = "waiting for %d instances to be upgraded to template revision %s"
+	errFmtRolloutPaused      = "rollout of template revision %s is paused since %d of %d upgraded instances are failing"
+)
+
+// TypeRolloutPending indicates whether the parent resource is waiting to be
+// upgraded to the new revision of the template source.
+const TypeRolloutPending v1alpha1.ConditionType = "RolloutPending"
+
+// Reasons the parent resource is or is not waiting for the rollout.
+const (
+	ReasonRolloutPending  v1alpha1.ConditionReason = "Waiting to be upgraded to the new template revision"
+	ReasonRolloutAdmitted v1alpha1.ConditionReason = "Upgraded to the new template revision"
+)
+
+// RolloutPending returns a condition that indicates the parent resource is
+// waiting to be upgraded to the new revision of the template source.
+func RolloutPending(err error) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeRolloutPending,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRolloutPending,
+		Message:            err.Error(),
+	}
+}
+
+// RolloutAdmitted returns a condition that indicates the parent resource is
+// upgraded to the new revision of the template source.
+func RolloutAdmitted() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeRolloutPending,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRolloutAdmitted,
+	}
+}
+
+// ParseMaxUnavailable parses the given maximum number of unavailable parent
+// resources, typically the value of RolloutMaxUnavailableAnnotationKey
+// annotation.
+func ParseMaxUnavailable(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n <= 0 {
+		return 0, errors.New(errParseMaxUnavailable)
+	}
+	return n, nil
+}
+
+// ParseMaxFailureRatio parses the given maximum failure ratio, typically the
+// value of RolloutMaxFailureRatioAnnotationKey annotation.
+func ParseMaxFailureRatio(s string) (float64, error) {
+	r, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || r < 0 || r > 1 {
+		return 0, errors.New(errParseMaxFailureRatio)
+	}
+	return r, nil
+}
+
+// TemplateRevisionOf returns the status.templateRevision field of the given
+// parent resource, which is the short revision of the template source that
+// its child resources were last applied with.
+func TemplateRevisionOf(cr interface{ UnstructuredContent() map[string]interface{} }) string {
+	rev, _, _ := unstructured.NestedString(cr.UnstructuredContent(), "status", "templateRevision")
+	return rev
+}
+
+// SetTemplateRevision sets the status.templateRevision field of the given
+// parent resource to the short form of the given revision.
+func SetTemplateRevision(cr interface{ UnstructuredContent() map[string]interface{} }, revision string) error {
+	return unstructured.SetNestedField(cr.UnstructuredContent(), resource.ShortHash(revision), "status", "templateRevision")
+}
+
+// NewRollout returns a new *Rollout of the given revision of the template
+// source that upgrades at most the given number of parent resources at the
+// same time, and pauses when more than the given ratio of the upgraded ones
+// are failing.
+func NewRollout(revision string, maxUnavailable int, maxFailureRatio float64) *Rollout {
+	return &Rollout{
+		revision:        resource.ShortHash(revision),
+		maxUnavailable:  maxUnavailable,
+		maxFailureRatio: maxFailureRatio,
+		upgrading:       map[types.NamespacedName]bool{},
+	}
+}
+
+// A Rollout upgrades the parent resources to a new revision of the template
+// source gradually instead of re-rendering all of them at once when the
+// controller starts with the new revision. A parent resource is upgrading
+// from the reconciliation it's admitted to until a reconciliation of it
+// succeeds, and the parent resources that are not admitted are not
+// reconciled, so their child resources stay as they were applied by the
+// previous revision. The parent resources that have never been applied, and
+// the ones that are already upgraded, are always admitted. The state of the
+// rollout is kept in memory, so a restart of the controller starts counting
+// from scratch, while the upgraded parent resources are remembered in their
+// status.templateRevision field.
+type Rollout struct {
+	revision        string
+	maxUnavailable  int
+	maxFailureRatio float64
+
+	mu sync.Mutex
+	// upgrading are the parent resources that are admitted but not
+	// upgraded yet, and whether their last reconciliation failed.
+	upgrading map[types.NamespacedName]bool
+	admitted  int
+}
+
+// Revision returns the short revision of the template source that is rolled
+// out.
+func (r *Rollout) Revision() string {
+	return r.revision
+}
+
+// Admit returns nil if the given parent resource can be reconciled with the
+// new revision, or an error that says why it has to wait otherwise.
+func (r *Rollout) Admit(cr resource.ParentResource) error {
+	if !r.outdated(cr) {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := keyOf(cr)
+	if _, ok := r.upgrading[key]; ok {
+		return nil
+	}
+	failing := 0
+	for _, failed := range r.upgrading {
+		if failed {
+			failing++
+		}
+	}
+	if failing > 0 && float64(failing) > r.maxFailureRatio*float64(r.admitted) {
+		return errors.Errorf(errFmtRolloutPaused, r.revision, failing, r.admitted)
+	}
+	if len(r.upgrading) >= r.maxUnavailable {
+		return errors.Errorf(errFmtRolloutUnavailable, len(r.upgrading), r.revision)
+	}
+	r.upgrading[key] = false
+	r.admitted++
+	return nil
+}
+
+// Observe records the result of a reconciliation of the given admitted
+// parent resource. A parent resource that is reconciled successfully, or
+// deleted, is not upgrading anymore.
+func (r *Rollout) Observe(cr resource.ParentResource, succeeded bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := keyOf(cr)
+	if _, ok := r.upgrading[key]; !ok {
+		return
+	}
+	if succeeded || meta.WasDeleted(cr) {
+		delete(r.upgrading, key)
+		return
+	}
+	r.upgrading[key] = true
+}
+
+// outdated returns true if the child resources of the given parent resource
+// were applied with another revision of the template source. The parent
+// resources that were applied before their revision was recorded are
+// outdated too.
+func (r *Rollout) outdated(cr resource.ParentResource) bool {
+	if rev := TemplateRevisionOf(cr); rev != "" {
+		return rev != r.revision
+	}
+	refs, _, _ := unstructured.NestedSlice(cr.UnstructuredContent(), "status", "resourceRefs")
+	return len(refs) > 0
+}
+
+// rolloutPending returns true if the given parent resource is marked as
+// waiting for the rollout.
+func rolloutPending(cr resource.ParentResource) bool {
+	c, err := resource.GetCondition(cr, TypeRolloutPending)
+	return err == nil && c.Status == corev1.ConditionTrue
+}
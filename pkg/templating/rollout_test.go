@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestRollout(t *testing.T) {
+	ro := NewRollout("new", 2, 0.5)
+	parent := func(name, revision string) resource.ParentResource {
+		cr := fake.NewMockResource()
+		cr.SetName(name)
+		if revision != "" {
+			_ = SetTemplateRevision(cr, revision)
+		}
+		return cr
+	}
+	admit := func(cr resource.ParentResource, want error) {
+		t.Helper()
+		if diff := cmp.Diff(fmt.Sprint(want), fmt.Sprint(ro.Admit(cr))); diff != "" {
+			t.Errorf("Admit(%s): -want, +got:\n%s", cr.GetName(), diff)
+		}
+	}
+	a, b, c, d := parent("a", "old"), parent("b", "old"), parent("c", "old"), parent("d", "old")
+
+	// The parent resources that were never applied, and the upgraded ones,
+	// should always be admitted.
+	admit(parent("fresh", ""), nil)
+	admit(parent("upgraded", "new"), nil)
+
+	// The parent resources that were applied before their revision was
+	// recorded should be outdated.
+	applied := parent("applied", "")
+	_ = unstructured.SetNestedSlice(applied.UnstructuredContent(), []interface{}{map[string]interface{}{"name": "cm"}}, "status", "resourceRefs")
+	if !ro.outdated(applied) {
+		t.Errorf("outdated(...): a parent resource with child resources and no revision should be outdated")
+	}
+
+	// At most maxUnavailable parent resources should be upgraded at once.
+	admit(a, nil)
+	admit(b, nil)
+	admit(a, nil)
+	admit(c, errors.Errorf(errFmtRolloutUnavailable, 2, "new"))
+
+	// A successful reconciliation should free a slot.
+	ro.Observe(a, true)
+	admit(c, nil)
+
+	// The rollout should be paused once more than the maximum ratio of the
+	// upgraded parent resources are failing.
+	ro.Observe(b, false)
+	admit(d, errors.Errorf(errFmtRolloutUnavailable, 2, "new"))
+	ro.Observe(c, false)
+	admit(d, errors.Errorf(errFmtRolloutPaused, "new", 2, 3))
+
+	// The rollout should resume once the failing ones recover.
+	ro.Observe(b, true)
+	ro.Observe(c, true)
+	admit(d, nil)
+}
+
+func TestParseMaxFailureRatio(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		s      string
+		want   float64
+		err    error
+	}{
+		"Valid": {
+			reason: "A ratio between 0 and 1 should be parsed.",
+			s:      " 0.25 ",
+			want:   0.25,
+		},
+		"OutOfRange": {
+			reason: "A ratio greater than 1 should be rejected.",
+			s:      "1.5",
+			err:    errors.New(errParseMaxFailureRatio),
+		},
+		"NotANumber": {
+			reason: "A value that is not a number should be rejected.",
+			s:      "ten percent",
+			err:    errors.New(errParseMaxFailureRatio),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMaxFailureRatio(tc.s)
+			if diff := cmp.Diff(fmt.Sprint(tc.err), fmt.Sprint(err)); diff != "" {
+				t.Errorf("\n%s\nParseMaxFailureRatio(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nParseMaxFailureRatio(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// ScaleAnnotationKey is the annotation on the StackDefinition whose value is
+// the YAML representation of the Scale of the parent resources.
+const ScaleAnnotationKey = "templatestacks.crossplane.io/scale"
+
+// Default paths of the scale subresource of the parent resources.
+const (
+	DefaultSpecReplicasPath   = ".spec.replicas"
+	DefaultStatusReplicasPath = ".status.replicas"
+	DefaultLabelSelectorPath  = ".status.selector"
+)
+
+const (
+	errParseScale          = "could not parse the scale"
+	errFmtScalePath        = "%s must be a path under %s"
+	errScaleTargetKind     = "the kind of the scale target is required"
+	errFmtScaleTarget      = "cannot find the scale target %s %s among the child resources"
+	errFmtScaleTargetType  = "cannot set the replicas of the scale target %s %s of type %T"
+	errFmtScaleReplicas    = "the replicas at %s are not an integer"
+	errGetScaleTarget      = "cannot get the scale target"
+	errScaleTargetSelector = "cannot convert the selector of the scale target"
+)
+
+// A Scale makes the parent resources scalable through the scale subresource
+// of their CustomResourceDefinition, e.g. with kubectl scale or a
+// HorizontalPodAutoscaler, by binding their replicas to those of a child
+// resource.
+type Scale struct {
+	// SpecReplicasPath is the JSON path of the desired replicas in the parent
+	// resource. Defaults to .spec.replicas.
+	SpecReplicasPath string `json:"specReplicasPath,omitempty"`
+
+	// StatusReplicasPath is the JSON path of the observed replicas in the
+	// parent resource. Defaults to .status.replicas.
+	StatusReplicasPath string `json:"statusReplicasPath,omitempty"`
+
+	// LabelSelectorPath is the JSON path of the label selector of the pods in
+	// the parent resource. Defaults to .status.selector.
+	LabelSelectorPath string `json:"labelSelectorPath,omitempty"`
+
+	// Target is the child resource whose spec.replicas is set to the desired
+	// replicas, and whose status.replicas and spec.selector are reported.
+	Target ScaleTarget `json:"target"`
+}
+
+// A ScaleTarget identifies the child resource that a Scale binds to.
+type ScaleTarget struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// Name of the child resource as it's rendered. The first child resource
+	// of the kind is the target if it's empty.
+	Name string `json:"name,omitempty"`
+}
+
+// ParseScale parses the given YAML representation of a Scale, typically the
+// value of ScaleAnnotationKey annotation, and fills in the default paths.
+func ParseScale(data string) (Scale, error) {
+	s := Scale{}
+	if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+		return Scale{}, errors.Wrap(err, errParseScale)
+	}
+	if s.SpecReplicasPath == "" {
+		s.SpecReplicasPath = DefaultSpecReplicasPath
+	}
+	if s.StatusReplicasPath == "" {
+		s.StatusReplicasPath = DefaultStatusReplicasPath
+	}
+	if s.LabelSelectorPath == "" {
+		s.LabelSelectorPath = DefaultLabelSelectorPath
+	}
+	for _, p := range []struct{ path, under string }{
+		{path: s.SpecReplicasPath, under: ".spec."},
+		{path: s.StatusReplicasPath, under: ".status."},
+		{path: s.LabelSelectorPath, under: ".status."},
+	} {
+		if !strings.HasPrefix(p.path, p.under) || len(p.path) == len(p.under) {
+			return Scale{}, errors.Errorf(errFmtScalePath, p.path, strings.TrimSuffix(p.under, "."))
+		}
+	}
+	if s.Target.Kind == "" {
+		return Scale{}, errors.New(errScaleTargetKind)
+	}
+	return s, nil
+}
+
+// Subresource returns the scale subresource of the CustomResourceDefinition
+// of the parent resources.
+func (s Scale) Subresource() *apiextensionsv1.CustomResourceSubresourceScale {
+	selector := s.LabelSelectorPath
+	return &apiextensionsv1.CustomResourceSubresourceScale{
+		SpecReplicasPath:   s.SpecReplicasPath,
+		StatusReplicasPath: s.StatusReplicasPath,
+		LabelSelectorPath:  &selector,
+	}
+}
+
+// target returns the child resource in the given list that the Scale binds
+// to, or nil if there is none.
+func (s Scale) target(list []resource.ChildResource) resource.ChildResource {
+	gk := schema.FromAPIVersionAndKind(s.Target.APIVersion, s.Target.Kind).GroupKind()
+	for _, o := range list {
+		if o.GetObjectKind().GroupVersionKind().GroupKind() != gk {
+			continue
+		}
+		if s.Target.Name == "" || o.GetName() == s.Target.Name {
+			return o
+		}
+	}
+	return nil
+}
+
+// NewScalePatcher returns a ChildResourcePatcher that sets the spec.replicas
+// field of the target of the given Scale to the desired replicas of the
+// parent resource. The target is left as rendered if the parent resource
+// doesn't declare its replicas.
+func NewScalePatcher(s Scale) ChildResourcePatcherFunc {
+	return func(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+		replicas, ok, err := unstructured.NestedInt64(cr.UnstructuredContent(), fieldsOf(s.SpecReplicasPath)...)
+		if err != nil {
+			return nil, &resource.ValuesError{Path: strings.TrimPrefix(s.SpecReplicasPath, "."), Err: errors.Wrapf(err, errFmtScaleReplicas, s.SpecReplicasPath)}
+		}
+		if !ok {
+			return list, nil
+		}
+		t := s.target(list)
+		if t == nil {
+			return nil, errors.Errorf(errFmtScaleTarget, s.Target.Kind, s.Target.Name)
+		}
+		u, ok := t.(interface{ UnstructuredContent() map[string]interface{} })
+		if !ok {
+			return nil, errors.Errorf(errFmtScaleTargetType, s.Target.Kind, t.GetName(), t)
+		}
+		return list, unstructured.SetNestedField(u.UnstructuredContent(), replicas, "spec", "replicas")
+	}
+}
+
+// NewScaleStatusWriter returns a new *ScaleStatusWriter that reports the
+// replicas and the selector of the target of the given Scale and writes the
+// status with the given StatusWriter.
+func NewScaleStatusWriter(c client.Client, s Scale, w StatusWriter) *ScaleStatusWriter {
+	return &ScaleStatusWriter{client: c, scale: s, status: w}
+}
+
+// A ScaleStatusWriter sets the observed replicas and the label selector of
+// the scale subresource of a parent resource from the target of its Scale.
+type ScaleStatusWriter struct {
+	client client.Client
+	scale  Scale
+	status StatusWriter
+}
+
+// WriteStatus reports the scale of the given parent resource and writes its
+// status. The scale is left as is if the child resources could not be
+// rendered.
+func (w *ScaleStatusWriter) WriteStatus(ctx context.Context, cr resource.ParentResource, o Observation) error {
+	if t := w.scale.target(o.Children); t != nil {
+		if err := w.observe(ctx, cr, t); err != nil {
+			return err
+		}
+	}
+	return w.status.WriteStatus(ctx, cr, o)
+}
+
+// observe sets the observed replicas and the label selector of the given
+// parent resource from the live state of the given target.
+func (w *ScaleStatusWriter) observe(ctx context.Context, cr resource.ParentResource, t resource.ChildResource) error {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(t.GetObjectKind().GroupVersionKind())
+	err := w.client.Get(ctx, types.NamespacedName{Namespace: t.GetNamespace(), Name: t.GetName()}, live)
+	if client.IgnoreNotFound(err) != nil {
+		return errors.Wrap(err, errGetScaleTarget)
+	}
+	if kerrors.IsNotFound(err) {
+		// NOTE: The target is not created yet, e.g. in dry-run mode, so none
+		// of its replicas exist.
+		live = &unstructured.Unstructured{Object: map[string]interface{}{}}
+	}
+	replicas, _, _ := unstructured.NestedInt64(live.Object, "status", "replicas")
+	if err := unstructured.SetNestedField(cr.UnstructuredContent(), replicas, fieldsOf(w.scale.StatusReplicasPath)...); err != nil {
+		return err
+	}
+	raw, ok, _ := unstructured.NestedMap(live.Object, "spec", "selector")
+	if !ok {
+		return nil
+	}
+	ls := &metav1.LabelSelector{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, ls); err != nil {
+		return errors.Wrap(err, errScaleTargetSelector)
+	}
+	sel, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return errors.Wrap(err, errScaleTargetSelector)
+	}
+	return unstructured.SetNestedField(cr.UnstructuredContent(), sel.String(), fieldsOf(w.scale.LabelSelectorPath)...)
+}
+
+// fieldsOf returns the fields of the given JSON path, e.g. .spec.replicas.
+func fieldsOf(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "."), ".")
+}
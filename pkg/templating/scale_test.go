@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestParseScale(t *testing.T) {
+	type want struct {
+		s   Scale
+		err error
+	}
+	cases := map[string]struct {
+		reason string
+		data   string
+		want   want
+	}{
+		"Defaults": {
+			reason: "The default paths should be used if none is given.",
+			data:   "target: {apiVersion: apps/v1, kind: Deployment}",
+			want: want{s: Scale{
+				SpecReplicasPath:   DefaultSpecReplicasPath,
+				StatusReplicasPath: DefaultStatusReplicasPath,
+				LabelSelectorPath:  DefaultLabelSelectorPath,
+				Target:             ScaleTarget{APIVersion: "apps/v1", Kind: "Deployment"},
+			}},
+		},
+		"PathOutsideSpec": {
+			reason: "The desired replicas should be rejected if they are not under spec.",
+			data:   "specReplicasPath: .status.replicas\ntarget: {apiVersion: apps/v1, kind: Deployment}",
+			want:   want{err: errors.Errorf(errFmtScalePath, ".status.replicas", ".spec")},
+		},
+		"NoTarget": {
+			reason: "A scale without the kind of its target should be rejected.",
+			data:   "specReplicasPath: .spec.size",
+			want:   want{err: errors.New(errScaleTargetKind)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseScale(tc.data)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nParseScale(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.s, got); diff != "" {
+				t.Errorf("\n%s\nParseScale(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func newDeployment(name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("apps/v1")
+	u.SetKind("Deployment")
+	u.SetNamespace("default")
+	u.SetName(name)
+	return u
+}
+
+func TestScalePatcher(t *testing.T) {
+	s := Scale{
+		SpecReplicasPath: DefaultSpecReplicasPath,
+		Target:           ScaleTarget{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+	}
+	scaled := newDeployment("web")
+	_ = unstructured.SetNestedField(scaled.Object, int64(3), "spec", "replicas")
+
+	type want struct {
+		list []resource.ChildResource
+		err  error
+	}
+	cases := map[string]struct {
+		reason   string
+		replicas interface{}
+		list     []resource.ChildResource
+		want     want
+	}{
+		"Scaled": {
+			reason:   "The replicas of the target should be set to the desired replicas of the parent resource.",
+			replicas: int64(3),
+			list:     []resource.ChildResource{newDeployment("worker"), newDeployment("web")},
+			want:     want{list: []resource.ChildResource{newDeployment("worker"), scaled}},
+		},
+		"NotDeclared": {
+			reason: "The target should be left as rendered if the parent resource doesn't declare its replicas.",
+			list:   []resource.ChildResource{newDeployment("web")},
+			want:   want{list: []resource.ChildResource{newDeployment("web")}},
+		},
+		"NoTarget": {
+			reason:   "An error should be returned if the target is not rendered.",
+			replicas: int64(3),
+			list:     []resource.ChildResource{newDeployment("worker")},
+			want:     want{err: errors.Errorf(errFmtScaleTarget, "Deployment", "web")},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := fake.NewMockResource()
+			if tc.replicas != nil {
+				cr.Object["spec"] = map[string]interface{}{"replicas": tc.replicas}
+			}
+			got, err := NewScalePatcher(s).Patch(context.Background(), cr, tc.list)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.list, got); diff != "" {
+				t.Errorf("\n%s\nPatch(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestScaleStatusWriter(t *testing.T) {
+	s := Scale{
+		StatusReplicasPath: DefaultStatusReplicasPath,
+		LabelSelectorPath:  DefaultLabelSelectorPath,
+		Target:             ScaleTarget{APIVersion: "apps/v1", Kind: "Deployment"},
+	}
+	type want struct {
+		status interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason string
+		get    test.MockGetFn
+		want   want
+	}{
+		"Observed": {
+			reason: "The replicas and the selector of the target should be reported.",
+			get: func(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+				u := obj.(*unstructured.Unstructured)
+				_ = unstructured.SetNestedField(u.Object, int64(2), "status", "replicas")
+				_ = unstructured.SetNestedStringMap(u.Object, map[string]string{"app": "web"}, "spec", "selector", "matchLabels")
+				return nil
+			},
+			want: want{status: map[string]interface{}{
+				"replicas":           int64(2),
+				"selector":           "app=web",
+				"observedGeneration": int64(0),
+			}},
+		},
+		"NotCreated": {
+			reason: "No replicas should be reported if the target doesn't exist yet.",
+			get:    test.NewMockGetFn(kerrors.NewNotFound(schema.GroupResource{}, "web")),
+			want: want{status: map[string]interface{}{
+				"replicas":           int64(0),
+				"observedGeneration": int64(0),
+			}},
+		},
+		"GetFailed": {
+			reason: "An error should be returned if the target cannot be read.",
+			get:    test.NewMockGetFn(errBoom),
+			want:   want{err: errors.Wrap(errBoom, errGetScaleTarget)},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := fake.NewMockResource()
+			c := &test.MockClient{MockGet: tc.get, MockStatusUpdate: test.NewMockStatusUpdateFn(nil)}
+			w := NewScaleStatusWriter(c, s, NewAPIStatusWriter(c))
+			err := w.WriteStatus(context.Background(), cr, Observation{Children: []resource.ChildResource{newDeployment("web")}})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nWriteStatus(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, cr.Object["status"]); diff != "" {
+				t.Errorf("\n%s\nWriteStatus(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/openapi"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// SchemaValidationAnnotationKey is the annotation on the StackDefinition that
+// makes the controller validate the rendered child resources against the
+// schemas of their CustomResourceDefinitions when its value is "true".
+const SchemaValidationAnnotationKey = "templatestacks.crossplane.io/schema-validation"
+
+const (
+	errGetCRD             = "cannot get the CustomResourceDefinition of the child resource"
+	errConvertCRD         = "cannot convert the CustomResourceDefinition of the child resource"
+	errFmtSchemaViolation = "child resources do not conform to their schemas: %s"
+)
+
+// A SchemaViolation is a field of a child resource that does not conform to
+// the schema of its kind.
+type SchemaViolation struct {
+	Child ChildReference
+	Error *field.Error
+}
+
+func (v SchemaViolation) String() string {
+	ns := ""
+	if v.Child.Namespace != "" {
+		ns = v.Child.Namespace + "/"
+	}
+	return fmt.Sprintf("%s %s%s: %s", v.Child.Kind, ns, v.Child.Name, v.Error.Error())
+}
+
+// A SchemaError is returned when the child resources do not conform to the
+// schemas of their kinds.
+type SchemaError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return fmt.Sprintf(errFmtSchemaViolation, strings.Join(s, ", "))
+}
+
+// A SchemaValidator checks the rendered child resources against the schemas
+// of their kinds.
+type SchemaValidator interface {
+	Validate(ctx context.Context, list []resource.ChildResource) ([]SchemaViolation, error)
+}
+
+// NewCRDSchemaValidator returns a new *CRDSchemaValidator.
+func NewCRDSchemaValidator(c client.Reader, m meta.RESTMapper) *CRDSchemaValidator {
+	return &CRDSchemaValidator{client: c, mapper: m}
+}
+
+// A CRDSchemaValidator checks the child resources against the OpenAPI v3
+// schemas of their CustomResourceDefinitions in the cluster. The child
+// resources whose kinds are not served by a CustomResourceDefinition, e.g.
+// the built-in kinds and the kinds whose CustomResourceDefinitions are not
+// established yet, are not checked.
+type CRDSchemaValidator struct {
+	client client.Reader
+	mapper meta.RESTMapper
+}
+
+// Validate returns the fields of the given child resources that do not
+// conform to their schemas in the order of the child resources.
+func (v *CRDSchemaValidator) Validate(ctx context.Context, list []resource.ChildResource) ([]SchemaViolation, error) {
+	schemas := map[schema.GroupVersionKind]*apiextensionsv1.JSONSchemaProps{}
+	var result []SchemaViolation
+	for i, ref := range NewInventory(list) {
+		u, ok := list[i].(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		gvk := u.GroupVersionKind()
+		s, ok := schemas[gvk]
+		if !ok {
+			var err error
+			if s, err = v.schemaOf(ctx, gvk); err != nil {
+				return nil, err
+			}
+			schemas[gvk] = s
+		}
+		if s == nil {
+			continue
+		}
+		for _, err := range openapi.Validate(u.Object, s) {
+			result = append(result, SchemaViolation{Child: ref, Error: err})
+		}
+	}
+	return result, nil
+}
+
+// schemaOf returns the schema of the given kind in its
+// CustomResourceDefinition, or nil if it has none.
+func (v *CRDSchemaValidator) schemaOf(ctx context.Context, gvk schema.GroupVersionKind) (*apiextensionsv1.JSONSchemaProps, error) {
+	if gvk.Group == "" {
+		return nil, nil
+	}
+	m, err := v.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCRD)
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition"))
+	err = v.client.Get(ctx, types.NamespacedName{Name: m.Resource.Resource + "." + gvk.Group}, u)
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCRD)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, crd); err != nil {
+		return nil, errors.Wrap(err, errConvertCRD)
+	}
+	for _, ver := range crd.Spec.Versions {
+		if ver.Name == gvk.Version && ver.Schema != nil {
+			return ver.Schema.OpenAPIV3Schema, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateSchemas checks the given child resources with the configured
+// SchemaValidator and returns a SchemaError if any of them does not conform
+// to its schema.
+func (r *Reconciler) validateSchemas(ctx context.Context, list []resource.ChildResource) error {
+	violations, err := r.schemas.Validate(ctx, list)
+	if err != nil {
+		return err
+	}
+	if len(violations) != 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestCRDSchemaValidator(t *testing.T) {
+	database := schema.GroupVersionKind{Group: "database.example.org", Version: "v1alpha1", Kind: "Database"}
+	bucket := schema.GroupVersionKind{Group: "storage.example.org", Version: "v1alpha1", Kind: "Bucket"}
+	configMap := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(database, meta.RESTScopeNamespace)
+	mapper.Add(bucket, meta.RESTScopeNamespace)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{{
+				Name: "v1alpha1",
+				Schema: &apiextensionsv1.CustomResourceValidation{OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {
+							Type:       "object",
+							Required:   []string{"engine"},
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{"engine": {Type: "string"}},
+						},
+					},
+				}},
+			}},
+		},
+	}
+	child := func(gvk schema.GroupVersionKind, name string, spec map[string]interface{}) resource.ChildResource {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(gvk)
+		u.SetName(name)
+		return u
+	}
+	var got []string
+	c := &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+			got = append(got, key.Name)
+			if key.Name != "databases.database.example.org" {
+				return kerrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+			obj.(*unstructured.Unstructured).SetUnstructuredContent(m)
+			return err
+		},
+	}
+	list := []resource.ChildResource{
+		child(database, "valid", map[string]interface{}{"engine": "postgres"}),
+		child(database, "invalid", map[string]interface{}{"size": int64(20)}),
+		child(bucket, "unknown", map[string]interface{}{"size": int64(20)}),
+		child(configMap, "builtin", map[string]interface{}{"size": int64(20)}),
+	}
+	violations, err := NewCRDSchemaValidator(c, mapper).Validate(context.Background(), list)
+	if err != nil {
+		t.Fatalf("Validate(...): unexpected error: %v", err)
+	}
+	want := []SchemaViolation{
+		{Child: ChildReference{APIVersion: "database.example.org/v1alpha1", Kind: "Database", Name: "invalid"}, Error: field.Required(field.NewPath("spec", "engine"), "")},
+		{Child: ChildReference{APIVersion: "database.example.org/v1alpha1", Kind: "Database", Name: "invalid"}, Error: field.Forbidden(field.NewPath("spec", "size"), "unknown field")},
+	}
+	if diff := cmp.Diff(want, violations); diff != "" {
+		t.Errorf("Validate(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"databases.database.example.org", "buckets.storage.example.org"}, got); diff != "" {
+		t.Errorf("Validate(...): want the CustomResourceDefinitions to be read once per kind, -want, +got:\n%s", diff)
+	}
+
+	c.MockGet = test.NewMockGetFn(errBoom)
+	if _, err := NewCRDSchemaValidator(c, mapper).Validate(context.Background(), list[:1]); !errors.Is(err, errBoom) {
+		t.Errorf("Validate(...): want the error of reading the CustomResourceDefinition, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+)
+
+const (
+	errSetupNoGVK = "group, version and kind of the parent resource must be given"
+	errSetup      = "cannot set up the templating controller"
+)
+
+// SetupOptions configure the templating controller that is registered by
+// Setup.
+type SetupOptions struct {
+	// GVK is the GroupVersionKind of the parent resource. Required.
+	GVK schema.GroupVersionKind
+
+	// Engine renders the child resources. Defaults to NopEngine.
+	Engine Engine
+
+	// Patchers are appended to the default ChildResourcePatchers.
+	Patchers []ChildResourcePatcher
+
+	// Deleter replaces the default APIOrderedDeleter if given.
+	Deleter ChildResourceDeleter
+
+	// Predicates filter the events of the parent resource.
+	Predicates []predicate.Predicate
+
+	// Watches trigger the reconciliation of the parent resources when other
+	// resources change.
+	Watches []Watch
+
+	// Concurrency is the maximum number of concurrent reconciles. Defaults
+	// to 1.
+	Concurrency int
+
+	// Options are applied to the reconciler after the ones derived from the
+	// fields above.
+	Options []ReconcilerOption
+}
+
+// A Watch triggers the reconciliation of the parent resources that its Handler
+// maps the events of the resources of its Kind to.
+type Watch struct {
+	Kind    schema.GroupVersionKind
+	Handler handler.EventHandler
+}
+
+// Setup registers a templating controller for the parent resource with the
+// given options to the given manager. It lets other operators embed the
+// templating controller as a library.
+func Setup(mgr manager.Manager, o SetupOptions) error {
+	if o.GVK.Empty() {
+		return errors.New(errSetupNoGVK)
+	}
+	opts := []ReconcilerOption{WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(NameOf(o.GVK))))}
+	if o.Engine != nil {
+		opts = append(opts, WithEngine(o.Engine))
+	}
+	if len(o.Patchers) != 0 {
+		opts = append(opts, WithAdditionalChildResourcePatcher(o.Patchers...))
+	}
+	if o.Deleter != nil {
+		opts = append(opts, WithChildResourceDeleter(o.Deleter))
+	}
+	opts = append(opts, o.Options...)
+
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(o.GVK)
+	// NOTE: The predicates apply only to the parent resource since the
+	// watched resources are filtered by their handlers.
+	b := ctrl.NewControllerManagedBy(mgr).
+		Named(NameOf(o.GVK)).
+		For(u, builder.WithPredicates(o.Predicates...)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: o.Concurrency})
+	for _, w := range o.Watches {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(w.Kind)
+		b = b.Watches(&source.Kind{Type: obj}, w.Handler)
+	}
+	return errors.Wrap(b.Complete(NewReconciler(mgr, o.GVK, opts...)), errSetup)
+}
+
+// NameOf returns the name of the templating controller of the parent resource
+// with the given GroupVersionKind.
+func NameOf(gvk schema.GroupVersionKind) string {
+	return strings.ToLower(gvk.GroupKind().String())
+}
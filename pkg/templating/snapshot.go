@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ValuesSnapshotAnnotationKey is the annotation on the StackDefinition
+	// that enables the values snapshots when its value is "true".
+	ValuesSnapshotAnnotationKey = "templatestacks.crossplane.io/values-snapshot"
+
+	// ValuesSnapshotConfigMapPrefix is the prefix of the name of the
+	// ConfigMap that keeps the values snapshot of a parent resource. It's
+	// followed by the UID of the parent resource.
+	ValuesSnapshotConfigMapPrefix = "values-snapshot-"
+
+	// RedactedValue replaces the redacted values in the snapshots.
+	RedactedValue = "REDACTED"
+
+	snapshotTimeout = 10 * time.Second
+
+	errComputeValues  = "cannot compute values"
+	errMarshalValues  = "cannot marshal values snapshot"
+	errStoreSnapshot  = "cannot store values snapshot"
+	errSnapshotValues = "cannot take values snapshot"
+)
+
+// DefaultRedactedKeys are the case-insensitive substrings of the keys whose
+// values are redacted in the snapshots.
+var DefaultRedactedKeys = []string{"password", "secret", "token", "credential", "apikey", "privatekey"}
+
+// A ValuesComputer computes the values that it would render the given parent
+// resource with. Engines that build their input from the parent resource,
+// e.g. from field bindings, implement it so that their exact input can be
+// recorded.
+type ValuesComputer interface {
+	Values(cr resource.ParentResource) (map[string]interface{}, error)
+}
+
+// NewValuesSnapshotEngine returns a new *ValuesSnapshotEngine. The ConfigMaps
+// of cluster-scoped parent resources are stored in the given namespace.
+func NewValuesSnapshotEngine(e Engine, c client.Client, namespace string, log logging.Logger) *ValuesSnapshotEngine {
+	return &ValuesSnapshotEngine{
+		Engine:       e,
+		RedactedKeys: DefaultRedactedKeys,
+		applier:      apply.NewApplier(c),
+		namespace:    namespace,
+		log:          log,
+	}
+}
+
+// ValuesSnapshotEngine records the values that the underlying Engine renders
+// every parent resource with in a ConfigMap per parent resource before
+// running it, so the values of a failed render are recorded as well. If the
+// underlying Engine is a ValuesComputer, its values are recorded. Otherwise,
+// the spec of the parent resource it receives is recorded. The values whose
+// keys contain one of RedactedKeys are replaced with RedactedValue. The
+// ConfigMap is owned by the parent resource so that it is garbage collected
+// with the parent.
+type ValuesSnapshotEngine struct {
+	Engine       Engine
+	RedactedKeys []string
+
+	applier   *apply.Applier
+	namespace string
+	log       logging.Logger
+}
+
+// Run records the values snapshot of the given parent resource and runs the
+// underlying Engine. A failure to record the snapshot does not block the
+// rendering.
+func (e *ValuesSnapshotEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if err := e.snapshot(ctx, cr); err != nil {
+		e.log.Info(errSnapshotValues, "error", err)
+	}
+	return e.Engine.Run(ctx, cr)
+}
+
+func (e *ValuesSnapshotEngine) snapshot(ctx context.Context, cr resource.ParentResource) error {
+	values, err := valuesOf(e.Engine, cr)
+	if err != nil {
+		return errors.Wrap(err, errComputeValues)
+	}
+	data, err := yaml.Marshal(redact(values, e.RedactedKeys))
+	if err != nil {
+		return errors.Wrap(err, errMarshalValues)
+	}
+	ns := cr.GetNamespace()
+	if ns == "" {
+		ns = e.namespace
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ValuesSnapshotConfigMapPrefix + string(cr.GetUID()), Namespace: ns},
+		Data:       map[string]string{ValuesDataKey: string(data)},
+	}
+	// NOTE: A namespaced ConfigMap can be owned by a cluster-scoped parent.
+	meta.AddOwnerReference(cm, meta.AsOwner(meta.ReferenceTo(cr, cr.GroupVersionKind())))
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+	_, err = e.applier.Apply(ctx, cm)
+	return errors.Wrap(err, errStoreSnapshot)
+}
+
+// ValuesSources returns the sources of the values of the underlying Engine
+// for the given parent resource.
+func (e *ValuesSnapshotEngine) ValuesSources(cr resource.ParentResource) []string {
+	return valuesSourcesOf(e.Engine, cr)
+}
+
+// valuesOf returns the values of the given Engine if it's a ValuesComputer.
+// Otherwise, it returns the spec of the given parent resource.
+func valuesOf(e Engine, cr resource.ParentResource) (map[string]interface{}, error) {
+	if vc, ok := e.(ValuesComputer); ok {
+		return vc.Values(cr)
+	}
+	spec, _ := cr.UnstructuredContent()["spec"].(map[string]interface{})
+	return spec, nil
+}
+
+// redact returns a copy of the given value in which the values whose keys
+// contain one of the given substrings, case-insensitively, are replaced with
+// RedactedValue. The given value is not modified.
+func redact(val interface{}, keys []string) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, field := range v {
+			if containsAny(strings.ToLower(k), keys) {
+				result[k] = RedactedValue
+				continue
+			}
+			result[k] = redact(field, keys)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = redact(e, keys)
+		}
+		return result
+	}
+	return val
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
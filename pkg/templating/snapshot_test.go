@@ -0,0 +1,55 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRedact(t *testing.T) {
+	values := map[string]interface{}{
+		"replicas": int64(3),
+		"db": map[string]interface{}{
+			"user":     "admin",
+			"Password": "hunter2",
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "a", "apiKey": "abc"},
+		},
+		"tokens": []interface{}{"x", "y"},
+	}
+	want := map[string]interface{}{
+		"replicas": int64(3),
+		"db": map[string]interface{}{
+			"user":     "admin",
+			"Password": RedactedValue,
+		},
+		"users": []interface{}{
+			map[string]interface{}{"name": "a", "apiKey": RedactedValue},
+		},
+		"tokens": RedactedValue,
+	}
+	got := redact(values, DefaultRedactedKeys)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("redact(...): -want, +got:\n%s", diff)
+	}
+	if values["db"].(map[string]interface{})["Password"] != "hunter2" {
+		t.Errorf("redact(...): the given values are modified")
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+var namespaceGroupKind = schema.GroupKind{Kind: "Namespace"}
+
+// The annotations of the Helm hooks, which the helm3 engine keeps on the
+// child resources that are rendered from the hooks.
+const (
+	helmHookAnnotationKey       = "helm.sh/hook"
+	helmHookWeightAnnotationKey = "helm.sh/hook-weight"
+)
+
+const (
+	// ChildResourceOrderAnnotationKey is the annotation on the StackDefinition
+	// that determines the order in which the child resources are applied.
+	ChildResourceOrderAnnotationKey = "templatestacks.crossplane.io/child-resource-order"
+
+	// ChildResourceOrderSorted sorts the child resources with the
+	// ChildResourceSorter. It's the default.
+	ChildResourceOrderSorted = "sorted"
+
+	// ChildResourceOrderRendered keeps the order in which the engine rendered
+	// the child resources.
+	ChildResourceOrderRendered = "rendered"
+)
+
+// NewChildResourceSorter returns a new ChildResourceSorter.
+func NewChildResourceSorter() ChildResourceSorter {
+	return ChildResourceSorter{}
+}
+
+// ChildResourceSorter sorts the child resources so that they are applied,
+// reported and diffed in the same order in every reconciliation regardless
+// of the order the engine produced them in. The CustomResourceDefinitions
+// come first and the Namespaces second so that the kinds and the namespaces
+// of the other child resources exist when they're applied. The pre-install
+// and pre-upgrade Helm hooks come next and the post-install and post-upgrade
+// ones last, each in the order of their weights and then in the order they
+// were rendered, so that they're still applied before and after the rest.
+// The rest are sorted by their namespace, kind, name and API version.
+type ChildResourceSorter struct{}
+
+// Patch returns the given child resources in order.
+func (ChildResourceSorter) Patch(_ context.Context, _ resource.ParentResource, list []resource.ChildResource) ([]resource.ChildResource, error) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		pa, pb := applyPriority(a), applyPriority(b)
+		if pa != pb {
+			return pa < pb
+		}
+		if pa == priorityPreHook || pa == priorityPostHook {
+			return hookWeight(a) < hookWeight(b)
+		}
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+		ka, kb := a.GetObjectKind().GroupVersionKind(), b.GetObjectKind().GroupVersionKind()
+		if ka.Kind != kb.Kind {
+			return ka.Kind < kb.Kind
+		}
+		if a.GetName() != b.GetName() {
+			return a.GetName() < b.GetName()
+		}
+		return ka.GroupVersion().String() < kb.GroupVersion().String()
+	})
+	return list, nil
+}
+
+// The ranks of the child resources in the apply order; lower goes first.
+const (
+	priorityCRD = iota
+	priorityNamespace
+	priorityPreHook
+	priorityDefault
+	priorityPostHook
+)
+
+// applyPriority returns the rank of the given child resource in the apply
+// order.
+func applyPriority(o resource.ChildResource) int {
+	switch o.GetObjectKind().GroupVersionKind().GroupKind() {
+	case crdGroupKind:
+		return priorityCRD
+	case namespaceGroupKind:
+		return priorityNamespace
+	}
+	events, ok := o.GetAnnotations()[helmHookAnnotationKey]
+	if !ok {
+		return priorityDefault
+	}
+	for _, e := range strings.Split(events, ",") {
+		if e = strings.TrimSpace(e); e == "pre-install" || e == "pre-upgrade" {
+			return priorityPreHook
+		}
+	}
+	return priorityPostHook
+}
+
+// hookWeight returns the weight of the given Helm hook, which is 0 if it's
+// not set or not a number, as in Helm.
+func hookWeight(o resource.ChildResource) int {
+	w, _ := strconv.Atoi(o.GetAnnotations()[helmHookWeightAnnotationKey])
+	return w
+}
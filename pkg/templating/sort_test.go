@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+func TestChildResourceSorter(t *testing.T) {
+	child := func(apiVersion, kind, namespace, name string) resource.ChildResource {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return u
+	}
+	hook := func(events, weight, name string) resource.ChildResource {
+		o := child("batch/v1", "Job", "a", name)
+		o.SetAnnotations(map[string]string{helmHookAnnotationKey: events, helmHookWeightAnnotationKey: weight})
+		return o
+	}
+	list := []resource.ChildResource{
+		hook("post-install", "0", "a-notify"),
+		hook("pre-install,pre-upgrade", "5", "migrate"),
+		child("apps/v1", "Deployment", "b", "web"),
+		child("v1", "ConfigMap", "b", "web"),
+		child("v1", "Namespace", "", "b"),
+		child("example.org/v1", "ConfigMap", "a", "web"),
+		child("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.org"),
+		child("v1", "ConfigMap", "a", "web"),
+		child("v1", "Namespace", "", "a"),
+		child("rbac.authorization.k8s.io/v1", "ClusterRole", "", "web"),
+		hook("pre-install", "-5", "z-secret"),
+		hook("pre-upgrade", "5", "backup"),
+	}
+	want := []resource.ChildResource{
+		child("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.org"),
+		child("v1", "Namespace", "", "a"),
+		child("v1", "Namespace", "", "b"),
+		hook("pre-install", "-5", "z-secret"),
+		hook("pre-install,pre-upgrade", "5", "migrate"),
+		hook("pre-upgrade", "5", "backup"),
+		child("rbac.authorization.k8s.io/v1", "ClusterRole", "", "web"),
+		child("example.org/v1", "ConfigMap", "a", "web"),
+		child("v1", "ConfigMap", "a", "web"),
+		child("v1", "ConfigMap", "b", "web"),
+		child("apps/v1", "Deployment", "b", "web"),
+		hook("post-install", "0", "a-notify"),
+	}
+	got, err := NewChildResourceSorter().Patch(context.Background(), nil, list)
+	if err != nil {
+		t.Fatalf("Patch(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Patch(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// ReportValuesSourcesAnnotationKey is the annotation on the StackDefinition
+// that makes the controller report the sources of the values of every parent
+// resource in its ValuesResolved condition when its value is "true".
+const ReportValuesSourcesAnnotationKey = "templatestacks.crossplane.io/report-values-sources"
+
+// TypeValuesResolved reports the sources that the values of the parent
+// resource are merged from.
+const TypeValuesResolved v1alpha1.ConditionType = "ValuesResolved"
+
+// ReasonValuesResolved is the reason of the ValuesResolved condition.
+const ReasonValuesResolved v1alpha1.ConditionReason = "Merged the values from the reported sources"
+
+// SourceSpec is the source of the values that are read from the spec of the
+// parent resource. The sources that are derived from the spec, e.g. its
+// bindings, start with it.
+const SourceSpec = "spec"
+
+// A ValuesSourcesReporter is an Engine that reports the sources that it
+// merges the values of the given parent resource from, in increasing order
+// of precedence. Only the sources are described, e.g. "ConfigMap
+// default/wordpress-defaults", never their content.
+type ValuesSourcesReporter interface {
+	ValuesSources(cr resource.ParentResource) []string
+}
+
+// ValuesResolved returns a condition that reports the given sources of the
+// values of the parent resource, in increasing order of precedence.
+func ValuesResolved(sources []string) v1alpha1.Condition {
+	msg := "no values"
+	if len(sources) != 0 {
+		msg = "in increasing order of precedence: " + strings.Join(sources, ", ")
+	}
+	return v1alpha1.Condition{
+		Type:               TypeValuesResolved,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonValuesResolved,
+		Message:            msg,
+	}
+}
+
+// WithValuesSourcesReport returns a ReconcilerOption that makes the
+// Reconciler report the sources of the values of every parent resource in its
+// ValuesResolved condition.
+func WithValuesSourcesReport() ReconcilerOption {
+	return func(reconciler *Reconciler) {
+		reconciler.reportSources = true
+	}
+}
+
+// valuesSourcesOf returns the sources of the values of the given Engine if
+// it's a ValuesSourcesReporter. Otherwise, it returns SourceSpec if the given
+// parent resource has a spec.
+func valuesSourcesOf(e Engine, cr resource.ParentResource) []string {
+	if r, ok := e.(ValuesSourcesReporter); ok {
+		return r.ValuesSources(cr)
+	}
+	if _, ok := cr.UnstructuredContent()["spec"]; ok {
+		return []string{SourceSpec}
+	}
+	return nil
+}
+
+// describe returns the description of the given source of values, or a
+// generic one with its number if it does not describe itself.
+func describe(src ValuesSource, i int) string {
+	if s, ok := src.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("values source %d", i+1)
+}
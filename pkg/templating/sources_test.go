@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+type reportingEngine struct {
+	Engine
+	sources []string
+}
+
+func (e reportingEngine) ValuesSources(_ resource.ParentResource) []string {
+	return e.sources
+}
+
+func TestValuesSources(t *testing.T) {
+	withSpec := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{}}}
+	anonymous := ValuesSourceFunc(func(_ context.Context) (map[string]interface{}, error) { return nil, nil })
+	cm := NewConfigMapValues(&test.MockClient{}, types.NamespacedName{Namespace: "default", Name: "defaults"})
+	secret := NewSecretValues(&test.MockClient{}, types.NamespacedName{Namespace: "default", Name: "creds"})
+
+	cases := map[string]struct {
+		reason string
+		e      Engine
+		cr     resource.ParentResource
+		want   []string
+	}{
+		"NotReporter": {
+			reason: "An Engine that does not report its sources should be assumed to read the spec only.",
+			e:      &NopEngine{},
+			cr:     withSpec,
+			want:   []string{SourceSpec},
+		},
+		"NoSpec": {
+			reason: "A parent resource without a spec should have no sources.",
+			e:      &NopEngine{},
+			cr:     &unstructured.Unstructured{Object: map[string]interface{}{}},
+		},
+		"Merging": {
+			reason: "The sources of a ValuesMergingEngine should be inserted right beneath the spec.",
+			e: NewValuesMergingEngine(NewChainedEngine(reportingEngine{
+				sources: []string{"ConfigMap default/referenced", "spec bindings", "annotation"},
+			}), cm, secret, anonymous),
+			cr:   withSpec,
+			want: []string{"ConfigMap default/referenced", "ConfigMap default/defaults", "Secret default/creds (redacted)", "values source 3", "spec bindings", "annotation"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := valuesSourcesOf(tc.e, tc.cr)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nvaluesSourcesOf(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
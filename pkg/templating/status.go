@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// An Observation is what the reconciler observed about a parent resource
+// during a reconciliation in addition to the conditions, which are already
+// set in the status.conditions field of the parent resource when it's
+// written.
+type Observation struct {
+	// Generation of the parent resource that is reconciled.
+	Generation int64
+
+	// ResourceRefs are the references of the rendered child resources. It's
+	// nil if the child resources could not be rendered.
+	ResourceRefs []ChildReference
+
+	// Children are the rendered child resources. It's nil if the child
+	// resources could not be rendered.
+	Children []resource.ChildResource
+
+	// Revision of the template source that the child resources are rendered
+	// with.
+	Revision string
+
+	// LastKnownGood is true if the child resources could not be rendered and
+	// the last known good ones are used instead.
+	LastKnownGood bool
+}
+
+// A StatusWriter writes the status of a parent resource at the end of every
+// reconciliation.
+type StatusWriter interface {
+	WriteStatus(ctx context.Context, cr resource.ParentResource, o Observation) error
+}
+
+// A StatusWriterFunc is a function that satisfies the StatusWriter interface.
+type StatusWriterFunc func(ctx context.Context, cr resource.ParentResource, o Observation) error
+
+// WriteStatus calls the StatusWriterFunc.
+func (fn StatusWriterFunc) WriteStatus(ctx context.Context, cr resource.ParentResource, o Observation) error {
+	return fn(ctx, cr, o)
+}
+
+// NewAPIStatusWriter returns a new *APIStatusWriter.
+func NewAPIStatusWriter(c client.Client) *APIStatusWriter {
+	return &APIStatusWriter{client: c}
+}
+
+// APIStatusWriter sets the status.observedGeneration and status.resourceRefs
+// fields of the parent resource and updates its status subresource. The
+// status.resourceRefs field is left as is if the child resources could not be
+// rendered.
+type APIStatusWriter struct {
+	client client.Client
+}
+
+// WriteStatus writes the status of the given parent resource.
+func (w *APIStatusWriter) WriteStatus(ctx context.Context, cr resource.ParentResource, o Observation) error {
+	if err := SetObservation(cr, o); err != nil {
+		return err
+	}
+	return w.client.Status().Update(ctx, cr)
+}
+
+// SetObservation sets the status.observedGeneration and status.resourceRefs
+// fields of the given parent resource. The status.resourceRefs field is left
+// as is if the given Observation has no resource references.
+func SetObservation(cr interface{ UnstructuredContent() map[string]interface{} }, o Observation) error {
+	if err := unstructured.SetNestedField(cr.UnstructuredContent(), o.Generation, "status", "observedGeneration"); err != nil {
+		return err
+	}
+	if o.ResourceRefs == nil {
+		return nil
+	}
+	data, err := json.Marshal(o.ResourceRefs)
+	if err != nil {
+		return err
+	}
+	list := []interface{}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(cr.UnstructuredContent(), list, "status", "resourceRefs")
+}
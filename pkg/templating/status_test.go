@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestAPIStatusWriter(t *testing.T) {
+	refs := []ChildReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cool"}}
+	type want struct {
+		status map[string]interface{}
+		err    error
+	}
+	cases := map[string]struct {
+		reason   string
+		status   map[string]interface{}
+		o        Observation
+		updateFn test.MockStatusUpdateFn
+		want     want
+	}{
+		"Rendered": {
+			reason:   "The observed generation and the resource references should be written",
+			o:        Observation{Generation: 3, ResourceRefs: refs},
+			updateFn: test.NewMockStatusUpdateFn(nil),
+			want: want{status: map[string]interface{}{
+				"observedGeneration": int64(3),
+				"resourceRefs": []interface{}{map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"namespace":  "default",
+					"name":       "cool",
+				}},
+			}},
+		},
+		"NotRendered": {
+			reason: "The resource references should be left as is if the child resources could not be rendered",
+			status: map[string]interface{}{
+				"observedGeneration": int64(2),
+				"resourceRefs":       []interface{}{"old"},
+			},
+			o:        Observation{Generation: 3},
+			updateFn: test.NewMockStatusUpdateFn(nil),
+			want: want{status: map[string]interface{}{
+				"observedGeneration": int64(3),
+				"resourceRefs":       []interface{}{"old"},
+			}},
+		},
+		"UpdateFailed": {
+			reason:   "The error of the status update should be returned",
+			o:        Observation{Generation: 3},
+			updateFn: test.NewMockStatusUpdateFn(errBoom),
+			want: want{
+				status: map[string]interface{}{"observedGeneration": int64(3)},
+				err:    errBoom,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := fake.NewMockResource()
+			if tc.status != nil {
+				cr.Object["status"] = tc.status
+			}
+			w := NewAPIStatusWriter(&test.MockClient{MockStatusUpdate: tc.updateFn})
+			err := w.WriteStatus(context.Background(), cr, tc.o)
+			if diff := cmp.Diff(tc.want.err, errors.Cause(err), test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nWriteStatus(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.status, cr.Object["status"]); diff != "" {
+				t.Errorf("\n%s\nWriteStatus(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/crossplane/templating-controller/pkg/apply"
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// LastKnownGoodAnnotationKey is the annotation on the StackDefinition
+	// whose value is the kind of RenderStore that keeps the last known good
+	// child resources. Either LastKnownGoodMemory or LastKnownGoodConfigMap.
+	LastKnownGoodAnnotationKey = "templatestacks.crossplane.io/last-known-good"
+
+	// LastKnownGoodMemory keeps the last known good child resources in the
+	// memory of the controller.
+	LastKnownGoodMemory = "memory"
+
+	// LastKnownGoodConfigMap keeps the last known good child resources in a
+	// ConfigMap per parent resource so that they survive restarts.
+	LastKnownGoodConfigMap = "configmap"
+
+	renderStoreConfigMapPrefix = "last-known-good-"
+	renderStoreDataKey         = "children.json"
+
+	errGetRenderStore       = "cannot get the stored child resources"
+	errStoreRenderStore     = "cannot store the child resources"
+	errDeleteRenderStore    = "cannot delete the stored child resources"
+	errMarshalRenderStore   = "cannot marshal child resources"
+	errUnmarshalRenderStore = "cannot unmarshal stored child resources"
+)
+
+// NewMemoryRenderStore returns a new *MemoryRenderStore.
+func NewMemoryRenderStore() *MemoryRenderStore {
+	return &MemoryRenderStore{children: map[types.UID][]resource.ChildResource{}}
+}
+
+// MemoryRenderStore keeps the child resources in memory. The stored child
+// resources are lost when the controller restarts.
+type MemoryRenderStore struct {
+	mu       sync.RWMutex
+	children map[types.UID][]resource.ChildResource
+}
+
+// Get returns a copy of the stored child resources of the given parent.
+func (s *MemoryRenderStore) Get(_ context.Context, cr resource.ParentResource) ([]resource.ChildResource, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return deepCopyChildren(s.children[cr.GetUID()]), nil
+}
+
+// Store stores a copy of the given child resources of the given parent.
+func (s *MemoryRenderStore) Store(_ context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.children[cr.GetUID()] = deepCopyChildren(list)
+	return nil
+}
+
+// Delete removes the stored child resources of the given parent.
+func (s *MemoryRenderStore) Delete(_ context.Context, cr resource.ParentResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.children, cr.GetUID())
+	return nil
+}
+
+// NewConfigMapRenderStore returns a new *ConfigMapRenderStore. The ConfigMaps
+// of cluster-scoped parent resources are stored in the given namespace.
+func NewConfigMapRenderStore(c client.Client, namespace string) *ConfigMapRenderStore {
+	return &ConfigMapRenderStore{
+		client:    c,
+		applier:   apply.NewApplier(c),
+		namespace: namespace,
+	}
+}
+
+// ConfigMapRenderStore keeps the child resources in a ConfigMap per parent
+// resource. The ConfigMap is owned by the parent resource if they are in the
+// same namespace so that it is garbage collected with the parent.
+type ConfigMapRenderStore struct {
+	client    client.Client
+	applier   *apply.Applier
+	namespace string
+}
+
+// Get returns the child resources stored in the ConfigMap of the given parent.
+func (s *ConfigMapRenderStore) Get(ctx context.Context, cr resource.ParentResource) ([]resource.ChildResource, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, s.key(cr), cm); err != nil {
+		return nil, errors.Wrap(client.IgnoreNotFound(err), errGetRenderStore)
+	}
+	data, ok := cm.Data[renderStoreDataKey]
+	if !ok {
+		return nil, nil
+	}
+	var objs []map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &objs); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalRenderStore)
+	}
+	list := make([]resource.ChildResource, len(objs))
+	for i, o := range objs {
+		list[i] = &unstructured.Unstructured{Object: o}
+	}
+	return list, nil
+}
+
+// Store writes the given child resources to the ConfigMap of the given parent.
+func (s *ConfigMapRenderStore) Store(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return errors.Wrap(err, errMarshalRenderStore)
+	}
+	key := s.key(cr)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		Data:       map[string]string{renderStoreDataKey: string(data)},
+	}
+	if cr.GetNamespace() == key.Namespace {
+		meta.AddOwnerReference(cm, meta.AsOwner(meta.ReferenceTo(cr, cr.GroupVersionKind())))
+	}
+	_, err = s.applier.Apply(ctx, cm)
+	return errors.Wrap(err, errStoreRenderStore)
+}
+
+// Delete deletes the ConfigMap of the given parent.
+func (s *ConfigMapRenderStore) Delete(ctx context.Context, cr resource.ParentResource) error {
+	key := s.key(cr)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	if err := s.client.Delete(ctx, cm); err != nil && !kerrors.IsNotFound(err) {
+		return errors.Wrap(err, errDeleteRenderStore)
+	}
+	return nil
+}
+
+func (s *ConfigMapRenderStore) key(cr resource.ParentResource) types.NamespacedName {
+	ns := cr.GetNamespace()
+	if ns == "" {
+		ns = s.namespace
+	}
+	return types.NamespacedName{Name: renderStoreConfigMapPrefix + string(cr.GetUID()), Namespace: ns}
+}
+
+func deepCopyChildren(list []resource.ChildResource) []resource.ChildResource {
+	if list == nil {
+		return nil
+	}
+	result := make([]resource.ChildResource, len(list))
+	for i, o := range list {
+		result[i] = o.DeepCopyObject().(resource.ChildResource)
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestMemoryRenderStore(t *testing.T) {
+	ctx := context.Background()
+	cr := fake.NewMockResource(fake.WithUID(types.UID("olala")))
+	child := fake.NewMockResource(fake.WithNamespaceName(fakeName, fakeNamespace))
+	s := NewMemoryRenderStore()
+
+	if err := s.Store(ctx, cr, []resource.ChildResource{child}); err != nil {
+		t.Fatalf("Store(...): unexpected error: %v", err)
+	}
+	// Changes on the given list should not affect the stored one.
+	child.SetName("changed")
+
+	got, err := s.Get(ctx, cr)
+	if err != nil {
+		t.Fatalf("Get(...): unexpected error: %v", err)
+	}
+	// NOTE: Copies of the child resources are stored as
+	// *unstructured.Unstructured since that is what *MockResource embeds.
+	want := []resource.ChildResource{fake.NewMockResource(fake.WithNamespaceName(fakeName, fakeNamespace)).Unstructured.DeepCopy()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Get(...): -want, +got:\n%s", diff)
+	}
+
+	if err := s.Delete(ctx, cr); err != nil {
+		t.Fatalf("Delete(...): unexpected error: %v", err)
+	}
+	got, _ = s.Get(ctx, cr)
+	if diff := cmp.Diff([]resource.ChildResource(nil), got); diff != "" {
+		t.Errorf("Get(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// SuspendedKindsAnnotationKey is the annotation on the StackDefinition or the
+// parent resource whose value is the comma-separated list of the kinds of
+// child resources that are rendered but not applied or deleted, e.g.
+// "Deployment.apps,ConfigMap".
+const SuspendedKindsAnnotationKey = "templatestacks.crossplane.io/suspended-kinds"
+
+// ParseGroupKinds parses the given comma-separated list of kinds in
+// Kind.group format. The group of the core kinds is omitted.
+func ParseGroupKinds(s string) []schema.GroupKind {
+	var result []schema.GroupKind
+	for _, gk := range strings.Split(s, ",") {
+		if gk = strings.TrimSpace(gk); gk != "" {
+			result = append(result, schema.ParseGroupKind(gk))
+		}
+	}
+	return result
+}
+
+// suspended returns true if the kind of the given child resource is
+// suspended either by the reconciler or by the given parent resource.
+func (r *Reconciler) suspended(cr resource.ParentResource, o resource.ChildResource) bool {
+	gk := o.GetObjectKind().GroupVersionKind().GroupKind()
+	for _, s := range r.suspendedKinds {
+		if s == gk {
+			return true
+		}
+	}
+	for _, s := range ParseGroupKinds(cr.GetAnnotations()[SuspendedKindsAnnotationKey]) {
+		if s == gk {
+			return true
+		}
+	}
+	return false
+}
+
+// unsuspended returns the child resources in the given list whose kinds are
+// not suspended.
+func (r *Reconciler) unsuspended(cr resource.ParentResource, list []resource.ChildResource) []resource.ChildResource {
+	result := make([]resource.ChildResource, 0, len(list))
+	for _, o := range list {
+		if !r.suspended(cr, o) {
+			result = append(result, o)
+		}
+	}
+	return result
+}
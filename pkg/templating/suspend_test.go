@@ -0,0 +1,35 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseGroupKinds(t *testing.T) {
+	want := []schema.GroupKind{
+		{Group: "apps", Kind: "Deployment"},
+		{Kind: "ConfigMap"},
+	}
+	got := ParseGroupKinds(" Deployment.apps, ConfigMap,")
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseGroupKinds(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReportTimingsAnnotationKey is the annotation on the StackDefinition that
+// makes the reconciler report the durations of the phases of the last
+// reconciliation in the status.timings field of the parent resources when
+// its value is "true".
+const ReportTimingsAnnotationKey = "templatestacks.crossplane.io/report-timings"
+
+// Timings are the durations of the phases of a reconciliation. The durations
+// of the phases that didn't run are zero.
+type Timings struct {
+	// Render is the duration of the rendering by the Engine.
+	Render time.Duration
+
+	// Patch is the duration of the ChildResourcePatchers and the Linter.
+	Patch time.Duration
+
+	// Apply is the duration of the checks and the apply of the child
+	// resources.
+	Apply time.Duration
+
+	// Children is the number of the rendered child resources.
+	Children int
+
+	phases map[Phase]bool
+}
+
+// SetTimings sets the status.timings field of the given parent resource.
+func SetTimings(cr interface{ UnstructuredContent() map[string]interface{} }, t Timings) error {
+	return unstructured.SetNestedMap(cr.UnstructuredContent(), map[string]interface{}{
+		"renderMilliseconds": t.Render.Milliseconds(),
+		"patchMilliseconds":  t.Patch.Milliseconds(),
+		"applyMilliseconds":  t.Apply.Milliseconds(),
+		"children":           int64(t.Children),
+	}, "status", "timings")
+}
+
+// A stopwatch measures the durations of the phases of a reconciliation.
+type stopwatch struct {
+	mu       sync.Mutex
+	started  map[Phase]time.Time
+	measured map[Phase]time.Duration
+}
+
+type stopwatchKey struct{}
+
+// withStopwatch returns a copy of the given context that the durations of the
+// phases of a reconciliation are measured in.
+func withStopwatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stopwatchKey{}, &stopwatch{started: map[Phase]time.Time{}, measured: map[Phase]time.Duration{}})
+}
+
+// startPhase starts measuring the duration of the given phase, if the given
+// context has a stopwatch.
+func startPhase(ctx context.Context, p Phase) {
+	s, ok := ctx.Value(stopwatchKey{}).(*stopwatch)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started[p] = time.Now()
+}
+
+// stopPhase stops measuring the duration of the given phase, if the given
+// context has a stopwatch.
+func stopPhase(ctx context.Context, p Phase) {
+	s, ok := ctx.Value(stopwatchKey{}).(*stopwatch)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start, ok := s.started[p]; ok {
+		s.measured[p] += time.Since(start)
+		delete(s.started, p)
+	}
+}
+
+// timingsOf returns the Timings that the stopwatch of the given context
+// measured so far, with the given number of child resources. The phases that
+// are still running are measured until now.
+func timingsOf(ctx context.Context, children int) Timings {
+	t := Timings{Children: children, phases: map[Phase]bool{}}
+	s, ok := ctx.Value(stopwatchKey{}).(*stopwatch)
+	if !ok {
+		return t
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := map[Phase]time.Duration{}
+	for p, m := range s.measured {
+		d[p] = m
+		t.phases[p] = true
+	}
+	for p, start := range s.started {
+		d[p] += time.Since(start)
+		t.phases[p] = true
+	}
+	t.Render = d[PhaseRender]
+	t.Patch = d[PhasePatch] + d[PhaseValidate]
+	t.Apply = d[PhaseApply]
+	return t
+}
+
+// NewTimingMetrics returns new *TimingMetrics for the parent resources of the
+// given kind.
+func NewTimingMetrics(gk schema.GroupKind) *TimingMetrics {
+	labels := prometheus.Labels{"parent": strings.ToLower(gk.String())}
+	return &TimingMetrics{
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "templating_controller_phase_duration_seconds",
+			Help:        "Duration of the render, patch and apply phases of the reconciliations of the parent resources.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.005, 2, 14),
+		}, []string{"phase"}),
+		children: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "templating_controller_children",
+			Help:        "Number of the rendered child resources of the parent resources.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+	}
+}
+
+// TimingMetrics are the Prometheus histograms of the durations of the phases
+// of the reconciliations and of the number of the rendered child resources,
+// so that whether the templates or the cluster are slow can be told apart.
+type TimingMetrics struct {
+	durations *prometheus.HistogramVec
+	children  prometheus.Histogram
+}
+
+// Observe records the given Timings. Only the phases that ran are recorded.
+func (m *TimingMetrics) Observe(t Timings) {
+	for p, d := range map[Phase]time.Duration{PhaseRender: t.Render, PhasePatch: t.Patch, PhaseApply: t.Apply} {
+		if t.phases[p] || (p == PhasePatch && t.phases[PhaseValidate]) {
+			m.durations.WithLabelValues(strings.ToLower(string(p))).Observe(d.Seconds())
+		}
+	}
+	if t.phases[PhaseRender] {
+		m.children.Observe(float64(t.Children))
+	}
+}
+
+// Describe sends the descriptors of the metrics of the TimingMetrics.
+func (m *TimingMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.durations.Describe(ch)
+	m.children.Describe(ch)
+}
+
+// Collect sends the metrics of the TimingMetrics.
+func (m *TimingMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.durations.Collect(ch)
+	m.children.Collect(ch)
+}
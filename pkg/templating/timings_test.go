@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestStopwatch(t *testing.T) {
+	// Phases are not measured without a stopwatch.
+	ctx := context.Background()
+	startPhase(ctx, PhaseRender)
+	stopPhase(ctx, PhaseRender)
+	if got := timingsOf(ctx, 1); got.Render != 0 || len(got.phases) != 0 {
+		t.Errorf("timingsOf(...): want no timings without a stopwatch, got %+v", got)
+	}
+
+	ctx = withStopwatch(ctx)
+	startPhase(ctx, PhaseRender)
+	time.Sleep(time.Millisecond)
+	stopPhase(ctx, PhaseRender)
+	startPhase(ctx, PhaseApply)
+	time.Sleep(time.Millisecond)
+
+	// The phases that are still running are measured until now.
+	got := timingsOf(ctx, 3)
+	if got.Render < time.Millisecond || got.Apply < time.Millisecond {
+		t.Errorf("timingsOf(...): want the render and the apply to be measured, got %+v", got)
+	}
+	if got.Patch != 0 {
+		t.Errorf("timingsOf(...): want no patch duration since it didn't run, got %s", got.Patch)
+	}
+	want := map[Phase]bool{PhaseRender: true, PhaseApply: true}
+	if diff := cmp.Diff(want, got.phases); diff != "" {
+		t.Errorf("timingsOf(...): -want phases, +got phases:\n%s", diff)
+	}
+	if diff := cmp.Diff(3, got.Children); diff != "" {
+		t.Errorf("timingsOf(...): -want children, +got children:\n%s", diff)
+	}
+}
+
+func TestSetTimings(t *testing.T) {
+	cr := fake.NewMockResource()
+	if err := SetTimings(cr, Timings{Render: 1500 * time.Millisecond, Apply: 20 * time.Millisecond, Children: 2}); err != nil {
+		t.Fatalf("SetTimings(...): %s", err)
+	}
+	got, _, _ := unstructured.NestedMap(cr.UnstructuredContent(), "status", "timings")
+	want := map[string]interface{}{
+		"renderMilliseconds": int64(1500),
+		"patchMilliseconds":  int64(0),
+		"applyMilliseconds":  int64(20),
+		"children":           int64(2),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SetTimings(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// TTLAnnotationKey is the annotation on a rendered child resource whose
+	// value is the duration after which the child resource is deleted even
+	// though it's still rendered, e.g. "1h" for a one-shot migration Job.
+	TTLAnnotationKey = "templatestacks.crossplane.io/ttl"
+
+	errFmtParseTTL   = "cannot parse the value of %s annotation of %s %s"
+	errDeleteExpired = "cannot delete the expired child resource"
+)
+
+// TTLOf returns the TTL of the given child resource and true if it has one.
+func TTLOf(o resource.ChildResource) (time.Duration, bool, error) {
+	s, ok := o.GetAnnotations()[TTLAnnotationKey]
+	if !ok {
+		return 0, false, nil
+	}
+	ttl, err := time.ParseDuration(s)
+	if err != nil || ttl <= 0 {
+		return 0, false, errors.Errorf(errFmtParseTTL, TTLAnnotationKey, o.GetObjectKind().GroupVersionKind().Kind, o.GetName())
+	}
+	return ttl, true, nil
+}
+
+// inventoryOf returns the child resource references in the status.resourceRefs
+// field of the given parent resource, or nil if they cannot be read.
+func inventoryOf(cr resource.ParentResource) []ChildReference {
+	list, ok, err := unstructured.NestedSlice(cr.UnstructuredContent(), "status", "resourceRefs")
+	if err != nil || !ok {
+		return nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil
+	}
+	var refs []ChildReference
+	if err := json.Unmarshal(data, &refs); err != nil {
+		return nil
+	}
+	return refs
+}
+
+// trackExpiry copies the creation and expiry times of the given previous
+// references to the matching given current references, which are returned.
+// The references match if they have the same group, kind, namespace and name.
+func trackExpiry(previous, current []ChildReference) []ChildReference {
+	key := func(ref ChildReference) ChildReference {
+		gk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).GroupKind()
+		return ChildReference{APIVersion: gk.Group, Kind: gk.Kind, Namespace: ref.Namespace, Name: ref.Name}
+	}
+	tracked := map[ChildReference]ChildReference{}
+	for _, ref := range previous {
+		if ref.CreatedAt != nil {
+			tracked[key(ref)] = ref
+		}
+	}
+	for i, ref := range current {
+		if prev, ok := tracked[key(ref)]; ok {
+			current[i].CreatedAt = prev.CreatedAt
+			current[i].ExpiredAt = prev.ExpiredAt
+		}
+	}
+	return current
+}
+
+// expiring returns true if the given parent resource has a child resource
+// with a TTL that has not expired yet.
+func expiring(cr resource.ParentResource) bool {
+	for _, ref := range inventoryOf(cr) {
+		if ref.CreatedAt != nil && ref.ExpiredAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expire deletes the given child resources whose TTL has passed at the given
+// time. The given references of the child resources, in the same order,
+// record the time the child resources with a TTL are first seen and the time
+// they expire, so that an expired child resource is not applied again while
+// it's still rendered. The child resources that are not expired, the expired
+// ones and the time until the next one expires are returned. The suspended
+// child resources never expire.
+func (r *Reconciler) expire(ctx context.Context, cr resource.ParentResource, list []resource.ChildResource, refs []ChildReference, now metav1.Time) ([]resource.ChildResource, []resource.ChildResource, time.Duration, error) {
+	live := make([]resource.ChildResource, 0, len(list))
+	var expired []resource.ChildResource
+	var wait time.Duration
+	for i, o := range list {
+		ttl, ok, err := TTLOf(o)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if !ok || r.suspended(cr, o) {
+			live = append(live, o)
+			continue
+		}
+		if refs[i].CreatedAt == nil {
+			refs[i].CreatedAt = now.DeepCopy()
+		}
+		if refs[i].ExpiredAt != nil {
+			expired = append(expired, o)
+			continue
+		}
+		if remaining := refs[i].CreatedAt.Add(ttl).Sub(now.Time); remaining > 0 {
+			if wait == 0 || remaining < wait {
+				wait = remaining
+			}
+			live = append(live, o)
+			continue
+		}
+		// NOTE: The dependents, e.g. the Pods of a Job, are deleted too.
+		if err := r.client.Delete(ctx, o, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !kerrors.IsNotFound(err) {
+			return nil, nil, 0, errors.Wrap(err, errDeleteExpired)
+		}
+		refs[i].ExpiredAt = now.DeepCopy()
+		expired = append(expired, o)
+	}
+	return live, expired, wait, nil
+}
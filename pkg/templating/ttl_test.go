@@ -0,0 +1,188 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestTrackExpiry(t *testing.T) {
+	created := metav1.NewTime(time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC))
+	previous := []ChildReference{
+		{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "migrate", CreatedAt: &created, ExpiredAt: &created},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "cm"},
+	}
+	current := []ChildReference{
+		{APIVersion: "batch/v2alpha1", Kind: "Job", Namespace: "default", Name: "migrate"},
+		{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "migrate-2"},
+	}
+	want := []ChildReference{
+		{APIVersion: "batch/v2alpha1", Kind: "Job", Namespace: "default", Name: "migrate", CreatedAt: &created, ExpiredAt: &created},
+		{APIVersion: "batch/v1", Kind: "Job", Namespace: "default", Name: "migrate-2"},
+	}
+	if diff := cmp.Diff(want, trackExpiry(previous, current)); diff != "" {
+		t.Errorf("trackExpiry(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := metav1.NewTime(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC))
+	hourAgo := metav1.NewTime(now.Add(-time.Hour))
+	minuteAgo := metav1.NewTime(now.Add(-time.Minute))
+	child := func(name, ttl string) resource.ChildResource {
+		o := []fake.MockResourceOption{fake.WithGVK(fake.MockChildGVK), fake.WithNamespaceName(name, "default")}
+		if ttl != "" {
+			o = append(o, fake.WithAdditionalAnnotations(map[string]string{TTLAnnotationKey: ttl}))
+		}
+		return fake.NewMockResource(o...)
+	}
+	cm, job := child("cm", ""), child("migrate", "30m")
+
+	type args struct {
+		client client.Client
+		list   []resource.ChildResource
+		refs   []ChildReference
+	}
+	type want struct {
+		live    []resource.ChildResource
+		expired []resource.ChildResource
+		wait    time.Duration
+		refs    []ChildReference
+		err     error
+	}
+	cases := map[string]struct {
+		reason string
+		args   args
+		want   want
+	}{
+		"FirstSeen": {
+			reason: "A child resource with a TTL should be tracked from the first time it's seen.",
+			args: args{
+				client: &test.MockClient{MockDelete: test.NewMockDeleteFn(errBoom)},
+				list:   []resource.ChildResource{cm, job},
+				refs:   []ChildReference{{Name: "cm"}, {Name: "migrate"}},
+			},
+			want: want{
+				live: []resource.ChildResource{cm, job},
+				wait: 30 * time.Minute,
+				refs: []ChildReference{{Name: "cm"}, {Name: "migrate", CreatedAt: &now}},
+			},
+		},
+		"NotExpiredYet": {
+			reason: "A child resource whose TTL has not passed should be applied until it does.",
+			args: args{
+				client: &test.MockClient{MockDelete: test.NewMockDeleteFn(errBoom)},
+				list:   []resource.ChildResource{job},
+				refs:   []ChildReference{{Name: "migrate", CreatedAt: &minuteAgo}},
+			},
+			want: want{
+				live: []resource.ChildResource{job},
+				wait: 29 * time.Minute,
+				refs: []ChildReference{{Name: "migrate", CreatedAt: &minuteAgo}},
+			},
+		},
+		"Expired": {
+			reason: "A child resource whose TTL has passed should be deleted.",
+			args: args{
+				client: &test.MockClient{MockDelete: func(_ context.Context, obj runtime.Object, _ ...client.DeleteOption) error {
+					if diff := cmp.Diff(job, obj); diff != "" {
+						t.Errorf("Delete(...): -want, +got:\n%s", diff)
+					}
+					return nil
+				}},
+				list: []resource.ChildResource{cm, job},
+				refs: []ChildReference{{Name: "cm"}, {Name: "migrate", CreatedAt: &hourAgo}},
+			},
+			want: want{
+				live:    []resource.ChildResource{cm},
+				expired: []resource.ChildResource{job},
+				refs:    []ChildReference{{Name: "cm"}, {Name: "migrate", CreatedAt: &hourAgo, ExpiredAt: &now}},
+			},
+		},
+		"AlreadyExpired": {
+			reason: "A child resource that has already expired should not be applied or deleted again.",
+			args: args{
+				client: &test.MockClient{MockDelete: test.NewMockDeleteFn(errBoom)},
+				list:   []resource.ChildResource{job},
+				refs:   []ChildReference{{Name: "migrate", CreatedAt: &hourAgo, ExpiredAt: &minuteAgo}},
+			},
+			want: want{
+				live:    []resource.ChildResource{},
+				expired: []resource.ChildResource{job},
+				refs:    []ChildReference{{Name: "migrate", CreatedAt: &hourAgo, ExpiredAt: &minuteAgo}},
+			},
+		},
+		"DeleteFailed": {
+			reason: "An error should be returned if an expired child resource cannot be deleted.",
+			args: args{
+				client: &test.MockClient{MockDelete: test.NewMockDeleteFn(errBoom)},
+				list:   []resource.ChildResource{job},
+				refs:   []ChildReference{{Name: "migrate", CreatedAt: &hourAgo}},
+			},
+			want: want{
+				refs: []ChildReference{{Name: "migrate", CreatedAt: &hourAgo}},
+				err:  errors.Wrap(errBoom, errDeleteExpired),
+			},
+		},
+		"InvalidTTL": {
+			reason: "A TTL that is not a positive duration should be rejected.",
+			args: args{
+				client: &test.MockClient{MockDelete: test.NewMockDeleteFn(errBoom)},
+				list:   []resource.ChildResource{child("migrate", "forever")},
+				refs:   []ChildReference{{Name: "migrate"}},
+			},
+			want: want{
+				refs: []ChildReference{{Name: "migrate"}},
+				err:  errors.Errorf(errFmtParseTTL, TTLAnnotationKey, fake.MockChildGVK.Kind, "migrate"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Reconciler{client: tc.args.client}
+			live, expired, wait, err := r.expire(context.Background(), fake.NewMockResource(), tc.args.list, tc.args.refs, now)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("\n%s\nexpire(...): -want error, +got error:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.live, live); diff != "" {
+				t.Errorf("\n%s\nexpire(...): -want live, +got live:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.expired, expired); diff != "" {
+				t.Errorf("\n%s\nexpire(...): -want expired, +got expired:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.wait, wait); diff != "" {
+				t.Errorf("\n%s\nexpire(...): -want wait, +got wait:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.refs, tc.args.refs); diff != "" {
+				t.Errorf("\n%s\nexpire(...): -want refs, +got refs:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+const (
+	// ValuesDataKey is the key in the data of the ConfigMap or Secret whose
+	// value is the YAML document of the values.
+	ValuesDataKey = "values.yaml"
+
+	valuesTimeout = 10 * time.Second
+
+	errGetValuesConfigMap = "cannot get values ConfigMap"
+	errGetValuesSecret    = "cannot get values Secret"
+	errUnmarshalValues    = "cannot unmarshal values"
+	errValuesSource       = "cannot fetch values"
+	errSpecNotObject      = "spec of the parent resource is not an object"
+	errCopyParent         = "cannot copy the parent resource"
+)
+
+// A ValuesSource returns the values that are merged beneath the spec of every
+// parent resource before rendering.
+type ValuesSource interface {
+	Values(ctx context.Context) (map[string]interface{}, error)
+}
+
+// ValuesSourceFunc makes it easier to provide only a function as
+// ValuesSource.
+type ValuesSourceFunc func(ctx context.Context) (map[string]interface{}, error)
+
+// Values calls the ValuesSourceFunc function.
+func (f ValuesSourceFunc) Values(ctx context.Context) (map[string]interface{}, error) {
+	return f(ctx)
+}
+
+// namedValuesSource is a ValuesSource that describes itself.
+type namedValuesSource struct {
+	ValuesSourceFunc
+	name string
+}
+
+func (s namedValuesSource) String() string {
+	return s.name
+}
+
+// NewConfigMapValues returns a ValuesSource that reads the values from the
+// ValuesDataKey of the ConfigMap with given key.
+func NewConfigMapValues(c client.Reader, key types.NamespacedName) ValuesSource {
+	return namedValuesSource{name: "ConfigMap " + key.String(), ValuesSourceFunc: func(ctx context.Context) (map[string]interface{}, error) {
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, key, cm); err != nil {
+			return nil, errors.Wrap(err, errGetValuesConfigMap)
+		}
+		return parseValues([]byte(cm.Data[ValuesDataKey]))
+	}}
+}
+
+// NewSecretValues returns a ValuesSource that reads the values from the
+// ValuesDataKey of the Secret with given key.
+func NewSecretValues(c client.Reader, key types.NamespacedName) ValuesSource {
+	return namedValuesSource{name: "Secret " + key.String() + " (redacted)", ValuesSourceFunc: func(ctx context.Context) (map[string]interface{}, error) {
+		s := &corev1.Secret{}
+		if err := c.Get(ctx, key, s); err != nil {
+			return nil, errors.Wrap(err, errGetValuesSecret)
+		}
+		return parseValues(s.Data[ValuesDataKey])
+	}}
+}
+
+func parseValues(data []byte) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, errors.Wrap(err, errUnmarshalValues)
+	}
+	return values, nil
+}
+
+// NewValuesMergingEngine returns a new *ValuesMergingEngine.
+func NewValuesMergingEngine(e Engine, src ...ValuesSource) *ValuesMergingEngine {
+	return &ValuesMergingEngine{Engine: e, Sources: src}
+}
+
+// ValuesMergingEngine merges the values from its sources beneath the spec of
+// the parent resource and runs the underlying Engine with the result. The
+// fields in the spec of the parent resource take precedence over the values,
+// and the later sources take precedence over the earlier ones.
+type ValuesMergingEngine struct {
+	Engine  Engine
+	Sources []ValuesSource
+}
+
+// Run runs the underlying Engine with a copy of the parent resource whose
+// spec is merged on top of the values.
+func (e *ValuesMergingEngine) Run(ctx context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+	if len(e.Sources) == 0 {
+		return e.Engine.Run(ctx, cr)
+	}
+	merged, err := e.values(ctx)
+	if err != nil {
+		return resource.RenderResult{}, err
+	}
+	spec := map[string]interface{}{}
+	if s, ok := cr.UnstructuredContent()["spec"]; ok {
+		if spec, ok = s.(map[string]interface{}); !ok {
+			return resource.RenderResult{}, errors.New(errSpecNotObject)
+		}
+	}
+	cp, ok := cr.DeepCopyObject().(resource.ParentResource)
+	if !ok {
+		return resource.RenderResult{}, errors.New(errCopyParent)
+	}
+	cp.UnstructuredContent()["spec"] = mergeValues(merged, spec)
+	return e.Engine.Run(ctx, cp)
+}
+
+// InputDigest returns the digest of the merged values of the sources, combined
+// with the digest of the underlying Engine if it reports one, so that the
+// render cache is invalidated when the values change.
+func (e *ValuesMergingEngine) InputDigest(cr resource.ParentResource) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), valuesTimeout)
+	defer cancel()
+	merged, err := e.values(ctx)
+	if err != nil {
+		return "", err
+	}
+	// NOTE: The keys of the maps are marshalled in sorted order, so the
+	// digest is stable.
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return "", errors.Wrap(err, errMarshalValues)
+	}
+	h := sha256.Sum256(data)
+	return withInputDigest(e.Engine, cr, hex.EncodeToString(h[:]))
+}
+
+// values returns the result of merging the values of the sources in order.
+func (e *ValuesMergingEngine) values(ctx context.Context) (map[string]interface{}, error) {
+	readCtx, cancel := context.WithTimeout(ctx, valuesTimeout)
+	defer cancel()
+	merged := map[string]interface{}{}
+	for _, src := range e.Sources {
+		values, err := src.Values(readCtx)
+		if err != nil {
+			return nil, errors.Wrap(err, errValuesSource)
+		}
+		merged = mergeValues(merged, values)
+	}
+	return merged, nil
+}
+
+// withInputDigest returns the given digest combined with the digest of the
+// given Engine if it's an InputDigester.
+func withInputDigest(e Engine, cr resource.ParentResource, digest string) (string, error) {
+	d, ok := e.(InputDigester)
+	if !ok {
+		return digest, nil
+	}
+	inner, err := d.InputDigest(cr)
+	if err != nil {
+		return "", err
+	}
+	return digest + "/" + inner, nil
+}
+
+// ValuesSources returns the sources of the underlying Engine with the sources
+// of the ValuesMergingEngine inserted right beneath the spec of the given
+// parent resource.
+func (e *ValuesMergingEngine) ValuesSources(cr resource.ParentResource) []string {
+	sources := valuesSourcesOf(e.Engine, cr)
+	i := 0
+	for i < len(sources) && !strings.HasPrefix(sources[i], SourceSpec) {
+		i++
+	}
+	result := make([]string, 0, len(sources)+len(e.Sources))
+	result = append(result, sources[:i]...)
+	for j, src := range e.Sources {
+		result = append(result, describe(src, j))
+	}
+	return append(result, sources[i:]...)
+}
+
+// mergeValues returns the result of merging overrides on top of base
+// recursively. Neither of the given maps is modified.
+func mergeValues(base, overrides map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(base))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range overrides {
+		baseMap, baseOK := result[k].(map[string]interface{})
+		overrideMap, overrideOK := v.(map[string]interface{})
+		if baseOK && overrideOK {
+			result[k] = mergeValues(baseMap, overrideMap)
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
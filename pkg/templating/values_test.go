@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+func TestValuesMergingEngine(t *testing.T) {
+	values := func(v map[string]interface{}) ValuesSource {
+		return ValuesSourceFunc(func(_ context.Context) (map[string]interface{}, error) {
+			return v, nil
+		})
+	}
+	cases := map[string]struct {
+		sources  []ValuesSource
+		spec     map[string]interface{}
+		wantSpec map[string]interface{}
+		wantErr  error
+	}{
+		"SpecTakesPrecedence": {
+			sources: []ValuesSource{
+				values(map[string]interface{}{
+					"domain": "cluster.local",
+					"proxy":  map[string]interface{}{"http": "proxy:80", "https": "proxy:443"},
+				}),
+				values(map[string]interface{}{"registry": "mirror.local"}),
+			},
+			spec: map[string]interface{}{
+				"proxy": map[string]interface{}{"http": "other:80"},
+			},
+			wantSpec: map[string]interface{}{
+				"domain":   "cluster.local",
+				"registry": "mirror.local",
+				"proxy":    map[string]interface{}{"http": "other:80", "https": "proxy:443"},
+			},
+		},
+		"SourceFailed": {
+			sources: []ValuesSource{ValuesSourceFunc(func(_ context.Context) (map[string]interface{}, error) {
+				return nil, errBoom
+			})},
+			wantErr: errors.Wrap(errBoom, errValuesSource),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := fake.NewMockResource()
+			if tc.spec != nil {
+				cr.Object["spec"] = tc.spec
+			}
+			var gotSpec interface{}
+			e := NewValuesMergingEngine(EngineFunc(func(_ context.Context, cr resource.ParentResource) (resource.RenderResult, error) {
+				gotSpec = cr.UnstructuredContent()["spec"]
+				return resource.RenderResult{}, nil
+			}), tc.sources...)
+			_, err := e.Run(context.Background(), cr)
+			if diff := cmp.Diff(tc.wantErr, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", diff)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.wantSpec, gotSpec); diff != "" {
+				t.Errorf("Run(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.spec, cr.Object["spec"]); diff != "" {
+				t.Errorf("Run(...): parent resource should not be modified: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValuesMergingEngineInputDigest(t *testing.T) {
+	defaults := map[string]interface{}{"registry": "mirror.local"}
+	e := NewValuesMergingEngine(EngineFunc(func(_ context.Context, _ resource.ParentResource) (resource.RenderResult, error) {
+		return resource.RenderResult{}, nil
+	}), ValuesSourceFunc(func(_ context.Context) (map[string]interface{}, error) {
+		return defaults, nil
+	}))
+	cr := fake.NewMockResource()
+	r := &Reconciler{templating: e, cache: &mockRenderCache{}, revision: "rev", resyncInterval: time.Hour}
+	if err := r.record(context.Background(), cr, nil); err != nil {
+		t.Fatalf("record(...): %s", err)
+	}
+	if _, unchanged := r.unchanged(context.Background(), cr); !unchanged {
+		t.Errorf("unchanged(...): the parent resource should not be rendered again when the values are the same")
+	}
+	defaults = map[string]interface{}{"registry": "other.local"}
+	if _, unchanged := r.unchanged(context.Background(), cr); unchanged {
+		t.Errorf("unchanged(...): the parent resource should be rendered again when only the values change")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/apis/core/v1alpha1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+)
+
+// TypeRenderWarnings indicates whether the rendering of the child resources
+// of the parent resource resulted in warnings.
+const TypeRenderWarnings v1alpha1.ConditionType = "RenderWarnings"
+
+// Reasons the rendering of the child resources of a parent resource did or
+// did not result in warnings.
+const (
+	ReasonRenderWarnings   v1alpha1.ConditionReason = "Child resources are rendered with warnings"
+	ReasonNoRenderWarnings v1alpha1.ConditionReason = "Child resources are rendered without warnings"
+)
+
+// EventReasonRenderWarnings is the reason of the event that is emitted when
+// the warnings of the rendering of the child resources change.
+const EventReasonRenderWarnings event.Reason = "RenderWarnings"
+
+const (
+	errFmtRenderWarnings = "child resources are rendered with warnings: %s"
+	errFmtDeprecatedAPI  = "%s %s uses the deprecated API version %s, use %s instead"
+)
+
+// deprecatedAPIs are the API versions of the kinds that are deprecated, or
+// already removed in the later Kubernetes versions, and the API versions that
+// replace them.
+var deprecatedAPIs = func() map[schema.GroupVersionKind]string {
+	m := map[schema.GroupVersionKind]string{
+		{Group: "extensions", Version: "v1beta1", Kind: "Deployment"}:                         "apps/v1",
+		{Group: "extensions", Version: "v1beta1", Kind: "DaemonSet"}:                          "apps/v1",
+		{Group: "extensions", Version: "v1beta1", Kind: "ReplicaSet"}:                         "apps/v1",
+		{Group: "extensions", Version: "v1beta1", Kind: "NetworkPolicy"}:                      "networking.k8s.io/v1",
+		{Group: "extensions", Version: "v1beta1", Kind: "PodSecurityPolicy"}:                  "policy/v1beta1",
+		{Group: "extensions", Version: "v1beta1", Kind: "Ingress"}:                            "networking.k8s.io/v1beta1",
+		{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}: "apiextensions.k8s.io/v1",
+		{Group: "scheduling.k8s.io", Version: "v1beta1", Kind: "PriorityClass"}:               "scheduling.k8s.io/v1",
+	}
+	for _, v := range []string{"v1beta1", "v1beta2"} {
+		for _, k := range []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet"} {
+			m[schema.GroupVersionKind{Group: "apps", Version: v, Kind: k}] = "apps/v1"
+		}
+	}
+	for _, v := range []string{"v1alpha1", "v1beta1"} {
+		for _, k := range []string{"Role", "ClusterRole", "RoleBinding", "ClusterRoleBinding"} {
+			m[schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: v, Kind: k}] = "rbac.authorization.k8s.io/v1"
+		}
+	}
+	for _, k := range []string{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"} {
+		m[schema.GroupVersionKind{Group: "admissionregistration.k8s.io", Version: "v1beta1", Kind: k}] = "admissionregistration.k8s.io/v1"
+	}
+	return m
+}()
+
+// deprecationWarnings returns a warning for every given child resource whose
+// API version is deprecated.
+func deprecationWarnings(list []resource.ChildResource) []string {
+	var result []string
+	for _, o := range list {
+		gvk := o.GetObjectKind().GroupVersionKind()
+		if replacement, ok := deprecatedAPIs[gvk]; ok {
+			result = append(result, fmt.Sprintf(errFmtDeprecatedAPI, gvk.Kind, o.GetName(), gvk.GroupVersion(), replacement))
+		}
+	}
+	return result
+}
+
+// RenderWarnings returns a condition that indicates the rendering of the
+// child resources of the parent resource resulted in the given warnings.
+func RenderWarnings(warnings []string) v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeRenderWarnings,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonRenderWarnings,
+		Message:            strings.Join(warnings, ", "),
+	}
+}
+
+// NoRenderWarnings returns a condition that indicates the rendering of the
+// child resources of the parent resource resulted in no warnings.
+func NoRenderWarnings() v1alpha1.Condition {
+	return v1alpha1.Condition{
+		Type:               TypeRenderWarnings,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonNoRenderWarnings,
+	}
+}
+
+// warn sets the RenderWarnings condition of the given parent resource to the
+// given warnings of the engine along with the deprecated API versions of the
+// given child resources. The warnings are emitted as an event when they
+// change so that the ones that persist are not repeated at every resync.
+func (r *Reconciler) warn(cr resource.ParentResource, warnings []string, list []resource.ChildResource) error {
+	warnings = append(warnings, deprecationWarnings(list)...)
+	c, err := resource.GetCondition(cr, TypeRenderWarnings)
+	if len(warnings) == 0 {
+		if err == nil && c.Status == corev1.ConditionTrue {
+			return resource.SetConditions(cr, NoRenderWarnings())
+		}
+		return nil
+	}
+	want := RenderWarnings(warnings)
+	if err == nil && c.Status == corev1.ConditionTrue && c.Message == want.Message {
+		return nil
+	}
+	r.recorder.Event(cr, event.Warning(EventReasonRenderWarnings, errors.Errorf(errFmtRenderWarnings, want.Message)))
+	return resource.SetConditions(cr, want)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templating
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+
+	"github.com/crossplane/templating-controller/pkg/resource"
+	"github.com/crossplane/templating-controller/pkg/resource/fake"
+)
+
+type eventRecorder struct {
+	events []event.Event
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, e event.Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) WithAnnotations(_ ...string) event.Recorder {
+	return r
+}
+
+func childOf(apiVersion, kind, name string) resource.ChildResource {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetName(name)
+	return u
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		list   []resource.ChildResource
+		want   []string
+	}{
+		"Supported": {
+			reason: "The child resources with supported API versions should not result in warnings.",
+			list:   []resource.ChildResource{childOf("apps/v1", "Deployment", "cool"), childOf("v1", "ConfigMap", "cool")},
+		},
+		"Deprecated": {
+			reason: "Every child resource with a deprecated API version should result in a warning.",
+			list: []resource.ChildResource{
+				childOf("extensions/v1beta1", "Deployment", "cool"),
+				childOf("v1", "ConfigMap", "cool"),
+				childOf("rbac.authorization.k8s.io/v1beta1", "ClusterRole", "admin"),
+			},
+			want: []string{
+				fmt.Sprintf(errFmtDeprecatedAPI, "Deployment", "cool", "extensions/v1beta1", "apps/v1"),
+				fmt.Sprintf(errFmtDeprecatedAPI, "ClusterRole", "admin", "rbac.authorization.k8s.io/v1beta1", "rbac.authorization.k8s.io/v1"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, deprecationWarnings(tc.list)); diff != "" {
+				t.Errorf("\n%s\ndeprecationWarnings(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestWarn(t *testing.T) {
+	deprecated := []resource.ChildResource{childOf("extensions/v1beta1", "Deployment", "cool")}
+	withWarnings := func(warnings ...string) *fake.MockResource {
+		cr := fake.NewMockResource()
+		if err := resource.SetConditions(cr, RenderWarnings(warnings)); err != nil {
+			t.Fatal(err)
+		}
+		return cr
+	}
+	type want struct {
+		condition corev1.ConditionStatus
+		message   string
+		events    int
+	}
+	cases := map[string]struct {
+		reason   string
+		cr       *fake.MockResource
+		warnings []string
+		list     []resource.ChildResource
+		want     want
+	}{
+		"NoWarnings": {
+			reason: "A rendering without warnings should not set the condition.",
+			cr:     fake.NewMockResource(),
+			want:   want{condition: corev1.ConditionUnknown},
+		},
+		"Resolved": {
+			reason: "The condition should be cleared once the warnings are resolved.",
+			cr:     withWarnings("careful"),
+			want:   want{condition: corev1.ConditionFalse},
+		},
+		"NewWarnings": {
+			reason:   "The warnings of the engine and the deprecated API versions should be reported in the condition and an event.",
+			cr:       fake.NewMockResource(),
+			warnings: []string{"careful"},
+			list:     deprecated,
+			want: want{
+				condition: corev1.ConditionTrue,
+				message:   "careful, " + fmt.Sprintf(errFmtDeprecatedAPI, "Deployment", "cool", "extensions/v1beta1", "apps/v1"),
+				events:    1,
+			},
+		},
+		"SameWarnings": {
+			reason:   "The warnings that are already reported should not be emitted as an event again.",
+			cr:       withWarnings("careful"),
+			warnings: []string{"careful"},
+			want:     want{condition: corev1.ConditionTrue, message: "careful"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			r := &Reconciler{recorder: rec}
+			if err := r.warn(tc.cr, tc.warnings, tc.list); err != nil {
+				t.Fatalf("\n%s\nwarn(...): %s", tc.reason, err)
+			}
+			c, _ := resource.GetCondition(tc.cr, TypeRenderWarnings)
+			got := want{condition: c.Status, message: c.Message, events: len(rec.events)}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nwarn(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
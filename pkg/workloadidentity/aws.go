@@ -0,0 +1,243 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadidentity
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	awsUsername = "AWS"
+
+	errFmtECRHost      = "%s is not an ECR registry"
+	errNoAWSIdentity   = "neither AWS_WEB_IDENTITY_TOKEN_FILE nor AWS_ACCESS_KEY_ID is set"
+	errAssumeRole      = "cannot assume the role of the web identity"
+	errParseAssumeRole = "cannot parse the credentials of the assumed role"
+	errGetECRToken     = "cannot get the authorization token of ECR"
+	errParseECRToken   = "cannot parse the authorization token of ECR"
+)
+
+// ecrHost matches the hosts of the private ECR registries, e.g.
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com, and captures their region
+// and the domain of their partition, e.g. amazonaws.com.cn in China.
+var ecrHost = regexp.MustCompile(`^\d{12}\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.(amazonaws\.com(?:\.cn)?)$`)
+
+// NewAWSSource returns a new *AWSSource that is configured with the
+// environment variables of the AWS SDKs, e.g. the ones that IAM roles for
+// service accounts inject.
+func NewAWSSource() *AWSSource {
+	return &AWSSource{
+		Client: &http.Client{Timeout: DefaultTimeout},
+		Endpoint: func(service, region, domain string) string {
+			return "https://" + service + "." + region + "." + domain + "/"
+		},
+		RoleARN:         os.Getenv("AWS_ROLE_ARN"),
+		RoleSessionName: envOr("AWS_ROLE_SESSION_NAME", "templating-controller"),
+		TokenFile:       os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		now:             time.Now,
+	}
+}
+
+// An AWSSource returns the authorization tokens of Elastic Container
+// Registry. The token is requested with the credentials of the role that the
+// web identity in its TokenFile assumes if it's set, and with its static
+// AccessKeyID otherwise.
+type AWSSource struct {
+	Client *http.Client
+
+	// Endpoint returns the endpoint of the given service in the given
+	// region of the partition with the given domain, e.g. amazonaws.com.
+	Endpoint func(service, region, domain string) string
+
+	RoleARN         string
+	RoleSessionName string
+	TokenFile       string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	now func() time.Time
+}
+
+// awsCredentials are the credentials that the requests to AWS are signed
+// with.
+type awsCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+}
+
+// Credentials returns an authorization token of the ECR registry at the given
+// host. The endpoints of STS and ECR are in the region and the partition of
+// the registry.
+func (s *AWSSource) Credentials(ctx context.Context, host string) (Credentials, error) {
+	m := ecrHost.FindStringSubmatch(host)
+	if m == nil {
+		return Credentials{}, errors.Errorf(errFmtECRHost, host)
+	}
+	region, domain := m[1], m[2]
+	ac, err := s.awsCredentials(ctx, region, domain)
+	if err != nil {
+		return Credentials{}, err
+	}
+	body := []byte("{}")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint("api.ecr", region, domain), bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonEC2ContainerRegistry_V20150921.GetAuthorizationToken")
+	signV4(req, body, "ecr", region, ac, s.now())
+	out := &struct {
+		AuthorizationData []struct {
+			AuthorizationToken string  `json:"authorizationToken"`
+			ExpiresAt          float64 `json:"expiresAt"`
+		} `json:"authorizationData"`
+	}{}
+	if err := doJSON(s.Client, req, out); err != nil {
+		return Credentials{}, errors.Wrap(err, errGetECRToken)
+	}
+	if len(out.AuthorizationData) == 0 {
+		return Credentials{}, errors.New(errParseECRToken)
+	}
+	cr, err := decodeBasic(out.AuthorizationData[0].AuthorizationToken)
+	if err != nil || cr.Username != awsUsername {
+		return Credentials{}, errors.New(errParseECRToken)
+	}
+	cr.Expires = time.Unix(int64(out.AuthorizationData[0].ExpiresAt), 0)
+	return cr, nil
+}
+
+// awsCredentials returns the credentials that the request to ECR is signed
+// with.
+func (s *AWSSource) awsCredentials(ctx context.Context, region, domain string) (awsCredentials, error) {
+	if s.TokenFile == "" {
+		if s.AccessKeyID == "" {
+			return awsCredentials{}, errors.New(errNoAWSIdentity)
+		}
+		return awsCredentials{AccessKeyID: s.AccessKeyID, SecretAccessKey: s.SecretAccessKey, SessionToken: s.SessionToken}, nil
+	}
+	token, err := ioutil.ReadFile(filepath.Clean(s.TokenFile))
+	if err != nil {
+		return awsCredentials{}, errors.Wrap(err, errReadTokenFile)
+	}
+	// NOTE: AssumeRoleWithWebIdentity is not signed; the web identity token
+	// is the proof of the identity.
+	q := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {s.RoleARN},
+		"RoleSessionName":  {s.RoleSessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Endpoint("sts", region, domain)+"?"+q.Encode(), nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return awsCredentials{}, errors.Wrap(err, errAssumeRole)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, errors.Wrap(errors.Errorf(errFmtStatusCode, req.URL.Host, resp.Status), errAssumeRole)
+	}
+	out := &struct {
+		Credentials awsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}{}
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return awsCredentials{}, errors.Wrap(err, errParseAssumeRole)
+	}
+	return out.Credentials, nil
+}
+
+// signV4 signs the given request with the given body for the given service
+// in the given region with the AWS Signature Version 4. All of the headers
+// of the request are signed.
+func signV4(req *http.Request, body []byte, service, region string, ac awsCredentials, t time.Time) {
+	amzDate := t.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if ac.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", ac.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	canonical := &strings.Builder{}
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+	p := req.URL.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	request := strings.Join([]string{
+		req.Method,
+		p,
+		strings.Replace(req.URL.Query().Encode(), "+", "%20", -1),
+		canonical.String(),
+		signed,
+		sha256Hex(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(request))
+	key := []byte("AWS4" + ac.SecretAccessKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+ac.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadidentity
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSignV4(t *testing.T) {
+	// The get-vanilla case of the test suite of AWS Signature Version 4.
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signV4(req, nil, "service", "us-east-1", awsCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if diff := cmp.Diff(want, req.Header.Get("Authorization")); diff != "" {
+		t.Errorf("signV4(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestNewAWSSourceEndpoint(t *testing.T) {
+	cases := map[string]struct {
+		region string
+		domain string
+		want   string
+	}{
+		"Commercial": {
+			region: "eu-west-1",
+			domain: "amazonaws.com",
+			want:   "https://sts.eu-west-1.amazonaws.com/",
+		},
+		"China": {
+			region: "cn-north-1",
+			domain: "amazonaws.com.cn",
+			want:   "https://sts.cn-north-1.amazonaws.com.cn/",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, NewAWSSource().Endpoint("sts", tc.region, tc.domain)); diff != "" {
+				t.Errorf("Endpoint(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAWSSource(t *testing.T) {
+	expires := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	dir, err := ioutil.TempDir("", "aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint:errcheck
+	token := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(token, []byte("web-identity\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		reason   string
+		host     string
+		endpoint string
+	}{
+		"Commercial": {
+			reason:   "The endpoints of a registry in a commercial region should be in the amazonaws.com domain.",
+			host:     "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+			endpoint: "eu-west-1/amazonaws.com",
+		},
+		"China": {
+			reason:   "The endpoints of a registry in a China region should be in the amazonaws.com.cn domain.",
+			host:     "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn",
+			endpoint: "cn-north-1/amazonaws.com.cn",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/sts/" + tc.endpoint + "/":
+					if diff := cmp.Diff("web-identity", r.URL.Query().Get("WebIdentityToken")); diff != "" {
+						t.Errorf("AssumeRoleWithWebIdentity: -want token, +got token:\n%s", diff)
+					}
+					_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAEXAMPLE</AccessKeyId>
+      <SecretAccessKey>secret</SecretAccessKey>
+      <SessionToken>session</SessionToken>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+				case "/api.ecr/" + tc.endpoint + "/":
+					if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ASIAEXAMPLE/") ||
+						r.Header.Get("X-Amz-Security-Token") != "session" {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					fmt.Fprintf(w, `{"authorizationData": [{"authorizationToken": "QVdTOnBhc3N3b3Jk", "expiresAt": %d}]}`, expires.Unix())
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			s := &AWSSource{
+				Client: srv.Client(),
+				Endpoint: func(service, region, domain string) string {
+					return srv.URL + "/" + service + "/" + region + "/" + domain + "/"
+				},
+				RoleARN:         "arn:aws:iam::123456789012:role/cool",
+				RoleSessionName: "cool",
+				TokenFile:       token,
+				now:             time.Now,
+			}
+			got, err := s.Credentials(context.Background(), tc.host)
+			if err != nil {
+				t.Fatalf("\n%s\nCredentials(...): %s", tc.reason, err)
+			}
+			if diff := cmp.Diff(Credentials{Username: "AWS", Password: "password", Expires: expires}, got, cmp.Comparer(func(a, b time.Time) bool {
+				return a.Equal(b)
+			})); diff != "" {
+				t.Errorf("\n%s\nCredentials(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestAWSSourceNotECR(t *testing.T) {
+	if _, err := NewAWSSource().Credentials(context.Background(), "registry.io"); err == nil {
+		t.Errorf("Credentials(...): a host that is not an ECR registry should be rejected")
+	}
+}
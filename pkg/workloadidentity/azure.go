@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadidentity
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// AzureIMDSTokenURL is the endpoint of the instance metadata service that
+	// returns the access token of the managed identity of the workload.
+	AzureIMDSTokenURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+	// AzureUsername is the username that the refresh tokens of Azure
+	// Container Registry are used with.
+	AzureUsername = "00000000-0000-0000-0000-000000000000"
+
+	// DefaultAzureAuthorityHost is the authority that the federated tokens of
+	// the Azure workload identity are exchanged with, unless
+	// AZURE_AUTHORITY_HOST is set.
+	DefaultAzureAuthorityHost = "https://login.microsoftonline.com/"
+
+	azureResource = "https://management.azure.com/"
+
+	errParseAzureToken = "cannot parse the access token of Azure"
+	errExchangeAzure   = "cannot exchange the access token of Azure for a token of the registry"
+)
+
+// NewAzureSource returns a new *AzureSource that is configured with the
+// environment variables that the Azure workload identity webhook injects.
+func NewAzureSource() *AzureSource {
+	return &AzureSource{
+		Client:        &http.Client{Timeout: DefaultTimeout},
+		IMDSTokenURL:  AzureIMDSTokenURL,
+		AuthorityHost: envOr("AZURE_AUTHORITY_HOST", DefaultAzureAuthorityHost),
+		TenantID:      os.Getenv("AZURE_TENANT_ID"),
+		ClientID:      os.Getenv("AZURE_CLIENT_ID"),
+		TokenFile:     os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+	}
+}
+
+// An AzureSource exchanges an access token of Azure Active Directory for a
+// refresh token of Azure Container Registry. The access token is requested
+// with the federated token of the Azure workload identity if its TokenFile
+// is set, and from the instance metadata service, i.e. for the managed
+// identity of the node or of the given ClientID, otherwise.
+type AzureSource struct {
+	Client        *http.Client
+	IMDSTokenURL  string
+	AuthorityHost string
+	TenantID      string
+	ClientID      string
+	TokenFile     string
+}
+
+// Credentials returns a refresh token of the registry at the given host.
+func (s *AzureSource) Credentials(ctx context.Context, host string) (Credentials, error) {
+	t, err := s.accessToken(ctx)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, errParseAzureToken)
+	}
+	form := url.Values{"grant_type": {"access_token"}, "service": {host}, "access_token": {t.AccessToken}}
+	if s.TenantID != "" {
+		form.Set("tenant", s.TenantID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/oauth2/exchange", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rt := &struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if err := doJSON(s.Client, req, rt); err != nil {
+		return Credentials{}, errors.Wrap(err, errExchangeAzure)
+	}
+	// NOTE: The refresh tokens of the registry outlive the access tokens they
+	// are exchanged for, so they are refreshed along with the access tokens.
+	return Credentials{Username: AzureUsername, Password: rt.RefreshToken, Expires: t.expires()}, nil
+}
+
+func (s *AzureSource) accessToken(ctx context.Context) (*oauthToken, error) {
+	var req *http.Request
+	if s.TokenFile != "" {
+		assertion, err := ioutil.ReadFile(filepath.Clean(s.TokenFile))
+		if err != nil {
+			return nil, errors.Wrap(err, errReadTokenFile)
+		}
+		form := url.Values{
+			"grant_type":            {"client_credentials"},
+			"client_id":             {s.ClientID},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+			"scope":                 {azureResource + ".default"},
+		}
+		u := strings.TrimSuffix(s.AuthorityHost, "/") + "/" + s.TenantID + "/oauth2/v2.0/token"
+		if req, err = http.NewRequestWithContext(ctx, http.MethodPost, u, strings.NewReader(form.Encode())); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		q := url.Values{"api-version": {"2018-02-01"}, "resource": {azureResource}}
+		if s.ClientID != "" {
+			q.Set("client_id", s.ClientID)
+		}
+		var err error
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, s.IMDSTokenURL+"?"+q.Encode(), nil); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+	}
+	t := &oauthToken{}
+	return t, doJSON(s.Client, req, t)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadidentity
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// GCPTokenURL is the endpoint of the metadata server that returns the
+	// access token of the service account of the workload.
+	GCPTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+	// GCPUsername is the username that the access tokens of GCP are used
+	// with.
+	GCPUsername = "oauth2accesstoken"
+
+	errParseGCPToken = "cannot parse the access token of the metadata server"
+)
+
+// NewGCPSource returns a new *GCPSource that asks the metadata server of GCP
+// for the access tokens.
+func NewGCPSource() *GCPSource {
+	return &GCPSource{Client: &http.Client{Timeout: DefaultTimeout}, TokenURL: GCPTokenURL}
+}
+
+// A GCPSource returns the access token of the service account of the
+// workload, e.g. the one that GKE Workload Identity binds to the service
+// account of the controller, as the password of Artifact Registry and
+// Container Registry.
+type GCPSource struct {
+	Client   *http.Client
+	TokenURL string
+}
+
+// Credentials returns an access token for any host.
+func (s *GCPSource) Credentials(ctx context.Context, _ string) (Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.TokenURL, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	t := &oauthToken{}
+	if err := doJSON(s.Client, req, t); err != nil {
+		return Credentials{}, errors.Wrap(err, errParseGCPToken)
+	}
+	return Credentials{Username: GCPUsername, Password: t.AccessToken, Expires: t.expires()}, nil
+}
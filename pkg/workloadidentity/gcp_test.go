@@ -0,0 +1,52 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workloadidentity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGCPSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token": "t0k3n", "expires_in": 3599, "token_type": "Bearer"}`))
+	}))
+	defer srv.Close()
+
+	s := &GCPSource{Client: srv.Client(), TokenURL: srv.URL}
+	got, err := s.Credentials(context.Background(), "europe-docker.pkg.dev")
+	if err != nil {
+		t.Fatalf("Credentials(...): %s", err)
+	}
+	if diff := cmp.Diff(Credentials{Username: GCPUsername, Password: "t0k3n"}, got, cmp.FilterPath(func(p cmp.Path) bool {
+		return p.Last().String() == ".Expires"
+	}, cmp.Ignore())); diff != "" {
+		t.Errorf("Credentials(...): -want, +got:\n%s", diff)
+	}
+	if got.Expires.Before(time.Now().Add(time.Hour - time.Minute)) {
+		t.Errorf("Credentials(...): the credentials should expire with the access token")
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package workloadidentity exchanges the identity of the workload in a cloud,
+// e.g. the service account of the controller that is bound to a cloud
+// identity, for the credentials of the container registries of that cloud.
+package workloadidentity
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the default timeout of every request of the sources.
+const DefaultTimeout = 2 * time.Minute
+
+const (
+	errFmtStatusCode = "%s responded with %s"
+	errReadTokenFile = "cannot read the federated token file"
+	errBasicAuth     = "credentials are not in username:password form"
+)
+
+// Credentials are the username and the password that authenticate to a
+// registry.
+type Credentials struct {
+	Username string
+	Password string
+
+	// Expires is when the credentials expire.
+	Expires time.Time
+}
+
+// A Source returns the credentials for the registry at the given host.
+type Source interface {
+	Credentials(ctx context.Context, host string) (Credentials, error)
+}
+
+// oauthToken is an OAuth 2.0 access token response.
+type oauthToken struct {
+	AccessToken string `json:"access_token"`
+
+	// ExpiresIn is a number in the responses of GCP and of Azure Active
+	// Directory, and a string in the responses of the instance metadata
+	// service of Azure.
+	ExpiresIn json.Number `json:"expires_in"`
+
+	received time.Time
+}
+
+func (t *oauthToken) expires() time.Time {
+	n, err := t.ExpiresIn.Int64()
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	return t.received.Add(time.Duration(n) * time.Second)
+}
+
+// UnmarshalJSON unmarshals the token and records when it's received.
+func (t *oauthToken) UnmarshalJSON(data []byte) error {
+	type token oauthToken
+	if err := json.Unmarshal(data, (*token)(t)); err != nil {
+		return err
+	}
+	t.received = time.Now()
+	return nil
+}
+
+// doJSON does the given request and decodes its JSON response into the given
+// object.
+func doJSON(c *http.Client, req *http.Request, obj interface{}) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf(errFmtStatusCode, req.URL.Host, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+// decodeBasic decodes the given base64 encoded username:password pair.
+func decodeBasic(s string) (Credentials, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return Credentials{}, err
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return Credentials{}, errors.New(errBasicAuth)
+	}
+	return Credentials{Username: parts[0], Password: parts[1]}, nil
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,21 @@
+package database
+
+// The parent resource is unified with the parent field, so its spec is
+// validated against these constraints before anything is rendered.
+parent: {
+	metadata: name: string
+	spec: engineVersion: *"5.7" | "5.6" | "8.0"
+}
+
+resources: [{
+	apiVersion: "database.crossplane.io/v1alpha1"
+	kind:       "MySQLInstance"
+	metadata: name: "\(parent.metadata.name)-sql"
+	spec: {
+		engineVersion: parent.spec.engineVersion
+		// A secret is exported by providing the secret name
+		// to export it under. This is the name of the secret
+		// in the crossplane cluster, and it's scoped to this claim's namespace.
+		writeConnectionSecretToRef: name: "sql"
+	}
+}]
@@ -0,0 +1,385 @@
+//go:build integration
+// +build integration
+
+/*
+Copyright 2020 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	kustomizeapi "sigs.k8s.io/kustomize/api/types"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test/integration"
+	"github.com/crossplane/crossplane/apis/packages/v1alpha1"
+
+	templatingv1alpha1 "github.com/crossplane/templating-controller/api/v1alpha1"
+	"github.com/crossplane/templating-controller/pkg/operations/helm3"
+	"github.com/crossplane/templating-controller/pkg/operations/kustomize"
+	"github.com/crossplane/templating-controller/pkg/templating"
+)
+
+// The tests run against the kube-apiserver and etcd binaries of envtest,
+// found in $KUBEBUILDER_ASSETS, unless USE_EXISTING_CLUSTER is true, in which
+// case they run against the cluster of the current kubeconfig, e.g. a kind
+// cluster.
+const envUseExistingCluster = "USE_EXISTING_CLUSTER"
+
+const (
+	examplesDir = "../../examples"
+	namespace   = "default"
+
+	timeout  = 2 * time.Minute
+	interval = 500 * time.Millisecond
+)
+
+// A stack is one of the example stacks in the examples directory.
+type stack struct {
+	dir       string
+	resources string
+	engine    func(b *templatingv1alpha1.Behavior, resourceDir string) templating.Engine
+}
+
+func TestKustomizeStack(t *testing.T) {
+	s := stack{
+		dir:       "kustomize",
+		resources: "resources",
+		engine: func(b *templatingv1alpha1.Behavior, resourceDir string) templating.Engine {
+			c := b.Engine.Kustomize.DeepCopy()
+			k := c.Kustomization
+			if k == nil {
+				k = &kustomizeapi.Kustomization{}
+			}
+			return kustomize.NewKustomizeEngine(k,
+				kustomize.WithResourcePath(resourceDir),
+				kustomize.WithOverlayGenerator(kustomize.NewPatchOverlayGenerator(c.Overlays)),
+			)
+		},
+	}
+	c, cr, cleanup := s.setup(t)
+	defer cleanup()
+
+	// Render and apply.
+	cm := child("v1", "ConfigMap", "sample-config")
+	app := child("apps/v1", "Deployment", "sample-app")
+	waitFor(t, "the child resources to be applied", func() (bool, error) {
+		return all(
+			hasField(c, cm, "Hello from the kustomize stack", "data", "message"),
+			hasField(c, app, int64(2), "spec", "replicas"),
+			hasRefs(c, cr, cm, app),
+		)
+	})
+	for _, o := range []*unstructured.Unstructured{cm, app} {
+		if err := ownedBy(c, o, cr); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Update.
+	update(t, c, cr, func(u *unstructured.Unstructured) {
+		must(t, unstructured.SetNestedField(u.Object, "Hello again", "spec", "message"))
+		must(t, unstructured.SetNestedField(u.Object, int64(3), "spec", "replicas"))
+	})
+	waitFor(t, "the child resources to be updated", func() (bool, error) {
+		return all(
+			hasField(c, cm, "Hello again", "data", "message"),
+			hasField(c, app, int64(3), "spec", "replicas"),
+		)
+	})
+
+	// Delete.
+	remove(t, c, cr, cm, app)
+}
+
+func TestHelm3Stack(t *testing.T) {
+	s := stack{
+		dir:       "helm3",
+		resources: "chart",
+		engine: func(_ *templatingv1alpha1.Behavior, resourceDir string) templating.Engine {
+			return helm3.NewHelm3Engine(helm3.WithResourcePath(resourceDir))
+		},
+	}
+	c, cr, cleanup := s.setup(t)
+	defer cleanup()
+
+	// Render and apply.
+	cm := child("v1", "ConfigMap", "sample-config")
+	app := child("apps/v1", "Deployment", "sample-app")
+	cache := child("apps/v1", "Deployment", "sample-cache")
+	waitFor(t, "the child resources to be applied", func() (bool, error) {
+		return all(
+			hasField(c, cm, "Hello from the helm3 stack", "data", "message"),
+			hasField(c, app, int64(2), "spec", "replicas"),
+			exists(c, cache),
+			hasRefs(c, cr, cm, app, cache),
+		)
+	})
+
+	// Update. The child resources that are no longer rendered are dropped
+	// from the inventory of the instance in status.resourceRefs, but they are
+	// not pruned, so the cache is left behind.
+	update(t, c, cr, func(u *unstructured.Unstructured) {
+		must(t, unstructured.SetNestedField(u.Object, "Hello again", "spec", "message"))
+		must(t, unstructured.SetNestedField(u.Object, false, "spec", "cache", "enabled"))
+	})
+	waitFor(t, "the child resources to be updated and the cache to be dropped from the inventory", func() (bool, error) {
+		return all(
+			hasField(c, cm, "Hello again", "data", "message"),
+			hasRefs(c, cr, cm, app),
+			exists(c, cache),
+		)
+	})
+
+	// Delete. The cache is not in the inventory anymore, so it's not deleted
+	// with the instance.
+	remove(t, c, cr, cm, app)
+	must(t, client.IgnoreNotFound(c.Delete(context.Background(), cache)))
+}
+
+// setup starts a manager with a templating controller for the parent
+// resources of the stack, creates the sample instance of the stack and
+// returns a client, the instance and a function that tears the test
+// environment down.
+func (s stack) setup(t *testing.T) (client.Client, *unstructured.Unstructured, func()) {
+	t.Helper()
+	dir := filepath.Join(examplesDir, s.dir)
+
+	cfg, err := restConfig()
+	if err != nil {
+		t.Fatalf("cannot get the config of the existing cluster: %s", err)
+	}
+	m, err := integration.New(cfg, integration.WithCRDPaths(filepath.Join(dir, "crds")))
+	if err != nil {
+		t.Fatalf("cannot start the test environment: %s", err)
+	}
+	cleanup := func() {
+		if err := m.Cleanup(); err != nil {
+			t.Errorf("cannot clean up the test environment: %s", err)
+		}
+	}
+	defer func() {
+		if t.Failed() {
+			cleanup()
+		}
+	}()
+
+	sd := &v1alpha1.StackDefinition{}
+	must(t, read(filepath.Join(dir, "stackdefinition.yaml"), sd))
+	b, err := templatingv1alpha1.BehaviorOf(sd)
+	if err != nil {
+		t.Fatalf("invalid behavior of the StackDefinition: %s", err)
+	}
+	resourceDir, err := filepath.Abs(filepath.Join(dir, s.resources))
+	must(t, err)
+	gvk := schema.FromAPIVersionAndKind(b.CRD.APIVersion, b.CRD.Kind)
+	must(t, templating.Setup(m, templating.SetupOptions{
+		GVK:     gvk,
+		Engine:  s.engine(b, resourceDir),
+		Options: []templating.ReconcilerOption{templating.WithShortWait(time.Second)},
+	}))
+	m.Run()
+
+	c := m.GetClient()
+	cr := &unstructured.Unstructured{}
+	must(t, read(filepath.Join(dir, "cr.yaml"), cr))
+	must(t, c.Create(context.Background(), cr))
+	return c, cr, cleanup
+}
+
+// remove deletes the given instance and waits until it and the given child
+// resources are gone.
+func remove(t *testing.T, c client.Client, cr *unstructured.Unstructured, children ...*unstructured.Unstructured) {
+	t.Helper()
+	must(t, c.Delete(context.Background(), cr))
+	waitFor(t, "the instance and its child resources to be deleted", func() (bool, error) {
+		checks := []check{gone(c, cr)}
+		for _, o := range children {
+			checks = append(checks, gone(c, o))
+		}
+		return all(checks...)
+	})
+}
+
+// restConfig returns the config of the existing cluster if the tests should
+// run against one, or nil if they should run against envtest.
+func restConfig() (*rest.Config, error) {
+	if os.Getenv(envUseExistingCluster) != "true" {
+		return nil, nil
+	}
+	return config.GetConfig()
+}
+
+// read reads the YAML manifest in the given file into the given object.
+func read(path string, obj interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	return sigsyaml.Unmarshal(data, obj)
+}
+
+// child returns an object that identifies a child resource of the sample
+// instance.
+func child(apiVersion, kind, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(apiVersion)
+	u.SetKind(kind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+// update applies the given change to the latest version of the given
+// instance, retrying on conflicts with the controller.
+func update(t *testing.T, c client.Client, cr *unstructured.Unstructured, fn func(*unstructured.Unstructured)) {
+	t.Helper()
+	waitFor(t, "the instance to be updated", func() (bool, error) {
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.GetName()}, cr); err != nil {
+			return false, err
+		}
+		fn(cr)
+		err := c.Update(context.Background(), cr)
+		if kerrors.IsConflict(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// waitFor polls the given condition until it's met, fails or times out.
+func waitFor(t *testing.T, what string, fn wait.ConditionFunc) {
+	t.Helper()
+	if err := wait.PollImmediate(interval, timeout, fn); err != nil {
+		t.Fatalf("cannot wait for %s: %s", what, err)
+	}
+}
+
+// A check reports whether a condition that is polled is met.
+type check func() (bool, error)
+
+// all returns true if all the given checks are met.
+func all(checks ...check) (bool, error) {
+	for _, fn := range checks {
+		if ok, err := fn(); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// get gets the latest version of the given object, which is reported as
+// missing rather than failed if it does not exist.
+func get(c client.Client, o *unstructured.Unstructured) (bool, error) {
+	err := c.Get(context.Background(), types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}, o)
+	if kerrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func exists(c client.Client, o *unstructured.Unstructured) check {
+	return func() (bool, error) {
+		return get(c, o)
+	}
+}
+
+func gone(c client.Client, o *unstructured.Unstructured) check {
+	return func() (bool, error) {
+		found, err := get(c, o.DeepCopy())
+		return !found && err == nil, err
+	}
+}
+
+func hasField(c client.Client, o *unstructured.Unstructured, want interface{}, path ...string) check {
+	return func() (bool, error) {
+		if found, err := get(c, o); !found || err != nil {
+			return false, err
+		}
+		got, _, err := unstructured.NestedFieldNoCopy(o.Object, path...)
+		return got == want, err
+	}
+}
+
+func hasRefs(c client.Client, cr *unstructured.Unstructured, children ...*unstructured.Unstructured) check {
+	return func() (bool, error) {
+		if found, err := get(c, cr); !found || err != nil {
+			return false, err
+		}
+		raw, _, err := unstructured.NestedSlice(cr.Object, "status", "resourceRefs")
+		if err != nil {
+			return false, err
+		}
+		data, err := json.Marshal(raw)
+		if err != nil {
+			return false, err
+		}
+		refs := []templating.ChildReference{}
+		if err := json.Unmarshal(data, &refs); err != nil {
+			return false, err
+		}
+		listed := map[string]bool{}
+		for _, ref := range refs {
+			listed[ref.Kind+"/"+ref.Namespace+"/"+ref.Name] = true
+		}
+		if len(listed) != len(children) {
+			return false, nil
+		}
+		for _, o := range children {
+			if !listed[o.GetKind()+"/"+o.GetNamespace()+"/"+o.GetName()] {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+}
+
+// ownedBy returns an error if the given child resource is not controlled by
+// the given instance.
+func ownedBy(c client.Client, o, cr *unstructured.Unstructured) error {
+	if _, err := get(c, o); err != nil {
+		return err
+	}
+	for _, ref := range o.GetOwnerReferences() {
+		if ref.UID == cr.GetUID() && ref.Controller != nil && *ref.Controller {
+			return nil
+		}
+	}
+	return errors.Errorf("%s %s is not controlled by %s %s", o.GetKind(), o.GetName(), cr.GetKind(), cr.GetName())
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}